@@ -1,26 +1,40 @@
 package main
 
 import (
+	"archive/zip"
 	"bytes"
-	"database/sql"
+	"context"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
+	"image"
+	"image/png"
+	"io"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
 	"strings"
 	"testing"
 	"time"
 
+	"github.com/LonleySailor/privatepaste/backend/internal/auth"
 	"github.com/LonleySailor/privatepaste/backend/internal/config"
 	"github.com/LonleySailor/privatepaste/backend/internal/database"
 	"github.com/LonleySailor/privatepaste/backend/internal/handlers"
 	"github.com/LonleySailor/privatepaste/backend/internal/middleware"
 	"github.com/LonleySailor/privatepaste/backend/internal/models"
+	"github.com/LonleySailor/privatepaste/backend/internal/services"
+	"github.com/LonleySailor/privatepaste/backend/pkg/render"
 	"github.com/LonleySailor/privatepaste/backend/pkg/utils"
 	"github.com/LonleySailor/privatepaste/backend/pkg/validation"
 	"github.com/gorilla/mux"
-	_ "github.com/mattn/go-sqlite3"
 )
 
 // Test server setup
@@ -41,58 +55,28 @@ func setupTestServer(t *testing.T) *TestServer {
 		JWTSecret:    "test-secret-key-for-testing-only",
 	}
 
-	// Create database connection manually for testing
-	sqlDB, err := sql.Open("sqlite3", tempDB)
+	// Create database with the same migrations as production
+	db, err := database.NewSQLiteDB(tempDB)
 	if err != nil {
 		t.Fatalf("Failed to open test database: %v", err)
 	}
 
-	// Enable foreign keys
-	if _, err := sqlDB.Exec("PRAGMA foreign_keys = ON;"); err != nil {
-		t.Fatalf("Failed to enable foreign keys: %v", err)
-	}
-
-	// Create tables manually for testing
-	createUsersSQL := `
-	CREATE TABLE users (
-		id INTEGER PRIMARY KEY AUTOINCREMENT,
-		username TEXT UNIQUE NOT NULL,
-		password_hash TEXT NOT NULL,
-		created_at DATETIME DEFAULT CURRENT_TIMESTAMP
-	);`
-
-	createPastesSQL := `
-	CREATE TABLE pastes (
-		id TEXT PRIMARY KEY,
-		content TEXT NOT NULL,
-		language TEXT,
-		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
-		expires_at DATETIME,
-		password_hash TEXT,
-		user_id INTEGER,
-		FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE SET NULL
-	);`
-
-	if _, err := sqlDB.Exec(createUsersSQL); err != nil {
-		t.Fatalf("Failed to create users table: %v", err)
-	}
-
-	if _, err := sqlDB.Exec(createPastesSQL); err != nil {
-		t.Fatalf("Failed to create pastes table: %v", err)
-	}
-
-	// Create database wrapper
-	db := &database.Database{DB: sqlDB}
-
 	// Create repositories and utilities
 	pasteRepo := models.NewPasteRepository(db.DB)
+	jobRepo := models.NewJobRepository(db.DB)
 	userRepo := models.NewUserRepository(db.DB)
 	idGenerator := utils.NewIDGenerator()
 	validator := validation.NewValidator()
 
 	// Create handlers
 	pasteHandler := handlers.NewPasteHandler(pasteRepo, idGenerator, validator)
-	userHandler := handlers.NewUserHandler(userRepo, validator)
+	ipHasher := utils.NewIPHasher("test-ip-secret", 0)
+	pasteHandler.SetIPHasher(ipHasher)
+	notificationRepo := models.NewNotificationRepository(db.DB)
+	pasteHandler.SetViewRecorder(notificationRepo)
+	tokenManager := auth.NewTokenManager(cfg.JWTSecret, cfg.JWTSecret)
+	userHandler := handlers.NewUserHandler(userRepo, tokenManager, validator)
+	userHandler.SetRenameCooldown(24 * time.Hour)
 
 	// Setup router
 	router := mux.NewRouter()
@@ -100,11 +84,20 @@ func setupTestServer(t *testing.T) *TestServer {
 	// Apply global middleware
 	router.Use(middleware.LoggingMiddleware)
 	router.Use(middleware.RecoveryMiddleware)
+	readOnlyMode := middleware.NewReadOnlyMode(false, "/api/auth/login", "/api/admin/read-only")
+	router.Use(readOnlyMode.Guard)
 
 	api := router.PathPrefix("/api").Subrouter()
 
 	// Rate limiting
-	rateLimiter := middleware.NewRateLimiter(10, 100, time.Hour)
+	rateLimiter := middleware.NewDefaultRateLimiter()
+	pasteHandler.SetHintLimiter(rateLimiter)
+	developerKeyRepo := models.NewDeveloperKeyRepository(db.DB)
+	api.Use(rateLimiter.LimitDeveloperKeys(developerKeyRepo))
+	developerKeyHandler := handlers.NewDeveloperKeyHandler(developerKeyRepo, rateLimiter, validator)
+	api.HandleFunc("/developer/keys", developerKeyHandler.Create).Methods("POST")
+	api.HandleFunc("/developer/key", developerKeyHandler.Usage).Methods("GET")
+	api.HandleFunc("/developer/key", developerKeyHandler.Revoke).Methods("DELETE")
 
 	// Public routes
 	api.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
@@ -115,18 +108,111 @@ func setupTestServer(t *testing.T) *TestServer {
 	// User routes
 	api.HandleFunc("/auth/register", userHandler.Register).Methods("POST")
 	api.HandleFunc("/auth/login", userHandler.Login).Methods("POST")
+	introspectionHandler := handlers.NewIntrospectionHandler(tokenManager, userRepo, "test-introspection-secret")
+	api.HandleFunc("/auth/introspect", introspectionHandler.Introspect).Methods("POST")
+	serviceAccountHandler := handlers.NewServiceAccountHandler(models.NewServiceAccountRepository(db.DB), userRepo, tokenManager, validator)
+	api.HandleFunc("/auth/token", serviceAccountHandler.Token).Methods("POST")
+	scimRepo := models.NewSCIMRepository(db.DB)
+	scimRepo.SetRenameCooldown(24 * time.Hour)
+	scimHandler := handlers.NewSCIMHandler(scimRepo, validator, "test-scim-token")
+	router.HandleFunc("/scim/v2/Users", scimHandler.ListUsers).Methods("GET")
+	router.HandleFunc("/scim/v2/Users", scimHandler.CreateUser).Methods("POST")
+	router.HandleFunc("/scim/v2/Users/{id}", scimHandler.GetUser).Methods("GET")
+	router.HandleFunc("/scim/v2/Users/{id}", scimHandler.ReplaceUser).Methods("PUT")
+	router.HandleFunc("/scim/v2/Users/{id}", scimHandler.PatchUser).Methods("PATCH")
+	router.HandleFunc("/scim/v2/Users/{id}", scimHandler.DeleteUser).Methods("DELETE")
 
 	// Paste routes with rate limiting
-	api.Handle("/paste", rateLimiter.LimitPasteCreation(http.HandlerFunc(pasteHandler.Create))).Methods("POST")
+	authMiddleware := middleware.NewAuthMiddleware(tokenManager)
+	api.Handle("/paste", authMiddleware.OptionalAuth(authMiddleware.RequireScope(auth.ScopeUser, auth.ScopePasteCreate)(rateLimiter.LimitPasteCreation(http.HandlerFunc(pasteHandler.Create))))).Methods("POST")
 	api.Handle("/paste/{id}", rateLimiter.LimitPasteRetrieval(http.HandlerFunc(pasteHandler.GetByID))).Methods("GET")
+	api.HandleFunc("/validate/password", pasteHandler.CheckPasswordStrength).Methods("POST")
 	api.Handle("/paste/{id}/raw", rateLimiter.LimitPasteRetrieval(http.HandlerFunc(pasteHandler.GetRaw))).Methods("GET")
 	api.HandleFunc("/paste/{id}/unlock", pasteHandler.GetByIDWithPassword).Methods("POST")
+	pasteHandler.SetAttachments(models.NewAttachmentRepository(db.DB), handlers.AttachmentLimits{MaxSize: 1024, MaxCount: 2})
+	api.HandleFunc("/paste/{id}/attachments/{name}", pasteHandler.GetAttachment).Methods("GET")
+	api.HandleFunc("/paste/{id}/archive.zip", pasteHandler.GetArchive).Methods("GET")
+
+	// Routes open to service accounts with the scope
+	api.Handle("/paste/{id}", authMiddleware.RequireAuth(authMiddleware.RequireScope(auth.ScopeUser, auth.ScopePasteDelete)(http.HandlerFunc(pasteHandler.Delete)))).Methods("DELETE")
 
 	// Protected routes
-	authMiddleware := middleware.NewAuthMiddleware(cfg.JWTSecret)
 	protected := api.PathPrefix("").Subrouter()
 	protected.Use(authMiddleware.RequireAuth)
-	protected.HandleFunc("/paste/{id}", pasteHandler.Delete).Methods("DELETE")
+	protected.Use(authMiddleware.RequireScope(auth.ScopeUser))
+	pasteHandler.SetTagStore(models.NewTagRepository(db.DB))
+	pasteHandler.SetPasteSearcher(pasteRepo)
+	protected.HandleFunc("/user/pastes", pasteHandler.GetUserPastes).Methods("GET")
+	protected.HandleFunc("/user/pastes/search", pasteHandler.SearchUserPastes).Methods("GET")
+	protected.HandleFunc("/user/tags", pasteHandler.GetUserTags).Methods("GET")
+	protected.HandleFunc("/paste/{id}/attachments/{name}", pasteHandler.UploadAttachment).Methods("PUT")
+	protected.HandleFunc("/paste/{id}/attachments/{name}", pasteHandler.DeleteAttachment).Methods("DELETE")
+	protected.HandleFunc("/user/storage", handlers.NewStorageHandler(models.NewStorageRepository(db.DB)).GetUserStorage).Methods("GET")
+	notificationHandler := handlers.NewNotificationHandler(notificationRepo, models.NewIntegrationRepository(db.DB))
+	protected.HandleFunc("/user/notifications", notificationHandler.Get).Methods("GET")
+	userSettingsHandler := handlers.NewUserSettingsHandler(models.NewUserSettingsRepository(db.DB), validator)
+	protected.HandleFunc("/user/settings", userSettingsHandler.Get).Methods("GET")
+	protected.HandleFunc("/user/settings", userSettingsHandler.Update).Methods("PUT")
+	protected.HandleFunc("/user/username", userHandler.ChangeUsername).Methods("PUT")
+	protected.HandleFunc("/user/profile", userHandler.GetProfile).Methods("GET")
+	avatarRepo := models.NewAvatarRepository(db.DB)
+	userHandler.SetAvatars(avatarRepo)
+	avatarHandler := handlers.NewAvatarHandler(avatarRepo)
+	protected.HandleFunc("/user/avatar", avatarHandler.Upload).Methods("PUT")
+	protected.HandleFunc("/user/avatar", avatarHandler.Delete).Methods("DELETE")
+	api.HandleFunc("/avatars/{hash}", avatarHandler.Get).Methods("GET", "HEAD")
+	followHandler := handlers.NewFollowHandler(models.NewFollowRepository(db.DB), userRepo)
+	protected.HandleFunc("/user/following", followHandler.List).Methods("GET")
+	protected.HandleFunc("/user/following/{username}", followHandler.Follow).Methods("PUT")
+	protected.HandleFunc("/user/following/{username}", followHandler.Unfollow).Methods("DELETE")
+	protected.HandleFunc("/user/feed", followHandler.Feed).Methods("GET")
+	api.HandleFunc("/pastes/trending", handlers.NewTrendingHandler(models.NewTrendingRepository(db.DB)).List).Methods("GET")
+	languageHandler := handlers.NewLanguageHandler(pasteRepo, validator)
+	api.HandleFunc("/pastes/language/{lang}", languageHandler.ListPastes).Methods("GET")
+	api.HandleFunc("/languages", languageHandler.ListLanguages).Methods("GET")
+	api.HandleFunc("/archive/{year}/{month}", handlers.NewArchiveHandler(pasteRepo).GetMonth).Methods("GET")
+	protected.HandleFunc("/user/notifications", notificationHandler.Update).Methods("PUT")
+
+	apiKeyRepo := models.NewAPIKeyRepository(db.DB)
+	apiKeyHandler := handlers.NewAPIKeyHandler(apiKeyRepo, validator)
+	protected.HandleFunc("/user/api-keys", apiKeyHandler.Create).Methods("POST")
+	protected.HandleFunc("/user/api-keys/{id}", apiKeyHandler.Delete).Methods("DELETE")
+	quickHandler := handlers.NewQuickHandler(pasteRepo, apiKeyRepo, idGenerator, validator)
+	api.Handle("/quick", middleware.AllowAnyOrigin(http.HandlerFunc(quickHandler.Create))).Methods("POST")
+	triggerHandler := handlers.NewTriggerHandler(models.NewTriggerRepository(db.DB), apiKeyRepo)
+	api.HandleFunc("/triggers/new-paste", triggerHandler.NewPaste).Methods("GET")
+	api.HandleFunc("/triggers/paste-viewed", triggerHandler.PasteViewed).Methods("GET")
+	mcpHandler := handlers.NewMCPHandler(pasteRepo, apiKeyRepo, idGenerator, validator)
+	api.HandleFunc("/mcp", mcpHandler.Serve).Methods("POST")
+
+	adminHandler := handlers.NewAdminHandler(pasteRepo, jobRepo, ipHasher)
+	admin := protected.PathPrefix("/admin").Subrouter()
+	admin.Use(authMiddleware.RequireAdmin)
+	admin.HandleFunc("/pastes/search", adminHandler.SearchPastes).Methods("GET")
+	admin.HandleFunc("/pastes/duplicates", adminHandler.FindDuplicates).Methods("POST")
+	responseSigner, err := utils.LoadResponseSigner(filepath.Join(t.TempDir(), "signing.pem"))
+	if err != nil {
+		t.Fatalf("Failed to create signing key: %v", err)
+	}
+	router.HandleFunc(handlers.SigningKeyPath, handlers.NewSigningKeyHandler(responseSigner).GetKey).Methods("GET")
+	legalExportHandler := handlers.NewLegalExportHandler(handlers.LegalExportSources{
+		Users:       userRepo,
+		Pastes:      pasteRepo,
+		Attachments: models.NewAttachmentRepository(db.DB),
+		APIKeys:     models.NewAPIKeyRepository(db.DB),
+		SSHKeys:     models.NewSSHKeyRepository(db.DB),
+		Events:      models.NewPasteEventRepository(db.DB),
+		Audit:       models.NewAuditRepository(db.DB),
+	}, responseSigner, validator)
+	admin.HandleFunc("/users/{username}/legal-export", legalExportHandler.Export).Methods("POST")
+	integrityHandler := handlers.NewIntegrityHandler(models.NewIntegrityRepository(db.DB))
+	admin.HandleFunc("/integrity", integrityHandler.Check).Methods("GET")
+	admin.HandleFunc("/integrity/repair", integrityHandler.Repair).Methods("POST")
+	readOnlyHandler := handlers.NewReadOnlyHandler(readOnlyMode)
+	admin.HandleFunc("/read-only", readOnlyHandler.Get).Methods("GET")
+	admin.HandleFunc("/read-only", readOnlyHandler.Set).Methods("PUT")
+	admin.HandleFunc("/service-accounts", serviceAccountHandler.Create).Methods("POST")
+	admin.HandleFunc("/service-accounts/{id}", serviceAccountHandler.Disable).Methods("DELETE")
 
 	server := httptest.NewServer(router)
 
@@ -178,6 +264,31 @@ func (ts *TestServer) DELETE(path string, token string) (*http.Response, error)
 	return client.Do(req)
 }
 
+// GETWithToken issues an authenticated GET request
+func (ts *TestServer) GETWithToken(path string, token string) (*http.Response, error) {
+	req, err := http.NewRequest("GET", ts.server.URL+path, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	return http.DefaultClient.Do(req)
+}
+
+// POSTWithToken issues an authenticated JSON POST request
+func (ts *TestServer) POSTWithToken(path string, body interface{}, token string) (*http.Response, error) {
+	reqBody, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequest("POST", ts.server.URL+path, bytes.NewReader(reqBody))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+token)
+	return http.DefaultClient.Do(req)
+}
+
 // Test data structures
 type CreatePasteRequest struct {
 	Content  string `json:"content"`
@@ -357,15 +468,15 @@ func TestGetPasteScenarios(t *testing.T) {
 				Password: "secret123",
 			},
 			testGet: func(t *testing.T, pasteID string) {
-				// Test without password - should get 401
+				// Test without password - should get 423
 				resp, err := ts.GET("/api/paste/" + pasteID)
 				if err != nil {
 					t.Fatalf("GET request failed: %v", err)
 				}
 				resp.Body.Close()
 
-				if resp.StatusCode != http.StatusUnauthorized {
-					t.Errorf("Expected status 401, got %d", resp.StatusCode)
+				if resp.StatusCode != http.StatusLocked {
+					t.Errorf("Expected status 423, got %d", resp.StatusCode)
 				}
 
 				// Test with correct password
@@ -727,3 +838,2140 @@ func TestMain(m *testing.M) {
 	// Cleanup
 	os.Exit(code)
 }
+
+func TestAdminSearch(t *testing.T) {
+	ts := setupTestServer(t)
+	defer ts.Close()
+
+	credentials := map[string]string{"username": "moderator", "password": "Password123!"}
+	resp, err := ts.POST("/api/auth/register", credentials)
+	if err != nil {
+		t.Fatalf("Registration failed: %v", err)
+	}
+	var userAuth handlers.AuthResponse
+	json.NewDecoder(resp.Body).Decode(&userAuth)
+	resp.Body.Close()
+	if userAuth.TokenPair == nil {
+		t.Fatalf("Expected tokens from registration, got status %d", resp.StatusCode)
+	}
+
+	for _, content := range []string{"leaked api_key=abc123", "harmless note"} {
+		resp, _ := ts.POST("/api/paste", CreatePasteRequest{Content: content, Language: "text"})
+		resp.Body.Close()
+	}
+
+	// Regular users are rejected
+	resp, _ = ts.GETWithToken("/api/admin/pastes/search?q=api_key", userAuth.TokenPair.AccessToken)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusForbidden {
+		t.Errorf("Expected status %d for non-admin, got %d", http.StatusForbidden, resp.StatusCode)
+	}
+
+	// Promote and log in again so the token carries the admin role
+	if _, err := models.NewUserRepository(ts.db.DB).SetRoleByUsername("moderator", models.RoleAdmin); err != nil {
+		t.Fatalf("Failed to promote user: %v", err)
+	}
+	resp, _ = ts.POST("/api/auth/login", credentials)
+	var adminAuth handlers.AuthResponse
+	json.NewDecoder(resp.Body).Decode(&adminAuth)
+	resp.Body.Close()
+
+	resp, err = ts.GETWithToken("/api/admin/pastes/search?q=api_key&ip=127.0.0.1&language=text", adminAuth.TokenPair.AccessToken)
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", resp.StatusCode)
+	}
+
+	var result struct {
+		Pastes []handlers.AdminPasteItem `json:"pastes"`
+		Total  int                       `json:"total"`
+	}
+	json.NewDecoder(resp.Body).Decode(&result)
+
+	if result.Total != 1 || len(result.Pastes) != 1 || !strings.Contains(result.Pastes[0].Snippet, "api_key") {
+		t.Errorf("Unexpected search result: %+v", result)
+	}
+	if hash := result.Pastes[0].CreatorIPHash; hash == "" || hash == "127.0.0.1" {
+		t.Errorf("Expected a hashed creator IP in admin results, got %q", hash)
+	}
+}
+
+func TestStorageUsage(t *testing.T) {
+	ts := setupTestServer(t)
+	defer ts.Close()
+
+	resp, err := ts.POST("/api/auth/register", map[string]string{"username": "storer", "password": "Password123!"})
+	if err != nil {
+		t.Fatalf("Registration failed: %v", err)
+	}
+	var auth handlers.AuthResponse
+	json.NewDecoder(resp.Body).Decode(&auth)
+	resp.Body.Close()
+	if auth.TokenPair == nil {
+		t.Fatalf("Expected tokens from registration, got status %d", resp.StatusCode)
+	}
+
+	for _, content := range []string{"hello", "héllo"} {
+		resp, _ := ts.POST("/api/paste", CreatePasteRequest{Content: content})
+		resp.Body.Close()
+	}
+
+	pasteRepo := models.NewPasteRepository(ts.db.DB)
+	userID := auth.User.ID
+	for _, id := range []string{"own001", "own002"} {
+		if err := pasteRepo.Create(&models.Paste{ID: id, Content: "0123456789", UserID: &userID, Kind: models.KindText, Visibility: models.VisibilityPublic}); err != nil {
+			t.Fatalf("Failed to create paste: %v", err)
+		}
+	}
+	if err := pasteRepo.Delete("own002"); err != nil {
+		t.Fatalf("Failed to delete paste: %v", err)
+	}
+
+	resp, err = ts.GETWithToken("/api/user/storage", auth.TokenPair.AccessToken)
+	if err != nil {
+		t.Fatalf("Storage request failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	var usage models.StorageUsage
+	json.NewDecoder(resp.Body).Decode(&usage)
+	if usage.PasteCount != 1 || usage.Bytes != 10 {
+		t.Errorf("Expected 1 paste using 10 bytes, got %+v", usage)
+	}
+
+	total, err := models.NewStorageRepository(ts.db.DB).GetInstanceUsage()
+	if err != nil {
+		t.Fatalf("Failed to get instance usage: %v", err)
+	}
+	// "héllo" is 6 bytes in UTF-8
+	if total.PasteCount != 3 || total.Bytes != 21 {
+		t.Errorf("Expected 3 pastes using 21 bytes, got %+v", total)
+	}
+}
+
+func TestPasteEventRollup(t *testing.T) {
+	ts := setupTestServer(t)
+	defer ts.Close()
+
+	pasteRepo := models.NewPasteRepository(ts.db.DB)
+	expired := time.Now().Add(-time.Minute)
+	pastes := []*models.Paste{
+		{ID: "evt001", Content: "kept", Kind: models.KindText, Visibility: models.VisibilityPublic},
+		{ID: "evt002", Content: "deleted", Kind: models.KindText, Visibility: models.VisibilityPublic},
+		{ID: "evt003", Content: "expired", ExpiresAt: &expired, Kind: models.KindText, Visibility: models.VisibilityPublic},
+		{ID: "evt004", Content: "untracked", DoNotTrack: true, Kind: models.KindText, Visibility: models.VisibilityPublic},
+	}
+	for _, p := range pastes {
+		if err := pasteRepo.Create(p); err != nil {
+			t.Fatalf("Failed to create paste: %v", err)
+		}
+	}
+	if err := pasteRepo.Delete("evt002"); err != nil {
+		t.Fatalf("Failed to delete paste: %v", err)
+	}
+	if _, err := pasteRepo.DeleteExpired(); err != nil {
+		t.Fatalf("Failed to delete expired pastes: %v", err)
+	}
+
+	var untracked int
+	ts.db.DB.QueryRow(`SELECT COUNT(*) FROM paste_events WHERE paste_id = 'evt004'`).Scan(&untracked)
+	if untracked != 0 {
+		t.Errorf("Expected do_not_track paste ID to be left out of the event log")
+	}
+
+	eventRepo := models.NewPasteEventRepository(ts.db.DB)
+	// Rolling up twice must not double count
+	for i := 0; i < 2; i++ {
+		if _, err := eventRepo.Rollup(); err != nil {
+			t.Fatalf("Rollup failed: %v", err)
+		}
+	}
+
+	days, err := eventRepo.GetDailyStats(time.Now().AddDate(0, 0, -1))
+	if err != nil {
+		t.Fatalf("Failed to get daily stats: %v", err)
+	}
+	if len(days) != 1 {
+		t.Fatalf("Expected 1 day of stats, got %d", len(days))
+	}
+	day := days[0]
+	if day.Created != 4 || day.Deleted != 1 || day.Expired != 1 || day.Burned != 0 {
+		t.Errorf("Unexpected daily stats: %+v", day)
+	}
+	if day.BytesCreated != int64(len("kept")+len("deleted")+len("expired")+len("untracked")) {
+		t.Errorf("Unexpected bytes created: %d", day.BytesCreated)
+	}
+}
+
+func TestExpiredBacklogAge(t *testing.T) {
+	ts := setupTestServer(t)
+	defer ts.Close()
+
+	pasteRepo := models.NewPasteRepository(ts.db.DB)
+	if age, err := pasteRepo.ExpiredBacklogAge(); err != nil || age != 0 {
+		t.Fatalf("Expected no backlog, got %v (err %v)", age, err)
+	}
+
+	expired := time.Now().Add(-10 * time.Minute)
+	if err := pasteRepo.Create(&models.Paste{ID: "lag001", Content: "x", ExpiresAt: &expired, Kind: models.KindText, Visibility: models.VisibilityPublic}); err != nil {
+		t.Fatalf("Failed to create paste: %v", err)
+	}
+
+	age, err := pasteRepo.ExpiredBacklogAge()
+	if err != nil {
+		t.Fatalf("Failed to get backlog age: %v", err)
+	}
+	if age < 9*time.Minute || age > 11*time.Minute {
+		t.Errorf("Expected a backlog of about 10 minutes, got %v", age)
+	}
+}
+
+func TestContentHashLookup(t *testing.T) {
+	ts := setupTestServer(t)
+	defer ts.Close()
+
+	pasteRepo := models.NewPasteRepository(ts.db.DB)
+	for _, p := range []*models.Paste{
+		{ID: "hash01", Content: "same", Kind: models.KindText, Visibility: models.VisibilityPrivate},
+		{ID: "hash02", Content: "same", Kind: models.KindText, Visibility: models.VisibilityPublic},
+		{ID: "hash03", Content: "older", Kind: models.KindText, Visibility: models.VisibilityPublic},
+	} {
+		if err := pasteRepo.Create(p); err != nil {
+			t.Fatalf("Failed to create paste: %v", err)
+		}
+	}
+
+	paste, err := pasteRepo.GetByContentHash(models.ContentSHA256("same"))
+	if err != nil || paste == nil || paste.ID != "hash02" {
+		t.Fatalf("Expected the public copy of the content, got %+v (err %v)", paste, err)
+	}
+
+	// Pastes from before content addressing are hashed by the backfill
+	ts.db.DB.Exec(`UPDATE pastes SET content_sha256 = NULL WHERE id = 'hash03'`)
+	if n, err := pasteRepo.BackfillContentHashes(100); err != nil || n != 1 {
+		t.Fatalf("Expected one paste to be backfilled, got %d (err %v)", n, err)
+	}
+	if paste, _ := pasteRepo.GetByContentHash(models.ContentSHA256("older")); paste == nil || paste.ID != "hash03" {
+		t.Errorf("Expected the backfilled paste by its hash, got %+v", paste)
+	}
+}
+
+func TestDeleteExpiredPastesReturnsSurrogateKeys(t *testing.T) {
+	ts := setupTestServer(t)
+	defer ts.Close()
+
+	pasteRepo := models.NewPasteRepository(ts.db.DB)
+	expired := time.Now().Add(-time.Minute)
+	for _, p := range []*models.Paste{
+		{ID: "cdn001", Content: "kept", Kind: models.KindText, Visibility: models.VisibilityPublic},
+		{ID: "cdn002", Content: "gone", ExpiresAt: &expired, Kind: models.KindText, Visibility: models.VisibilityPublic},
+	} {
+		if err := pasteRepo.Create(p); err != nil {
+			t.Fatalf("Failed to create paste: %v", err)
+		}
+	}
+
+	deleted, err := pasteRepo.DeleteExpiredPastes()
+	if err != nil || len(deleted) != 1 || deleted[0].ID != "cdn002" {
+		t.Fatalf("Expected only the expired paste to be deleted, got %+v (err %v)", deleted, err)
+	}
+	keys := deleted[0].SurrogateKeys()
+	if len(keys) != 3 || keys[0] != "paste-cdn002" || keys[1] != "blob-"+models.ContentSHA256("gone") ||
+		keys[2] != models.ArchiveSurrogateKey(time.Now()) {
+		t.Errorf("Unexpected surrogate keys %v", keys)
+	}
+}
+
+func TestDeveloperKeyUsage(t *testing.T) {
+	ts := setupTestServer(t)
+	defer ts.Close()
+
+	resp, err := ts.POST("/api/developer/keys", map[string]string{"name": "my bot"})
+	if err != nil {
+		t.Fatalf("Failed to create developer key: %v", err)
+	}
+	var created handlers.CreateDeveloperKeyResponse
+	json.NewDecoder(resp.Body).Decode(&created)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated || !strings.HasPrefix(created.Key, "pvd_") {
+		t.Fatalf("Expected a developer key, got %d %+v", resp.StatusCode, created)
+	}
+
+	withKey := func(method, path, key string) *http.Response {
+		req, _ := http.NewRequest(method, ts.server.URL+path, nil)
+		req.Header.Set(middleware.DeveloperKeyHeader, key)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("Request failed: %v", err)
+		}
+		return resp
+	}
+
+	resp = withKey("GET", "/api/health", created.Key)
+	resp.Body.Close()
+	if resp.Header.Get("X-RateLimit-Limit") != "60" || resp.Header.Get("X-RateLimit-Remaining") != "59" {
+		t.Errorf("Expected the key's quota in the headers, got %v", resp.Header)
+	}
+
+	resp = withKey("GET", "/api/developer/key", created.Key)
+	var usage handlers.DeveloperKeyUsageResponse
+	json.NewDecoder(resp.Body).Decode(&usage)
+	resp.Body.Close()
+	if usage.Key == nil || usage.Key.Name != "my bot" || usage.Remaining != 58 {
+		t.Fatalf("Unexpected usage %+v", usage)
+	}
+	if len(usage.Days) != 1 || usage.Days[0].Requests != 2 {
+		t.Errorf("Expected two requests recorded today, got %+v", usage.Days)
+	}
+
+	if resp := withKey("GET", "/api/health", "pvd_unknown"); resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("Expected an unknown key to be refused, got %d", resp.StatusCode)
+	}
+
+	if resp := withKey("DELETE", "/api/developer/key", created.Key); resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("Expected the key to be revoked, got %d", resp.StatusCode)
+	}
+	if resp := withKey("GET", "/api/health", created.Key); resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("Expected a revoked key to be refused, got %d", resp.StatusCode)
+	}
+}
+
+func TestIntegrationSecretRotation(t *testing.T) {
+	ts := setupTestServer(t)
+	defer ts.Close()
+
+	user := &models.User{Username: "hooks", PasswordHash: "x"}
+	if err := models.NewUserRepository(ts.db.DB).Create(user); err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+	repo := models.NewIntegrationRepository(ts.db.DB)
+	integration := &models.Integration{UserID: user.ID, Name: "team", Platform: "slack", WebhookURL: "https://hooks.slack.com/services/T0/B0/x"}
+	if err := repo.Create(integration); err != nil {
+		t.Fatalf("Failed to create integration: %v", err)
+	}
+	if !strings.HasPrefix(integration.SigningSecret, "whsec_") {
+		t.Fatalf("Expected a generated signing secret, got %q", integration.SigningSecret)
+	}
+
+	secret, err := repo.RotateSecret(user.ID, integration.ID, time.Hour)
+	if err != nil || secret == "" || secret == integration.SigningSecret {
+		t.Fatalf("Expected a new secret, got %q (err %v)", secret, err)
+	}
+	rotated, _ := repo.GetByID(user.ID, integration.ID)
+	secrets := rotated.SigningSecrets(time.Now())
+	if len(secrets) != 2 || secrets[0] != secret || secrets[1] != integration.SigningSecret {
+		t.Errorf("Expected the new and previous secret, got %v", secrets)
+	}
+	if secrets := rotated.SigningSecrets(time.Now().Add(2 * time.Hour)); len(secrets) != 1 {
+		t.Errorf("Expected the previous secret to lapse after the grace period, got %v", secrets)
+	}
+
+	if secret, err := repo.RotateSecret(user.ID+1, integration.ID, time.Hour); err != nil || secret != "" {
+		t.Errorf("Expected another user's integration to be left alone, got %q (err %v)", secret, err)
+	}
+}
+
+func TestWebhookDeliveryLogRetention(t *testing.T) {
+	ts := setupTestServer(t)
+	defer ts.Close()
+
+	user := &models.User{Username: "deliveries", PasswordHash: "x"}
+	if err := models.NewUserRepository(ts.db.DB).Create(user); err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+	integration := &models.Integration{UserID: user.ID, Name: "team", Platform: "slack", WebhookURL: "https://hooks.slack.com/services/T0/B0/x"}
+	if err := models.NewIntegrationRepository(ts.db.DB).Create(integration); err != nil {
+		t.Fatalf("Failed to create integration: %v", err)
+	}
+
+	repo := models.NewWebhookDeliveryRepository(ts.db.DB)
+	status := 500
+	for i := 0; i < 105; i++ {
+		attempt := &models.WebhookDeliveryAttempt{IntegrationID: integration.ID, Event: models.WebhookEventShare, Body: []byte(fmt.Sprintf(`{"n":%d}`, i)), StatusCode: &status}
+		if err := repo.Record(attempt); err != nil {
+			t.Fatalf("Failed to record delivery: %v", err)
+		}
+	}
+
+	if count, err := repo.CountByIntegration(integration.ID); err != nil || count != 100 {
+		t.Fatalf("Expected the log to keep 100 attempts, got %d (err %v)", count, err)
+	}
+	latest, err := repo.ListByIntegration(integration.ID, 1, 0)
+	if err != nil || len(latest) != 1 || string(latest[0].Body) != `{"n":104}` || *latest[0].StatusCode != 500 {
+		t.Fatalf("Expected the newest attempt first, got %+v (err %v)", latest, err)
+	}
+	if a, _ := repo.GetByID(integration.ID+1, latest[0].ID); a != nil {
+		t.Error("Expected attempts to be scoped to their integration")
+	}
+}
+
+func TestWeeklyDigest(t *testing.T) {
+	ts := setupTestServer(t)
+	defer ts.Close()
+
+	resp, err := ts.POST("/api/auth/register", map[string]string{"username": "digest", "password": "Password123!"})
+	if err != nil {
+		t.Fatalf("Registration failed: %v", err)
+	}
+	var auth handlers.AuthResponse
+	json.NewDecoder(resp.Body).Decode(&auth)
+	resp.Body.Close()
+	if auth.TokenPair == nil {
+		t.Fatalf("Expected tokens from registration, got status %d", resp.StatusCode)
+	}
+	token := auth.TokenPair.AccessToken
+
+	users := models.NewUserRepository(ts.db.DB)
+	user, _ := users.GetByUsername("digest")
+	pasteRepo := models.NewPasteRepository(ts.db.DB)
+	soon := time.Now().Add(48 * time.Hour)
+	pastes := []*models.Paste{
+		{ID: "aB3dE5gH7jK9mN1pQ3sT", Content: "viewed", Language: "go", UserID: &user.ID, ExpiresAt: &soon},
+		{ID: "zY9xW8vU7tS6rQ5pO4nM", Content: "untracked", UserID: &user.ID, DoNotTrack: true},
+	}
+	for _, paste := range pastes {
+		if err := pasteRepo.Create(paste); err != nil {
+			t.Fatalf("Failed to create paste: %v", err)
+		}
+		for i := 0; i < 2; i++ {
+			resp, _ := ts.GET("/api/paste/" + paste.ID)
+			resp.Body.Close()
+		}
+	}
+
+	put := func(body string) *http.Response {
+		req, _ := http.NewRequest("PUT", ts.server.URL+"/api/user/notifications", strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer "+token)
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("Request failed: %v", err)
+		}
+		resp.Body.Close()
+		return resp
+	}
+	if resp := put(`{"weekly_digest":"daily"}`); resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("Expected an unknown channel to be rejected, got %d", resp.StatusCode)
+	}
+	if resp := put(`{"weekly_digest":"webhook","digest_integration_id":99}`); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("Expected an unknown integration to be rejected, got %d", resp.StatusCode)
+	}
+	if resp := put(`{"weekly_digest":"email"}`); resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected the email digest to be enabled, got %d", resp.StatusCode)
+	}
+	if err := users.SetVerifiedEmail(user.ID, "digest@example.com"); err != nil {
+		t.Fatalf("Failed to set email: %v", err)
+	}
+
+	notifications := models.NewNotificationRepository(ts.db.DB)
+	jobRepo := models.NewJobRepository(ts.db.DB)
+	queue := services.NewJobQueue(jobRepo, 1)
+	queue.Register(services.JobTypeEmail, func(ctx context.Context, payload []byte) error { return nil })
+	job := services.NewDigestJob(notifications, models.NewIntegrationRepository(ts.db.DB), queue, handlers.PasteURL)
+	if err := job.Run(context.Background()); err != nil {
+		t.Fatalf("Digest run failed: %v", err)
+	}
+
+	queued, err := jobRepo.ClaimNext()
+	if err != nil || queued == nil {
+		t.Fatalf("Expected a queued digest email (err %v)", err)
+	}
+	var msg services.EmailMessage
+	json.Unmarshal([]byte(queued.Payload), &msg)
+	if msg.To != "digest@example.com" {
+		t.Errorf("Expected the digest to go to the verified address, got %q", msg.To)
+	}
+	for _, want := range []string{"Views: 2", handlers.PasteURL("aB3dE5gH7jK9mN1pQ3sT"), "2 pastes"} {
+		if !strings.Contains(msg.Body, want) {
+			t.Errorf("Expected %q in digest:\n%s", want, msg.Body)
+		}
+	}
+
+	// The next run within the week sends nothing
+	if err := job.Run(context.Background()); err != nil {
+		t.Fatalf("Digest run failed: %v", err)
+	}
+	if next, _ := jobRepo.ClaimNext(); next != nil {
+		t.Errorf("Expected one digest a week, got another job %+v", next)
+	}
+}
+
+func TestUserSettings(t *testing.T) {
+	ts := setupTestServer(t)
+	defer ts.Close()
+
+	resp, err := ts.POST("/api/auth/register", map[string]string{"username": "settings", "password": "Password123!"})
+	if err != nil {
+		t.Fatalf("Registration failed: %v", err)
+	}
+	var auth handlers.AuthResponse
+	json.NewDecoder(resp.Body).Decode(&auth)
+	resp.Body.Close()
+	token := auth.TokenPair.AccessToken
+
+	put := func(body string) *http.Response {
+		req, _ := http.NewRequest("PUT", ts.server.URL+"/api/user/settings", strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer "+token)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("Request failed: %v", err)
+		}
+		resp.Body.Close()
+		return resp
+	}
+
+	if resp := put(`{"default_expiry":"forever","default_visibility":"secret"}`); resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("Expected invalid defaults to be rejected, got %d", resp.StatusCode)
+	}
+	if resp := put(`{"default_expiry":"7d","default_visibility":"unlisted","default_language":"go"}`); resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected the settings to be saved, got %d", resp.StatusCode)
+	}
+
+	resp, _ = ts.GETWithToken("/api/user/settings", token)
+	var settings models.UserSettings
+	json.NewDecoder(resp.Body).Decode(&settings)
+	resp.Body.Close()
+	if settings.DefaultExpiry != "7d" || settings.DefaultVisibility != "unlisted" || settings.DefaultLanguage != "go" {
+		t.Errorf("Expected the saved settings back, got %+v", settings)
+	}
+}
+
+func TestTokenIntrospection(t *testing.T) {
+	ts := setupTestServer(t)
+	defer ts.Close()
+
+	resp, err := ts.POST("/api/auth/register", map[string]string{"username": "sidecar", "password": "Password123!"})
+	if err != nil {
+		t.Fatalf("Registration failed: %v", err)
+	}
+	var auth handlers.AuthResponse
+	json.NewDecoder(resp.Body).Decode(&auth)
+	resp.Body.Close()
+
+	introspect := func(secret, token string) (*http.Response, handlers.IntrospectResponse) {
+		body, _ := json.Marshal(handlers.IntrospectRequest{Token: token})
+		req, _ := http.NewRequest("POST", ts.server.URL+"/api/auth/introspect", bytes.NewReader(body))
+		req.Header.Set("Authorization", "Bearer "+secret)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("Request failed: %v", err)
+		}
+		defer resp.Body.Close()
+		var result handlers.IntrospectResponse
+		json.NewDecoder(resp.Body).Decode(&result)
+		return resp, result
+	}
+
+	if resp, _ := introspect("wrong-secret", auth.TokenPair.AccessToken); resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("Expected a service without the secret to be refused, got %d", resp.StatusCode)
+	}
+	// A user's own access token does not authorize introspection
+	if resp, _ := introspect(auth.TokenPair.AccessToken, auth.TokenPair.AccessToken); resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("Expected an access token to be refused as credentials, got %d", resp.StatusCode)
+	}
+
+	resp, result := introspect("test-introspection-secret", auth.TokenPair.AccessToken)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d", http.StatusOK, resp.StatusCode)
+	}
+	if !result.Active || result.UserID != auth.User.ID || result.Username != "sidecar" || result.ExpiresAt != auth.TokenPair.ExpiresAt {
+		t.Errorf("Expected the token's user and expiry, got %+v", result)
+	}
+	if len(result.Scopes) != 1 || result.Scopes[0] != "user" {
+		t.Errorf("Expected the user scope, got %v", result.Scopes)
+	}
+
+	// Invalid tokens are reported inactive, not as errors
+	resp, result = introspect("test-introspection-secret", "not-a-token")
+	if resp.StatusCode != http.StatusOK || result.Active || result.UserID != 0 {
+		t.Errorf("Expected an inactive token, got %d %+v", resp.StatusCode, result)
+	}
+}
+
+func TestServiceAccounts(t *testing.T) {
+	ts := setupTestServer(t)
+	defer ts.Close()
+
+	credentials := map[string]string{"username": "operator", "password": "Password123!"}
+	resp, err := ts.POST("/api/auth/register", credentials)
+	if err != nil {
+		t.Fatalf("Registration failed: %v", err)
+	}
+	resp.Body.Close()
+	if _, err := models.NewUserRepository(ts.db.DB).SetRoleByUsername("operator", models.RoleAdmin); err != nil {
+		t.Fatalf("Failed to promote user: %v", err)
+	}
+	resp, _ = ts.POST("/api/auth/login", credentials)
+	var adminAuth handlers.AuthResponse
+	json.NewDecoder(resp.Body).Decode(&adminAuth)
+	resp.Body.Close()
+	adminToken := adminAuth.TokenPair.AccessToken
+
+	resp, _ = ts.POSTWithToken("/api/admin/service-accounts", map[string]interface{}{"name": "ci-builds", "scopes": []string{"paste:write"}}, adminToken)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("Expected an unknown scope to be rejected, got %d", resp.StatusCode)
+	}
+
+	resp, _ = ts.POSTWithToken("/api/admin/service-accounts", map[string]interface{}{"name": "ci-builds", "description": "Build logs", "scopes": []string{"paste:create"}}, adminToken)
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("Expected status %d, got %d", http.StatusCreated, resp.StatusCode)
+	}
+	var account handlers.CreateServiceAccountResponse
+	json.NewDecoder(resp.Body).Decode(&account)
+	resp.Body.Close()
+	if account.ServiceAccount == nil || account.ClientID == "" || account.ClientSecret == "" {
+		t.Fatalf("Expected client credentials, got %+v", account)
+	}
+
+	token := func(clientID, secret string) (*http.Response, handlers.TokenResponse) {
+		req, _ := http.NewRequest("POST", ts.server.URL+"/api/auth/token", strings.NewReader(`{"grant_type":"client_credentials"}`))
+		req.SetBasicAuth(clientID, secret)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("Request failed: %v", err)
+		}
+		defer resp.Body.Close()
+		var result handlers.TokenResponse
+		json.NewDecoder(resp.Body).Decode(&result)
+		return resp, result
+	}
+
+	if resp, _ := token(account.ClientID, "pvs_wrong"); resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("Expected a wrong secret to be refused, got %d", resp.StatusCode)
+	}
+	resp, grant := token(account.ClientID, account.ClientSecret)
+	if resp.StatusCode != http.StatusOK || grant.AccessToken == "" {
+		t.Fatalf("Expected an access token, got %d %+v", resp.StatusCode, grant)
+	}
+
+	// Pastes published by the account are owned by it
+	resp, _ = ts.POSTWithToken("/api/paste", CreatePasteRequest{Content: "build #42 passed"}, grant.AccessToken)
+	var created CreatePasteResponse
+	json.NewDecoder(resp.Body).Decode(&created)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("Expected the service account to create a paste, got %d", resp.StatusCode)
+	}
+	paste, _ := models.NewPasteRepository(ts.db.DB).GetByID(created.ID)
+	if paste == nil || paste.UserID == nil || *paste.UserID != account.UserID {
+		t.Errorf("Expected the paste to be owned by the service account, got %+v", paste)
+	}
+
+	// Anything beyond its scopes is refused
+	if resp, _ := ts.DELETE("/api/paste/"+created.ID, grant.AccessToken); resp.StatusCode != http.StatusForbidden {
+		t.Errorf("Expected deleting without the scope to be refused, got %d", resp.StatusCode)
+	}
+	if resp, _ := ts.GETWithToken("/api/user/settings", grant.AccessToken); resp.StatusCode != http.StatusForbidden {
+		t.Errorf("Expected account routes to be refused, got %d", resp.StatusCode)
+	}
+	if resp, _ := ts.POST("/api/auth/login", map[string]string{"username": "ci-builds", "password": "!"}); resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("Expected the service account to be unable to sign in, got %d", resp.StatusCode)
+	}
+
+	// People keep their access to the same routes
+	if resp, _ := ts.GETWithToken("/api/user/settings", adminToken); resp.StatusCode != http.StatusOK {
+		t.Errorf("Expected a person's token to be accepted, got %d", resp.StatusCode)
+	}
+
+	if resp, _ := ts.DELETE(fmt.Sprintf("/api/admin/service-accounts/%d", account.UserID), adminToken); resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("Expected the account to be disabled, got %d", resp.StatusCode)
+	}
+	if resp, _ := token(account.ClientID, account.ClientSecret); resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("Expected a disabled account to be refused tokens, got %d", resp.StatusCode)
+	}
+}
+
+func TestSSOProvisioning(t *testing.T) {
+	ts := setupTestServer(t)
+	defer ts.Close()
+	identities := models.NewIdentityRepository(ts.db.DB)
+
+	user, err := identities.Provision(models.IdentityProviderOIDC, "sub-1", "alice", models.RoleAdmin)
+	if err != nil {
+		t.Fatalf("Provision failed: %v", err)
+	}
+	if user.Role != models.RoleAdmin {
+		t.Errorf("Expected the mapped role, got %q", user.Role)
+	}
+
+	// Later sign-ins find the same user and follow group changes
+	again, err := identities.Provision(models.IdentityProviderOIDC, "sub-1", "alice-renamed", models.RoleUser)
+	if err != nil || again.ID != user.ID || again.Username != "alice" || again.Role != models.RoleUser {
+		t.Errorf("Expected the linked user with the new role, got %+v, %v", again, err)
+	}
+	// An empty role leaves it alone
+	if again, _ := identities.Provision(models.IdentityProviderOIDC, "sub-1", "alice", ""); again.Role != models.RoleUser {
+		t.Errorf("Expected the role to be kept, got %q", again.Role)
+	}
+
+	// SSO users cannot sign in with a password
+	resp, _ := ts.POST("/api/auth/login", map[string]string{"username": "alice", "password": "!"})
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("Expected password sign-in to be refused, got %d", resp.StatusCode)
+	}
+
+	// Local accounts are never taken over by an identity with their username
+	resp, _ = ts.POST("/api/auth/register", map[string]string{"username": "bob", "password": "Password123!"})
+	resp.Body.Close()
+	if _, err := identities.Provision(models.IdentityProviderLDAP, "uid=bob,dc=example,dc=org", "bob", ""); err != models.ErrIdentityUsernameTaken {
+		t.Errorf("Expected the username to be refused, got %v", err)
+	}
+}
+
+func TestSCIMProvisioning(t *testing.T) {
+	ts := setupTestServer(t)
+	defer ts.Close()
+
+	scim := func(method, path, token string, body interface{}) (*http.Response, handlers.SCIMUserResource) {
+		t.Helper()
+		var reader io.Reader
+		if body != nil {
+			encoded, _ := json.Marshal(body)
+			reader = bytes.NewReader(encoded)
+		}
+		req, _ := http.NewRequest(method, ts.server.URL+path, reader)
+		req.Header.Set("Authorization", "Bearer "+token)
+		req.Header.Set("Content-Type", "application/scim+json")
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("SCIM request failed: %v", err)
+		}
+		defer resp.Body.Close()
+		var user handlers.SCIMUserResource
+		json.NewDecoder(resp.Body).Decode(&user)
+		return resp, user
+	}
+
+	if resp, _ := scim("GET", "/scim/v2/Users", "wrong-token", nil); resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("Expected a wrong token to be refused, got %d", resp.StatusCode)
+	}
+
+	resp, created := scim("POST", "/scim/v2/Users", "test-scim-token", map[string]interface{}{
+		"schemas":    []string{"urn:ietf:params:scim:schemas:core:2.0:User"},
+		"userName":   "alice@example.com",
+		"externalId": "ext-1",
+		"name":       map[string]string{"givenName": "Alice"},
+	})
+	if resp.StatusCode != http.StatusCreated || created.UserName != "alice@example.com" || !created.Active {
+		t.Fatalf("Expected an active user, got %d %+v", resp.StatusCode, created)
+	}
+	if resp, _ := scim("POST", "/scim/v2/Users", "test-scim-token", map[string]string{"userName": "ALICE@example.com"}); resp.StatusCode != http.StatusConflict {
+		t.Errorf("Expected a duplicate user name to conflict, got %d", resp.StatusCode)
+	}
+
+	// Providers find users by filter
+	req, _ := http.NewRequest("GET", ts.server.URL+"/scim/v2/Users?filter="+url.QueryEscape(`userName eq "Alice@Example.com"`), nil)
+	req.Header.Set("Authorization", "Bearer test-scim-token")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("List failed: %v", err)
+	}
+	var list handlers.SCIMListResponse
+	json.NewDecoder(resp.Body).Decode(&list)
+	resp.Body.Close()
+	if list.TotalResults != 1 || len(list.Resources) != 1 || list.Resources[0].ID != created.ID {
+		t.Errorf("Expected the user to match the filter, got %+v", list)
+	}
+
+	// Deactivation, with the value as a string as some providers send it
+	resp, patched := scim("PATCH", "/scim/v2/Users/"+created.ID, "test-scim-token", map[string]interface{}{
+		"schemas":    []string{"urn:ietf:params:scim:api:messages:2.0:PatchOp"},
+		"Operations": []map[string]interface{}{{"op": "Replace", "path": "active", "value": "False"}},
+	})
+	if resp.StatusCode != http.StatusOK || patched.Active {
+		t.Fatalf("Expected the user to be deactivated, got %d %+v", resp.StatusCode, patched)
+	}
+
+	// A matching username does not claim the provisioned user
+	identities := models.NewIdentityRepository(ts.db.DB)
+	if _, err := identities.Provision(models.IdentityProviderOIDC, "sub-other", "alice", ""); err != models.ErrIdentityUsernameTaken {
+		t.Errorf("Expected a sign-in with another subject not to claim the user, got %v", err)
+	}
+
+	// Single sign-on with the externalId as its subject claims the
+	// provisioned user, which is refused while deactivated
+	user, err := identities.Provision(models.IdentityProviderOIDC, "ext-1", "alice", "")
+	if err != nil || strconv.Itoa(user.ID) != created.ID || user.IsActive() {
+		t.Fatalf("Expected the deactivated provisioned user, got %+v, %v", user, err)
+	}
+
+	// Renaming and reactivating by replacement
+	resp, replaced := scim("PUT", "/scim/v2/Users/"+created.ID, "test-scim-token", map[string]string{"userName": "alice.smith@example.com", "externalId": "ext-1"})
+	if resp.StatusCode != http.StatusOK || replaced.UserName != "alice.smith@example.com" || !replaced.Active {
+		t.Fatalf("Expected the user to be renamed and active, got %d %+v", resp.StatusCode, replaced)
+	}
+	if user, _ := identities.Provision(models.IdentityProviderOIDC, "ext-1", "ignored", ""); user.Username != "alice-smith" || !user.IsActive() {
+		t.Errorf("Expected the renamed active user, got %+v", user)
+	}
+	if resp, _ := scim("POST", "/scim/v2/Users", "test-scim-token", map[string]string{"userName": "alice@example.org"}); resp.StatusCode != http.StatusConflict {
+		t.Errorf("Expected the old username to be held after a rename, got %d", resp.StatusCode)
+	}
+
+	// Deleting deprovisions the user
+	if resp, _ := scim("DELETE", "/scim/v2/Users/"+created.ID, "test-scim-token", nil); resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("Expected the user to be deleted, got %d", resp.StatusCode)
+	}
+	if resp, _ := scim("GET", "/scim/v2/Users/"+created.ID, "test-scim-token", nil); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("Expected a deleted user to be gone, got %d", resp.StatusCode)
+	}
+	if user, _ := identities.Provision(models.IdentityProviderOIDC, "ext-1", "alice-smith", ""); user.IsActive() {
+		t.Error("Expected a deprovisioned user to stay deactivated")
+	}
+}
+
+func TestUsernamePolicy(t *testing.T) {
+	ts := setupTestServer(t)
+	defer ts.Close()
+
+	register := func(username string) (int, *handlers.AuthResponse) {
+		resp, err := ts.POST("/api/auth/register", map[string]string{"username": username, "password": "Password123!"})
+		if err != nil {
+			t.Fatalf("Registration failed: %v", err)
+		}
+		defer resp.Body.Close()
+		var auth handlers.AuthResponse
+		json.NewDecoder(resp.Body).Decode(&auth)
+		return resp.StatusCode, &auth
+	}
+
+	if code, _ := register("Admin"); code != http.StatusBadRequest {
+		t.Errorf("Expected a reserved username to be refused, got %d", code)
+	}
+	code, alice := register("Alice")
+	if code != http.StatusCreated {
+		t.Fatalf("Expected registration to succeed, got %d", code)
+	}
+	if code, _ := register("alice"); code != http.StatusConflict {
+		t.Errorf("Expected usernames to be unique regardless of case, got %d", code)
+	}
+	resp, _ := ts.POST("/api/auth/login", map[string]string{"username": "ALICE", "password": "Password123!"})
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("Expected login to ignore the username's case, got %d", resp.StatusCode)
+	}
+
+	rename := func(token, username, password string) (int, *handlers.AuthResponse) {
+		body := fmt.Sprintf(`{"username": %q, "password": %q}`, username, password)
+		req, _ := http.NewRequest("PUT", ts.server.URL+"/api/user/username", strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer "+token)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("Rename failed: %v", err)
+		}
+		defer resp.Body.Close()
+		var auth handlers.AuthResponse
+		json.NewDecoder(resp.Body).Decode(&auth)
+		return resp.StatusCode, &auth
+	}
+
+	token := alice.TokenPair.AccessToken
+	if code, _ := rename(token, "support", "Password123!"); code != http.StatusBadRequest {
+		t.Errorf("Expected renaming to a reserved username to be refused, got %d", code)
+	}
+	if code, _ := rename(token, "alice-renamed", "wrong-password"); code != http.StatusUnauthorized {
+		t.Errorf("Expected a wrong password to be refused, got %d", code)
+	}
+	code, renamed := rename(token, "alice-renamed", "Password123!")
+	if code != http.StatusOK || renamed.User.Username != "alice-renamed" || renamed.TokenPair == nil {
+		t.Fatalf("Expected the rename to succeed with new tokens, got %d %+v", code, renamed)
+	}
+
+	_, bob := register("bob")
+	if code, _ := rename(bob.TokenPair.AccessToken, "ALICE-RENAMED", "Password123!"); code != http.StatusConflict {
+		t.Errorf("Expected a taken username to conflict, got %d", code)
+	}
+	if code, _ := rename(bob.TokenPair.AccessToken, "Bob", "Password123!"); code != http.StatusOK {
+		t.Errorf("Expected changing the case of one's own name to succeed, got %d", code)
+	}
+
+	// alice's old name is held for her after the rename
+	if code, _ := register("alice"); code != http.StatusConflict {
+		t.Errorf("Expected a recently released username to be held, got %d", code)
+	}
+	if code, _ := rename(bob.TokenPair.AccessToken, "Alice", "Password123!"); code != http.StatusConflict {
+		t.Errorf("Expected renaming to a held username to conflict, got %d", code)
+	}
+	if code, _ := rename(renamed.TokenPair.AccessToken, "alice", "Password123!"); code != http.StatusOK {
+		t.Errorf("Expected the previous owner to take back a held username, got %d", code)
+	}
+}
+
+func TestAvatarUpload(t *testing.T) {
+	ts := setupTestServer(t)
+	defer ts.Close()
+
+	resp, err := ts.POST("/api/auth/register", map[string]string{"username": "painter", "password": "Password123!"})
+	if err != nil {
+		t.Fatalf("Registration failed: %v", err)
+	}
+	var auth handlers.AuthResponse
+	json.NewDecoder(resp.Body).Decode(&auth)
+	resp.Body.Close()
+	token := auth.TokenPair.AccessToken
+
+	upload := func(body []byte) (*http.Response, handlers.AvatarResponse) {
+		req, _ := http.NewRequest("PUT", ts.server.URL+"/api/user/avatar", bytes.NewReader(body))
+		req.Header.Set("Authorization", "Bearer "+token)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("Upload failed: %v", err)
+		}
+		defer resp.Body.Close()
+		var avatar handlers.AvatarResponse
+		json.NewDecoder(resp.Body).Decode(&avatar)
+		return resp, avatar
+	}
+
+	if resp, _ := upload([]byte("not an image")); resp.StatusCode != http.StatusUnsupportedMediaType {
+		t.Errorf("Expected a non-image upload to be refused, got %d", resp.StatusCode)
+	}
+
+	var img bytes.Buffer
+	png.Encode(&img, image.NewRGBA(image.Rect(0, 0, 640, 480)))
+	resp, avatar := upload(img.Bytes())
+	if resp.StatusCode != http.StatusOK || !strings.HasPrefix(avatar.AvatarURL, "/api/avatars/") {
+		t.Fatalf("Expected the upload to succeed, got %d %+v", resp.StatusCode, avatar)
+	}
+
+	resp, _ = ts.GETWithToken("/api/user/profile", token)
+	var profile handlers.UserResponse
+	json.NewDecoder(resp.Body).Decode(&profile)
+	resp.Body.Close()
+	if profile.AvatarURL != avatar.AvatarURL {
+		t.Errorf("Expected the profile to show the avatar, got %q", profile.AvatarURL)
+	}
+
+	resp, _ = ts.GET(avatar.AvatarURL)
+	served, err := png.Decode(resp.Body)
+	resp.Body.Close()
+	if err != nil || served.Bounds().Dx() != render.AvatarSize || served.Bounds().Dy() != render.AvatarSize {
+		t.Errorf("Expected a resized square PNG, got %v", err)
+	}
+
+	resp, _ = ts.DELETE("/api/user/avatar", token)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent {
+		t.Errorf("Expected the avatar to be removed, got %d", resp.StatusCode)
+	}
+	resp, _ = ts.GET(avatar.AvatarURL)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("Expected the removed avatar to be gone, got %d", resp.StatusCode)
+	}
+}
+
+func TestFollowFeed(t *testing.T) {
+	ts := setupTestServer(t)
+	defer ts.Close()
+
+	tokens := map[string]string{}
+	for _, username := range []string{"reader", "writer", "other"} {
+		resp, err := ts.POST("/api/auth/register", map[string]string{"username": username, "password": "Password123!"})
+		if err != nil {
+			t.Fatalf("Registration failed: %v", err)
+		}
+		var auth handlers.AuthResponse
+		json.NewDecoder(resp.Body).Decode(&auth)
+		resp.Body.Close()
+		tokens[username] = auth.TokenPair.AccessToken
+	}
+
+	send := func(method, path string) int {
+		req, _ := http.NewRequest(method, ts.server.URL+path, nil)
+		req.Header.Set("Authorization", "Bearer "+tokens["reader"])
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("Request failed: %v", err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	if code := send("PUT", "/api/user/following/nobody"); code != http.StatusNotFound {
+		t.Errorf("Expected following an unknown user to fail, got %d", code)
+	}
+	if code := send("PUT", "/api/user/following/reader"); code != http.StatusBadRequest {
+		t.Errorf("Expected following yourself to fail, got %d", code)
+	}
+	if code := send("PUT", "/api/user/following/Writer"); code != http.StatusNoContent {
+		t.Fatalf("Expected following to succeed, got %d", code)
+	}
+
+	for _, paste := range []struct{ owner, content, password string }{
+		{"writer", "first", ""},
+		{"writer", "locked", "secret123"},
+		{"other", "unfollowed", ""},
+		{"writer", "second", ""},
+	} {
+		resp, _ := ts.POSTWithToken("/api/paste", CreatePasteRequest{Content: paste.content, Password: paste.password}, tokens[paste.owner])
+		resp.Body.Close()
+	}
+
+	resp, _ := ts.GETWithToken("/api/user/feed?limit=1", tokens["reader"])
+	var feed handlers.FeedResponse
+	json.NewDecoder(resp.Body).Decode(&feed)
+	resp.Body.Close()
+	if feed.Total != 2 || len(feed.Pastes) != 1 || feed.Pastes[0].Username != "writer" {
+		t.Fatalf("Expected the feed to hold writer's two open pastes, got %+v", feed)
+	}
+
+	resp, _ = ts.GETWithToken("/api/user/following", tokens["reader"])
+	var following handlers.FollowingResponse
+	json.NewDecoder(resp.Body).Decode(&following)
+	resp.Body.Close()
+	if len(following.Following) != 1 || following.Following[0] != "writer" {
+		t.Errorf("Expected to follow writer, got %v", following.Following)
+	}
+
+	if code := send("DELETE", "/api/user/following/writer"); code != http.StatusNoContent {
+		t.Errorf("Expected unfollowing to succeed, got %d", code)
+	}
+	if code := send("DELETE", "/api/user/following/writer"); code != http.StatusNotFound {
+		t.Errorf("Expected unfollowing twice to fail, got %d", code)
+	}
+	resp, _ = ts.GETWithToken("/api/user/feed", tokens["reader"])
+	json.NewDecoder(resp.Body).Decode(&feed)
+	resp.Body.Close()
+	if feed.Total != 0 || len(feed.Pastes) != 0 {
+		t.Errorf("Expected an empty feed after unfollowing, got %+v", feed)
+	}
+}
+
+func TestTrendingPastes(t *testing.T) {
+	ts := setupTestServer(t)
+	defer ts.Close()
+
+	create := func(req CreatePasteRequest) string {
+		resp, err := ts.POST("/api/paste", req)
+		if err != nil {
+			t.Fatalf("Failed to create paste: %v", err)
+		}
+		defer resp.Body.Close()
+		var created CreatePasteResponse
+		json.NewDecoder(resp.Body).Decode(&created)
+		return created.ID
+	}
+	popular := create(CreatePasteRequest{Content: "popular"})
+	quiet := create(CreatePasteRequest{Content: "quiet"})
+	locked := create(CreatePasteRequest{Content: "locked", Password: "secret123"})
+
+	notifications := models.NewNotificationRepository(ts.db.DB)
+	for id, views := range map[string]int{popular: 3, quiet: 1, locked: 5} {
+		for i := 0; i < views; i++ {
+			notifications.RecordView(id, false)
+		}
+	}
+	trending := models.NewTrendingRepository(ts.db.DB)
+	if err := services.NewTrendingJob(trending).Run(context.Background()); err != nil {
+		t.Fatalf("Trending job failed: %v", err)
+	}
+
+	resp, _ := ts.GET("/api/pastes/trending")
+	var body handlers.TrendingResponse
+	json.NewDecoder(resp.Body).Decode(&body)
+	resp.Body.Close()
+	if len(body.Pastes) != 2 || body.Pastes[0].ID != popular || body.Pastes[0].Views != 3 || body.Pastes[1].ID != quiet {
+		t.Errorf("Expected the open pastes ranked by views, got %+v", body.Pastes)
+	}
+}
+
+func TestLanguageDirectory(t *testing.T) {
+	ts := setupTestServer(t)
+	defer ts.Close()
+
+	for _, req := range []CreatePasteRequest{
+		{Content: "a", Language: "go"},
+		{Content: "b", Language: "Go"},
+		{Content: "c", Language: "go", Password: "secret123"},
+		{Content: "d", Language: "python"},
+		{Content: "e"},
+	} {
+		resp, err := ts.POST("/api/paste", req)
+		if err != nil {
+			t.Fatalf("Failed to create paste: %v", err)
+		}
+		resp.Body.Close()
+	}
+
+	resp, _ := ts.GET("/api/languages")
+	var languages handlers.LanguagesResponse
+	json.NewDecoder(resp.Body).Decode(&languages)
+	resp.Body.Close()
+	if len(languages.Languages) != 2 || languages.Languages[0] != (models.LanguageCount{Language: "go", Count: 2}) {
+		t.Errorf("Expected go and python counted, got %+v", languages.Languages)
+	}
+
+	resp, _ = ts.GET("/api/pastes/language/GO?limit=1")
+	var page handlers.LanguagePastesResponse
+	json.NewDecoder(resp.Body).Decode(&page)
+	resp.Body.Close()
+	if page.Total != 2 || len(page.Pastes) != 1 || page.Pastes[0].Language != "Go" {
+		t.Errorf("Expected the newest of two go pastes, got %+v", page)
+	}
+}
+
+func TestNoIndexPaste(t *testing.T) {
+	ts := setupTestServer(t)
+	defer ts.Close()
+
+	resp, err := ts.POST("/api/paste", handlers.CreatePasteRequest{Content: "hidden", Language: "go", NoIndex: true})
+	if err != nil {
+		t.Fatalf("Failed to create paste: %v", err)
+	}
+	var paste CreatePasteResponse
+	json.NewDecoder(resp.Body).Decode(&paste)
+	resp.Body.Close()
+
+	for _, path := range []string{"/api/paste/" + paste.ID, "/api/paste/" + paste.ID + "/raw"} {
+		resp, _ = ts.GET(path)
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK || resp.Header.Get("X-Robots-Tag") != "noindex, nofollow" {
+			t.Errorf("Expected %s to be served with X-Robots-Tag, got %d %q", path, resp.StatusCode, resp.Header.Get("X-Robots-Tag"))
+		}
+	}
+
+	resp, _ = ts.GET("/api/pastes/language/go")
+	var page handlers.LanguagePastesResponse
+	json.NewDecoder(resp.Body).Decode(&page)
+	resp.Body.Close()
+	if page.Total != 0 {
+		t.Errorf("Expected noindex paste to be left out of listings, got %+v", page)
+	}
+}
+
+func TestPasswordStrength(t *testing.T) {
+	ts := setupTestServer(t)
+	defer ts.Close()
+
+	// Weak passwords are accepted but flagged in the create response
+	resp, err := ts.POST("/api/paste", CreatePasteRequest{Content: "secret", Password: "password"})
+	if err != nil {
+		t.Fatalf("Failed to create paste: %v", err)
+	}
+	var created handlers.CreatePasteResponse
+	json.NewDecoder(resp.Body).Decode(&created)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated || created.PasswordStrength == nil || created.PasswordStrength.Score != 0 {
+		t.Errorf("Expected paste created with a weak password score, got %d %+v", resp.StatusCode, created.PasswordStrength)
+	}
+
+	resp, _ = ts.POST("/api/validate/password", map[string]string{"password": "k8#Lp2!qZr"})
+	var strength validation.PasswordStrength
+	json.NewDecoder(resp.Body).Decode(&strength)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || strength.Score != 4 || len(strength.Warnings) != 0 {
+		t.Errorf("Expected a strong password without warnings, got %d %+v", resp.StatusCode, strength)
+	}
+
+	resp, _ = ts.POST("/api/validate/password", map[string]string{})
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("Expected 400 for a missing password, got %d", resp.StatusCode)
+	}
+}
+
+func TestPasswordHint(t *testing.T) {
+	ts := setupTestServer(t)
+	defer ts.Close()
+
+	resp, _ := ts.POST("/api/paste", handlers.CreatePasteRequest{Content: "secret", Password: "hunter22", PasswordHint: "My HUNTER22"})
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("Expected a hint containing the password to be rejected, got %d", resp.StatusCode)
+	}
+
+	resp, _ = ts.POST("/api/paste", handlers.CreatePasteRequest{Content: "secret", Password: "hunter22", PasswordHint: "the usual"})
+	var created CreatePasteResponse
+	json.NewDecoder(resp.Body).Decode(&created)
+	resp.Body.Close()
+
+	resp, _ = ts.GET("/api/paste/" + created.ID)
+	var body struct {
+		Error string `json:"error"`
+		Hint  string `json:"hint"`
+	}
+	json.NewDecoder(resp.Body).Decode(&body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusLocked || body.Error != "password_required" || body.Hint != "the usual" {
+		t.Errorf("Expected password_required with the hint, got %d %+v", resp.StatusCode, body)
+	}
+}
+
+func TestUnlockAttemptLimit(t *testing.T) {
+	ts := setupTestServer(t)
+	defer ts.Close()
+
+	resp, _ := ts.POST("/api/paste", handlers.CreatePasteRequest{Content: "secret", MaxUnlockAttempts: 3})
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("Expected an attempt limit without a password to be rejected, got %d", resp.StatusCode)
+	}
+
+	resp, _ = ts.POST("/api/paste", handlers.CreatePasteRequest{Content: "secret", Password: "hunter22", MaxUnlockAttempts: 2})
+	var created CreatePasteResponse
+	json.NewDecoder(resp.Body).Decode(&created)
+	resp.Body.Close()
+
+	wrong := map[string]string{"password": "wrong"}
+	for i, expected := range []int{http.StatusForbidden, http.StatusGone} {
+		resp, _ = ts.POST("/api/paste/"+created.ID+"/unlock", wrong)
+		resp.Body.Close()
+		if resp.StatusCode != expected {
+			t.Errorf("Attempt %d: expected %d, got %d", i+1, expected, resp.StatusCode)
+		}
+	}
+
+	resp, _ = ts.POST("/api/paste/"+created.ID+"/unlock", map[string]string{"password": "hunter22"})
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("Expected the destroyed paste to be gone, got %d", resp.StatusCode)
+	}
+}
+
+func TestAccessWindow(t *testing.T) {
+	ts := setupTestServer(t)
+	defer ts.Close()
+
+	// Windows starting an hour from now exclude now; the complementary
+	// window includes it
+	now := time.Now().UTC()
+	later, muchLater := now.Add(time.Hour).Format("15:04"), now.Add(2*time.Hour).Format("15:04")
+	for _, tc := range []struct {
+		window   string
+		expected int
+	}{
+		{later + "-" + muchLater, http.StatusForbidden},
+		{muchLater + "-" + later, http.StatusOK},
+	} {
+		resp, _ := ts.POST("/api/paste", handlers.CreatePasteRequest{Content: "exam", AccessWindow: tc.window})
+		var created CreatePasteResponse
+		json.NewDecoder(resp.Body).Decode(&created)
+		resp.Body.Close()
+
+		resp, _ = ts.GET("/api/paste/" + created.ID)
+		var body struct {
+			Error        string `json:"error"`
+			AccessWindow string `json:"access_window"`
+			Restricted   bool   `json:"restricted"`
+		}
+		json.NewDecoder(resp.Body).Decode(&body)
+		resp.Body.Close()
+		if resp.StatusCode != tc.expected {
+			t.Errorf("Window %s: expected %d, got %d %+v", tc.window, tc.expected, resp.StatusCode, body)
+		}
+		// Only the owner is shown the window itself
+		if tc.expected == http.StatusOK && (!body.Restricted || body.AccessWindow != "") {
+			t.Errorf("Expected only the restricted flag in the metadata, got %+v", body)
+		}
+	}
+
+	resp, _ := ts.POST("/api/paste", handlers.CreatePasteRequest{Content: "exam", AccessWindow: "09:00-09:00"})
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("Expected an empty window to be rejected, got %d", resp.StatusCode)
+	}
+}
+
+func TestLocationRestrictedPaste(t *testing.T) {
+	ts := setupTestServer(t)
+	defer ts.Close()
+
+	resp, _ := ts.POST("/api/paste", handlers.CreatePasteRequest{Content: "internal", AllowedCountries: []string{"de"}})
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("Expected country restrictions to need GeoIP, got %d", resp.StatusCode)
+	}
+
+	for _, tc := range []struct {
+		network  string
+		expected int
+	}{
+		{"10.0.0.0/8", http.StatusForbidden},
+		{"127.0.0.1", http.StatusOK},
+	} {
+		resp, _ := ts.POST("/api/paste", handlers.CreatePasteRequest{Content: "internal", AllowedNetworks: []string{tc.network}})
+		var created CreatePasteResponse
+		json.NewDecoder(resp.Body).Decode(&created)
+		resp.Body.Close()
+
+		resp, _ = ts.GET("/api/paste/" + created.ID)
+		resp.Body.Close()
+		if resp.StatusCode != tc.expected {
+			t.Errorf("Network %s: expected %d, got %d", tc.network, tc.expected, resp.StatusCode)
+		}
+	}
+}
+
+func TestMonthlyArchive(t *testing.T) {
+	ts := setupTestServer(t)
+	defer ts.Close()
+
+	created := map[string]bool{}
+	for _, req := range []CreatePasteRequest{{Content: "one"}, {Content: "two"}, {Content: "three"}, {Content: "hidden", Password: "secret123"}} {
+		resp, err := ts.POST("/api/paste", req)
+		if err != nil {
+			t.Fatalf("Failed to create paste: %v", err)
+		}
+		var paste CreatePasteResponse
+		json.NewDecoder(resp.Body).Decode(&paste)
+		resp.Body.Close()
+		if req.Password == "" {
+			created[paste.ID] = true
+		}
+	}
+
+	now := time.Now().UTC()
+	month := now.Format("2006/01")
+	seen := map[string]bool{}
+	after := ""
+	for page := 0; page < 5; page++ {
+		resp, _ := ts.GET("/api/archive/" + month + "?limit=2&after=" + after)
+		var archive handlers.ArchiveResponse
+		json.NewDecoder(resp.Body).Decode(&archive)
+		resp.Body.Close()
+		if resp.Header.Get("Cache-Control") == middleware.CachePublicDay {
+			t.Error("Expected the current month not to be cached for a day")
+		}
+		for _, paste := range archive.Pastes {
+			seen[paste.ID] = true
+		}
+		if after = archive.Next; after == "" {
+			break
+		}
+	}
+	if len(seen) != len(created) {
+		t.Errorf("Expected to walk the %d open pastes, saw %v", len(created), seen)
+	}
+	for id := range created {
+		if !seen[id] {
+			t.Errorf("Expected paste %s in the archive", id)
+		}
+	}
+
+	resp, _ := ts.GET("/api/archive/" + now.AddDate(0, -1, 0).Format("2006/01"))
+	var archive handlers.ArchiveResponse
+	json.NewDecoder(resp.Body).Decode(&archive)
+	resp.Body.Close()
+	if len(archive.Pastes) != 0 || resp.Header.Get("Cache-Control") != middleware.CachePublicDay {
+		t.Errorf("Expected last month to be empty and cached for a day, got %+v %q", archive, resp.Header.Get("Cache-Control"))
+	}
+
+	for _, path := range []string{now.AddDate(0, 1, 0).Format("2006/01"), "2025/13", "25/01"} {
+		resp, _ := ts.GET("/api/archive/" + path)
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusNotFound {
+			t.Errorf("Expected no archive for %s, got %d", path, resp.StatusCode)
+		}
+	}
+}
+
+func TestQuickPasteWithAPIKey(t *testing.T) {
+	ts := setupTestServer(t)
+	defer ts.Close()
+
+	resp, err := ts.POST("/api/auth/register", map[string]string{"username": "extension", "password": "Password123!"})
+	if err != nil {
+		t.Fatalf("Registration failed: %v", err)
+	}
+	var auth handlers.AuthResponse
+	json.NewDecoder(resp.Body).Decode(&auth)
+	resp.Body.Close()
+	if auth.TokenPair == nil {
+		t.Fatalf("Expected tokens from registration, got status %d", resp.StatusCode)
+	}
+
+	body, _ := json.Marshal(map[string]string{"name": "Firefox"})
+	req, _ := http.NewRequest("POST", ts.server.URL+"/api/user/api-keys", bytes.NewReader(body))
+	req.Header.Set("Authorization", "Bearer "+auth.TokenPair.AccessToken)
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("API key request failed: %v", err)
+	}
+	var created handlers.CreateAPIKeyResponse
+	json.NewDecoder(resp.Body).Decode(&created)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated || !strings.HasPrefix(created.Key, "pv_") {
+		t.Fatalf("Expected a new API key, got status %d and %+v", resp.StatusCode, created)
+	}
+
+	// A form post is a CORS simple request, so no preflight is needed
+	quick := func(key string) *http.Response {
+		resp, err := http.PostForm(ts.server.URL+"/api/quick", url.Values{"key": {key}, "text": {"from the extension"}})
+		if err != nil {
+			t.Fatalf("Quick paste request failed: %v", err)
+		}
+		return resp
+	}
+
+	resp = quick(created.Key)
+	var paste CreatePasteResponse
+	json.NewDecoder(resp.Body).Decode(&paste)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated || paste.URL == "" {
+		t.Fatalf("Expected a quick paste URL, got status %d and %+v", resp.StatusCode, paste)
+	}
+	if got := resp.Header.Get("Access-Control-Allow-Origin"); got != "*" {
+		t.Errorf("Expected any origin to read the response, got %q", got)
+	}
+
+	stored, _ := models.NewPasteRepository(ts.db.DB).GetByID(paste.ID)
+	if stored == nil || stored.UserID == nil || *stored.UserID != auth.User.ID || stored.Visibility != models.VisibilityUnlisted {
+		t.Errorf("Expected an unlisted paste owned by the key's user, got %+v", stored)
+	}
+
+	resp, _ = ts.DELETE(fmt.Sprintf("/api/user/api-keys/%d", created.ID), auth.TokenPair.AccessToken)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("Expected revocation to succeed, got status %d", resp.StatusCode)
+	}
+
+	resp = quick(created.Key)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("Expected revoked key to be rejected, got status %d", resp.StatusCode)
+	}
+}
+
+func TestPasteAttachments(t *testing.T) {
+	ts := setupTestServer(t)
+	defer ts.Close()
+
+	resp, _ := ts.POST("/api/auth/register", map[string]string{"username": "attacher", "password": "Password123!"})
+	var auth handlers.AuthResponse
+	json.NewDecoder(resp.Body).Decode(&auth)
+	resp.Body.Close()
+	token := auth.TokenPair.AccessToken
+
+	resp, _ = ts.POSTWithToken("/api/paste", CreatePasteRequest{Content: "see attached"}, token)
+	var created CreatePasteResponse
+	json.NewDecoder(resp.Body).Decode(&created)
+	resp.Body.Close()
+
+	upload := func(name string, body []byte, token string) int {
+		req, _ := http.NewRequest("PUT", ts.server.URL+"/api/paste/"+created.ID+"/attachments/"+name, bytes.NewReader(body))
+		req.Header.Set("Authorization", "Bearer "+token)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("Upload failed: %v", err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	var img bytes.Buffer
+	png.Encode(&img, image.NewRGBA(image.Rect(0, 0, 4, 4)))
+	for _, tc := range []struct {
+		name     string
+		body     []byte
+		expected int
+	}{
+		{"shot.png", img.Bytes(), http.StatusCreated},
+		{"notes.txt", []byte("hello"), http.StatusCreated},
+		{"notes.txt", []byte("hello again"), http.StatusOK},
+		{"page.html", []byte("<html><script>alert(1)</script>"), http.StatusUnsupportedMediaType},
+		{"big.txt", bytes.Repeat([]byte("a"), 2048), http.StatusRequestEntityTooLarge},
+		{".hidden", []byte("hello"), http.StatusBadRequest},
+		{"third.txt", []byte("hello"), http.StatusConflict},
+	} {
+		if status := upload(tc.name, tc.body, token); status != tc.expected {
+			t.Errorf("Upload %s: expected %d, got %d", tc.name, tc.expected, status)
+		}
+	}
+
+	resp, _ = ts.POST("/api/auth/register", map[string]string{"username": "intruder", "password": "Password123!"})
+	var other handlers.AuthResponse
+	json.NewDecoder(resp.Body).Decode(&other)
+	resp.Body.Close()
+	if status := upload("evil.txt", []byte("hello"), other.TokenPair.AccessToken); status != http.StatusForbidden {
+		t.Errorf("Expected another user's upload to be refused, got %d", status)
+	}
+
+	resp, _ = ts.GET("/api/paste/" + created.ID)
+	var paste handlers.PasteResponse
+	json.NewDecoder(resp.Body).Decode(&paste)
+	resp.Body.Close()
+	if len(paste.Attachments) != 2 || paste.Attachments[0].Name != "notes.txt" || paste.Attachments[0].Size != int64(len("hello again")) {
+		t.Fatalf("Expected the paste to list both attachments, got %+v", paste.Attachments)
+	}
+
+	resp, _ = ts.GET(paste.Attachments[1].URL)
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.Header.Get("Content-Type") != "image/png" || !strings.HasPrefix(resp.Header.Get("Content-Disposition"), "attachment") || !bytes.Equal(body, img.Bytes()) {
+		t.Errorf("Expected the PNG as a download, got %q %q", resp.Header.Get("Content-Type"), resp.Header.Get("Content-Disposition"))
+	}
+
+	resp, _ = ts.DELETE(paste.Attachments[0].URL, token)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent {
+		t.Errorf("Expected the attachment to be deleted, got %d", resp.StatusCode)
+	}
+	resp, _ = ts.GET(paste.Attachments[0].URL)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("Expected the deleted attachment to be gone, got %d", resp.StatusCode)
+	}
+
+	resp, _ = ts.DELETE("/api/paste/"+created.ID, token)
+	resp.Body.Close()
+	var blobs int
+	ts.db.DB.QueryRow(`SELECT COUNT(*) FROM attachment_blobs`).Scan(&blobs)
+	if blobs != 0 {
+		t.Errorf("Expected deleting the paste to remove its attachments, %d left", blobs)
+	}
+}
+
+func TestPasteArchive(t *testing.T) {
+	ts := setupTestServer(t)
+	defer ts.Close()
+
+	resp, _ := ts.POST("/api/auth/register", map[string]string{"username": "archiver", "password": "Password123!"})
+	var auth handlers.AuthResponse
+	json.NewDecoder(resp.Body).Decode(&auth)
+	resp.Body.Close()
+	token := auth.TokenPair.AccessToken
+
+	resp, _ = ts.POSTWithToken("/api/paste", CreatePasteRequest{Content: "print('hi')", Language: "python"}, token)
+	var created CreatePasteResponse
+	json.NewDecoder(resp.Body).Decode(&created)
+	resp.Body.Close()
+
+	req, _ := http.NewRequest("PUT", ts.server.URL+"/api/paste/"+created.ID+"/attachments/notes.txt", strings.NewReader("some notes"))
+	req.Header.Set("Authorization", "Bearer "+token)
+	resp, _ = http.DefaultClient.Do(req)
+	resp.Body.Close()
+
+	resp, _ = ts.GET("/api/paste/" + created.ID + "/archive.zip")
+	data, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != "application/zip" {
+		t.Fatalf("Expected a zip archive, got %d %q", resp.StatusCode, resp.Header.Get("Content-Type"))
+	}
+
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatalf("Invalid archive: %v", err)
+	}
+	files := map[string]string{}
+	for _, f := range zr.File {
+		rc, _ := f.Open()
+		content, _ := io.ReadAll(rc)
+		rc.Close()
+		files[f.Name] = string(content)
+		if time.Since(f.Modified) > time.Hour {
+			t.Errorf("Expected %s to carry its creation time, got %v", f.Name, f.Modified)
+		}
+	}
+	if len(files) != 2 || files[created.ID+".py"] != "print('hi')" || files["attachments/notes.txt"] != "some notes" {
+		t.Errorf("Unexpected archive contents: %v", files)
+	}
+}
+
+func TestPollingTriggers(t *testing.T) {
+	ts := setupTestServer(t)
+	defer ts.Close()
+
+	resp, _ := ts.POST("/api/auth/register", map[string]string{"username": "zapper", "password": "Password123!"})
+	var auth handlers.AuthResponse
+	json.NewDecoder(resp.Body).Decode(&auth)
+	resp.Body.Close()
+	token := auth.TokenPair.AccessToken
+
+	resp, _ = ts.POSTWithToken("/api/user/api-keys", map[string]string{"name": "Zapier"}, token)
+	var key handlers.CreateAPIKeyResponse
+	json.NewDecoder(resp.Body).Decode(&key)
+	resp.Body.Close()
+
+	poll := func(path, apiKey string) (int, handlers.TriggerResponse) {
+		req, _ := http.NewRequest("GET", ts.server.URL+path, nil)
+		req.Header.Set("Authorization", "Bearer "+apiKey)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("Poll failed: %v", err)
+		}
+		defer resp.Body.Close()
+		var page handlers.TriggerResponse
+		json.NewDecoder(resp.Body).Decode(&page)
+		return resp.StatusCode, page
+	}
+
+	if status, _ := poll("/api/triggers/new-paste", "pv_wrong"); status != http.StatusUnauthorized {
+		t.Errorf("Expected an invalid key to be refused, got %d", status)
+	}
+
+	var ids []string
+	for _, content := range []string{"first", "second", "third"} {
+		resp, _ := ts.POSTWithToken("/api/paste", CreatePasteRequest{Content: content}, token)
+		var created CreatePasteResponse
+		json.NewDecoder(resp.Body).Decode(&created)
+		resp.Body.Close()
+		ids = append(ids, created.ID)
+	}
+	resp, _ = ts.POST("/api/paste", CreatePasteRequest{Content: "someone else's"})
+	resp.Body.Close()
+
+	status, page := poll("/api/triggers/new-paste", key.Key)
+	if status != http.StatusOK || len(page.Data) != 3 || page.Data[0].PasteID != ids[2] || page.Data[2].PasteID != ids[0] {
+		t.Fatalf("Expected the user's three pastes newest first, got %d %+v", status, page.Data)
+	}
+
+	// Paging forward from an old cursor returns the oldest events first
+	_, older := poll("/api/triggers/new-paste?limit=1&after="+page.Data[2].ID, key.Key)
+	if len(older.Data) != 1 || older.Data[0].PasteID != ids[1] || older.Cursor != page.Data[1].ID {
+		t.Errorf("Expected the second paste after the first, got %+v", older)
+	}
+	_, none := poll("/api/triggers/new-paste?after="+page.Cursor, key.Key)
+	if len(none.Data) != 0 || none.Cursor != page.Cursor {
+		t.Errorf("Expected nothing after the latest cursor, got %+v", none)
+	}
+
+	resp, _ = ts.GET("/api/paste/" + ids[1])
+	resp.Body.Close()
+	resp, _ = ts.GET("/api/paste/" + ids[1])
+	resp.Body.Close()
+	_, views := poll("/api/triggers/paste-viewed", key.Key)
+	if len(views.Data) != 2 || views.Data[0].PasteID != ids[1] || views.Data[0].ID == views.Data[1].ID {
+		t.Errorf("Expected two distinct views, got %+v", views.Data)
+	}
+}
+
+func TestMCPToolServer(t *testing.T) {
+	ts := setupTestServer(t)
+	defer ts.Close()
+
+	resp, _ := ts.POST("/api/auth/register", map[string]string{"username": "assistant", "password": "Password123!"})
+	var auth handlers.AuthResponse
+	json.NewDecoder(resp.Body).Decode(&auth)
+	resp.Body.Close()
+	token := auth.TokenPair.AccessToken
+
+	createKey := func(body map[string]interface{}) (int, string) {
+		resp, _ := ts.POSTWithToken("/api/user/api-keys", body, token)
+		defer resp.Body.Close()
+		var key handlers.CreateAPIKeyResponse
+		json.NewDecoder(resp.Body).Decode(&key)
+		return resp.StatusCode, key.Key
+	}
+	if status, _ := createKey(map[string]interface{}{"name": "bad", "scopes": []string{"admin"}}); status != http.StatusBadRequest {
+		t.Errorf("Expected an unknown scope to be rejected, got %d", status)
+	}
+	_, writeOnly := createKey(map[string]interface{}{"name": "filer", "scopes": []string{"paste:create"}})
+	_, full := createKey(map[string]interface{}{"name": "assistant"})
+
+	type rpcResult struct {
+		Result struct {
+			Tools   []handlers.MCPTool `json:"tools"`
+			Content []struct {
+				Text string `json:"text"`
+			} `json:"content"`
+			IsError bool `json:"isError"`
+		} `json:"result"`
+		Error *struct {
+			Code int `json:"code"`
+		} `json:"error"`
+	}
+	call := func(apiKey, method string, params interface{}) rpcResult {
+		body, _ := json.Marshal(map[string]interface{}{"jsonrpc": "2.0", "id": 1, "method": method, "params": params})
+		req, _ := http.NewRequest("POST", ts.server.URL+"/api/mcp", bytes.NewReader(body))
+		req.Header.Set("Authorization", "Bearer "+apiKey)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("Request failed: %v", err)
+		}
+		defer resp.Body.Close()
+		var result rpcResult
+		json.NewDecoder(resp.Body).Decode(&result)
+		return result
+	}
+
+	if tools := call(writeOnly, "tools/list", nil).Result.Tools; len(tools) != 1 || tools[0].Name != "create_paste" {
+		t.Errorf("Expected only create_paste for a paste:create key, got %+v", tools)
+	}
+	if denied := call(writeOnly, "tools/call", map[string]interface{}{"name": "search_my_pastes"}); !denied.Result.IsError {
+		t.Errorf("Expected search to be refused without paste:read, got %+v", denied)
+	}
+	if unknown := call(full, "resources/list", nil); unknown.Error == nil || unknown.Error.Code != -32601 {
+		t.Errorf("Expected an unknown method error, got %+v", unknown)
+	}
+
+	created := call(writeOnly, "tools/call", map[string]interface{}{
+		"name":      "create_paste",
+		"arguments": map[string]string{"content": "deploy checklist", "visibility": "private"},
+	})
+	var paste CreatePasteResponse
+	if created.Result.IsError || len(created.Result.Content) != 1 || json.Unmarshal([]byte(created.Result.Content[0].Text), &paste) != nil || paste.ID == "" {
+		t.Fatalf("Expected the paste to be created, got %+v", created)
+	}
+
+	fetched := call(full, "tools/call", map[string]interface{}{"name": "get_paste", "arguments": map[string]string{"id": paste.ID}})
+	if fetched.Result.IsError || !strings.Contains(fetched.Result.Content[0].Text, "deploy checklist") {
+		t.Errorf("Expected the owner's private paste to be returned, got %+v", fetched)
+	}
+
+	resp, _ = ts.POST("/api/paste", CreatePasteRequest{Content: "deploy someone else's checklist"})
+	resp.Body.Close()
+	found := call(full, "tools/call", map[string]interface{}{"name": "search_my_pastes", "arguments": map[string]string{"query": "checklist"}})
+	var results struct {
+		Pastes []handlers.MCPSearchResult `json:"pastes"`
+	}
+	json.Unmarshal([]byte(found.Result.Content[0].Text), &results)
+	if len(results.Pastes) != 1 || results.Pastes[0].ID != paste.ID {
+		t.Errorf("Expected only the user's own paste, got %+v", found)
+	}
+}
+
+func TestPasteViewCounts(t *testing.T) {
+	ts := setupTestServer(t)
+	defer ts.Close()
+
+	resp, _ := ts.POST("/api/auth/register", map[string]string{"username": "counted", "password": "Password123!"})
+	var auth handlers.AuthResponse
+	json.NewDecoder(resp.Body).Decode(&auth)
+	resp.Body.Close()
+	token := auth.TokenPair.AccessToken
+
+	create := func(req map[string]interface{}) string {
+		resp, _ := ts.POSTWithToken("/api/paste", req, token)
+		defer resp.Body.Close()
+		var created CreatePasteResponse
+		json.NewDecoder(resp.Body).Decode(&created)
+		return created.ID
+	}
+	seen := create(map[string]interface{}{"content": "seen"})
+	untracked := create(map[string]interface{}{"content": "untracked", "do_not_track": true})
+
+	for _, path := range []string{"/api/paste/" + seen + "/raw", "/api/paste/" + seen + "/raw", "/api/paste/" + untracked} {
+		resp, _ := ts.GET(path)
+		resp.Body.Close()
+	}
+
+	resp, _ = ts.GET("/api/paste/" + seen)
+	var paste handlers.PasteResponse
+	json.NewDecoder(resp.Body).Decode(&paste)
+	resp.Body.Close()
+	if paste.Views == nil || *paste.Views != (handlers.PasteViews{Total: 3, Raw: 2, JSON: 1}) {
+		t.Errorf("Expected 2 raw and 1 JSON view, got %+v", paste.Views)
+	}
+
+	resp, _ = ts.GETWithToken("/api/user/pastes", token)
+	var list handlers.UserPastesResponse
+	json.NewDecoder(resp.Body).Decode(&list)
+	resp.Body.Close()
+	for _, item := range list.Pastes {
+		switch {
+		case item.ID == seen && (item.Views == nil || item.Views.Total != 3):
+			t.Errorf("Expected the listed paste to show 3 views, got %+v", item.Views)
+		case item.ID == untracked && item.Views != nil:
+			t.Errorf("Expected no views for a do_not_track paste, got %+v", item.Views)
+		}
+	}
+	if len(list.Pastes) != 2 {
+		t.Errorf("Expected both pastes to be listed, got %d", len(list.Pastes))
+	}
+}
+
+func TestAdminFindDuplicates(t *testing.T) {
+	ts := setupTestServer(t)
+	defer ts.Close()
+
+	credentials := map[string]string{"username": "responder", "password": "Password123!"}
+	resp, _ := ts.POST("/api/auth/register", credentials)
+	resp.Body.Close()
+	if _, err := models.NewUserRepository(ts.db.DB).SetRoleByUsername("responder", models.RoleAdmin); err != nil {
+		t.Fatalf("Failed to promote user: %v", err)
+	}
+	resp, _ = ts.POST("/api/auth/login", credentials)
+	var adminAuth handlers.AuthResponse
+	json.NewDecoder(resp.Body).Decode(&adminAuth)
+	resp.Body.Close()
+	token := adminAuth.TokenPair.AccessToken
+
+	var dump strings.Builder
+	for i := 0; i < 100; i++ {
+		fmt.Fprintf(&dump, "user%d@example.com:hunter%d\n", i, i*7)
+	}
+	leak := dump.String()
+	create := func(content string) string {
+		resp, _ := ts.POST("/api/paste", CreatePasteRequest{Content: content})
+		defer resp.Body.Close()
+		var created CreatePasteResponse
+		json.NewDecoder(resp.Body).Decode(&created)
+		return created.ID
+	}
+	original := create(leak)
+	repost := create(leak)
+	reformatted := create(strings.ToUpper(leak) + "\n\n")
+	create("something else entirely, nothing to see here")
+
+	find := func(body map[string]interface{}) (int, []handlers.DuplicatePasteItem) {
+		resp, _ := ts.POSTWithToken("/api/admin/pastes/duplicates", body, token)
+		defer resp.Body.Close()
+		var result struct {
+			Pastes []handlers.DuplicatePasteItem `json:"pastes"`
+		}
+		json.NewDecoder(resp.Body).Decode(&result)
+		return resp.StatusCode, result.Pastes
+	}
+
+	if status, _ := find(map[string]interface{}{}); status != http.StatusBadRequest {
+		t.Errorf("Expected a query without content or hash to be rejected, got %d", status)
+	}
+
+	_, found := find(map[string]interface{}{"content": leak})
+	exact := map[string]bool{}
+	for _, item := range found {
+		exact[item.ID] = item.Exact
+	}
+	if len(found) != 3 || !exact[original] || !exact[repost] || exact[reformatted] {
+		t.Errorf("Expected two exact copies and one near-duplicate, got %+v", found)
+	}
+
+	_, found = find(map[string]interface{}{"sha256": models.ContentSHA256(leak), "max_distance": 0})
+	if len(found) != 3 {
+		t.Errorf("Expected the digest to find all three copies, got %d", len(found))
+	}
+}
+
+func TestLegalExport(t *testing.T) {
+	ts := setupTestServer(t)
+	defer ts.Close()
+
+	resp, _ := ts.POST("/api/auth/register", map[string]string{"username": "subject", "password": "Password123!"})
+	var subject handlers.AuthResponse
+	json.NewDecoder(resp.Body).Decode(&subject)
+	resp.Body.Close()
+	resp, _ = ts.POSTWithToken("/api/paste", CreatePasteRequest{Content: "private notes"}, subject.TokenPair.AccessToken)
+	var created CreatePasteResponse
+	json.NewDecoder(resp.Body).Decode(&created)
+	resp.Body.Close()
+
+	credentials := map[string]string{"username": "counsel", "password": "Password123!"}
+	resp, _ = ts.POST("/api/auth/register", credentials)
+	resp.Body.Close()
+	if _, err := models.NewUserRepository(ts.db.DB).SetRoleByUsername("counsel", models.RoleAdmin); err != nil {
+		t.Fatalf("Failed to promote user: %v", err)
+	}
+	resp, _ = ts.POST("/api/auth/login", credentials)
+	var adminAuth handlers.AuthResponse
+	json.NewDecoder(resp.Body).Decode(&adminAuth)
+	resp.Body.Close()
+	token := adminAuth.TokenPair.AccessToken
+
+	resp, _ = ts.POSTWithToken("/api/admin/users/subject/legal-export", json.RawMessage(`{}`), token)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("Expected a reason to be required, got %d", resp.StatusCode)
+	}
+	resp, _ = ts.POSTWithToken("/api/admin/users/subject/legal-export", json.RawMessage(`{"reason":"Case 2026-114"}`), token)
+	data, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", resp.StatusCode, data)
+	}
+
+	archive, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatalf("Expected a zip archive: %v", err)
+	}
+	files := map[string][]byte{}
+	for _, f := range archive.File {
+		rc, _ := f.Open()
+		files[f.Name], _ = io.ReadAll(rc)
+		rc.Close()
+	}
+
+	var key handlers.SigningKeyResponse
+	resp, _ = ts.GET(handlers.SigningKeyPath)
+	json.NewDecoder(resp.Body).Decode(&key)
+	resp.Body.Close()
+	publicKey, _ := base64.StdEncoding.DecodeString(key.PublicKey)
+	signature, _ := base64.StdEncoding.DecodeString(string(files[handlers.LegalExportSignature]))
+	if !ed25519.Verify(publicKey, files[handlers.LegalExportManifest], signature) {
+		t.Fatal("Expected the manifest signature to verify")
+	}
+
+	var manifest handlers.LegalExportManifestFile
+	json.Unmarshal(files[handlers.LegalExportManifest], &manifest)
+	for name, digest := range manifest.Files {
+		sum := sha256.Sum256(files[name])
+		if hex.EncodeToString(sum[:]) != digest {
+			t.Errorf("Digest of %s does not match the manifest", name)
+		}
+	}
+	if manifest.Username != "subject" || files["pastes/"+created.ID+".txt"] == nil || len(manifest.Files) != len(files)-2 {
+		t.Errorf("Expected the subject's paste in a complete manifest, got %+v", manifest)
+	}
+	if !strings.Contains(string(files["audit_events.json"]), `"legal_export"`) || !strings.Contains(string(files["audit_events.json"]), "Case 2026-114") {
+		t.Errorf("Expected the export itself in the audit events, got %s", files["audit_events.json"])
+	}
+}
+
+func TestReadOnlyModeToggle(t *testing.T) {
+	ts := setupTestServer(t)
+	defer ts.Close()
+
+	credentials := map[string]string{"username": "operator", "password": "Password123!"}
+	resp, _ := ts.POST("/api/auth/register", credentials)
+	resp.Body.Close()
+	if _, err := models.NewUserRepository(ts.db.DB).SetRoleByUsername("operator", models.RoleAdmin); err != nil {
+		t.Fatalf("Failed to promote user: %v", err)
+	}
+	resp, _ = ts.POST("/api/auth/login", credentials)
+	var adminAuth handlers.AuthResponse
+	json.NewDecoder(resp.Body).Decode(&adminAuth)
+	resp.Body.Close()
+	token := adminAuth.TokenPair.AccessToken
+
+	resp, _ = ts.POSTWithToken("/api/paste", CreatePasteRequest{Content: "before the migration"}, token)
+	var created CreatePasteResponse
+	json.NewDecoder(resp.Body).Decode(&created)
+	resp.Body.Close()
+
+	setReadOnly := func(enabled bool) {
+		t.Helper()
+		body, _ := json.Marshal(handlers.ReadOnlyState{Enabled: enabled})
+		req, _ := http.NewRequest(http.MethodPut, ts.server.URL+"/api/admin/read-only", bytes.NewReader(body))
+		req.Header.Set("Authorization", "Bearer "+token)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("Failed to set read-only mode: %v", err)
+		}
+		var state handlers.ReadOnlyState
+		json.NewDecoder(resp.Body).Decode(&state)
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK || state.Enabled != enabled {
+			t.Fatalf("Expected read-only %v, got %d %+v", enabled, resp.StatusCode, state)
+		}
+	}
+
+	setReadOnly(true)
+	resp, _ = ts.POSTWithToken("/api/paste", CreatePasteRequest{Content: "during the migration"}, token)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("Expected writes to get 503 while read-only, got %d", resp.StatusCode)
+	}
+	resp, _ = ts.GET("/api/paste/" + created.ID)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("Expected reads to work while read-only, got %d", resp.StatusCode)
+	}
+	resp, _ = ts.POST("/api/auth/login", credentials)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("Expected admins to still log in while read-only, got %d", resp.StatusCode)
+	}
+
+	setReadOnly(false)
+	resp, _ = ts.POSTWithToken("/api/paste", CreatePasteRequest{Content: "after the migration"}, token)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated {
+		t.Errorf("Expected writes to work again, got %d", resp.StatusCode)
+	}
+}
+
+func TestIntegrityCheck(t *testing.T) {
+	ts := setupTestServer(t)
+	defer ts.Close()
+
+	credentials := map[string]string{"username": "auditor", "password": "Password123!"}
+	resp, _ := ts.POST("/api/auth/register", credentials)
+	resp.Body.Close()
+	if _, err := models.NewUserRepository(ts.db.DB).SetRoleByUsername("auditor", models.RoleAdmin); err != nil {
+		t.Fatalf("Failed to promote user: %v", err)
+	}
+	resp, _ = ts.POST("/api/auth/login", credentials)
+	var adminAuth handlers.AuthResponse
+	json.NewDecoder(resp.Body).Decode(&adminAuth)
+	resp.Body.Close()
+	token := adminAuth.TokenPair.AccessToken
+
+	// Rows foreign keys would have prevented
+	for _, stmt := range []string{
+		`PRAGMA foreign_keys = OFF`,
+		`INSERT INTO pastes (id, content, user_id) VALUES ('ownerless', 'x', 999)`,
+		`INSERT INTO pastes (id, content, expires_at) VALUES ('badexpiry', 'x', 'soon')`,
+		`INSERT INTO attachment_blobs (hash, data) VALUES ('` + models.ContentSHA256("orphan") + `', 'orphan')`,
+		`INSERT INTO paste_attachments (paste_id, name, content_type, size, hash) VALUES ('gone', 'a.txt', 'text/plain', 6, '` + models.ContentSHA256("orphan") + `')`,
+		`INSERT INTO avatar_blobs (hash, data) VALUES ('deadbeef', 'not the digest')`,
+		`PRAGMA foreign_keys = ON`,
+	} {
+		if _, err := ts.db.DB.Exec(stmt); err != nil {
+			t.Fatalf("Failed to seed %q: %v", stmt, err)
+		}
+	}
+
+	check := func(path string) handlers.IntegrityReport {
+		t.Helper()
+		var resp *http.Response
+		if path == "/api/admin/integrity" {
+			resp, _ = ts.GETWithToken(path, token)
+		} else {
+			resp, _ = ts.POSTWithToken(path, nil, token)
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("Expected 200 from %s, got %d", path, resp.StatusCode)
+		}
+		var report handlers.IntegrityReport
+		json.NewDecoder(resp.Body).Decode(&report)
+		return report
+	}
+
+	found := map[string]int{}
+	for _, issue := range check("/api/admin/integrity").Issues {
+		found[issue.Check] = issue.Count
+	}
+	for _, name := range []string{"pastes_of_deleted_users", "invalid_expiry", "orphaned_attachments", "unreferenced_avatar_blobs", "corrupt_avatar_blobs"} {
+		if found[name] != 1 {
+			t.Errorf("Expected one %s, got %d (%v)", name, found[name], found)
+		}
+	}
+
+	report := check("/api/admin/integrity/repair")
+	if !report.Repaired {
+		t.Error("Expected the report to say it repaired")
+	}
+	var owner *int
+	ts.db.DB.QueryRow(`SELECT user_id FROM pastes WHERE id = 'ownerless'`).Scan(&owner)
+	if owner != nil {
+		t.Errorf("Expected the paste of a deleted user to become anonymous, got owner %d", *owner)
+	}
+
+	if remaining := check("/api/admin/integrity").Issues; len(remaining) != 0 {
+		for _, issue := range remaining {
+			t.Errorf("Expected no issues after repair, got %s: %d", issue.Check, issue.Count)
+		}
+	}
+}
+
+func TestPasteTags(t *testing.T) {
+	ts := setupTestServer(t)
+	defer ts.Close()
+
+	resp, _ := ts.POST("/api/auth/register", map[string]string{"username": "tagger", "password": "Password123!"})
+	var auth handlers.AuthResponse
+	json.NewDecoder(resp.Body).Decode(&auth)
+	resp.Body.Close()
+	token := auth.TokenPair.AccessToken
+
+	create := func(tags []string) string {
+		t.Helper()
+		resp, _ := ts.POSTWithToken("/api/paste", map[string]interface{}{"content": "x", "tags": tags}, token)
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusCreated {
+			t.Fatalf("Expected 201 creating a paste tagged %v, got %d", tags, resp.StatusCode)
+		}
+		var created CreatePasteResponse
+		json.NewDecoder(resp.Body).Decode(&created)
+		return created.ID
+	}
+	first := create([]string{"Infra", "db"})
+	create([]string{"infra"})
+	create(nil)
+
+	resp, _ = ts.POSTWithToken("/api/paste", map[string]interface{}{"content": "x", "tags": []string{"not a tag"}}, token)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("Expected an invalid tag to be refused, got %d", resp.StatusCode)
+	}
+	resp, _ = ts.POST("/api/paste", map[string]interface{}{"content": "x", "expiry": "1h", "tags": []string{"infra"}})
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("Expected tags on an anonymous paste to be refused, got %d", resp.StatusCode)
+	}
+
+	resp, _ = ts.GET("/api/paste/" + first)
+	var paste handlers.PasteResponse
+	json.NewDecoder(resp.Body).Decode(&paste)
+	resp.Body.Close()
+	if strings.Join(paste.Tags, ",") != "db,infra" {
+		t.Errorf("Expected the paste to be tagged db and infra, got %v", paste.Tags)
+	}
+
+	resp, _ = ts.GETWithToken("/api/user/pastes?tag=INFRA", token)
+	var list handlers.UserPastesResponse
+	json.NewDecoder(resp.Body).Decode(&list)
+	resp.Body.Close()
+	if list.Total != 2 || len(list.Pastes) != 2 {
+		t.Fatalf("Expected 2 pastes tagged infra, got %d of %d", len(list.Pastes), list.Total)
+	}
+	for _, item := range list.Pastes {
+		if !slices.Contains(item.Tags, "infra") {
+			t.Errorf("Expected listed paste %s to carry its tags, got %v", item.ID, item.Tags)
+		}
+	}
+
+	resp, _ = ts.GETWithToken("/api/user/tags", token)
+	var tags handlers.UserTagsResponse
+	json.NewDecoder(resp.Body).Decode(&tags)
+	resp.Body.Close()
+	expected := []models.TagCount{{Name: "infra", Count: 2}, {Name: "db", Count: 1}}
+	if !slices.Equal(tags.Tags, expected) {
+		t.Errorf("Expected tag counts %v, got %v", expected, tags.Tags)
+	}
+}
+
+func TestSearchUserPastes(t *testing.T) {
+	ts := setupTestServer(t)
+	defer ts.Close()
+
+	register := func(username string) string {
+		resp, _ := ts.POST("/api/auth/register", map[string]string{"username": username, "password": "Password123!"})
+		defer resp.Body.Close()
+		var auth handlers.AuthResponse
+		json.NewDecoder(resp.Body).Decode(&auth)
+		return auth.TokenPair.AccessToken
+	}
+	token := register("searcher")
+	other := register("bystander")
+
+	for _, paste := range []struct {
+		token string
+		body  map[string]interface{}
+	}{
+		{token, map[string]interface{}{"content": "server {\n    listen 443 ssl;\n}", "language": "nginx"}},
+		{token, map[string]interface{}{"content": "ssl_certificate /etc/ssl/cert.pem", "password": "Secret123!"}},
+		{token, map[string]interface{}{"content": "nothing relevant"}},
+		{other, map[string]interface{}{"content": "listen 443 ssl"}},
+	} {
+		resp, _ := ts.POSTWithToken("/api/paste", paste.body, paste.token)
+		resp.Body.Close()
+	}
+
+	resp, _ := ts.GETWithToken("/api/user/pastes/search?q=SSL+listen", token)
+	var found handlers.UserPasteSearchResponse
+	json.NewDecoder(resp.Body).Decode(&found)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || found.Total != 1 || len(found.Pastes) != 1 {
+		t.Fatalf("Expected only the user's paste with both words, got %d: %+v", resp.StatusCode, found)
+	}
+	if found.Pastes[0].Snippet != "listen 443 ssl;" {
+		t.Errorf("Expected the matching line as the snippet, got %q", found.Pastes[0].Snippet)
+	}
+
+	resp, _ = ts.GETWithToken("/api/user/pastes/search?q=ssl_certificate", token)
+	found = handlers.UserPasteSearchResponse{}
+	json.NewDecoder(resp.Body).Decode(&found)
+	resp.Body.Close()
+	if len(found.Pastes) != 1 || found.Pastes[0].Snippet != "" {
+		t.Errorf("Expected the protected paste without a snippet, got %+v", found.Pastes)
+	}
+
+	resp, _ = ts.GETWithToken("/api/user/pastes/search?q=%22", token)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("Expected FTS syntax in the query to be searched literally, got %d", resp.StatusCode)
+	}
+
+	resp, _ = ts.GETWithToken("/api/user/pastes/search", token)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("Expected a missing query to be refused, got %d", resp.StatusCode)
+	}
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"flag"
+	"log"
+
+	"github.com/LonleySailor/privatepaste/backend/internal/config"
+	"github.com/LonleySailor/privatepaste/backend/internal/database"
+	"github.com/LonleySailor/privatepaste/backend/internal/models"
+)
+
+// backfillBatch is how many attachment contents are looked up at a time
+const backfillBatch = 500
+
+// runBackfillBlobs implements the backfill-blobs command, which copies the
+// attachment contents the shadow store is missing from the primary, so the
+// shadow can become the primary once reads stop diverging:
+//
+//	ATTACHMENT_SHADOW_S3_BUCKET=pastevault ./pastevault-server backfill-blobs
+//
+// Contents already in the shadow are left alone, so it can be run again.
+func runBackfillBlobs(cfg *config.Config, args []string) {
+	flags := flag.NewFlagSet("backfill-blobs", flag.ExitOnError)
+	dbPath := flags.String("db", cfg.DatabasePath, "SQLite database whose attachments to copy")
+	flags.Parse(args)
+
+	db, err := database.NewSQLiteDB(*dbPath)
+	if err != nil {
+		log.Fatalf("Failed to initialize database: %v", err)
+	}
+	defer db.Close()
+
+	store := attachmentShadowStore(cfg, db.DB)
+	if store == nil {
+		log.Fatal("No shadow store is configured; set ATTACHMENT_SHADOW_S3_BUCKET")
+	}
+	attachmentRepo := models.NewAttachmentRepository(db.DB)
+
+	var after string
+	checked, copied := 0, 0
+	for {
+		hashes, err := attachmentRepo.BlobHashes(after, backfillBatch)
+		if err != nil {
+			log.Fatalf("Failed to list attachment contents: %v", err)
+		}
+		for _, hash := range hashes {
+			ok, err := store.Backfill(hash)
+			if err != nil {
+				log.Fatalf("Failed to copy %s to the shadow after %d copied: %v", hash, copied, err)
+			}
+			if ok {
+				copied++
+			}
+		}
+		checked += len(hashes)
+		if len(hashes) < backfillBatch {
+			break
+		}
+		after = hashes[len(hashes)-1]
+	}
+	log.Printf("Checked %d attachment contents, copied %d to the shadow", checked, copied)
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"flag"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/LonleySailor/privatepaste/backend/internal/config"
+	"github.com/LonleySailor/privatepaste/backend/internal/database"
+	"github.com/LonleySailor/privatepaste/backend/internal/models"
+)
+
+// runCheckIntegrity implements the check-integrity command, which reports
+// inconsistent rows and, with -repair, fixes those that can be fixed safely.
+// It exits non-zero while problems remain, so it can run from cron.
+//
+//	./pastevault-server check-integrity -repair
+func runCheckIntegrity(cfg *config.Config, args []string) {
+	flags := flag.NewFlagSet("check-integrity", flag.ExitOnError)
+	dbPath := flags.String("db", cfg.DatabasePath, "SQLite database to check")
+	repair := flags.Bool("repair", false, "fix problems that can be fixed safely")
+	flags.Parse(args)
+
+	db, err := database.NewSQLiteDB(*dbPath)
+	if err != nil {
+		log.Fatalf("Failed to initialize database: %v", err)
+	}
+	defer db.Close()
+
+	issues, err := models.NewIntegrityRepository(db.DB).Check(*repair)
+	if err != nil {
+		log.Fatalf("Integrity check failed: %v", err)
+	}
+
+	remaining := 0
+	for _, issue := range issues {
+		log.Printf("%s: %d found, %d repaired - %s", issue.Check, issue.Count, issue.Repaired, issue.Description)
+		if len(issue.Samples) > 0 {
+			log.Printf("  e.g. %s", strings.Join(issue.Samples, ", "))
+		}
+		if issue.Repaired < int64(issue.Count) {
+			remaining++
+		}
+	}
+	if remaining > 0 {
+		db.Close()
+		os.Exit(1)
+	}
+	log.Println("No integrity problems left")
+}
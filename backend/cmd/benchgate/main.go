@@ -0,0 +1,76 @@
+// Command benchgate compares go test -bench output with a stored baseline and
+// exits non-zero when a benchmark regressed by more than the threshold, in
+// time or in allocations. Run the benchmarks several times so the medians it
+// compares are stable, and record a baseline on the machine doing the gating.
+//
+//	go test ./internal/bench -run '^$' -bench . -benchmem -count 5 | go run ./cmd/benchgate
+//	go test ./internal/bench -run '^$' -bench . -benchmem -count 5 | go run ./cmd/benchgate -update
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+
+	"github.com/LonleySailor/privatepaste/backend/internal/bench"
+)
+
+func main() {
+	baselinePath := flag.String("baseline", "bench-baseline.json", "baseline to compare with, or to write with -update")
+	inputPath := flag.String("input", "-", "go test -bench output to read, - for stdin")
+	threshold := flag.Float64("threshold", 0.2, "largest allowed slowdown, 0.2 for 20%")
+	update := flag.Bool("update", false, "record the results as the new baseline instead of comparing")
+	flag.Parse()
+
+	var input io.Reader = os.Stdin
+	if *inputPath != "-" {
+		f, err := os.Open(*inputPath)
+		if err != nil {
+			log.Fatalf("Failed to open benchmark output: %v", err)
+		}
+		defer f.Close()
+		input = f
+	}
+
+	current, err := bench.Parse(input)
+	if err != nil {
+		log.Fatalf("Failed to read benchmark output: %v", err)
+	}
+	if len(current) == 0 {
+		log.Fatalf("No benchmark results found in the input")
+	}
+
+	if *update {
+		if err := bench.SaveBaseline(*baselinePath, current); err != nil {
+			log.Fatalf("Failed to write baseline: %v", err)
+		}
+		log.Printf("Recorded %d benchmarks in %s", len(current), *baselinePath)
+		return
+	}
+
+	baseline, err := bench.LoadBaseline(*baselinePath)
+	if err != nil {
+		log.Fatalf("Failed to load baseline (record one with -update): %v", err)
+	}
+
+	changes, regressions := bench.Compare(baseline, current, *threshold)
+	for _, change := range changes {
+		fmt.Println(change)
+	}
+	for name := range current {
+		if _, ok := baseline[name]; !ok {
+			fmt.Printf("%s: not in the baseline\n", name)
+		}
+	}
+
+	if len(regressions) > 0 {
+		fmt.Printf("\n%d regression(s) over %.0f%%:\n", len(regressions), *threshold*100)
+		for _, r := range regressions {
+			fmt.Printf("  %s\n", r)
+		}
+		os.Exit(1)
+	}
+	fmt.Printf("\nNo regressions over %.0f%%\n", *threshold*100)
+}
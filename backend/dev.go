@@ -0,0 +1,69 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/LonleySailor/privatepaste/backend/internal/auth"
+	"github.com/LonleySailor/privatepaste/backend/internal/config"
+	"github.com/LonleySailor/privatepaste/backend/internal/models"
+	"github.com/LonleySailor/privatepaste/backend/internal/seed"
+	"github.com/LonleySailor/privatepaste/backend/pkg/utils"
+)
+
+// Dev mode accounts, recreated on every start since the database is in memory
+const (
+	devPassword   = "DevPassword123!"
+	devAdminName  = "dev_admin"
+	devUserName   = "dev_user"
+	devPasteCount = 50
+)
+
+// applyDevMode switches the configuration to a throwaway in-memory setup
+// that any local frontend can talk to
+func applyDevMode(cfg *config.Config) {
+	cfg.Environment = "development"
+	cfg.DatabasePath = ":memory:"
+	cfg.ReadReplicaPath = ""
+	cfg.CORSOrigins = []string{"*"}
+}
+
+// seedDevData creates the dev accounts and example pastes, then prints
+// ready-to-use tokens for both accounts
+func seedDevData(userRepo *models.UserRepository, pasteRepo *models.PasteRepository, tokenManager *auth.TokenManager) error {
+	// Minimum bcrypt cost keeps startup instant
+	passwordHash, err := utils.HashPasswordWithCost(devPassword, 4)
+	if err != nil {
+		return fmt.Errorf("failed to hash password: %w", err)
+	}
+
+	var users []*models.User
+	for _, username := range []string{devAdminName, devUserName} {
+		user := &models.User{Username: username, PasswordHash: passwordHash}
+		if err := userRepo.Create(user); err != nil {
+			return fmt.Errorf("failed to create %s: %w", username, err)
+		}
+		users = append(users, user)
+	}
+	if _, err := userRepo.SetRoleByUsername(devAdminName, models.RoleAdmin); err != nil {
+		return fmt.Errorf("failed to promote %s: %w", devAdminName, err)
+	}
+	users[0].Role = models.RoleAdmin
+
+	gen := seed.NewGenerator(1, time.Now())
+	if _, err := gen.CreatePastes(pasteRepo, devPasteCount, []int{users[0].ID, users[1].ID}, nil); err != nil {
+		return err
+	}
+
+	log.Printf("Dev mode: in-memory database seeded with %d pastes; data is lost on exit", devPasteCount)
+	for _, user := range users {
+		tokens, err := tokenManager.GenerateTokenPair(user.ID, user.Username, user.Role)
+		if err != nil {
+			return fmt.Errorf("failed to issue tokens for %s: %w", user.Username, err)
+		}
+		log.Printf("Dev mode: %s (%s) password %q\n  access token:  %s\n  refresh token: %s",
+			user.Username, user.Role, devPassword, tokens.AccessToken, tokens.RefreshToken)
+	}
+	return nil
+}
@@ -7,6 +7,20 @@ require (
 	github.com/mattn/go-sqlite3 v1.14.32
 	github.com/rs/cors v1.11.1
 	golang.org/x/crypto v0.42.0
+	golang.org/x/image v0.31.0
 )
 
-require github.com/golang-jwt/jwt/v5 v5.3.0 // indirect
+require (
+	github.com/golang-jwt/jwt/v5 v5.3.0
+	github.com/jackc/pgx/v5 v5.10.0
+	github.com/oschwald/maxminddb-golang v1.13.1
+)
+
+require (
+	github.com/jackc/pgpassfile v1.0.0 // indirect
+	github.com/jackc/pgservicefile v0.0.0-20240606120523-5a60cdf6a761 // indirect
+	github.com/jackc/puddle/v2 v2.2.2 // indirect
+	golang.org/x/sync v0.17.0 // indirect
+	golang.org/x/sys v0.36.0 // indirect
+	golang.org/x/text v0.29.0 // indirect
+)
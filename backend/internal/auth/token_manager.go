@@ -2,21 +2,39 @@ package auth
 
 import (
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
 )
 
+// Default token lifetimes
+const (
+	DefaultAccessTokenLifetime  = 15 * time.Minute
+	DefaultRefreshTokenLifetime = 7 * 24 * time.Hour
+)
+
 // TokenManager handles JWT token creation and validation
 type TokenManager struct {
 	accessSecret  string
 	refreshSecret string
+
+	accessLifetime  time.Duration
+	refreshLifetime time.Duration
+	leeway          time.Duration // Clock skew tolerated on exp, nbf and iat
+
+	// Stamped into tokens and required on validation when set, so tokens of
+	// one deployment are refused by another sharing its secret
+	issuer   string
+	audience string
 }
 
 // Claims represents the JWT claims
 type Claims struct {
 	UserID   int    `json:"user_id"`
 	Username string `json:"username"`
+	Role     string `json:"role,omitempty"`
+	Scope    string `json:"scope,omitempty"` // Space-separated; empty for a person's default scopes
 	jwt.RegisteredClaims
 }
 
@@ -29,34 +47,81 @@ type RefreshClaims struct {
 // TokenPair represents access and refresh tokens
 type TokenPair struct {
 	AccessToken  string `json:"access_token"`
-	RefreshToken string `json:"refresh_token"`
+	RefreshToken string `json:"refresh_token,omitempty"`
 	ExpiresAt    int64  `json:"expires_at"`
 }
 
 // NewTokenManager creates a new token manager
 func NewTokenManager(accessSecret, refreshSecret string) *TokenManager {
 	return &TokenManager{
-		accessSecret:  accessSecret,
-		refreshSecret: refreshSecret,
+		accessSecret:    accessSecret,
+		refreshSecret:   refreshSecret,
+		accessLifetime:  DefaultAccessTokenLifetime,
+		refreshLifetime: DefaultRefreshTokenLifetime,
+	}
+}
+
+// SetLifetimes sets how long access and refresh tokens are valid
+func (tm *TokenManager) SetLifetimes(access, refresh time.Duration) {
+	tm.accessLifetime = access
+	tm.refreshLifetime = refresh
+}
+
+// SetLeeway sets the clock skew tolerated when validating token times
+func (tm *TokenManager) SetLeeway(leeway time.Duration) {
+	tm.leeway = leeway
+}
+
+// SetAudience sets the issuer and audience of issued tokens. Tokens without
+// matching iss and aud claims are then refused.
+func (tm *TokenManager) SetAudience(issuer, audience string) {
+	tm.issuer = issuer
+	tm.audience = audience
+}
+
+// registeredClaims returns the standard claims of a token issued now
+func (tm *TokenManager) registeredClaims(userID int, now, expiresAt time.Time) jwt.RegisteredClaims {
+	claims := jwt.RegisteredClaims{
+		Issuer:    tm.issuer,
+		ExpiresAt: jwt.NewNumericDate(expiresAt),
+		IssuedAt:  jwt.NewNumericDate(now),
+		NotBefore: jwt.NewNumericDate(now),
+		Subject:   fmt.Sprintf("%d", userID),
+	}
+	if tm.audience != "" {
+		claims.Audience = jwt.ClaimStrings{tm.audience}
+	}
+	return claims
+}
+
+// parserOptions returns the checks applied to every token
+func (tm *TokenManager) parserOptions() []jwt.ParserOption {
+	options := []jwt.ParserOption{
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuedAt(),
+		jwt.WithLeeway(tm.leeway),
+	}
+	if tm.issuer != "" {
+		options = append(options, jwt.WithIssuer(tm.issuer))
 	}
+	if tm.audience != "" {
+		options = append(options, jwt.WithAudience(tm.audience))
+	}
+	return options
 }
 
 // GenerateTokenPair generates both access and refresh tokens
-func (tm *TokenManager) GenerateTokenPair(userID int, username string) (*TokenPair, error) {
+func (tm *TokenManager) GenerateTokenPair(userID int, username, role string) (*TokenPair, error) {
 	now := time.Now()
-	accessExpiresAt := now.Add(15 * time.Minute)    // 15 minutes as specified
-	refreshExpiresAt := now.Add(7 * 24 * time.Hour) // 7 days as specified
+	accessExpiresAt := now.Add(tm.accessLifetime)
+	refreshExpiresAt := now.Add(tm.refreshLifetime)
 
 	// Create access token
 	accessClaims := &Claims{
-		UserID:   userID,
-		Username: username,
-		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(accessExpiresAt),
-			IssuedAt:  jwt.NewNumericDate(now),
-			NotBefore: jwt.NewNumericDate(now),
-			Subject:   fmt.Sprintf("%d", userID),
-		},
+		UserID:           userID,
+		Username:         username,
+		Role:             role,
+		RegisteredClaims: tm.registeredClaims(userID, now, accessExpiresAt),
 	}
 
 	accessToken := jwt.NewWithClaims(jwt.SigningMethodHS256, accessClaims)
@@ -67,13 +132,8 @@ func (tm *TokenManager) GenerateTokenPair(userID int, username string) (*TokenPa
 
 	// Create refresh token
 	refreshClaims := &RefreshClaims{
-		UserID: userID,
-		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(refreshExpiresAt),
-			IssuedAt:  jwt.NewNumericDate(now),
-			NotBefore: jwt.NewNumericDate(now),
-			Subject:   fmt.Sprintf("%d", userID),
-		},
+		UserID:           userID,
+		RegisteredClaims: tm.registeredClaims(userID, now, refreshExpiresAt),
 	}
 
 	refreshToken := jwt.NewWithClaims(jwt.SigningMethodHS256, refreshClaims)
@@ -89,6 +149,29 @@ func (tm *TokenManager) GenerateTokenPair(userID int, username string) (*TokenPa
 	}, nil
 }
 
+// GenerateServiceToken generates an access token for a service account,
+// limited to the given scopes. Service accounts get no refresh token; they
+// request a new access token with their credentials instead.
+func (tm *TokenManager) GenerateServiceToken(userID int, name string, scopes []string) (*TokenPair, error) {
+	now := time.Now()
+	expiresAt := now.Add(tm.accessLifetime)
+
+	claims := &Claims{
+		UserID:           userID,
+		Username:         name,
+		Role:             "service",
+		Scope:            strings.Join(scopes, " "),
+		RegisteredClaims: tm.registeredClaims(userID, now, expiresAt),
+	}
+
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(tm.accessSecret))
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign access token: %w", err)
+	}
+
+	return &TokenPair{AccessToken: token, ExpiresAt: expiresAt.Unix()}, nil
+}
+
 // ValidateAccessToken validates an access token and returns the claims
 func (tm *TokenManager) ValidateAccessToken(tokenString string) (*Claims, error) {
 	token, err := jwt.ParseWithClaims(tokenString, &Claims{}, func(token *jwt.Token) (interface{}, error) {
@@ -96,7 +179,7 @@ func (tm *TokenManager) ValidateAccessToken(tokenString string) (*Claims, error)
 			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
 		}
 		return []byte(tm.accessSecret), nil
-	})
+	}, tm.parserOptions()...)
 
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse token: %w", err)
@@ -116,7 +199,7 @@ func (tm *TokenManager) ValidateRefreshToken(tokenString string) (*RefreshClaims
 			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
 		}
 		return []byte(tm.refreshSecret), nil
-	})
+	}, tm.parserOptions()...)
 
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse refresh token: %w", err)
@@ -130,12 +213,41 @@ func (tm *TokenManager) ValidateRefreshToken(tokenString string) (*RefreshClaims
 }
 
 // RefreshAccessToken generates a new access token from a valid refresh token
-func (tm *TokenManager) RefreshAccessToken(refreshTokenString string, username string) (*TokenPair, error) {
+func (tm *TokenManager) RefreshAccessToken(refreshTokenString string, username, role string) (*TokenPair, error) {
 	claims, err := tm.ValidateRefreshToken(refreshTokenString)
 	if err != nil {
 		return nil, fmt.Errorf("invalid refresh token: %w", err)
 	}
 
 	// Generate new token pair
-	return tm.GenerateTokenPair(claims.UserID, username)
+	return tm.GenerateTokenPair(claims.UserID, username, role)
+}
+
+// Scopes granted by access tokens. Tokens of people act for their user, and
+// admin tokens may also use the admin API. Service account tokens carry only
+// the narrower scopes they were granted.
+const (
+	ScopeUser        = "user"
+	ScopeAdmin       = "admin"
+	ScopePasteCreate = "paste:create"
+	ScopePasteDelete = "paste:delete"
+	ScopePasteRead   = "paste:read" // Reading and searching one's own pastes
+)
+
+// ServiceScopes are the scopes that may be granted to service accounts
+var ServiceScopes = []string{ScopePasteCreate, ScopePasteDelete}
+
+// APIKeyScopes are the scopes an API key may be limited to; a key without
+// scopes may do everything API keys can
+var APIKeyScopes = []string{ScopePasteCreate, ScopePasteRead}
+
+// Scopes returns what the token is allowed to do
+func (c *Claims) Scopes() []string {
+	if c.Scope != "" || c.Role == "service" {
+		return strings.Fields(c.Scope)
+	}
+	if c.Role == "admin" {
+		return []string{ScopeUser, ScopeAdmin}
+	}
+	return []string{ScopeUser}
 }
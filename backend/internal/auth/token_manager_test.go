@@ -0,0 +1,89 @@
+package auth
+
+import (
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func signAccessToken(t *testing.T, secret string, claims *Claims) string {
+	t.Helper()
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(secret))
+	if err != nil {
+		t.Fatalf("Failed to sign token: %v", err)
+	}
+	return token
+}
+
+func TestTokenManager_Lifetimes(t *testing.T) {
+	tm := NewTokenManager("access", "refresh")
+	tm.SetLifetimes(time.Hour, 48*time.Hour)
+
+	pair, err := tm.GenerateTokenPair(1, "alice", "")
+	if err != nil {
+		t.Fatalf("Failed to generate tokens: %v", err)
+	}
+	if remaining := time.Until(time.Unix(pair.ExpiresAt, 0)); remaining < 59*time.Minute || remaining > time.Hour {
+		t.Errorf("Expected the access token to last an hour, expires in %v", remaining)
+	}
+
+	refresh, err := tm.ValidateRefreshToken(pair.RefreshToken)
+	if err != nil {
+		t.Fatalf("Expected the refresh token to validate: %v", err)
+	}
+	if remaining := time.Until(refresh.ExpiresAt.Time); remaining < 47*time.Hour || remaining > 48*time.Hour {
+		t.Errorf("Expected the refresh token to last two days, expires in %v", remaining)
+	}
+}
+
+func TestTokenManager_Leeway(t *testing.T) {
+	tm := NewTokenManager("access", "refresh")
+	now := time.Now()
+	// Expired 10 seconds ago and issued 10 seconds ahead of this clock
+	claims := &Claims{UserID: 1, RegisteredClaims: jwt.RegisteredClaims{
+		ExpiresAt: jwt.NewNumericDate(now.Add(-10 * time.Second)),
+		IssuedAt:  jwt.NewNumericDate(now.Add(10 * time.Second)),
+	}}
+	token := signAccessToken(t, "access", claims)
+
+	if _, err := tm.ValidateAccessToken(token); err == nil {
+		t.Error("Expected an expired token to be refused without leeway")
+	}
+	tm.SetLeeway(30 * time.Second)
+	if _, err := tm.ValidateAccessToken(token); err != nil {
+		t.Errorf("Expected the token to be accepted within the leeway: %v", err)
+	}
+
+	// Tokens must carry an expiry at all
+	if _, err := tm.ValidateAccessToken(signAccessToken(t, "access", &Claims{UserID: 1})); err == nil {
+		t.Error("Expected a token without an expiry to be refused")
+	}
+}
+
+func TestTokenManager_Audience(t *testing.T) {
+	production := NewTokenManager("shared", "shared-refresh")
+	production.SetAudience("pastevault", "pastevault:production")
+	staging := NewTokenManager("shared", "shared-refresh")
+	staging.SetAudience("pastevault", "pastevault:staging")
+
+	pair, err := staging.GenerateTokenPair(1, "alice", "")
+	if err != nil {
+		t.Fatalf("Failed to generate tokens: %v", err)
+	}
+	if _, err := staging.ValidateAccessToken(pair.AccessToken); err != nil {
+		t.Errorf("Expected the token to validate where it was issued: %v", err)
+	}
+	if _, err := production.ValidateAccessToken(pair.AccessToken); err == nil {
+		t.Error("Expected an access token for another audience to be refused")
+	}
+	if _, err := production.ValidateRefreshToken(pair.RefreshToken); err == nil {
+		t.Error("Expected a refresh token for another audience to be refused")
+	}
+
+	// Tokens issued before the audience was configured lack the claims
+	legacy, _ := NewTokenManager("shared", "shared-refresh").GenerateTokenPair(1, "alice", "")
+	if _, err := production.ValidateAccessToken(legacy.AccessToken); err == nil {
+		t.Error("Expected a token without an audience to be refused")
+	}
+}
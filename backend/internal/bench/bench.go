@@ -0,0 +1,142 @@
+// Package bench benchmarks the paste hot paths against each storage backend
+// and compares benchmark results with a stored baseline, so a performance
+// change can be shown to help and a regression can fail the build.
+package bench
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Result is the median of the runs of one benchmark
+type Result struct {
+	NsPerOp     float64 `json:"ns_per_op"`
+	AllocsPerOp float64 `json:"allocs_per_op"`
+}
+
+// Baseline maps benchmark names, without the GOMAXPROCS suffix, to results
+type Baseline map[string]Result
+
+// benchLine matches a result line of go test -bench, with -benchmem
+var benchLine = regexp.MustCompile(`^(Benchmark\S+?)(?:-\d+)?\s+\d+\s+([\d.]+) ns/op(?:.*?\s([\d.]+) allocs/op)?`)
+
+// Parse reads go test -bench output. A benchmark run several times with
+// -count is reduced to its median, which is less noisy than the mean.
+func Parse(r io.Reader) (Baseline, error) {
+	runs := map[string][]Result{}
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		m := benchLine.FindStringSubmatch(strings.TrimSpace(scanner.Text()))
+		if m == nil {
+			continue
+		}
+		var result Result
+		result.NsPerOp, _ = strconv.ParseFloat(m[2], 64)
+		if m[3] != "" {
+			result.AllocsPerOp, _ = strconv.ParseFloat(m[3], 64)
+		}
+		runs[m[1]] = append(runs[m[1]], result)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	results := Baseline{}
+	for name, rs := range runs {
+		results[name] = Result{
+			NsPerOp:     median(rs, func(r Result) float64 { return r.NsPerOp }),
+			AllocsPerOp: median(rs, func(r Result) float64 { return r.AllocsPerOp }),
+		}
+	}
+	return results, nil
+}
+
+// median returns the median of one field of a set of results
+func median(results []Result, field func(Result) float64) float64 {
+	values := make([]float64, len(results))
+	for i, r := range results {
+		values[i] = field(r)
+	}
+	sort.Float64s(values)
+	mid := len(values) / 2
+	if len(values)%2 == 0 {
+		return (values[mid-1] + values[mid]) / 2
+	}
+	return values[mid]
+}
+
+// LoadBaseline reads a baseline written by SaveBaseline
+func LoadBaseline(path string) (Baseline, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var baseline Baseline
+	if err := json.Unmarshal(data, &baseline); err != nil {
+		return nil, fmt.Errorf("invalid baseline %s: %w", path, err)
+	}
+	return baseline, nil
+}
+
+// SaveBaseline writes results as the baseline later runs are compared with
+func SaveBaseline(path string, results Baseline) error {
+	data, err := json.MarshalIndent(results, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0644)
+}
+
+// Change is how one benchmark moved from the baseline
+type Change struct {
+	Name     string
+	Metric   string // "ns/op" or "allocs/op"
+	Baseline float64
+	Current  float64
+}
+
+// Delta is the relative change, positive when the benchmark got slower
+func (c Change) Delta() float64 {
+	if c.Baseline == 0 {
+		return 0
+	}
+	return (c.Current - c.Baseline) / c.Baseline
+}
+
+func (c Change) String() string {
+	return fmt.Sprintf("%s: %s %.0f -> %.0f (%+.1f%%)", c.Name, c.Metric, c.Baseline, c.Current, c.Delta()*100)
+}
+
+// Compare returns every change of the benchmarks in both baseline and
+// current, in name order, and those that regressed by more than threshold
+// (0.2 for 20%). Benchmarks missing from either side are not compared.
+func Compare(baseline, current Baseline, threshold float64) (changes, regressions []Change) {
+	names := make([]string, 0, len(current))
+	for name := range current {
+		if _, ok := baseline[name]; ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		base, cur := baseline[name], current[name]
+		for _, change := range []Change{
+			{Name: name, Metric: "ns/op", Baseline: base.NsPerOp, Current: cur.NsPerOp},
+			{Name: name, Metric: "allocs/op", Baseline: base.AllocsPerOp, Current: cur.AllocsPerOp},
+		} {
+			changes = append(changes, change)
+			if change.Delta() > threshold {
+				regressions = append(regressions, change)
+			}
+		}
+	}
+	return changes, regressions
+}
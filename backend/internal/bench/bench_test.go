@@ -0,0 +1,77 @@
+package bench
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const sampleOutput = `goos: linux
+goarch: amd64
+pkg: github.com/LonleySailor/privatepaste/backend/internal/bench
+BenchmarkPaste/memory/get-8         	   50000	     20000 ns/op	    4096 B/op	      40 allocs/op
+BenchmarkPaste/memory/get-8         	   50000	     30000 ns/op	    4096 B/op	      40 allocs/op
+BenchmarkPaste/memory/get-8         	   50000	     90000 ns/op	    4096 B/op	      42 allocs/op
+BenchmarkPaste/sqlite-wal/create-8  	    2000	    500000 ns/op
+PASS
+ok  	github.com/LonleySailor/privatepaste/backend/internal/bench	4.2s
+`
+
+func TestParse(t *testing.T) {
+	results, err := Parse(strings.NewReader(sampleOutput))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if len(results) != 2 {
+		t.Fatalf("Expected 2 benchmarks, got %d: %v", len(results), results)
+	}
+
+	// The median keeps the outlying third run from skewing the result
+	get := results["BenchmarkPaste/memory/get"]
+	if get.NsPerOp != 30000 || get.AllocsPerOp != 40 {
+		t.Errorf("Expected median 30000 ns/op and 40 allocs/op, got %+v", get)
+	}
+	create := results["BenchmarkPaste/sqlite-wal/create"]
+	if create.NsPerOp != 500000 || create.AllocsPerOp != 0 {
+		t.Errorf("Expected 500000 ns/op without allocs, got %+v", create)
+	}
+}
+
+func TestCompare(t *testing.T) {
+	baseline := Baseline{
+		"BenchmarkA":       {NsPerOp: 1000, AllocsPerOp: 10},
+		"BenchmarkB":       {NsPerOp: 1000, AllocsPerOp: 10},
+		"BenchmarkRemoved": {NsPerOp: 1000},
+	}
+	current := Baseline{
+		"BenchmarkA":   {NsPerOp: 1100, AllocsPerOp: 10}, // Within the threshold
+		"BenchmarkB":   {NsPerOp: 900, AllocsPerOp: 15},  // Faster, but allocating more
+		"BenchmarkNew": {NsPerOp: 5000},
+	}
+
+	changes, regressions := Compare(baseline, current, 0.2)
+	if len(changes) != 4 {
+		t.Errorf("Expected 4 changes for the 2 common benchmarks, got %d", len(changes))
+	}
+	if len(regressions) != 1 {
+		t.Fatalf("Expected 1 regression, got %v", regressions)
+	}
+	if r := regressions[0]; r.Name != "BenchmarkB" || r.Metric != "allocs/op" {
+		t.Errorf("Expected BenchmarkB allocs/op to regress, got %s", r)
+	}
+}
+
+func TestBaselineRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "baseline.json")
+	want := Baseline{"BenchmarkA": {NsPerOp: 1234.5, AllocsPerOp: 7}}
+	if err := SaveBaseline(path, want); err != nil {
+		t.Fatalf("SaveBaseline failed: %v", err)
+	}
+	got, err := LoadBaseline(path)
+	if err != nil {
+		t.Fatalf("LoadBaseline failed: %v", err)
+	}
+	if got["BenchmarkA"] != want["BenchmarkA"] {
+		t.Errorf("Expected %+v, got %+v", want, got)
+	}
+}
@@ -0,0 +1,146 @@
+package bench
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/LonleySailor/privatepaste/backend/internal/database"
+	"github.com/LonleySailor/privatepaste/backend/internal/handlers"
+	"github.com/LonleySailor/privatepaste/backend/internal/models"
+	"github.com/LonleySailor/privatepaste/backend/pkg/utils"
+	"github.com/LonleySailor/privatepaste/backend/pkg/validation"
+	"github.com/gorilla/mux"
+)
+
+const benchPassword = "BenchPassword123!"
+
+// backend is a paste store the benchmarks run against, with the ID of a
+// user owning pastes in it
+type backend struct {
+	name  string
+	setup func(b *testing.B) (handlers.PasteRepositoryInterface, int)
+}
+
+var backends = []backend{
+	{"memory", func(b *testing.B) (handlers.PasteRepositoryInterface, int) {
+		return models.NewMemoryPasteRepository(), 1
+	}},
+	{"sqlite-wal", setupSQLite},
+}
+
+// setupSQLite opens a file database in WAL mode, as a production server runs
+func setupSQLite(b *testing.B) (handlers.PasteRepositoryInterface, int) {
+	db, err := database.NewSQLiteDB(filepath.Join(b.TempDir(), "bench.db"))
+	if err != nil {
+		b.Fatalf("Failed to open database: %v", err)
+	}
+	b.Cleanup(func() { db.Close() })
+	if _, err := db.DB.Exec("PRAGMA journal_mode = WAL"); err != nil {
+		b.Fatalf("Failed to enable WAL: %v", err)
+	}
+
+	user := &models.User{Username: "bench_user", PasswordHash: "unused"}
+	if err := models.NewUserRepository(db.DB).Create(user); err != nil {
+		b.Fatalf("Failed to create user: %v", err)
+	}
+	return models.NewPasteRepository(db.DB), user.ID
+}
+
+// withUser authenticates a request as userID
+func withUser(req *http.Request, userID int) *http.Request {
+	return req.WithContext(context.WithValue(req.Context(), "userID", userID))
+}
+
+// createPaste creates a paste through the handler, returning its ID
+func createPaste(b *testing.B, h *handlers.PasteHandler, userID int, reqBody handlers.CreatePasteRequest) string {
+	b.Helper()
+
+	body, _ := json.Marshal(reqBody)
+	req := httptest.NewRequest("POST", "/api/paste", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	if userID != 0 {
+		req = withUser(req, userID)
+	}
+	rr := httptest.NewRecorder()
+	h.Create(rr, req)
+	if rr.Code != http.StatusCreated {
+		b.Fatalf("Expected status %d creating paste, got %d: %s", http.StatusCreated, rr.Code, rr.Body.String())
+	}
+
+	var resp handlers.CreatePasteResponse
+	json.Unmarshal(rr.Body.Bytes(), &resp)
+	return resp.ID
+}
+
+// serve runs one request against a handler, failing on an unexpected status
+func serve(b *testing.B, fn http.HandlerFunc, req *http.Request, status int) {
+	rr := httptest.NewRecorder()
+	fn(rr, req)
+	if rr.Code != status {
+		b.Fatalf("Expected status %d, got %d: %s", status, rr.Code, rr.Body.String())
+	}
+}
+
+// BenchmarkPaste runs the create, get, list and unlock paths against each
+// backend. cmd/benchgate compares its results with the stored baseline.
+func BenchmarkPaste(b *testing.B) {
+	content := "package main\n\nfunc main() {\n\tprintln(\"benchmark\")\n}\n"
+
+	for _, be := range backends {
+		b.Run(be.name, func(b *testing.B) {
+			repo, userID := be.setup(b)
+			h := handlers.NewPasteHandler(repo, utils.NewIDGenerator(), validation.NewValidator())
+
+			b.Run("create", func(b *testing.B) {
+				body, _ := json.Marshal(handlers.CreatePasteRequest{Content: content, Language: "go", Expiry: "1h"})
+				b.ReportAllocs()
+				b.ResetTimer()
+				for i := 0; i < b.N; i++ {
+					req := httptest.NewRequest("POST", "/api/paste", bytes.NewReader(body))
+					req.Header.Set("Content-Type", "application/json")
+					serve(b, h.Create, req, http.StatusCreated)
+				}
+			})
+
+			b.Run("get", func(b *testing.B) {
+				id := createPaste(b, h, 0, handlers.CreatePasteRequest{Content: content, Language: "go", Expiry: "1h"})
+				b.ReportAllocs()
+				b.ResetTimer()
+				for i := 0; i < b.N; i++ {
+					req := mux.SetURLVars(httptest.NewRequest("GET", "/api/paste/"+id, nil), map[string]string{"id": id})
+					serve(b, h.GetByID, req, http.StatusOK)
+				}
+			})
+
+			b.Run("list", func(b *testing.B) {
+				for i := 0; i < 50; i++ {
+					createPaste(b, h, userID, handlers.CreatePasteRequest{Content: fmt.Sprintf("%s// %d\n", content, i), Expiry: "1h"})
+				}
+				b.ReportAllocs()
+				b.ResetTimer()
+				for i := 0; i < b.N; i++ {
+					req := withUser(httptest.NewRequest("GET", "/api/user/pastes?limit=20", nil), userID)
+					serve(b, h.GetUserPastes, req, http.StatusOK)
+				}
+			})
+
+			b.Run("unlock", func(b *testing.B) {
+				id := createPaste(b, h, 0, handlers.CreatePasteRequest{Content: content, Password: benchPassword, Expiry: "1h"})
+				body, _ := json.Marshal(map[string]string{"password": benchPassword})
+				b.ReportAllocs()
+				b.ResetTimer()
+				for i := 0; i < b.N; i++ {
+					req := httptest.NewRequest("POST", "/api/paste/"+id+"/unlock", bytes.NewReader(body))
+					req = mux.SetURLVars(req, map[string]string{"id": id})
+					serve(b, h.GetByIDWithPassword, req, http.StatusOK)
+				}
+			})
+		})
+	}
+}
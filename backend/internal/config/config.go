@@ -1,38 +1,410 @@
 package config
 
 import (
+	"fmt"
 	"os"
+	"path/filepath"
 	"strconv"
+	"strings"
 )
 
 // Config holds all configuration for the application
 type Config struct {
-	// Server configuration
-	Port string
+	// Server configuration; a systemd-activated socket overrides both
+	Port           string
+	UnixSocketPath string      // Listen on this Unix socket instead of Port
+	UnixSocketMode os.FileMode // Permissions of the Unix socket
 
-	// Database configuration
-	DatabasePath string
+	// Data directory holding all persistent state, so containers need a single
+	// volume. Each location below defaults to a path inside it.
+	DataDir          string
+	DatabasePath     string
+	BlobDir          string // Large paste content stored outside the database
+	BackupDir        string // Database backups
+	AutocertCacheDir string // TLS certificates obtained via ACME
+
+	// Optional read-only replica for lookups and listings
+	ReadReplicaPath          string
+	ReadReplicaMaxLagSeconds int // Reads fall back to the primary beyond this lag
+
+	// Connection pool limits for drivers other than SQLite
+	DBMaxOpenConns           int
+	DBMaxIdleConns           int
+	DBConnMaxLifetimeMinutes int
 
 	// Security configuration
 	JWTSecret        string
 	RefreshJWTSecret string
 
+	// Token lifetimes, and the clock skew tolerated when validating tokens
+	AccessTokenMinutes int
+	RefreshTokenHours  int
+	JWTLeewaySeconds   int
+
+	// Issuer and audience claims of tokens; the audience defaults to one per
+	// environment so tokens are not accepted across environments
+	JWTIssuer   string
+	JWTAudience string
+
+	// Privacy configuration
+	IPHashSecret       string // Secret the rotating IP hash salts are derived from
+	IPHashRotationDays int    // Days between salt rotations; 0 never rotates
+	DataRetentionDays  int    // Days before IP hashes, access logs and audit details are scrubbed; 0 keeps them
+	URLSigningSecret   string // Secret used to sign expiring raw and download URLs
+
+	// Ed25519 key signing raw paste responses, generated if missing; empty disables signing
+	ResponseSigningKeyPath string
+
+	// GeoIP configuration; restrictions apply to paste creation and registration,
+	// and the database also enables restricting individual pastes to countries
+	GeoIPDatabasePath string // MaxMind country or city database; empty disables GeoIP
+	GeoIPAllow        []string
+	GeoIPDeny         []string
+
+	// Scraper tarpit configuration (404s per window on paste lookups)
+	TarpitDelayThreshold int // Misses before responses are slowed
+	TarpitBanThreshold   int // Misses before the client is banned
+	TarpitWindowMinutes  int
+	TarpitBanMinutes     int
+
+	// Reverse proxies (addresses or CIDR ranges) whose X-Forwarded-For and
+	// X-Real-IP headers are believed; empty ignores the headers except over
+	// a Unix socket, which only a local proxy can reach
+	TrustedProxies []string
+
+	// Prometheus metrics are served on MetricsAddr, an internal listener, or
+	// on the main port to requests bearing MetricsToken; with neither they
+	// are not exposed
+	MetricsAddr  string
+	MetricsToken string
+
+	// Expiry of pastes created without an account. The default is a duration
+	// like "24h", "never", or "required" to make anonymous requests choose.
+	AnonymousDefaultExpiry string
+	AnonymousAllowNever    bool // Whether anonymous pastes may be kept forever
+
+	// Expiry choices offered to clients, empty for the built-in ones; in strict
+	// mode the only ones accepted
+	ExpiryPresets []string
+	StrictExpiry  bool
+
+	// Bandwidth quota in megabytes served per IP or user per hour; 0 disables
+	BandwidthLimitMB int
+
+	// Database circuit breaker; opens after this many consecutive failures
+	DBBreakerThreshold       int
+	DBBreakerCooldownSeconds int
+
+	// Background job workers for webhooks and emails
+	JobWorkers int
+
+	// Outgoing mail; an empty SMTP host disables email features
+	SMTPHost     string
+	SMTPPort     int
+	SMTPUsername string
+	SMTPPassword string
+	MailFrom     string
+
+	// Shared secret for the inbound email webhook; empty disables it
+	InboundEmailSecret string
+
+	// OpenID Connect single sign-on; an empty issuer disables it
+	OIDCIssuerURL     string
+	OIDCClientID      string
+	OIDCClientSecret  string
+	OIDCRedirectURL   string // This server's /api/auth/oidc/callback
+	OIDCScopes        []string
+	OIDCUsernameClaim string
+	OIDCGroupsClaim   string
+	OIDCPostLoginURL  string // Frontend page receiving the tokens in its URL fragment
+
+	// LDAP directory sign-in; an empty URL disables it. The user filter
+	// contains %s where the escaped username goes.
+	LDAPURL               string
+	LDAPStartTLS          bool
+	LDAPBindDN            string // Account searching for users; empty binds anonymously
+	LDAPBindPassword      string
+	LDAPBaseDN            string
+	LDAPUserFilter        string
+	LDAPUsernameAttribute string
+	LDAPGroupAttribute    string
+
+	// Single sign-on group mapping: members of an admin group get the admin
+	// role, and when allowed groups are set only their members may sign in
+	SSOAdminGroups   []string
+	SSOAllowedGroups []string
+
+	// Bearer secret identity providers present to the SCIM provisioning
+	// endpoint; empty disables it
+	SCIMToken string
+
+	// Bearer secret services present to introspect access tokens; empty
+	// disables introspection
+	IntrospectionSecret string
+
+	// Secret S3 gateway credentials are derived from; empty disables the gateway
+	S3GatewaySecret string
+
+	// Read-only mirror of another instance; an empty upstream disables it
+	MirrorUpstreamURL     string
+	MirrorCacheTTLSeconds int // How long mirrored copies are kept before refetching
+
+	// Start with writes paused, as during a storage migration; admins can
+	// also toggle this at runtime
+	ReadOnly bool
+
+	// Destination of the migrate-data command; the DSN is read from the
+	// environment so passwords stay out of shell history
+	MigrateToDriver string
+	MigrateToDSN    string
+
+	// CDN purging of deleted and expired pastes; an empty provider disables it
+	CDNProvider  string // fastly or cloudflare
+	CDNServiceID string // Fastly service ID or Cloudflare zone ID
+	CDNAPIToken  string
+
+	// SSH paste server (ssh paste@host < file); an empty port disables it
+	SSHPort        string
+	SSHHostKeyPath string // Generated on first start if missing
+
+	// Chaos mode for testing clients against a degraded backend; ignored in production
+	ChaosErrorPercent     int // Share of API requests failed with 503
+	ChaosLatencyPercent   int // Share of API requests delayed
+	ChaosMaxLatencyMillis int // Upper bound of the injected delay
+
 	// CORS configuration
 	CORSOrigins []string
 
+	// Admin configuration
+	AdminUsernames []string // Users promoted to admin at startup
+
+	// Username policy: the shortest username allowed, and names nobody may
+	// register or rename to; empty uses the built-in list
+	UsernameMinLength int
+	ReservedUsernames []string
+
+	// How long a renamed user's old name stays reserved for them, so nobody
+	// can impersonate them right after a rename; 0 releases it at once
+	RenameCooldownDays int
+
 	// Environment
 	Environment string
+
+	// Rendering configuration
+	DiagramRendererURL string // Kroki-compatible service; empty disables diagram rendering
+	SandboxURL         string // Piston-compatible execution API; empty disables snippet execution
+
+	// Files attached to pastes; a zero count disables attachments
+	AttachmentMaxSize  int // Bytes per file
+	AttachmentMaxCount int // Files per paste
+
+	// S3-compatible bucket attachment contents are also written to in shadow
+	// mode, ahead of moving them out of the database; an empty bucket
+	// disables it
+	AttachmentShadowS3Endpoint  string
+	AttachmentShadowS3Bucket    string
+	AttachmentShadowS3Prefix    string
+	AttachmentShadowS3Region    string
+	AttachmentShadowS3AccessKey string
+	AttachmentShadowS3SecretKey string
+
+	// Which store serves attachment contents while a shadow bucket is
+	// configured: "database" or "s3". The other one is the shadow.
+	AttachmentBlobPrimary string
+
+	// Days a paste may go unread before its content moves to the compressed
+	// cold tier; 0 keeps all pastes hot
+	ColdStorageDays int
+
+	// In-memory cache of recently created and read pastes; a zero size
+	// disables it. With prerendering, the highlighted HTML of new pastes is
+	// rendered into it ahead of their first view.
+	PasteCacheSize       int
+	PasteCacheTTLSeconds int
+	PrerenderHTML        bool
+
+	// Slow client protection. Request headers must arrive within the header
+	// timeout and fit, with the request line, in MaxHeaderBytes; request
+	// lines over MaxRequestLineBytes are refused. Requests of every route
+	// fail when no body data arrives for BodyChunkTimeoutSeconds or the
+	// whole body takes longer than BodyReadTimeoutSeconds.
+	ReadHeaderTimeoutSeconds int
+	IdleTimeoutSeconds       int
+	MaxHeaderBytes           int
+	MaxRequestLineBytes      int
+	BodyChunkTimeoutSeconds  int
+	BodyReadTimeoutSeconds   int
+
+	// ClamAV daemon scanning attachments (host:port or a unix socket path);
+	// empty accepts attachments unscanned
+	ClamdAddress string
+
+	// Service generating titles and summaries of new pastes; empty
+	// disables summarization
+	SummaryHookURL   string
+	SummaryHookToken string
 }
 
+// Stores that can serve attachment contents, for AttachmentBlobPrimary
+const (
+	BlobPrimaryDatabase = "database"
+	BlobPrimaryS3       = "s3"
+)
+
 // Load creates a new Config instance with values from environment variables
 // or sensible defaults for development
 func Load() *Config {
 	config := &Config{
 		Port:             getEnv("PORT", "8080"),
-		DatabasePath:     getEnv("DATABASE_PATH", "./privatepaste.db"),
+		DataDir:          getEnv("DATA_DIR", "."),
+		UnixSocketPath:   getEnv("UNIX_SOCKET", ""),
+		UnixSocketMode:   getEnvAsFileMode("UNIX_SOCKET_MODE", 0o660),
 		JWTSecret:        getEnv("JWT_SECRET", "your-secret-key-change-in-production"),
 		RefreshJWTSecret: getEnv("REFRESH_JWT_SECRET", "your-refresh-secret-key-change-in-production"),
 		Environment:      getEnv("ENVIRONMENT", "development"),
+
+		AccessTokenMinutes: getEnvAsInt("ACCESS_TOKEN_MINUTES", 15),
+		RefreshTokenHours:  getEnvAsInt("REFRESH_TOKEN_HOURS", 7*24),
+		JWTLeewaySeconds:   getEnvAsInt("JWT_LEEWAY_SECONDS", 30),
+		JWTIssuer:          getEnv("JWT_ISSUER", "pastevault"),
+
+		IPHashRotationDays: getEnvAsInt("IP_HASH_ROTATION_DAYS", 30),
+		DataRetentionDays:  getEnvAsInt("DATA_RETENTION_DAYS", 90),
+
+		GeoIPDatabasePath: getEnv("GEOIP_DB_PATH", ""),
+		GeoIPAllow:        getEnvAsList("GEOIP_ALLOW_COUNTRIES"),
+		GeoIPDeny:         getEnvAsList("GEOIP_DENY_COUNTRIES"),
+		AdminUsernames:    getEnvAsList("ADMIN_USERNAMES"),
+		UsernameMinLength: getEnvAsInt("USERNAME_MIN_LENGTH", 3),
+		ReservedUsernames: getEnvAsList("RESERVED_USERNAMES"),
+
+		RenameCooldownDays: getEnvAsInt("USERNAME_RENAME_COOLDOWN_DAYS", 30),
+
+		BandwidthLimitMB: getEnvAsInt("BANDWIDTH_LIMIT_MB", 100),
+
+		AnonymousDefaultExpiry: getEnv("ANONYMOUS_DEFAULT_EXPIRY", "24h"),
+		AnonymousAllowNever:    getEnvAsBool("ANONYMOUS_ALLOW_NEVER", true),
+		ExpiryPresets:          getEnvAsList("EXPIRY_PRESETS"),
+		StrictExpiry:           getEnvAsBool("STRICT_EXPIRY", false),
+
+		ReadReplicaPath:          getEnv("READ_REPLICA_PATH", ""),
+		ReadReplicaMaxLagSeconds: getEnvAsInt("READ_REPLICA_MAX_LAG_SECONDS", 5),
+
+		DBMaxOpenConns:           getEnvAsInt("DB_MAX_OPEN_CONNS", 25),
+		DBMaxIdleConns:           getEnvAsInt("DB_MAX_IDLE_CONNS", 5),
+		DBConnMaxLifetimeMinutes: getEnvAsInt("DB_CONN_MAX_LIFETIME_MINUTES", 30),
+
+		JobWorkers: getEnvAsInt("JOB_WORKERS", 4),
+
+		SMTPHost:     getEnv("SMTP_HOST", ""),
+		SMTPPort:     getEnvAsInt("SMTP_PORT", 587),
+		SMTPUsername: getEnv("SMTP_USERNAME", ""),
+		SMTPPassword: getEnv("SMTP_PASSWORD", ""),
+		MailFrom:     getEnv("MAIL_FROM", "pastevault@localhost"),
+
+		InboundEmailSecret: getEnv("INBOUND_EMAIL_SECRET", ""),
+
+		IntrospectionSecret: getEnv("INTROSPECTION_SECRET", ""),
+
+		OIDCIssuerURL:     getEnv("OIDC_ISSUER_URL", ""),
+		OIDCClientID:      getEnv("OIDC_CLIENT_ID", ""),
+		OIDCClientSecret:  getEnv("OIDC_CLIENT_SECRET", ""),
+		OIDCRedirectURL:   getEnv("OIDC_REDIRECT_URL", ""),
+		OIDCScopes:        getEnvAsList("OIDC_SCOPES"),
+		OIDCUsernameClaim: getEnv("OIDC_USERNAME_CLAIM", "preferred_username"),
+		OIDCGroupsClaim:   getEnv("OIDC_GROUPS_CLAIM", "groups"),
+		OIDCPostLoginURL:  getEnv("OIDC_POST_LOGIN_URL", "/login"),
+
+		LDAPURL:               getEnv("LDAP_URL", ""),
+		LDAPStartTLS:          getEnvAsBool("LDAP_START_TLS", false),
+		LDAPBindDN:            getEnv("LDAP_BIND_DN", ""),
+		LDAPBindPassword:      getEnv("LDAP_BIND_PASSWORD", ""),
+		LDAPBaseDN:            getEnv("LDAP_BASE_DN", ""),
+		LDAPUserFilter:        getEnv("LDAP_USER_FILTER", "(uid=%s)"),
+		LDAPUsernameAttribute: getEnv("LDAP_USERNAME_ATTRIBUTE", "uid"),
+		LDAPGroupAttribute:    getEnv("LDAP_GROUP_ATTRIBUTE", "memberOf"),
+
+		SSOAdminGroups:   getEnvAsList("SSO_ADMIN_GROUPS"),
+		SSOAllowedGroups: getEnvAsList("SSO_ALLOWED_GROUPS"),
+		SCIMToken:        getEnv("SCIM_TOKEN", ""),
+
+		SSHPort: getEnv("SSH_PORT", ""),
+
+		ResponseSigningKeyPath: getEnv("RESPONSE_SIGNING_KEY_PATH", ""),
+
+		S3GatewaySecret: getEnv("S3_GATEWAY_SECRET", ""),
+
+		MirrorUpstreamURL:     getEnv("MIRROR_UPSTREAM_URL", ""),
+		MirrorCacheTTLSeconds: getEnvAsInt("MIRROR_CACHE_TTL_SECONDS", 86400),
+
+		ReadOnly: getEnvAsBool("READ_ONLY", false),
+
+		MigrateToDriver: getEnv("MIGRATE_TO_DRIVER", "postgres"),
+		MigrateToDSN:    getEnv("MIGRATE_TO_DSN", ""),
+
+		CDNProvider:  getEnv("CDN_PROVIDER", ""),
+		CDNServiceID: getEnv("CDN_SERVICE_ID", ""),
+		CDNAPIToken:  getEnv("CDN_API_TOKEN", ""),
+
+		ChaosErrorPercent:     getEnvAsInt("CHAOS_ERROR_PERCENT", 0),
+		ChaosLatencyPercent:   getEnvAsInt("CHAOS_LATENCY_PERCENT", 0),
+		ChaosMaxLatencyMillis: getEnvAsInt("CHAOS_MAX_LATENCY_MS", 2000),
+
+		DBBreakerThreshold:       getEnvAsInt("DB_BREAKER_THRESHOLD", 5),
+		DBBreakerCooldownSeconds: getEnvAsInt("DB_BREAKER_COOLDOWN_SECONDS", 30),
+
+		TarpitDelayThreshold: getEnvAsInt("TARPIT_DELAY_THRESHOLD", 20),
+		TarpitBanThreshold:   getEnvAsInt("TARPIT_BAN_THRESHOLD", 50),
+		TarpitWindowMinutes:  getEnvAsInt("TARPIT_WINDOW_MINUTES", 10),
+		TarpitBanMinutes:     getEnvAsInt("TARPIT_BAN_MINUTES", 30),
+
+		TrustedProxies: getEnvAsList("TRUSTED_PROXIES"),
+
+		MetricsAddr:  getEnv("METRICS_ADDR", ""),
+		MetricsToken: getEnv("METRICS_TOKEN", ""),
+
+		DiagramRendererURL: getEnv("DIAGRAM_RENDERER_URL", ""),
+		SandboxURL:         getEnv("SANDBOX_URL", ""),
+
+		AttachmentMaxSize:  getEnvAsInt("ATTACHMENT_MAX_SIZE", 1<<20),
+		AttachmentMaxCount: getEnvAsInt("ATTACHMENT_MAX_COUNT", 5),
+		ClamdAddress:       getEnv("CLAMD_ADDRESS", ""),
+		SummaryHookURL:     getEnv("SUMMARY_HOOK_URL", ""),
+		SummaryHookToken:   getEnv("SUMMARY_HOOK_TOKEN", ""),
+
+		AttachmentShadowS3Endpoint:  getEnv("ATTACHMENT_SHADOW_S3_ENDPOINT", "https://s3.amazonaws.com"),
+		AttachmentShadowS3Bucket:    getEnv("ATTACHMENT_SHADOW_S3_BUCKET", ""),
+		AttachmentShadowS3Prefix:    getEnv("ATTACHMENT_SHADOW_S3_PREFIX", "attachments/"),
+		AttachmentShadowS3Region:    getEnv("ATTACHMENT_SHADOW_S3_REGION", "us-east-1"),
+		AttachmentShadowS3AccessKey: getEnv("ATTACHMENT_SHADOW_S3_ACCESS_KEY", ""),
+		AttachmentShadowS3SecretKey: getEnv("ATTACHMENT_SHADOW_S3_SECRET_KEY", ""),
+		AttachmentBlobPrimary:       getEnv("ATTACHMENT_BLOB_PRIMARY", BlobPrimaryDatabase),
+
+		ColdStorageDays:      getEnvAsInt("COLD_STORAGE_DAYS", 0),
+		PasteCacheSize:       getEnvAsInt("PASTE_CACHE_SIZE", 1000),
+		PasteCacheTTLSeconds: getEnvAsInt("PASTE_CACHE_TTL_SECONDS", 300),
+		PrerenderHTML:        getEnvAsBool("PRERENDER_HTML", false),
+
+		ReadHeaderTimeoutSeconds: getEnvAsInt("READ_HEADER_TIMEOUT_SECONDS", 10),
+		IdleTimeoutSeconds:       getEnvAsInt("IDLE_TIMEOUT_SECONDS", 120),
+		MaxHeaderBytes:           getEnvAsInt("MAX_HEADER_BYTES", 16*1024),
+		MaxRequestLineBytes:      getEnvAsInt("MAX_REQUEST_LINE_BYTES", 8*1024),
+		BodyChunkTimeoutSeconds:  getEnvAsInt("BODY_CHUNK_TIMEOUT_SECONDS", 10),
+		BodyReadTimeoutSeconds:   getEnvAsInt("BODY_READ_TIMEOUT_SECONDS", 120),
+	}
+
+	config.DatabasePath = getEnv("DATABASE_PATH", filepath.Join(config.DataDir, "privatepaste.db"))
+	config.BlobDir = getEnv("BLOB_DIR", filepath.Join(config.DataDir, "blobs"))
+	config.BackupDir = getEnv("BACKUP_DIR", filepath.Join(config.DataDir, "backups"))
+	config.AutocertCacheDir = getEnv("AUTOCERT_CACHE_DIR", filepath.Join(config.DataDir, "autocert"))
+	config.SSHHostKeyPath = getEnv("SSH_HOST_KEY_PATH", filepath.Join(config.DataDir, "ssh_host_ed25519_key"))
+
+	// Fall back to a secret derived from the JWT secret so hashes survive restarts
+	config.IPHashSecret = getEnv("IP_HASH_SECRET", "ip-hash:"+config.JWTSecret)
+	config.URLSigningSecret = getEnv("URL_SIGNING_SECRET", "url-signing:"+config.JWTSecret)
+	config.JWTAudience = getEnv("JWT_AUDIENCE", "pastevault:"+config.Environment)
+	if len(config.OIDCScopes) == 0 {
+		config.OIDCScopes = []string{"openid", "profile", "email"}
 	}
 
 	// Set CORS origins based on environment
@@ -71,6 +443,55 @@ func getEnvAsInt(key string, defaultValue int) int {
 	return defaultValue
 }
 
+// getEnvAsBool gets an environment variable such as "true" or "0" as a
+// boolean with a fallback default value
+func getEnvAsBool(key string, defaultValue bool) bool {
+	if value := os.Getenv(key); value != "" {
+		if boolValue, err := strconv.ParseBool(value); err == nil {
+			return boolValue
+		}
+	}
+	return defaultValue
+}
+
+// getEnvAsFileMode gets an octal environment variable such as "0660" as file
+// permissions with a fallback default value
+func getEnvAsFileMode(key string, defaultValue os.FileMode) os.FileMode {
+	if value := os.Getenv(key); value != "" {
+		if mode, err := strconv.ParseUint(value, 8, 32); err == nil {
+			return os.FileMode(mode).Perm()
+		}
+	}
+	return defaultValue
+}
+
+// getEnvAsList gets a comma separated environment variable as a list, skipping empty entries
+func getEnvAsList(key string) []string {
+	var list []string
+	for _, item := range strings.Split(os.Getenv(key), ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+// PrepareDataDir creates the data directory and its subdirectories if they
+// are missing. New directories are only accessible to the server's user
+// since they hold pastes, backups and TLS keys.
+func (c *Config) PrepareDataDir() error {
+	dirs := []string{c.DataDir, c.BlobDir, c.BackupDir, c.AutocertCacheDir}
+	if c.DatabasePath != ":memory:" {
+		dirs = append(dirs, filepath.Dir(c.DatabasePath))
+	}
+	for _, dir := range dirs {
+		if err := os.MkdirAll(dir, 0o700); err != nil {
+			return fmt.Errorf("failed to create %s: %w", dir, err)
+		}
+	}
+	return nil
+}
+
 // IsDevelopment returns true if running in development mode
 func (c *Config) IsDevelopment() bool {
 	return c.Environment == "development"
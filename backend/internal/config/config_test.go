@@ -0,0 +1,37 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDataDirLayout(t *testing.T) {
+	dataDir := filepath.Join(t.TempDir(), "data")
+	t.Setenv("DATA_DIR", dataDir)
+	t.Setenv("BACKUP_DIR", filepath.Join(dataDir, "snapshots"))
+
+	cfg := Load()
+	if cfg.DatabasePath != filepath.Join(dataDir, "privatepaste.db") {
+		t.Errorf("Expected the database inside the data directory, got %s", cfg.DatabasePath)
+	}
+	if cfg.BlobDir != filepath.Join(dataDir, "blobs") || cfg.AutocertCacheDir != filepath.Join(dataDir, "autocert") {
+		t.Errorf("Unexpected default layout: blobs %s, autocert %s", cfg.BlobDir, cfg.AutocertCacheDir)
+	}
+	if cfg.BackupDir != filepath.Join(dataDir, "snapshots") {
+		t.Errorf("Expected BACKUP_DIR to override the default, got %s", cfg.BackupDir)
+	}
+
+	if err := cfg.PrepareDataDir(); err != nil {
+		t.Fatalf("PrepareDataDir failed: %v", err)
+	}
+	for _, dir := range []string{dataDir, cfg.BlobDir, cfg.BackupDir, cfg.AutocertCacheDir} {
+		info, err := os.Stat(dir)
+		if err != nil || !info.IsDir() {
+			t.Fatalf("Expected directory %s to exist", dir)
+		}
+		if perm := info.Mode().Perm(); perm != 0o700 {
+			t.Errorf("Expected %s to be private, got %o", dir, perm)
+		}
+	}
+}
@@ -0,0 +1,120 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/LonleySailor/privatepaste/backend/internal/metrics"
+)
+
+// ErrCircuitOpen is returned instead of running a query while the breaker is open
+var ErrCircuitOpen = errors.New("database circuit breaker is open")
+
+var breakerOpen = metrics.NewGauge("pastevault_db_circuit_open", "1 while the database circuit breaker is rejecting queries.")
+
+// Circuit breaker states
+const (
+	BreakerClosed   = "closed"
+	BreakerOpen     = "open"
+	BreakerHalfOpen = "half-open"
+)
+
+// Breaker stops issuing database calls after sustained failures so requests
+// fail fast instead of piling up behind a broken database. After the cooldown
+// a single probe call is let through; its outcome closes or reopens the breaker.
+type Breaker struct {
+	mu        sync.Mutex
+	threshold int           // Consecutive failures before opening
+	cooldown  time.Duration // Time to stay open before probing
+	failures  int
+	openUntil time.Time
+	probing   bool
+	now       func() time.Time
+}
+
+// NewBreaker creates a breaker that opens after threshold consecutive failures
+func NewBreaker(threshold int, cooldown time.Duration) *Breaker {
+	return &Breaker{
+		threshold: threshold,
+		cooldown:  cooldown,
+		now:       time.Now,
+	}
+}
+
+// Do runs fn unless the breaker is open, recording its outcome
+func (b *Breaker) Do(fn func() error) error {
+	if err := b.allow(); err != nil {
+		return err
+	}
+	err := fn()
+	b.record(err)
+	return err
+}
+
+// allow reports whether a call may proceed, claiming the probe slot when the
+// cooldown has elapsed
+func (b *Breaker) allow() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.openUntil.IsZero() {
+		return nil
+	}
+	if b.now().Before(b.openUntil) || b.probing {
+		return ErrCircuitOpen
+	}
+	b.probing = true
+	return nil
+}
+
+// record updates the breaker with a call outcome. Missing rows are not failures.
+func (b *Breaker) record(err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.probing = false
+	if err == nil || errors.Is(err, sql.ErrNoRows) {
+		b.failures = 0
+		b.openUntil = time.Time{}
+		breakerOpen.Set(0)
+		return
+	}
+
+	b.failures++
+	if b.failures >= b.threshold || !b.openUntil.IsZero() {
+		b.openUntil = b.now().Add(b.cooldown)
+		breakerOpen.Set(1)
+	}
+}
+
+// State returns the current breaker state
+func (b *Breaker) State() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch {
+	case b.openUntil.IsZero():
+		return BreakerClosed
+	case b.now().Before(b.openUntil):
+		return BreakerOpen
+	default:
+		return BreakerHalfOpen
+	}
+}
+
+// RetryAfter returns how long until the breaker allows a probe, or zero if
+// calls are currently allowed
+func (b *Breaker) RetryAfter() time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.openUntil.IsZero() {
+		return 0
+	}
+	if wait := b.openUntil.Sub(b.now()); wait > 0 {
+		return wait
+	}
+	return 0
+}
@@ -0,0 +1,56 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestBreaker(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	b := NewBreaker(3, 30*time.Second)
+	b.now = func() time.Time { return now }
+
+	failing := func() error { return errors.New("database is locked") }
+	calls := 0
+	counting := func() error { calls++; return nil }
+
+	// Missing rows never count as failures
+	b.Do(func() error { return sql.ErrNoRows })
+
+	for i := 0; i < 3; i++ {
+		if err := b.Do(failing); err == ErrCircuitOpen {
+			t.Fatalf("Call %d: breaker opened too early", i+1)
+		}
+	}
+	if b.State() != BreakerOpen {
+		t.Fatalf("Expected breaker to be open, got %s", b.State())
+	}
+	if b.RetryAfter() != 30*time.Second {
+		t.Errorf("Expected RetryAfter of 30s, got %v", b.RetryAfter())
+	}
+
+	if err := b.Do(counting); err != ErrCircuitOpen || calls != 0 {
+		t.Fatalf("Expected open breaker to reject calls, got %v with %d calls", err, calls)
+	}
+
+	// After the cooldown a failed probe reopens immediately
+	now = now.Add(31 * time.Second)
+	if b.State() != BreakerHalfOpen {
+		t.Fatalf("Expected breaker to be half-open, got %s", b.State())
+	}
+	b.Do(failing)
+	if b.State() != BreakerOpen {
+		t.Fatalf("Expected failed probe to reopen the breaker, got %s", b.State())
+	}
+
+	// A successful probe closes it
+	now = now.Add(31 * time.Second)
+	if err := b.Do(counting); err != nil || calls != 1 {
+		t.Fatalf("Expected probe to run, got %v with %d calls", err, calls)
+	}
+	if b.State() != BreakerClosed || b.RetryAfter() != 0 {
+		t.Errorf("Expected breaker to close, got %s", b.State())
+	}
+}
@@ -0,0 +1,642 @@
+package database
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// migrationProgressTable records in the destination how far each table has
+// been copied, so an interrupted migration resumes where it stopped
+const migrationProgressTable = "data_migration_progress"
+
+// DefaultMigrationBatch is how many rows are copied per transaction
+const DefaultMigrationBatch = 1000
+
+// DataMigration copies every table of a SQLite database into another
+// database, such as Postgres. Rows are copied in rowid order in batches, each
+// committed with the progress made, so running it again after an
+// interruption continues from the last batch. The source must not change
+// while it runs; put the instance in read-only mode first.
+type DataMigration struct {
+	src    *sql.DB
+	dst    *sql.DB
+	target migrationTarget
+	batch  int
+	logf   func(format string, args ...interface{})
+}
+
+// TableCheck compares a table in the source and the destination. Content
+// hashes do not depend on row order or on how each driver returns values.
+type TableCheck struct {
+	Table      string
+	SourceRows int64
+	DestRows   int64
+	SourceHash string
+	DestHash   string
+}
+
+// OK reports whether the table was copied completely and unaltered
+func (c TableCheck) OK() bool {
+	return c.SourceRows == c.DestRows && c.SourceHash == c.DestHash
+}
+
+// migrationTable is a table of the source database
+type migrationTable struct {
+	name      string
+	createSQL string
+	columns   []migrationColumn
+}
+
+// migrationColumn is a column of a source table with its declared type
+type migrationColumn struct {
+	name     string
+	datetime bool
+}
+
+// migrationTarget is what a data migration needs to know about the engine
+// of the destination
+type migrationTarget interface {
+	placeholder(n int) string
+	// value converts a value read from the source for inserting
+	value(column migrationColumn, v interface{}) interface{}
+	// prepare makes sure the destination has the tables to copy into
+	prepare(ctx context.Context, m *DataMigration, tables []migrationTable) error
+	// finish restores what was left out while copying, such as indexes
+	finish(ctx context.Context, m *DataMigration, tables []migrationTable) error
+}
+
+// NewDataMigration creates a migration from the SQLite database src to dst,
+// opened with the given driver. Only SQLite and Postgres destinations are
+// supported. A nil logf discards progress messages.
+func NewDataMigration(src *sql.DB, dstDriver string, dst *sql.DB, batch int, logf func(format string, args ...interface{})) (*DataMigration, error) {
+	var target migrationTarget
+	switch dstDriver {
+	case DriverSQLite:
+		target = sqliteTarget{}
+	case DriverPostgres:
+		target = postgresTarget{}
+	default:
+		return nil, fmt.Errorf("unsupported destination driver %q", dstDriver)
+	}
+	if batch <= 0 {
+		batch = DefaultMigrationBatch
+	}
+	if logf == nil {
+		logf = func(string, ...interface{}) {}
+	}
+	return &DataMigration{src: src, dst: dst, target: target, batch: batch, logf: logf}, nil
+}
+
+// Copy copies every table not yet completely copied
+func (m *DataMigration) Copy(ctx context.Context) error {
+	tables, err := m.tables(ctx)
+	if err != nil {
+		return err
+	}
+
+	if _, err := m.dst.ExecContext(ctx, `
+		CREATE TABLE IF NOT EXISTS `+migrationProgressTable+` (
+			table_name TEXT PRIMARY KEY,
+			last_rowid BIGINT NOT NULL,
+			copied BIGINT NOT NULL,
+			done BOOLEAN NOT NULL
+		)`); err != nil {
+		return fmt.Errorf("failed to create progress table: %w", err)
+	}
+	if err := m.target.prepare(ctx, m, tables); err != nil {
+		return err
+	}
+
+	for _, table := range tables {
+		if err := m.copyTable(ctx, table); err != nil {
+			return fmt.Errorf("failed to copy %s: %w", table.name, err)
+		}
+	}
+	return m.target.finish(ctx, m, tables)
+}
+
+// Verify compares the row count and content hash of every table
+func (m *DataMigration) Verify(ctx context.Context) ([]TableCheck, error) {
+	tables, err := m.tables(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	checks := make([]TableCheck, 0, len(tables))
+	for _, table := range tables {
+		check := TableCheck{Table: table.name}
+		if check.SourceRows, check.SourceHash, err = hashTable(ctx, m.src, table); err != nil {
+			return nil, fmt.Errorf("failed to hash source %s: %w", table.name, err)
+		}
+		if check.DestRows, check.DestHash, err = hashTable(ctx, m.dst, table); err != nil {
+			return nil, fmt.Errorf("failed to hash destination %s: %w", table.name, err)
+		}
+		checks = append(checks, check)
+	}
+	return checks, nil
+}
+
+// Finish drops the progress kept for resuming, once the copy is verified
+func (m *DataMigration) Finish(ctx context.Context) error {
+	_, err := m.dst.ExecContext(ctx, `DROP TABLE IF EXISTS `+migrationProgressTable)
+	return err
+}
+
+// tables returns the source's tables in an order where tables come after
+// those they reference. Virtual tables and their shadow tables are left
+// out; they are rebuilt from the tables they index.
+func (m *DataMigration) tables(ctx context.Context) ([]migrationTable, error) {
+	rows, err := m.src.QueryContext(ctx, `
+		SELECT name, sql FROM sqlite_master
+		WHERE type = 'table' AND name NOT LIKE 'sqlite_%'
+		ORDER BY name`)
+	if err != nil {
+		return nil, err
+	}
+	var all []migrationTable
+	var virtual []string
+	for rows.Next() {
+		var table migrationTable
+		if err := rows.Scan(&table.name, &table.createSQL); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		if strings.HasPrefix(strings.ToUpper(table.createSQL), "CREATE VIRTUAL TABLE") {
+			virtual = append(virtual, table.name+"_")
+			continue
+		}
+		all = append(all, table)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	byName := map[string]*migrationTable{}
+	var names []string
+	for i := range all {
+		table := &all[i]
+		if table.name == migrationProgressTable || slices.ContainsFunc(virtual, func(prefix string) bool {
+			return strings.HasPrefix(table.name, prefix)
+		}) {
+			continue
+		}
+		if table.columns, err = m.columns(ctx, table.name); err != nil {
+			return nil, err
+		}
+		byName[table.name] = table
+		names = append(names, table.name)
+	}
+
+	// Order by foreign keys so destinations enforcing them accept each batch
+	references := map[string][]string{}
+	for _, name := range names {
+		parents, err := querySingleColumn(ctx, m.src, `SELECT DISTINCT "table" FROM pragma_foreign_key_list(?)`, name)
+		if err != nil {
+			return nil, err
+		}
+		references[name] = parents
+	}
+	ordered := make([]migrationTable, 0, len(names))
+	placed := map[string]bool{}
+	for len(ordered) < len(names) {
+		progress := false
+		for _, name := range names {
+			if placed[name] {
+				continue
+			}
+			ready := true
+			for _, parent := range references[name] {
+				if parent != name && byName[parent] != nil && !placed[parent] {
+					ready = false
+					break
+				}
+			}
+			if ready {
+				ordered = append(ordered, *byName[name])
+				placed[name] = true
+				progress = true
+			}
+		}
+		if !progress {
+			// A reference cycle; copy the rest in name order
+			for _, name := range names {
+				if !placed[name] {
+					ordered = append(ordered, *byName[name])
+					placed[name] = true
+				}
+			}
+		}
+	}
+	return ordered, nil
+}
+
+// columns returns the columns of a source table
+func (m *DataMigration) columns(ctx context.Context, table string) ([]migrationColumn, error) {
+	rows, err := m.src.QueryContext(ctx, `SELECT name, type FROM pragma_table_info(?) ORDER BY cid`, table)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var columns []migrationColumn
+	for rows.Next() {
+		var name, declared string
+		if err := rows.Scan(&name, &declared); err != nil {
+			return nil, err
+		}
+		switch strings.ToUpper(declared) {
+		case "DATETIME", "TIMESTAMP", "DATE":
+			columns = append(columns, migrationColumn{name: name, datetime: true})
+		default:
+			columns = append(columns, migrationColumn{name: name})
+		}
+	}
+	return columns, rows.Err()
+}
+
+// copyTable copies the rows of a table after the last batch committed
+func (m *DataMigration) copyTable(ctx context.Context, table migrationTable) error {
+	var lastRowID, copied int64
+	var done bool
+	err := m.dst.QueryRowContext(ctx,
+		`SELECT last_rowid, copied, done FROM `+migrationProgressTable+` WHERE table_name = `+m.target.placeholder(1),
+		table.name).Scan(&lastRowID, &copied, &done)
+	switch {
+	case err == sql.ErrNoRows:
+	case err != nil:
+		return err
+	case done:
+		m.logf("%s: already copied (%d rows)", table.name, copied)
+		return nil
+	default:
+		m.logf("%s: resuming after %d rows", table.name, copied)
+	}
+
+	// Dates are read as stored; the SQLite driver would otherwise reformat them
+	selected := make([]string, len(table.columns))
+	names := make([]string, len(table.columns))
+	placeholders := make([]string, len(table.columns))
+	for i, column := range table.columns {
+		names[i] = quoteIdentifier(column.name)
+		selected[i] = names[i]
+		if column.datetime {
+			selected[i] = "CAST(" + names[i] + " AS TEXT)"
+		}
+		placeholders[i] = m.target.placeholder(i + 1)
+	}
+	selectQuery := `SELECT rowid, ` + strings.Join(selected, ", ") + ` FROM ` + quoteIdentifier(table.name) + ` WHERE rowid > ? ORDER BY rowid LIMIT ?`
+	insertQuery := `INSERT INTO ` + quoteIdentifier(table.name) + ` (` + strings.Join(names, ", ") + `) VALUES (` + strings.Join(placeholders, ", ") + `)`
+	progressQuery := fmt.Sprintf(`
+		INSERT INTO %s (table_name, last_rowid, copied, done) VALUES (%s, %s, %s, %s)
+		ON CONFLICT (table_name) DO UPDATE SET last_rowid = excluded.last_rowid, copied = excluded.copied, done = excluded.done`,
+		migrationProgressTable, m.target.placeholder(1), m.target.placeholder(2), m.target.placeholder(3), m.target.placeholder(4))
+
+	for {
+		batch, err := m.readBatch(ctx, selectQuery, table.columns, lastRowID)
+		if err != nil {
+			return err
+		}
+		finished := len(batch) < m.batch
+
+		tx, err := m.dst.BeginTx(ctx, nil)
+		if err != nil {
+			return err
+		}
+		if err := insertBatch(ctx, tx, insertQuery, batch); err != nil {
+			tx.Rollback()
+			return err
+		}
+		if len(batch) > 0 {
+			lastRowID = batch[len(batch)-1].rowid
+			copied += int64(len(batch))
+		}
+		if _, err := tx.ExecContext(ctx, progressQuery, table.name, lastRowID, copied, finished); err != nil {
+			tx.Rollback()
+			return err
+		}
+		if err := tx.Commit(); err != nil {
+			return err
+		}
+
+		if finished {
+			m.logf("%s: copied %d rows", table.name, copied)
+			return nil
+		}
+	}
+}
+
+// migrationRow is a source row with the rowid it was read at
+type migrationRow struct {
+	rowid  int64
+	values []interface{}
+}
+
+// readBatch reads the next batch of rows after lastRowID
+func (m *DataMigration) readBatch(ctx context.Context, query string, columns []migrationColumn, lastRowID int64) ([]migrationRow, error) {
+	rows, err := m.src.QueryContext(ctx, query, lastRowID, m.batch)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var batch []migrationRow
+	for rows.Next() {
+		row := migrationRow{values: make([]interface{}, len(columns))}
+		dest := []interface{}{&row.rowid}
+		for i := range row.values {
+			dest = append(dest, &row.values[i])
+		}
+		if err := rows.Scan(dest...); err != nil {
+			return nil, err
+		}
+		for i, column := range columns {
+			row.values[i] = m.target.value(column, row.values[i])
+		}
+		batch = append(batch, row)
+	}
+	return batch, rows.Err()
+}
+
+// insertBatch inserts rows into the destination within tx
+func insertBatch(ctx context.Context, tx *sql.Tx, query string, batch []migrationRow) error {
+	if len(batch) == 0 {
+		return nil
+	}
+	stmt, err := tx.PrepareContext(ctx, query)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+	for _, row := range batch {
+		if _, err := stmt.ExecContext(ctx, row.values...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// hashTable counts a table's rows and hashes their contents. Each row is
+// hashed on its own and the digests summed, so the result does not depend
+// on the order rows are returned in.
+func hashTable(ctx context.Context, db *sql.DB, table migrationTable) (int64, string, error) {
+	names := make([]string, len(table.columns))
+	for i, column := range table.columns {
+		names[i] = quoteIdentifier(column.name)
+	}
+	rows, err := db.QueryContext(ctx, `SELECT `+strings.Join(names, ", ")+` FROM `+quoteIdentifier(table.name))
+	if err != nil {
+		return 0, "", err
+	}
+	defer rows.Close()
+
+	var count int64
+	var sum [4]uint64
+	values := make([]interface{}, len(table.columns))
+	dest := make([]interface{}, len(values))
+	for i := range values {
+		dest[i] = &values[i]
+	}
+	for rows.Next() {
+		if err := rows.Scan(dest...); err != nil {
+			return 0, "", err
+		}
+		h := sha256.New()
+		for i, value := range values {
+			canonical := canonicalValue(value, table.columns[i].datetime)
+			fmt.Fprintf(h, "%d:%s", len(canonical), canonical)
+		}
+		digest := h.Sum(nil)
+		for lane := range sum {
+			sum[lane] += binary.BigEndian.Uint64(digest[lane*8:])
+		}
+		count++
+	}
+	if err := rows.Err(); err != nil {
+		return 0, "", err
+	}
+
+	var total [32]byte
+	for lane, value := range sum {
+		binary.BigEndian.PutUint64(total[lane*8:], value)
+	}
+	return count, hex.EncodeToString(total[:]), nil
+}
+
+// datetimeLayouts are the formats dates may be stored in as text
+var datetimeLayouts = []string{
+	"2006-01-02 15:04:05.999999999Z07:00",
+	"2006-01-02T15:04:05.999999999Z07:00",
+	"2006-01-02 15:04:05.999999999",
+	"2006-01-02T15:04:05.999999999",
+	"2006-01-02 15:04:05",
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04",
+	"2006-01-02T15:04",
+	"2006-01-02",
+}
+
+// canonicalValue formats a column value the same way whichever driver
+// returned it, so hashes can be compared across databases
+func canonicalValue(value interface{}, datetime bool) string {
+	switch v := value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		if v {
+			return "i1"
+		}
+		return "i0"
+	case int64:
+		return "i" + strconv.FormatInt(v, 10)
+	case float64:
+		if v == float64(int64(v)) {
+			return "i" + strconv.FormatInt(int64(v), 10)
+		}
+		return "f" + strconv.FormatFloat(v, 'g', -1, 64)
+	case time.Time:
+		return canonicalTime(v)
+	case []byte:
+		return canonicalValue(string(v), datetime)
+	case string:
+		if datetime {
+			if t, ok := parseDatetime(v); ok {
+				return canonicalTime(t)
+			}
+		}
+		return "s" + v
+	default:
+		return fmt.Sprintf("s%v", v)
+	}
+}
+
+// canonicalTime formats a time to the microsecond, the precision Postgres
+// keeps
+func canonicalTime(t time.Time) string {
+	return "t" + t.UTC().Round(time.Microsecond).Format(time.RFC3339Nano)
+}
+
+// parseDatetime parses a date stored as text in any of datetimeLayouts
+func parseDatetime(s string) (time.Time, bool) {
+	for _, layout := range datetimeLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// quoteIdentifier quotes a table or column name for SQLite and Postgres
+func quoteIdentifier(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
+
+// querySingleColumn returns the first column of every row of a query
+func querySingleColumn(ctx context.Context, db *sql.DB, query string, args ...interface{}) ([]string, error) {
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var values []string
+	for rows.Next() {
+		var value string
+		if err := rows.Scan(&value); err != nil {
+			return nil, err
+		}
+		values = append(values, value)
+	}
+	return values, rows.Err()
+}
+
+// sqliteTarget copies into a SQLite database, recreating the source's schema.
+// Indexes, triggers and virtual tables are created once the data is in, so
+// triggers do not fire for copied rows.
+type sqliteTarget struct{}
+
+func (sqliteTarget) placeholder(int) string { return "?" }
+
+func (sqliteTarget) value(_ migrationColumn, v interface{}) interface{} { return v }
+
+func (sqliteTarget) prepare(ctx context.Context, m *DataMigration, tables []migrationTable) error {
+	for _, table := range tables {
+		if err := createMissing(ctx, m.dst, "table", table.name, table.createSQL); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (sqliteTarget) finish(ctx context.Context, m *DataMigration, tables []migrationTable) error {
+	rows, err := m.src.QueryContext(ctx, `
+		SELECT type, name, sql FROM sqlite_master
+		WHERE sql IS NOT NULL AND (type IN ('index', 'trigger', 'view')
+			OR (type = 'table' AND sql LIKE 'CREATE VIRTUAL TABLE%'))
+		ORDER BY CASE type WHEN 'table' THEN 0 WHEN 'index' THEN 1 WHEN 'view' THEN 2 ELSE 3 END, name`)
+	if err != nil {
+		return err
+	}
+	type schemaObject struct{ kind, name, sql string }
+	var objects []schemaObject
+	for rows.Next() {
+		var object schemaObject
+		if err := rows.Scan(&object.kind, &object.name, &object.sql); err != nil {
+			rows.Close()
+			return err
+		}
+		objects = append(objects, object)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, object := range objects {
+		if err := createMissing(ctx, m.dst, object.kind, object.name, object.sql); err != nil {
+			return err
+		}
+		// Full-text indexes are rebuilt from the table they index
+		if object.kind == "table" && strings.Contains(strings.ToLower(object.sql), "using fts") {
+			if _, err := m.dst.ExecContext(ctx, `INSERT INTO `+quoteIdentifier(object.name)+`(`+quoteIdentifier(object.name)+`) VALUES ('rebuild')`); err != nil {
+				return fmt.Errorf("failed to rebuild %s: %w", object.name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// createMissing runs createSQL unless the destination has an object named name
+func createMissing(ctx context.Context, db *sql.DB, kind, name, createSQL string) error {
+	var exists int
+	if err := db.QueryRowContext(ctx, `SELECT COUNT(*) FROM sqlite_master WHERE type = ? AND name = ?`, kind, name).Scan(&exists); err != nil {
+		return err
+	}
+	if exists > 0 {
+		return nil
+	}
+	if _, err := db.ExecContext(ctx, createSQL); err != nil {
+		return fmt.Errorf("failed to create %s %s: %w", kind, name, err)
+	}
+	return nil
+}
+
+// postgresTarget copies into a Postgres database, creating the tables of
+// postgresSchemaSQL that are missing. Afterwards, indexes are created and
+// sequences behind id columns are moved past the copied IDs.
+type postgresTarget struct{}
+
+func (postgresTarget) placeholder(n int) string { return "$" + strconv.Itoa(n) }
+
+// value turns dates stored as text into times, rounded to the microsecond
+// as they are compared, so dates without a zone are not read in the
+// session's time zone
+func (postgresTarget) value(column migrationColumn, v interface{}) interface{} {
+	if s, ok := v.(string); ok && column.datetime {
+		if t, ok := parseDatetime(s); ok {
+			return t.UTC().Round(time.Microsecond)
+		}
+	}
+	return v
+}
+
+func (postgresTarget) prepare(ctx context.Context, m *DataMigration, tables []migrationTable) error {
+	if _, err := m.dst.ExecContext(ctx, postgresSchemaSQL); err != nil {
+		return fmt.Errorf("failed to create schema: %w", err)
+	}
+	for _, table := range tables {
+		var exists bool
+		if err := m.dst.QueryRowContext(ctx, `SELECT to_regclass($1) IS NOT NULL`, quoteIdentifier(table.name)).Scan(&exists); err != nil {
+			return err
+		}
+		if !exists {
+			return fmt.Errorf("table %s does not exist in the destination schema", table.name)
+		}
+	}
+	return nil
+}
+
+func (postgresTarget) finish(ctx context.Context, m *DataMigration, tables []migrationTable) error {
+	if _, err := m.dst.ExecContext(ctx, postgresIndexesSQL); err != nil {
+		return fmt.Errorf("failed to create indexes: %w", err)
+	}
+	for _, table := range tables {
+		if !slices.ContainsFunc(table.columns, func(c migrationColumn) bool { return c.name == "id" }) {
+			continue
+		}
+		// setval ignores tables whose id has no sequence
+		query := `SELECT setval(pg_get_serial_sequence($1, 'id'), (SELECT COALESCE(MAX(id), 0) + 1 FROM ` + quoteIdentifier(table.name) + `), false)`
+		if _, err := m.dst.ExecContext(ctx, query, quoteIdentifier(table.name)); err != nil {
+			return fmt.Errorf("failed to reset the id sequence of %s: %w", table.name, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,217 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDataMigration(t *testing.T) {
+	dir := t.TempDir()
+	source, err := NewSQLiteDB(filepath.Join(dir, "source.db"))
+	if err != nil {
+		t.Fatalf("Failed to open source: %v", err)
+	}
+	defer source.Close()
+
+	if _, err := source.DB.Exec(`INSERT INTO users (username, password_hash) VALUES ('alice', 'x')`); err != nil {
+		t.Fatalf("Failed to insert user: %v", err)
+	}
+	expires := time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC)
+	for i := 0; i < 7; i++ {
+		if _, err := source.DB.Exec(`INSERT INTO pastes (id, content, user_id, expires_at) VALUES (?, ?, 1, ?)`,
+			fmt.Sprintf("p%d", i), fmt.Sprintf("needle %d", i), expires); err != nil {
+			t.Fatalf("Failed to insert paste: %v", err)
+		}
+	}
+
+	dst, err := sql.Open(DriverSQLite, filepath.Join(dir, "dest.db"))
+	if err != nil {
+		t.Fatalf("Failed to open destination: %v", err)
+	}
+	defer dst.Close()
+	dst.SetMaxOpenConns(1)
+
+	// Interrupt the first run once a table has been copied
+	ctx, cancel := context.WithCancel(context.Background())
+	interrupted, err := NewDataMigration(source.DB, DriverSQLite, dst, 3, func(format string, args ...interface{}) {
+		if strings.Contains(format, "copied") {
+			cancel()
+		}
+	})
+	if err != nil {
+		t.Fatalf("Failed to create migration: %v", err)
+	}
+	if err := interrupted.Copy(ctx); err == nil {
+		t.Fatal("Expected the interrupted copy to fail")
+	}
+
+	var resumed bool
+	migration, _ := NewDataMigration(source.DB, DriverSQLite, dst, 3, func(format string, args ...interface{}) {
+		if strings.Contains(format, "already copied") {
+			resumed = true
+		}
+	})
+	if err := migration.Copy(context.Background()); err != nil {
+		t.Fatalf("Resumed copy failed: %v", err)
+	}
+	if !resumed {
+		t.Error("Expected the resumed copy to skip tables already copied")
+	}
+
+	checks, err := migration.Verify(context.Background())
+	if err != nil {
+		t.Fatalf("Verify failed: %v", err)
+	}
+	for _, check := range checks {
+		if !check.OK() {
+			t.Errorf("Table %s differs: %+v", check.Table, check)
+		}
+	}
+	if err := migration.Finish(context.Background()); err != nil {
+		t.Fatalf("Finish failed: %v", err)
+	}
+
+	// Events came across once, not again from triggers, and the full-text
+	// index was rebuilt
+	var events, matches int
+	dst.QueryRow(`SELECT COUNT(*) FROM paste_events`).Scan(&events)
+	dst.QueryRow(`SELECT COUNT(*) FROM pastes_fts WHERE pastes_fts MATCH 'needle'`).Scan(&matches)
+	if events != 7 || matches != 7 {
+		t.Errorf("Expected 7 events and 7 search matches, got %d and %d", events, matches)
+	}
+	var stored string
+	dst.QueryRow(`SELECT CAST(expires_at AS TEXT) FROM pastes WHERE id = 'p0'`).Scan(&stored)
+	var original string
+	source.DB.QueryRow(`SELECT CAST(expires_at AS TEXT) FROM pastes WHERE id = 'p0'`).Scan(&original)
+	if stored != original {
+		t.Errorf("Expected dates copied as stored, got %q for %q", stored, original)
+	}
+
+	// Triggers work again for new rows
+	if _, err := dst.Exec(`INSERT INTO pastes (id, content) VALUES ('new', 'after')`); err != nil {
+		t.Fatalf("Failed to insert into destination: %v", err)
+	}
+	dst.QueryRow(`SELECT COUNT(*) FROM paste_events`).Scan(&events)
+	if events != 8 {
+		t.Errorf("Expected triggers to log the new paste, got %d events", events)
+	}
+
+	checks, _ = migration.Verify(context.Background())
+	for _, check := range checks {
+		if check.Table == "pastes" && check.OK() {
+			t.Error("Expected verification to catch the extra paste")
+		}
+	}
+}
+
+func TestPostgresSchemaCoversSQLite(t *testing.T) {
+	source, err := NewSQLiteDB(filepath.Join(t.TempDir(), "source.db"))
+	if err != nil {
+		t.Fatalf("Failed to open source: %v", err)
+	}
+	defer source.Close()
+
+	migration, _ := NewDataMigration(source.DB, DriverSQLite, nil, 0, nil)
+	tables, err := migration.tables(context.Background())
+	if err != nil {
+		t.Fatalf("Failed to list tables: %v", err)
+	}
+	for _, table := range tables {
+		create := regexp.MustCompile(`(?s)CREATE TABLE IF NOT EXISTS ` + table.name + ` \((.*?)\n\);`).FindStringSubmatch(postgresSchemaSQL)
+		if create == nil {
+			t.Errorf("Expected table %s in the Postgres schema", table.name)
+			continue
+		}
+		for _, column := range table.columns {
+			if !regexp.MustCompile(`(?m)^    ` + column.name + ` `).MatchString(create[1]) {
+				t.Errorf("Expected column %s.%s in the Postgres schema", table.name, column.name)
+			}
+		}
+	}
+}
+
+// TestDataMigration_Postgres copies into the Postgres database named by
+// TEST_POSTGRES_DSN, such as postgres://postgres@localhost/pastevault_test.
+// Everything in its public schema is dropped first.
+func TestDataMigration_Postgres(t *testing.T) {
+	dsn := os.Getenv("TEST_POSTGRES_DSN")
+	if dsn == "" {
+		t.Skip("TEST_POSTGRES_DSN is not set")
+	}
+
+	source, err := NewSQLiteDB(filepath.Join(t.TempDir(), "source.db"))
+	if err != nil {
+		t.Fatalf("Failed to open source: %v", err)
+	}
+	defer source.Close()
+
+	dst, err := sql.Open(DriverPostgres, dsn)
+	if err != nil {
+		t.Fatalf("Failed to open destination: %v", err)
+	}
+	defer dst.Close()
+	if _, err := dst.Exec(`DROP SCHEMA public CASCADE; CREATE SCHEMA public`); err != nil {
+		t.Fatalf("Failed to reset destination: %v", err)
+	}
+
+	// Dates with and without a zone and finer than Postgres keeps them,
+	// booleans, blobs and self references
+	expires := time.Date(2030, 1, 2, 3, 4, 5, 123456789, time.FixedZone("CET", 3600))
+	for _, insert := range []struct {
+		query string
+		args  []interface{}
+	}{
+		{`INSERT INTO users (username, password_hash, created_at) VALUES ('alice', 'x', '2026-01-02 03:04:05')`, nil},
+		{`INSERT INTO pastes (id, content, user_id, expires_at, do_not_track) VALUES ('p0', 'needle', 1, ?, 1)`, []interface{}{expires}},
+		{`INSERT INTO pastes (id, content, parent_id, line_numbers) VALUES ('p1', 'derived', 'p0', 0)`, nil},
+		{`INSERT INTO paste_cold (paste_id, data) VALUES ('p1', X'00ff')`, nil},
+		{`INSERT INTO trending_pastes (paste_id, score, views, computed_at) VALUES ('p0', 1.5, 3, CURRENT_TIMESTAMP)`, nil},
+	} {
+		if _, err := source.DB.Exec(insert.query, insert.args...); err != nil {
+			t.Fatalf("Failed to fill source: %v", err)
+		}
+	}
+
+	migration, err := NewDataMigration(source.DB, DriverPostgres, dst, 1, nil)
+	if err != nil {
+		t.Fatalf("Failed to create migration: %v", err)
+	}
+	for run := 0; run < 2; run++ {
+		if err := migration.Copy(context.Background()); err != nil {
+			t.Fatalf("Copy %d failed: %v", run+1, err)
+		}
+	}
+	checks, err := migration.Verify(context.Background())
+	if err != nil {
+		t.Fatalf("Verify failed: %v", err)
+	}
+	for _, check := range checks {
+		if !check.OK() {
+			t.Errorf("Table %s differs: %+v", check.Table, check)
+		}
+	}
+	if err := migration.Finish(context.Background()); err != nil {
+		t.Fatalf("Finish failed: %v", err)
+	}
+
+	var stored time.Time
+	dst.QueryRow(`SELECT expires_at FROM pastes WHERE id = 'p0'`).Scan(&stored)
+	if !stored.Equal(expires.Round(time.Microsecond)) {
+		t.Errorf("Expected expiry %v, got %v", expires, stored)
+	}
+	// New rows get IDs after the copied ones
+	var id int64
+	if err := dst.QueryRow(`INSERT INTO users (username, password_hash) VALUES ('bob', 'x') RETURNING id`).Scan(&id); err != nil || id != 2 {
+		t.Errorf("Expected the next user ID to be 2, got %d (%v)", id, err)
+	}
+	if _, err := dst.Exec(`INSERT INTO users (username, password_hash) VALUES ('ALICE', 'x')`); err == nil {
+		t.Error("Expected usernames to stay unique regardless of case")
+	}
+}
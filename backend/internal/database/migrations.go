@@ -26,6 +26,276 @@ func (d *Database) runMigrations() error {
 			Description: "Create pastes table",
 			SQL:         createPastesTableSQL,
 		},
+		{
+			ID:          3,
+			Description: "Add do_not_track flag to pastes",
+			SQL:         addPasteDoNotTrackSQL,
+		},
+		{
+			ID:          4,
+			Description: "Add render preferences to pastes",
+			SQL:         addPasteRenderPreferencesSQL,
+		},
+		{
+			ID:          5,
+			Description: "Add kind to pastes",
+			SQL:         addPasteKindSQL,
+		},
+		{
+			ID:          6,
+			Description: "Add parent paste link to pastes",
+			SQL:         addPasteParentSQL,
+		},
+		{
+			ID:          7,
+			Description: "Add role to users",
+			SQL:         addUserRoleSQL,
+		},
+		{
+			ID:          8,
+			Description: "Add creator IP to pastes",
+			SQL:         addPasteCreatorIPSQL,
+		},
+		{
+			ID:          9,
+			Description: "Create full-text search index for pastes",
+			SQL:         createPastesFTSSQL,
+		},
+		{
+			ID:          10,
+			Description: "Replace raw creator IPs with salted hashes",
+			SQL:         hashPasteCreatorIPSQL,
+		},
+		{
+			ID:          11,
+			Description: "Add visibility to pastes",
+			SQL:         addPasteVisibilitySQL,
+		},
+		{
+			ID:          12,
+			Description: "Add signed URL requirement to pastes",
+			SQL:         addPasteSignedURLsSQL,
+		},
+		{
+			ID:          13,
+			Description: "Create jobs table",
+			SQL:         createJobsTableSQL,
+		},
+		{
+			ID:          14,
+			Description: "Track storage usage per user",
+			SQL:         createStorageUsageSQL,
+		},
+		{
+			ID:          15,
+			Description: "Record paste lifecycle events",
+			SQL:         createPasteEventsSQL,
+		},
+		{
+			ID:          16,
+			Description: "Create API keys table",
+			SQL:         createAPIKeysSQL,
+		},
+		{
+			ID:          17,
+			Description: "Create chat integrations table",
+			SQL:         createIntegrationsSQL,
+		},
+		{
+			ID:          18,
+			Description: "Add verified email addresses to users",
+			SQL:         addUserVerifiedEmailSQL,
+		},
+		{
+			ID:          19,
+			Description: "Create SSH keys table",
+			SQL:         createSSHKeysSQL,
+		},
+		{
+			ID:          20,
+			Description: "Add content digests to pastes",
+			SQL:         addPasteContentSHA256SQL,
+		},
+		{
+			ID:          21,
+			Description: "Add detached content signatures to pastes",
+			SQL:         addPasteSignatureSQL,
+		},
+		{
+			ID:          22,
+			Description: "Create developer keys and usage tables",
+			SQL:         createDeveloperKeysSQL,
+		},
+		{
+			ID:          23,
+			Description: "Add webhook signing secrets to integrations",
+			SQL:         addIntegrationSigningSecretSQL,
+		},
+		{
+			ID:          24,
+			Description: "Create webhook delivery log table",
+			SQL:         createWebhookDeliveriesSQL,
+		},
+		{
+			ID:          25,
+			Description: "Create paste view counts and notification preferences tables",
+			SQL:         createNotificationPreferencesSQL,
+		},
+		{
+			ID:          26,
+			Description: "Create user settings table",
+			SQL:         createUserSettingsSQL,
+		},
+		{
+			ID:          27,
+			Description: "Create service accounts table",
+			SQL:         createServiceAccountsSQL,
+		},
+		{
+			ID:          28,
+			Description: "Create external identities table",
+			SQL:         createUserIdentitiesSQL,
+		},
+		{
+			ID:          29,
+			Description: "Add user deactivation and SCIM provisioned users table",
+			SQL:         createSCIMUsersSQL,
+		},
+		{
+			ID:          30,
+			Description: "Create per-role content policies table",
+			SQL:         createContentPoliciesSQL,
+		},
+		{
+			ID:          31,
+			Description: "Make usernames unique regardless of case",
+			SQL:         addUsernameNocaseIndexSQL,
+		},
+		{
+			ID:          32,
+			Description: "Create username holds table",
+			SQL:         createUsernameHoldsSQL,
+		},
+		{
+			ID:          33,
+			Description: "Create user avatars tables",
+			SQL:         createAvatarsSQL,
+		},
+		{
+			ID:          34,
+			Description: "Create follows table",
+			SQL:         createFollowsSQL,
+		},
+		{
+			ID:          35,
+			Description: "Create trending pastes table",
+			SQL:         createTrendingPastesSQL,
+		},
+		{
+			ID:          36,
+			Description: "Add noindex flag to pastes",
+			SQL:         addPasteNoIndexSQL,
+		},
+		{
+			ID:          37,
+			Description: "Add password hint to pastes",
+			SQL:         addPastePasswordHintSQL,
+		},
+		{
+			ID:          38,
+			Description: "Add unlock attempt limit to pastes",
+			SQL:         addPasteUnlockAttemptsSQL,
+		},
+		{
+			ID:          39,
+			Description: "Add access windows to pastes",
+			SQL:         addPasteAccessWindowSQL,
+		},
+		{
+			ID:          40,
+			Description: "Add network and country restrictions to pastes",
+			SQL:         addPasteLocationRestrictionsSQL,
+		},
+		{
+			ID:          41,
+			Description: "Add embed domains to pastes",
+			SQL:         addPasteEmbedDomainsSQL,
+		},
+		{
+			ID:          42,
+			Description: "Add watermark flag to pastes",
+			SQL:         addPasteWatermarkSQL,
+		},
+		{
+			ID:          43,
+			Description: "Create paste attachment tables",
+			SQL:         createPasteAttachmentsSQL,
+		},
+		{
+			ID:          44,
+			Description: "Create paste view log for polling triggers",
+			SQL:         createPasteViewLogSQL,
+		},
+		{
+			ID:          45,
+			Description: "Add scopes to API keys",
+			SQL:         addAPIKeyScopesSQL,
+		},
+		{
+			ID:          46,
+			Description: "Create paste metadata table",
+			SQL:         createPasteMetadataSQL,
+		},
+		{
+			ID:          47,
+			Description: "Count raw views of pastes separately",
+			SQL:         addRawPasteViewsSQL,
+		},
+		{
+			ID:          48,
+			Description: "Add simhash fingerprints of paste content",
+			SQL:         addPasteContentSimhashSQL,
+		},
+		{
+			ID:          49,
+			Description: "Create admin audit log",
+			SQL:         createAuditLogSQL,
+		},
+		{
+			ID:          50,
+			Description: "Create paste tags tables",
+			SQL:         createPasteTagsSQL,
+		},
+		{
+			ID:          51,
+			Description: "Create cold storage tier for pastes",
+			SQL:         createPasteColdStorageSQL,
+		},
+		{
+			ID:          52,
+			Description: "Drop response excerpts from webhook deliveries",
+			SQL:         dropWebhookResponseExcerptSQL,
+		},
+		{
+			ID:          53,
+			Description: "Keep cold pastes in the full-text index",
+			SQL:         keepColdPastesIndexedSQL,
+		},
+		{
+			ID:          54,
+			Description: "Record attachment contents removed by triggers",
+			SQL:         createBlobDeletionsSQL,
+		},
+		{
+			ID:          55,
+			Description: "Only reindex pastes when their content or language changes",
+			SQL:         narrowPastesFTSUpdateTriggersSQL,
+		},
+		{
+			ID:          56,
+			Description: "Create replica heartbeat table",
+			SQL:         createReplicaHeartbeatSQL,
+		},
 	}
 
 	// Execute migrations
@@ -110,3 +380,689 @@ CREATE TABLE IF NOT EXISTS pastes (
     user_id INTEGER,
     FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE SET NULL
 );`
+
+// SQL for adding the do-not-track flag to pastes
+const addPasteDoNotTrackSQL = `
+ALTER TABLE pastes ADD COLUMN do_not_track BOOLEAN NOT NULL DEFAULT 0;`
+
+// SQL for adding creator-chosen render preferences to pastes
+const addPasteRenderPreferencesSQL = `
+ALTER TABLE pastes ADD COLUMN theme TEXT NOT NULL DEFAULT '';
+ALTER TABLE pastes ADD COLUMN line_numbers BOOLEAN NOT NULL DEFAULT 1;
+ALTER TABLE pastes ADD COLUMN word_wrap BOOLEAN NOT NULL DEFAULT 0;`
+
+// SQL for adding the content kind to pastes
+const addPasteKindSQL = `
+ALTER TABLE pastes ADD COLUMN kind TEXT NOT NULL DEFAULT 'text';`
+
+// SQL for linking derived pastes (transforms, execution output) to their source
+const addPasteParentSQL = `
+ALTER TABLE pastes ADD COLUMN parent_id TEXT REFERENCES pastes (id) ON DELETE SET NULL;`
+
+// SQL for adding roles to users
+const addUserRoleSQL = `
+ALTER TABLE users ADD COLUMN role TEXT NOT NULL DEFAULT 'user';`
+
+// SQL for recording the creator IP of pastes for abuse investigations
+const addPasteCreatorIPSQL = `
+ALTER TABLE pastes ADD COLUMN creator_ip TEXT;
+CREATE INDEX IF NOT EXISTS idx_pastes_creator_ip ON pastes (creator_ip);`
+
+// SQL for the FTS4 index over paste content, kept in sync by triggers.
+// FTS4 is used because the default go-sqlite3 build does not include FTS5.
+const createPastesFTSSQL = `
+CREATE VIRTUAL TABLE IF NOT EXISTS pastes_fts USING fts4(content="pastes", content, language);
+
+CREATE TRIGGER IF NOT EXISTS pastes_fts_bu BEFORE UPDATE ON pastes BEGIN
+    DELETE FROM pastes_fts WHERE docid = old.rowid;
+END;
+CREATE TRIGGER IF NOT EXISTS pastes_fts_bd BEFORE DELETE ON pastes BEGIN
+    DELETE FROM pastes_fts WHERE docid = old.rowid;
+END;
+CREATE TRIGGER IF NOT EXISTS pastes_fts_au AFTER UPDATE ON pastes BEGIN
+    INSERT INTO pastes_fts (docid, content, language) VALUES (new.rowid, new.content, new.language);
+END;
+CREATE TRIGGER IF NOT EXISTS pastes_fts_ai AFTER INSERT ON pastes BEGIN
+    INSERT INTO pastes_fts (docid, content, language) VALUES (new.rowid, new.content, new.language);
+END;
+
+INSERT INTO pastes_fts (pastes_fts) VALUES ('rebuild');`
+
+// SQL for replacing raw creator IPs with salted hashes. Existing raw IPs are
+// discarded since the salt cannot be applied retroactively in SQL.
+const hashPasteCreatorIPSQL = `
+DROP INDEX IF EXISTS idx_pastes_creator_ip;
+ALTER TABLE pastes DROP COLUMN creator_ip;
+ALTER TABLE pastes ADD COLUMN creator_ip_hash TEXT;
+CREATE INDEX IF NOT EXISTS idx_pastes_creator_ip_hash ON pastes (creator_ip_hash);`
+
+// SQL for adding visibility (public, unlisted or private) to pastes
+const addPasteVisibilitySQL = `
+ALTER TABLE pastes ADD COLUMN visibility TEXT NOT NULL DEFAULT 'public';`
+
+// SQL for requiring signed, expiring URLs for raw and download access
+const addPasteSignedURLsSQL = `
+ALTER TABLE pastes ADD COLUMN require_signed_urls BOOLEAN NOT NULL DEFAULT 0;`
+
+// SQL for the persistent background job queue
+const createJobsTableSQL = `
+CREATE TABLE IF NOT EXISTS jobs (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    type TEXT NOT NULL,
+    payload TEXT NOT NULL,
+    status TEXT NOT NULL DEFAULT 'pending',
+    attempts INTEGER NOT NULL DEFAULT 0,
+    max_attempts INTEGER NOT NULL,
+    run_at DATETIME NOT NULL,
+    last_error TEXT NOT NULL DEFAULT '',
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+CREATE INDEX IF NOT EXISTS idx_jobs_status_run_at ON jobs (status, run_at);`
+
+// SQL for storage usage counters kept up to date by triggers, so usage never
+// requires scanning pastes. Anonymous pastes are counted under user_id 0.
+const createStorageUsageSQL = `
+CREATE TABLE IF NOT EXISTS storage_usage (
+    user_id INTEGER PRIMARY KEY,
+    paste_count INTEGER NOT NULL DEFAULT 0,
+    bytes INTEGER NOT NULL DEFAULT 0
+);
+
+CREATE TRIGGER IF NOT EXISTS storage_usage_ai AFTER INSERT ON pastes BEGIN
+    INSERT INTO storage_usage (user_id, paste_count, bytes)
+    VALUES (COALESCE(new.user_id, 0), 1, length(CAST(new.content AS BLOB)))
+    ON CONFLICT (user_id) DO UPDATE SET
+        paste_count = paste_count + 1,
+        bytes = bytes + excluded.bytes;
+END;
+CREATE TRIGGER IF NOT EXISTS storage_usage_ad AFTER DELETE ON pastes BEGIN
+    UPDATE storage_usage SET
+        paste_count = paste_count - 1,
+        bytes = bytes - length(CAST(old.content AS BLOB))
+    WHERE user_id = COALESCE(old.user_id, 0);
+END;
+CREATE TRIGGER IF NOT EXISTS storage_usage_au AFTER UPDATE OF content, user_id ON pastes BEGIN
+    UPDATE storage_usage SET
+        paste_count = paste_count - 1,
+        bytes = bytes - length(CAST(old.content AS BLOB))
+    WHERE user_id = COALESCE(old.user_id, 0);
+    INSERT INTO storage_usage (user_id, paste_count, bytes)
+    VALUES (COALESCE(new.user_id, 0), 1, length(CAST(new.content AS BLOB)))
+    ON CONFLICT (user_id) DO UPDATE SET
+        paste_count = paste_count + 1,
+        bytes = bytes + excluded.bytes;
+END;
+
+INSERT INTO storage_usage (user_id, paste_count, bytes)
+SELECT COALESCE(user_id, 0), COUNT(*), COALESCE(SUM(length(CAST(content AS BLOB))), 0)
+FROM pastes
+GROUP BY COALESCE(user_id, 0);`
+
+// SQL for the append-only paste lifecycle event log and its daily rollup.
+// Deleting a paste past its expiry is recorded as an expire event; pastes
+// with do_not_track keep their ID out of the log.
+const createPasteEventsSQL = `
+CREATE TABLE IF NOT EXISTS paste_events (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    paste_id TEXT,
+    event TEXT NOT NULL,
+    language TEXT NOT NULL DEFAULT '',
+    bytes INTEGER NOT NULL DEFAULT 0,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+CREATE INDEX IF NOT EXISTS idx_paste_events_created_at ON paste_events (created_at);
+
+CREATE TABLE IF NOT EXISTS paste_stats_daily (
+    day TEXT NOT NULL,
+    event TEXT NOT NULL,
+    count INTEGER NOT NULL DEFAULT 0,
+    bytes INTEGER NOT NULL DEFAULT 0,
+    PRIMARY KEY (day, event)
+);
+
+CREATE TRIGGER IF NOT EXISTS paste_events_ai AFTER INSERT ON pastes BEGIN
+    INSERT INTO paste_events (paste_id, event, language, bytes)
+    VALUES (
+        CASE WHEN new.do_not_track THEN NULL ELSE new.id END,
+        'create',
+        COALESCE(new.language, ''),
+        length(CAST(new.content AS BLOB))
+    );
+END;
+CREATE TRIGGER IF NOT EXISTS paste_events_ad AFTER DELETE ON pastes BEGIN
+    INSERT INTO paste_events (paste_id, event, language, bytes)
+    VALUES (
+        CASE WHEN old.do_not_track THEN NULL ELSE old.id END,
+        CASE WHEN old.expires_at IS NOT NULL AND julianday(old.expires_at) <= julianday('now')
+            THEN 'expire' ELSE 'delete' END,
+        COALESCE(old.language, ''),
+        length(CAST(old.content AS BLOB))
+    );
+END;
+
+INSERT INTO paste_events (paste_id, event, language, bytes, created_at)
+SELECT CASE WHEN do_not_track THEN NULL ELSE id END, 'create', COALESCE(language, ''),
+    length(CAST(content AS BLOB)), created_at
+FROM pastes;`
+
+// SQL for creating the API keys table. Only a SHA-256 hash of each key is
+// stored; the prefix identifies a key in listings.
+const createAPIKeysSQL = `
+CREATE TABLE IF NOT EXISTS api_keys (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name TEXT NOT NULL,
+    prefix TEXT NOT NULL,
+    key_hash TEXT NOT NULL UNIQUE,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    last_used_at DATETIME
+);
+CREATE INDEX IF NOT EXISTS idx_api_keys_user_id ON api_keys (user_id);`
+
+// SQL for creating the chat integrations table; names are unique per user
+const createIntegrationsSQL = `
+CREATE TABLE IF NOT EXISTS integrations (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name TEXT NOT NULL,
+    platform TEXT NOT NULL,
+    webhook_url TEXT NOT NULL,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (user_id, name)
+);`
+
+// SQL for adding a verified email address to users. Addresses are stored
+// lowercased and belong to at most one account.
+const addUserVerifiedEmailSQL = `
+ALTER TABLE users ADD COLUMN verified_email TEXT;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_users_verified_email ON users (verified_email)
+    WHERE verified_email IS NOT NULL;`
+
+// SQL for creating the SSH public keys table used by the SSH paste server.
+// A key belongs to at most one account so it identifies its user.
+const createSSHKeysSQL = `
+CREATE TABLE IF NOT EXISTS ssh_keys (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name TEXT NOT NULL,
+    fingerprint TEXT NOT NULL UNIQUE,
+    public_key TEXT NOT NULL,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    last_used_at DATETIME
+);
+CREATE INDEX IF NOT EXISTS idx_ssh_keys_user_id ON ssh_keys (user_id);`
+
+// SQL for adding the SHA-256 digest pastes are content addressed by. Existing
+// rows are hashed by the server at startup since SQLite has no SHA-256.
+const addPasteContentSHA256SQL = `
+ALTER TABLE pastes ADD COLUMN content_sha256 TEXT;
+CREATE INDEX IF NOT EXISTS idx_pastes_content_sha256 ON pastes (content_sha256);`
+
+// SQL for adding the optional detached signature a creator attaches to a
+// paste's content
+const addPasteSignatureSQL = `
+ALTER TABLE pastes ADD COLUMN signature TEXT;
+ALTER TABLE pastes ADD COLUMN signature_format TEXT;`
+
+// SQL for creating the self-service developer keys of anonymous API users,
+// kept apart from account API keys, and their daily usage counts
+const createDeveloperKeysSQL = `
+CREATE TABLE IF NOT EXISTS developer_keys (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    name TEXT NOT NULL,
+    prefix TEXT NOT NULL,
+    key_hash TEXT NOT NULL UNIQUE,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    last_used_at DATETIME
+);
+
+CREATE TABLE IF NOT EXISTS developer_key_usage (
+    key_id INTEGER NOT NULL REFERENCES developer_keys(id) ON DELETE CASCADE,
+    day TEXT NOT NULL,
+    requests INTEGER NOT NULL DEFAULT 0,
+    limited INTEGER NOT NULL DEFAULT 0,
+    PRIMARY KEY (key_id, day)
+);`
+
+// SQL for adding the secrets webhook deliveries are signed with. The previous
+// secret keeps signing deliveries for a grace period after a rotation.
+// Existing integrations get a random secret.
+const addIntegrationSigningSecretSQL = `
+ALTER TABLE integrations ADD COLUMN signing_secret TEXT;
+ALTER TABLE integrations ADD COLUMN previous_signing_secret TEXT;
+ALTER TABLE integrations ADD COLUMN previous_secret_expires_at DATETIME;
+UPDATE integrations SET signing_secret = 'whsec_' || lower(hex(randomblob(32)))
+    WHERE signing_secret IS NULL;`
+
+// SQL for creating the log of webhook delivery attempts users debug their
+// integrations with. The body is kept so a delivery can be sent again.
+const createWebhookDeliveriesSQL = `
+CREATE TABLE IF NOT EXISTS webhook_deliveries (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    integration_id INTEGER NOT NULL REFERENCES integrations(id) ON DELETE CASCADE,
+    event TEXT NOT NULL,
+    body TEXT NOT NULL,
+    success BOOLEAN NOT NULL,
+    status_code INTEGER,
+    latency_ms INTEGER NOT NULL DEFAULT 0,
+    response_excerpt TEXT NOT NULL DEFAULT '',
+    error TEXT NOT NULL DEFAULT '',
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_integration ON webhook_deliveries (integration_id, id);`
+
+// SQL for creating daily paste view counts and users' notification
+// preferences. The weekly digest goes out by email or to one of the user's
+// integrations; last_digest_at keeps it weekly across restarts.
+const createNotificationPreferencesSQL = `
+CREATE TABLE IF NOT EXISTS paste_views (
+    paste_id TEXT NOT NULL REFERENCES pastes(id) ON DELETE CASCADE,
+    day TEXT NOT NULL,
+    views INTEGER NOT NULL DEFAULT 0,
+    PRIMARY KEY (paste_id, day)
+);
+
+CREATE TABLE IF NOT EXISTS notification_preferences (
+    user_id INTEGER PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    weekly_digest TEXT NOT NULL DEFAULT 'off',
+    digest_integration_id INTEGER REFERENCES integrations(id) ON DELETE SET NULL,
+    last_digest_at DATETIME,
+    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);`
+
+// SQL for creating users' settings: the defaults applied to new pastes when
+// a request leaves the option out. Empty strings mean no default.
+const createUserSettingsSQL = `
+CREATE TABLE IF NOT EXISTS user_settings (
+    user_id INTEGER PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    default_expiry TEXT NOT NULL DEFAULT '',
+    default_visibility TEXT NOT NULL DEFAULT '',
+    default_language TEXT NOT NULL DEFAULT '',
+    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);`
+
+// SQL for creating service accounts. Each is backed by a users row with the
+// service role, which owns its pastes, and authenticates with a client ID and
+// secret instead of a password. Only a hash of the secret is stored.
+const createServiceAccountsSQL = `
+CREATE TABLE IF NOT EXISTS service_accounts (
+    user_id INTEGER PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    description TEXT NOT NULL DEFAULT '',
+    scopes TEXT NOT NULL,
+    client_id TEXT UNIQUE NOT NULL,
+    secret_hash TEXT NOT NULL,
+    created_by INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    last_used_at DATETIME,
+    disabled_at DATETIME
+);`
+
+// SQL for creating the links between users and their accounts at single
+// sign-on providers, keyed by the provider's stable subject identifier
+const createUserIdentitiesSQL = `
+CREATE TABLE IF NOT EXISTS user_identities (
+    provider TEXT NOT NULL,
+    subject TEXT NOT NULL,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    last_login_at DATETIME,
+    PRIMARY KEY (provider, subject)
+);
+
+CREATE INDEX IF NOT EXISTS idx_user_identities_user_id ON user_identities(user_id);`
+
+// SQL for user deactivation and the users managed by an identity provider
+// over SCIM, with the provider's own username and identifier for each
+const createSCIMUsersSQL = `
+ALTER TABLE users ADD COLUMN deactivated_at DATETIME;
+
+CREATE TABLE IF NOT EXISTS scim_users (
+    user_id INTEGER PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    user_name TEXT NOT NULL,
+    external_id TEXT,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_scim_users_external_id ON scim_users(external_id) WHERE external_id IS NOT NULL;`
+
+// SQL for creating the content policies admins assign to roles. Roles
+// without a row get the default policy, which permits everything the
+// instance allows.
+const createContentPoliciesSQL = `
+CREATE TABLE IF NOT EXISTS content_policies (
+    role TEXT PRIMARY KEY,
+    max_size INTEGER NOT NULL DEFAULT 0,
+    allowed_expiries TEXT NOT NULL DEFAULT '',
+    allow_public BOOLEAN NOT NULL DEFAULT 1,
+    allow_attachments BOOLEAN NOT NULL DEFAULT 1,
+    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);`
+
+// SQL making usernames unique regardless of case. Existing accounts whose
+// names differ only in case from an older account's are renamed by
+// appending their ID, so the index can be built.
+const addUsernameNocaseIndexSQL = `
+UPDATE users SET username = username || '-' || id
+WHERE EXISTS (
+    SELECT 1 FROM users older
+    WHERE older.username = users.username COLLATE NOCASE AND older.id < users.id
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_users_username_nocase ON users(username COLLATE NOCASE);`
+
+// SQL for creating the table of usernames recently given up by a rename,
+// which only their previous owner may claim until the hold expires
+const createUsernameHoldsSQL = `
+CREATE TABLE IF NOT EXISTS username_holds (
+    username TEXT PRIMARY KEY COLLATE NOCASE,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    held_until DATETIME NOT NULL
+);`
+
+// SQL for creating the avatar tables: resized images keyed by their SHA-256
+// digest, and which image each user has
+const createAvatarsSQL = `
+CREATE TABLE IF NOT EXISTS avatar_blobs (
+    hash TEXT PRIMARY KEY,
+    data BLOB NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS user_avatars (
+    user_id INTEGER PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    hash TEXT NOT NULL,
+    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);`
+
+// SQL for creating the table of users following each other
+const createFollowsSQL = `
+CREATE TABLE IF NOT EXISTS follows (
+    follower_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    followee_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (follower_id, followee_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_follows_followee ON follows(followee_id);`
+
+// SQL for creating the trending pastes ranking, recomputed by a background
+// job from the daily view counts
+const createTrendingPastesSQL = `
+CREATE TABLE IF NOT EXISTS trending_pastes (
+    paste_id TEXT PRIMARY KEY REFERENCES pastes(id) ON DELETE CASCADE,
+    score REAL NOT NULL,
+    views INTEGER NOT NULL,
+    computed_at DATETIME NOT NULL
+);`
+
+// SQL for adding the flag that keeps a paste out of search engines and
+// public listings
+const addPasteNoIndexSQL = `
+ALTER TABLE pastes ADD COLUMN noindex BOOLEAN NOT NULL DEFAULT 0;`
+
+// SQL for adding the optional hint shown when a paste's password is requested
+const addPastePasswordHintSQL = `
+ALTER TABLE pastes ADD COLUMN password_hint TEXT;`
+
+// SQL for adding the failed unlock counter of pastes destroyed after too many
+// wrong passwords
+const addPasteUnlockAttemptsSQL = `
+ALTER TABLE pastes ADD COLUMN max_unlock_attempts INTEGER;
+ALTER TABLE pastes ADD COLUMN failed_unlocks INTEGER NOT NULL DEFAULT 0;`
+
+// SQL for adding the daily UTC time range, in minutes after midnight, during
+// which a paste may be viewed
+const addPasteAccessWindowSQL = `
+ALTER TABLE pastes ADD COLUMN access_window_start INTEGER;
+ALTER TABLE pastes ADD COLUMN access_window_end INTEGER;`
+
+// SQL for adding the comma-separated CIDR ranges and country codes a paste
+// may be viewed from
+const addPasteLocationRestrictionsSQL = `
+ALTER TABLE pastes ADD COLUMN allowed_networks TEXT;
+ALTER TABLE pastes ADD COLUMN allowed_countries TEXT;`
+
+// SQL for adding the comma-separated domains allowed to embed a paste
+const addPasteEmbedDomainsSQL = `
+ALTER TABLE pastes ADD COLUMN embed_domains TEXT;`
+
+// SQL for adding the flag that watermarks a paste's rendered views with the
+// viewer's identity
+const addPasteWatermarkSQL = `
+ALTER TABLE pastes ADD COLUMN watermark BOOLEAN NOT NULL DEFAULT 0;`
+
+// SQL for creating the paste attachment tables: file contents keyed by their
+// SHA-256 digest, and the named attachments of each paste. Triggers remove
+// contents no attachment refers to any more, including after the paste is
+// deleted.
+const createPasteAttachmentsSQL = `
+CREATE TABLE IF NOT EXISTS attachment_blobs (
+    hash TEXT PRIMARY KEY,
+    data BLOB NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS paste_attachments (
+    paste_id TEXT NOT NULL REFERENCES pastes(id) ON DELETE CASCADE,
+    name TEXT NOT NULL,
+    content_type TEXT NOT NULL,
+    size INTEGER NOT NULL,
+    hash TEXT NOT NULL,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (paste_id, name)
+);
+
+CREATE TRIGGER IF NOT EXISTS paste_attachments_delete_blob
+AFTER DELETE ON paste_attachments
+BEGIN
+    DELETE FROM attachment_blobs WHERE hash = OLD.hash
+        AND NOT EXISTS (SELECT 1 FROM paste_attachments WHERE hash = OLD.hash);
+END;
+
+CREATE TRIGGER IF NOT EXISTS paste_attachments_replace_blob
+AFTER UPDATE OF hash ON paste_attachments
+BEGIN
+    DELETE FROM attachment_blobs WHERE hash = OLD.hash
+        AND NOT EXISTS (SELECT 1 FROM paste_attachments WHERE hash = OLD.hash);
+END;`
+
+// SQL for the log of individual paste views read by polling triggers,
+// recorded by triggers on the daily view counts so pastes with do_not_track
+// never appear in it
+const createPasteViewLogSQL = `
+CREATE TABLE IF NOT EXISTS paste_view_log (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    paste_id TEXT NOT NULL,
+    viewed_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+CREATE INDEX IF NOT EXISTS idx_paste_view_log_viewed_at ON paste_view_log (viewed_at);
+
+CREATE TRIGGER IF NOT EXISTS paste_view_log_ai AFTER INSERT ON paste_views BEGIN
+    INSERT INTO paste_view_log (paste_id) VALUES (new.paste_id);
+END;
+CREATE TRIGGER IF NOT EXISTS paste_view_log_au AFTER UPDATE OF views ON paste_views BEGIN
+    INSERT INTO paste_view_log (paste_id) VALUES (new.paste_id);
+END;`
+
+// SQL for adding the space-separated scopes an API key is limited to; empty
+// leaves it unrestricted
+const addAPIKeyScopesSQL = `
+ALTER TABLE api_keys ADD COLUMN scopes TEXT NOT NULL DEFAULT '';`
+
+// SQL for metadata generated about pastes after they are created, such as
+// a title and summary
+const createPasteMetadataSQL = `
+CREATE TABLE IF NOT EXISTS paste_metadata (
+    paste_id TEXT NOT NULL REFERENCES pastes(id) ON DELETE CASCADE,
+    key TEXT NOT NULL,
+    value TEXT NOT NULL,
+    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (paste_id, key)
+);`
+
+// SQL for counting raw content fetches among a paste's daily views; views
+// stays the total
+const addRawPasteViewsSQL = `
+ALTER TABLE paste_views ADD COLUMN raw_views INTEGER NOT NULL DEFAULT 0;`
+
+// SQL for the SimHash fingerprint of paste content, indexed by each of its
+// four 16-bit bands so near-duplicates can be looked up
+const addPasteContentSimhashSQL = `
+ALTER TABLE pastes ADD COLUMN content_simhash INTEGER;
+CREATE INDEX IF NOT EXISTS idx_pastes_simhash_band0 ON pastes (content_simhash & 65535);
+CREATE INDEX IF NOT EXISTS idx_pastes_simhash_band1 ON pastes ((content_simhash >> 16) & 65535);
+CREATE INDEX IF NOT EXISTS idx_pastes_simhash_band2 ON pastes ((content_simhash >> 32) & 65535);
+CREATE INDEX IF NOT EXISTS idx_pastes_simhash_band3 ON pastes ((content_simhash >> 48) & 65535);`
+
+// SQL for the audit log of sensitive admin actions. Entries keep the IDs of
+// the users involved, without foreign keys, so they outlive the accounts.
+const createAuditLogSQL = `
+CREATE TABLE IF NOT EXISTS audit_log (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    actor_id INTEGER,
+    action TEXT NOT NULL,
+    target_user_id INTEGER,
+    details TEXT NOT NULL DEFAULT '',
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+CREATE INDEX IF NOT EXISTS idx_audit_log_actor_id ON audit_log (actor_id);
+CREATE INDEX IF NOT EXISTS idx_audit_log_target_user_id ON audit_log (target_user_id);`
+
+// SQL for tags users put on their pastes. Tags belong to a user, so each
+// user has their own set of tag names.
+const createPasteTagsSQL = `
+CREATE TABLE IF NOT EXISTS tags (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name TEXT NOT NULL,
+    UNIQUE (user_id, name)
+);
+CREATE TABLE IF NOT EXISTS paste_tags (
+    paste_id TEXT NOT NULL REFERENCES pastes(id) ON DELETE CASCADE,
+    tag_id INTEGER NOT NULL REFERENCES tags(id) ON DELETE CASCADE,
+    PRIMARY KEY (paste_id, tag_id)
+);
+CREATE INDEX IF NOT EXISTS idx_paste_tags_tag_id ON paste_tags (tag_id);`
+
+// SQL for the compressed cold tier of pastes unread for a long time. A cold
+// paste keeps its row with empty content and the size of its content in
+// cold_size; storage usage and delete events count that size instead, so
+// moving a paste between tiers changes neither.
+const createPasteColdStorageSQL = `
+ALTER TABLE pastes ADD COLUMN cold_size INTEGER;
+CREATE TABLE IF NOT EXISTS paste_cold (
+    paste_id TEXT PRIMARY KEY REFERENCES pastes(id) ON DELETE CASCADE,
+    data BLOB NOT NULL,
+    archived_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+
+DROP TRIGGER IF EXISTS storage_usage_ad;
+CREATE TRIGGER storage_usage_ad AFTER DELETE ON pastes BEGIN
+    UPDATE storage_usage SET
+        paste_count = paste_count - 1,
+        bytes = bytes - COALESCE(old.cold_size, length(CAST(old.content AS BLOB)))
+    WHERE user_id = COALESCE(old.user_id, 0);
+END;
+DROP TRIGGER IF EXISTS storage_usage_au;
+CREATE TRIGGER storage_usage_au AFTER UPDATE OF content, user_id, cold_size ON pastes BEGIN
+    UPDATE storage_usage SET
+        paste_count = paste_count - 1,
+        bytes = bytes - COALESCE(old.cold_size, length(CAST(old.content AS BLOB)))
+    WHERE user_id = COALESCE(old.user_id, 0);
+    INSERT INTO storage_usage (user_id, paste_count, bytes)
+    VALUES (COALESCE(new.user_id, 0), 1, COALESCE(new.cold_size, length(CAST(new.content AS BLOB))))
+    ON CONFLICT (user_id) DO UPDATE SET
+        paste_count = paste_count + 1,
+        bytes = bytes + excluded.bytes;
+END;
+DROP TRIGGER IF EXISTS paste_events_ad;
+CREATE TRIGGER paste_events_ad AFTER DELETE ON pastes BEGIN
+    INSERT INTO paste_events (paste_id, event, language, bytes)
+    VALUES (
+        CASE WHEN old.do_not_track THEN NULL ELSE old.id END,
+        CASE WHEN old.expires_at IS NOT NULL AND julianday(old.expires_at) <= julianday('now')
+            THEN 'expire' ELSE 'delete' END,
+        COALESCE(old.language, ''),
+        COALESCE(old.cold_size, length(CAST(old.content AS BLOB)))
+    );
+END;`
+
+// SQL for dropping the excerpts of receivers' responses from the webhook
+// delivery log. Endpoints are user-supplied, so their responses could be
+// internal pages read back through the log.
+const dropWebhookResponseExcerptSQL = `
+ALTER TABLE webhook_deliveries DROP COLUMN response_excerpt;`
+
+// SQL for keeping cold pastes in the full-text index. The index reads a
+// paste's terms back from its content to drop them, so the triggers leave
+// cold rows alone: their terms stay indexed while the content is blank, and
+// the repository moves a cold paste back to the hot tier before changing or
+// deleting it. Pastes archived before this lost their terms; paste_cold
+// records which copies are indexed so the repository can add them back.
+const keepColdPastesIndexedSQL = `
+ALTER TABLE paste_cold ADD COLUMN indexed BOOLEAN NOT NULL DEFAULT 0;
+
+DROP TRIGGER IF EXISTS pastes_fts_bu;
+CREATE TRIGGER pastes_fts_bu BEFORE UPDATE ON pastes
+WHEN old.cold_size IS NULL AND new.cold_size IS NULL BEGIN
+    DELETE FROM pastes_fts WHERE docid = old.rowid;
+END;
+DROP TRIGGER IF EXISTS pastes_fts_bd;
+CREATE TRIGGER pastes_fts_bd BEFORE DELETE ON pastes
+WHEN old.cold_size IS NULL BEGIN
+    DELETE FROM pastes_fts WHERE docid = old.rowid;
+END;
+DROP TRIGGER IF EXISTS pastes_fts_au;
+CREATE TRIGGER pastes_fts_au AFTER UPDATE ON pastes
+WHEN old.cold_size IS NULL AND new.cold_size IS NULL BEGIN
+    INSERT INTO pastes_fts (docid, content, language) VALUES (new.rowid, new.content, new.language);
+END;`
+
+// SQL for recording attachment contents no attachment refers to any more.
+// The triggers only remove contents kept in the database; the repository
+// removes recorded ones from every other store the contents are kept in.
+const createBlobDeletionsSQL = `
+CREATE TABLE IF NOT EXISTS blob_deletions (
+    hash TEXT PRIMARY KEY,
+    deleted_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+
+DROP TRIGGER IF EXISTS paste_attachments_delete_blob;
+CREATE TRIGGER paste_attachments_delete_blob
+AFTER DELETE ON paste_attachments
+WHEN NOT EXISTS (SELECT 1 FROM paste_attachments WHERE hash = OLD.hash)
+BEGIN
+    DELETE FROM attachment_blobs WHERE hash = OLD.hash;
+    INSERT OR IGNORE INTO blob_deletions (hash) VALUES (OLD.hash);
+END;
+
+DROP TRIGGER IF EXISTS paste_attachments_replace_blob;
+CREATE TRIGGER paste_attachments_replace_blob
+AFTER UPDATE OF hash ON paste_attachments
+WHEN NOT EXISTS (SELECT 1 FROM paste_attachments WHERE hash = OLD.hash)
+BEGIN
+    DELETE FROM attachment_blobs WHERE hash = OLD.hash;
+    INSERT OR IGNORE INTO blob_deletions (hash) VALUES (OLD.hash);
+END;`
+
+// SQL for reindexing a paste only when a column in the index changes, so
+// updates such as view counts do not rewrite its full-text entry
+const narrowPastesFTSUpdateTriggersSQL = `
+DROP TRIGGER IF EXISTS pastes_fts_bu;
+CREATE TRIGGER pastes_fts_bu BEFORE UPDATE OF content, language ON pastes
+WHEN old.cold_size IS NULL AND new.cold_size IS NULL BEGIN
+    DELETE FROM pastes_fts WHERE docid = old.rowid;
+END;
+DROP TRIGGER IF EXISTS pastes_fts_au;
+CREATE TRIGGER pastes_fts_au AFTER UPDATE OF content, language ON pastes
+WHEN old.cold_size IS NULL AND new.cold_size IS NULL BEGIN
+    INSERT INTO pastes_fts (docid, content, language) VALUES (new.rowid, new.content, new.language);
+END;`
+
+// SQL for the heartbeat written to the primary to measure how far a read
+// replica trails it
+const createReplicaHeartbeatSQL = `
+CREATE TABLE IF NOT EXISTS replica_heartbeat (
+    id INTEGER PRIMARY KEY CHECK (id = 1),
+    beat_at INTEGER NOT NULL
+);`
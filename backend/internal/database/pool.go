@@ -0,0 +1,96 @@
+package database
+
+import (
+	"database/sql"
+	"log"
+	"time"
+
+	"github.com/LonleySailor/privatepaste/backend/internal/metrics"
+)
+
+var (
+	poolOpen      = metrics.NewGauge("pastevault_db_pool_open_connections", "Open database connections.")
+	poolInUse     = metrics.NewGauge("pastevault_db_pool_in_use_connections", "Database connections currently in use.")
+	poolWaits     = metrics.NewCounter("pastevault_db_pool_waits_total", "Queries that had to wait for a free database connection.")
+	poolExhausted = metrics.NewCounter("pastevault_db_pool_exhausted_total", "Sampling intervals in which every connection was busy and queries waited.")
+)
+
+// PoolConfig holds connection pool limits for drivers that support concurrent
+// connections. SQLite always uses a single connection and ignores it.
+type PoolConfig struct {
+	MaxOpenConns    int           // 0 means unlimited
+	MaxIdleConns    int           // 0 keeps the driver default
+	ConnMaxLifetime time.Duration // 0 means connections are reused forever
+}
+
+// ConfigurePool applies cfg to the connection pool unless the driver is SQLite
+func (d *Database) ConfigurePool(cfg PoolConfig) {
+	if d.Driver == DriverSQLite {
+		return
+	}
+	d.DB.SetMaxOpenConns(cfg.MaxOpenConns)
+	if cfg.MaxIdleConns > 0 {
+		d.DB.SetMaxIdleConns(cfg.MaxIdleConns)
+	}
+	d.DB.SetConnMaxLifetime(cfg.ConnMaxLifetime)
+}
+
+// PoolMonitor periodically exports connection pool statistics as metrics and
+// warns when the pool is exhausted
+type PoolMonitor struct {
+	db        *sql.DB
+	interval  time.Duration
+	stopChan  chan struct{}
+	lastWaits int64
+}
+
+// NewPoolMonitor creates a pool monitor sampling db every interval
+func NewPoolMonitor(db *sql.DB, interval time.Duration) *PoolMonitor {
+	return &PoolMonitor{
+		db:       db,
+		interval: interval,
+		stopChan: make(chan struct{}),
+	}
+}
+
+// Start starts the pool monitor background worker
+func (m *PoolMonitor) Start() {
+	ticker := time.NewTicker(m.interval)
+
+	go func() {
+		for {
+			select {
+			case <-ticker.C:
+				m.sample()
+			case <-m.stopChan:
+				ticker.Stop()
+				return
+			}
+		}
+	}()
+}
+
+// Stop stops the pool monitor
+func (m *PoolMonitor) Stop() {
+	close(m.stopChan)
+}
+
+// sample records the current pool statistics
+func (m *PoolMonitor) sample() {
+	stats := m.db.Stats()
+	poolOpen.Set(float64(stats.OpenConnections))
+	poolInUse.Set(float64(stats.InUse))
+
+	waits := stats.WaitCount - m.lastWaits
+	m.lastWaits = stats.WaitCount
+	if waits <= 0 {
+		return
+	}
+	poolWaits.Add(float64(waits))
+
+	if stats.MaxOpenConnections > 0 && stats.InUse >= stats.MaxOpenConnections {
+		poolExhausted.Inc()
+		log.Printf("Warning: database connection pool exhausted (%d/%d in use, %d queries waited)",
+			stats.InUse, stats.MaxOpenConnections, waits)
+	}
+}
@@ -0,0 +1,37 @@
+package database
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+)
+
+func TestConfigurePool_SQLiteKeepsSingleConnection(t *testing.T) {
+	db, err := NewSQLiteDB(":memory:")
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	db.ConfigurePool(PoolConfig{MaxOpenConns: 25, MaxIdleConns: 5, ConnMaxLifetime: time.Minute})
+
+	if max := db.DB.Stats().MaxOpenConnections; max != 1 {
+		t.Errorf("Expected SQLite to keep a single connection, got %d", max)
+	}
+}
+
+func TestConfigurePool(t *testing.T) {
+	sqlDB, err := sql.Open(DriverSQLite, ":memory:")
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer sqlDB.Close()
+
+	// Pretend to be a driver with a real pool
+	db := &Database{DB: sqlDB, Driver: "postgres"}
+	db.ConfigurePool(PoolConfig{MaxOpenConns: 25, MaxIdleConns: 5})
+
+	if max := sqlDB.Stats().MaxOpenConnections; max != 25 {
+		t.Errorf("Expected max open connections of 25, got %d", max)
+	}
+}
@@ -0,0 +1,355 @@
+package database
+
+import (
+	"database/sql"
+
+	"github.com/jackc/pgx/v5/stdlib"
+)
+
+// DriverPostgres is the database/sql driver name for Postgres, registered
+// for migrating data there
+const DriverPostgres = "postgres"
+
+func init() {
+	sql.Register(DriverPostgres, stdlib.GetDefaultDriver())
+}
+
+// postgresSchemaSQL creates the tables of the current schema in Postgres, for
+// copying a SQLite database into. Column types follow the SQLite
+// declarations: integers are BIGINT, DATETIME is TIMESTAMPTZ and BLOB is
+// BYTEA. Triggers and the full-text index are SQLite specific and left out.
+const postgresSchemaSQL = `
+CREATE TABLE IF NOT EXISTS migrations (
+    id BIGINT PRIMARY KEY,
+    description TEXT NOT NULL,
+    applied_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS users (
+    id BIGINT GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+    username TEXT UNIQUE NOT NULL,
+    password_hash TEXT NOT NULL,
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    role TEXT NOT NULL DEFAULT 'user',
+    verified_email TEXT,
+    deactivated_at TIMESTAMPTZ
+);
+
+CREATE TABLE IF NOT EXISTS pastes (
+    id TEXT PRIMARY KEY,
+    content TEXT NOT NULL,
+    language TEXT,
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    expires_at TIMESTAMPTZ,
+    password_hash TEXT,
+    user_id BIGINT REFERENCES users (id) ON DELETE SET NULL,
+    do_not_track BOOLEAN NOT NULL DEFAULT FALSE,
+    theme TEXT NOT NULL DEFAULT '',
+    line_numbers BOOLEAN NOT NULL DEFAULT TRUE,
+    word_wrap BOOLEAN NOT NULL DEFAULT FALSE,
+    kind TEXT NOT NULL DEFAULT 'text',
+    parent_id TEXT REFERENCES pastes (id) ON DELETE SET NULL,
+    creator_ip_hash TEXT,
+    visibility TEXT NOT NULL DEFAULT 'public',
+    require_signed_urls BOOLEAN NOT NULL DEFAULT FALSE,
+    content_sha256 TEXT,
+    signature TEXT,
+    signature_format TEXT,
+    noindex BOOLEAN NOT NULL DEFAULT FALSE,
+    password_hint TEXT,
+    max_unlock_attempts BIGINT,
+    failed_unlocks BIGINT NOT NULL DEFAULT 0,
+    access_window_start BIGINT,
+    access_window_end BIGINT,
+    allowed_networks TEXT,
+    allowed_countries TEXT,
+    embed_domains TEXT,
+    watermark BOOLEAN NOT NULL DEFAULT FALSE,
+    content_simhash BIGINT,
+    cold_size BIGINT
+);
+
+CREATE TABLE IF NOT EXISTS paste_cold (
+    paste_id TEXT PRIMARY KEY REFERENCES pastes (id) ON DELETE CASCADE,
+    data BYTEA NOT NULL,
+    archived_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    indexed BOOLEAN NOT NULL DEFAULT FALSE
+);
+
+CREATE TABLE IF NOT EXISTS jobs (
+    id BIGINT GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+    type TEXT NOT NULL,
+    payload TEXT NOT NULL,
+    status TEXT NOT NULL DEFAULT 'pending',
+    attempts BIGINT NOT NULL DEFAULT 0,
+    max_attempts BIGINT NOT NULL,
+    run_at TIMESTAMPTZ NOT NULL,
+    last_error TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS storage_usage (
+    user_id BIGINT PRIMARY KEY,
+    paste_count BIGINT NOT NULL DEFAULT 0,
+    bytes BIGINT NOT NULL DEFAULT 0
+);
+
+CREATE TABLE IF NOT EXISTS paste_events (
+    id BIGINT GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+    paste_id TEXT,
+    event TEXT NOT NULL,
+    language TEXT NOT NULL DEFAULT '',
+    bytes BIGINT NOT NULL DEFAULT 0,
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS paste_stats_daily (
+    day TEXT NOT NULL,
+    event TEXT NOT NULL,
+    count BIGINT NOT NULL DEFAULT 0,
+    bytes BIGINT NOT NULL DEFAULT 0,
+    PRIMARY KEY (day, event)
+);
+
+CREATE TABLE IF NOT EXISTS api_keys (
+    id BIGINT GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+    user_id BIGINT NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    name TEXT NOT NULL,
+    prefix TEXT NOT NULL,
+    key_hash TEXT NOT NULL UNIQUE,
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    last_used_at TIMESTAMPTZ,
+    scopes TEXT NOT NULL DEFAULT ''
+);
+
+CREATE TABLE IF NOT EXISTS integrations (
+    id BIGINT GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+    user_id BIGINT NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    name TEXT NOT NULL,
+    platform TEXT NOT NULL,
+    webhook_url TEXT NOT NULL,
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    signing_secret TEXT,
+    previous_signing_secret TEXT,
+    previous_secret_expires_at TIMESTAMPTZ,
+    UNIQUE (user_id, name)
+);
+
+CREATE TABLE IF NOT EXISTS ssh_keys (
+    id BIGINT GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+    user_id BIGINT NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    name TEXT NOT NULL,
+    fingerprint TEXT NOT NULL UNIQUE,
+    public_key TEXT NOT NULL,
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    last_used_at TIMESTAMPTZ
+);
+
+CREATE TABLE IF NOT EXISTS developer_keys (
+    id BIGINT GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+    name TEXT NOT NULL,
+    prefix TEXT NOT NULL,
+    key_hash TEXT NOT NULL UNIQUE,
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    last_used_at TIMESTAMPTZ
+);
+
+CREATE TABLE IF NOT EXISTS developer_key_usage (
+    key_id BIGINT NOT NULL REFERENCES developer_keys (id) ON DELETE CASCADE,
+    day TEXT NOT NULL,
+    requests BIGINT NOT NULL DEFAULT 0,
+    limited BIGINT NOT NULL DEFAULT 0,
+    PRIMARY KEY (key_id, day)
+);
+
+CREATE TABLE IF NOT EXISTS webhook_deliveries (
+    id BIGINT GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+    integration_id BIGINT NOT NULL REFERENCES integrations (id) ON DELETE CASCADE,
+    event TEXT NOT NULL,
+    body TEXT NOT NULL,
+    success BOOLEAN NOT NULL,
+    status_code BIGINT,
+    latency_ms BIGINT NOT NULL DEFAULT 0,
+    error TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS paste_views (
+    paste_id TEXT NOT NULL REFERENCES pastes (id) ON DELETE CASCADE,
+    day TEXT NOT NULL,
+    views BIGINT NOT NULL DEFAULT 0,
+    raw_views BIGINT NOT NULL DEFAULT 0,
+    PRIMARY KEY (paste_id, day)
+);
+
+CREATE TABLE IF NOT EXISTS notification_preferences (
+    user_id BIGINT PRIMARY KEY REFERENCES users (id) ON DELETE CASCADE,
+    weekly_digest TEXT NOT NULL DEFAULT 'off',
+    digest_integration_id BIGINT REFERENCES integrations (id) ON DELETE SET NULL,
+    last_digest_at TIMESTAMPTZ,
+    updated_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS user_settings (
+    user_id BIGINT PRIMARY KEY REFERENCES users (id) ON DELETE CASCADE,
+    default_expiry TEXT NOT NULL DEFAULT '',
+    default_visibility TEXT NOT NULL DEFAULT '',
+    default_language TEXT NOT NULL DEFAULT '',
+    updated_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS service_accounts (
+    user_id BIGINT PRIMARY KEY REFERENCES users (id) ON DELETE CASCADE,
+    description TEXT NOT NULL DEFAULT '',
+    scopes TEXT NOT NULL,
+    client_id TEXT UNIQUE NOT NULL,
+    secret_hash TEXT NOT NULL,
+    created_by BIGINT REFERENCES users (id) ON DELETE SET NULL,
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    last_used_at TIMESTAMPTZ,
+    disabled_at TIMESTAMPTZ
+);
+
+CREATE TABLE IF NOT EXISTS user_identities (
+    provider TEXT NOT NULL,
+    subject TEXT NOT NULL,
+    user_id BIGINT NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    last_login_at TIMESTAMPTZ,
+    PRIMARY KEY (provider, subject)
+);
+
+CREATE TABLE IF NOT EXISTS scim_users (
+    user_id BIGINT PRIMARY KEY REFERENCES users (id) ON DELETE CASCADE,
+    user_name TEXT NOT NULL,
+    external_id TEXT,
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS content_policies (
+    role TEXT PRIMARY KEY,
+    max_size BIGINT NOT NULL DEFAULT 0,
+    allowed_expiries TEXT NOT NULL DEFAULT '',
+    allow_public BOOLEAN NOT NULL DEFAULT TRUE,
+    allow_attachments BOOLEAN NOT NULL DEFAULT TRUE,
+    updated_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS username_holds (
+    username TEXT PRIMARY KEY,
+    user_id BIGINT NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    held_until TIMESTAMPTZ NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS avatar_blobs (
+    hash TEXT PRIMARY KEY,
+    data BYTEA NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS user_avatars (
+    user_id BIGINT PRIMARY KEY REFERENCES users (id) ON DELETE CASCADE,
+    hash TEXT NOT NULL,
+    updated_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS follows (
+    follower_id BIGINT NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    followee_id BIGINT NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (follower_id, followee_id)
+);
+
+CREATE TABLE IF NOT EXISTS trending_pastes (
+    paste_id TEXT PRIMARY KEY REFERENCES pastes (id) ON DELETE CASCADE,
+    score DOUBLE PRECISION NOT NULL,
+    views BIGINT NOT NULL,
+    computed_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS attachment_blobs (
+    hash TEXT PRIMARY KEY,
+    data BYTEA NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS paste_attachments (
+    paste_id TEXT NOT NULL REFERENCES pastes (id) ON DELETE CASCADE,
+    name TEXT NOT NULL,
+    content_type TEXT NOT NULL,
+    size BIGINT NOT NULL,
+    hash TEXT NOT NULL,
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (paste_id, name)
+);
+
+CREATE TABLE IF NOT EXISTS blob_deletions (
+    hash TEXT PRIMARY KEY,
+    deleted_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS paste_view_log (
+    id BIGINT GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+    paste_id TEXT NOT NULL,
+    viewed_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS paste_metadata (
+    paste_id TEXT NOT NULL REFERENCES pastes (id) ON DELETE CASCADE,
+    key TEXT NOT NULL,
+    value TEXT NOT NULL,
+    updated_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (paste_id, key)
+);
+
+CREATE TABLE IF NOT EXISTS audit_log (
+    id BIGINT GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+    actor_id BIGINT,
+    action TEXT NOT NULL,
+    target_user_id BIGINT,
+    details TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS tags (
+    id BIGINT GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+    user_id BIGINT NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    name TEXT NOT NULL,
+    UNIQUE (user_id, name)
+);
+
+CREATE TABLE IF NOT EXISTS paste_tags (
+    paste_id TEXT NOT NULL REFERENCES pastes (id) ON DELETE CASCADE,
+    tag_id BIGINT NOT NULL REFERENCES tags (id) ON DELETE CASCADE,
+    PRIMARY KEY (paste_id, tag_id)
+);
+
+CREATE TABLE IF NOT EXISTS replica_heartbeat (
+    id BIGINT PRIMARY KEY CHECK (id = 1),
+    beat_at BIGINT NOT NULL
+);`
+
+// postgresIndexesSQL creates the indexes of the current schema in Postgres.
+// They are created once the data is in, which is faster than keeping them
+// up to date while copying.
+const postgresIndexesSQL = `
+CREATE INDEX IF NOT EXISTS idx_api_keys_user_id ON api_keys (user_id);
+CREATE INDEX IF NOT EXISTS idx_audit_log_actor_id ON audit_log (actor_id);
+CREATE INDEX IF NOT EXISTS idx_audit_log_target_user_id ON audit_log (target_user_id);
+CREATE INDEX IF NOT EXISTS idx_follows_followee ON follows (followee_id);
+CREATE INDEX IF NOT EXISTS idx_jobs_status_run_at ON jobs (status, run_at);
+CREATE INDEX IF NOT EXISTS idx_paste_events_created_at ON paste_events (created_at);
+CREATE INDEX IF NOT EXISTS idx_paste_tags_tag_id ON paste_tags (tag_id);
+CREATE INDEX IF NOT EXISTS idx_paste_view_log_viewed_at ON paste_view_log (viewed_at);
+CREATE INDEX IF NOT EXISTS idx_pastes_content_sha256 ON pastes (content_sha256);
+CREATE INDEX IF NOT EXISTS idx_pastes_creator_ip_hash ON pastes (creator_ip_hash);
+CREATE INDEX IF NOT EXISTS idx_pastes_simhash_band0 ON pastes ((content_simhash & 65535));
+CREATE INDEX IF NOT EXISTS idx_pastes_simhash_band1 ON pastes (((content_simhash >> 16) & 65535));
+CREATE INDEX IF NOT EXISTS idx_pastes_simhash_band2 ON pastes (((content_simhash >> 32) & 65535));
+CREATE INDEX IF NOT EXISTS idx_pastes_simhash_band3 ON pastes (((content_simhash >> 48) & 65535));
+CREATE UNIQUE INDEX IF NOT EXISTS idx_scim_users_external_id ON scim_users (external_id) WHERE external_id IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_ssh_keys_user_id ON ssh_keys (user_id);
+CREATE INDEX IF NOT EXISTS idx_user_identities_user_id ON user_identities (user_id);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_users_username_nocase ON users (lower(username));
+CREATE UNIQUE INDEX IF NOT EXISTS idx_users_verified_email ON users (verified_email) WHERE verified_email IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_integration ON webhook_deliveries (integration_id, id);`
@@ -0,0 +1,141 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"sync/atomic"
+	"time"
+
+	"github.com/LonleySailor/privatepaste/backend/internal/metrics"
+)
+
+var replicaLag = metrics.NewGauge("pastevault_db_replica_lag_seconds", "How far the read replica trails the primary; -1 when unreachable.")
+
+// Replica routes read-only queries to a replica database while it keeps up
+// with the primary. When the replica lags by more than maxLag or cannot be
+// reached, reads fall back to the primary until it catches up.
+type Replica struct {
+	primary  *sql.DB
+	replica  *sql.DB
+	maxLag   time.Duration
+	healthy  atomic.Bool
+	stopChan chan struct{}
+}
+
+// OpenSQLiteReplica opens a read-only SQLite replica (for example one restored
+// continuously by Litestream) and checks its lag against primary
+func OpenSQLiteReplica(path string, primary *sql.DB, maxLag time.Duration) (*Replica, error) {
+	db, err := sql.Open(DriverSQLite, "file:"+path+"?mode=ro")
+	if err != nil {
+		return nil, fmt.Errorf("failed to open read replica: %w", err)
+	}
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to ping read replica: %w", err)
+	}
+
+	return NewReplica(primary, db, maxLag), nil
+}
+
+// NewReplica creates a replica router over already opened connections
+func NewReplica(primary, replica *sql.DB, maxLag time.Duration) *Replica {
+	r := &Replica{
+		primary:  primary,
+		replica:  replica,
+		maxLag:   maxLag,
+		stopChan: make(chan struct{}),
+	}
+	r.Check()
+	return r
+}
+
+// ReadDB returns the connection to use for read-only queries
+func (r *Replica) ReadDB() *sql.DB {
+	if r.healthy.Load() {
+		return r.replica
+	}
+	return r.primary
+}
+
+// Lag returns how long the replica has been missing writes to the primary.
+// Each call writes a heartbeat to the primary; a replica without the last
+// heartbeat lags by at least the time since it was written. Deletes and
+// edits replicate in the same stream as heartbeats, so unlike comparing
+// the newest paste, this notices a replica stuck on any kind of write.
+func (r *Replica) Lag() (time.Duration, error) {
+	primaryBeat, err := lastHeartbeat(r.primary)
+	if err != nil {
+		return 0, err
+	}
+	replicaBeat, err := lastHeartbeat(r.replica)
+	if err != nil {
+		return 0, err
+	}
+
+	now := time.Now()
+	if _, err := r.primary.Exec(`
+		INSERT INTO replica_heartbeat (id, beat_at) VALUES (1, ?)
+		ON CONFLICT (id) DO UPDATE SET beat_at = excluded.beat_at`, now.UnixNano()); err != nil {
+		return 0, fmt.Errorf("failed to write replica heartbeat: %w", err)
+	}
+
+	if replicaBeat >= primaryBeat {
+		return 0, nil
+	}
+	return now.Sub(time.Unix(0, primaryBeat)), nil
+}
+
+// Check measures the replica lag and enables or disables replica reads
+func (r *Replica) Check() {
+	lag, err := r.Lag()
+	healthy := err == nil && lag <= r.maxLag
+
+	if err != nil {
+		replicaLag.Set(-1)
+	} else {
+		replicaLag.Set(lag.Seconds())
+	}
+
+	if r.healthy.Swap(healthy) != healthy {
+		if healthy {
+			log.Println("Read replica caught up; serving reads from the replica")
+		} else {
+			log.Printf("Read replica unavailable or lagging (lag %v, error %v); serving reads from the primary", lag, err)
+		}
+	}
+}
+
+// Start periodically re-checks the replica lag
+func (r *Replica) Start(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+
+	go func() {
+		for {
+			select {
+			case <-ticker.C:
+				r.Check()
+			case <-r.stopChan:
+				ticker.Stop()
+				return
+			}
+		}
+	}()
+}
+
+// Close stops lag checks and closes the replica connection
+func (r *Replica) Close() error {
+	close(r.stopChan)
+	return r.replica.Close()
+}
+
+// lastHeartbeat returns when the last replica heartbeat was written, in
+// nanoseconds since the epoch, or 0 if none was
+func lastHeartbeat(db *sql.DB) (int64, error) {
+	var beat int64
+	err := db.QueryRow(`SELECT beat_at FROM replica_heartbeat WHERE id = 1`).Scan(&beat)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	return beat, err
+}
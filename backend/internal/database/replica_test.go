@@ -0,0 +1,40 @@
+package database
+
+import "testing"
+
+func TestReplica(t *testing.T) {
+	primary, err := NewSQLiteDB(":memory:")
+	if err != nil {
+		t.Fatalf("Failed to open primary: %v", err)
+	}
+	defer primary.Close()
+
+	replicaDB, err := NewSQLiteDB(":memory:")
+	if err != nil {
+		t.Fatalf("Failed to open replica: %v", err)
+	}
+
+	replica := NewReplica(primary.DB, replicaDB.DB, 0)
+	defer replica.Close()
+
+	if replica.ReadDB() != replicaDB.DB {
+		t.Fatal("Expected reads to use the replica before any heartbeat")
+	}
+
+	// The replica never received the first check's heartbeat
+	replica.Check()
+	if replica.ReadDB() != primary.DB {
+		t.Error("Expected reads to fall back to the primary while the replica lags")
+	}
+
+	var beat int64
+	primary.DB.QueryRow(`SELECT beat_at FROM replica_heartbeat`).Scan(&beat)
+	if _, err := replicaDB.DB.Exec(`INSERT INTO replica_heartbeat (id, beat_at) VALUES (1, ?)`, beat); err != nil {
+		t.Fatalf("Failed to replicate heartbeat: %v", err)
+	}
+
+	replica.Check()
+	if replica.ReadDB() != replicaDB.DB {
+		t.Error("Expected reads to return to the replica once it caught up")
+	}
+}
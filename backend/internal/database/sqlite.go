@@ -8,14 +8,18 @@ import (
 	_ "github.com/mattn/go-sqlite3" // SQLite driver
 )
 
+// DriverSQLite is the database/sql driver name for SQLite
+const DriverSQLite = "sqlite3"
+
 // Database wraps the sql.DB connection and provides helper methods
 type Database struct {
-	DB *sql.DB
+	DB     *sql.DB
+	Driver string
 }
 
 // NewSQLiteDB creates a new SQLite database connection
 func NewSQLiteDB(databasePath string) (*Database, error) {
-	db, err := sql.Open("sqlite3", databasePath)
+	db, err := sql.Open(DriverSQLite, databasePath)
 	if err != nil {
 		return nil, fmt.Errorf("failed to open database: %w", err)
 	}
@@ -34,7 +38,7 @@ func NewSQLiteDB(databasePath string) (*Database, error) {
 	db.SetMaxOpenConns(1) // SQLite works best with a single connection
 	db.SetMaxIdleConns(1)
 
-	database := &Database{DB: db}
+	database := &Database{DB: db, Driver: DriverSQLite}
 
 	// Run migrations
 	if err := database.runMigrations(); err != nil {
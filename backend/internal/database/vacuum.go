@@ -0,0 +1,100 @@
+package database
+
+import (
+	"fmt"
+
+	"github.com/LonleySailor/privatepaste/backend/internal/metrics"
+)
+
+var (
+	dbSizeBytes      = metrics.NewGauge("pastevault_db_size_bytes", "Size of the database file.")
+	dbFreeBytes      = metrics.NewGauge("pastevault_db_free_bytes", "Unused space inside the database file left behind by deletions.")
+	dbReclaimedBytes = metrics.NewCounter("pastevault_db_reclaimed_bytes_total", "Space returned to the filesystem by vacuuming.")
+)
+
+// SQLite auto_vacuum modes
+const (
+	autoVacuumNone        = 0
+	autoVacuumIncremental = 2
+)
+
+// VacuumReport describes the database size before and after a vacuum
+type VacuumReport struct {
+	SizeBefore int64
+	SizeAfter  int64
+	FreeBefore int64
+	Vacuumed   bool // False when free space was below the threshold
+}
+
+// Reclaimed returns the number of bytes returned to the filesystem
+func (r *VacuumReport) Reclaimed() int64 {
+	return r.SizeBefore - r.SizeAfter
+}
+
+// Vacuum reclaims free pages once they make up at least minFreeRatio of the
+// database file, then refreshes the query planner statistics. The first run
+// switches the database to incremental auto-vacuum, which requires a one-off
+// full VACUUM; later runs only release free pages.
+func (d *Database) Vacuum(minFreeRatio float64) (*VacuumReport, error) {
+	size, free, err := d.spaceUsage()
+	if err != nil {
+		return nil, err
+	}
+	report := &VacuumReport{SizeBefore: size, SizeAfter: size, FreeBefore: free}
+	dbSizeBytes.Set(float64(size))
+	dbFreeBytes.Set(float64(free))
+
+	if size == 0 || float64(free)/float64(size) < minFreeRatio {
+		return report, nil
+	}
+
+	var mode int
+	if err := d.DB.QueryRow("PRAGMA auto_vacuum").Scan(&mode); err != nil {
+		return nil, fmt.Errorf("failed to read auto_vacuum mode: %w", err)
+	}
+
+	if mode == autoVacuumIncremental {
+		_, err = d.DB.Exec("PRAGMA incremental_vacuum")
+	} else {
+		if mode == autoVacuumNone {
+			if _, err := d.DB.Exec("PRAGMA auto_vacuum = INCREMENTAL"); err != nil {
+				return nil, fmt.Errorf("failed to enable incremental auto_vacuum: %w", err)
+			}
+		}
+		_, err = d.DB.Exec("VACUUM")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to vacuum database: %w", err)
+	}
+
+	if _, err := d.DB.Exec("ANALYZE"); err != nil {
+		return nil, fmt.Errorf("failed to analyze database: %w", err)
+	}
+
+	if report.SizeAfter, free, err = d.spaceUsage(); err != nil {
+		return nil, err
+	}
+	report.Vacuumed = true
+	dbSizeBytes.Set(float64(report.SizeAfter))
+	dbFreeBytes.Set(float64(free))
+	if reclaimed := report.Reclaimed(); reclaimed > 0 {
+		dbReclaimedBytes.Add(float64(reclaimed))
+	}
+
+	return report, nil
+}
+
+// spaceUsage returns the database size and the unused space inside it, in bytes
+func (d *Database) spaceUsage() (int64, int64, error) {
+	var pageSize, pageCount, freePages int64
+	if err := d.DB.QueryRow("PRAGMA page_size").Scan(&pageSize); err != nil {
+		return 0, 0, fmt.Errorf("failed to read page size: %w", err)
+	}
+	if err := d.DB.QueryRow("PRAGMA page_count").Scan(&pageCount); err != nil {
+		return 0, 0, fmt.Errorf("failed to read page count: %w", err)
+	}
+	if err := d.DB.QueryRow("PRAGMA freelist_count").Scan(&freePages); err != nil {
+		return 0, 0, fmt.Errorf("failed to read free page count: %w", err)
+	}
+	return pageSize * pageCount, pageSize * freePages, nil
+}
@@ -0,0 +1,49 @@
+package database
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestVacuum(t *testing.T) {
+	db, err := NewSQLiteDB(":memory:")
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	content := strings.Repeat("x", 4096)
+	for i := 0; i < 200; i++ {
+		if _, err := db.DB.Exec(`INSERT INTO pastes (id, content) VALUES (?, ?)`, fmt.Sprintf("p%05d", i), content); err != nil {
+			t.Fatalf("Failed to insert paste: %v", err)
+		}
+	}
+
+	// Nothing to reclaim yet
+	report, err := db.Vacuum(0.1)
+	if err != nil {
+		t.Fatalf("Vacuum failed: %v", err)
+	}
+	if report.Vacuumed {
+		t.Error("Expected vacuum to be skipped without free space")
+	}
+
+	if _, err := db.DB.Exec(`DELETE FROM pastes`); err != nil {
+		t.Fatalf("Failed to delete pastes: %v", err)
+	}
+
+	report, err = db.Vacuum(0.1)
+	if err != nil {
+		t.Fatalf("Vacuum failed: %v", err)
+	}
+	if !report.Vacuumed || report.Reclaimed() <= 0 {
+		t.Errorf("Expected space to be reclaimed, got %+v", report)
+	}
+
+	var mode int
+	db.DB.QueryRow("PRAGMA auto_vacuum").Scan(&mode)
+	if mode != autoVacuumIncremental {
+		t.Errorf("Expected incremental auto_vacuum after the first run, got %d", mode)
+	}
+}
@@ -0,0 +1,159 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/mail"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/LonleySailor/privatepaste/backend/internal/middleware"
+	"github.com/LonleySailor/privatepaste/backend/internal/models"
+	"github.com/LonleySailor/privatepaste/backend/internal/services"
+	"github.com/LonleySailor/privatepaste/backend/pkg/utils"
+	"github.com/LonleySailor/privatepaste/backend/pkg/validation"
+)
+
+// emailVerificationTTL is how long a verification link stays valid
+const emailVerificationTTL = 24 * time.Hour
+
+// VerifiedEmailStore records users' verified email addresses
+type VerifiedEmailStore interface {
+	SetVerifiedEmail(userID int, email string) error
+}
+
+// AccountEmailHandler handles verifying the email address on an account.
+// Verification links are signed URLs, so no pending state is stored.
+type AccountEmailHandler struct {
+	users  VerifiedEmailStore
+	queue  JobEnqueuer
+	signer *utils.URLSigner
+}
+
+// NewAccountEmailHandler creates a new account email handler. A nil queue
+// means email is not configured and verification cannot be requested.
+func NewAccountEmailHandler(users VerifiedEmailStore, queue JobEnqueuer, signer *utils.URLSigner) *AccountEmailHandler {
+	return &AccountEmailHandler{users: users, queue: queue, signer: signer}
+}
+
+// VerifyEmailRequest represents a request to verify an email address
+type VerifyEmailRequest struct {
+	Email string `json:"email"`
+}
+
+// emailSigningID is the value signed into a verification link
+func emailSigningID(userID int, email string) string {
+	return "email:" + strconv.Itoa(userID) + ":" + strings.ToLower(email)
+}
+
+// RequestVerification handles sending a verification link to an email
+// address for the authenticated user
+func (h *AccountEmailHandler) RequestVerification(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		WriteError(w, &APIError{
+			Code:    "method_not_allowed",
+			Message: "Method not allowed",
+			Status:  http.StatusMethodNotAllowed,
+		})
+		return
+	}
+
+	if h.queue == nil {
+		WriteError(w, &APIError{
+			Code:    "email_disabled",
+			Message: "Email is not configured on this server",
+			Status:  http.StatusServiceUnavailable,
+		})
+		return
+	}
+
+	userID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		WriteError(w, &APIError{
+			Code:    "unauthorized",
+			Message: "User ID not found in token",
+			Status:  http.StatusUnauthorized,
+		})
+		return
+	}
+
+	var req VerifyEmailRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		WriteError(w, ErrInvalidJSON)
+		return
+	}
+	addr, err := mail.ParseAddress(req.Email)
+	if err != nil || addr.Name != "" {
+		WriteValidationError(w, []validation.ValidationError{{Field: "email", Message: "must be a single email address"}})
+		return
+	}
+
+	expires := time.Now().Add(emailVerificationTTL)
+	query := url.Values{}
+	query.Set("user", strconv.Itoa(userID))
+	query.Set("email", addr.Address)
+	query.Set("expires", strconv.FormatInt(expires.Unix(), 10))
+	query.Set("signature", h.signer.Sign(emailSigningID(userID, addr.Address), expires))
+
+	username, _ := middleware.GetUsernameFromContext(r.Context())
+	body := "Confirm this address for the PasteVault account " + username + ":\n" +
+		siteBaseURL + "/api/user/email/verify?" + query.Encode() + "\n\n" +
+		"The link expires in 24 hours. If you did not request this, ignore this email.\n"
+
+	if err := h.queue.Enqueue(services.JobTypeEmail, services.EmailMessage{To: addr.Address, Subject: "Confirm your email address", Body: body}); err != nil {
+		WriteRepositoryError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(map[string]string{"status": "verification_sent"})
+}
+
+// Verify handles a verification link, recording the address on the account
+func (h *AccountEmailHandler) Verify(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		WriteError(w, &APIError{
+			Code:    "method_not_allowed",
+			Message: "Method not allowed",
+			Status:  http.StatusMethodNotAllowed,
+		})
+		return
+	}
+
+	query := r.URL.Query()
+	userID, err := strconv.Atoi(query.Get("user"))
+	email := query.Get("email")
+	if err != nil || email == "" {
+		WriteError(w, ErrSignatureInvalid)
+		return
+	}
+
+	switch h.signer.Verify(emailSigningID(userID, email), query.Get("expires"), query.Get("signature")) {
+	case nil:
+	case utils.ErrSignatureExpired:
+		WriteError(w, ErrSignatureExpired)
+		return
+	default:
+		WriteError(w, ErrSignatureInvalid)
+		return
+	}
+
+	if err := h.users.SetVerifiedEmail(userID, email); err == models.ErrEmailTaken {
+		WriteError(w, &APIError{
+			Code:    "email_taken",
+			Message: "This email address is verified on another account",
+			Status:  http.StatusConflict,
+		})
+		return
+	} else if err != nil {
+		WriteRepositoryError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{"verified_email": strings.ToLower(email)})
+}
@@ -0,0 +1,336 @@
+package handlers
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/LonleySailor/privatepaste/backend/internal/models"
+	"github.com/LonleySailor/privatepaste/backend/pkg/simhash"
+	"github.com/LonleySailor/privatepaste/backend/pkg/utils"
+	"github.com/LonleySailor/privatepaste/backend/pkg/validation"
+	"github.com/gorilla/mux"
+)
+
+// AdminHandler handles admin-only HTTP requests
+type AdminHandler struct {
+	pasteRepo *models.PasteRepository
+	jobRepo   *models.JobRepository
+	ipHasher  *utils.IPHasher
+}
+
+// NewAdminHandler creates a new admin handler
+func NewAdminHandler(pasteRepo *models.PasteRepository, jobRepo *models.JobRepository, ipHasher *utils.IPHasher) *AdminHandler {
+	return &AdminHandler{
+		pasteRepo: pasteRepo,
+		jobRepo:   jobRepo,
+		ipHasher:  ipHasher,
+	}
+}
+
+// AdminPasteItem represents a paste in admin search results, including
+// metadata that is never exposed through the public API
+type AdminPasteItem struct {
+	ID            string `json:"id"`
+	Snippet       string `json:"snippet"`
+	Language      string `json:"language,omitempty"`
+	Kind          string `json:"kind"`
+	CreatedAt     string `json:"created_at"`
+	ExpiresAt     string `json:"expires_at,omitempty"`
+	HasPassword   bool   `json:"has_password"`
+	UserID        *int   `json:"user_id,omitempty"`
+	CreatorIPHash string `json:"creator_ip_hash,omitempty"`
+}
+
+// adminSnippetLength is the number of characters of content shown per result
+const adminSnippetLength = 200
+
+// newAdminPasteItem summarizes a paste for admin results
+func newAdminPasteItem(paste *models.Paste) AdminPasteItem {
+	snippet := []rune(paste.Content)
+	if len(snippet) > adminSnippetLength {
+		snippet = snippet[:adminSnippetLength]
+	}
+
+	item := AdminPasteItem{
+		ID:          paste.ID,
+		Snippet:     string(snippet),
+		Language:    paste.Language,
+		Kind:        paste.Kind,
+		CreatedAt:   paste.CreatedAt.Format(time.RFC3339),
+		HasPassword: paste.HasPassword(),
+		UserID:      paste.UserID,
+	}
+	if paste.ExpiresAt != nil {
+		item.ExpiresAt = paste.ExpiresAt.Format(time.RFC3339)
+	}
+	if paste.CreatorIPHash != nil {
+		item.CreatorIPHash = *paste.CreatorIPHash
+	}
+	return item
+}
+
+// SearchPastes handles searching across all pastes for abuse investigations.
+// Supported query parameters: q, user, ip, language, since, until (RFC3339
+// or YYYY-MM-DD), limit and offset.
+func (h *AdminHandler) SearchPastes(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		WriteError(w, &APIError{
+			Code:    "method_not_allowed",
+			Message: "Method not allowed",
+			Status:  http.StatusMethodNotAllowed,
+		})
+		return
+	}
+
+	query := r.URL.Query()
+	filter := models.PasteSearchFilter{
+		Query:    query.Get("q"),
+		Username: query.Get("user"),
+		Language: query.Get("language"),
+		Limit:    50,
+	}
+
+	// IPs are only stored hashed, so look up the hashes from recent salt periods
+	if ip := query.Get("ip"); ip != "" {
+		filter.CreatorIPHashes = h.ipHasher.Candidates(ip)
+	}
+
+	if l := query.Get("limit"); l != "" {
+		if parsed, err := strconv.Atoi(l); err == nil && parsed > 0 && parsed <= 200 {
+			filter.Limit = parsed
+		}
+	}
+	if o := query.Get("offset"); o != "" {
+		if parsed, err := strconv.Atoi(o); err == nil && parsed >= 0 {
+			filter.Offset = parsed
+		}
+	}
+
+	for param, target := range map[string]**time.Time{"since": &filter.Since, "until": &filter.Until} {
+		value := query.Get(param)
+		if value == "" {
+			continue
+		}
+		t, err := parseSearchTime(value)
+		if err != nil {
+			WriteError(w, &APIError{
+				Code:    "invalid_" + param,
+				Message: "Invalid " + param + " timestamp, expected RFC3339 or YYYY-MM-DD",
+				Status:  http.StatusBadRequest,
+			})
+			return
+		}
+		*target = &t
+	}
+
+	pastes, total, err := h.pasteRepo.Search(filter)
+	if err != nil {
+		WriteError(w, ErrInternalServer)
+		return
+	}
+
+	items := make([]AdminPasteItem, len(pastes))
+	for i, paste := range pastes {
+		items[i] = newAdminPasteItem(paste)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"pastes": items,
+		"total":  total,
+		"limit":  filter.Limit,
+		"offset": filter.Offset,
+	})
+}
+
+// parseSearchTime accepts an RFC3339 timestamp or a plain date
+func parseSearchTime(value string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	return time.Parse("2006-01-02", value)
+}
+
+// FindDuplicatesRequest asks for pastes with the same or nearly the same
+// content as the given content, or as the content with the given SHA-256
+// digest. MaxDistance is how many bits the content fingerprints may differ
+// by, from 0 for exact duplicates up to 3; it defaults to 3.
+type FindDuplicatesRequest struct {
+	Content     string `json:"content,omitempty"`
+	SHA256      string `json:"sha256,omitempty"`
+	MaxDistance *int   `json:"max_distance,omitempty"`
+}
+
+// DuplicatePasteItem is a paste found by FindDuplicates
+type DuplicatePasteItem struct {
+	AdminPasteItem
+	Distance int  `json:"distance"` // Bits the content fingerprints differ by
+	Exact    bool `json:"exact"`    // Identical content
+}
+
+// maxDuplicateResults caps the pastes returned by FindDuplicates
+const maxDuplicateResults = 200
+
+// FindDuplicates handles finding every paste with identical or
+// near-identical content, including private and expired pastes, to trace
+// leaked credentials or spam campaigns across the instance
+func (h *AdminHandler) FindDuplicates(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		WriteError(w, &APIError{
+			Code:    "method_not_allowed",
+			Message: "Method not allowed",
+			Status:  http.StatusMethodNotAllowed,
+		})
+		return
+	}
+
+	var req FindDuplicatesRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxPasteSize+4096)).Decode(&req); err != nil {
+		WriteError(w, ErrInvalidJSON)
+		return
+	}
+
+	var errors validation.ValidationErrors
+	req.SHA256 = strings.ToLower(strings.TrimSpace(req.SHA256))
+	if (req.Content == "") == (req.SHA256 == "") {
+		errors.Add("content", "exactly one of content and sha256 is required")
+	}
+	if _, err := hex.DecodeString(req.SHA256); err != nil || (req.SHA256 != "" && len(req.SHA256) != 64) {
+		errors.Add("sha256", "must be a hex SHA-256 digest")
+	}
+	maxDistance := simhash.MaxBandedDistance
+	if req.MaxDistance != nil {
+		if *req.MaxDistance < 0 || *req.MaxDistance > simhash.MaxBandedDistance {
+			errors.Add("max_distance", "must be between 0 and "+strconv.Itoa(simhash.MaxBandedDistance))
+		}
+		maxDistance = *req.MaxDistance
+	}
+	if errors.HasErrors() {
+		WriteValidationError(w, errors)
+		return
+	}
+
+	digest := req.SHA256
+	fingerprint := simhash.Fingerprint(req.Content)
+	if req.Content != "" {
+		digest = models.ContentSHA256(req.Content)
+	} else {
+		stored, found, err := h.pasteRepo.FingerprintByContentSHA256(digest)
+		if err != nil {
+			WriteError(w, ErrInternalServer)
+			return
+		}
+		if !found {
+			writeDuplicates(w, []DuplicatePasteItem{})
+			return
+		}
+		fingerprint = stored
+	}
+
+	similar, err := h.pasteRepo.FindSimilar(fingerprint, maxDistance, maxDuplicateResults)
+	if err != nil {
+		WriteError(w, ErrInternalServer)
+		return
+	}
+
+	items := make([]DuplicatePasteItem, len(similar))
+	for i, paste := range similar {
+		items[i] = DuplicatePasteItem{
+			AdminPasteItem: newAdminPasteItem(paste.Paste),
+			Distance:       paste.Distance,
+			Exact:          paste.ContentSHA256 == digest,
+		}
+	}
+	writeDuplicates(w, items)
+}
+
+// writeDuplicates writes the pastes found by FindDuplicates
+func writeDuplicates(w http.ResponseWriter, items []DuplicatePasteItem) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"pastes": items,
+		"total":  len(items),
+	})
+}
+
+// ListDeadJobs handles listing background jobs that exhausted their retries
+func (h *AdminHandler) ListDeadJobs(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		WriteError(w, &APIError{
+			Code:    "method_not_allowed",
+			Message: "Method not allowed",
+			Status:  http.StatusMethodNotAllowed,
+		})
+		return
+	}
+
+	limit, offset := 50, 0
+	if l := r.URL.Query().Get("limit"); l != "" {
+		if parsed, err := strconv.Atoi(l); err == nil && parsed > 0 && parsed <= 200 {
+			limit = parsed
+		}
+	}
+	if o := r.URL.Query().Get("offset"); o != "" {
+		if parsed, err := strconv.Atoi(o); err == nil && parsed >= 0 {
+			offset = parsed
+		}
+	}
+
+	jobs, err := h.jobRepo.ListDead(limit, offset)
+	if err != nil {
+		WriteError(w, ErrInternalServer)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"jobs":   jobs,
+		"limit":  limit,
+		"offset": offset,
+	})
+}
+
+// RetryJob handles requeueing a dead background job
+func (h *AdminHandler) RetryJob(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		WriteError(w, &APIError{
+			Code:    "method_not_allowed",
+			Message: "Method not allowed",
+			Status:  http.StatusMethodNotAllowed,
+		})
+		return
+	}
+
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		WriteError(w, &APIError{
+			Code:    "invalid_id",
+			Message: "Invalid job ID",
+			Status:  http.StatusBadRequest,
+		})
+		return
+	}
+
+	retried, err := h.jobRepo.Retry(id)
+	if err != nil {
+		WriteError(w, ErrInternalServer)
+		return
+	}
+	if !retried {
+		WriteError(w, &APIError{
+			Code:    "job_not_found",
+			Message: "Dead job not found",
+			Status:  http.StatusNotFound,
+		})
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
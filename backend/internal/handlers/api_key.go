@@ -0,0 +1,192 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"slices"
+	"strconv"
+
+	"github.com/LonleySailor/privatepaste/backend/internal/auth"
+	"github.com/LonleySailor/privatepaste/backend/internal/middleware"
+	"github.com/LonleySailor/privatepaste/backend/internal/models"
+	"github.com/LonleySailor/privatepaste/backend/pkg/validation"
+	"github.com/gorilla/mux"
+)
+
+// maxAPIKeysPerUser limits how many keys one account may hold
+const maxAPIKeysPerUser = 20
+
+// APIKeyStore manages users' API keys
+type APIKeyStore interface {
+	Create(userID int, name string, scopes []string) (string, *models.APIKey, error)
+	ListByUserID(userID int) ([]*models.APIKey, error)
+	Delete(userID int, id int64) (bool, error)
+}
+
+// APIKeyHandler handles managing the authenticated user's API keys
+type APIKeyHandler struct {
+	keys      APIKeyStore
+	validator *validation.Validator
+}
+
+// NewAPIKeyHandler creates a new API key handler
+func NewAPIKeyHandler(keys APIKeyStore, validator *validation.Validator) *APIKeyHandler {
+	return &APIKeyHandler{keys: keys, validator: validator}
+}
+
+// CreateAPIKeyRequest represents a request to create an API key. Without
+// scopes the key may do everything API keys can.
+type CreateAPIKeyRequest struct {
+	Name   string   `json:"name"`
+	Scopes []string `json:"scopes,omitempty"`
+}
+
+// CreateAPIKeyResponse includes the key itself, which is never shown again
+type CreateAPIKeyResponse struct {
+	*models.APIKey
+	Key string `json:"key"`
+}
+
+// Create handles creating a new API key
+func (h *APIKeyHandler) Create(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		WriteError(w, &APIError{
+			Code:    "method_not_allowed",
+			Message: "Method not allowed",
+			Status:  http.StatusMethodNotAllowed,
+		})
+		return
+	}
+
+	userID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		WriteError(w, &APIError{
+			Code:    "unauthorized",
+			Message: "User ID not found in token",
+			Status:  http.StatusUnauthorized,
+		})
+		return
+	}
+
+	var req CreateAPIKeyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		WriteError(w, ErrInvalidJSON)
+		return
+	}
+	var errors validation.ValidationErrors
+	if err := h.validator.ValidateString(req.Name, "name", true, 1, 100); err != nil {
+		errors.Add(err.Field, err.Message)
+	}
+	for _, scope := range req.Scopes {
+		if !slices.Contains(auth.APIKeyScopes, scope) {
+			errors.Add("scopes", "Unknown scope "+strconv.Quote(scope))
+		}
+	}
+	if errors.HasErrors() {
+		WriteValidationError(w, errors)
+		return
+	}
+
+	existing, err := h.keys.ListByUserID(userID)
+	if err != nil {
+		WriteRepositoryError(w, err)
+		return
+	}
+	if len(existing) >= maxAPIKeysPerUser {
+		WriteValidationError(w, []validation.ValidationError{{Field: "name", Message: "API key limit reached; revoke an unused key first"}})
+		return
+	}
+
+	key, apiKey, err := h.keys.Create(userID, req.Name, req.Scopes)
+	if err != nil {
+		WriteRepositoryError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(CreateAPIKeyResponse{APIKey: apiKey, Key: key})
+}
+
+// List handles listing the authenticated user's API keys
+func (h *APIKeyHandler) List(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		WriteError(w, &APIError{
+			Code:    "method_not_allowed",
+			Message: "Method not allowed",
+			Status:  http.StatusMethodNotAllowed,
+		})
+		return
+	}
+
+	userID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		WriteError(w, &APIError{
+			Code:    "unauthorized",
+			Message: "User ID not found in token",
+			Status:  http.StatusUnauthorized,
+		})
+		return
+	}
+
+	keys, err := h.keys.ListByUserID(userID)
+	if err != nil {
+		WriteRepositoryError(w, err)
+		return
+	}
+	if keys == nil {
+		keys = []*models.APIKey{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{"keys": keys})
+}
+
+// Delete handles revoking one of the authenticated user's API keys
+func (h *APIKeyHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		WriteError(w, &APIError{
+			Code:    "method_not_allowed",
+			Message: "Method not allowed",
+			Status:  http.StatusMethodNotAllowed,
+		})
+		return
+	}
+
+	userID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		WriteError(w, &APIError{
+			Code:    "unauthorized",
+			Message: "User ID not found in token",
+			Status:  http.StatusUnauthorized,
+		})
+		return
+	}
+
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		WriteError(w, &APIError{
+			Code:    "invalid_id",
+			Message: "Invalid API key ID",
+			Status:  http.StatusBadRequest,
+		})
+		return
+	}
+
+	deleted, err := h.keys.Delete(userID, id)
+	if err != nil {
+		WriteRepositoryError(w, err)
+		return
+	}
+	if !deleted {
+		WriteError(w, &APIError{
+			Code:    "api_key_not_found",
+			Message: "API key not found",
+			Status:  http.StatusNotFound,
+		})
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
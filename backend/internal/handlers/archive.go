@@ -0,0 +1,100 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/LonleySailor/privatepaste/backend/internal/middleware"
+	"github.com/LonleySailor/privatepaste/backend/internal/models"
+	"github.com/gorilla/mux"
+)
+
+// Archive page sizes
+const (
+	defaultArchivePageSize = 100
+	maxArchivePageSize     = 500
+)
+
+// ArchiveHandler serves the archive of public pastes by month, for
+// researchers and mirrors walking the whole history
+type ArchiveHandler struct {
+	pasteRepo *models.PasteRepository
+	now       func() time.Time
+}
+
+// NewArchiveHandler creates a new archive handler
+func NewArchiveHandler(pasteRepo *models.PasteRepository) *ArchiveHandler {
+	return &ArchiveHandler{pasteRepo: pasteRepo, now: time.Now}
+}
+
+// ArchiveResponse represents a page of the public pastes created in a month
+type ArchiveResponse struct {
+	Year   int               `json:"year"`
+	Month  int               `json:"month"`
+	Pastes []PublicPasteItem `json:"pastes"`
+	Next   string            `json:"next,omitempty"` // Pass as after to fetch the next page; empty on the last page
+}
+
+// GetMonth handles listing the public pastes created in the month named in
+// the path (UTC), in ID order. Pages are addressed by the after query
+// parameter rather than an offset, so they stay stable while clients walk
+// them. Once a month is over its pages are cached for a day, tagged with its
+// surrogate key so deleting or expiring one of its pastes purges them.
+func (h *ArchiveHandler) GetMonth(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		WriteError(w, &APIError{
+			Code:    "method_not_allowed",
+			Message: "Method not allowed",
+			Status:  http.StatusMethodNotAllowed,
+		})
+		return
+	}
+
+	vars := mux.Vars(r)
+	start, err := time.Parse("2006/01", vars["year"]+"/"+vars["month"])
+	now := h.now().UTC()
+	if err != nil || start.After(now) {
+		WriteError(w, &APIError{
+			Code:    "archive_not_found",
+			Message: "No archive for this month",
+			Status:  http.StatusNotFound,
+		})
+		return
+	}
+	end := start.AddDate(0, 1, 0)
+
+	query := r.URL.Query()
+	limit := defaultArchivePageSize
+	if l, err := strconv.Atoi(query.Get("limit")); err == nil && l > 0 && l <= maxArchivePageSize {
+		limit = l
+	}
+
+	pastes, err := h.pasteRepo.ListPublicCreatedBetween(start, end, query.Get("after"), limit)
+	if err != nil {
+		WriteRepositoryError(w, err)
+		return
+	}
+
+	response := ArchiveResponse{
+		Year:   start.Year(),
+		Month:  int(start.Month()),
+		Pastes: make([]PublicPasteItem, len(pastes)),
+	}
+	for i, paste := range pastes {
+		response.Pastes[i] = PublicPasteItem{PasteListItem: newPasteListItem(paste.Paste, false), Username: paste.Username}
+	}
+	if len(pastes) == limit {
+		response.Next = pastes[len(pastes)-1].ID
+	}
+
+	// No new pastes join a month that is over, though its pastes can still
+	// expire or be deleted
+	if !end.After(now) {
+		w.Header().Set("Cache-Control", middleware.CachePublicDay)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
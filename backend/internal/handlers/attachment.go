@@ -0,0 +1,398 @@
+package handlers
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/json"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+
+	"github.com/LonleySailor/privatepaste/backend/internal/middleware"
+	"github.com/LonleySailor/privatepaste/backend/internal/models"
+	"github.com/LonleySailor/privatepaste/backend/internal/services"
+	"github.com/LonleySailor/privatepaste/backend/pkg/validation"
+	"github.com/gorilla/mux"
+)
+
+// AttachmentStore stores the files attached to pastes
+type AttachmentStore interface {
+	Put(attachment *models.Attachment, data []byte) error
+	Get(pasteID, name string) (*models.Attachment, []byte, error)
+	List(pasteID string) ([]*models.Attachment, error)
+	Delete(pasteID, name string) (bool, error)
+}
+
+// AttachmentScanner checks uploaded attachments for malware, returning the
+// name of the threat found or "" if the data is clean
+type AttachmentScanner interface {
+	Scan(ctx context.Context, data []byte) (string, error)
+}
+
+// AttachmentLimits bound the files attached to each paste
+type AttachmentLimits struct {
+	MaxSize  int64 `json:"max_size"`  // Largest attachment in bytes
+	MaxCount int   `json:"max_count"` // Most attachments on one paste
+}
+
+// attachmentTypes are the sniffed content types accepted as attachments
+var attachmentTypes = []string{
+	"image/png", "image/jpeg", "image/gif", "image/webp",
+	"application/pdf", "application/zip", "application/x-gzip",
+	"text/plain; charset=utf-8",
+}
+
+// Errors specific to attachments
+var (
+	ErrAttachmentNotFound = &APIError{
+		Code:    "attachment_not_found",
+		Message: "Attachment not found",
+		Status:  http.StatusNotFound,
+	}
+
+	ErrAttachmentsDisabled = &APIError{
+		Code:    "attachments_disabled",
+		Message: "Attachments are not enabled on this server",
+		Status:  http.StatusNotFound,
+	}
+)
+
+// AttachmentInfo describes a file attached to a paste
+type AttachmentInfo struct {
+	Name        string `json:"name"`
+	ContentType string `json:"content_type"`
+	Size        int64  `json:"size"`
+	SHA256      string `json:"sha256"`
+	URL         string `json:"url"`
+}
+
+// newAttachmentInfo builds the API representation of an attachment
+func newAttachmentInfo(attachment *models.Attachment) AttachmentInfo {
+	return AttachmentInfo{
+		Name:        attachment.Name,
+		ContentType: attachment.ContentType,
+		Size:        attachment.Size,
+		SHA256:      attachment.SHA256,
+		URL:         "/api/paste/" + attachment.PasteID + "/attachments/" + url.PathEscape(attachment.Name),
+	}
+}
+
+// SetAttachments enables attaching files to pastes within the given limits
+func (h *PasteHandler) SetAttachments(store AttachmentStore, limits AttachmentLimits) {
+	h.attachments = store
+	h.attachmentLimits = limits
+}
+
+// SetAttachmentScanner enables scanning uploaded attachments for malware.
+// Uploads are refused while the scanner is unavailable.
+func (h *PasteHandler) SetAttachmentScanner(scanner AttachmentScanner) {
+	h.attachmentScanner = scanner
+}
+
+// listAttachments describes a paste's attachments, or returns nil when
+// attachments are disabled
+func (h *PasteHandler) listAttachments(paste *models.Paste) ([]AttachmentInfo, error) {
+	if h.attachments == nil {
+		return nil, nil
+	}
+	attachments, err := h.attachments.List(paste.ID)
+	if err != nil {
+		return nil, err
+	}
+	var infos []AttachmentInfo
+	for _, attachment := range attachments {
+		infos = append(infos, newAttachmentInfo(attachment))
+	}
+	return infos, nil
+}
+
+// UploadAttachment handles attaching the file in the request body to one of
+// the current user's pastes, replacing any attachment of the same name. The
+// user's content policy must allow attachments.
+func (h *PasteHandler) UploadAttachment(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut {
+		WriteError(w, &APIError{
+			Code:    "method_not_allowed",
+			Message: "Method not allowed",
+			Status:  http.StatusMethodNotAllowed,
+		})
+		return
+	}
+
+	paste, name, ok := h.loadOwnedAttachmentPaste(w, r)
+	if !ok {
+		return
+	}
+
+	policy, err := h.creator.Policy(requestRole(r))
+	if err != nil {
+		WriteRepositoryError(w, err)
+		return
+	}
+	if err := services.CheckAttachment(policy); err != nil {
+		writePolicyViolation(w, err)
+		return
+	}
+
+	data, err := io.ReadAll(http.MaxBytesReader(w, r.Body, h.attachmentLimits.MaxSize))
+	if err != nil {
+		WriteBodyError(w, err, ErrContentTooLarge)
+		return
+	}
+	if len(data) == 0 {
+		WriteValidationError(w, []validation.ValidationError{{Field: "body", Message: "must not be empty"}})
+		return
+	}
+
+	contentType := http.DetectContentType(data)
+	if !slices.Contains(attachmentTypes, contentType) {
+		WriteError(w, &APIError{
+			Code:    "unsupported_attachment",
+			Message: "Attachments must be PNG, JPEG, GIF or WebP images, PDF documents, zip or gzip archives, or UTF-8 text",
+			Status:  http.StatusUnsupportedMediaType,
+		})
+		return
+	}
+
+	existing, err := h.attachments.List(paste.ID)
+	if err != nil {
+		WriteRepositoryError(w, err)
+		return
+	}
+	replacing := slices.ContainsFunc(existing, func(a *models.Attachment) bool { return a.Name == name })
+	if !replacing && len(existing) >= h.attachmentLimits.MaxCount {
+		WriteError(w, &APIError{
+			Code:    "too_many_attachments",
+			Message: "A paste may have at most " + strconv.Itoa(h.attachmentLimits.MaxCount) + " attachments",
+			Status:  http.StatusConflict,
+		})
+		return
+	}
+
+	if h.attachmentScanner != nil {
+		threat, err := h.attachmentScanner.Scan(r.Context(), data)
+		if err != nil {
+			WriteError(w, &APIError{
+				Code:    "scanner_unavailable",
+				Message: "Attachments cannot be scanned right now; try again later",
+				Status:  http.StatusServiceUnavailable,
+			})
+			return
+		}
+		if threat != "" {
+			WriteError(w, &APIError{
+				Code:    "attachment_infected",
+				Message: "Attachment was rejected by the virus scanner: " + threat,
+				Status:  http.StatusUnprocessableEntity,
+			})
+			return
+		}
+	}
+
+	attachment := &models.Attachment{PasteID: paste.ID, Name: name, ContentType: contentType}
+	if err := h.attachments.Put(attachment, data); err != nil {
+		WriteRepositoryError(w, err)
+		return
+	}
+
+	status := http.StatusCreated
+	if replacing {
+		status = http.StatusOK
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(newAttachmentInfo(attachment))
+}
+
+// DeleteAttachment handles removing an attachment from one of the current
+// user's pastes
+func (h *PasteHandler) DeleteAttachment(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		WriteError(w, &APIError{
+			Code:    "method_not_allowed",
+			Message: "Method not allowed",
+			Status:  http.StatusMethodNotAllowed,
+		})
+		return
+	}
+
+	paste, name, ok := h.loadOwnedAttachmentPaste(w, r)
+	if !ok {
+		return
+	}
+
+	found, err := h.attachments.Delete(paste.ID, name)
+	if err != nil {
+		WriteRepositoryError(w, err)
+		return
+	}
+	if !found {
+		WriteError(w, ErrAttachmentNotFound)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GetAttachment handles downloading a paste's attachment. It is subject to
+// the same checks as viewing the paste, and always served as a download so
+// browsers never render it in the instance's origin.
+func (h *PasteHandler) GetAttachment(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		WriteError(w, &APIError{
+			Code:    "method_not_allowed",
+			Message: "Method not allowed",
+			Status:  http.StatusMethodNotAllowed,
+		})
+		return
+	}
+
+	if h.attachments == nil {
+		WriteError(w, ErrAttachmentsDisabled)
+		return
+	}
+
+	paste, ok := h.loadViewablePaste(w, r)
+	if !ok || !h.checkSignedURL(w, r, paste) {
+		return
+	}
+
+	attachment, data, err := h.attachments.Get(paste.ID, mux.Vars(r)["name"])
+	if err != nil {
+		WriteRepositoryError(w, err)
+		return
+	}
+	if attachment == nil {
+		WriteError(w, ErrAttachmentNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", attachment.ContentType)
+	w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": attachment.Name}))
+	w.Header().Set("Content-Security-Policy", "sandbox")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("ETag", `"`+attachment.SHA256+`"`)
+	if paste.HasPassword() || paste.Visibility == models.VisibilityPrivate {
+		w.Header().Set("Cache-Control", "private, no-store")
+	}
+	w.WriteHeader(http.StatusOK)
+	w.Write(data)
+}
+
+// loadOwnedAttachmentPaste loads the paste named in the URL for changing its
+// attachments, checking that attachments are enabled, the attachment name is
+// valid and the current user owns the paste. On failure it writes the error
+// response and returns false.
+func (h *PasteHandler) loadOwnedAttachmentPaste(w http.ResponseWriter, r *http.Request) (*models.Paste, string, bool) {
+	if h.attachments == nil {
+		WriteError(w, ErrAttachmentsDisabled)
+		return nil, "", false
+	}
+
+	userID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		WriteError(w, &APIError{
+			Code:    "unauthorized",
+			Message: "Authentication required",
+			Status:  http.StatusUnauthorized,
+		})
+		return nil, "", false
+	}
+
+	vars := mux.Vars(r)
+	if err := h.validator.ValidateID(vars["id"]); err != nil {
+		WriteError(w, &APIError{
+			Code:    "invalid_id",
+			Message: "Invalid paste ID format",
+			Status:  http.StatusBadRequest,
+		})
+		return nil, "", false
+	}
+	name := vars["name"]
+	if err := h.validator.ValidateAttachmentName(name); err != nil {
+		WriteValidationError(w, []validation.ValidationError{*err})
+		return nil, "", false
+	}
+
+	paste, err := h.pasteRepo.GetByID(vars["id"])
+	if err != nil {
+		WriteRepositoryError(w, err)
+		return nil, "", false
+	}
+	if paste == nil {
+		WriteError(w, ErrPasteNotFound)
+		return nil, "", false
+	}
+	if paste.UserID == nil || *paste.UserID != userID {
+		WriteError(w, &APIError{
+			Code:    "forbidden",
+			Message: "You can only change the attachments of your own pastes",
+			Status:  http.StatusForbidden,
+		})
+		return nil, "", false
+	}
+	if paste.IsExpired() {
+		WriteError(w, ErrPasteExpired)
+		return nil, "", false
+	}
+	return paste, name, true
+}
+
+// GetArchive handles downloading a paste together with its attachments as a
+// zip archive. The content is named after the paste and its language, the
+// attachments keep their names under attachments/, and every file carries
+// the time it was created or attached.
+func (h *PasteHandler) GetArchive(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		WriteError(w, &APIError{
+			Code:    "method_not_allowed",
+			Message: "Method not allowed",
+			Status:  http.StatusMethodNotAllowed,
+		})
+		return
+	}
+
+	paste, ok := h.loadViewablePaste(w, r)
+	if !ok || !h.checkSignedURL(w, r, paste) {
+		return
+	}
+
+	var attachments []*models.Attachment
+	if h.attachments != nil {
+		var err error
+		if attachments, err = h.attachments.List(paste.ID); err != nil {
+			WriteRepositoryError(w, err)
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", `attachment; filename="`+paste.ID+`.zip"`)
+	if paste.HasPassword() || paste.Visibility == models.VisibilityPrivate {
+		w.Header().Set("Cache-Control", "private, no-store")
+	}
+	w.WriteHeader(http.StatusOK)
+
+	// Each file is flushed as it is written, so attachments are never all
+	// held in memory at once
+	archive := &zipArchive{zw: zip.NewWriter(w)}
+	if err := archive.add(paste.ID+exportExtension(paste), paste.CreatedAt, []byte(paste.Content)); err != nil {
+		return
+	}
+	for _, attachment := range attachments {
+		found, data, err := h.attachments.Get(paste.ID, attachment.Name)
+		if err != nil {
+			return // The client sees a truncated archive
+		}
+		if found == nil {
+			continue // Deleted while the archive was being written
+		}
+		if err := archive.add("attachments/"+found.Name, found.CreatedAt, data); err != nil {
+			return
+		}
+	}
+	archive.Close()
+}
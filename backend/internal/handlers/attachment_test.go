@@ -0,0 +1,114 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/LonleySailor/privatepaste/backend/internal/models"
+	"github.com/LonleySailor/privatepaste/backend/pkg/utils"
+	"github.com/gorilla/mux"
+)
+
+// stubAttachmentStore keeps attachments in memory, keyed by paste ID and name
+type stubAttachmentStore map[string]*stubAttachment
+
+type stubAttachment struct {
+	attachment *models.Attachment
+	data       []byte
+}
+
+func (s stubAttachmentStore) Put(attachment *models.Attachment, data []byte) error {
+	attachment.Size = int64(len(data))
+	s[attachment.PasteID+"/"+attachment.Name] = &stubAttachment{attachment, data}
+	return nil
+}
+
+func (s stubAttachmentStore) Get(pasteID, name string) (*models.Attachment, []byte, error) {
+	if a, ok := s[pasteID+"/"+name]; ok {
+		return a.attachment, a.data, nil
+	}
+	return nil, nil, nil
+}
+
+func (s stubAttachmentStore) List(pasteID string) ([]*models.Attachment, error) {
+	var attachments []*models.Attachment
+	for _, a := range s {
+		if a.attachment.PasteID == pasteID {
+			attachments = append(attachments, a.attachment)
+		}
+	}
+	return attachments, nil
+}
+
+func (s stubAttachmentStore) Delete(pasteID, name string) (bool, error) {
+	_, ok := s[pasteID+"/"+name]
+	delete(s, pasteID+"/"+name)
+	return ok, nil
+}
+
+// uploadAttachment runs the upload handler as the given user and role
+func uploadAttachment(handler *PasteHandler, id, name string, userID int, role string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("PUT", "/api/paste/"+id+"/attachments/"+name, bytes.NewBufferString("notes"))
+	req = mux.SetURLVars(req, map[string]string{"id": id, "name": name})
+	ctx := context.WithValue(req.Context(), "userID", userID)
+	req = req.WithContext(context.WithValue(ctx, "role", role))
+
+	rr := httptest.NewRecorder()
+	handler.UploadAttachment(rr, req)
+	return rr
+}
+
+func TestUploadAttachment_ContentPolicy(t *testing.T) {
+	handler, repo := setupTestHandler()
+	handler.SetAttachments(stubAttachmentStore{}, AttachmentLimits{MaxSize: 1 << 20, MaxCount: 5})
+	handler.SetContentPolicies(stubContentPolicies{
+		models.RoleUser: {Role: models.RoleUser, AllowPublic: true, AllowAttachments: false},
+	})
+
+	userID := 7
+	repo.Create(&models.Paste{ID: "att123", Content: "x", UserID: &userID})
+
+	rr := uploadAttachment(handler, "att123", "notes.txt", userID, models.RoleUser)
+	if rr.Code != http.StatusForbidden {
+		t.Errorf("Expected status %d when attachments are not allowed, got %d: %s", http.StatusForbidden, rr.Code, rr.Body.String())
+	}
+
+	rr = uploadAttachment(handler, "att123", "notes.txt", userID, models.RoleAdmin)
+	if rr.Code != http.StatusCreated {
+		t.Errorf("Expected status %d for a role allowed attachments, got %d: %s", http.StatusCreated, rr.Code, rr.Body.String())
+	}
+}
+
+func TestGetAttachment_SignedURLs(t *testing.T) {
+	handler, repo := setupTestHandler()
+	store := stubAttachmentStore{}
+	handler.SetAttachments(store, AttachmentLimits{MaxSize: 1 << 20, MaxCount: 5})
+	signer := utils.NewURLSigner("test-secret")
+	handler.SetURLSigner(signer)
+
+	repo.Create(&models.Paste{ID: "att123", Content: "x", RequireSignedURLs: true})
+	store.Put(&models.Attachment{PasteID: "att123", Name: "notes.txt", ContentType: "text/plain; charset=utf-8"}, []byte("notes"))
+
+	get := func(query string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/api/paste/att123/attachments/notes.txt"+query, nil)
+		req = mux.SetURLVars(req, map[string]string{"id": "att123", "name": "notes.txt"})
+		rr := httptest.NewRecorder()
+		handler.GetAttachment(rr, req)
+		return rr
+	}
+
+	if rr := get(""); rr.Code != http.StatusForbidden {
+		t.Errorf("Expected status %d without a signature, got %d", http.StatusForbidden, rr.Code)
+	}
+
+	expires := time.Now().Add(time.Hour)
+	rr := get(fmt.Sprintf("?expires=%d&signature=%s", expires.Unix(), signer.Sign("att123", expires)))
+	if rr.Code != http.StatusOK || rr.Body.String() != "notes" {
+		t.Errorf("Expected the attachment with a signature, got %d: %s", rr.Code, rr.Body.String())
+	}
+}
@@ -0,0 +1,175 @@
+package handlers
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/LonleySailor/privatepaste/backend/internal/middleware"
+	"github.com/LonleySailor/privatepaste/backend/internal/models"
+	"github.com/LonleySailor/privatepaste/backend/pkg/render"
+	"github.com/gorilla/mux"
+)
+
+// maxAvatarUploadSize is the largest image accepted for resizing
+const maxAvatarUploadSize = 2 << 20
+
+// AvatarLookup finds the digest of a user's avatar
+type AvatarLookup interface {
+	GetHash(userID int) (string, error)
+}
+
+// AvatarURL returns the path an avatar is served from, or "" for none
+func AvatarURL(hash string) string {
+	if hash == "" {
+		return ""
+	}
+	return "/api/avatars/" + hash
+}
+
+// AvatarHandler handles uploading and serving user avatars. Uploads are
+// resized server-side and served from URLs naming their content, so
+// responses never change and cache forever.
+type AvatarHandler struct {
+	avatars *models.AvatarRepository
+}
+
+// NewAvatarHandler creates a new avatar handler
+func NewAvatarHandler(avatars *models.AvatarRepository) *AvatarHandler {
+	return &AvatarHandler{avatars: avatars}
+}
+
+// AvatarResponse represents the current user's avatar
+type AvatarResponse struct {
+	AvatarURL string `json:"avatar_url"`
+}
+
+// Upload handles replacing the current user's avatar with the PNG, JPEG or
+// GIF image in the request body
+func (h *AvatarHandler) Upload(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut {
+		WriteError(w, &APIError{
+			Code:    "method_not_allowed",
+			Message: "Method not allowed",
+			Status:  http.StatusMethodNotAllowed,
+		})
+		return
+	}
+
+	userID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		WriteError(w, &APIError{
+			Code:    "unauthorized",
+			Message: "User ID not found in token",
+			Status:  http.StatusUnauthorized,
+		})
+		return
+	}
+
+	data, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxAvatarUploadSize))
+	if err != nil {
+		WriteBodyError(w, err, ErrContentTooLarge)
+		return
+	}
+	image, err := render.ResizeAvatar(data)
+	if err == render.ErrUnsupportedAvatar {
+		WriteError(w, &APIError{
+			Code:    "unsupported_avatar",
+			Message: err.Error(),
+			Status:  http.StatusUnsupportedMediaType,
+		})
+		return
+	}
+	if err != nil {
+		WriteError(w, ErrInternalServer)
+		return
+	}
+
+	hash, err := h.avatars.Set(userID, image)
+	if err != nil {
+		WriteError(w, ErrInternalServer)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(AvatarResponse{AvatarURL: AvatarURL(hash)})
+}
+
+// Delete handles removing the current user's avatar
+func (h *AvatarHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		WriteError(w, &APIError{
+			Code:    "method_not_allowed",
+			Message: "Method not allowed",
+			Status:  http.StatusMethodNotAllowed,
+		})
+		return
+	}
+
+	userID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		WriteError(w, &APIError{
+			Code:    "unauthorized",
+			Message: "User ID not found in token",
+			Status:  http.StatusUnauthorized,
+		})
+		return
+	}
+
+	found, err := h.avatars.Delete(userID)
+	if err != nil {
+		WriteError(w, ErrInternalServer)
+		return
+	}
+	if !found {
+		WriteError(w, ErrAvatarNotFound)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Get handles serving an avatar by its lowercase hex SHA-256 digest
+func (h *AvatarHandler) Get(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		WriteError(w, &APIError{
+			Code:    "method_not_allowed",
+			Message: "Method not allowed",
+			Status:  http.StatusMethodNotAllowed,
+		})
+		return
+	}
+
+	hash := mux.Vars(r)["hash"]
+	if digest, err := hex.DecodeString(hash); err != nil || len(digest) != 32 || hex.EncodeToString(digest) != hash {
+		WriteError(w, ErrAvatarNotFound)
+		return
+	}
+
+	etag := `"` + hash + `"`
+	if r.Header.Get("If-None-Match") == etag {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	image, err := h.avatars.GetByHash(hash)
+	if err != nil {
+		WriteRepositoryError(w, err)
+		return
+	}
+	if image == nil {
+		WriteError(w, ErrAvatarNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "image/png")
+	w.Header().Set("Content-Length", strconv.Itoa(len(image)))
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("ETag", etag)
+	w.WriteHeader(http.StatusOK)
+	if r.Method == http.MethodGet {
+		w.Write(image)
+	}
+}
@@ -0,0 +1,91 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"net/http"
+	"strconv"
+
+	"github.com/LonleySailor/privatepaste/backend/internal/middleware"
+	"github.com/LonleySailor/privatepaste/backend/internal/models"
+	"github.com/LonleySailor/privatepaste/backend/pkg/utils"
+	"github.com/gorilla/mux"
+)
+
+// BlobLookup finds paste content by its SHA-256 digest
+type BlobLookup interface {
+	GetByContentHash(hash string) (*models.Paste, error)
+}
+
+// BlobHandler serves paste content addressed by its SHA-256 digest. Since
+// the URL names the content, responses never change and cache forever.
+type BlobHandler struct {
+	blobs          BlobLookup
+	responseSigner *utils.ResponseSigner
+}
+
+// NewBlobHandler creates a new blob handler
+func NewBlobHandler(blobs BlobLookup) *BlobHandler {
+	return &BlobHandler{blobs: blobs}
+}
+
+// SetResponseSigner enables signing served content
+func (h *BlobHandler) SetResponseSigner(signer *utils.ResponseSigner) {
+	h.responseSigner = signer
+}
+
+// GetBlob handles retrieving content by its lowercase hex SHA-256 digest.
+// Only content of a paste anyone with its link could read is served, so a
+// hash cannot be used to confirm the content of private or protected pastes.
+// The Content-Digest header lets clients verify what they received.
+func (h *BlobHandler) GetBlob(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		WriteError(w, &APIError{
+			Code:    "method_not_allowed",
+			Message: "Method not allowed",
+			Status:  http.StatusMethodNotAllowed,
+		})
+		return
+	}
+
+	hash := mux.Vars(r)["hash"]
+	digest, err := hex.DecodeString(hash)
+	if err != nil || len(digest) != 32 || hex.EncodeToString(digest) != hash {
+		WriteError(w, &APIError{
+			Code:    "invalid_hash",
+			Message: "Hash must be a lowercase hex SHA-256 digest",
+			Status:  http.StatusBadRequest,
+		})
+		return
+	}
+
+	etag := `"` + hash + `"`
+	if r.Header.Get("If-None-Match") == etag {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	paste, err := h.blobs.GetByContentHash(hash)
+	if err != nil {
+		WriteRepositoryError(w, err)
+		return
+	}
+	if paste == nil {
+		WriteError(w, ErrBlobNotFound)
+		return
+	}
+	if paste.DoNotTrack {
+		middleware.SuppressAccessLog(r)
+	}
+	setRobotsHeader(w, paste)
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Header().Set("Content-Length", strconv.Itoa(len(paste.Content)))
+	w.Header().Set("Content-Digest", "sha-256=:"+base64.StdEncoding.EncodeToString(digest)+":")
+	w.Header().Set("ETag", etag)
+	signContent(w, h.responseSigner, paste.ID, paste.Content)
+	w.WriteHeader(http.StatusOK)
+	if r.Method == http.MethodGet {
+		w.Write([]byte(paste.Content))
+	}
+}
@@ -0,0 +1,47 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/LonleySailor/privatepaste/backend/internal/models"
+	"github.com/gorilla/mux"
+)
+
+func TestGetBlob(t *testing.T) {
+	pasteRepo := models.NewMemoryPasteRepository()
+	pasteRepo.Create(&models.Paste{ID: "blob01", Content: "shared content", Visibility: models.VisibilityUnlisted})
+	pasteRepo.Create(&models.Paste{ID: "blob02", Content: "secret content", Visibility: models.VisibilityPrivate})
+	handler := NewBlobHandler(pasteRepo)
+
+	get := func(hash, etag string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest("GET", "/api/blob/"+hash, nil)
+		if etag != "" {
+			r.Header.Set("If-None-Match", etag)
+		}
+		r = mux.SetURLVars(r, map[string]string{"hash": hash})
+		rr := httptest.NewRecorder()
+		handler.GetBlob(rr, r)
+		return rr
+	}
+
+	hash := models.ContentSHA256("shared content")
+	rr := get(hash, "")
+	if rr.Code != http.StatusOK || rr.Body.String() != "shared content" {
+		t.Fatalf("Expected the content for its hash, got %d %q", rr.Code, rr.Body.String())
+	}
+	if rr.Header().Get("ETag") != `"`+hash+`"` || rr.Header().Get("Content-Digest") == "" {
+		t.Errorf("Expected ETag and Content-Digest headers, got %v", rr.Header())
+	}
+
+	if rr := get(hash, `"`+hash+`"`); rr.Code != http.StatusNotModified {
+		t.Errorf("Expected 304 for a matching ETag, got %d", rr.Code)
+	}
+	if rr := get(models.ContentSHA256("secret content"), ""); rr.Code != http.StatusNotFound {
+		t.Errorf("Expected private content not to be served by hash, got %d", rr.Code)
+	}
+	if rr := get("ABC", ""); rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected a malformed hash to be rejected, got %d", rr.Code)
+	}
+}
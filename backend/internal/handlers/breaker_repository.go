@@ -0,0 +1,78 @@
+package handlers
+
+import (
+	"github.com/LonleySailor/privatepaste/backend/internal/database"
+	"github.com/LonleySailor/privatepaste/backend/internal/models"
+)
+
+// breakerPasteRepository routes every paste repository call through a
+// circuit breaker so sustained database failures fail fast
+type breakerPasteRepository struct {
+	repo    PasteRepositoryInterface
+	breaker *database.Breaker
+}
+
+// NewBreakerPasteRepository wraps repo with the given circuit breaker
+func NewBreakerPasteRepository(repo PasteRepositoryInterface, breaker *database.Breaker) PasteRepositoryInterface {
+	return &breakerPasteRepository{repo: repo, breaker: breaker}
+}
+
+func (r *breakerPasteRepository) Create(paste *models.Paste) error {
+	return r.breaker.Do(func() error { return r.repo.Create(paste) })
+}
+
+func (r *breakerPasteRepository) GetByID(id string) (paste *models.Paste, err error) {
+	err = r.breaker.Do(func() error {
+		paste, err = r.repo.GetByID(id)
+		return err
+	})
+	return paste, err
+}
+
+func (r *breakerPasteRepository) Exists(id string) (exists bool, err error) {
+	err = r.breaker.Do(func() error {
+		exists, err = r.repo.Exists(id)
+		return err
+	})
+	return exists, err
+}
+
+func (r *breakerPasteRepository) Delete(id string) error {
+	return r.breaker.Do(func() error { return r.repo.Delete(id) })
+}
+
+func (r *breakerPasteRepository) GetByUserID(userID int, limit, offset int) (pastes []*models.Paste, err error) {
+	err = r.breaker.Do(func() error {
+		pastes, err = r.repo.GetByUserID(userID, limit, offset)
+		return err
+	})
+	return pastes, err
+}
+
+func (r *breakerPasteRepository) Update(paste *models.Paste) error {
+	return r.breaker.Do(func() error { return r.repo.Update(paste) })
+}
+
+func (r *breakerPasteRepository) DeleteExpired() (count int64, err error) {
+	err = r.breaker.Do(func() error {
+		count, err = r.repo.DeleteExpired()
+		return err
+	})
+	return count, err
+}
+
+func (r *breakerPasteRepository) CountByUserID(userID int) (count int, err error) {
+	err = r.breaker.Do(func() error {
+		count, err = r.repo.CountByUserID(userID)
+		return err
+	})
+	return count, err
+}
+
+func (r *breakerPasteRepository) RecordFailedUnlock(id string) (destroyed bool, err error) {
+	err = r.breaker.Do(func() error {
+		destroyed, err = r.repo.RecordFailedUnlock(id)
+		return err
+	})
+	return destroyed, err
+}
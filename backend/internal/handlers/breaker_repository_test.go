@@ -0,0 +1,45 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/LonleySailor/privatepaste/backend/internal/database"
+	"github.com/LonleySailor/privatepaste/backend/internal/models"
+	"github.com/LonleySailor/privatepaste/backend/pkg/utils"
+	"github.com/LonleySailor/privatepaste/backend/pkg/validation"
+)
+
+// failingPasteRepository fails every lookup as a broken database would
+type failingPasteRepository struct {
+	*models.MemoryPasteRepository
+	calls int
+}
+
+func (r *failingPasteRepository) GetByID(id string) (*models.Paste, error) {
+	r.calls++
+	return nil, errors.New("database is locked")
+}
+
+func TestBreakerPasteRepository(t *testing.T) {
+	repo := &failingPasteRepository{MemoryPasteRepository: models.NewMemoryPasteRepository()}
+	breaker := database.NewBreaker(2, time.Minute)
+	handler := NewPasteHandler(NewBreakerPasteRepository(repo, breaker), utils.NewIDGenerator(), validation.NewValidator())
+
+	for i := 0; i < 2; i++ {
+		if rr := getPasteView(handler.GetByID, "abc123", ""); rr.Code != http.StatusInternalServerError {
+			t.Fatalf("Request %d: expected status %d, got %d", i+1, http.StatusInternalServerError, rr.Code)
+		}
+	}
+
+	// Once open, lookups fail fast without reaching the database
+	rr := getPasteView(handler.GetByID, "abc123", "")
+	if rr.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected status %d once the breaker opens, got %d", http.StatusServiceUnavailable, rr.Code)
+	}
+	if repo.calls != 2 {
+		t.Errorf("Expected 2 database calls, got %d", repo.calls)
+	}
+}
@@ -0,0 +1,65 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/LonleySailor/privatepaste/backend/internal/middleware"
+)
+
+// Capabilities describes the limits, expiry choices and optional features of
+// an instance so clients can adapt their forms and options to it
+type Capabilities struct {
+	MaxPasteSize    int                   `json:"max_paste_size"` // Bytes
+	Expiry          ExpiryPresets         `json:"expiry"`
+	AnonymousExpiry AnonymousExpiryPolicy `json:"anonymous_expiry"`
+	ReadOnly        bool                  `json:"read_only"` // A mirror of another instance, or writes are paused
+	Email           bool                  `json:"email"`
+	SignedResponses bool                  `json:"signed_responses"`
+	Diagrams        bool                  `json:"diagrams"`
+	Sandbox         bool                  `json:"sandbox"`
+	Attachments     *AttachmentLimits     `json:"attachments"`
+	SSO             []string              `json:"sso"` // Enabled single sign-on providers
+}
+
+// CapabilitiesHandler handles the instance information endpoint
+type CapabilitiesHandler struct {
+	capabilities Capabilities
+	readOnly     *middleware.ReadOnlyMode
+}
+
+// NewCapabilitiesHandler creates a new capabilities handler. The paste size
+// limit is filled in from the paste handler's own.
+func NewCapabilitiesHandler(capabilities Capabilities) *CapabilitiesHandler {
+	capabilities.MaxPasteSize = maxPasteSize
+	if capabilities.SSO == nil {
+		capabilities.SSO = []string{}
+	}
+	return &CapabilitiesHandler{capabilities: capabilities}
+}
+
+// SetReadOnlyMode reports the instance as read-only while writes are paused
+func (h *CapabilitiesHandler) SetReadOnlyMode(mode *middleware.ReadOnlyMode) {
+	h.readOnly = mode
+}
+
+// Get handles returning the instance's capabilities
+func (h *CapabilitiesHandler) Get(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		WriteError(w, &APIError{
+			Code:    "method_not_allowed",
+			Message: "Method not allowed",
+			Status:  http.StatusMethodNotAllowed,
+		})
+		return
+	}
+
+	capabilities := h.capabilities
+	if h.readOnly != nil && h.readOnly.Enabled() {
+		capabilities.ReadOnly = true
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(capabilities)
+}
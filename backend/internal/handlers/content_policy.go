@@ -0,0 +1,169 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/LonleySailor/privatepaste/backend/internal/models"
+	"github.com/LonleySailor/privatepaste/backend/internal/services"
+	"github.com/LonleySailor/privatepaste/backend/pkg/validation"
+	"github.com/gorilla/mux"
+)
+
+// ContentPolicyHandler lets admins assign content policies to roles
+type ContentPolicyHandler struct {
+	policies  *services.ContentPolicyService
+	validator *validation.Validator
+}
+
+// NewContentPolicyHandler creates a new content policy handler
+func NewContentPolicyHandler(policies *services.ContentPolicyService, validator *validation.Validator) *ContentPolicyHandler {
+	return &ContentPolicyHandler{policies: policies, validator: validator}
+}
+
+// ContentPolicyRequest sets a role's policy. Omitted permissions are granted.
+type ContentPolicyRequest struct {
+	MaxSize          int      `json:"max_size"`
+	AllowedExpiries  []string `json:"allowed_expiries"`
+	AllowPublic      *bool    `json:"allow_public"`
+	AllowAttachments *bool    `json:"allow_attachments"`
+}
+
+// writePolicyViolation writes the error for a paste refused by its
+// creator's content policy
+func writePolicyViolation(w http.ResponseWriter, err error) {
+	var violation *services.PolicyViolation
+	if !errors.As(err, &violation) {
+		WriteRepositoryError(w, err)
+		return
+	}
+	status := http.StatusForbidden
+	if violation.Code == services.ViolationContentTooLarge {
+		status = http.StatusRequestEntityTooLarge
+	}
+	WriteError(w, &APIError{Code: violation.Code, Message: violation.Message, Status: status})
+}
+
+// List handles listing the policy of every role
+func (h *ContentPolicyHandler) List(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		WriteError(w, &APIError{
+			Code:    "method_not_allowed",
+			Message: "Method not allowed",
+			Status:  http.StatusMethodNotAllowed,
+		})
+		return
+	}
+
+	policies, err := h.policies.Policies()
+	if err != nil {
+		WriteRepositoryError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{"policies": policies})
+}
+
+// Set handles assigning a policy to a role
+func (h *ContentPolicyHandler) Set(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut {
+		WriteError(w, &APIError{
+			Code:    "method_not_allowed",
+			Message: "Method not allowed",
+			Status:  http.StatusMethodNotAllowed,
+		})
+		return
+	}
+
+	role, ok := policyRole(w, r)
+	if !ok {
+		return
+	}
+
+	var req ContentPolicyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		WriteError(w, ErrInvalidJSON)
+		return
+	}
+
+	policy := &models.ContentPolicy{
+		Role:             role,
+		MaxSize:          req.MaxSize,
+		AllowedExpiries:  []string{},
+		AllowPublic:      req.AllowPublic == nil || *req.AllowPublic,
+		AllowAttachments: req.AllowAttachments == nil || *req.AllowAttachments,
+	}
+
+	var errs validation.ValidationErrors
+	if req.MaxSize < 0 || req.MaxSize > maxPasteSize {
+		errs.Add("max_size", fmt.Sprintf("must be between 0 and %d bytes", maxPasteSize))
+	}
+	for _, expiry := range req.AllowedExpiries {
+		if _, err := h.validator.ValidateExpiryDuration(expiry); err != nil || expiry == "" {
+			errs.Add("allowed_expiries", fmt.Sprintf("%q is not a valid expiry", expiry))
+			continue
+		}
+		if !slices.Contains(policy.AllowedExpiries, expiry) {
+			policy.AllowedExpiries = append(policy.AllowedExpiries, expiry)
+		}
+	}
+	if errs.HasErrors() {
+		WriteValidationError(w, errs)
+		return
+	}
+
+	if err := h.policies.SetPolicy(policy); err != nil {
+		WriteRepositoryError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(policy)
+}
+
+// Reset handles restoring a role's default policy
+func (h *ContentPolicyHandler) Reset(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		WriteError(w, &APIError{
+			Code:    "method_not_allowed",
+			Message: "Method not allowed",
+			Status:  http.StatusMethodNotAllowed,
+		})
+		return
+	}
+
+	role, ok := policyRole(w, r)
+	if !ok {
+		return
+	}
+	if err := h.policies.ResetPolicy(role); err != nil {
+		WriteRepositoryError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(models.DefaultContentPolicy(role))
+}
+
+// policyRole reads the role in the URL, writing an error if policies cannot
+// be assigned to it
+func policyRole(w http.ResponseWriter, r *http.Request) (string, bool) {
+	role := mux.Vars(r)["role"]
+	if !slices.Contains(models.PolicyRoles, role) {
+		WriteError(w, &APIError{
+			Code:    "unknown_role",
+			Message: "Content policies can be assigned to: " + strings.Join(models.PolicyRoles, ", "),
+			Status:  http.StatusNotFound,
+		})
+		return "", false
+	}
+	return role, true
+}
@@ -0,0 +1,180 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/LonleySailor/privatepaste/backend/internal/middleware"
+	"github.com/LonleySailor/privatepaste/backend/internal/models"
+	"github.com/LonleySailor/privatepaste/backend/pkg/validation"
+)
+
+// developerUsageDays is how many days of usage the key dashboard shows
+const developerUsageDays = 30
+
+// DeveloperKeyStore manages the self-service keys of anonymous developers
+type DeveloperKeyStore interface {
+	Create(name string) (string, *models.DeveloperKey, error)
+	GetByID(id int64) (*models.DeveloperKey, error)
+	GetUsage(id int64, since time.Time) ([]*models.DeveloperKeyUsage, error)
+	Delete(id int64) (bool, error)
+}
+
+// DeveloperQuota reports how much of its rate limit a developer key has left
+type DeveloperQuota interface {
+	DeveloperKeyQuota(id int64) (limit, remaining int64, resetIn time.Duration)
+}
+
+// DeveloperKeyHandler handles self-service developer keys for the public API.
+// Keys are sent in the X-API-Key header and are separate from account API keys.
+type DeveloperKeyHandler struct {
+	keys      DeveloperKeyStore
+	quota     DeveloperQuota
+	validator *validation.Validator
+}
+
+// NewDeveloperKeyHandler creates a new developer key handler
+func NewDeveloperKeyHandler(keys DeveloperKeyStore, quota DeveloperQuota, validator *validation.Validator) *DeveloperKeyHandler {
+	return &DeveloperKeyHandler{keys: keys, quota: quota, validator: validator}
+}
+
+// CreateDeveloperKeyRequest represents a request for a developer key; the
+// name describes the project using it
+type CreateDeveloperKeyRequest struct {
+	Name string `json:"name"`
+}
+
+// CreateDeveloperKeyResponse includes the key itself, which is never shown again
+type CreateDeveloperKeyResponse struct {
+	*models.DeveloperKey
+	Key string `json:"key"`
+}
+
+// DeveloperKeyUsageResponse is the usage dashboard of a developer key
+type DeveloperKeyUsageResponse struct {
+	Key       *models.DeveloperKey        `json:"key"`
+	Limit     int64                       `json:"limit"`
+	Remaining int64                       `json:"remaining"`
+	ResetIn   int                         `json:"reset_in"` // Seconds until the rate limit window resets
+	Days      []*models.DeveloperKeyUsage `json:"days"`
+}
+
+// Create handles issuing a developer key; no account is needed
+func (h *DeveloperKeyHandler) Create(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		WriteError(w, &APIError{
+			Code:    "method_not_allowed",
+			Message: "Method not allowed",
+			Status:  http.StatusMethodNotAllowed,
+		})
+		return
+	}
+
+	var req CreateDeveloperKeyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		WriteError(w, ErrInvalidJSON)
+		return
+	}
+	if err := h.validator.ValidateString(req.Name, "name", true, 1, 100); err != nil {
+		WriteValidationError(w, []validation.ValidationError{*err})
+		return
+	}
+
+	key, devKey, err := h.keys.Create(req.Name)
+	if err != nil {
+		WriteRepositoryError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(CreateDeveloperKeyResponse{DeveloperKey: devKey, Key: key})
+}
+
+// Usage handles returning the dashboard of the developer key the request is
+// made with: its remaining quota and daily request counts
+func (h *DeveloperKeyHandler) Usage(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		WriteError(w, &APIError{
+			Code:    "method_not_allowed",
+			Message: "Method not allowed",
+			Status:  http.StatusMethodNotAllowed,
+		})
+		return
+	}
+
+	devKey, ok := h.currentKey(w, r)
+	if !ok {
+		return
+	}
+
+	days, err := h.keys.GetUsage(devKey.ID, time.Now().AddDate(0, 0, -developerUsageDays))
+	if err != nil {
+		WriteRepositoryError(w, err)
+		return
+	}
+	limit, remaining, resetIn := h.quota.DeveloperKeyQuota(devKey.ID)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(DeveloperKeyUsageResponse{
+		Key:       devKey,
+		Limit:     limit,
+		Remaining: remaining,
+		ResetIn:   int(resetIn.Seconds()),
+		Days:      days,
+	})
+}
+
+// Revoke handles revoking the developer key the request is made with
+func (h *DeveloperKeyHandler) Revoke(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		WriteError(w, &APIError{
+			Code:    "method_not_allowed",
+			Message: "Method not allowed",
+			Status:  http.StatusMethodNotAllowed,
+		})
+		return
+	}
+
+	devKey, ok := h.currentKey(w, r)
+	if !ok {
+		return
+	}
+	if _, err := h.keys.Delete(devKey.ID); err != nil {
+		WriteRepositoryError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// currentKey returns the developer key authenticated by the middleware. On
+// failure it writes the error response and returns false.
+func (h *DeveloperKeyHandler) currentKey(w http.ResponseWriter, r *http.Request) (*models.DeveloperKey, bool) {
+	id, ok := middleware.GetDeveloperKeyIDFromContext(r.Context())
+	if !ok {
+		WriteError(w, &APIError{
+			Code:    "unauthorized",
+			Message: "A developer key is required in the " + middleware.DeveloperKeyHeader + " header",
+			Status:  http.StatusUnauthorized,
+		})
+		return nil, false
+	}
+
+	devKey, err := h.keys.GetByID(id)
+	if err != nil {
+		WriteRepositoryError(w, err)
+		return nil, false
+	}
+	if devKey == nil {
+		WriteError(w, &APIError{
+			Code:    "invalid_api_key",
+			Message: "Unknown or revoked developer key",
+			Status:  http.StatusUnauthorized,
+		})
+		return nil, false
+	}
+	return devKey, true
+}
@@ -0,0 +1,170 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/mail"
+	"strings"
+
+	"github.com/LonleySailor/privatepaste/backend/internal/middleware"
+	"github.com/LonleySailor/privatepaste/backend/internal/services"
+	"github.com/LonleySailor/privatepaste/backend/pkg/utils"
+	"github.com/LonleySailor/privatepaste/backend/pkg/validation"
+	"github.com/gorilla/mux"
+)
+
+// Excerpt limits for emailed pastes
+const (
+	emailExcerptLines = 20
+	emailExcerptBytes = 2000
+)
+
+// EmailHandler handles emailing paste links
+type EmailHandler struct {
+	pasteRepo PasteRepositoryInterface
+	queue     JobEnqueuer
+	signer    *utils.URLSigner
+	validator *validation.Validator
+}
+
+// NewEmailHandler creates a new email handler. A nil queue means email is not
+// configured and requests are refused.
+func NewEmailHandler(pasteRepo PasteRepositoryInterface, queue JobEnqueuer, signer *utils.URLSigner, validator *validation.Validator) *EmailHandler {
+	return &EmailHandler{
+		pasteRepo: pasteRepo,
+		queue:     queue,
+		signer:    signer,
+		validator: validator,
+	}
+}
+
+// EmailPasteRequest represents a request to email a paste
+type EmailPasteRequest struct {
+	To             string `json:"to"`
+	Message        string `json:"message,omitempty"`
+	IncludeExcerpt bool   `json:"include_excerpt"`
+}
+
+// EmailPaste handles sending a paste's link, and optionally an excerpt, to a
+// recipient. Only the paste's owner may send it, or anyone holding a valid
+// signed URL for it (the expires and signature query parameters).
+func (h *EmailHandler) EmailPaste(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		WriteError(w, &APIError{
+			Code:    "method_not_allowed",
+			Message: "Method not allowed",
+			Status:  http.StatusMethodNotAllowed,
+		})
+		return
+	}
+
+	if h.queue == nil {
+		WriteError(w, &APIError{
+			Code:    "email_disabled",
+			Message: "Email is not configured on this server",
+			Status:  http.StatusServiceUnavailable,
+		})
+		return
+	}
+
+	id := mux.Vars(r)["id"]
+	if err := h.validator.ValidateID(id); err != nil {
+		WriteError(w, &APIError{
+			Code:    "invalid_id",
+			Message: "Invalid paste ID format",
+			Status:  http.StatusBadRequest,
+		})
+		return
+	}
+
+	var req EmailPasteRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		WriteError(w, ErrInvalidJSON)
+		return
+	}
+
+	var errors validation.ValidationErrors
+	if addr, err := mail.ParseAddress(req.To); err != nil || addr.Name != "" {
+		errors.Add("to", "must be a single email address")
+	}
+	if err := h.validator.ValidateString(req.Message, "message", false, 0, 1000); err != nil {
+		errors.Add(err.Field, err.Message)
+	}
+	if errors.HasErrors() {
+		WriteValidationError(w, errors)
+		return
+	}
+
+	paste, err := h.pasteRepo.GetByID(id)
+	if err != nil {
+		WriteRepositoryError(w, err)
+		return
+	}
+	if paste == nil || !canView(r, paste) {
+		WriteError(w, ErrPasteNotFound)
+		return
+	}
+	if paste.IsExpired() {
+		WriteError(w, ErrPasteExpired)
+		return
+	}
+	if !checkAccessWindow(w, r, paste) || !checkLocation(w, r, paste, nil) {
+		return
+	}
+
+	userID, isUser := middleware.GetUserIDFromContext(r.Context())
+	if !isUser || !paste.IsOwnedBy(userID) {
+		query := r.URL.Query()
+		if h.signer == nil || h.signer.Verify(paste.ID, query.Get("expires"), query.Get("signature")) != nil {
+			WriteError(w, &APIError{
+				Code:    "forbidden",
+				Message: "Only the paste's owner or a signed URL holder can email this paste",
+				Status:  http.StatusForbidden,
+			})
+			return
+		}
+	}
+
+	var body strings.Builder
+	if req.Message != "" {
+		body.WriteString(req.Message + "\n\n")
+	}
+	body.WriteString("A paste has been shared with you:\n" + PasteURL(paste.ID) + "\n")
+	if paste.HasPassword() {
+		body.WriteString("\nIt is password protected; ask the sender for the password.\n")
+	} else if req.IncludeExcerpt {
+		body.WriteString("\n-----\n" + excerpt(paste.Content) + "\n-----\n")
+	}
+
+	subject := "A paste has been shared with you"
+	if username, ok := middleware.GetUsernameFromContext(r.Context()); ok {
+		subject = username + " shared a paste with you"
+	}
+
+	if err := h.queue.Enqueue(services.JobTypeEmail, services.EmailMessage{To: req.To, Subject: subject, Body: body.String()}); err != nil {
+		WriteRepositoryError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(map[string]string{"status": "queued"})
+}
+
+// excerpt returns the first lines of content, within emailExcerptBytes
+func excerpt(content string) string {
+	lines := strings.SplitN(content, "\n", emailExcerptLines+1)
+	truncated := len(lines) > emailExcerptLines
+	if truncated {
+		lines = lines[:emailExcerptLines]
+	}
+	text := strings.Join(lines, "\n")
+	if len(text) > emailExcerptBytes {
+		text = strings.ToValidUTF8(text[:emailExcerptBytes], "")
+		truncated = true
+	}
+	if truncated {
+		text += "\n…"
+	}
+	return text
+}
@@ -0,0 +1,69 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/LonleySailor/privatepaste/backend/internal/models"
+	"github.com/LonleySailor/privatepaste/backend/internal/services"
+	"github.com/LonleySailor/privatepaste/backend/pkg/utils"
+	"github.com/LonleySailor/privatepaste/backend/pkg/validation"
+	"github.com/gorilla/mux"
+)
+
+func TestEmailPaste(t *testing.T) {
+	pasteRepo := models.NewMemoryPasteRepository()
+	ownerID := 1
+	pasteRepo.Create(&models.Paste{ID: "mail01", Content: "line one\nline two", UserID: &ownerID, Visibility: models.VisibilityPublic})
+
+	signer := utils.NewURLSigner("test-secret")
+	queue := &recordingQueue{}
+	handler := NewEmailHandler(pasteRepo, queue, signer, validation.NewValidator())
+
+	send := func(h *EmailHandler, userID int, query string, req EmailPasteRequest) int {
+		body, _ := json.Marshal(req)
+		r := httptest.NewRequest("POST", "/api/paste/mail01/email"+query, bytes.NewReader(body))
+		if userID != 0 {
+			r = r.WithContext(context.WithValue(r.Context(), "userID", userID))
+		}
+		r = mux.SetURLVars(r, map[string]string{"id": "mail01"})
+		rr := httptest.NewRecorder()
+		h.EmailPaste(rr, r)
+		return rr.Code
+	}
+	req := EmailPasteRequest{To: "friend@example.com", IncludeExcerpt: true}
+
+	if code := send(handler, ownerID, "", req); code != http.StatusAccepted {
+		t.Fatalf("Expected the owner to email the paste, got %d", code)
+	}
+	msg := queue.jobs[0].(services.EmailMessage)
+	if msg.To != "friend@example.com" || !strings.Contains(msg.Body, "mail01") || !strings.Contains(msg.Body, "line two") {
+		t.Errorf("Unexpected email %+v", msg)
+	}
+
+	if code := send(handler, 2, "", req); code != http.StatusForbidden {
+		t.Errorf("Expected another user without a signature to be refused, got %d", code)
+	}
+
+	expires := time.Now().Add(time.Hour)
+	query := "?expires=" + strconv.FormatInt(expires.Unix(), 10) + "&signature=" + signer.Sign("mail01", expires)
+	if code := send(handler, 0, query, req); code != http.StatusAccepted {
+		t.Errorf("Expected a signed URL holder to email the paste, got %d", code)
+	}
+
+	if code := send(handler, ownerID, "", EmailPasteRequest{To: "Friend <a@b.c>, x@y.z"}); code != http.StatusBadRequest {
+		t.Errorf("Expected an invalid recipient to be rejected, got %d", code)
+	}
+
+	disabled := NewEmailHandler(pasteRepo, nil, signer, validation.NewValidator())
+	if code := send(disabled, ownerID, "", req); code != http.StatusServiceUnavailable {
+		t.Errorf("Expected 503 when email is not configured, got %d", code)
+	}
+}
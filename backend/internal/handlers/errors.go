@@ -2,7 +2,11 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
+
+	"github.com/LonleySailor/privatepaste/backend/internal/database"
+	"github.com/LonleySailor/privatepaste/backend/internal/middleware"
 )
 
 // APIError represents a custom API error response
@@ -17,6 +21,31 @@ func (e *APIError) Error() string {
 	return e.Message
 }
 
+// WriteRepositoryError writes the response for a failed repository call,
+// reporting an open database circuit breaker as 503 and an unreachable
+// mirror upstream as 502 rather than 500
+func WriteRepositoryError(w http.ResponseWriter, err error) {
+	if errors.Is(err, database.ErrCircuitOpen) {
+		WriteError(w, ErrServiceUnavailable)
+		return
+	}
+	if errors.Is(err, ErrMirrorUpstream) {
+		WriteError(w, ErrUpstreamUnavailable)
+		return
+	}
+	WriteError(w, ErrInternalServer)
+}
+
+// WriteBodyError writes the response for a request body that could not be
+// read, reporting a client too slow to send it as 408 rather than fallback
+func WriteBodyError(w http.ResponseWriter, err error, fallback *APIError) {
+	if errors.Is(err, middleware.ErrSlowBody) {
+		WriteError(w, ErrRequestTimeout)
+		return
+	}
+	WriteError(w, fallback)
+}
+
 // WriteError writes an API error response to the HTTP response writer
 func WriteError(w http.ResponseWriter, err *APIError) {
 	w.Header().Set("Content-Type", "application/json")
@@ -44,6 +73,12 @@ var (
 		Status:  http.StatusRequestEntityTooLarge,
 	}
 
+	ErrRequestTimeout = &APIError{
+		Code:    "request_timeout",
+		Message: "Request body was not received in time",
+		Status:  http.StatusRequestTimeout,
+	}
+
 	ErrPasteNotFound = &APIError{
 		Code:    "paste_not_found",
 		Message: "Paste not found",
@@ -56,6 +91,54 @@ var (
 		Status:  http.StatusGone,
 	}
 
+	ErrSignatureNotFound = &APIError{
+		Code:    "signature_not_found",
+		Message: "No signature was attached to this paste",
+		Status:  http.StatusNotFound,
+	}
+
+	ErrBlobNotFound = &APIError{
+		Code:    "blob_not_found",
+		Message: "No paste with this content hash",
+		Status:  http.StatusNotFound,
+	}
+
+	ErrAvatarNotFound = &APIError{
+		Code:    "avatar_not_found",
+		Message: "Avatar not found",
+		Status:  http.StatusNotFound,
+	}
+
+	ErrIntegrationNotFound = &APIError{
+		Code:    "integration_not_found",
+		Message: "Integration not found",
+		Status:  http.StatusNotFound,
+	}
+
+	ErrDeliveryNotFound = &APIError{
+		Code:    "delivery_not_found",
+		Message: "Webhook delivery not found",
+		Status:  http.StatusNotFound,
+	}
+
+	ErrSSOUnavailable = &APIError{
+		Code:    "sso_unavailable",
+		Message: "The single sign-on provider could not be reached",
+		Status:  http.StatusServiceUnavailable,
+	}
+
+	ErrAccountDeactivated = &APIError{
+		Code:    "account_deactivated",
+		Message: "This account has been deactivated",
+		Status:  http.StatusForbidden,
+	}
+
+	ErrServiceAccountNotFound = &APIError{
+		Code:    "service_account_not_found",
+		Message: "Service account not found",
+		Status:  http.StatusNotFound,
+	}
+
 	ErrPasswordRequired = &APIError{
 		Code:    "password_required",
 		Message: "This paste is password protected",
@@ -68,12 +151,66 @@ var (
 		Status:  http.StatusForbidden,
 	}
 
+	ErrLocationNotAllowed = &APIError{
+		Code:    "location_not_allowed",
+		Message: "This paste is not available from your network or country",
+		Status:  http.StatusForbidden,
+	}
+
+	ErrEmbedNotAllowed = &APIError{
+		Code:    "embed_not_allowed",
+		Message: "This paste may not be embedded on this site",
+		Status:  http.StatusForbidden,
+	}
+
+	ErrPasteDestroyed = &APIError{
+		Code:    "paste_destroyed",
+		Message: "Too many wrong passwords; the paste has been destroyed",
+		Status:  http.StatusGone,
+	}
+
+	ErrUnsupportedPasteKind = &APIError{
+		Code:    "unsupported_paste_kind",
+		Message: "This view is not available for this kind of paste",
+		Status:  http.StatusBadRequest,
+	}
+
+	ErrSignatureRequired = &APIError{
+		Code:    "signature_required",
+		Message: "This paste can only be accessed through a signed URL",
+		Status:  http.StatusForbidden,
+	}
+
+	ErrSignatureInvalid = &APIError{
+		Code:    "signature_invalid",
+		Message: "Invalid URL signature",
+		Status:  http.StatusForbidden,
+	}
+
+	ErrSignatureExpired = &APIError{
+		Code:    "signature_expired",
+		Message: "Signed URL has expired",
+		Status:  http.StatusForbidden,
+	}
+
 	ErrInternalServer = &APIError{
 		Code:    "internal_server_error",
 		Message: "Internal server error",
 		Status:  http.StatusInternalServerError,
 	}
 
+	ErrServiceUnavailable = &APIError{
+		Code:    "service_unavailable",
+		Message: "The service is temporarily unavailable; try again later",
+		Status:  http.StatusServiceUnavailable,
+	}
+
+	ErrUpstreamUnavailable = &APIError{
+		Code:    "upstream_unavailable",
+		Message: "The instance this mirror reads from is unavailable; try again later",
+		Status:  http.StatusBadGateway,
+	}
+
 	ErrIDGenerationFailed = &APIError{
 		Code:    "id_generation_failed",
 		Message: "Failed to generate unique paste ID",
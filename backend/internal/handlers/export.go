@@ -0,0 +1,226 @@
+package handlers
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/LonleySailor/privatepaste/backend/internal/middleware"
+	"github.com/LonleySailor/privatepaste/backend/internal/models"
+	"github.com/LonleySailor/privatepaste/backend/pkg/validation"
+)
+
+// exportBatchSize is how many pastes are loaded per query while streaming
+const exportBatchSize = 100
+
+// ExportSource lists a user's pastes page by page
+type ExportSource interface {
+	ListByUserIDAfter(userID int, afterID string, limit int) ([]*models.Paste, error)
+}
+
+// ExportHandler handles exporting a user's pastes as an archive
+type ExportHandler struct {
+	source ExportSource
+}
+
+// NewExportHandler creates a new export handler
+func NewExportHandler(source ExportSource) *ExportHandler {
+	return &ExportHandler{source: source}
+}
+
+// ExportedPaste is the metadata written next to each paste in an export
+type ExportedPaste struct {
+	ID          string     `json:"id"`
+	Language    string     `json:"language,omitempty"`
+	Kind        string     `json:"kind"`
+	Visibility  string     `json:"visibility"`
+	CreatedAt   time.Time  `json:"created_at"`
+	ExpiresAt   *time.Time `json:"expires_at,omitempty"`
+	HasPassword bool       `json:"has_password"`
+	ParentID    *string    `json:"parent_id,omitempty"`
+	Size        int        `json:"size"`
+}
+
+// archiveWriter adds files to a zip or tar.gz archive
+type archiveWriter interface {
+	add(name string, modified time.Time, data []byte) error
+	Close() error
+}
+
+// ExportUserPastes handles streaming the authenticated user's pastes as a zip
+// (default) or tar.gz archive, selected with the format query parameter.
+// Pastes are read in batches and each file is flushed as soon as it is
+// written, so the archive is never held in memory and clients see progress.
+func (h *ExportHandler) ExportUserPastes(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		WriteError(w, &APIError{
+			Code:    "method_not_allowed",
+			Message: "Method not allowed",
+			Status:  http.StatusMethodNotAllowed,
+		})
+		return
+	}
+
+	userID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		WriteError(w, &APIError{
+			Code:    "unauthorized",
+			Message: "User ID not found in token",
+			Status:  http.StatusUnauthorized,
+		})
+		return
+	}
+
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = "zip"
+	}
+	if format != "zip" && format != "tar.gz" {
+		WriteValidationError(w, []validation.ValidationError{{Field: "format", Message: "must be zip or tar.gz"}})
+		return
+	}
+
+	// Load the first batch before committing to a 200 so errors can still
+	// be reported normally
+	batch, err := h.source.ListByUserIDAfter(userID, "", exportBatchSize)
+	if err != nil {
+		WriteRepositoryError(w, err)
+		return
+	}
+
+	username, _ := middleware.GetUsernameFromContext(r.Context())
+	filename := "pastevault-export-" + username + "-" + time.Now().UTC().Format("20060102") + "." + format
+
+	var archive archiveWriter
+	if format == "zip" {
+		w.Header().Set("Content-Type", "application/zip")
+		archive = &zipArchive{zw: zip.NewWriter(w)}
+	} else {
+		w.Header().Set("Content-Type", "application/gzip")
+		gz := gzip.NewWriter(w)
+		archive = &tarArchive{tw: tar.NewWriter(gz), gz: gz}
+	}
+	w.Header().Set("Content-Disposition", `attachment; filename="`+filename+`"`)
+	w.WriteHeader(http.StatusOK)
+
+	rc := http.NewResponseController(w)
+	now := time.Now()
+	for len(batch) > 0 {
+		for _, paste := range batch {
+			if paste.ExpiresAt != nil && !paste.ExpiresAt.After(now) {
+				continue
+			}
+			if err := writeExportedPaste(archive, paste); err != nil {
+				return // The client sees a truncated archive
+			}
+			rc.Flush()
+		}
+
+		if len(batch) < exportBatchSize {
+			break
+		}
+		if batch, err = h.source.ListByUserIDAfter(userID, batch[len(batch)-1].ID, exportBatchSize); err != nil {
+			return
+		}
+	}
+
+	archive.Close()
+}
+
+// writeExportedPaste adds a paste's content and its metadata to the archive
+func writeExportedPaste(archive archiveWriter, paste *models.Paste) error {
+	meta, err := json.MarshalIndent(ExportedPaste{
+		ID:          paste.ID,
+		Language:    paste.Language,
+		Kind:        paste.Kind,
+		Visibility:  paste.Visibility,
+		CreatedAt:   paste.CreatedAt,
+		ExpiresAt:   paste.ExpiresAt,
+		HasPassword: paste.HasPassword(),
+		ParentID:    paste.ParentID,
+		Size:        len(paste.Content),
+	}, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	base := "pastes/" + paste.ID
+	if err := archive.add(base+exportExtension(paste), paste.CreatedAt, []byte(paste.Content)); err != nil {
+		return err
+	}
+	return archive.add(base+".json", paste.CreatedAt, meta)
+}
+
+// exportExtensions maps common languages to file extensions
+var exportExtensions = map[string]string{
+	"go": ".go", "javascript": ".js", "typescript": ".ts", "python": ".py", "rust": ".rs",
+	"java": ".java", "ruby": ".rb", "bash": ".sh", "sql": ".sql", "json": ".json",
+	"yaml": ".yaml", "markdown": ".md", "html": ".html", "css": ".css", "c": ".c", "cpp": ".cpp",
+	"diff": ".diff", "patch": ".patch",
+}
+
+// exportExtension picks a file extension from the paste's language. JSON
+// content gets .json.txt so it cannot clash with the metadata file.
+func exportExtension(paste *models.Paste) string {
+	ext, ok := exportExtensions[strings.ToLower(paste.Language)]
+	switch {
+	case !ok:
+		return ".txt"
+	case ext == ".json":
+		return ".json.txt"
+	default:
+		return ext
+	}
+}
+
+// zipArchive writes files into a zip stream
+type zipArchive struct {
+	zw *zip.Writer
+}
+
+func (a *zipArchive) add(name string, modified time.Time, data []byte) error {
+	f, err := a.zw.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Deflate, Modified: modified})
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(data)
+	if err == nil {
+		err = a.zw.Flush()
+	}
+	return err
+}
+
+func (a *zipArchive) Close() error {
+	return a.zw.Close()
+}
+
+// tarArchive writes files into a gzip-compressed tar stream
+type tarArchive struct {
+	tw *tar.Writer
+	gz *gzip.Writer
+}
+
+func (a *tarArchive) add(name string, modified time.Time, data []byte) error {
+	hdr := &tar.Header{Name: name, Mode: 0o644, Size: int64(len(data)), ModTime: modified}
+	if err := a.tw.WriteHeader(hdr); err != nil {
+		return err
+	}
+	if _, err := a.tw.Write(data); err != nil {
+		return err
+	}
+	if err := a.tw.Flush(); err != nil {
+		return err
+	}
+	return a.gz.Flush()
+}
+
+func (a *tarArchive) Close() error {
+	if err := a.tw.Close(); err != nil {
+		return err
+	}
+	return a.gz.Close()
+}
@@ -0,0 +1,119 @@
+package handlers
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/LonleySailor/privatepaste/backend/internal/models"
+)
+
+func setupExportTest(t *testing.T, count int) *ExportHandler {
+	repo := models.NewMemoryPasteRepository()
+	ownerID, otherID := 1, 2
+	for i := 0; i < count; i++ {
+		repo.Create(&models.Paste{ID: fmt.Sprintf("exp%03d", i), Content: fmt.Sprintf("paste %d", i), Language: "go", UserID: &ownerID})
+	}
+	expired := time.Now().Add(-time.Minute)
+	repo.Create(&models.Paste{ID: "old001", Content: "gone", UserID: &ownerID, ExpiresAt: &expired})
+	repo.Create(&models.Paste{ID: "oth001", Content: "not mine", UserID: &otherID})
+	return NewExportHandler(repo)
+}
+
+func requestExport(handler *ExportHandler, format string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("GET", "/api/user/export?format="+format, nil)
+	ctx := context.WithValue(req.Context(), "userID", 1)
+	ctx = context.WithValue(ctx, "username", "alice")
+	rr := httptest.NewRecorder()
+	handler.ExportUserPastes(rr, req.WithContext(ctx))
+	return rr
+}
+
+func TestExportUserPastes_Zip(t *testing.T) {
+	// More pastes than one batch, to cover paging
+	handler := setupExportTest(t, exportBatchSize+5)
+
+	rr := requestExport(handler, "")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d", http.StatusOK, rr.Code)
+	}
+	if ct := rr.Header().Get("Content-Type"); ct != "application/zip" {
+		t.Errorf("Expected zip content type, got %q", ct)
+	}
+
+	body := rr.Body.Bytes()
+	zr, err := zip.NewReader(bytes.NewReader(body), int64(len(body)))
+	if err != nil {
+		t.Fatalf("Failed to read zip: %v", err)
+	}
+
+	files := make(map[string]*zip.File)
+	for _, f := range zr.File {
+		files[f.Name] = f
+	}
+	if len(files) != 2*(exportBatchSize+5) {
+		t.Errorf("Expected content and metadata for %d pastes, got %d files", exportBatchSize+5, len(files))
+	}
+	if _, ok := files["pastes/old001.txt"]; ok {
+		t.Error("Expected expired pastes to be skipped")
+	}
+	if _, ok := files["pastes/oth001.txt"]; ok {
+		t.Error("Expected other users' pastes to be excluded")
+	}
+
+	f, ok := files["pastes/exp042.go"]
+	if !ok {
+		t.Fatal("Expected pastes/exp042.go in the archive")
+	}
+	rc, _ := f.Open()
+	content, _ := io.ReadAll(rc)
+	rc.Close()
+	if string(content) != "paste 42" {
+		t.Errorf("Unexpected content %q", content)
+	}
+}
+
+func TestExportUserPastes_TarGz(t *testing.T) {
+	handler := setupExportTest(t, 3)
+
+	rr := requestExport(handler, "tar.gz")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d", http.StatusOK, rr.Code)
+	}
+
+	gz, err := gzip.NewReader(rr.Body)
+	if err != nil {
+		t.Fatalf("Failed to read gzip: %v", err)
+	}
+	tr := tar.NewReader(gz)
+	var names []string
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Failed to read tar: %v", err)
+		}
+		names = append(names, hdr.Name)
+	}
+	if len(names) != 6 || names[0] != "pastes/exp000.go" || names[1] != "pastes/exp000.json" {
+		t.Errorf("Unexpected archive entries: %v", names)
+	}
+}
+
+func TestExportUserPastes_InvalidFormat(t *testing.T) {
+	handler := setupExportTest(t, 1)
+
+	if rr := requestExport(handler, "rar"); rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected status %d, got %d", http.StatusBadRequest, rr.Code)
+	}
+}
@@ -0,0 +1,219 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/LonleySailor/privatepaste/backend/internal/middleware"
+	"github.com/LonleySailor/privatepaste/backend/internal/models"
+	"github.com/gorilla/mux"
+)
+
+// FollowHandler handles users following each other and the feed of pastes
+// by the users someone follows
+type FollowHandler struct {
+	follows  *models.FollowRepository
+	userRepo *models.UserRepository
+}
+
+// NewFollowHandler creates a new follow handler
+func NewFollowHandler(follows *models.FollowRepository, userRepo *models.UserRepository) *FollowHandler {
+	return &FollowHandler{follows: follows, userRepo: userRepo}
+}
+
+// FollowingResponse lists the usernames the current user follows
+type FollowingResponse struct {
+	Following []string `json:"following"`
+}
+
+// FeedItem represents a paste in the feed together with its author
+type FeedItem struct {
+	PasteListItem
+	Username string `json:"username"`
+}
+
+// FeedResponse represents a page of the current user's feed
+type FeedResponse struct {
+	Pastes []FeedItem `json:"pastes"`
+	Total  int        `json:"total"`
+	Page   int        `json:"page"`
+	Limit  int        `json:"limit"`
+}
+
+// List handles listing the users the current user follows
+func (h *FollowHandler) List(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		WriteError(w, &APIError{
+			Code:    "method_not_allowed",
+			Message: "Method not allowed",
+			Status:  http.StatusMethodNotAllowed,
+		})
+		return
+	}
+
+	userID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		WriteError(w, &APIError{
+			Code:    "unauthorized",
+			Message: "User ID not found in token",
+			Status:  http.StatusUnauthorized,
+		})
+		return
+	}
+
+	following, err := h.follows.ListFollowing(userID)
+	if err != nil {
+		WriteError(w, ErrInternalServer)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(FollowingResponse{Following: following})
+}
+
+// Follow handles the current user following the user named in the path
+func (h *FollowHandler) Follow(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut {
+		WriteError(w, &APIError{
+			Code:    "method_not_allowed",
+			Message: "Method not allowed",
+			Status:  http.StatusMethodNotAllowed,
+		})
+		return
+	}
+
+	userID, followee, ok := h.resolve(w, r)
+	if !ok {
+		return
+	}
+	if followee.ID == userID {
+		WriteError(w, &APIError{
+			Code:    "cannot_follow_self",
+			Message: "You cannot follow yourself",
+			Status:  http.StatusBadRequest,
+		})
+		return
+	}
+
+	if err := h.follows.Follow(userID, followee.ID); err != nil {
+		WriteError(w, ErrInternalServer)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Unfollow handles the current user no longer following the user named in
+// the path
+func (h *FollowHandler) Unfollow(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		WriteError(w, &APIError{
+			Code:    "method_not_allowed",
+			Message: "Method not allowed",
+			Status:  http.StatusMethodNotAllowed,
+		})
+		return
+	}
+
+	userID, followee, ok := h.resolve(w, r)
+	if !ok {
+		return
+	}
+
+	found, err := h.follows.Unfollow(userID, followee.ID)
+	if err != nil {
+		WriteError(w, ErrInternalServer)
+		return
+	}
+	if !found {
+		WriteError(w, &APIError{
+			Code:    "not_following",
+			Message: "You do not follow this user",
+			Status:  http.StatusNotFound,
+		})
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Feed handles listing recent public pastes by the users the current user
+// follows, newest first. Supports page and limit query parameters.
+func (h *FollowHandler) Feed(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		WriteError(w, &APIError{
+			Code:    "method_not_allowed",
+			Message: "Method not allowed",
+			Status:  http.StatusMethodNotAllowed,
+		})
+		return
+	}
+
+	userID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		WriteError(w, &APIError{
+			Code:    "unauthorized",
+			Message: "User ID not found in token",
+			Status:  http.StatusUnauthorized,
+		})
+		return
+	}
+
+	page := 1
+	limit := 20
+	if p, err := strconv.Atoi(r.URL.Query().Get("page")); err == nil && p > 0 {
+		page = p
+	}
+	if l, err := strconv.Atoi(r.URL.Query().Get("limit")); err == nil && l > 0 && l <= 100 {
+		limit = l
+	}
+
+	pastes, total, err := h.follows.Feed(userID, limit, (page-1)*limit)
+	if err != nil {
+		WriteRepositoryError(w, err)
+		return
+	}
+
+	items := make([]FeedItem, len(pastes))
+	for i, paste := range pastes {
+		items[i] = FeedItem{PasteListItem: newPasteListItem(paste.Paste, false), Username: paste.Username}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(FeedResponse{
+		Pastes: items,
+		Total:  total,
+		Page:   page,
+		Limit:  limit,
+	})
+}
+
+// resolve returns the current user's ID and the user named in the path,
+// writing an error response and returning false if either is missing
+func (h *FollowHandler) resolve(w http.ResponseWriter, r *http.Request) (int, *models.User, bool) {
+	userID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		WriteError(w, &APIError{
+			Code:    "unauthorized",
+			Message: "User ID not found in token",
+			Status:  http.StatusUnauthorized,
+		})
+		return 0, nil, false
+	}
+
+	user, err := h.userRepo.GetByUsername(mux.Vars(r)["username"])
+	if err != nil {
+		WriteError(w, ErrInternalServer)
+		return 0, nil, false
+	}
+	if user == nil || !user.IsActive() {
+		WriteError(w, &APIError{
+			Code:    "user_not_found",
+			Message: "User not found",
+			Status:  http.StatusNotFound,
+		})
+		return 0, nil, false
+	}
+	return userID, user, true
+}
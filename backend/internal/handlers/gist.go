@@ -0,0 +1,155 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/LonleySailor/privatepaste/backend/internal/models"
+	"github.com/LonleySailor/privatepaste/backend/pkg/gist"
+	"github.com/LonleySailor/privatepaste/backend/pkg/validation"
+)
+
+// ExportGistRequest represents a request to copy a paste into a GitHub gist.
+// The token is used for this request only and never stored.
+type ExportGistRequest struct {
+	Token       string `json:"token"`
+	Public      bool   `json:"public"`
+	Description string `json:"description,omitempty"`
+}
+
+// ExportGistResponse describes the gist a paste was copied into
+type ExportGistResponse struct {
+	ID    string   `json:"id"`
+	URL   string   `json:"url"`
+	Files []string `json:"files"`
+}
+
+// SetGistClient enables exporting pastes to GitHub gists
+func (h *PasteHandler) SetGistClient(client *gist.Client) {
+	h.gists = client
+}
+
+// ExportGist handles copying a paste, with its text attachments as further
+// files, into a gist owned by whoever the given GitHub token belongs to.
+// Gists are secret unless a public one is requested for a public paste.
+func (h *PasteHandler) ExportGist(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		WriteError(w, &APIError{
+			Code:    "method_not_allowed",
+			Message: "Method not allowed",
+			Status:  http.StatusMethodNotAllowed,
+		})
+		return
+	}
+
+	if h.gists == nil {
+		WriteError(w, &APIError{
+			Code:    "gist_export_disabled",
+			Message: "Exporting to GitHub gists is not enabled on this server",
+			Status:  http.StatusServiceUnavailable,
+		})
+		return
+	}
+
+	paste, ok := h.loadViewablePaste(w, r)
+	if !ok {
+		return
+	}
+
+	var req ExportGistRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		WriteError(w, ErrInvalidJSON)
+		return
+	}
+	req.Token = strings.TrimSpace(req.Token)
+	if req.Token == "" {
+		WriteValidationError(w, []validation.ValidationError{{Field: "token", Message: "is required"}})
+		return
+	}
+	if err := h.validator.ValidateString(req.Description, "description", false, 0, 256); err != nil {
+		WriteValidationError(w, []validation.ValidationError{*err})
+		return
+	}
+	if req.Public && (paste.Visibility != models.VisibilityPublic || paste.HasPassword()) {
+		WriteValidationError(w, []validation.ValidationError{{Field: "public", Message: "only public pastes without a password can become public gists"}})
+		return
+	}
+
+	files, ok := h.gistFiles(w, paste)
+	if !ok {
+		return
+	}
+	description := req.Description
+	if description == "" {
+		description = "Exported from paste " + paste.ID
+	}
+
+	created, err := h.gists.Create(r.Context(), req.Token, gist.Gist{Description: description, Public: req.Public, Files: files})
+	switch {
+	case errors.Is(err, gist.ErrUnauthorized):
+		WriteError(w, &APIError{
+			Code:    "github_unauthorized",
+			Message: err.Error(),
+			Status:  http.StatusUnprocessableEntity,
+		})
+		return
+	case errors.Is(err, gist.ErrRejected):
+		WriteError(w, &APIError{
+			Code:    "gist_rejected",
+			Message: err.Error(),
+			Status:  http.StatusUnprocessableEntity,
+		})
+		return
+	case err != nil:
+		WriteError(w, &APIError{
+			Code:    "github_unavailable",
+			Message: "GitHub is unavailable; try again later",
+			Status:  http.StatusBadGateway,
+		})
+		return
+	}
+
+	response := ExportGistResponse{ID: created.ID, URL: created.URL}
+	for name := range files {
+		response.Files = append(response.Files, name)
+	}
+	slices.Sort(response.Files)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(response)
+}
+
+// gistFiles collects the files of a gist copied from a paste: its content,
+// named as in archives, and its text attachments. Binary attachments cannot
+// be stored in a gist and are left out. On failure it writes the error
+// response and returns false.
+func (h *PasteHandler) gistFiles(w http.ResponseWriter, paste *models.Paste) (map[string]string, bool) {
+	files := map[string]string{paste.ID + exportExtension(paste): paste.Content}
+	if h.attachments == nil {
+		return files, true
+	}
+
+	attachments, err := h.attachments.List(paste.ID)
+	if err != nil {
+		WriteRepositoryError(w, err)
+		return nil, false
+	}
+	for _, attachment := range attachments {
+		if _, clash := files[attachment.Name]; clash || !strings.HasPrefix(attachment.ContentType, "text/") {
+			continue
+		}
+		found, data, err := h.attachments.Get(paste.ID, attachment.Name)
+		if err != nil {
+			WriteRepositoryError(w, err)
+			return nil, false
+		}
+		if found != nil {
+			files[found.Name] = string(data)
+		}
+	}
+	return files, true
+}
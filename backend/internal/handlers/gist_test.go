@@ -0,0 +1,64 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/LonleySailor/privatepaste/backend/pkg/gist"
+	"github.com/gorilla/mux"
+)
+
+func TestExportGist(t *testing.T) {
+	var received struct {
+		Public bool                         `json:"public"`
+		Files  map[string]map[string]string `json:"files"`
+	}
+	github := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer ghp_valid" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		json.NewDecoder(r.Body).Decode(&received)
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"id":"g1","html_url":"https://gist.github.com/g1"}`))
+	}))
+	defer github.Close()
+
+	handler, _ := setupTestHandler()
+	handler.SetGistClient(gist.NewClient(github.URL))
+	id := createTestPaste(t, handler, CreatePasteRequest{Content: "fmt.Println()", Language: "go", Visibility: "unlisted"})
+
+	export := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/api/paste/"+id+"/export/gist", strings.NewReader(body))
+		req = mux.SetURLVars(req, map[string]string{"id": id})
+		rr := httptest.NewRecorder()
+		handler.ExportGist(rr, req)
+		return rr
+	}
+
+	for _, tc := range []struct {
+		body     string
+		expected int
+	}{
+		{`{}`, http.StatusBadRequest},
+		{`{"token":"ghp_valid","public":true}`, http.StatusBadRequest},
+		{`{"token":"ghp_revoked"}`, http.StatusUnprocessableEntity},
+	} {
+		if rr := export(tc.body); rr.Code != tc.expected {
+			t.Errorf("Body %s: expected %d, got %d", tc.body, tc.expected, rr.Code)
+		}
+	}
+
+	rr := export(`{"token":"ghp_valid"}`)
+	var response ExportGistResponse
+	json.Unmarshal(rr.Body.Bytes(), &response)
+	if rr.Code != http.StatusCreated || response.URL != "https://gist.github.com/g1" {
+		t.Fatalf("Expected the gist to be created, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if received.Public || received.Files[id+".go"]["content"] != "fmt.Println()" {
+		t.Errorf("Expected a secret gist of the paste, got %+v", received)
+	}
+}
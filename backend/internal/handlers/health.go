@@ -5,11 +5,14 @@ import (
 	"encoding/json"
 	"net/http"
 	"time"
+
+	"github.com/LonleySailor/privatepaste/backend/internal/database"
 )
 
 // HealthHandler handles health check endpoints
 type HealthHandler struct {
-	db *sql.DB
+	db      *sql.DB
+	breaker *database.Breaker // Optional; an open breaker marks the service unready
 }
 
 // NewHealthHandler creates a new health handler
@@ -19,6 +22,11 @@ func NewHealthHandler(db *sql.DB) *HealthHandler {
 	}
 }
 
+// SetBreaker reports the given database circuit breaker in health checks
+func (h *HealthHandler) SetBreaker(breaker *database.Breaker) {
+	h.breaker = breaker
+}
+
 // BasicHealthResponse represents basic health check response
 type BasicHealthResponse struct {
 	Status  string `json:"status"`
@@ -38,9 +46,21 @@ type DetailedHealthResponse struct {
 
 // DatabaseHealth represents database health information
 type DatabaseHealth struct {
-	Status      string `json:"status"`
-	Ping        bool   `json:"ping"`
-	Connections int    `json:"connections"`
+	Status      string     `json:"status"`
+	Ping        bool       `json:"ping"`
+	Connections int        `json:"connections"`
+	Breaker     string     `json:"circuit_breaker,omitempty"`
+	Pool        PoolHealth `json:"pool"`
+}
+
+// PoolHealth represents connection pool statistics
+type PoolHealth struct {
+	MaxOpen      int    `json:"max_open"`
+	Open         int    `json:"open"`
+	InUse        int    `json:"in_use"`
+	Idle         int    `json:"idle"`
+	WaitCount    int64  `json:"wait_count"`
+	WaitDuration string `json:"wait_duration"`
 }
 
 // MemoryHealth represents memory health information
@@ -103,6 +123,21 @@ func (h *HealthHandler) DetailedHealth(w http.ResponseWriter, r *http.Request) {
 	// Get database stats
 	stats := h.db.Stats()
 	dbHealth.Connections = stats.OpenConnections
+	dbHealth.Pool = PoolHealth{
+		MaxOpen:      stats.MaxOpenConnections,
+		Open:         stats.OpenConnections,
+		InUse:        stats.InUse,
+		Idle:         stats.Idle,
+		WaitCount:    stats.WaitCount,
+		WaitDuration: stats.WaitDuration.String(),
+	}
+
+	if h.breaker != nil {
+		dbHealth.Breaker = h.breaker.State()
+		if dbHealth.Breaker == database.BreakerOpen {
+			dbHealth.Status = "unhealthy"
+		}
+	}
 
 	// Determine overall status
 	overallStatus := "healthy"
@@ -134,3 +169,34 @@ func (h *HealthHandler) DetailedHealth(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(response)
 }
+
+// Ready handles the readiness probe. The service is unready while the database
+// is unreachable or the circuit breaker is open.
+func (h *HealthHandler) Ready(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		WriteError(w, &APIError{
+			Code:    "method_not_allowed",
+			Message: "Method not allowed",
+			Status:  http.StatusMethodNotAllowed,
+		})
+		return
+	}
+
+	response := BasicHealthResponse{
+		Status:  "ready",
+		Version: "1.0.0",
+	}
+	status := http.StatusOK
+
+	if h.breaker != nil && h.breaker.State() == database.BreakerOpen {
+		response.Status = "unready"
+		status = http.StatusServiceUnavailable
+	} else if err := h.db.Ping(); err != nil {
+		response.Status = "unready"
+		status = http.StatusServiceUnavailable
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(response)
+}
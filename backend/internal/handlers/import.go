@@ -0,0 +1,202 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/LonleySailor/privatepaste/backend/internal/middleware"
+	"github.com/LonleySailor/privatepaste/backend/internal/models"
+	"github.com/LonleySailor/privatepaste/backend/internal/services"
+	"github.com/LonleySailor/privatepaste/backend/pkg/importer"
+	"github.com/LonleySailor/privatepaste/backend/pkg/utils"
+	"github.com/LonleySailor/privatepaste/backend/pkg/validation"
+)
+
+// Import request limits
+const (
+	maxImportArchiveSize = 20 << 20
+	maxImportURLs        = 20
+)
+
+// PasteFetcher downloads a paste from another service by URL
+type PasteFetcher interface {
+	Fetch(pasteURL string) (*importer.Paste, error)
+}
+
+// ImportHandler handles importing pastes from other paste services
+type ImportHandler struct {
+	pasteRepo   PasteRepositoryInterface
+	idGenerator *utils.IDGenerator
+	validator   *validation.Validator
+	fetcher     PasteFetcher
+	creator     *PasteCreator
+}
+
+// NewImportHandler creates a new import handler
+func NewImportHandler(pasteRepo PasteRepositoryInterface, idGenerator *utils.IDGenerator, validator *validation.Validator, fetcher PasteFetcher) *ImportHandler {
+	return &ImportHandler{
+		pasteRepo:   pasteRepo,
+		idGenerator: idGenerator,
+		validator:   validator,
+		fetcher:     fetcher,
+		creator:     NewPasteCreator(pasteRepo),
+	}
+}
+
+// SetPasteCreator replaces the default creator, which applies no content
+// policies, with one that enforces them
+func (h *ImportHandler) SetPasteCreator(creator *PasteCreator) {
+	h.creator = creator
+}
+
+// ImportURLsRequest represents a request to import pastes by URL
+type ImportURLsRequest struct {
+	URLs []string `json:"urls"`
+}
+
+// ImportedPaste reports a paste created by an import
+type ImportedPaste struct {
+	Source    string `json:"source"`
+	ID        string `json:"id"`
+	Title     string `json:"title,omitempty"`
+	ExpiresAt string `json:"expires_at,omitempty"`
+}
+
+// SkippedImport reports a source that could not be imported
+type SkippedImport struct {
+	Source string `json:"source"`
+	Reason string `json:"reason"`
+}
+
+// ImportResponse represents the result of an import
+type ImportResponse struct {
+	Imported []ImportedPaste `json:"imported"`
+	Skipped  []SkippedImport `json:"skipped"`
+}
+
+// Import handles importing pastes into the authenticated user's account. A
+// zip body is read as a pastebin.com or Ghostbin export archive; a JSON body
+// lists pastebin.com or Ghostbin paste URLs to fetch. Expiry, visibility and
+// syntax are carried over where the source provides them. Sources that cannot
+// be imported are reported as skipped rather than failing the whole import.
+func (h *ImportHandler) Import(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		WriteError(w, &APIError{
+			Code:    "method_not_allowed",
+			Message: "Method not allowed",
+			Status:  http.StatusMethodNotAllowed,
+		})
+		return
+	}
+
+	userID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		WriteError(w, &APIError{
+			Code:    "unauthorized",
+			Message: "User ID not found in token",
+			Status:  http.StatusUnauthorized,
+		})
+		return
+	}
+
+	response := ImportResponse{Imported: []ImportedPaste{}, Skipped: []SkippedImport{}}
+	var pastes []importer.Paste
+
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "application/json") {
+		var req ImportURLsRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			WriteError(w, ErrInvalidJSON)
+			return
+		}
+		if len(req.URLs) == 0 || len(req.URLs) > maxImportURLs {
+			WriteValidationError(w, []validation.ValidationError{{Field: "urls", Message: "must list between 1 and 20 URLs"}})
+			return
+		}
+		for _, u := range req.URLs {
+			paste, err := h.fetcher.Fetch(u)
+			if err != nil {
+				response.Skipped = append(response.Skipped, SkippedImport{Source: u, Reason: err.Error()})
+				continue
+			}
+			pastes = append(pastes, *paste)
+		}
+	} else {
+		data, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxImportArchiveSize))
+		if err != nil {
+			WriteBodyError(w, err, ErrContentTooLarge)
+			return
+		}
+		if pastes, err = importer.ReadArchive(data, time.Now()); err != nil {
+			WriteValidationError(w, []validation.ValidationError{{Field: "archive", Message: err.Error()}})
+			return
+		}
+	}
+
+	for _, imported := range pastes {
+		if reason := h.checkImport(imported); reason != "" {
+			response.Skipped = append(response.Skipped, SkippedImport{Source: imported.Source, Reason: reason})
+			continue
+		}
+
+		paste, err := h.store(r, imported, userID)
+		var violation *services.PolicyViolation
+		if errors.As(err, &violation) {
+			response.Skipped = append(response.Skipped, SkippedImport{Source: imported.Source, Reason: violation.Message})
+			continue
+		}
+		if err != nil {
+			WriteRepositoryError(w, err)
+			return
+		}
+
+		result := ImportedPaste{Source: imported.Source, ID: paste.ID, Title: imported.Title}
+		if paste.ExpiresAt != nil {
+			result.ExpiresAt = paste.ExpiresAt.Format(time.RFC3339)
+		}
+		response.Imported = append(response.Imported, result)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
+
+// checkImport returns why an imported paste cannot be stored, or "" if it can
+func (h *ImportHandler) checkImport(paste importer.Paste) string {
+	if err := h.validator.ValidatePasteContent(paste.Content); err != nil {
+		return "content " + err.Message
+	}
+	if err := h.validator.ValidateLanguage(paste.Language); err != nil {
+		return "language " + err.Message
+	}
+	return ""
+}
+
+// store creates a paste owned by the user from an imported paste, through
+// the same creation path as the paste API
+func (h *ImportHandler) store(r *http.Request, imported importer.Paste, userID int) (*models.Paste, error) {
+	id, err := generatePasteID(h.idGenerator, h.pasteRepo, imported.Visibility)
+	if err != nil {
+		return nil, err
+	}
+
+	paste := &models.Paste{
+		ID:          id,
+		Content:     imported.Content,
+		Language:    imported.Language,
+		ExpiresAt:   imported.ExpiresAt,
+		UserID:      &userID,
+		Kind:        models.KindText,
+		Visibility:  imported.Visibility,
+		LineNumbers: true,
+	}
+	err = h.creator.CreateFromRequest(r, paste, services.PasteAttributes{ExpiresAt: paste.ExpiresAt != nil})
+	if err != nil {
+		return nil, err
+	}
+	return paste, nil
+}
@@ -0,0 +1,141 @@
+package handlers
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/LonleySailor/privatepaste/backend/internal/models"
+	"github.com/LonleySailor/privatepaste/backend/pkg/importer"
+	"github.com/LonleySailor/privatepaste/backend/pkg/utils"
+	"github.com/LonleySailor/privatepaste/backend/pkg/validation"
+)
+
+// stubFetcher returns canned pastes keyed by URL
+type stubFetcher map[string]*importer.Paste
+
+func (f stubFetcher) Fetch(pasteURL string) (*importer.Paste, error) {
+	if paste, ok := f[pasteURL]; ok {
+		return paste, nil
+	}
+	return nil, errors.New("not found")
+}
+
+func runImport(t *testing.T, fetcher PasteFetcher, contentType string, body []byte) (*httptest.ResponseRecorder, *models.MemoryPasteRepository) {
+	repo := models.NewMemoryPasteRepository()
+	handler := NewImportHandler(repo, utils.NewIDGenerator(), validation.NewValidator(), fetcher)
+
+	req := httptest.NewRequest("POST", "/api/user/import", bytes.NewReader(body))
+	req.Header.Set("Content-Type", contentType)
+	req = req.WithContext(context.WithValue(req.Context(), "userID", 7))
+
+	rr := httptest.NewRecorder()
+	handler.Import(rr, req)
+	return rr, repo
+}
+
+func TestImport_URLs(t *testing.T) {
+	fetcher := stubFetcher{
+		"https://pastebin.com/AbCd1234": {Source: "https://pastebin.com/AbCd1234", Title: "AbCd1234", Content: "hello", Visibility: importer.VisibilityPublic},
+		"https://pastebin.com/Empty000": {Source: "https://pastebin.com/Empty000", Content: "", Visibility: importer.VisibilityPublic},
+	}
+	body, _ := json.Marshal(ImportURLsRequest{URLs: []string{
+		"https://pastebin.com/AbCd1234",
+		"https://pastebin.com/Empty000",
+		"https://pastebin.com/Missing0",
+	}})
+
+	rr, repo := runImport(t, fetcher, "application/json", body)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+
+	var response ImportResponse
+	if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Failed to parse response: %v", err)
+	}
+	if len(response.Imported) != 1 || len(response.Skipped) != 2 {
+		t.Fatalf("Expected 1 imported and 2 skipped, got %+v", response)
+	}
+
+	paste, _ := repo.GetByID(response.Imported[0].ID)
+	if paste == nil || paste.Content != "hello" || paste.UserID == nil || *paste.UserID != 7 {
+		t.Errorf("Expected the paste to be stored under the importing user, got %+v", paste)
+	}
+}
+
+func TestImport_Archive(t *testing.T) {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	f, _ := zw.Create("pastes.xml")
+	f.Write([]byte(`<paste><paste_key>Priv0001</paste_key><paste_expire_date>0</paste_expire_date><paste_private>2</paste_private><paste_format_short>python</paste_format_short></paste>`))
+	f, _ = zw.Create("Priv0001.txt")
+	f.Write([]byte("print('hi')"))
+	zw.Close()
+
+	rr, repo := runImport(t, stubFetcher{}, "application/zip", buf.Bytes())
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+
+	var response ImportResponse
+	json.Unmarshal(rr.Body.Bytes(), &response)
+	if len(response.Imported) != 1 {
+		t.Fatalf("Expected 1 imported paste, got %+v", response)
+	}
+
+	paste, _ := repo.GetByID(response.Imported[0].ID)
+	if paste == nil || paste.Language != "python" || paste.Visibility != models.VisibilityPrivate || paste.ExpiresAt != nil {
+		t.Errorf("Unexpected imported paste: %+v", paste)
+	}
+}
+
+func TestImport_InvalidArchive(t *testing.T) {
+	rr, _ := runImport(t, stubFetcher{}, "application/zip", []byte("not a zip"))
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected status %d, got %d", http.StatusBadRequest, rr.Code)
+	}
+}
+
+func TestImport_CreationPath(t *testing.T) {
+	zone := time.FixedZone("CEST", 2*60*60)
+	soon, far := time.Now().Add(time.Hour).In(zone), time.Now().AddDate(2, 0, 0)
+	fetcher := stubFetcher{
+		"https://pastebin.com/Soon0000": {Source: "https://pastebin.com/Soon0000", Content: "soon", Visibility: importer.VisibilityPublic, ExpiresAt: &soon},
+		"https://pastebin.com/Far00000": {Source: "https://pastebin.com/Far00000", Content: "far", Visibility: importer.VisibilityPublic, ExpiresAt: &far},
+	}
+	repo := models.NewMemoryPasteRepository()
+	handler := NewImportHandler(repo, utils.NewIDGenerator(), validation.NewValidator(), fetcher)
+	queue := &recordingQueue{}
+	creator := NewPasteCreator(repo)
+	creator.SetIPHasher(utils.NewIPHasher("test-secret", 0))
+	creator.SetHookQueue(queue)
+	handler.SetPasteCreator(creator)
+
+	body, _ := json.Marshal(ImportURLsRequest{URLs: []string{"https://pastebin.com/Soon0000", "https://pastebin.com/Far00000"}})
+	req := httptest.NewRequest("POST", "/api/user/import", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req = req.WithContext(context.WithValue(req.Context(), "userID", 7))
+	rr := httptest.NewRecorder()
+	handler.Import(rr, req)
+
+	var response ImportResponse
+	json.Unmarshal(rr.Body.Bytes(), &response)
+	if len(response.Imported) != 1 || len(response.Skipped) != 1 || response.Skipped[0].Source != "https://pastebin.com/Far00000" {
+		t.Fatalf("Expected the paste expiring beyond a year to be skipped, got %+v", response)
+	}
+
+	paste, _ := repo.GetByID(response.Imported[0].ID)
+	if paste == nil || paste.ExpiresAt.Location() != time.UTC || paste.CreatorIPHash == nil {
+		t.Errorf("Expected a UTC expiry and a creator IP hash, got %+v", paste)
+	}
+	if len(queue.jobs) != 1 {
+		t.Errorf("Expected post-create hooks queued for the imported paste, got %d jobs", len(queue.jobs))
+	}
+}
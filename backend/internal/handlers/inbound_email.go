@@ -0,0 +1,220 @@
+package handlers
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/mail"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/LonleySailor/privatepaste/backend/internal/models"
+	"github.com/LonleySailor/privatepaste/backend/internal/services"
+	"github.com/LonleySailor/privatepaste/backend/pkg/importer"
+	"github.com/LonleySailor/privatepaste/backend/pkg/utils"
+	"github.com/LonleySailor/privatepaste/backend/pkg/validation"
+)
+
+// Inbound email limits
+const (
+	maxInboundEmailSize   = 10 << 20
+	maxInboundAttachments = 10
+)
+
+// SenderLookup finds the account that verified an email address
+type SenderLookup interface {
+	GetByVerifiedEmail(email string) (*models.User, error)
+}
+
+// InboundEmailHandler turns email received by a mail provider's inbound
+// webhook into pastes
+type InboundEmailHandler struct {
+	users       SenderLookup
+	pasteRepo   PasteRepositoryInterface
+	idGenerator *utils.IDGenerator
+	validator   *validation.Validator
+	queue       JobEnqueuer
+	secret      string
+	creator     *PasteCreator
+}
+
+// NewInboundEmailHandler creates a new inbound email handler. Requests must
+// carry secret in the token query parameter. A nil queue disables replies.
+func NewInboundEmailHandler(users SenderLookup, pasteRepo PasteRepositoryInterface, idGenerator *utils.IDGenerator, validator *validation.Validator, queue JobEnqueuer, secret string) *InboundEmailHandler {
+	return &InboundEmailHandler{
+		users:       users,
+		pasteRepo:   pasteRepo,
+		idGenerator: idGenerator,
+		validator:   validator,
+		queue:       queue,
+		secret:      secret,
+		creator:     NewPasteCreator(pasteRepo),
+	}
+}
+
+// SetPasteCreator replaces the default creator, which applies no content
+// policies, with one that enforces the sender's. Their policy may forbid
+// turning attachments into pastes.
+func (h *InboundEmailHandler) SetPasteCreator(creator *PasteCreator) {
+	h.creator = creator
+}
+
+// inboundPaste is a paste taken from an email body or attachment
+type inboundPaste struct {
+	content    string
+	language   string
+	attachment bool
+}
+
+// Receive handles an inbound email posted as multipart form data in the
+// common provider format: sender, subject, body-plain and file attachments.
+// The text body and each text attachment become unlisted pastes owned by the
+// account that verified the sender's address, and the sender is emailed the
+// links. Mail from unknown senders is accepted and dropped so it cannot be
+// used to probe which addresses are registered. The provider is trusted to
+// have checked SPF and DKIM before posting.
+func (h *InboundEmailHandler) Receive(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		WriteError(w, &APIError{
+			Code:    "method_not_allowed",
+			Message: "Method not allowed",
+			Status:  http.StatusMethodNotAllowed,
+		})
+		return
+	}
+
+	if subtle.ConstantTimeCompare([]byte(r.URL.Query().Get("token")), []byte(h.secret)) != 1 {
+		WriteError(w, &APIError{
+			Code:    "unauthorized",
+			Message: "Invalid inbound email token",
+			Status:  http.StatusUnauthorized,
+		})
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxInboundEmailSize)
+	if err := r.ParseMultipartForm(maxInboundEmailSize); err != nil {
+		WriteBodyError(w, err, ErrContentTooLarge)
+		return
+	}
+
+	created := []string{}
+	sender, err := mail.ParseAddress(firstNonEmpty(r.FormValue("sender"), r.FormValue("from")))
+	if err != nil {
+		WriteValidationError(w, []validation.ValidationError{{Field: "sender", Message: "must be an email address"}})
+		return
+	}
+
+	user, err := h.users.GetByVerifiedEmail(sender.Address)
+	if err != nil {
+		WriteRepositoryError(w, err)
+		return
+	}
+	// Mail from unknown senders and deactivated accounts is dropped silently
+	if user == nil || !user.IsActive() {
+		writeInboundResult(w, created)
+		return
+	}
+
+	for _, p := range h.collect(r) {
+		id, err := generatePasteID(h.idGenerator, h.pasteRepo, models.VisibilityUnlisted)
+		if err != nil {
+			WriteError(w, ErrIDGenerationFailed)
+			return
+		}
+		paste := &models.Paste{
+			ID:          id,
+			Content:     p.content,
+			Language:    p.language,
+			UserID:      &user.ID,
+			Kind:        models.KindText,
+			Visibility:  models.VisibilityUnlisted,
+			LineNumbers: true,
+		}
+		attrs := services.PasteAttributes{}
+		if p.attachment {
+			attrs.Attachments = 1
+		}
+		// Pastes the sender's content policy refuses are left out
+		var violation *services.PolicyViolation
+		err = h.creator.Create(paste, user.Role, attrs)
+		if errors.As(err, &violation) {
+			continue
+		}
+		if err != nil {
+			WriteRepositoryError(w, err)
+			return
+		}
+		created = append(created, paste.ID)
+	}
+
+	if h.queue != nil && len(created) > 0 {
+		var body strings.Builder
+		body.WriteString("Your email was saved as:\n")
+		for _, id := range created {
+			body.WriteString(PasteURL(id) + "\n")
+		}
+		subject := "Re: " + r.FormValue("subject")
+		if err := h.queue.Enqueue(services.JobTypeEmail, services.EmailMessage{To: sender.Address, Subject: subject, Body: body.String()}); err != nil {
+			WriteRepositoryError(w, err)
+			return
+		}
+	}
+
+	writeInboundResult(w, created)
+}
+
+// collect returns the pastes in an email: the plain text body and every
+// attachment that is valid UTF-8 text within the paste size limit
+func (h *InboundEmailHandler) collect(r *http.Request) []inboundPaste {
+	var pastes []inboundPaste
+	allowed := func(content string) bool {
+		return h.validator.ValidatePasteContent(content) == nil
+	}
+
+	body := firstNonEmpty(r.FormValue("body-plain"), r.FormValue("text"))
+	if strings.TrimSpace(body) != "" && allowed(body) {
+		pastes = append(pastes, inboundPaste{content: body})
+	}
+
+	attachments := 0
+	for _, files := range r.MultipartForm.File {
+		for _, fh := range files {
+			if attachments == maxInboundAttachments {
+				return pastes
+			}
+			attachments++
+
+			f, err := fh.Open()
+			if err != nil {
+				continue
+			}
+			data, err := io.ReadAll(io.LimitReader(f, importer.MaxPasteSize+1))
+			f.Close()
+			if err != nil || !utf8.Valid(data) || !allowed(string(data)) {
+				continue
+			}
+			pastes = append(pastes, inboundPaste{content: string(data), language: importer.LanguageForFilename(fh.Filename), attachment: true})
+		}
+	}
+	return pastes
+}
+
+// writeInboundResult reports the pastes created from an email
+func writeInboundResult(w http.ResponseWriter, created []string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{"created": created})
+}
+
+// firstNonEmpty returns the first non-empty value
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
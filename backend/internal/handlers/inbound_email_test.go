@@ -0,0 +1,96 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/LonleySailor/privatepaste/backend/internal/models"
+	"github.com/LonleySailor/privatepaste/backend/internal/services"
+	"github.com/LonleySailor/privatepaste/backend/pkg/utils"
+	"github.com/LonleySailor/privatepaste/backend/pkg/validation"
+)
+
+type stubSenders map[string]*models.User
+
+func (s stubSenders) GetByVerifiedEmail(email string) (*models.User, error) {
+	return s[strings.ToLower(email)], nil
+}
+
+func TestInboundEmailReceive(t *testing.T) {
+	pasteRepo := models.NewMemoryPasteRepository()
+	queue := &recordingQueue{}
+	deactivatedAt := time.Now()
+	senders := stubSenders{
+		"alice@example.com": {ID: 7, Username: "alice"},
+		"carol@example.com": {ID: 9, Username: "carol", DeactivatedAt: &deactivatedAt},
+	}
+	handler := NewInboundEmailHandler(senders, pasteRepo, utils.NewIDGenerator(), validation.NewValidator(), queue, "inbound-secret")
+
+	receive := func(token, sender string) (int, []string) {
+		var body bytes.Buffer
+		form := multipart.NewWriter(&body)
+		form.WriteField("sender", sender)
+		form.WriteField("subject", "logs")
+		form.WriteField("body-plain", "hello from email")
+		part, _ := form.CreateFormFile("attachment-1", "main.go")
+		part.Write([]byte("package main\n"))
+		part, _ = form.CreateFormFile("attachment-2", "photo.jpg")
+		part.Write([]byte{0xff, 0xd8, 0xff})
+		form.Close()
+
+		r := httptest.NewRequest("POST", "/api/inbound/email?token="+token, &body)
+		r.Header.Set("Content-Type", form.FormDataContentType())
+		rr := httptest.NewRecorder()
+		handler.Receive(rr, r)
+
+		var resp struct {
+			Created []string `json:"created"`
+		}
+		json.NewDecoder(rr.Body).Decode(&resp)
+		return rr.Code, resp.Created
+	}
+
+	if code, _ := receive("wrong", "Alice <alice@example.com>"); code != http.StatusUnauthorized {
+		t.Fatalf("Expected a bad token to be rejected, got %d", code)
+	}
+
+	code, created := receive("inbound-secret", "Alice <ALICE@example.com>")
+	if code != http.StatusOK || len(created) != 2 {
+		t.Fatalf("Expected the body and text attachment as pastes, got %d %v", code, created)
+	}
+	for _, id := range created {
+		paste, _ := pasteRepo.GetByID(id)
+		if paste == nil || !paste.IsOwnedBy(7) || paste.Visibility != models.VisibilityUnlisted {
+			t.Errorf("Expected an unlisted paste owned by the sender, got %+v", paste)
+		}
+	}
+	if paste, _ := pasteRepo.GetByID(created[1]); paste.Language != "go" {
+		t.Errorf("Expected the attachment's language from its filename, got %q", paste.Language)
+	}
+
+	if len(queue.jobs) != 1 {
+		t.Fatalf("Expected one reply, got %d", len(queue.jobs))
+	}
+	reply := queue.jobs[0].(services.EmailMessage)
+	if reply.To != "ALICE@example.com" || reply.Subject != "Re: logs" || !strings.Contains(reply.Body, created[0]) {
+		t.Errorf("Unexpected reply %+v", reply)
+	}
+
+	code, created = receive("inbound-secret", "mallory@example.com")
+	if code != http.StatusOK || len(created) != 0 || len(queue.jobs) != 1 {
+		t.Errorf("Expected mail from an unknown sender to be dropped, got %d %v", code, created)
+	}
+	code, created = receive("inbound-secret", "carol@example.com")
+	if code != http.StatusOK || len(created) != 0 || len(queue.jobs) != 1 {
+		t.Errorf("Expected mail from a deactivated account to be dropped, got %d %v", code, created)
+	}
+	if pastes, _ := pasteRepo.GetByUserID(9, 100, 0); len(pastes) != 0 {
+		t.Errorf("Expected no pastes for a deactivated account, got %d", len(pastes))
+	}
+}
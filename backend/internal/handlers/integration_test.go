@@ -0,0 +1,257 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/LonleySailor/privatepaste/backend/internal/models"
+	"github.com/LonleySailor/privatepaste/backend/internal/services"
+	"github.com/LonleySailor/privatepaste/backend/pkg/validation"
+	"github.com/gorilla/mux"
+)
+
+// stubIntegrations is an in-memory IntegrationStore
+type stubIntegrations []*models.Integration
+
+func (s *stubIntegrations) Create(i *models.Integration) error {
+	i.ID = int64(len(*s) + 1)
+	*s = append(*s, i)
+	return nil
+}
+
+func (s *stubIntegrations) ListByUserID(userID int) ([]*models.Integration, error) {
+	var owned []*models.Integration
+	for _, i := range *s {
+		if i.UserID == userID {
+			owned = append(owned, i)
+		}
+	}
+	return owned, nil
+}
+
+func (s *stubIntegrations) GetByName(userID int, name string) (*models.Integration, error) {
+	for _, i := range *s {
+		if i.UserID == userID && i.Name == name {
+			return i, nil
+		}
+	}
+	return nil, nil
+}
+
+func (s *stubIntegrations) GetByID(userID int, id int64) (*models.Integration, error) {
+	for _, i := range *s {
+		if i.UserID == userID && i.ID == id {
+			return i, nil
+		}
+	}
+	return nil, nil
+}
+
+func (s *stubIntegrations) RotateSecret(userID int, id int64, grace time.Duration) (string, error) {
+	i, _ := s.GetByID(userID, id)
+	if i == nil {
+		return "", nil
+	}
+	expires := time.Now().Add(grace)
+	i.PreviousSigningSecret, i.PreviousSecretExpiresAt = i.SigningSecret, &expires
+	i.SigningSecret = i.SigningSecret + "-rotated"
+	return i.SigningSecret, nil
+}
+
+func (s *stubIntegrations) Delete(userID int, id int64) (bool, error) {
+	return false, nil
+}
+
+// stubDeliveries is an in-memory WebhookDeliveryStore
+type stubDeliveries []*models.WebhookDeliveryAttempt
+
+func (s *stubDeliveries) Record(a *models.WebhookDeliveryAttempt) error {
+	a.ID = int64(len(*s) + 1)
+	*s = append(*s, a)
+	return nil
+}
+
+func (s *stubDeliveries) ListByIntegration(integrationID int64, limit, offset int) ([]*models.WebhookDeliveryAttempt, error) {
+	var attempts []*models.WebhookDeliveryAttempt
+	for i := len(*s) - 1; i >= 0; i-- {
+		if (*s)[i].IntegrationID == integrationID {
+			attempts = append(attempts, (*s)[i])
+		}
+	}
+	if offset >= len(attempts) {
+		return nil, nil
+	}
+	return attempts[offset:min(offset+limit, len(attempts))], nil
+}
+
+func (s *stubDeliveries) CountByIntegration(integrationID int64) (int, error) {
+	attempts, _ := s.ListByIntegration(integrationID, len(*s), 0)
+	return len(attempts), nil
+}
+
+func (s *stubDeliveries) GetByID(integrationID, id int64) (*models.WebhookDeliveryAttempt, error) {
+	for _, a := range *s {
+		if a.IntegrationID == integrationID && a.ID == id {
+			return a, nil
+		}
+	}
+	return nil, nil
+}
+
+// recordingQueue records enqueued jobs instead of running them
+type recordingQueue struct {
+	jobs []interface{}
+}
+
+func (q *recordingQueue) Enqueue(jobType string, payload interface{}) error {
+	q.jobs = append(q.jobs, payload)
+	return nil
+}
+
+func TestIntegrationShare(t *testing.T) {
+	pasteRepo := models.NewMemoryPasteRepository()
+	ownerID := 1
+	pasteRepo.Create(&models.Paste{ID: "shr001", Content: "\n  Deploy notes\nstep 1", Language: "markdown", UserID: &ownerID, Visibility: models.VisibilityPublic})
+
+	integrations := &stubIntegrations{}
+	queue := &recordingQueue{}
+	handler := NewIntegrationHandler(integrations, &stubDeliveries{}, pasteRepo, queue, validation.NewValidator())
+
+	withUser := func(req *http.Request) *http.Request {
+		ctx := context.WithValue(req.Context(), "userID", ownerID)
+		ctx = context.WithValue(ctx, "username", "alice")
+		return req.WithContext(ctx)
+	}
+
+	body, _ := json.Marshal(CreateIntegrationRequest{Name: "team", Platform: "slack", WebhookURL: "https://evil.example.com/services/x"})
+	rr := httptest.NewRecorder()
+	handler.Create(rr, withUser(httptest.NewRequest("POST", "/api/user/integrations", bytes.NewReader(body))))
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("Expected a non-Slack URL to be rejected, got %d", rr.Code)
+	}
+
+	body, _ = json.Marshal(CreateIntegrationRequest{Name: "team", Platform: "slack", WebhookURL: "https://hooks.slack.com/services/T0/B0/secret"})
+	rr = httptest.NewRecorder()
+	handler.Create(rr, withUser(httptest.NewRequest("POST", "/api/user/integrations", bytes.NewReader(body))))
+	if rr.Code != http.StatusCreated || strings.Contains(rr.Body.String(), "B0/secret") {
+		t.Fatalf("Expected the integration to be created without echoing the URL, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	share := func(integration string) *httptest.ResponseRecorder {
+		req := withUser(httptest.NewRequest("POST", "/api/paste/shr001/share/"+integration, nil))
+		req = mux.SetURLVars(req, map[string]string{"id": "shr001", "integration": integration})
+		rr := httptest.NewRecorder()
+		handler.Share(rr, req)
+		return rr
+	}
+
+	if rr := share("missing"); rr.Code != http.StatusNotFound {
+		t.Errorf("Expected unknown integration to return 404, got %d", rr.Code)
+	}
+
+	if rr := share("team"); rr.Code != http.StatusAccepted {
+		t.Fatalf("Expected the share to be queued, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if len(queue.jobs) != 1 {
+		t.Fatalf("Expected one queued delivery, got %d", len(queue.jobs))
+	}
+	delivery := queue.jobs[0].(services.WebhookDelivery)
+	if delivery.URL != "https://hooks.slack.com/services/T0/B0/secret" {
+		t.Errorf("Unexpected delivery URL %q", delivery.URL)
+	}
+	for _, want := range []string{"Deploy notes", "markdown", "shr001", "alice"} {
+		if !strings.Contains(string(delivery.Body), want) {
+			t.Errorf("Expected %q in message %s", want, delivery.Body)
+		}
+	}
+}
+
+func TestIntegrationRotateAndTest(t *testing.T) {
+	var signature string
+	status := http.StatusOK
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		signature = r.Header.Get(services.WebhookSignatureHeader)
+		w.WriteHeader(status)
+	}))
+	defer receiver.Close()
+
+	ownerID := 1
+	integrations := &stubIntegrations{{ID: 1, UserID: ownerID, Name: "team", Platform: "slack", WebhookURL: receiver.URL, SigningSecret: "whsec_old"}}
+	deliveries := &stubDeliveries{}
+	queue := &recordingQueue{}
+	handler := NewIntegrationHandler(integrations, deliveries, models.NewMemoryPasteRepository(), queue, validation.NewValidator())
+	handler.client = receiver.Client()
+
+	call := func(action func(http.ResponseWriter, *http.Request), id string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/api/user/integrations/"+id, nil)
+		req = mux.SetURLVars(req, map[string]string{"id": id, "delivery": "1"})
+		req = req.WithContext(context.WithValue(req.Context(), "userID", ownerID))
+		rr := httptest.NewRecorder()
+		action(rr, req)
+		return rr
+	}
+
+	rr := call(handler.RotateSecret, "1")
+	var rotated RotateSecretResponse
+	json.NewDecoder(rr.Body).Decode(&rotated)
+	if rr.Code != http.StatusOK || rotated.SigningSecret != "whsec_old-rotated" {
+		t.Fatalf("Expected a new secret, got %d %+v", rr.Code, rotated)
+	}
+	if rr := call(handler.RotateSecret, "2"); rr.Code != http.StatusNotFound {
+		t.Errorf("Expected an unknown integration to return 404, got %d", rr.Code)
+	}
+
+	rr = call(handler.SendTest, "1")
+	var result TestDeliveryResponse
+	json.NewDecoder(rr.Body).Decode(&result)
+	if rr.Code != http.StatusOK || !result.Delivered || result.StatusCode != http.StatusOK {
+		t.Fatalf("Expected a successful test delivery, got %d %+v", rr.Code, result)
+	}
+	// Both secrets sign deliveries during the grace period
+	if parts := strings.Split(signature, ","); len(parts) != 2 || !strings.HasPrefix(parts[0], "v1=") {
+		t.Errorf("Expected signatures with the new and old secret, got %q", signature)
+	}
+
+	status = http.StatusGone
+	rr = call(handler.SendTest, "1")
+	result = TestDeliveryResponse{}
+	json.NewDecoder(rr.Body).Decode(&result)
+	if result.Delivered || result.StatusCode != http.StatusGone || result.Error == "" {
+		t.Errorf("Expected the failed delivery to be reported, got %+v", result)
+	}
+
+	// Both attempts are in the delivery log, newest first
+	req := httptest.NewRequest("GET", "/api/user/integrations/1/deliveries?limit=1", nil)
+	req = mux.SetURLVars(req, map[string]string{"id": "1"})
+	req = req.WithContext(context.WithValue(req.Context(), "userID", ownerID))
+	rr = httptest.NewRecorder()
+	handler.ListDeliveries(rr, req)
+	var page WebhookDeliveriesResponse
+	json.NewDecoder(rr.Body).Decode(&page)
+	if rr.Code != http.StatusOK || page.Total != 2 || len(page.Deliveries) != 1 {
+		t.Fatalf("Expected one of two logged deliveries, got %d %+v", rr.Code, page)
+	}
+	if latest := page.Deliveries[0]; latest.Success || latest.StatusCode == nil || *latest.StatusCode != http.StatusGone || latest.Event != models.WebhookEventTest {
+		t.Errorf("Expected the failed test delivery first, got %+v", latest)
+	}
+
+	if rr := call(handler.Redeliver, "1"); rr.Code != http.StatusAccepted {
+		t.Fatalf("Expected the redelivery to be queued, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if len(queue.jobs) != 1 {
+		t.Fatalf("Expected one queued delivery, got %d", len(queue.jobs))
+	}
+	redelivery := queue.jobs[0].(services.WebhookDelivery)
+	if redelivery.IntegrationID != 1 || redelivery.URL != receiver.URL || string(redelivery.Body) != string((*deliveries)[0].Body) {
+		t.Errorf("Unexpected redelivery %+v", redelivery)
+	}
+	if rr := call(handler.Redeliver, "2"); rr.Code != http.StatusNotFound {
+		t.Errorf("Expected an unknown integration to return 404, got %d", rr.Code)
+	}
+}
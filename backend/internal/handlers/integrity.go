@@ -0,0 +1,77 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"github.com/LonleySailor/privatepaste/backend/internal/middleware"
+	"github.com/LonleySailor/privatepaste/backend/internal/models"
+)
+
+// IntegrityChecker finds, and optionally repairs, inconsistent rows
+type IntegrityChecker interface {
+	Check(repair bool) ([]*models.IntegrityIssue, error)
+}
+
+// IntegrityHandler handles admin requests to check the database's integrity
+type IntegrityHandler struct {
+	checker IntegrityChecker
+}
+
+// NewIntegrityHandler creates a new integrity handler
+func NewIntegrityHandler(checker IntegrityChecker) *IntegrityHandler {
+	return &IntegrityHandler{checker: checker}
+}
+
+// IntegrityReport lists the problems found by an integrity check; an empty
+// list means none were found
+type IntegrityReport struct {
+	Issues   []*models.IntegrityIssue `json:"issues"`
+	Repaired bool                     `json:"repaired"` // Whether repairable issues were fixed
+}
+
+// Check handles reporting integrity problems without changing anything
+func (h *IntegrityHandler) Check(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		WriteError(w, &APIError{
+			Code:    "method_not_allowed",
+			Message: "Method not allowed",
+			Status:  http.StatusMethodNotAllowed,
+		})
+		return
+	}
+
+	h.run(w, false)
+}
+
+// Repair handles fixing the integrity problems that can be fixed safely,
+// reporting everything found
+func (h *IntegrityHandler) Repair(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		WriteError(w, &APIError{
+			Code:    "method_not_allowed",
+			Message: "Method not allowed",
+			Status:  http.StatusMethodNotAllowed,
+		})
+		return
+	}
+
+	adminID, _ := middleware.GetUserIDFromContext(r.Context())
+	log.Printf("Integrity repair requested by user %d", adminID)
+	h.run(w, true)
+}
+
+// run checks the database and writes the report
+func (h *IntegrityHandler) run(w http.ResponseWriter, repair bool) {
+	issues, err := h.checker.Check(repair)
+	if err != nil {
+		WriteRepositoryError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(IntegrityReport{Issues: issues, Repaired: repair})
+}
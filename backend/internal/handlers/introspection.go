@@ -0,0 +1,104 @@
+package handlers
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/LonleySailor/privatepaste/backend/internal/auth"
+	"github.com/LonleySailor/privatepaste/backend/internal/models"
+)
+
+// TokenUserLookup finds the users tokens were issued to
+type TokenUserLookup interface {
+	GetByID(id int) (*models.User, error)
+}
+
+// IntrospectionHandler lets trusted services such as sidecars and the
+// WebSocket gateway check access tokens without sharing the JWT secret
+type IntrospectionHandler struct {
+	tokenManager *auth.TokenManager
+	users        TokenUserLookup
+	secret       string
+}
+
+// NewIntrospectionHandler creates a new introspection handler. Services
+// authenticate with secret as a bearer token.
+func NewIntrospectionHandler(tokenManager *auth.TokenManager, users TokenUserLookup, secret string) *IntrospectionHandler {
+	return &IntrospectionHandler{tokenManager: tokenManager, users: users, secret: secret}
+}
+
+// IntrospectRequest carries the token to check
+type IntrospectRequest struct {
+	Token string `json:"token"`
+}
+
+// IntrospectResponse describes a token. Only Active is set for tokens that
+// are invalid, expired or belong to a deleted or deactivated user.
+type IntrospectResponse struct {
+	Active    bool     `json:"active"`
+	UserID    int      `json:"user_id,omitempty"`
+	Username  string   `json:"username,omitempty"`
+	Role      string   `json:"role,omitempty"`
+	Scopes    []string `json:"scopes,omitempty"`
+	ExpiresAt int64    `json:"exp,omitempty"` // Unix time
+	IssuedAt  int64    `json:"iat,omitempty"` // Unix time
+}
+
+// Introspect handles checking an access token on behalf of another service
+func (h *IntrospectionHandler) Introspect(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		WriteError(w, &APIError{
+			Code:    "method_not_allowed",
+			Message: "Method not allowed",
+			Status:  http.StatusMethodNotAllowed,
+		})
+		return
+	}
+
+	secret, _ := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if h.secret == "" || subtle.ConstantTimeCompare([]byte(secret), []byte(h.secret)) != 1 {
+		WriteError(w, &APIError{
+			Code:    "unauthorized",
+			Message: "Invalid introspection credentials",
+			Status:  http.StatusUnauthorized,
+		})
+		return
+	}
+
+	var req IntrospectRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		WriteError(w, ErrInvalidJSON)
+		return
+	}
+
+	response := IntrospectResponse{}
+	if claims, err := h.tokenManager.ValidateAccessToken(req.Token); err == nil {
+		// Tokens outlive deleted and deactivated accounts, so check the
+		// user still exists and may sign in
+		user, err := h.users.GetByID(claims.UserID)
+		if err != nil {
+			WriteError(w, ErrInternalServer)
+			return
+		}
+		if user != nil && user.IsActive() {
+			response = IntrospectResponse{
+				Active:    true,
+				UserID:    user.ID,
+				Username:  claims.Username,
+				Role:      claims.Role,
+				Scopes:    claims.Scopes(),
+				ExpiresAt: claims.ExpiresAt.Unix(),
+			}
+			if claims.IssuedAt != nil {
+				response.IssuedAt = claims.IssuedAt.Unix()
+			}
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
@@ -0,0 +1,115 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/LonleySailor/privatepaste/backend/internal/models"
+	"github.com/LonleySailor/privatepaste/backend/pkg/validation"
+	"github.com/gorilla/mux"
+)
+
+// LanguageHandler serves the per-language directory of public pastes
+// behind the topic landing pages
+type LanguageHandler struct {
+	pasteRepo *models.PasteRepository
+	validator *validation.Validator
+}
+
+// NewLanguageHandler creates a new language handler
+func NewLanguageHandler(pasteRepo *models.PasteRepository, validator *validation.Validator) *LanguageHandler {
+	return &LanguageHandler{pasteRepo: pasteRepo, validator: validator}
+}
+
+// LanguagesResponse lists the languages of public pastes, most used first
+type LanguagesResponse struct {
+	Languages []models.LanguageCount `json:"languages"`
+}
+
+// PublicPasteItem represents a publicly listed paste with its author
+type PublicPasteItem struct {
+	PasteListItem
+	Username string `json:"username,omitempty"` // Empty for anonymous pastes
+}
+
+// LanguagePastesResponse represents a page of public pastes in a language
+type LanguagePastesResponse struct {
+	Language string            `json:"language"`
+	Pastes   []PublicPasteItem `json:"pastes"`
+	Total    int               `json:"total"`
+	Page     int               `json:"page"`
+	Limit    int               `json:"limit"`
+}
+
+// ListLanguages handles listing every language with public pastes and how
+// many each has
+func (h *LanguageHandler) ListLanguages(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		WriteError(w, &APIError{
+			Code:    "method_not_allowed",
+			Message: "Method not allowed",
+			Status:  http.StatusMethodNotAllowed,
+		})
+		return
+	}
+
+	counts, err := h.pasteRepo.CountPublicByLanguage()
+	if err != nil {
+		WriteRepositoryError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(LanguagesResponse{Languages: counts})
+}
+
+// ListPastes handles listing recent public pastes in the language named in
+// the path, ignoring case. Supports page and limit query parameters.
+func (h *LanguageHandler) ListPastes(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		WriteError(w, &APIError{
+			Code:    "method_not_allowed",
+			Message: "Method not allowed",
+			Status:  http.StatusMethodNotAllowed,
+		})
+		return
+	}
+
+	language := mux.Vars(r)["lang"]
+	if err := h.validator.ValidateLanguage(language); err != nil {
+		WriteValidationError(w, []validation.ValidationError{*err})
+		return
+	}
+
+	page := 1
+	limit := 20
+	if p, err := strconv.Atoi(r.URL.Query().Get("page")); err == nil && p > 0 {
+		page = p
+	}
+	if l, err := strconv.Atoi(r.URL.Query().Get("limit")); err == nil && l > 0 && l <= 100 {
+		limit = l
+	}
+
+	pastes, total, err := h.pasteRepo.ListPublicByLanguage(language, limit, (page-1)*limit)
+	if err != nil {
+		WriteRepositoryError(w, err)
+		return
+	}
+
+	items := make([]PublicPasteItem, len(pastes))
+	for i, paste := range pastes {
+		items[i] = PublicPasteItem{PasteListItem: newPasteListItem(paste.Paste, false), Username: paste.Username}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(LanguagePastesResponse{
+		Language: language,
+		Pastes:   items,
+		Total:    total,
+		Page:     page,
+		Limit:    limit,
+	})
+}
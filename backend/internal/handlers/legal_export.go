@@ -0,0 +1,255 @@
+package handlers
+
+import (
+	"archive/zip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/LonleySailor/privatepaste/backend/internal/middleware"
+	"github.com/LonleySailor/privatepaste/backend/internal/models"
+	"github.com/LonleySailor/privatepaste/backend/pkg/utils"
+	"github.com/LonleySailor/privatepaste/backend/pkg/validation"
+	"github.com/gorilla/mux"
+)
+
+// Files of a legal export that describe and sign it
+const (
+	LegalExportManifest  = "manifest.json"
+	LegalExportSignature = "manifest.json.sig" // Base64 Ed25519 signature of the manifest
+)
+
+// LegalExportSources are where a user's data is gathered from for a legal
+// export. A nil Attachments leaves attachments out.
+type LegalExportSources struct {
+	Users       *models.UserRepository
+	Pastes      *models.PasteRepository
+	Attachments AttachmentStore
+	APIKeys     *models.APIKeyRepository
+	SSHKeys     *models.SSHKeyRepository
+	Events      *models.PasteEventRepository
+	Audit       *models.AuditRepository
+}
+
+// LegalExportHandler handles admin exports of everything stored about a
+// user, for subpoenas and data subject access requests
+type LegalExportHandler struct {
+	sources   LegalExportSources
+	signer    *utils.ResponseSigner
+	validator *validation.Validator
+}
+
+// NewLegalExportHandler creates a new legal export handler. Exports are
+// signed with the response signing key; a nil signer disables them.
+func NewLegalExportHandler(sources LegalExportSources, signer *utils.ResponseSigner, validator *validation.Validator) *LegalExportHandler {
+	return &LegalExportHandler{sources: sources, signer: signer, validator: validator}
+}
+
+// LegalExportRequest gives the reason for an export, such as a case or
+// ticket reference, which is kept in the audit log
+type LegalExportRequest struct {
+	Reason string `json:"reason"`
+}
+
+// LegalExportManifestFile lists the contents of a legal export. Every other
+// file is listed with its SHA-256 digest, and the manifest itself is signed,
+// so the export can be shown to be complete and unaltered.
+type LegalExportManifestFile struct {
+	UserID      int               `json:"user_id"`
+	Username    string            `json:"username"`
+	Reason      string            `json:"reason"`
+	RequestedBy int               `json:"requested_by"`
+	AuditID     int64             `json:"audit_id"`
+	GeneratedAt time.Time         `json:"generated_at"`
+	Algorithm   string            `json:"algorithm"`
+	KeyID       string            `json:"key_id"`
+	Files       map[string]string `json:"files"`
+}
+
+// hashingArchive records the SHA-256 digest of every file added to a zip
+type hashingArchive struct {
+	zipArchive
+	files map[string]string
+}
+
+func (a *hashingArchive) add(name string, modified time.Time, data []byte) error {
+	sum := sha256.Sum256(data)
+	a.files[name] = hex.EncodeToString(sum[:])
+	return a.zipArchive.add(name, modified, data)
+}
+
+// Export handles streaming a signed zip of a user's account, pastes with
+// their attachments, API and SSH keys, paste events and audit events. The
+// export is recorded in the audit log before anything is sent.
+func (h *LegalExportHandler) Export(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		WriteError(w, &APIError{
+			Code:    "method_not_allowed",
+			Message: "Method not allowed",
+			Status:  http.StatusMethodNotAllowed,
+		})
+		return
+	}
+
+	if h.signer == nil {
+		WriteError(w, &APIError{
+			Code:    "legal_export_disabled",
+			Message: "Legal exports require a response signing key to be configured",
+			Status:  http.StatusServiceUnavailable,
+		})
+		return
+	}
+
+	adminID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		WriteError(w, &APIError{
+			Code:    "unauthorized",
+			Message: "User ID not found in token",
+			Status:  http.StatusUnauthorized,
+		})
+		return
+	}
+
+	var req LegalExportRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		WriteError(w, ErrInvalidJSON)
+		return
+	}
+	req.Reason = strings.TrimSpace(req.Reason)
+	if err := h.validator.ValidateString(req.Reason, "reason", true, 1, 500); err != nil {
+		WriteValidationError(w, []validation.ValidationError{*err})
+		return
+	}
+
+	user, err := h.sources.Users.GetByUsername(mux.Vars(r)["username"])
+	if err != nil {
+		WriteRepositoryError(w, err)
+		return
+	}
+	if user == nil {
+		WriteError(w, &APIError{
+			Code:    "user_not_found",
+			Message: "User not found",
+			Status:  http.StatusNotFound,
+		})
+		return
+	}
+
+	// Gather the records before committing to a 200 so errors can still be
+	// reported normally; pastes are streamed afterwards
+	apiKeys, err := h.sources.APIKeys.ListByUserID(user.ID)
+	if err != nil {
+		WriteRepositoryError(w, err)
+		return
+	}
+	sshKeys, err := h.sources.SSHKeys.ListByUserID(user.ID)
+	if err != nil {
+		WriteRepositoryError(w, err)
+		return
+	}
+	events, err := h.sources.Events.ListByUserID(user.ID)
+	if err != nil {
+		WriteRepositoryError(w, err)
+		return
+	}
+
+	audit := &models.AuditEvent{ActorID: &adminID, Action: models.AuditLegalExport, TargetUserID: &user.ID, Details: req.Reason}
+	if err := h.sources.Audit.Record(audit); err != nil {
+		WriteRepositoryError(w, err)
+		return
+	}
+	auditEvents, err := h.sources.Audit.ListByUserID(user.ID)
+	if err != nil {
+		WriteRepositoryError(w, err)
+		return
+	}
+
+	now := time.Now().UTC()
+	filename := "pastevault-legal-export-" + user.Username + "-" + now.Format("20060102") + ".zip"
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", `attachment; filename="`+filename+`"`)
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusOK)
+
+	archive := &hashingArchive{zipArchive: zipArchive{zw: zip.NewWriter(w)}, files: map[string]string{}}
+	for name, records := range map[string]interface{}{
+		"user.json":         user,
+		"api_keys.json":     apiKeys,
+		"ssh_keys.json":     sshKeys,
+		"paste_events.json": events,
+		"audit_events.json": auditEvents,
+	} {
+		data, err := json.MarshalIndent(records, "", "  ")
+		if err != nil || archive.add(name, now, data) != nil {
+			return // The client sees a truncated archive
+		}
+	}
+
+	// Unlike the user's own export, expired pastes not yet deleted are included
+	rc := http.NewResponseController(w)
+	after := ""
+	for {
+		batch, err := h.sources.Pastes.ListByUserIDAfter(user.ID, after, exportBatchSize)
+		if err != nil {
+			return
+		}
+		for _, paste := range batch {
+			if writeExportedPaste(archive, paste) != nil || h.writeAttachments(archive, paste) != nil {
+				return
+			}
+			rc.Flush()
+		}
+		if len(batch) < exportBatchSize {
+			break
+		}
+		after = batch[len(batch)-1].ID
+	}
+
+	manifest, err := json.MarshalIndent(LegalExportManifestFile{
+		UserID:      user.ID,
+		Username:    user.Username,
+		Reason:      req.Reason,
+		RequestedBy: adminID,
+		AuditID:     audit.ID,
+		GeneratedAt: now,
+		Algorithm:   "ed25519",
+		KeyID:       h.signer.KeyID(),
+		Files:       archive.files,
+	}, "", "  ")
+	if err != nil {
+		return
+	}
+	if archive.add(LegalExportManifest, now, manifest) != nil ||
+		archive.add(LegalExportSignature, now, []byte(h.signer.Sign(manifest))) != nil {
+		return
+	}
+	archive.Close()
+}
+
+// writeAttachments adds a paste's attachments to the archive under
+// attachments/<paste id>/
+func (h *LegalExportHandler) writeAttachments(archive archiveWriter, paste *models.Paste) error {
+	if h.sources.Attachments == nil {
+		return nil
+	}
+	attachments, err := h.sources.Attachments.List(paste.ID)
+	if err != nil {
+		return err
+	}
+	for _, attachment := range attachments {
+		found, data, err := h.sources.Attachments.Get(paste.ID, attachment.Name)
+		if err != nil {
+			return err
+		}
+		if found == nil {
+			continue
+		}
+		if err := archive.add("attachments/"+paste.ID+"/"+found.Name, found.CreatedAt, data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
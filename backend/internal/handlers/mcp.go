@@ -0,0 +1,432 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/LonleySailor/privatepaste/backend/internal/auth"
+	"github.com/LonleySailor/privatepaste/backend/internal/models"
+	"github.com/LonleySailor/privatepaste/backend/internal/services"
+	"github.com/LonleySailor/privatepaste/backend/pkg/utils"
+	"github.com/LonleySailor/privatepaste/backend/pkg/validation"
+)
+
+// mcpProtocolVersion is the Model Context Protocol revision spoken by the
+// tool server
+const mcpProtocolVersion = "2025-03-26"
+
+// maxMCPRequestSize caps a JSON-RPC message, leaving room for the envelope
+// around a maximum size paste
+const maxMCPRequestSize = maxPasteSize + 4096
+
+// Search result limits and snippet length for search_my_pastes
+const (
+	defaultMCPSearchLimit = 10
+	maxMCPSearchLimit     = 20
+	mcpSnippetLength      = 200
+)
+
+// JSON-RPC 2.0 error codes
+const (
+	rpcParseError     = -32700
+	rpcInvalidRequest = -32600
+	rpcMethodNotFound = -32601
+	rpcInvalidParams  = -32602
+	rpcInternalError  = -32603
+)
+
+// MCPPasteStore is the paste storage used by the tool server
+type MCPPasteStore interface {
+	PasteRepositoryInterface
+	Search(filter models.PasteSearchFilter) ([]*models.Paste, int, error)
+}
+
+// MCPHandler serves a Model Context Protocol tool server so AI assistants
+// can file and fetch pastes on a user's behalf. It speaks JSON-RPC 2.0 over
+// POST, authenticated with an API key as the bearer token; the key's scopes
+// decide which tools are offered.
+type MCPHandler struct {
+	pasteRepo   MCPPasteStore
+	keys        APIKeyAuthenticator
+	idGenerator *utils.IDGenerator
+	validator   *validation.Validator
+	creator     *PasteCreator
+}
+
+// NewMCPHandler creates a new tool server handler
+func NewMCPHandler(pasteRepo MCPPasteStore, keys APIKeyAuthenticator, idGenerator *utils.IDGenerator, validator *validation.Validator) *MCPHandler {
+	return &MCPHandler{
+		pasteRepo:   pasteRepo,
+		keys:        keys,
+		idGenerator: idGenerator,
+		validator:   validator,
+		creator:     NewPasteCreator(pasteRepo),
+	}
+}
+
+// SetPasteCreator replaces the default creator, which applies no content
+// policies, with one that enforces them
+func (h *MCPHandler) SetPasteCreator(creator *PasteCreator) {
+	h.creator = creator
+}
+
+// MCPTool describes a tool and the JSON schema of its arguments
+type MCPTool struct {
+	Name        string                 `json:"name"`
+	Description string                 `json:"description"`
+	InputSchema map[string]interface{} `json:"inputSchema"`
+
+	scope string // Required of the API key
+	call  func(h *MCPHandler, r *http.Request, apiKey *models.APIKey, args json.RawMessage) (interface{}, error)
+}
+
+// mcpTools are the tools offered by the server
+var mcpTools = []MCPTool{
+	{
+		Name:        "create_paste",
+		Description: "Create a paste owned by the user and return its id and URL.",
+		InputSchema: map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"content":    map[string]interface{}{"type": "string", "description": "Text of the paste"},
+				"language":   map[string]interface{}{"type": "string", "description": "Syntax highlighting language, such as go or python"},
+				"expiry":     map[string]interface{}{"type": "string", "description": "How long the paste lives, such as 1h, 1d or 1w; omit to keep it"},
+				"visibility": map[string]interface{}{"type": "string", "enum": []string{models.VisibilityPublic, models.VisibilityUnlisted, models.VisibilityPrivate}, "default": models.VisibilityUnlisted},
+			},
+			"required": []string{"content"},
+		},
+		scope: auth.ScopePasteCreate,
+		call:  (*MCPHandler).createPaste,
+	},
+	{
+		Name:        "get_paste",
+		Description: "Fetch a paste by id: any of the user's own pastes, or a public or unlisted paste without a password.",
+		InputSchema: map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"id": map[string]interface{}{"type": "string", "description": "Paste id, the last part of its URL"},
+			},
+			"required": []string{"id"},
+		},
+		scope: auth.ScopePasteRead,
+		call:  (*MCPHandler).getPaste,
+	},
+	{
+		Name:        "search_my_pastes",
+		Description: "Search the user's own pastes by text, newest first, returning snippets.",
+		InputSchema: map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"query":    map[string]interface{}{"type": "string", "description": "Words that must all appear in the paste; omit to list recent pastes"},
+				"language": map[string]interface{}{"type": "string"},
+				"limit":    map[string]interface{}{"type": "integer", "minimum": 1, "maximum": maxMCPSearchLimit, "default": defaultMCPSearchLimit},
+			},
+		},
+		scope: auth.ScopePasteRead,
+		call:  (*MCPHandler).searchMyPastes,
+	},
+}
+
+// rpcRequest is a JSON-RPC 2.0 request; notifications have no id
+type rpcRequest struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+}
+
+// rpcResponse is a JSON-RPC 2.0 response carrying either a result or an error
+type rpcResponse struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Result  interface{}     `json:"result,omitempty"`
+	Error   *rpcError       `json:"error,omitempty"`
+}
+
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// mcpToolError is a failure reported to the model as a tool result rather
+// than as a protocol error, so it can correct itself
+type mcpToolError string
+
+func (e mcpToolError) Error() string { return string(e) }
+
+// MCPPaste is a paste as returned by get_paste
+type MCPPaste struct {
+	ID         string `json:"id"`
+	URL        string `json:"url"`
+	Content    string `json:"content"`
+	Language   string `json:"language,omitempty"`
+	Visibility string `json:"visibility"`
+	CreatedAt  string `json:"created_at"`
+	ExpiresAt  string `json:"expires_at,omitempty"`
+}
+
+// MCPSearchResult is one paste found by search_my_pastes
+type MCPSearchResult struct {
+	ID        string `json:"id"`
+	URL       string `json:"url"`
+	Snippet   string `json:"snippet"`
+	Language  string `json:"language,omitempty"`
+	CreatedAt string `json:"created_at"`
+}
+
+// Serve handles a JSON-RPC message from an MCP client
+func (h *MCPHandler) Serve(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		WriteError(w, &APIError{
+			Code:    "method_not_allowed",
+			Message: "Method not allowed",
+			Status:  http.StatusMethodNotAllowed,
+		})
+		return
+	}
+
+	apiKey, ok := authenticateAPIKey(w, h.keys, bearerAPIKey(r), "")
+	if !ok {
+		return
+	}
+
+	var req rpcRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxMCPRequestSize)).Decode(&req); err != nil {
+		writeRPC(w, rpcResponse{ID: json.RawMessage("null"), Error: &rpcError{Code: rpcParseError, Message: "Parse error"}})
+		return
+	}
+	if req.JSONRPC != "2.0" || req.Method == "" {
+		writeRPC(w, rpcResponse{ID: json.RawMessage("null"), Error: &rpcError{Code: rpcInvalidRequest, Message: "Invalid request"}})
+		return
+	}
+
+	// Notifications, such as notifications/initialized, need no answer
+	if len(req.ID) == 0 {
+		w.WriteHeader(http.StatusAccepted)
+		return
+	}
+
+	response := rpcResponse{ID: req.ID}
+	switch req.Method {
+	case "initialize":
+		response.Result = map[string]interface{}{
+			"protocolVersion": mcpProtocolVersion,
+			"capabilities":    map[string]interface{}{"tools": map[string]interface{}{}},
+			"serverInfo":      map[string]string{"name": "pastevault", "version": "1.0.0"},
+		}
+	case "ping":
+		response.Result = map[string]interface{}{}
+	case "tools/list":
+		tools := []MCPTool{}
+		for _, tool := range mcpTools {
+			if apiKey.Allows(tool.scope) {
+				tools = append(tools, tool)
+			}
+		}
+		response.Result = map[string]interface{}{"tools": tools}
+	case "tools/call":
+		response.Result, response.Error = h.callTool(r, apiKey, req.Params)
+	default:
+		response.Error = &rpcError{Code: rpcMethodNotFound, Message: "Method not found: " + req.Method}
+	}
+	writeRPC(w, response)
+}
+
+// callTool runs the tool named in params. Tool failures become error
+// results; only malformed calls and internal errors are protocol errors.
+func (h *MCPHandler) callTool(r *http.Request, apiKey *models.APIKey, params json.RawMessage) (interface{}, *rpcError) {
+	var call struct {
+		Name      string          `json:"name"`
+		Arguments json.RawMessage `json:"arguments"`
+	}
+	if err := json.Unmarshal(params, &call); err != nil {
+		return nil, &rpcError{Code: rpcInvalidParams, Message: "Invalid params"}
+	}
+
+	var tool *MCPTool
+	for i := range mcpTools {
+		if mcpTools[i].Name == call.Name {
+			tool = &mcpTools[i]
+		}
+	}
+	if tool == nil {
+		return nil, &rpcError{Code: rpcInvalidParams, Message: "Unknown tool: " + call.Name}
+	}
+	if len(call.Arguments) == 0 {
+		call.Arguments = json.RawMessage("{}")
+	}
+
+	var output interface{}
+	var err error = mcpToolError("This API key lacks the " + tool.scope + " scope")
+	if apiKey.Allows(tool.scope) {
+		output, err = tool.call(h, r, apiKey, call.Arguments)
+	}
+
+	var toolErr mcpToolError
+	switch {
+	case errors.As(err, &toolErr):
+		return mcpToolResult(toolErr.Error(), true), nil
+	case err != nil:
+		return nil, &rpcError{Code: rpcInternalError, Message: "Internal error"}
+	}
+	text, _ := json.Marshal(output)
+	return mcpToolResult(string(text), false), nil
+}
+
+// createPaste runs create_paste, validating like the quick paste endpoint
+func (h *MCPHandler) createPaste(r *http.Request, apiKey *models.APIKey, args json.RawMessage) (interface{}, error) {
+	var params struct {
+		Content    string `json:"content"`
+		Language   string `json:"language"`
+		Expiry     string `json:"expiry"`
+		Visibility string `json:"visibility"`
+	}
+	if err := json.Unmarshal(args, &params); err != nil {
+		return nil, mcpToolError("Invalid arguments: " + err.Error())
+	}
+
+	errs := h.validator.ValidateCreatePasteRequestFull(params.Content, "", params.Expiry, params.Language)
+	if err := h.validator.ValidateVisibility(params.Visibility); err != nil {
+		errs.Add(err.Field, err.Message)
+	}
+	if errs.HasErrors() {
+		return nil, mcpToolError(errs.Error())
+	}
+	if params.Visibility == "" {
+		params.Visibility = models.VisibilityUnlisted
+	}
+
+	id, err := generatePasteID(h.idGenerator, h.pasteRepo, params.Visibility)
+	if err != nil {
+		return nil, err
+	}
+
+	paste := &models.Paste{
+		ID:          id,
+		Content:     params.Content,
+		Language:    params.Language,
+		UserID:      &apiKey.UserID,
+		Kind:        models.KindText,
+		Visibility:  params.Visibility,
+		LineNumbers: true,
+	}
+	if params.Expiry != "" {
+		if duration, _ := h.validator.ValidateExpiryDuration(params.Expiry); duration != nil {
+			expiresAt := time.Now().Add(*duration)
+			paste.ExpiresAt = &expiresAt
+		}
+	}
+
+	var violation *services.PolicyViolation
+	err = h.creator.CreateOwnedFromRequest(r, paste, services.PasteAttributes{Expiry: params.Expiry})
+	if errors.As(err, &violation) {
+		return nil, mcpToolError(violation.Message)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return newCreatePasteResponse(paste), nil
+}
+
+// getPaste runs get_paste. Pastes of others are only returned when anyone
+// with the link could read them without further checks; protected ones are
+// reported as not found, as the paste API does for private pastes.
+func (h *MCPHandler) getPaste(r *http.Request, apiKey *models.APIKey, args json.RawMessage) (interface{}, error) {
+	var params struct {
+		ID string `json:"id"`
+	}
+	if err := json.Unmarshal(args, &params); err != nil {
+		return nil, mcpToolError("Invalid arguments: " + err.Error())
+	}
+	if err := h.validator.ValidateID(params.ID); err != nil {
+		return nil, mcpToolError("Invalid paste id")
+	}
+
+	paste, err := h.pasteRepo.GetByID(params.ID)
+	if err != nil {
+		return nil, err
+	}
+	if paste == nil || paste.IsExpired() {
+		return nil, mcpToolError("Paste not found")
+	}
+	if !paste.IsOwnedBy(apiKey.UserID) {
+		if paste.IsPrivate() || paste.HasPassword() || paste.RequireSignedURLs || paste.AccessWindow != nil || paste.IsLocationRestricted() {
+			return nil, mcpToolError("Paste not found")
+		}
+	}
+
+	result := MCPPaste{
+		ID:         paste.ID,
+		URL:        PasteURL(paste.ID),
+		Content:    paste.Content,
+		Language:   paste.Language,
+		Visibility: paste.Visibility,
+		CreatedAt:  paste.CreatedAt.Format(time.RFC3339),
+	}
+	if paste.ExpiresAt != nil {
+		result.ExpiresAt = paste.ExpiresAt.Format(time.RFC3339)
+	}
+	return result, nil
+}
+
+// searchMyPastes runs search_my_pastes over the key owner's unexpired pastes
+func (h *MCPHandler) searchMyPastes(r *http.Request, apiKey *models.APIKey, args json.RawMessage) (interface{}, error) {
+	var params struct {
+		Query    string `json:"query"`
+		Language string `json:"language"`
+		Limit    int    `json:"limit"`
+	}
+	if err := json.Unmarshal(args, &params); err != nil {
+		return nil, mcpToolError("Invalid arguments: " + err.Error())
+	}
+	if params.Limit <= 0 || params.Limit > maxMCPSearchLimit {
+		params.Limit = defaultMCPSearchLimit
+	}
+
+	pastes, _, err := h.pasteRepo.Search(models.PasteSearchFilter{
+		Query:     params.Query,
+		UserID:    apiKey.UserID,
+		Language:  params.Language,
+		Unexpired: true,
+		Limit:     params.Limit,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	results := []MCPSearchResult{}
+	for _, paste := range pastes {
+		snippet := []rune(paste.Content)
+		if len(snippet) > mcpSnippetLength {
+			snippet = snippet[:mcpSnippetLength]
+		}
+		results = append(results, MCPSearchResult{
+			ID:        paste.ID,
+			URL:       PasteURL(paste.ID),
+			Snippet:   string(snippet),
+			Language:  paste.Language,
+			CreatedAt: paste.CreatedAt.Format(time.RFC3339),
+		})
+	}
+	return map[string]interface{}{"pastes": results}, nil
+}
+
+// mcpToolResult wraps a tool's output as MCP text content
+func mcpToolResult(text string, isError bool) map[string]interface{} {
+	return map[string]interface{}{
+		"content": []map[string]string{{"type": "text", "text": text}},
+		"isError": isError,
+	}
+}
+
+// writeRPC writes a JSON-RPC response. Protocol errors are still HTTP 200,
+// as JSON-RPC carries them in the body.
+func writeRPC(w http.ResponseWriter, response rpcResponse) {
+	response.JSONRPC = "2.0"
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
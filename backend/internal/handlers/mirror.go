@@ -0,0 +1,144 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/LonleySailor/privatepaste/backend/internal/models"
+	"github.com/LonleySailor/privatepaste/backend/pkg/importer"
+)
+
+// mirrorTimeout bounds a single fetch from the upstream instance
+const mirrorTimeout = 10 * time.Second
+
+// ErrMirrorUpstream is returned by a mirror repository when the upstream
+// instance fails or returns something it cannot store
+var ErrMirrorUpstream = errors.New("upstream instance unavailable")
+
+// mirrorPasteRepository makes an instance a read-through mirror of another
+// PasteVault: pastes missing locally are fetched from the upstream's public
+// API and stored as local copies. Copies expire after ttl, or sooner if the
+// upstream paste does, so deletions upstream reach the mirror within ttl.
+// Password-protected, private, signed-URL-only and restricted pastes are never
+// mirrored, since the mirror could not enforce their access rules.
+type mirrorPasteRepository struct {
+	PasteRepositoryInterface
+	upstream string
+	ttl      time.Duration
+	client   *http.Client
+	now      func() time.Time
+}
+
+// NewMirrorPasteRepository wraps repo so lookups fall back to the instance at
+// upstream. Writes go to repo unchanged; mirrors are expected to reject them
+// with middleware.ReadOnly.
+func NewMirrorPasteRepository(repo PasteRepositoryInterface, upstream string, ttl time.Duration, client *http.Client) PasteRepositoryInterface {
+	if client == nil {
+		client = &http.Client{Timeout: mirrorTimeout}
+	}
+	return &mirrorPasteRepository{
+		PasteRepositoryInterface: repo,
+		upstream:                 strings.TrimSuffix(upstream, "/"),
+		ttl:                      ttl,
+		client:                   client,
+		now:                      time.Now,
+	}
+}
+
+// GetByID returns the local copy of a paste, fetching it from the upstream
+// when there is none or it has expired
+func (r *mirrorPasteRepository) GetByID(id string) (*models.Paste, error) {
+	paste, err := r.PasteRepositoryInterface.GetByID(id)
+	if err != nil {
+		return nil, err
+	}
+	if paste != nil && !paste.IsExpired() {
+		return paste, nil
+	}
+	if paste != nil {
+		// Drop the stale copy so it is refreshed from the upstream
+		if err := r.PasteRepositoryInterface.Delete(id); err != nil {
+			return nil, err
+		}
+	}
+
+	upstream, err := r.fetch(id)
+	if err != nil || upstream == nil {
+		return nil, err
+	}
+
+	expiresAt := r.now().Add(r.ttl)
+	if upstreamExpiry, err := time.Parse(time.RFC3339, upstream.ExpiresAt); err == nil && upstreamExpiry.Before(expiresAt) {
+		expiresAt = upstreamExpiry
+	}
+	paste = &models.Paste{
+		ID:          upstream.ID,
+		Content:     upstream.Content,
+		Language:    upstream.Language,
+		ExpiresAt:   &expiresAt,
+		DoNotTrack:  upstream.DoNotTrack,
+		NoIndex:     upstream.NoIndex,
+		Theme:       upstream.Theme,
+		LineNumbers: upstream.LineNumbers,
+		WordWrap:    upstream.WordWrap,
+		Kind:        upstream.Kind,
+		Visibility:  upstream.Visibility,
+	}
+	if paste.Kind == "" {
+		paste.Kind = models.KindText
+	}
+	if err := r.PasteRepositoryInterface.Create(paste); err != nil {
+		// A concurrent request may have stored the copy first
+		if existing, getErr := r.PasteRepositoryInterface.GetByID(id); getErr == nil && existing != nil {
+			return existing, nil
+		}
+		return nil, err
+	}
+	return paste, nil
+}
+
+// fetch retrieves a paste from the upstream's API. It returns nil if the
+// upstream does not serve the paste publicly.
+func (r *mirrorPasteRepository) fetch(id string) (*PasteResponse, error) {
+	req, err := http.NewRequest(http.MethodGet, r.upstream+"/api/paste/"+url.PathEscape(id), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrMirrorUpstream, err)
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound, http.StatusGone, http.StatusUnauthorized, http.StatusForbidden:
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("%w: status %d", ErrMirrorUpstream, resp.StatusCode)
+	}
+
+	// Leave room for JSON escaping of the largest allowed paste
+	var upstream PasteResponse
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 2*importer.MaxPasteSize)).Decode(&upstream); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrMirrorUpstream, err)
+	}
+	if upstream.ID != id || upstream.HasPassword || upstream.RequireSignedURLs || upstream.Restricted ||
+		upstream.AccessWindow != "" || len(upstream.AllowedNetworks) > 0 || len(upstream.AllowedCountries) > 0 ||
+		len(upstream.EmbedDomains) > 0 || upstream.Watermark ||
+		(upstream.Visibility != models.VisibilityPublic && upstream.Visibility != models.VisibilityUnlisted) {
+		return nil, nil
+	}
+	if upstream.ContentSHA256 != "" && upstream.ContentSHA256 != models.ContentSHA256(upstream.Content) {
+		return nil, fmt.Errorf("%w: content does not match its checksum", ErrMirrorUpstream)
+	}
+	return &upstream, nil
+}
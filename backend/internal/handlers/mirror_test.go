@@ -0,0 +1,71 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/LonleySailor/privatepaste/backend/internal/models"
+)
+
+func TestMirrorPasteRepository(t *testing.T) {
+	fetches := 0
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetches++
+		response := PasteResponse{ID: r.URL.Path[len("/api/paste/"):], Content: "hello", Kind: models.KindText, Visibility: models.VisibilityPublic}
+		response.ContentSHA256 = models.ContentSHA256(response.Content)
+		switch response.ID {
+		case "missing":
+			WriteError(w, ErrPasteNotFound)
+			return
+		case "broken":
+			WriteError(w, ErrInternalServer)
+			return
+		case "locked":
+			response.HasPassword = true
+		case "restricted":
+			response.Restricted = true
+		case "tampered":
+			response.Content = "changed"
+		}
+		json.NewEncoder(w).Encode(response)
+	}))
+	defer upstream.Close()
+
+	local := models.NewMemoryPasteRepository()
+	repo := NewMirrorPasteRepository(local, upstream.URL+"/", time.Hour, upstream.Client()).(*mirrorPasteRepository)
+
+	paste, err := repo.GetByID("mirrored")
+	if err != nil || paste == nil || paste.Content != "hello" {
+		t.Fatalf("Expected the upstream paste, got %+v (err %v)", paste, err)
+	}
+	if stored, _ := local.GetByID("mirrored"); stored == nil || stored.ExpiresAt == nil {
+		t.Fatalf("Expected an expiring local copy, got %+v", stored)
+	}
+	if repo.GetByID("mirrored"); fetches != 1 {
+		t.Errorf("Expected the local copy to be served, got %d fetches", fetches)
+	}
+
+	// Store a copy that has already expired; the next lookup refreshes it
+	repo.now = func() time.Time { return time.Now().Add(-2 * time.Hour) }
+	local.Delete("mirrored")
+	repo.GetByID("mirrored")
+	repo.now = time.Now
+	if paste, _ := repo.GetByID("mirrored"); paste == nil || fetches != 3 {
+		t.Errorf("Expected an expired copy to be refetched, got %+v after %d fetches", paste, fetches)
+	}
+
+	for _, id := range []string{"missing", "locked", "restricted"} {
+		if paste, err := repo.GetByID(id); paste != nil || err != nil {
+			t.Errorf("Expected %s not to be mirrored, got %+v (err %v)", id, paste, err)
+		}
+	}
+	for _, id := range []string{"broken", "tampered"} {
+		if _, err := repo.GetByID(id); !errors.Is(err, ErrMirrorUpstream) {
+			t.Errorf("Expected an upstream error for %s, got %v", id, err)
+		}
+	}
+}
@@ -0,0 +1,137 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/LonleySailor/privatepaste/backend/internal/middleware"
+	"github.com/LonleySailor/privatepaste/backend/internal/models"
+	"github.com/LonleySailor/privatepaste/backend/pkg/validation"
+)
+
+// NotificationStore stores users' notification preferences
+type NotificationStore interface {
+	GetPreferences(userID int) (*models.NotificationPreferences, error)
+	SetPreferences(prefs *models.NotificationPreferences) error
+}
+
+// NotificationHandler handles users' notification preferences, which choose
+// how the weekly activity digest is delivered
+type NotificationHandler struct {
+	notifications NotificationStore
+	integrations  IntegrationStore
+}
+
+// NewNotificationHandler creates a new notification handler
+func NewNotificationHandler(notifications NotificationStore, integrations IntegrationStore) *NotificationHandler {
+	return &NotificationHandler{notifications: notifications, integrations: integrations}
+}
+
+// UpdateNotificationsRequest represents a change to notification preferences.
+// DigestIntegrationID is required when the digest goes to a webhook.
+type UpdateNotificationsRequest struct {
+	WeeklyDigest        string `json:"weekly_digest"`
+	DigestIntegrationID *int64 `json:"digest_integration_id,omitempty"`
+}
+
+// Get handles returning the authenticated user's notification preferences
+func (h *NotificationHandler) Get(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		WriteError(w, &APIError{
+			Code:    "method_not_allowed",
+			Message: "Method not allowed",
+			Status:  http.StatusMethodNotAllowed,
+		})
+		return
+	}
+
+	userID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		WriteError(w, &APIError{
+			Code:    "unauthorized",
+			Message: "User ID not found in token",
+			Status:  http.StatusUnauthorized,
+		})
+		return
+	}
+
+	prefs, err := h.notifications.GetPreferences(userID)
+	if err != nil {
+		WriteRepositoryError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(prefs)
+}
+
+// Update handles changing the authenticated user's notification preferences.
+// Email digests go to the account's verified address.
+func (h *NotificationHandler) Update(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut {
+		WriteError(w, &APIError{
+			Code:    "method_not_allowed",
+			Message: "Method not allowed",
+			Status:  http.StatusMethodNotAllowed,
+		})
+		return
+	}
+
+	userID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		WriteError(w, &APIError{
+			Code:    "unauthorized",
+			Message: "User ID not found in token",
+			Status:  http.StatusUnauthorized,
+		})
+		return
+	}
+
+	var req UpdateNotificationsRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		WriteError(w, ErrInvalidJSON)
+		return
+	}
+
+	prefs := &models.NotificationPreferences{UserID: userID, WeeklyDigest: req.WeeklyDigest}
+	switch req.WeeklyDigest {
+	case models.DigestOff, models.DigestEmail:
+	case models.DigestWebhook:
+		if req.DigestIntegrationID == nil {
+			WriteValidationError(w, []validation.ValidationError{{
+				Field:   "digest_integration_id",
+				Message: "An integration is required for webhook digests",
+			}})
+			return
+		}
+		integration, err := h.integrations.GetByID(userID, *req.DigestIntegrationID)
+		if err != nil {
+			WriteRepositoryError(w, err)
+			return
+		}
+		if integration == nil {
+			WriteError(w, ErrIntegrationNotFound)
+			return
+		}
+		prefs.DigestIntegrationID = &integration.ID
+	default:
+		WriteValidationError(w, []validation.ValidationError{{
+			Field:   "weekly_digest",
+			Message: "Weekly digest must be off, email or webhook",
+		}})
+		return
+	}
+
+	if err := h.notifications.SetPreferences(prefs); err != nil {
+		WriteRepositoryError(w, err)
+		return
+	}
+	if stored, err := h.notifications.GetPreferences(userID); err == nil {
+		prefs = stored
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(prefs)
+}
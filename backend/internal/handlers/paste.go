@@ -2,12 +2,22 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net"
 	"net/http"
+	"slices"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/LonleySailor/privatepaste/backend/internal/middleware"
 	"github.com/LonleySailor/privatepaste/backend/internal/models"
+	"github.com/LonleySailor/privatepaste/backend/internal/services"
+	"github.com/LonleySailor/privatepaste/backend/pkg/gist"
+	"github.com/LonleySailor/privatepaste/backend/pkg/render"
+	"github.com/LonleySailor/privatepaste/backend/pkg/sandbox"
 	"github.com/LonleySailor/privatepaste/backend/pkg/utils"
 	"github.com/LonleySailor/privatepaste/backend/pkg/validation"
 	"github.com/gorilla/mux"
@@ -18,6 +28,192 @@ type PasteHandler struct {
 	pasteRepo   PasteRepositoryInterface
 	idGenerator *utils.IDGenerator
 	validator   *validation.Validator
+
+	// Optional renderers backed by external services; nil disables the endpoint
+	diagramRenderer *render.DiagramRenderer
+	sandbox         *sandbox.Client
+
+	// Creates GitHub gists with users' own tokens; nil disables gist export
+	gists *gist.Client
+
+	// Hashes creator IPs; nil disables recording them
+	ipHasher *utils.IPHasher
+
+	// Signs raw and download URLs for pastes that require signatures
+	urlSigner *utils.URLSigner
+
+	// Signs raw content served to clients; nil disables response signing
+	responseSigner *utils.ResponseSigner
+
+	// Queues CDN purges of deleted pastes; nil disables purging
+	purgeQueue JobEnqueuer
+
+	// Counts views for owners' digests; nil disables view counting
+	viewRecorder ViewRecorder
+
+	// Supplies users' defaults for new pastes; nil disables them
+	userSettings UserSettingsStore
+
+	// Limits how often password hints are revealed; nil reveals them freely
+	hintLimiter RequestLimiter
+
+	// Resolves viewers' countries for country-restricted pastes; nil
+	// disables creating them
+	countryLookup middleware.CountryLookup
+
+	// Expiry rules for pastes created without an account
+	anonymousExpiry AnonymousExpiryPolicy
+
+	// Expiry choices offered to clients, enforced in strict mode
+	expiryPresets ExpiryPresets
+
+	// Stores new pastes under the creator's content policy
+	creator *PasteCreator
+
+	// Stores files attached to pastes; nil disables attachments. A nil
+	// scanner accepts uploads unscanned.
+	attachments       AttachmentStore
+	attachmentLimits  AttachmentLimits
+	attachmentScanner AttachmentScanner
+
+	// Supplies metadata generated by hooks, such as a title; nil leaves it
+	// out of responses
+	metadata PasteMetadataReader
+
+	// Stores the tags users put on their pastes; nil disables tags
+	tags TagStore
+
+	// Searches paste content; nil disables searching a user's pastes
+	search PasteSearcher
+
+	// Keeps rendered views of pastes; nil renders every request. With
+	// prerender, the highlighted HTML of new pastes is rendered in the
+	// background so their first viewers find it ready.
+	renderCache RenderCache
+	prerender   bool
+
+	// While writes are paused, views are not counted; nil never pauses them
+	readOnly *middleware.ReadOnlyMode
+}
+
+// defaultExpiryPresets are offered when an instance configures none
+var defaultExpiryPresets = []string{"10m", "1h", "1d", "7d", "30d", "never"}
+
+// ExpiryPresets are the expiry choices offered to clients. In strict mode
+// they are the only expiries accepted.
+type ExpiryPresets struct {
+	Presets []string `json:"presets"`
+	Strict  bool     `json:"strict"`
+}
+
+// NewExpiryPresets checks that every preset is a valid expiry. Without any
+// presets the built-in ones are offered.
+func NewExpiryPresets(presets []string, strict bool, validator *validation.Validator) (ExpiryPresets, error) {
+	if len(presets) == 0 {
+		presets = defaultExpiryPresets
+	}
+	for _, preset := range presets {
+		if _, err := validator.ValidateExpiryDuration(preset); err != nil {
+			return ExpiryPresets{}, fmt.Errorf("invalid expiry preset %q: %s", preset, err.Message)
+		}
+	}
+	return ExpiryPresets{Presets: presets, Strict: strict}, nil
+}
+
+// Allows reports whether an expiry may be chosen. An empty expiry means the
+// paste never expires.
+func (p ExpiryPresets) Allows(expiry string) bool {
+	if !p.Strict {
+		return true
+	}
+	if expiry == "" {
+		expiry = "never"
+	}
+	return slices.Contains(p.Presets, expiry)
+}
+
+// maxPasteSize is the largest paste content accepted, in bytes
+const maxPasteSize = 1048576
+
+// expiryRequired is the anonymous default expiry making requests choose one
+const expiryRequired = "required"
+
+// AnonymousExpiryPolicy controls the expiry of pastes created without an account
+type AnonymousExpiryPolicy struct {
+	Default    string `json:"default,omitempty"` // Applied when the expiry is omitted; a duration or "never"
+	Required   bool   `json:"required"`          // Requests must choose an expiry
+	AllowNever bool   `json:"allow_never"`       // Pastes may be kept forever
+}
+
+// NewAnonymousExpiryPolicy parses the configured anonymous expiry: a default
+// duration, "never", or "required" to make requests choose one
+func NewAnonymousExpiryPolicy(defaultExpiry string, allowNever bool, validator *validation.Validator) (AnonymousExpiryPolicy, error) {
+	policy := AnonymousExpiryPolicy{AllowNever: allowNever}
+	if defaultExpiry == expiryRequired {
+		policy.Required = true
+		return policy, nil
+	}
+
+	duration, err := validator.ValidateExpiryDuration(defaultExpiry)
+	if err != nil {
+		return policy, fmt.Errorf("invalid anonymous default expiry %q: %s", defaultExpiry, err.Message)
+	}
+	if duration == nil {
+		if !allowNever {
+			return policy, errors.New("anonymous default expiry cannot be never when never is not allowed")
+		}
+		defaultExpiry = "never"
+	}
+	policy.Default = defaultExpiry
+	return policy, nil
+}
+
+// ContentPolicies supplies the content policy of each role
+type ContentPolicies interface {
+	Policy(role string) (*models.ContentPolicy, error)
+}
+
+// ViewRecorder counts paste views
+type ViewRecorder interface {
+	RecordView(pasteID string, raw bool) error
+	ViewCounts(pasteIDs []string) (map[string]models.ViewCount, error)
+}
+
+// PasteViews is how often a paste has been viewed, split by how its content
+// was fetched
+type PasteViews struct {
+	Total int64 `json:"total"`
+	Raw   int64 `json:"raw"`
+	JSON  int64 `json:"json"`
+}
+
+// PasteMetadataReader supplies metadata generated about pastes
+type PasteMetadataReader interface {
+	Get(pasteID string) (map[string]string, error)
+}
+
+// TagStore stores the tags users put on their pastes
+type TagStore interface {
+	SetPasteTags(pasteID string, userID int, names []string) error
+	ListByPasteIDs(pasteIDs []string) (map[string][]string, error)
+	ListByUserID(userID int) ([]models.TagCount, error)
+	ListPastes(userID int, tag string, limit, offset int) ([]*models.Paste, int, error)
+}
+
+// PasteSearcher finds pastes by their content
+type PasteSearcher interface {
+	Search(filter models.PasteSearchFilter) ([]*models.Paste, int, error)
+}
+
+// RenderCache keeps views rendered from pastes, such as highlighted HTML
+type RenderCache interface {
+	Rendered(pasteID, view string) (string, bool)
+	SetRendered(pasteID, view, body string)
+}
+
+// RequestLimiter counts requests against a named rate limit policy
+type RequestLimiter interface {
+	Allow(policy string, r *http.Request) bool
 }
 
 // NewPasteHandler creates a new paste handler
@@ -26,15 +222,262 @@ func NewPasteHandler(pasteRepo PasteRepositoryInterface, idGenerator *utils.IDGe
 		pasteRepo:   pasteRepo,
 		idGenerator: idGenerator,
 		validator:   validator,
+		creator:     NewPasteCreator(pasteRepo),
+
+		anonymousExpiry: AnonymousExpiryPolicy{Default: "24h", AllowNever: true},
+		expiryPresets:   ExpiryPresets{Presets: defaultExpiryPresets},
+	}
+}
+
+// SetDiagramRenderer enables diagram rendering through the given renderer
+func (h *PasteHandler) SetDiagramRenderer(renderer *render.DiagramRenderer) {
+	h.diagramRenderer = renderer
+}
+
+// SetIPHasher enables recording salted creator IP hashes on new pastes
+func (h *PasteHandler) SetIPHasher(hasher *utils.IPHasher) {
+	h.ipHasher = hasher
+	h.creator.SetIPHasher(hasher)
+}
+
+// SetPurgeQueue enables purging a deleted paste's cached copies from the CDN
+func (h *PasteHandler) SetPurgeQueue(queue JobEnqueuer) {
+	h.purgeQueue = queue
+}
+
+// SetHookQueue enables running post-create hooks on pastes created
+// through the paste API
+func (h *PasteHandler) SetHookQueue(queue JobEnqueuer) {
+	h.creator.SetHookQueue(queue)
+}
+
+// SetPasteMetadata enables including generated metadata in paste responses
+func (h *PasteHandler) SetPasteMetadata(metadata PasteMetadataReader) {
+	h.metadata = metadata
+}
+
+// SetTagStore enables tagging pastes and filtering a user's pastes by tag
+func (h *PasteHandler) SetTagStore(tags TagStore) {
+	h.tags = tags
+}
+
+// SetPasteSearcher enables full-text search of a user's pastes
+func (h *PasteHandler) SetPasteSearcher(search PasteSearcher) {
+	h.search = search
+}
+
+// SetRenderCache enables caching rendered views of pastes, and with
+// prerender, rendering the highlighted HTML of new pastes ahead of their
+// first view
+func (h *PasteHandler) SetRenderCache(cache RenderCache, prerender bool) {
+	h.renderCache = cache
+	h.prerender = prerender
+}
+
+// SetViewRecorder enables counting views of pastes
+func (h *PasteHandler) SetViewRecorder(recorder ViewRecorder) {
+	h.viewRecorder = recorder
+}
+
+// SetAnonymousExpiryPolicy replaces the default 24 hour expiry of pastes
+// created without an account
+func (h *PasteHandler) SetAnonymousExpiryPolicy(policy AnonymousExpiryPolicy) {
+	h.anonymousExpiry = policy
+}
+
+// SetExpiryPresets replaces the expiry choices offered to clients
+func (h *PasteHandler) SetExpiryPresets(presets ExpiryPresets) {
+	h.expiryPresets = presets
+}
+
+// SetUserSettings enables applying users' saved defaults to new pastes
+func (h *PasteHandler) SetUserSettings(settings UserSettingsStore) {
+	h.userSettings = settings
+}
+
+// SetHintLimiter rate limits revealing password hints under the
+// middleware.PolicyPasswordHint policy
+func (h *PasteHandler) SetHintLimiter(limiter RequestLimiter) {
+	h.hintLimiter = limiter
+}
+
+// SetCountryLookup enables restricting pastes to countries
+func (h *PasteHandler) SetCountryLookup(lookup middleware.CountryLookup) {
+	h.countryLookup = lookup
+}
+
+// SetCountryRestriction enables refusing pastes created, transformed or
+// run from restricted countries
+func (h *PasteHandler) SetCountryRestriction(restriction *middleware.GeoRestriction) {
+	h.creator.SetCountryRestriction(restriction)
+}
+
+// SetContentPolicies enables enforcing per-role content policies
+func (h *PasteHandler) SetContentPolicies(policies ContentPolicies) {
+	h.creator.SetContentPolicies(policies)
+}
+
+// SetReadOnlyMode stops reads from writing, such as counting views, while
+// writes are paused
+func (h *PasteHandler) SetReadOnlyMode(mode *middleware.ReadOnlyMode) {
+	h.readOnly = mode
+}
+
+// writesPaused reports whether writes to the instance are paused
+func (h *PasteHandler) writesPaused() bool {
+	return h.readOnly != nil && h.readOnly.Enabled()
+}
+
+// applyUserDefaults fills the options a create request leaves out from the
+// authenticated user's saved defaults. On failure it writes the error
+// response and returns false.
+func (h *PasteHandler) applyUserDefaults(w http.ResponseWriter, r *http.Request, req *CreatePasteRequest) bool {
+	userID, ok := middleware.GetUserIDFromContext(r.Context())
+	if h.userSettings == nil || !ok {
+		return true
+	}
+
+	settings, err := h.userSettings.Get(userID)
+	if err != nil {
+		WriteRepositoryError(w, err)
+		return false
+	}
+	if req.Expiry == "" && req.ExpiresAt == "" {
+		req.Expiry = settings.DefaultExpiry
+	}
+	if req.Visibility == "" {
+		req.Visibility = settings.DefaultVisibility
+	}
+	// Diffs are always highlighted as diffs
+	if req.Language == "" && req.Kind != models.KindDiff {
+		req.Language = settings.DefaultLanguage
+	}
+	return true
+}
+
+// recordView counts a view of a paste, as a raw content fetch or not,
+// unless its creator opted out of tracking. Failures are logged; they never
+// fail the request.
+func (h *PasteHandler) recordView(paste *models.Paste, raw bool) {
+	if h.viewRecorder == nil || paste.DoNotTrack || h.writesPaused() {
+		return
+	}
+	if err := h.viewRecorder.RecordView(paste.ID, raw); err != nil {
+		log.Printf("Failed to record view of paste %s: %v", paste.ID, err)
+	}
+}
+
+// viewCounts returns the views of each paste that is tracked, or nil when
+// views are not counted. Pastes never viewed have zero views.
+func (h *PasteHandler) viewCounts(pastes []*models.Paste) (map[string]*PasteViews, error) {
+	if h.viewRecorder == nil {
+		return nil, nil
 	}
+	var ids []string
+	for _, paste := range pastes {
+		if !paste.DoNotTrack {
+			ids = append(ids, paste.ID)
+		}
+	}
+	counts, err := h.viewRecorder.ViewCounts(ids)
+	if err != nil {
+		return nil, err
+	}
+
+	views := map[string]*PasteViews{}
+	for _, id := range ids {
+		count := counts[id]
+		views[id] = &PasteViews{Total: count.Total, Raw: count.Raw, JSON: count.Total - count.Raw}
+	}
+	return views, nil
+}
+
+// pasteMetadata returns the generated metadata of a paste, or nil when
+// there is none or metadata is disabled
+func (h *PasteHandler) pasteMetadata(paste *models.Paste) (map[string]string, error) {
+	if h.metadata == nil {
+		return nil, nil
+	}
+	metadata, err := h.metadata.Get(paste.ID)
+	if err != nil || len(metadata) == 0 {
+		return nil, err
+	}
+	return metadata, nil
+}
+
+// pasteTags returns the tags of each of the given pastes, or nil when tags
+// are disabled
+func (h *PasteHandler) pasteTags(pastes []*models.Paste) (map[string][]string, error) {
+	if h.tags == nil {
+		return nil, nil
+	}
+	ids := make([]string, len(pastes))
+	for i, paste := range pastes {
+		ids[i] = paste.ID
+	}
+	return h.tags.ListByPasteIDs(ids)
+}
+
+// creatorIPHash returns the salted hash of the client IP, or nil when IP
+// recording is disabled. Raw IPs are never stored.
+func (h *PasteHandler) creatorIPHash(r *http.Request) *string {
+	if h.ipHasher == nil {
+		return nil
+	}
+	hash := h.ipHasher.Hash(middleware.ClientIP(r))
+	return &hash
 }
 
 // CreatePasteRequest represents a request to create a new paste
 type CreatePasteRequest struct {
-	Content  string `json:"content"`
-	Password string `json:"password,omitempty"`
-	Expiry   string `json:"expiry,omitempty"`   // Duration string like "1h", "30m", "7d"
-	Language string `json:"language,omitempty"` // For syntax highlighting
+	Content    string `json:"content"`
+	Password   string `json:"password,omitempty"`
+	Expiry     string `json:"expiry,omitempty"`       // Duration string like "1h", "30m", "7d"
+	ExpiresAt  string `json:"expires_at,omitempty"`   // RFC3339 timestamp, instead of an expiry duration
+	Language   string `json:"language,omitempty"`     // For syntax highlighting
+	DoNotTrack bool   `json:"do_not_track,omitempty"` // Disable view counting and access logging
+	Kind       string `json:"kind,omitempty"`         // "text" (default) or "diff"
+	Visibility string `json:"visibility,omitempty"`   // "public" (default), "unlisted" or "private"
+
+	// Require expiring signed URLs for /raw and /download to prevent hotlinking
+	RequireSignedURLs bool `json:"require_signed_urls,omitempty"`
+
+	// Ask search engines not to index the paste and leave it out of public
+	// listings and feeds
+	NoIndex bool `json:"noindex,omitempty"`
+
+	// Shown to visitors asked for the password; requires a password
+	PasswordHint string `json:"password_hint,omitempty"`
+
+	// Destroy the paste after this many wrong passwords; requires a password
+	MaxUnlockAttempts int `json:"max_unlock_attempts,omitempty"`
+
+	// Daily UTC times like "09:00-17:00" outside of which only the owner may
+	// view the paste
+	AccessWindow string `json:"access_window,omitempty"`
+
+	// CIDR ranges and ISO country codes the paste may be viewed from
+	AllowedNetworks  []string `json:"allowed_networks,omitempty"`
+	AllowedCountries []string `json:"allowed_countries,omitempty"`
+
+	// Domains whose pages may embed the rendered HTML and image views
+	EmbedDomains []string `json:"embed_domains,omitempty"`
+
+	// Overlay rendered HTML views with the viewer's username or IP hash to
+	// discourage leaking screenshots
+	Watermark bool `json:"watermark,omitempty"`
+
+	// Detached PGP (ASCII-armored) or minisign signature of the content,
+	// served at /api/paste/{id}/signature for consumers to verify
+	Signature string `json:"signature,omitempty"`
+
+	// Render preferences applied wherever the paste is displayed
+	Theme       string `json:"theme,omitempty"`
+	LineNumbers *bool  `json:"line_numbers,omitempty"` // Defaults to true
+	WordWrap    bool   `json:"word_wrap,omitempty"`
+
+	// Labels for finding the paste among the owner's; requires an account
+	Tags []string `json:"tags,omitempty"`
 }
 
 // PasteResponse represents a paste response for GET requests
@@ -45,14 +488,218 @@ type PasteResponse struct {
 	CreatedAt   string `json:"created_at"`
 	ExpiresAt   string `json:"expires_at,omitempty"`
 	HasPassword bool   `json:"has_password"`
+	DoNotTrack  bool   `json:"do_not_track"`
+	Kind        string `json:"kind"`
+	Visibility  string `json:"visibility"`
+	Theme       string `json:"theme,omitempty"`
+	LineNumbers bool   `json:"line_numbers"`
+	WordWrap    bool   `json:"word_wrap"`
+	ParentID    string `json:"parent_id,omitempty"`
+
+	// Viewing depends on the time, the viewer's location or the embedding
+	// page; such pastes are never cached publicly or mirrored
+	Restricted bool `json:"restricted"`
+
+	RequireSignedURLs bool   `json:"require_signed_urls"`
+	NoIndex           bool   `json:"noindex"`
+	MaxUnlockAttempts *int   `json:"max_unlock_attempts,omitempty"`
+	AccessWindow      string `json:"access_window,omitempty"`
+
+	AllowedNetworks  []string `json:"allowed_networks,omitempty"`
+	AllowedCountries []string `json:"allowed_countries,omitempty"`
+	EmbedDomains     []string `json:"embed_domains,omitempty"`
+	Watermark        bool     `json:"watermark"`
+
+	// Integrity metadata: the content digest and the format of the
+	// creator's detached signature, if one was attached
+	ContentSHA256   string `json:"content_sha256"`
+	SignatureFormat string `json:"signature_format,omitempty"`
+
+	Attachments []AttachmentInfo `json:"attachments,omitempty"`
+
+	// Generated after creation by hooks, such as a title and summary
+	Metadata map[string]string `json:"metadata,omitempty"`
+
+	Tags []string `json:"tags,omitempty"`
+
+	// All-time views, including this one; absent for pastes with
+	// do_not_track
+	Views *PasteViews `json:"views,omitempty"`
+}
+
+// newPasteResponse builds the API representation of a paste. Its access
+// window, allowed networks and countries and embed domains are only shown to
+// its owner; other viewers just learn that it is restricted.
+func newPasteResponse(paste *models.Paste, owner bool) PasteResponse {
+	response := PasteResponse{
+		ID:          paste.ID,
+		Content:     paste.Content,
+		Language:    paste.Language,
+		CreatedAt:   paste.CreatedAt.Format(time.RFC3339),
+		HasPassword: paste.HasPassword(),
+		DoNotTrack:  paste.DoNotTrack,
+		Kind:        paste.Kind,
+		Visibility:  paste.Visibility,
+		Theme:       paste.Theme,
+		LineNumbers: paste.LineNumbers,
+		WordWrap:    paste.WordWrap,
+		Restricted:  paste.IsRestricted(),
+
+		RequireSignedURLs: paste.RequireSignedURLs,
+		NoIndex:           paste.NoIndex,
+		MaxUnlockAttempts: paste.MaxUnlockAttempts,
+		Watermark:         paste.Watermark,
+		ContentSHA256:     paste.ContentSHA256,
+		SignatureFormat:   paste.SignatureFormat,
+	}
+
+	if paste.ExpiresAt != nil {
+		response.ExpiresAt = paste.ExpiresAt.Format(time.RFC3339)
+	}
+
+	if paste.ParentID != nil {
+		response.ParentID = *paste.ParentID
+	}
+
+	if owner {
+		if paste.AccessWindow != nil {
+			response.AccessWindow = paste.AccessWindow.String()
+		}
+		response.AllowedNetworks = paste.AllowedNetworks
+		response.AllowedCountries = paste.AllowedCountries
+		response.EmbedDomains = paste.EmbedDomains
+	}
+
+	return response
 }
 
 // CreatePasteResponse represents the response when creating a paste
 type CreatePasteResponse struct {
-	ID        string `json:"id"`
-	URL       string `json:"url"`
-	CreatedAt string `json:"created_at"`
-	ExpiresAt string `json:"expires_at,omitempty"`
+	ID            string `json:"id"`
+	URL           string `json:"url"`
+	CreatedAt     string `json:"created_at"`
+	ExpiresAt     string `json:"expires_at,omitempty"`
+	ContentSHA256 string `json:"content_sha256"`
+
+	// Advisory estimate of the paste password's strength, if one was set
+	PasswordStrength *validation.PasswordStrength `json:"password_strength,omitempty"`
+}
+
+// siteBaseURL prefixes links sent outside the API, such as in responses,
+// chat messages and email
+const siteBaseURL = "https://privatepaste.example.com" // TODO: Use actual domain from config
+
+// PasteURL returns the public link to a paste
+func PasteURL(id string) string {
+	return siteBaseURL + "/" + id
+}
+
+// newCreatePasteResponse builds the response returned after creating a paste
+func newCreatePasteResponse(paste *models.Paste) CreatePasteResponse {
+	response := CreatePasteResponse{
+		ID:            paste.ID,
+		URL:           PasteURL(paste.ID),
+		CreatedAt:     paste.CreatedAt.Format(time.RFC3339),
+		ContentSHA256: paste.ContentSHA256,
+	}
+
+	if paste.ExpiresAt != nil {
+		response.ExpiresAt = paste.ExpiresAt.Format(time.RFC3339)
+	}
+
+	return response
+}
+
+// generateID generates a unique paste ID. Unlisted and private pastes get long,
+// high-entropy IDs so they cannot be found by enumeration.
+func (h *PasteHandler) generateID(visibility string) (string, error) {
+	return generatePasteID(h.idGenerator, h.pasteRepo, visibility)
+}
+
+// generatePasteID generates a unique paste ID suited to the visibility, for
+// handlers that create pastes outside of PasteHandler
+func generatePasteID(idGenerator *utils.IDGenerator, pasteRepo PasteRepositoryInterface, visibility string) (string, error) {
+	if visibility == models.VisibilityPublic {
+		return idGenerator.GenerateWithCollisionCheck(pasteRepo.Exists)
+	}
+	return idGenerator.GenerateLongWithCollisionCheck(pasteRepo.Exists)
+}
+
+// canView reports whether the requester may see the paste at all. Private
+// pastes are reported as not found to everyone but their owner.
+func canView(r *http.Request, paste *models.Paste) bool {
+	return !paste.IsPrivate() || ownsPaste(r, paste)
+}
+
+// ownsPaste reports whether the requester is the paste's owner
+func ownsPaste(r *http.Request, paste *models.Paste) bool {
+	userID, ok := middleware.GetUserIDFromContext(r.Context())
+	return ok && paste.IsOwnedBy(userID)
+}
+
+// checkAccessWindow refuses viewing a paste outside its daily access window
+// to everyone but its owner. On refusal it writes the error response and
+// returns false.
+func checkAccessWindow(w http.ResponseWriter, r *http.Request, paste *models.Paste) bool {
+	if paste.AccessWindow == nil || paste.AccessWindow.Contains(time.Now()) {
+		return true
+	}
+	if userID, ok := middleware.GetUserIDFromContext(r.Context()); ok && paste.IsOwnedBy(userID) {
+		return true
+	}
+	WriteError(w, &APIError{
+		Code:    "outside_access_window",
+		Message: "This paste can only be viewed during " + paste.AccessWindow.String() + " UTC",
+		Status:  http.StatusForbidden,
+	})
+	return false
+}
+
+// checkLocation refuses viewing a location-restricted paste from outside its
+// allowed networks and countries to everyone but its owner. Without a country
+// lookup only the allowed networks are admitted. On refusal it writes the
+// error response and returns false.
+func checkLocation(w http.ResponseWriter, r *http.Request, paste *models.Paste, lookup middleware.CountryLookup) bool {
+	if !paste.IsLocationRestricted() {
+		return true
+	}
+	if userID, ok := middleware.GetUserIDFromContext(r.Context()); ok && paste.IsOwnedBy(userID) {
+		return true
+	}
+	if locationAllowed(net.ParseIP(middleware.ClientIP(r)), paste, lookup) {
+		return true
+	}
+	WriteError(w, ErrLocationNotAllowed)
+	return false
+}
+
+// locationAllowed reports whether ip is in one of the paste's allowed
+// networks or countries. Unlike the instance-wide country restrictions this
+// fails closed, since the creator asked to keep the paste in.
+func locationAllowed(ip net.IP, paste *models.Paste, lookup middleware.CountryLookup) bool {
+	if ip == nil {
+		return false
+	}
+	for _, network := range paste.AllowedNetworks {
+		if _, ipNet, err := net.ParseCIDR(network); err == nil && ipNet.Contains(ip) {
+			return true
+		}
+	}
+	if len(paste.AllowedCountries) == 0 || lookup == nil {
+		return false
+	}
+
+	country, err := lookup.Country(ip)
+	if err != nil {
+		log.Printf("GeoIP lookup failed: %v", err)
+		return false
+	}
+	for _, allowed := range paste.AllowedCountries {
+		if country == allowed {
+			return true
+		}
+	}
+	return false
 }
 
 // Create handles creating a new paste
@@ -68,24 +715,104 @@ func (h *PasteHandler) Create(w http.ResponseWriter, r *http.Request) {
 
 	var req CreatePasteRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		WriteError(w, ErrInvalidJSON)
+		WriteBodyError(w, err, ErrInvalidJSON)
+		return
+	}
+	if !h.applyUserDefaults(w, r, &req) {
 		return
 	}
 
 	// Validate request
-	if errors := h.validator.ValidateCreatePasteRequestFull(req.Content, req.Password, req.Expiry, req.Language); errors.HasErrors() {
+	errors := h.validator.ValidateCreatePasteRequestFull(req.Content, req.Password, req.Expiry, req.Language)
+	if req.Expiry != "" && req.ExpiresAt != "" {
+		errors.Add("expires_at", "cannot be combined with expiry")
+	}
+	if err := h.validator.ValidateTheme(req.Theme); err != nil {
+		errors.Add(err.Field, err.Message)
+	}
+	if err := h.validator.ValidateKind(req.Kind); err != nil {
+		errors.Add(err.Field, err.Message)
+	} else if req.Kind == models.KindDiff {
+		if _, err := render.ParseUnifiedDiff(req.Content); err != nil {
+			errors.Add("content", "must be a unified diff when kind is diff")
+		}
+	}
+	if err := h.validator.ValidateSignature(req.Signature); err != nil {
+		errors.Add(err.Field, err.Message)
+	}
+	if err := h.validator.ValidatePasswordHint(req.PasswordHint, req.Password); err != nil {
+		errors.Add(err.Field, err.Message)
+	}
+	if err := h.validator.ValidateMaxUnlockAttempts(req.MaxUnlockAttempts, req.Password); err != nil {
+		errors.Add(err.Field, err.Message)
+	}
+	var accessWindow *models.AccessWindow
+	if req.AccessWindow != "" {
+		if start, end, err := h.validator.ValidateAccessWindow(req.AccessWindow); err != nil {
+			errors.Add(err.Field, err.Message)
+		} else {
+			accessWindow = &models.AccessWindow{Start: start, End: end}
+		}
+	}
+	allowedNetworks, networksErr := h.validator.ValidateAllowedNetworks(req.AllowedNetworks)
+	if networksErr != nil {
+		errors.Add(networksErr.Field, networksErr.Message)
+	}
+	allowedCountries, countriesErr := h.validator.ValidateAllowedCountries(req.AllowedCountries)
+	if countriesErr != nil {
+		errors.Add(countriesErr.Field, countriesErr.Message)
+	} else if len(allowedCountries) > 0 && h.countryLookup == nil {
+		errors.Add("allowed_countries", "requires GeoIP, which this instance has not configured")
+	}
+	embedDomains, embedErr := h.validator.ValidateEmbedDomains(req.EmbedDomains)
+	if embedErr != nil {
+		errors.Add(embedErr.Field, embedErr.Message)
+	}
+	if err := h.validator.ValidateVisibility(req.Visibility); err != nil {
+		errors.Add(err.Field, err.Message)
+	} else if req.Visibility == models.VisibilityPrivate {
+		if _, ok := middleware.GetUserIDFromContext(r.Context()); !ok {
+			errors.Add("visibility", "private pastes require an account")
+		}
+	}
+	tags, tagsErr := h.validator.ValidateTags(req.Tags)
+	if tagsErr != nil {
+		errors.Add(tagsErr.Field, tagsErr.Message)
+	} else if len(tags) > 0 {
+		if _, ok := middleware.GetUserIDFromContext(r.Context()); !ok {
+			errors.Add("tags", "require an account")
+		} else if h.tags == nil {
+			errors.Add("tags", "are not enabled on this instance")
+		}
+	}
+	if errors.HasErrors() {
 		WriteValidationError(w, errors)
 		return
 	}
 
+	role := requestRole(r)
+	policy, err := h.creator.Policy(role)
+	if err != nil {
+		WriteRepositoryError(w, err)
+		return
+	}
+
+	// Pastes default to public where the role may create public pastes
+	if req.Visibility == "" {
+		req.Visibility = models.VisibilityPublic
+		if !policy.AllowPublic {
+			req.Visibility = models.VisibilityUnlisted
+		}
+	}
+
 	// Check content size (1MB limit)
-	if len(req.Content) > 1048576 {
+	if len(req.Content) > maxPasteSize {
 		WriteError(w, ErrContentTooLarge)
 		return
 	}
 
 	// Generate unique ID
-	id, err := h.idGenerator.GenerateWithCollisionCheck(h.pasteRepo.Exists)
+	id, err := h.generateID(req.Visibility)
 	if err != nil {
 		WriteError(w, ErrIDGenerationFailed)
 		return
@@ -93,9 +820,39 @@ func (h *PasteHandler) Create(w http.ResponseWriter, r *http.Request) {
 
 	// Create paste object
 	paste := &models.Paste{
-		ID:       id,
-		Content:  req.Content,
-		Language: req.Language,
+		ID:          id,
+		Content:     req.Content,
+		Language:    req.Language,
+		DoNotTrack:  req.DoNotTrack,
+		Kind:        models.KindText,
+		Visibility:  req.Visibility,
+		Theme:       req.Theme,
+		LineNumbers: true,
+		WordWrap:    req.WordWrap,
+
+		RequireSignedURLs: req.RequireSignedURLs,
+		NoIndex:           req.NoIndex,
+		AccessWindow:      accessWindow,
+		AllowedNetworks:   allowedNetworks,
+		AllowedCountries:  allowedCountries,
+		EmbedDomains:      embedDomains,
+		Watermark:         req.Watermark,
+	}
+
+	if req.LineNumbers != nil {
+		paste.LineNumbers = *req.LineNumbers
+	}
+
+	if req.Signature != "" {
+		paste.Signature = strings.TrimSpace(req.Signature) + "\n"
+		paste.SignatureFormat = validation.SignatureFormat(req.Signature)
+	}
+
+	if req.Kind == models.KindDiff {
+		paste.Kind = models.KindDiff
+		if paste.Language == "" {
+			paste.Language = "diff"
+		}
 	}
 
 	// Handle password if provided
@@ -106,6 +863,10 @@ func (h *PasteHandler) Create(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 		paste.PasswordHash = &hashedPassword
+		paste.PasswordHint = req.PasswordHint
+		if req.MaxUnlockAttempts > 0 {
+			paste.MaxUnlockAttempts = &req.MaxUnlockAttempts
+		}
 	}
 
 	// Handle user association if authenticated
@@ -113,41 +874,81 @@ func (h *PasteHandler) Create(w http.ResponseWriter, r *http.Request) {
 		paste.UserID = &userID
 	}
 
-	// Handle expiry if provided
-	if req.Expiry != "" {
-		duration, validationErr := h.validator.ValidateExpiryDuration(req.Expiry)
+	// Anonymous pastes follow the instance's expiry policy; pastes of
+	// authenticated users default to no expiry
+	if paste.UserID == nil && req.Expiry == "" && req.ExpiresAt == "" {
+		if h.anonymousExpiry.Required {
+			WriteValidationError(w, []validation.ValidationError{{Field: "expiry", Message: "is required for anonymous pastes"}})
+			return
+		}
+		req.Expiry = h.anonymousExpiry.Default
+	}
+
+	// Handle expiry if provided, either as an absolute time or a duration
+	if req.ExpiresAt != "" {
+		if h.expiryPresets.Strict {
+			WriteValidationError(w, []validation.ValidationError{{
+				Field:   "expires_at",
+				Message: "is not allowed; choose an expiry from: " + strings.Join(h.expiryPresets.Presets, ", "),
+			}})
+			return
+		}
+		expiresAt, validationErr := h.validator.ValidateExpiresAt(req.ExpiresAt, time.Now())
 		if validationErr != nil {
 			WriteValidationError(w, []validation.ValidationError{*validationErr})
 			return
 		}
-		if duration != nil {
-			expiresAt := time.Now().Add(*duration)
-			paste.ExpiresAt = &expiresAt
-		}
+		paste.ExpiresAt = expiresAt
 	} else {
-		// Default expiry: 24 hours for anonymous pastes, never for authenticated users
-		if paste.UserID == nil {
-			expiresAt := time.Now().Add(24 * time.Hour)
-			paste.ExpiresAt = &expiresAt
+		if !h.expiryPresets.Allows(req.Expiry) {
+			WriteValidationError(w, []validation.ValidationError{{
+				Field:   "expiry",
+				Message: "must be one of: " + strings.Join(h.expiryPresets.Presets, ", "),
+			}})
+			return
+		}
+		if req.Expiry != "" {
+			duration, validationErr := h.validator.ValidateExpiryDuration(req.Expiry)
+			if validationErr != nil {
+				WriteValidationError(w, []validation.ValidationError{*validationErr})
+				return
+			}
+			if duration != nil {
+				expiresAt := time.Now().Add(*duration)
+				paste.ExpiresAt = &expiresAt
+			}
 		}
-		// For authenticated users, default to no expiry if not specified
 	}
-
-	// Save to database
-	if err := h.pasteRepo.Create(paste); err != nil {
-		WriteError(w, ErrInternalServer)
+	if paste.UserID == nil && paste.ExpiresAt == nil && !h.anonymousExpiry.AllowNever {
+		WriteValidationError(w, []validation.ValidationError{{Field: "expiry", Message: "anonymous pastes must expire"}})
 		return
 	}
 
-	// Prepare response
-	response := CreatePasteResponse{
-		ID:        paste.ID,
-		URL:       "https://privatepaste.example.com/" + paste.ID, // TODO: Use actual domain from config
-		CreatedAt: paste.CreatedAt.Format(time.RFC3339),
+	// Save to database, recording a salted hash of the creator IP for abuse
+	// investigations
+	err = h.creator.CreateFromRequest(r, paste, services.PasteAttributes{
+		Expiry:    req.Expiry,
+		ExpiresAt: req.ExpiresAt != "",
+	})
+	if err != nil {
+		writePolicyViolation(w, err)
+		return
+	}
+	if h.prerender && h.renderCache != nil {
+		go h.highlightedHTML(paste)
+	}
+	if len(tags) > 0 {
+		if err := h.tags.SetPasteTags(paste.ID, *paste.UserID, tags); err != nil {
+			WriteRepositoryError(w, err)
+			return
+		}
 	}
 
-	if paste.ExpiresAt != nil {
-		response.ExpiresAt = paste.ExpiresAt.Format(time.RFC3339)
+	// Prepare response
+	response := newCreatePasteResponse(paste)
+	if req.Password != "" {
+		strength := validation.EstimatePasswordStrength(req.Password)
+		response.PasswordStrength = &strength
 	}
 
 	w.Header().Set("Content-Type", "application/json")
@@ -155,6 +956,28 @@ func (h *PasteHandler) Create(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(response)
 }
 
+// CheckPasswordStrength handles estimating the strength of a prospective
+// paste password. Weak passwords are still accepted when creating a paste;
+// this only lets clients warn about them before submitting.
+func (h *PasteHandler) CheckPasswordStrength(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Password string `json:"password"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		WriteError(w, ErrInvalidJSON)
+		return
+	}
+	if req.Password == "" {
+		WriteValidationError(w, []validation.ValidationError{{Field: "password", Message: "is required"}})
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(validation.EstimatePasswordStrength(req.Password))
+}
+
 // GetByID handles retrieving a paste by its ID
 func (h *PasteHandler) GetByID(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
@@ -166,66 +989,36 @@ func (h *PasteHandler) GetByID(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Extract ID from URL path
-	vars := mux.Vars(r)
-	id := vars["id"]
-
-	// Validate ID format
-	if err := h.validator.ValidateID(id); err != nil {
-		WriteError(w, &APIError{
-			Code:    "invalid_id",
-			Message: "Invalid paste ID format",
-			Status:  http.StatusBadRequest,
-		})
-		return
-	}
-
-	// Get password from query parameter if provided
-	password := r.URL.Query().Get("password")
-
-	// Retrieve paste from database
-	paste, err := h.pasteRepo.GetByID(id)
-	if err != nil {
-		WriteError(w, ErrInternalServer)
-		return
-	}
-
-	if paste == nil {
-		WriteError(w, ErrPasteNotFound)
-		return
-	}
-
-	// Check if paste has expired
-	if paste.IsExpired() {
-		WriteError(w, ErrPasteExpired)
-		return
-	}
-
-	// Check password protection
-	if paste.HasPassword() {
-		if password == "" {
-			WriteError(w, ErrPasswordRequired)
-			return
-		}
-
-		if err := utils.VerifyPassword(password, *paste.PasswordHash); err != nil {
-			WriteError(w, ErrInvalidPassword)
-			return
-		}
+	paste, ok := h.loadViewablePaste(w, r)
+	if !ok {
+		return
 	}
+	h.recordView(paste, false)
 
 	// Prepare response
-	response := PasteResponse{
-		ID:          paste.ID,
-		Content:     paste.Content,
-		Language:    paste.Language,
-		CreatedAt:   paste.CreatedAt.Format(time.RFC3339),
-		HasPassword: paste.HasPassword(),
+	response := newPasteResponse(paste, ownsPaste(r, paste))
+	attachments, err := h.listAttachments(paste)
+	if err != nil {
+		WriteRepositoryError(w, err)
+		return
 	}
-
-	if paste.ExpiresAt != nil {
-		response.ExpiresAt = paste.ExpiresAt.Format(time.RFC3339)
+	response.Attachments = attachments
+	if response.Metadata, err = h.pasteMetadata(paste); err != nil {
+		WriteRepositoryError(w, err)
+		return
+	}
+	tags, err := h.pasteTags([]*models.Paste{paste})
+	if err != nil {
+		WriteRepositoryError(w, err)
+		return
 	}
+	response.Tags = tags[paste.ID]
+	views, err := h.viewCounts([]*models.Paste{paste})
+	if err != nil {
+		WriteRepositoryError(w, err)
+		return
+	}
+	response.Views = views[paste.ID]
 
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
@@ -243,6 +1036,64 @@ func (h *PasteHandler) GetRaw(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	paste, ok := h.loadViewablePaste(w, r)
+	if !ok || !h.checkSignedURL(w, r, paste) {
+		return
+	}
+	h.recordView(paste, true)
+
+	// Return raw content with appropriate headers
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	signContent(w, h.responseSigner, paste.ID, paste.Content)
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte(paste.Content))
+}
+
+// GetSignature handles retrieving the detached signature the creator attached
+// to a paste's content
+func (h *PasteHandler) GetSignature(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		WriteError(w, &APIError{
+			Code:    "method_not_allowed",
+			Message: "Method not allowed",
+			Status:  http.StatusMethodNotAllowed,
+		})
+		return
+	}
+
+	paste, ok := h.loadViewablePaste(w, r)
+	if !ok {
+		return
+	}
+	if paste.Signature == "" {
+		WriteError(w, ErrSignatureNotFound)
+		return
+	}
+
+	// Name the file the way gpg --verify and minisign -V expect by default
+	if paste.SignatureFormat == models.SignatureFormatMinisign {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.Header().Set("Content-Disposition", `inline; filename="`+paste.ID+`.txt.minisig"`)
+	} else {
+		w.Header().Set("Content-Type", "application/pgp-signature")
+		w.Header().Set("Content-Disposition", `inline; filename="`+paste.ID+`.txt.asc"`)
+	}
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte(paste.Signature))
+}
+
+// setRobotsHeader asks search engines not to index a paste's responses when
+// its creator opted out
+func setRobotsHeader(w http.ResponseWriter, paste *models.Paste) {
+	if paste.NoIndex {
+		w.Header().Set("X-Robots-Tag", "noindex, nofollow")
+	}
+}
+
+// loadViewablePaste resolves the paste addressed by the {id} route variable and
+// enforces expiry and password protection (via the password query parameter).
+// On failure it writes the error response and returns false.
+func (h *PasteHandler) loadViewablePaste(w http.ResponseWriter, r *http.Request) (*models.Paste, bool) {
 	// Extract ID from URL path
 	vars := mux.Vars(r)
 	id := vars["id"]
@@ -254,7 +1105,7 @@ func (h *PasteHandler) GetRaw(w http.ResponseWriter, r *http.Request) {
 			Message: "Invalid paste ID format",
 			Status:  http.StatusBadRequest,
 		})
-		return
+		return nil, false
 	}
 
 	// Get password from query parameter if provided
@@ -263,39 +1114,111 @@ func (h *PasteHandler) GetRaw(w http.ResponseWriter, r *http.Request) {
 	// Retrieve paste from database
 	paste, err := h.pasteRepo.GetByID(id)
 	if err != nil {
-		WriteError(w, ErrInternalServer)
-		return
+		WriteRepositoryError(w, err)
+		return nil, false
 	}
 
-	if paste == nil {
+	if paste == nil || !canView(r, paste) {
 		WriteError(w, ErrPasteNotFound)
-		return
+		return nil, false
 	}
 
 	// Check if paste has expired
 	if paste.IsExpired() {
 		WriteError(w, ErrPasteExpired)
-		return
+		return nil, false
+	}
+
+	// A shared cache would serve restricted pastes outside their access
+	// window, location or embedding pages
+	if paste.IsRestricted() {
+		w.Header().Set("Cache-Control", middleware.CachePrivate)
+	}
+	// Caches may only keep the paste until it expires
+	if paste.ExpiresAt != nil {
+		middleware.CacheUntil(w, *paste.ExpiresAt)
 	}
+	if !checkAccessWindow(w, r, paste) || !checkLocation(w, r, paste, h.countryLookup) {
+		return nil, false
+	}
+
+	// Honor the creator's do-not-track choice before anything else is recorded
+	if paste.DoNotTrack {
+		middleware.SuppressAccessLog(r)
+	}
+	setRobotsHeader(w, paste)
 
 	// Check password protection
 	if paste.HasPassword() {
 		if password == "" {
-			WriteError(w, ErrPasswordRequired)
-			return
+			h.writePasswordRequired(w, r, paste)
+			return nil, false
 		}
 
+		if !h.checkUnlockAttempts(w, paste) {
+			return nil, false
+		}
 		if err := utils.VerifyPassword(password, *paste.PasswordHash); err != nil {
-			WriteError(w, ErrInvalidPassword)
-			return
+			h.rejectPassword(w, paste)
+			return nil, false
 		}
 	}
 
-	// Return raw content with appropriate headers
-	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
-	w.Header().Set("Cache-Control", "public, max-age=3600")
-	w.WriteHeader(http.StatusOK)
-	w.Write([]byte(paste.Content))
+	return paste, true
+}
+
+// writePasswordRequired writes the password_required error for a protected
+// paste, including the creator's hint unless the client has been shown too
+// many hints recently
+func (h *PasteHandler) writePasswordRequired(w http.ResponseWriter, r *http.Request, paste *models.Paste) {
+	if paste.PasswordHint == "" || (h.hintLimiter != nil && !h.hintLimiter.Allow(middleware.PolicyPasswordHint, r)) {
+		WriteError(w, ErrPasswordRequired)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(ErrPasswordRequired.Status)
+	json.NewEncoder(w).Encode(struct {
+		*APIError
+		Hint string `json:"hint"`
+	}{ErrPasswordRequired, paste.PasswordHint})
+}
+
+// checkUnlockAttempts refuses passwords for pastes limited to a number of
+// failed unlock attempts while writes are paused, since failures could not
+// be counted. On refusal it writes the error response and returns false.
+func (h *PasteHandler) checkUnlockAttempts(w http.ResponseWriter, paste *models.Paste) bool {
+	if paste.MaxUnlockAttempts == nil || !h.writesPaused() {
+		return true
+	}
+	WriteError(w, &APIError{
+		Code:    "read_only",
+		Message: "This paste cannot be unlocked while the instance is temporarily read-only; try again later",
+		Status:  http.StatusServiceUnavailable,
+	})
+	return false
+}
+
+// rejectPassword writes the response to a wrong password, counting it
+// against pastes limited to a number of failed unlock attempts and
+// destroying them once the limit is reached
+func (h *PasteHandler) rejectPassword(w http.ResponseWriter, paste *models.Paste) {
+	if paste.MaxUnlockAttempts == nil {
+		WriteError(w, ErrInvalidPassword)
+		return
+	}
+
+	destroyed, err := h.pasteRepo.RecordFailedUnlock(paste.ID)
+	if err != nil {
+		WriteRepositoryError(w, err)
+		return
+	}
+	if !destroyed {
+		WriteError(w, ErrInvalidPassword)
+		return
+	}
+	h.queuePurge(paste)
+	WriteError(w, ErrPasteDestroyed)
 }
 
 // GetByIDWithPassword handles retrieving a password-protected paste via POST
@@ -343,11 +1266,11 @@ func (h *PasteHandler) GetByIDWithPassword(w http.ResponseWriter, r *http.Reques
 	// Retrieve paste from database
 	paste, err := h.pasteRepo.GetByID(id)
 	if err != nil {
-		WriteError(w, ErrInternalServer)
+		WriteRepositoryError(w, err)
 		return
 	}
 
-	if paste == nil {
+	if paste == nil || !canView(r, paste) {
 		WriteError(w, ErrPasteNotFound)
 		return
 	}
@@ -358,6 +1281,16 @@ func (h *PasteHandler) GetByIDWithPassword(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
+	if !checkAccessWindow(w, r, paste) || !checkLocation(w, r, paste, h.countryLookup) {
+		return
+	}
+
+	// Honor the creator's do-not-track choice before anything else is recorded
+	if paste.DoNotTrack {
+		middleware.SuppressAccessLog(r)
+	}
+	setRobotsHeader(w, paste)
+
 	// Check password protection
 	if !paste.HasPassword() {
 		WriteError(w, &APIError{
@@ -368,23 +1301,39 @@ func (h *PasteHandler) GetByIDWithPassword(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
+	if !h.checkUnlockAttempts(w, paste) {
+		return
+	}
 	if err := utils.VerifyPassword(req.Password, *paste.PasswordHash); err != nil {
-		WriteError(w, ErrInvalidPassword)
+		h.rejectPassword(w, paste)
 		return
 	}
+	h.recordView(paste, false)
 
 	// Prepare response
-	response := PasteResponse{
-		ID:          paste.ID,
-		Content:     paste.Content,
-		Language:    paste.Language,
-		CreatedAt:   paste.CreatedAt.Format(time.RFC3339),
-		HasPassword: paste.HasPassword(),
+	response := newPasteResponse(paste, ownsPaste(r, paste))
+	attachments, err := h.listAttachments(paste)
+	if err != nil {
+		WriteRepositoryError(w, err)
+		return
 	}
-
-	if paste.ExpiresAt != nil {
-		response.ExpiresAt = paste.ExpiresAt.Format(time.RFC3339)
+	response.Attachments = attachments
+	if response.Metadata, err = h.pasteMetadata(paste); err != nil {
+		WriteRepositoryError(w, err)
+		return
+	}
+	tags, err := h.pasteTags([]*models.Paste{paste})
+	if err != nil {
+		WriteRepositoryError(w, err)
+		return
+	}
+	response.Tags = tags[paste.ID]
+	views, err := h.viewCounts([]*models.Paste{paste})
+	if err != nil {
+		WriteRepositoryError(w, err)
+		return
 	}
+	response.Views = views[paste.ID]
 
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
@@ -419,7 +1368,7 @@ func (h *PasteHandler) Delete(w http.ResponseWriter, r *http.Request) {
 	// Check if paste exists
 	paste, err := h.pasteRepo.GetByID(id)
 	if err != nil {
-		WriteError(w, ErrInternalServer)
+		WriteRepositoryError(w, err)
 		return
 	}
 
@@ -450,13 +1399,27 @@ func (h *PasteHandler) Delete(w http.ResponseWriter, r *http.Request) {
 
 	// Delete the paste
 	if err := h.pasteRepo.Delete(id); err != nil {
-		WriteError(w, ErrInternalServer)
+		WriteRepositoryError(w, err)
 		return
 	}
 
+	h.queuePurge(paste)
+
 	w.WriteHeader(http.StatusNoContent)
 }
 
+// queuePurge queues purging a deleted paste from the CDN. A failed purge
+// only leaves edge copies around until they expire, so it does not fail the
+// delete.
+func (h *PasteHandler) queuePurge(paste *models.Paste) {
+	if h.purgeQueue == nil {
+		return
+	}
+	if err := h.purgeQueue.Enqueue(services.JobTypeCDNPurge, services.CDNPurge{Keys: paste.SurrogateKeys()}); err != nil {
+		log.Printf("Failed to queue CDN purge of paste %s: %v", paste.ID, err)
+	}
+}
+
 // UserPastesResponse represents a paginated list of user pastes
 type UserPastesResponse struct {
 	Pastes []PasteListItem `json:"pastes"`
@@ -469,10 +1432,38 @@ type UserPastesResponse struct {
 type PasteListItem struct {
 	ID          string `json:"id"`
 	Language    string `json:"language,omitempty"`
+	Kind        string `json:"kind"`
+	Visibility  string `json:"visibility"`
 	CreatedAt   string `json:"created_at"`
 	ExpiresAt   string `json:"expires_at,omitempty"`
 	HasPassword bool   `json:"has_password"`
 	Size        int    `json:"size"`
+
+	AccessWindow string   `json:"access_window,omitempty"`
+	Tags         []string `json:"tags,omitempty"`
+
+	Views *PasteViews `json:"views,omitempty"` // Absent for pastes with do_not_track
+}
+
+// newPasteListItem summarizes a paste for a list. The access window is only
+// listed for the paste's owner.
+func newPasteListItem(paste *models.Paste, owner bool) PasteListItem {
+	item := PasteListItem{
+		ID:          paste.ID,
+		Language:    paste.Language,
+		Kind:        paste.Kind,
+		Visibility:  paste.Visibility,
+		CreatedAt:   paste.CreatedAt.Format(time.RFC3339),
+		HasPassword: paste.HasPassword(),
+		Size:        len(paste.Content),
+	}
+	if paste.ExpiresAt != nil {
+		item.ExpiresAt = paste.ExpiresAt.Format(time.RFC3339)
+	}
+	if owner && paste.AccessWindow != nil {
+		item.AccessWindow = paste.AccessWindow.String()
+	}
+	return item
 }
 
 // GetUserPastes handles retrieving all pastes for the authenticated user
@@ -515,36 +1506,56 @@ func (h *PasteHandler) GetUserPastes(w http.ResponseWriter, r *http.Request) {
 
 	offset := (page - 1) * limit
 
-	// Get user's pastes
-	pastes, err := h.pasteRepo.GetByUserID(userID, limit, offset)
+	// Get user's pastes, only those with a tag if one is given
+	var pastes []*models.Paste
+	var total int
+	var err error
+	if tag := r.URL.Query().Get("tag"); tag != "" {
+		if h.tags == nil {
+			WriteError(w, &APIError{
+				Code:    "tags_disabled",
+				Message: "Tags are not enabled on this instance",
+				Status:  http.StatusBadRequest,
+			})
+			return
+		}
+		pastes, total, err = h.tags.ListPastes(userID, strings.ToLower(tag), limit, offset)
+		if err != nil {
+			WriteRepositoryError(w, err)
+			return
+		}
+	} else {
+		pastes, err = h.pasteRepo.GetByUserID(userID, limit, offset)
+		if err != nil {
+			WriteRepositoryError(w, err)
+			return
+		}
+
+		// Get total count
+		total, err = h.pasteRepo.CountByUserID(userID)
+		if err != nil {
+			WriteRepositoryError(w, err)
+			return
+		}
+	}
+
+	views, err := h.viewCounts(pastes)
 	if err != nil {
-		WriteError(w, ErrInternalServer)
+		WriteRepositoryError(w, err)
 		return
 	}
-
-	// Get total count
-	total, err := h.pasteRepo.CountByUserID(userID)
+	tags, err := h.pasteTags(pastes)
 	if err != nil {
-		WriteError(w, ErrInternalServer)
+		WriteRepositoryError(w, err)
 		return
 	}
 
 	// Convert to list items
 	pasteItems := make([]PasteListItem, len(pastes))
 	for i, paste := range pastes {
-		item := PasteListItem{
-			ID:          paste.ID,
-			Language:    paste.Language,
-			CreatedAt:   paste.CreatedAt.Format(time.RFC3339),
-			HasPassword: paste.HasPassword(),
-			Size:        len(paste.Content),
-		}
-
-		if paste.ExpiresAt != nil {
-			item.ExpiresAt = paste.ExpiresAt.Format(time.RFC3339)
-		}
-
-		pasteItems[i] = item
+		pasteItems[i] = newPasteListItem(paste, true)
+		pasteItems[i].Tags = tags[paste.ID]
+		pasteItems[i].Views = views[paste.ID]
 	}
 
 	// Prepare response
@@ -559,3 +1570,175 @@ func (h *PasteHandler) GetUserPastes(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusOK)
 	json.NewEncoder(w).Encode(response)
 }
+
+// UserTagsResponse lists the tags on a user's pastes
+type UserTagsResponse struct {
+	Tags []models.TagCount `json:"tags"`
+}
+
+// GetUserTags handles listing the tags on the authenticated user's pastes
+// with how many pastes carry each, most used first
+func (h *PasteHandler) GetUserTags(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		WriteError(w, &APIError{
+			Code:    "method_not_allowed",
+			Message: "Method not allowed",
+			Status:  http.StatusMethodNotAllowed,
+		})
+		return
+	}
+
+	userID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		WriteError(w, &APIError{
+			Code:    "unauthorized",
+			Message: "User ID not found in token",
+			Status:  http.StatusUnauthorized,
+		})
+		return
+	}
+
+	response := UserTagsResponse{Tags: []models.TagCount{}}
+	if h.tags != nil {
+		tags, err := h.tags.ListByUserID(userID)
+		if err != nil {
+			WriteRepositoryError(w, err)
+			return
+		}
+		response.Tags = tags
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
+
+// searchSnippetLength caps the snippets shown with search results, in
+// characters
+const searchSnippetLength = 200
+
+// PasteSearchItem is a paste found by a search, with the first line of its
+// content matching the query
+type PasteSearchItem struct {
+	PasteListItem
+	Snippet string `json:"snippet,omitempty"`
+}
+
+// UserPasteSearchResponse is a page of a user's pastes matching a search
+type UserPasteSearchResponse struct {
+	Pastes []PasteSearchItem `json:"pastes"`
+	Total  int               `json:"total"`
+	Page   int               `json:"page"`
+	Limit  int               `json:"limit"`
+}
+
+// SearchUserPastes handles full-text search over the authenticated user's
+// unexpired pastes, newest first. Every word of the query must match.
+func (h *PasteHandler) SearchUserPastes(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		WriteError(w, &APIError{
+			Code:    "method_not_allowed",
+			Message: "Method not allowed",
+			Status:  http.StatusMethodNotAllowed,
+		})
+		return
+	}
+
+	userID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		WriteError(w, &APIError{
+			Code:    "unauthorized",
+			Message: "User ID not found in token",
+			Status:  http.StatusUnauthorized,
+		})
+		return
+	}
+
+	if h.search == nil {
+		WriteError(w, &APIError{
+			Code:    "search_disabled",
+			Message: "Search is not enabled on this instance",
+			Status:  http.StatusServiceUnavailable,
+		})
+		return
+	}
+
+	query := strings.TrimSpace(r.URL.Query().Get("q"))
+	if err := h.validator.ValidateString(query, "q", true, 1, 200); err != nil {
+		WriteValidationError(w, []validation.ValidationError{*err})
+		return
+	}
+
+	page := 1
+	limit := 20
+	if p, err := strconv.Atoi(r.URL.Query().Get("page")); err == nil && p > 0 {
+		page = p
+	}
+	if l, err := strconv.Atoi(r.URL.Query().Get("limit")); err == nil && l > 0 && l <= 100 {
+		limit = l
+	}
+
+	pastes, total, err := h.search.Search(models.PasteSearchFilter{
+		Query:     query,
+		UserID:    userID,
+		Unexpired: true,
+		Limit:     limit,
+		Offset:    (page - 1) * limit,
+	})
+	if err != nil {
+		WriteRepositoryError(w, err)
+		return
+	}
+
+	views, err := h.viewCounts(pastes)
+	if err != nil {
+		WriteRepositoryError(w, err)
+		return
+	}
+	tags, err := h.pasteTags(pastes)
+	if err != nil {
+		WriteRepositoryError(w, err)
+		return
+	}
+
+	items := make([]PasteSearchItem, len(pastes))
+	for i, paste := range pastes {
+		items[i] = PasteSearchItem{PasteListItem: newPasteListItem(paste, true)}
+		items[i].Tags = tags[paste.ID]
+		items[i].Views = views[paste.ID]
+		// Content behind a password is not quoted, as in other listings
+		if !paste.HasPassword() {
+			items[i].Snippet = searchSnippet(paste.Content, query)
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(UserPasteSearchResponse{
+		Pastes: items,
+		Total:  total,
+		Page:   page,
+		Limit:  limit,
+	})
+}
+
+// searchSnippet returns the first line of content containing a word of the
+// query, ignoring case, or the first line if none does
+func searchSnippet(content, query string) string {
+	lines := strings.Split(content, "\n")
+	snippet := lines[0]
+	words := strings.Fields(strings.ToLower(query))
+	for _, line := range lines {
+		lower := strings.ToLower(line)
+		if slices.ContainsFunc(words, func(word string) bool { return strings.Contains(lower, word) }) {
+			snippet = line
+			break
+		}
+	}
+
+	runes := []rune(strings.TrimSpace(snippet))
+	if len(runes) > searchSnippetLength {
+		runes = runes[:searchSnippetLength]
+	}
+	return string(runes)
+}
@@ -0,0 +1,182 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/LonleySailor/privatepaste/backend/internal/middleware"
+	"github.com/LonleySailor/privatepaste/backend/internal/models"
+	"github.com/LonleySailor/privatepaste/backend/internal/services"
+	"github.com/LonleySailor/privatepaste/backend/pkg/utils"
+	"github.com/LonleySailor/privatepaste/backend/pkg/validation"
+)
+
+// UserRoles looks up the accounts pastes are created for outside a session,
+// such as with an API key or over SSH, to find their role
+type UserRoles interface {
+	GetByID(id int) (*models.User, error)
+}
+
+// PasteCreator stores new pastes. Every way of creating a paste goes through
+// it, so none of them can skip the creator's content policy.
+type PasteCreator struct {
+	pasteRepo PasteRepositoryInterface
+	policies  ContentPolicies            // Nil applies each role's default policy
+	users     UserRoles                  // Nil takes every account for a plain user
+	ipHasher  *utils.IPHasher            // Nil records no creator IP hashes
+	hookQueue JobEnqueuer                // Nil runs no post-create hooks
+	countries *middleware.GeoRestriction // Nil creates pastes from any country
+}
+
+// NewPasteCreator creates a paste creator storing pastes in pasteRepo
+func NewPasteCreator(pasteRepo PasteRepositoryInterface) *PasteCreator {
+	return &PasteCreator{pasteRepo: pasteRepo}
+}
+
+// SetContentPolicies enables enforcing per-role content policies
+func (c *PasteCreator) SetContentPolicies(policies ContentPolicies) {
+	c.policies = policies
+}
+
+// SetUserRoles enables looking up the role of accounts creating pastes
+// outside a session
+func (c *PasteCreator) SetUserRoles(users UserRoles) {
+	c.users = users
+}
+
+// SetIPHasher enables recording salted creator IP hashes on pastes created
+// by HTTP requests
+func (c *PasteCreator) SetIPHasher(hasher *utils.IPHasher) {
+	c.ipHasher = hasher
+}
+
+// SetHookQueue enables running post-create hooks on new pastes
+func (c *PasteCreator) SetHookQueue(queue JobEnqueuer) {
+	c.hookQueue = queue
+}
+
+// SetCountryRestriction enables refusing pastes created by requests from
+// restricted countries
+func (c *PasteCreator) SetCountryRestriction(restriction *middleware.GeoRestriction) {
+	c.countries = restriction
+}
+
+// Policy returns the content policy of a role
+func (c *PasteCreator) Policy(role string) (*models.ContentPolicy, error) {
+	if c.policies == nil {
+		return models.DefaultContentPolicy(role), nil
+	}
+	return c.policies.Policy(role)
+}
+
+// userRole returns the role of the account with the given ID
+func (c *PasteCreator) userRole(userID int) (string, error) {
+	if c.users == nil {
+		return models.RoleUser, nil
+	}
+	user, err := c.users.GetByID(userID)
+	if err != nil {
+		return "", err
+	}
+	if user == nil {
+		return "", errors.New("paste creator not found")
+	}
+	return user.Role, nil
+}
+
+// Create checks a paste against the content policy of its creator's role
+// and stores it. The size and visibility checked are the paste's; attrs
+// describes how its expiry was chosen and what was uploaded along with it.
+// Expiry times are stored in UTC and, unless kept from another paste, may
+// be at most validation.MaxExpiry ahead. A refused paste is reported as a
+// *services.PolicyViolation. Post-create hooks are queued for stored ones.
+func (c *PasteCreator) Create(paste *models.Paste, role string, attrs services.PasteAttributes) error {
+	if paste.ExpiresAt != nil {
+		// Stored in UTC so it compares correctly with the database clock
+		utc := paste.ExpiresAt.UTC()
+		paste.ExpiresAt = &utc
+		if !attrs.ExpiryKept && time.Until(utc) > validation.MaxExpiry {
+			return &services.PolicyViolation{
+				Code:    services.ViolationExpiry,
+				Message: "Pastes cannot expire more than 1 year in the future",
+			}
+		}
+	}
+
+	policy, err := c.Policy(role)
+	if err != nil {
+		return err
+	}
+	attrs.Size = len(paste.Content)
+	attrs.Visibility = paste.Visibility
+	if err := services.CheckPaste(policy, attrs); err != nil {
+		return err
+	}
+	if err := c.pasteRepo.Create(paste); err != nil {
+		return err
+	}
+
+	if c.hookQueue != nil {
+		if err := c.hookQueue.Enqueue(services.JobTypePasteHooks, services.PasteHookRun{PasteID: paste.ID}); err != nil {
+			log.Printf("Failed to queue hooks for paste %s: %v", paste.ID, err)
+		}
+	}
+	return nil
+}
+
+// CreateFromRequest is Create for a paste created by an HTTP request,
+// checked against the policy of the request's role and recording a salted
+// hash of the client IP. Requests from restricted countries are refused.
+func (c *PasteCreator) CreateFromRequest(r *http.Request, paste *models.Paste, attrs services.PasteAttributes) error {
+	if err := c.checkRequest(r, paste); err != nil {
+		return err
+	}
+	return c.Create(paste, requestRole(r), attrs)
+}
+
+// CreateOwnedFromRequest is CreateOwned for a paste created by an HTTP
+// request authenticated with an API key, refused and recorded like one
+// created with CreateFromRequest
+func (c *PasteCreator) CreateOwnedFromRequest(r *http.Request, paste *models.Paste, attrs services.PasteAttributes) error {
+	if err := c.checkRequest(r, paste); err != nil {
+		return err
+	}
+	return c.CreateOwned(paste, attrs)
+}
+
+// checkRequest refuses a paste created from a restricted country with a
+// *services.PolicyViolation, and otherwise records the client IP hash
+func (c *PasteCreator) checkRequest(r *http.Request, paste *models.Paste) error {
+	if c.countries != nil {
+		if code, message := c.countries.Check(r); code != "" {
+			return &services.PolicyViolation{Code: code, Message: message}
+		}
+	}
+	if c.ipHasher != nil {
+		hash := c.ipHasher.Hash(middleware.ClientIP(r))
+		paste.CreatorIPHash = &hash
+	}
+	return nil
+}
+
+// CreateOwned is Create for a paste created outside a session, such as with
+// an API key, checked against the policy of its owner's role
+func (c *PasteCreator) CreateOwned(paste *models.Paste, attrs services.PasteAttributes) error {
+	role, err := c.userRole(*paste.UserID)
+	if err != nil {
+		return err
+	}
+	return c.Create(paste, role, attrs)
+}
+
+// requestRole returns the role of the request's account. Requests without
+// an account have the anonymous role.
+func requestRole(r *http.Request) string {
+	role := models.RoleAnonymous
+	if _, ok := middleware.GetUserIDFromContext(r.Context()); ok {
+		role, _ = middleware.GetRoleFromContext(r.Context())
+	}
+	return role
+}
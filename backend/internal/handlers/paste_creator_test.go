@@ -0,0 +1,124 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/LonleySailor/privatepaste/backend/internal/middleware"
+	"github.com/LonleySailor/privatepaste/backend/internal/models"
+	"github.com/LonleySailor/privatepaste/backend/internal/services"
+	"github.com/LonleySailor/privatepaste/backend/pkg/utils"
+	"github.com/LonleySailor/privatepaste/backend/pkg/validation"
+)
+
+// stubUserRoles maps user IDs to accounts for tests
+type stubUserRoles map[int]*models.User
+
+func (s stubUserRoles) GetByID(id int) (*models.User, error) {
+	return s[id], nil
+}
+
+func TestPasteCreator_CreateOwned(t *testing.T) {
+	repo := models.NewMemoryPasteRepository()
+	creator := NewPasteCreator(repo)
+	creator.SetContentPolicies(stubContentPolicies{
+		"restricted": {Role: "restricted", MaxSize: 10, AllowedExpiries: []string{"1h"}},
+	})
+	creator.SetUserRoles(stubUserRoles{
+		7: {ID: 7, Role: "restricted"},
+		8: {ID: 8, Role: models.RoleUser},
+	})
+
+	create := func(userID int, id, content string, attrs services.PasteAttributes) error {
+		return creator.CreateOwned(&models.Paste{
+			ID:         id,
+			Content:    content,
+			UserID:     &userID,
+			Visibility: models.VisibilityUnlisted,
+		}, attrs)
+	}
+
+	tests := []struct {
+		name    string
+		userID  int
+		content string
+		attrs   services.PasteAttributes
+		code    string
+	}{
+		{"too large", 7, "more than ten bytes", services.PasteAttributes{Expiry: "1h"}, services.ViolationContentTooLarge},
+		{"expiry", 7, "small", services.PasteAttributes{Expiry: "2h"}, services.ViolationExpiry},
+		{"allowed", 7, "small", services.PasteAttributes{Expiry: "1h"}, ""},
+		{"another role", 8, "more than ten bytes", services.PasteAttributes{}, ""},
+	}
+	for _, tt := range tests {
+		var violation *services.PolicyViolation
+		err := create(tt.userID, tt.name, tt.content, tt.attrs)
+		if tt.code == "" && err != nil {
+			t.Errorf("%s: expected the paste to be stored, got %v", tt.name, err)
+		}
+		if tt.code != "" && (!errors.As(err, &violation) || violation.Code != tt.code) {
+			t.Errorf("%s: expected violation %s, got %v", tt.name, tt.code, err)
+		}
+	}
+
+	// An expiry carried over from another paste is not checked again
+	userID, expiresAt := 7, time.Now().Add(time.Hour)
+	err := creator.CreateOwned(&models.Paste{ID: "derived", Content: "small", UserID: &userID, ExpiresAt: &expiresAt},
+		services.PasteAttributes{ExpiryKept: true})
+	if err != nil {
+		t.Errorf("Expected a kept expiry to be accepted, got %v", err)
+	}
+	if paste, _ := repo.GetByID("derived"); paste == nil {
+		t.Error("Expected the derived paste to be stored")
+	}
+}
+
+// stubAPIKeys maps API keys to their records for tests
+type stubAPIKeys map[string]*models.APIKey
+
+func (s stubAPIKeys) Authenticate(key string) (*models.APIKey, error) {
+	return s[key], nil
+}
+
+func TestPasteCreator_CountryRestriction(t *testing.T) {
+	// The test client's address resolves to a denied country
+	lookup := stubCountryLookup{"192.0.2.1": "KP"}
+	denied := middleware.NewGeoRestriction(lookup, nil, []string{"KP"})
+
+	handler, repo := setupTestHandler()
+	handler.SetCountryRestriction(denied)
+	repo.Create(&models.Paste{ID: "geo123", Content: "a%20b", Visibility: models.VisibilityPublic})
+
+	rr := postTransform(handler, "geo123", TransformPasteRequest{Operations: []string{"url-decode"}})
+	if rr.Code != http.StatusForbidden || !strings.Contains(rr.Body.String(), "country_not_allowed") {
+		t.Errorf("Expected a transform from a denied country to be refused, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	quick := NewQuickHandler(repo, stubAPIKeys{"key": {UserID: 7}}, utils.NewIDGenerator(), validation.NewValidator())
+	creator := NewPasteCreator(repo)
+	quick.SetPasteCreator(creator)
+	post := func() *httptest.ResponseRecorder {
+		form := url.Values{"key": {"key"}, "text": {"from the extension"}}
+		req := httptest.NewRequest("POST", "/api/quick", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		rr := httptest.NewRecorder()
+		quick.Create(rr, req)
+		return rr
+	}
+	if rr := post(); rr.Code != http.StatusCreated {
+		t.Fatalf("Expected a quick paste without restrictions to be stored, got %d: %s", rr.Code, rr.Body.String())
+	}
+	creator.SetCountryRestriction(denied)
+	if rr := post(); rr.Code != http.StatusForbidden || !strings.Contains(rr.Body.String(), "country_not_allowed") {
+		t.Errorf("Expected a quick paste from a denied country to be refused, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	if pastes, _ := repo.GetByUserID(7, 100, 0); len(pastes) != 1 {
+		t.Errorf("Expected only the unrestricted quick paste to be stored, got %d", len(pastes))
+	}
+}
@@ -2,68 +2,24 @@ package handlers
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"net"
 	"net/http"
 	"net/http/httptest"
 	"testing"
 	"time"
 
+	"github.com/LonleySailor/privatepaste/backend/internal/middleware"
 	"github.com/LonleySailor/privatepaste/backend/internal/models"
+	"github.com/LonleySailor/privatepaste/backend/internal/services"
 	"github.com/LonleySailor/privatepaste/backend/pkg/utils"
 	"github.com/LonleySailor/privatepaste/backend/pkg/validation"
 	"github.com/gorilla/mux"
 )
 
-// MockPasteRepository implements a mock paste repository for testing
-type MockPasteRepository struct {
-	pastes map[string]*models.Paste
-}
-
-func NewMockPasteRepository() *MockPasteRepository {
-	return &MockPasteRepository{
-		pastes: make(map[string]*models.Paste),
-	}
-}
-
-func (r *MockPasteRepository) Create(paste *models.Paste) error {
-	paste.CreatedAt = time.Now()
-	r.pastes[paste.ID] = paste
-	return nil
-}
-
-func (r *MockPasteRepository) GetByID(id string) (*models.Paste, error) {
-	paste, exists := r.pastes[id]
-	if !exists {
-		return nil, nil
-	}
-	return paste, nil
-}
-
-func (r *MockPasteRepository) Exists(id string) (bool, error) {
-	_, exists := r.pastes[id]
-	return exists, nil
-}
-
-func (r *MockPasteRepository) Delete(id string) error {
-	delete(r.pastes, id)
-	return nil
-}
-
-func (r *MockPasteRepository) GetByUserID(userID int, limit, offset int) ([]*models.Paste, error) {
-	return nil, nil
-}
-
-func (r *MockPasteRepository) Update(paste *models.Paste) error {
-	r.pastes[paste.ID] = paste
-	return nil
-}
-
-func (r *MockPasteRepository) DeleteExpired() (int64, error) {
-	return 0, nil
-}
-
-func setupTestHandler() (*PasteHandler, *MockPasteRepository) {
-	mockRepo := NewMockPasteRepository()
+func setupTestHandler() (*PasteHandler, *models.MemoryPasteRepository) {
+	mockRepo := models.NewMemoryPasteRepository()
 	idGenerator := utils.NewIDGenerator()
 	validator := validation.NewValidator()
 
@@ -136,6 +92,46 @@ func TestCreatePaste_WithPassword(t *testing.T) {
 	}
 }
 
+func TestCreatePaste_DoNotTrack(t *testing.T) {
+	handler, mockRepo := setupTestHandler()
+
+	reqBody := CreatePasteRequest{
+		Content:    "Untracked content",
+		DoNotTrack: true,
+	}
+
+	body, _ := json.Marshal(reqBody)
+	req := httptest.NewRequest("POST", "/api/paste", bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+
+	rr := httptest.NewRecorder()
+	handler.Create(rr, req)
+
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("Expected status %d, got %d", http.StatusCreated, rr.Code)
+	}
+
+	var response CreatePasteResponse
+	json.Unmarshal(rr.Body.Bytes(), &response)
+
+	// Verify the flag is echoed back on retrieval
+	req = httptest.NewRequest("GET", "/api/paste/"+response.ID, nil)
+	req = mux.SetURLVars(req, map[string]string{"id": response.ID})
+	rr = httptest.NewRecorder()
+	handler.GetByID(rr, req)
+
+	var pasteResp PasteResponse
+	json.Unmarshal(rr.Body.Bytes(), &pasteResp)
+
+	if !pasteResp.DoNotTrack {
+		t.Error("Expected do_not_track to be set in paste response")
+	}
+
+	if paste, _ := mockRepo.GetByID(response.ID); !paste.DoNotTrack {
+		t.Error("Expected stored paste to be flagged do-not-track")
+	}
+}
+
 func TestCreatePaste_ValidationErrors(t *testing.T) {
 	handler, _ := setupTestHandler()
 
@@ -177,6 +173,31 @@ func TestCreatePaste_ValidationErrors(t *testing.T) {
 	}
 }
 
+// slowBody is a request body whose client stopped sending partway
+type slowBody struct{ sent bool }
+
+func (b *slowBody) Read(p []byte) (int, error) {
+	if b.sent {
+		return 0, middleware.ErrSlowBody
+	}
+	b.sent = true
+	return copy(p, `{"content": "partial`), nil
+}
+
+func TestCreatePaste_SlowBody(t *testing.T) {
+	handler, _ := setupTestHandler()
+
+	req := httptest.NewRequest("POST", "/api/paste", &slowBody{})
+	req.Header.Set("Content-Type", "application/json")
+
+	rr := httptest.NewRecorder()
+	handler.Create(rr, req)
+
+	if rr.Code != http.StatusRequestTimeout {
+		t.Errorf("Expected status %d, got %d: %s", http.StatusRequestTimeout, rr.Code, rr.Body.String())
+	}
+}
+
 func TestGetPaste_Success(t *testing.T) {
 	handler, mockRepo := setupTestHandler()
 
@@ -233,8 +254,8 @@ func TestGetPaste_PasswordProtected(t *testing.T) {
 	rr := httptest.NewRecorder()
 	handler.GetByID(rr, req)
 
-	if rr.Code != http.StatusUnauthorized {
-		t.Errorf("Expected status %d, got %d", http.StatusUnauthorized, rr.Code)
+	if rr.Code != http.StatusLocked {
+		t.Errorf("Expected status %d, got %d", http.StatusLocked, rr.Code)
 	}
 
 	// Test retrieval with correct password
@@ -383,3 +404,475 @@ func TestUnlockPaste_Success(t *testing.T) {
 		t.Errorf("Expected content 'Secret unlock content', got '%s'", response.Content)
 	}
 }
+
+func TestUnlockPaste_ReadOnly(t *testing.T) {
+	handler, mockRepo := setupTestHandler()
+	handler.SetReadOnlyMode(middleware.NewReadOnlyMode(true))
+
+	hashedPassword, _ := utils.HashPassword("secret123")
+	maxAttempts := 3
+	mockRepo.Create(&models.Paste{ID: "ro1234", Content: "unlimited", PasswordHash: &hashedPassword})
+	mockRepo.Create(&models.Paste{ID: "ro5678", Content: "limited", PasswordHash: &hashedPassword, MaxUnlockAttempts: &maxAttempts})
+
+	unlock := func(id string) int {
+		body, _ := json.Marshal(map[string]string{"password": "secret123"})
+		req := httptest.NewRequest("POST", "/api/paste/"+id+"/unlock", bytes.NewBuffer(body))
+		req = mux.SetURLVars(req, map[string]string{"id": id})
+		rr := httptest.NewRecorder()
+		handler.GetByIDWithPassword(rr, req)
+		return rr.Code
+	}
+
+	if code := unlock("ro1234"); code != http.StatusOK {
+		t.Errorf("Expected status %d unlocking while read-only, got %d", http.StatusOK, code)
+	}
+	// Failed attempts could not be counted, so limited pastes wait
+	if code := unlock("ro5678"); code != http.StatusServiceUnavailable {
+		t.Errorf("Expected status %d for a paste limiting unlock attempts, got %d", http.StatusServiceUnavailable, code)
+	}
+}
+
+func TestCreatePaste_UnlistedUsesLongID(t *testing.T) {
+	handler, mockRepo := setupTestHandler()
+
+	id := createTestPaste(t, handler, CreatePasteRequest{Content: "secret-ish", Visibility: "unlisted"})
+	if len(id) != utils.LongIDLength {
+		t.Errorf("Expected unlisted paste ID of length %d, got %q", utils.LongIDLength, id)
+	}
+
+	publicID := createTestPaste(t, handler, CreatePasteRequest{Content: "hello"})
+	if len(publicID) != utils.IDLength {
+		t.Errorf("Expected public paste ID of length %d, got %q", utils.IDLength, publicID)
+	}
+
+	unlisted, _ := mockRepo.GetByID(id)
+	public, _ := mockRepo.GetByID(publicID)
+	if unlisted.Visibility != "unlisted" || public.Visibility != "public" {
+		t.Error("Expected visibility to be stored on the paste")
+	}
+
+	// Long IDs must still be accepted on retrieval
+	if rr := getPasteView(handler.GetByID, id, ""); rr.Code != http.StatusOK {
+		t.Errorf("Expected status %d for unlisted paste, got %d", http.StatusOK, rr.Code)
+	}
+}
+
+func TestPrivatePaste(t *testing.T) {
+	handler, mockRepo := setupTestHandler()
+
+	// Anonymous users cannot create private pastes
+	body, _ := json.Marshal(CreatePasteRequest{Content: "mine", Visibility: "private"})
+	rr := httptest.NewRecorder()
+	handler.Create(rr, httptest.NewRequest("POST", "/api/paste", bytes.NewBuffer(body)))
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected status %d for anonymous private paste, got %d", http.StatusBadRequest, rr.Code)
+	}
+
+	ownerID := 7
+	mockRepo.Create(&models.Paste{
+		ID: "aB3dE5gH7jK9mN1pQ3sT", Content: "mine", Visibility: "private", UserID: &ownerID,
+	})
+
+	// Everyone but the owner gets a 404
+	if rr := getPasteView(handler.GetByID, "aB3dE5gH7jK9mN1pQ3sT", ""); rr.Code != http.StatusNotFound {
+		t.Errorf("Expected status %d for non-owner, got %d", http.StatusNotFound, rr.Code)
+	}
+
+	req := httptest.NewRequest("GET", "/api/paste/aB3dE5gH7jK9mN1pQ3sT", nil)
+	req = mux.SetURLVars(req, map[string]string{"id": "aB3dE5gH7jK9mN1pQ3sT"})
+	req = req.WithContext(context.WithValue(req.Context(), "userID", ownerID))
+	rr = httptest.NewRecorder()
+	handler.GetByID(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Errorf("Expected status %d for owner, got %d", http.StatusOK, rr.Code)
+	}
+}
+
+func TestCreatePaste_ChecksumAndSignature(t *testing.T) {
+	handler, _ := setupTestHandler()
+	signature := "-----BEGIN PGP SIGNATURE-----\n\niQEzBAABCAAdFiEE\n-----END PGP SIGNATURE-----"
+
+	body, _ := json.Marshal(CreatePasteRequest{Content: "signed content", Signature: signature})
+	rr := httptest.NewRecorder()
+	handler.Create(rr, httptest.NewRequest("POST", "/api/paste", bytes.NewBuffer(body)))
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusCreated, rr.Code, rr.Body.String())
+	}
+
+	var created CreatePasteResponse
+	json.Unmarshal(rr.Body.Bytes(), &created)
+	if created.ContentSHA256 != models.ContentSHA256("signed content") {
+		t.Errorf("Expected the content digest on create, got %q", created.ContentSHA256)
+	}
+
+	req := mux.SetURLVars(httptest.NewRequest("GET", "/api/paste/"+created.ID, nil), map[string]string{"id": created.ID})
+	rr = httptest.NewRecorder()
+	handler.GetByID(rr, req)
+	var response PasteResponse
+	json.Unmarshal(rr.Body.Bytes(), &response)
+	if response.ContentSHA256 != created.ContentSHA256 || response.SignatureFormat != models.SignatureFormatPGP {
+		t.Errorf("Expected the digest and signature format on GET, got %+v", response)
+	}
+
+	req = mux.SetURLVars(httptest.NewRequest("GET", "/api/paste/"+created.ID+"/signature", nil), map[string]string{"id": created.ID})
+	rr = httptest.NewRecorder()
+	handler.GetSignature(rr, req)
+	if rr.Code != http.StatusOK || rr.Body.String() != signature+"\n" || rr.Header().Get("Content-Type") != "application/pgp-signature" {
+		t.Errorf("Expected the detached signature, got %d %q", rr.Code, rr.Body.String())
+	}
+
+	body, _ = json.Marshal(CreatePasteRequest{Content: "x", Signature: "trust me"})
+	rr = httptest.NewRecorder()
+	handler.Create(rr, httptest.NewRequest("POST", "/api/paste", bytes.NewBuffer(body)))
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected an unrecognized signature to be rejected, got %d", rr.Code)
+	}
+}
+
+func TestDeletePaste_PurgesCDN(t *testing.T) {
+	handler, mockRepo := setupTestHandler()
+	queue := &recordingQueue{}
+	handler.SetPurgeQueue(queue)
+
+	ownerID := 7
+	mockRepo.Create(&models.Paste{ID: "aB3dE5gH7jK9mN1pQ3sT", Content: "bye", Visibility: models.VisibilityPublic, UserID: &ownerID})
+
+	req := httptest.NewRequest("DELETE", "/api/paste/aB3dE5gH7jK9mN1pQ3sT", nil)
+	req = mux.SetURLVars(req, map[string]string{"id": "aB3dE5gH7jK9mN1pQ3sT"})
+	req = req.WithContext(context.WithValue(req.Context(), "userID", ownerID))
+	rr := httptest.NewRecorder()
+	handler.Delete(rr, req)
+	if rr.Code != http.StatusNoContent {
+		t.Fatalf("Expected status %d, got %d", http.StatusNoContent, rr.Code)
+	}
+
+	if len(queue.jobs) != 1 {
+		t.Fatalf("Expected one purge job, got %d", len(queue.jobs))
+	}
+	purge := queue.jobs[0].(services.CDNPurge)
+	if len(purge.Keys) != 3 || purge.Keys[0] != "paste-aB3dE5gH7jK9mN1pQ3sT" || purge.Keys[1] != "blob-"+models.ContentSHA256("bye") ||
+		purge.Keys[2] != models.ArchiveSurrogateKey(time.Now()) {
+		t.Errorf("Unexpected surrogate keys %v", purge.Keys)
+	}
+}
+
+// stubUserSettings is an in-memory UserSettingsStore
+type stubUserSettings map[int]*models.UserSettings
+
+func (s stubUserSettings) Get(userID int) (*models.UserSettings, error) {
+	if settings, ok := s[userID]; ok {
+		return settings, nil
+	}
+	return &models.UserSettings{UserID: userID}, nil
+}
+
+func (s stubUserSettings) Set(settings *models.UserSettings) error {
+	s[settings.UserID] = settings
+	return nil
+}
+
+func TestCreatePaste_UserDefaults(t *testing.T) {
+	handler, mockRepo := setupTestHandler()
+	userID := 3
+	handler.SetUserSettings(stubUserSettings{userID: {
+		UserID:            userID,
+		DefaultExpiry:     "2h",
+		DefaultVisibility: models.VisibilityUnlisted,
+		DefaultLanguage:   "go",
+	}})
+
+	create := func(reqBody CreatePasteRequest, authenticated bool) *models.Paste {
+		body, _ := json.Marshal(reqBody)
+		req := httptest.NewRequest("POST", "/api/paste", bytes.NewBuffer(body))
+		if authenticated {
+			req = req.WithContext(context.WithValue(req.Context(), "userID", userID))
+		}
+		rr := httptest.NewRecorder()
+		handler.Create(rr, req)
+		if rr.Code != http.StatusCreated {
+			t.Fatalf("Expected status %d, got %d: %s", http.StatusCreated, rr.Code, rr.Body.String())
+		}
+		var response CreatePasteResponse
+		json.Unmarshal(rr.Body.Bytes(), &response)
+		paste, _ := mockRepo.GetByID(response.ID)
+		return paste
+	}
+
+	paste := create(CreatePasteRequest{Content: "defaults"}, true)
+	if paste.Visibility != models.VisibilityUnlisted || paste.Language != "go" {
+		t.Errorf("Expected the saved defaults, got visibility %q language %q", paste.Visibility, paste.Language)
+	}
+	if paste.ExpiresAt == nil || time.Until(*paste.ExpiresAt) > 2*time.Hour || time.Until(*paste.ExpiresAt) < time.Hour {
+		t.Errorf("Expected the default 2h expiry, got %v", paste.ExpiresAt)
+	}
+
+	paste = create(CreatePasteRequest{Content: "explicit", Language: "rust", Visibility: models.VisibilityPublic, Expiry: "never"}, true)
+	if paste.Visibility != models.VisibilityPublic || paste.Language != "rust" || paste.ExpiresAt != nil {
+		t.Errorf("Expected explicit options to win, got %+v", paste)
+	}
+
+	paste = create(CreatePasteRequest{Content: "anonymous"}, false)
+	if paste.Visibility != models.VisibilityPublic || paste.Language != "" {
+		t.Errorf("Expected no defaults for anonymous pastes, got %+v", paste)
+	}
+}
+
+func TestCreatePaste_AnonymousExpiryPolicy(t *testing.T) {
+	validator := validation.NewValidator()
+	if _, err := NewAnonymousExpiryPolicy("never", false, validator); err == nil {
+		t.Error("Expected a never default to conflict with never not being allowed")
+	}
+	if _, err := NewAnonymousExpiryPolicy("soon", true, validator); err == nil {
+		t.Error("Expected an invalid default to be rejected")
+	}
+
+	create := func(handler *PasteHandler, reqBody CreatePasteRequest) (*httptest.ResponseRecorder, *models.Paste) {
+		body, _ := json.Marshal(reqBody)
+		rr := httptest.NewRecorder()
+		handler.Create(rr, httptest.NewRequest("POST", "/api/paste", bytes.NewBuffer(body)))
+		var response CreatePasteResponse
+		json.Unmarshal(rr.Body.Bytes(), &response)
+		paste, _ := handler.pasteRepo.GetByID(response.ID)
+		return rr, paste
+	}
+
+	handler, _ := setupTestHandler()
+	policy, err := NewAnonymousExpiryPolicy("7d", false, validator)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	handler.SetAnonymousExpiryPolicy(policy)
+	if _, paste := create(handler, CreatePasteRequest{Content: "default"}); paste == nil || paste.ExpiresAt == nil || time.Until(*paste.ExpiresAt) < 6*24*time.Hour {
+		t.Errorf("Expected the configured 7 day default, got %+v", paste)
+	}
+	if rr, _ := create(handler, CreatePasteRequest{Content: "forever", Expiry: "never"}); rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected never to be refused, got %d", rr.Code)
+	}
+
+	policy, _ = NewAnonymousExpiryPolicy("required", true, validator)
+	handler.SetAnonymousExpiryPolicy(policy)
+	if rr, _ := create(handler, CreatePasteRequest{Content: "omitted"}); rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected an omitted expiry to be refused, got %d", rr.Code)
+	}
+	if rr, paste := create(handler, CreatePasteRequest{Content: "chosen", Expiry: "never"}); rr.Code != http.StatusCreated || paste.ExpiresAt != nil {
+		t.Errorf("Expected a chosen expiry to be accepted, got %d", rr.Code)
+	}
+
+	rr := httptest.NewRecorder()
+	NewCapabilitiesHandler(Capabilities{AnonymousExpiry: policy}).Get(rr, httptest.NewRequest("GET", "/api/capabilities", nil))
+	var capabilities Capabilities
+	json.Unmarshal(rr.Body.Bytes(), &capabilities)
+	if !capabilities.AnonymousExpiry.Required || capabilities.MaxPasteSize != maxPasteSize {
+		t.Errorf("Expected the policy in the capabilities, got %+v", capabilities)
+	}
+}
+
+func TestCreatePaste_StrictExpiryPresets(t *testing.T) {
+	validator := validation.NewValidator()
+	if _, err := NewExpiryPresets([]string{"1h", "fortnight"}, true, validator); err == nil {
+		t.Error("Expected an invalid preset to be rejected")
+	}
+	if presets, _ := NewExpiryPresets(nil, false, validator); len(presets.Presets) != len(defaultExpiryPresets) {
+		t.Errorf("Expected the built-in presets, got %v", presets.Presets)
+	}
+
+	handler, _ := setupTestHandler()
+	presets, _ := NewExpiryPresets([]string{"1h", "1d"}, true, validator)
+	handler.SetExpiryPresets(presets)
+	userID := 5
+
+	create := func(reqBody CreatePasteRequest, authenticated bool) int {
+		body, _ := json.Marshal(reqBody)
+		req := httptest.NewRequest("POST", "/api/paste", bytes.NewBuffer(body))
+		if authenticated {
+			req = req.WithContext(context.WithValue(req.Context(), "userID", userID))
+		}
+		rr := httptest.NewRecorder()
+		handler.Create(rr, req)
+		return rr.Code
+	}
+
+	if code := create(CreatePasteRequest{Content: "preset", Expiry: "1d"}, false); code != http.StatusCreated {
+		t.Errorf("Expected a preset to be accepted, got %d", code)
+	}
+	if code := create(CreatePasteRequest{Content: "custom", Expiry: "2h"}, false); code != http.StatusBadRequest {
+		t.Errorf("Expected an expiry outside the presets to be refused, got %d", code)
+	}
+	// Omitting the expiry keeps an account's paste forever, which is not a preset
+	if code := create(CreatePasteRequest{Content: "forever"}, true); code != http.StatusBadRequest {
+		t.Errorf("Expected a never expiry to be refused, got %d", code)
+	}
+}
+
+func TestCreatePaste_ExpiresAt(t *testing.T) {
+	handler, repo := setupTestHandler()
+
+	create := func(reqBody CreatePasteRequest) *httptest.ResponseRecorder {
+		body, _ := json.Marshal(reqBody)
+		req := httptest.NewRequest("POST", "/api/paste", bytes.NewBuffer(body))
+		rr := httptest.NewRecorder()
+		handler.Create(rr, req)
+		return rr
+	}
+
+	at := time.Now().Add(48 * time.Hour).Truncate(time.Second)
+	rr := create(CreatePasteRequest{Content: "demo", ExpiresAt: at.In(time.FixedZone("CET", 3600)).Format(time.RFC3339)})
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusCreated, rr.Code, rr.Body.String())
+	}
+	var response CreatePasteResponse
+	json.NewDecoder(rr.Body).Decode(&response)
+	paste, _ := repo.GetByID(response.ID)
+	if paste == nil || paste.ExpiresAt == nil || !paste.ExpiresAt.Equal(at) {
+		t.Errorf("Expected the paste to expire at %v, got %+v", at, paste)
+	}
+
+	tests := []struct {
+		name string
+		req  CreatePasteRequest
+	}{
+		{"not RFC3339", CreatePasteRequest{Content: "x", ExpiresAt: "Friday 17:00"}},
+		{"in the past", CreatePasteRequest{Content: "x", ExpiresAt: time.Now().Add(-time.Hour).Format(time.RFC3339)}},
+		{"too far ahead", CreatePasteRequest{Content: "x", ExpiresAt: time.Now().AddDate(2, 0, 0).Format(time.RFC3339)}},
+		{"with expiry", CreatePasteRequest{Content: "x", Expiry: "1h", ExpiresAt: at.Format(time.RFC3339)}},
+	}
+	for _, tt := range tests {
+		if rr := create(tt.req); rr.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected status %d, got %d", tt.name, http.StatusBadRequest, rr.Code)
+		}
+	}
+}
+
+// stubContentPolicies is an in-memory ContentPolicies
+type stubContentPolicies map[string]*models.ContentPolicy
+
+func (s stubContentPolicies) Policy(role string) (*models.ContentPolicy, error) {
+	if policy, ok := s[role]; ok {
+		return policy, nil
+	}
+	return models.DefaultContentPolicy(role), nil
+}
+
+func TestCreatePaste_ContentPolicies(t *testing.T) {
+	handler, repo := setupTestHandler()
+	handler.SetContentPolicies(stubContentPolicies{
+		models.RoleAnonymous: {Role: models.RoleAnonymous, MaxSize: 10, AllowedExpiries: []string{"1h", "1d"}},
+		models.RoleUser:      {Role: models.RoleUser, AllowedExpiries: []string{}, AllowPublic: true},
+	})
+
+	create := func(reqBody CreatePasteRequest, role string) (*httptest.ResponseRecorder, *models.Paste) {
+		body, _ := json.Marshal(reqBody)
+		req := httptest.NewRequest("POST", "/api/paste", bytes.NewBuffer(body))
+		if role != "" {
+			ctx := context.WithValue(req.Context(), "userID", 7)
+			req = req.WithContext(context.WithValue(ctx, "role", role))
+		}
+		rr := httptest.NewRecorder()
+		handler.Create(rr, req)
+		var response CreatePasteResponse
+		json.Unmarshal(rr.Body.Bytes(), &response)
+		paste, _ := repo.GetByID(response.ID)
+		return rr, paste
+	}
+
+	// Anonymous pastes default to unlisted when public pastes are not allowed
+	rr, paste := create(CreatePasteRequest{Content: "small", Expiry: "1h"}, "")
+	if rr.Code != http.StatusCreated || paste.Visibility != models.VisibilityUnlisted {
+		t.Fatalf("Expected an unlisted paste, got %d %s", rr.Code, rr.Body.String())
+	}
+
+	tests := []struct {
+		name   string
+		req    CreatePasteRequest
+		status int
+		code   string
+	}{
+		{"too large", CreatePasteRequest{Content: "more than ten bytes", Expiry: "1h"}, http.StatusRequestEntityTooLarge, services.ViolationContentTooLarge},
+		{"expiry", CreatePasteRequest{Content: "small", Expiry: "2h"}, http.StatusForbidden, services.ViolationExpiry},
+		{"absolute expiry", CreatePasteRequest{Content: "small", ExpiresAt: time.Now().Add(time.Hour).Format(time.RFC3339)}, http.StatusForbidden, services.ViolationExpiry},
+		{"public", CreatePasteRequest{Content: "small", Expiry: "1h", Visibility: models.VisibilityPublic}, http.StatusForbidden, services.ViolationVisibility},
+	}
+	for _, tt := range tests {
+		rr, _ := create(tt.req, "")
+		var apiErr APIError
+		json.Unmarshal(rr.Body.Bytes(), &apiErr)
+		if rr.Code != tt.status || apiErr.Code != tt.code {
+			t.Errorf("%s: expected %d %s, got %d %s", tt.name, tt.status, tt.code, rr.Code, rr.Body.String())
+		}
+	}
+
+	// Other roles have their own policy
+	rr, paste = create(CreatePasteRequest{Content: "a larger paste with no expiry"}, models.RoleUser)
+	if rr.Code != http.StatusCreated || paste.Visibility != models.VisibilityPublic {
+		t.Errorf("Expected the user's paste to be accepted, got %d %s", rr.Code, rr.Body.String())
+	}
+}
+
+// stubCountryLookup maps IPs to countries for tests
+type stubCountryLookup map[string]string
+
+func (s stubCountryLookup) Country(ip net.IP) (string, error) {
+	return s[ip.String()], nil
+}
+
+func TestGetPaste_LocationRestricted(t *testing.T) {
+	handler, mockRepo := setupTestHandler()
+	handler.SetCountryLookup(stubCountryLookup{"203.0.113.7": "DE"})
+	mockRepo.Create(&models.Paste{ID: "net123", Content: "internal", AllowedNetworks: []string{"10.0.0.0/8"}})
+	mockRepo.Create(&models.Paste{ID: "geo123", Content: "internal", AllowedCountries: []string{"DE"}})
+
+	tests := []struct {
+		id       string
+		clientIP string
+		expected int
+	}{
+		{"net123", "10.1.2.3", http.StatusOK},
+		{"net123", "203.0.113.7", http.StatusForbidden},
+		{"geo123", "203.0.113.7", http.StatusOK},
+		{"geo123", "198.51.100.1", http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest("GET", "/api/paste/"+tt.id, nil)
+		req.RemoteAddr = tt.clientIP + ":4000"
+		req = mux.SetURLVars(req, map[string]string{"id": tt.id})
+		rr := httptest.NewRecorder()
+		handler.GetByID(rr, req)
+
+		if rr.Code != tt.expected {
+			t.Errorf("%s from %s: expected status %d, got %d", tt.id, tt.clientIP, tt.expected, rr.Code)
+		}
+		if rr.Code == http.StatusForbidden && !bytes.Contains(rr.Body.Bytes(), []byte("location_not_allowed")) {
+			t.Errorf("Expected the location_not_allowed code, got %s", rr.Body.String())
+		}
+		if cacheControl := rr.Header().Get("Cache-Control"); cacheControl != middleware.CachePrivate {
+			t.Errorf("Expected restricted paste %s not to be cached publicly, got %q", tt.id, cacheControl)
+		}
+	}
+}
+
+func TestGetPaste_RestrictionsShownToOwner(t *testing.T) {
+	handler, mockRepo := setupTestHandler()
+	ownerID := 7
+	mockRepo.Create(&models.Paste{ID: "net123", Content: "internal", UserID: &ownerID,
+		AllowedNetworks: []string{"192.0.2.0/24"}, AccessWindow: &models.AccessWindow{Start: 0, End: 1440}})
+
+	get := func(userID int) PasteResponse {
+		req := httptest.NewRequest("GET", "/api/paste/net123", nil)
+		req = mux.SetURLVars(req, map[string]string{"id": "net123"})
+		if userID != 0 {
+			req = req.WithContext(context.WithValue(req.Context(), "userID", userID))
+		}
+		rr := httptest.NewRecorder()
+		handler.GetByID(rr, req)
+		var response PasteResponse
+		json.Unmarshal(rr.Body.Bytes(), &response)
+		return response
+	}
+
+	if response := get(0); !response.Restricted || response.AccessWindow != "" || len(response.AllowedNetworks) > 0 {
+		t.Errorf("Expected other viewers to see only that the paste is restricted, got %+v", response)
+	}
+	if response := get(ownerID); response.AccessWindow == "" || len(response.AllowedNetworks) != 1 {
+		t.Errorf("Expected the owner to see the restrictions, got %+v", response)
+	}
+}
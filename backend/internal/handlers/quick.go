@@ -0,0 +1,157 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/LonleySailor/privatepaste/backend/internal/auth"
+	"github.com/LonleySailor/privatepaste/backend/internal/models"
+	"github.com/LonleySailor/privatepaste/backend/internal/services"
+	"github.com/LonleySailor/privatepaste/backend/pkg/utils"
+	"github.com/LonleySailor/privatepaste/backend/pkg/validation"
+)
+
+// maxQuickFormSize caps the form body, leaving room for the other fields
+// around a maximum size paste
+const maxQuickFormSize = maxPasteSize + 4096
+
+// APIKeyAuthenticator resolves an API key to its record
+type APIKeyAuthenticator interface {
+	Authenticate(key string) (*models.APIKey, error)
+}
+
+// authenticateAPIKey resolves an API key and checks that it may be used for
+// scope, if one is given. On failure it writes the error response and
+// returns false.
+func authenticateAPIKey(w http.ResponseWriter, keys APIKeyAuthenticator, key, scope string) (*models.APIKey, bool) {
+	apiKey, err := keys.Authenticate(key)
+	if err != nil {
+		WriteRepositoryError(w, err)
+		return nil, false
+	}
+	if apiKey == nil {
+		WriteError(w, &APIError{
+			Code:    "invalid_api_key",
+			Message: "Invalid or revoked API key",
+			Status:  http.StatusUnauthorized,
+		})
+		return nil, false
+	}
+	if scope != "" && !apiKey.Allows(scope) {
+		WriteError(w, &APIError{
+			Code:    "insufficient_scope",
+			Message: "This API key lacks the " + scope + " scope",
+			Status:  http.StatusForbidden,
+		})
+		return nil, false
+	}
+	return apiKey, true
+}
+
+// bearerAPIKey returns the API key sent as the request's bearer token
+func bearerAPIKey(r *http.Request) string {
+	return strings.TrimSpace(strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer "))
+}
+
+// QuickHandler handles quick paste creation from the browser extension
+type QuickHandler struct {
+	pasteRepo   PasteRepositoryInterface
+	keys        APIKeyAuthenticator
+	idGenerator *utils.IDGenerator
+	validator   *validation.Validator
+	creator     *PasteCreator
+}
+
+// NewQuickHandler creates a new quick paste handler
+func NewQuickHandler(pasteRepo PasteRepositoryInterface, keys APIKeyAuthenticator, idGenerator *utils.IDGenerator, validator *validation.Validator) *QuickHandler {
+	return &QuickHandler{
+		pasteRepo:   pasteRepo,
+		keys:        keys,
+		idGenerator: idGenerator,
+		validator:   validator,
+		creator:     NewPasteCreator(pasteRepo),
+	}
+}
+
+// SetPasteCreator replaces the default creator, which applies no content
+// policies, with one that enforces them
+func (h *QuickHandler) SetPasteCreator(creator *PasteCreator) {
+	h.creator = creator
+}
+
+// Create handles creating a paste from a form post with the fields key,
+// text, and optionally language, expiry and visibility (default unlisted).
+// Form bodies without custom headers are CORS simple requests, so the
+// browser extension can post without a preflight; the API key in the body
+// takes the place of the Authorization header.
+func (h *QuickHandler) Create(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		WriteError(w, &APIError{
+			Code:    "method_not_allowed",
+			Message: "Method not allowed",
+			Status:  http.StatusMethodNotAllowed,
+		})
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxQuickFormSize)
+	if err := r.ParseMultipartForm(maxQuickFormSize); err != nil && err != http.ErrNotMultipart {
+		WriteBodyError(w, err, ErrContentTooLarge)
+		return
+	}
+
+	apiKey, ok := authenticateAPIKey(w, h.keys, r.PostFormValue("key"), auth.ScopePasteCreate)
+	if !ok {
+		return
+	}
+
+	text := r.PostFormValue("text")
+	language := r.PostFormValue("language")
+	expiry := r.PostFormValue("expiry")
+	visibility := r.PostFormValue("visibility")
+
+	errors := h.validator.ValidateCreatePasteRequestFull(text, "", expiry, language)
+	if err := h.validator.ValidateVisibility(visibility); err != nil {
+		errors.Add(err.Field, err.Message)
+	}
+	if errors.HasErrors() {
+		WriteValidationError(w, errors)
+		return
+	}
+	if visibility == "" {
+		visibility = models.VisibilityUnlisted
+	}
+
+	id, err := generatePasteID(h.idGenerator, h.pasteRepo, visibility)
+	if err != nil {
+		WriteError(w, ErrIDGenerationFailed)
+		return
+	}
+
+	paste := &models.Paste{
+		ID:          id,
+		Content:     text,
+		Language:    language,
+		UserID:      &apiKey.UserID,
+		Kind:        models.KindText,
+		Visibility:  visibility,
+		LineNumbers: true,
+	}
+	if expiry != "" {
+		if duration, _ := h.validator.ValidateExpiryDuration(expiry); duration != nil {
+			expiresAt := time.Now().Add(*duration)
+			paste.ExpiresAt = &expiresAt
+		}
+	}
+
+	if err := h.creator.CreateOwnedFromRequest(r, paste, services.PasteAttributes{Expiry: expiry}); err != nil {
+		writePolicyViolation(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(newCreatePasteResponse(paste))
+}
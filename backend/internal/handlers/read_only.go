@@ -0,0 +1,75 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"github.com/LonleySailor/privatepaste/backend/internal/middleware"
+)
+
+// ReadOnlyHandler handles admin requests to pause and resume writes
+type ReadOnlyHandler struct {
+	mode *middleware.ReadOnlyMode
+}
+
+// NewReadOnlyHandler creates a new read-only mode handler
+func NewReadOnlyHandler(mode *middleware.ReadOnlyMode) *ReadOnlyHandler {
+	return &ReadOnlyHandler{mode: mode}
+}
+
+// ReadOnlyState is whether the instance's writes are paused
+type ReadOnlyState struct {
+	Enabled bool `json:"enabled"`
+}
+
+// Get handles returning whether read-only mode is on
+func (h *ReadOnlyHandler) Get(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		WriteError(w, &APIError{
+			Code:    "method_not_allowed",
+			Message: "Method not allowed",
+			Status:  http.StatusMethodNotAllowed,
+		})
+		return
+	}
+
+	h.writeState(w)
+}
+
+// Set handles switching read-only mode on or off
+func (h *ReadOnlyHandler) Set(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut {
+		WriteError(w, &APIError{
+			Code:    "method_not_allowed",
+			Message: "Method not allowed",
+			Status:  http.StatusMethodNotAllowed,
+		})
+		return
+	}
+
+	var req ReadOnlyState
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		WriteError(w, ErrInvalidJSON)
+		return
+	}
+
+	if req.Enabled != h.mode.Enabled() {
+		adminID, _ := middleware.GetUserIDFromContext(r.Context())
+		if req.Enabled {
+			log.Printf("Read-only mode enabled by user %d", adminID)
+		} else {
+			log.Printf("Read-only mode disabled by user %d", adminID)
+		}
+		h.mode.SetEnabled(req.Enabled)
+	}
+	h.writeState(w)
+}
+
+// writeState writes the current read-only state
+func (h *ReadOnlyHandler) writeState(w http.ResponseWriter) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(ReadOnlyState{Enabled: h.mode.Enabled()})
+}
@@ -0,0 +1,452 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/LonleySailor/privatepaste/backend/internal/middleware"
+	"github.com/LonleySailor/privatepaste/backend/internal/models"
+	"github.com/LonleySailor/privatepaste/backend/pkg/render"
+)
+
+// GetPDF handles exporting a paste as a printable, syntax-highlighted PDF
+func (h *PasteHandler) GetPDF(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		WriteError(w, &APIError{
+			Code:    "method_not_allowed",
+			Message: "Method not allowed",
+			Status:  http.StatusMethodNotAllowed,
+		})
+		return
+	}
+
+	paste, ok := h.loadViewablePaste(w, r)
+	if !ok {
+		return
+	}
+
+	title := "Paste " + paste.ID
+	if paste.Language != "" {
+		title += " (" + paste.Language + ")"
+	}
+
+	pdf := render.RenderPDF(paste.Content, paste.Language, render.PDFOptions{
+		Title:       title,
+		Theme:       render.ThemeByName(paste.Theme),
+		LineNumbers: paste.LineNumbers,
+	})
+
+	w.Header().Set("Content-Type", "application/pdf")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("inline; filename=%q", paste.ID+".pdf"))
+	w.Header().Set("Content-Length", strconv.Itoa(len(pdf)))
+	w.WriteHeader(http.StatusOK)
+	w.Write(pdf)
+}
+
+// GetImage handles rendering the first lines of a paste as a PNG snippet image
+func (h *PasteHandler) GetImage(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		WriteError(w, &APIError{
+			Code:    "method_not_allowed",
+			Message: "Method not allowed",
+			Status:  http.StatusMethodNotAllowed,
+		})
+		return
+	}
+
+	paste, ok := h.loadEmbeddablePaste(w, r)
+	if !ok {
+		return
+	}
+
+	// Parse number of lines to render
+	maxLines := render.DefaultImageRows
+	if linesStr := r.URL.Query().Get("lines"); linesStr != "" {
+		if l, err := strconv.Atoi(linesStr); err == nil && l > 0 && l <= render.MaxImageRows {
+			maxLines = l
+		}
+	}
+
+	var buf bytes.Buffer
+	err := render.RenderPNG(&buf, paste.Content, paste.Language, render.ImageOptions{
+		Theme:       render.ThemeByName(paste.Theme),
+		LineNumbers: paste.LineNumbers,
+		MaxLines:    maxLines,
+	})
+	if err != nil {
+		WriteError(w, ErrInternalServer)
+		return
+	}
+
+	w.Header().Set("Content-Type", "image/png")
+	w.Header().Set("Content-Length", strconv.Itoa(buf.Len()))
+	w.WriteHeader(http.StatusOK)
+	w.Write(buf.Bytes())
+}
+
+// GetDiffJSON handles returning the structured form of a diff paste
+func (h *PasteHandler) GetDiffJSON(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		WriteError(w, &APIError{
+			Code:    "method_not_allowed",
+			Message: "Method not allowed",
+			Status:  http.StatusMethodNotAllowed,
+		})
+		return
+	}
+
+	paste, ok := h.loadViewablePaste(w, r)
+	if !ok {
+		return
+	}
+
+	if !paste.IsDiff() {
+		WriteError(w, ErrUnsupportedPasteKind)
+		return
+	}
+
+	diff, err := render.ParseUnifiedDiff(paste.Content)
+	if err != nil {
+		WriteError(w, &APIError{
+			Code:    "invalid_diff",
+			Message: "Paste content is not a valid unified diff",
+			Status:  http.StatusUnprocessableEntity,
+		})
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(diff)
+}
+
+// GetTable handles returning a typed, tabular preview of a CSV or TSV paste
+func (h *PasteHandler) GetTable(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		WriteError(w, &APIError{
+			Code:    "method_not_allowed",
+			Message: "Method not allowed",
+			Status:  http.StatusMethodNotAllowed,
+		})
+		return
+	}
+
+	paste, ok := h.loadViewablePaste(w, r)
+	if !ok {
+		return
+	}
+
+	delimiter, tabular := render.TableDelimiter(paste.Language)
+	if !tabular {
+		WriteError(w, ErrUnsupportedPasteKind)
+		return
+	}
+
+	// Parse preview parameters
+	limit := 100
+	if limitStr := r.URL.Query().Get("limit"); limitStr != "" {
+		if l, err := strconv.Atoi(limitStr); err == nil && l > 0 && l <= 1000 {
+			limit = l
+		}
+	}
+	hasHeader := r.URL.Query().Get("header") != "false"
+
+	table, err := render.ParseTable(paste.Content, delimiter, hasHeader, limit)
+	if err != nil {
+		WriteError(w, &APIError{
+			Code:    "invalid_table",
+			Message: "Paste content could not be parsed as a table",
+			Status:  http.StatusUnprocessableEntity,
+		})
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(table)
+}
+
+// GetNotebook handles rendering a Jupyter notebook paste as sanitized HTML
+func (h *PasteHandler) GetNotebook(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		WriteError(w, &APIError{
+			Code:    "method_not_allowed",
+			Message: "Method not allowed",
+			Status:  http.StatusMethodNotAllowed,
+		})
+		return
+	}
+
+	paste, ok := h.loadEmbeddablePaste(w, r)
+	if !ok {
+		return
+	}
+
+	isNotebookLanguage := paste.Language == "ipynb" || paste.Language == "jupyter"
+	if !isNotebookLanguage && !render.IsNotebook(paste.Content) {
+		WriteError(w, ErrUnsupportedPasteKind)
+		return
+	}
+
+	theme := render.ThemeByName(paste.Theme)
+	body, err := render.RenderNotebook(paste.Content, theme)
+	if err != nil {
+		WriteError(w, &APIError{
+			Code:    "invalid_notebook",
+			Message: "Paste content is not a valid Jupyter notebook",
+			Status:  http.StatusUnprocessableEntity,
+		})
+		return
+	}
+
+	h.writeRenderedHTML(w, r, paste, theme, body)
+}
+
+// GetANSI handles rendering terminal output with ANSI color codes as HTML
+func (h *PasteHandler) GetANSI(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		WriteError(w, &APIError{
+			Code:    "method_not_allowed",
+			Message: "Method not allowed",
+			Status:  http.StatusMethodNotAllowed,
+		})
+		return
+	}
+
+	paste, ok := h.loadEmbeddablePaste(w, r)
+	if !ok {
+		return
+	}
+
+	theme := render.ThemeByName(paste.Theme)
+	body := render.ANSIToHTML(paste.Content, theme)
+
+	h.writeRenderedHTML(w, r, paste, theme, body)
+}
+
+// renderedHighlight and renderedMarkdown name the highlighted HTML and
+// rendered Markdown views in the render cache
+const (
+	renderedHighlight = "highlight"
+	renderedMarkdown  = "markdown"
+)
+
+// GetHTML handles rendering a paste as a syntax-highlighted HTML document.
+// Signed URL checks apply as for the raw content, since the rendered view
+// shows all of it.
+func (h *PasteHandler) GetHTML(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		WriteError(w, &APIError{
+			Code:    "method_not_allowed",
+			Message: "Method not allowed",
+			Status:  http.StatusMethodNotAllowed,
+		})
+		return
+	}
+
+	paste, ok := h.loadEmbeddablePaste(w, r)
+	if !ok || !h.checkSignedURL(w, r, paste) {
+		return
+	}
+
+	theme := render.ThemeByName(paste.Theme)
+	body := h.highlightedHTML(paste)
+
+	h.writeRenderedHTML(w, r, paste, theme, body)
+}
+
+// highlightedHTML returns the highlighted HTML body of a paste, from the
+// render cache when it has been rendered before
+func (h *PasteHandler) highlightedHTML(paste *models.Paste) string {
+	if h.renderCache != nil {
+		if body, ok := h.renderCache.Rendered(paste.ID, renderedHighlight); ok {
+			return body
+		}
+	}
+	body := render.HighlightHTML(render.Highlight(paste.Content, paste.Language), render.ThemeByName(paste.Theme), paste.LineNumbers)
+	if h.renderCache != nil {
+		h.renderCache.SetRendered(paste.ID, renderedHighlight, body)
+	}
+	return body
+}
+
+// GetMarkdown handles rendering a Markdown paste as a sanitized HTML
+// document. Password, expiry and signed URL checks apply as for the raw
+// content, since the rendered view shows all of it.
+func (h *PasteHandler) GetMarkdown(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		WriteError(w, &APIError{
+			Code:    "method_not_allowed",
+			Message: "Method not allowed",
+			Status:  http.StatusMethodNotAllowed,
+		})
+		return
+	}
+
+	paste, ok := h.loadEmbeddablePaste(w, r)
+	if !ok || !h.checkSignedURL(w, r, paste) {
+		return
+	}
+
+	if paste.Language != "markdown" {
+		WriteError(w, ErrUnsupportedPasteKind)
+		return
+	}
+
+	theme := render.ThemeByName(paste.Theme)
+	var body string
+	cached := false
+	if h.renderCache != nil {
+		body, cached = h.renderCache.Rendered(paste.ID, renderedMarkdown)
+	}
+	if !cached {
+		body = render.RenderMarkdown(paste.Content, theme)
+		if h.renderCache != nil {
+			h.renderCache.SetRendered(paste.ID, renderedMarkdown, body)
+		}
+	}
+
+	h.writeRenderedHTML(w, r, paste, theme, body)
+}
+
+// GetDiagram handles rendering a Mermaid, PlantUML or Graphviz paste as SVG
+func (h *PasteHandler) GetDiagram(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		WriteError(w, &APIError{
+			Code:    "method_not_allowed",
+			Message: "Method not allowed",
+			Status:  http.StatusMethodNotAllowed,
+		})
+		return
+	}
+
+	if h.diagramRenderer == nil {
+		WriteError(w, &APIError{
+			Code:    "diagram_rendering_disabled",
+			Message: "Diagram rendering is not enabled on this server",
+			Status:  http.StatusServiceUnavailable,
+		})
+		return
+	}
+
+	paste, ok := h.loadEmbeddablePaste(w, r)
+	if !ok {
+		return
+	}
+
+	diagramType, ok := render.DiagramType(paste.Language)
+	if !ok {
+		WriteError(w, ErrUnsupportedPasteKind)
+		return
+	}
+
+	svg, err := h.diagramRenderer.RenderSVG(r.Context(), diagramType, paste.Content)
+	if errors.Is(err, render.ErrInvalidDiagram) {
+		WriteError(w, &APIError{
+			Code:    "invalid_diagram",
+			Message: "Paste content could not be rendered as a " + diagramType + " diagram",
+			Status:  http.StatusUnprocessableEntity,
+		})
+		return
+	}
+	if err != nil {
+		WriteError(w, &APIError{
+			Code:    "diagram_renderer_unavailable",
+			Message: "Diagram renderer is unavailable",
+			Status:  http.StatusBadGateway,
+		})
+		return
+	}
+
+	w.Header().Set("Content-Type", "image/svg+xml")
+	setFramePolicy(w, paste, render.SVGContentSecurityPolicy)
+	w.WriteHeader(http.StatusOK)
+	w.Write(svg)
+}
+
+// writeRenderedHTML writes a rendered HTML document with a locked-down
+// content security policy, since it is derived from user content. Pastes
+// flagged for watermarking get the viewer's identity overlaid, so the
+// response is personal and must not be cached.
+func (h *PasteHandler) writeRenderedHTML(w http.ResponseWriter, r *http.Request, paste *models.Paste, theme render.Theme, body string) {
+	if paste.Watermark {
+		body += render.WatermarkOverlay(h.viewerIdentity(r)+" · "+time.Now().UTC().Format("2006-01-02 15:04")+" UTC", theme)
+		w.Header().Set("Cache-Control", "private, no-store")
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	setFramePolicy(w, paste, render.HTMLContentSecurityPolicy)
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte(render.HTMLDocument("Paste "+paste.ID, theme, body)))
+}
+
+// viewerIdentity names the viewer in watermarks: their username when signed
+// in, otherwise a prefix of their salted IP hash. Raw IPs are never shown.
+func (h *PasteHandler) viewerIdentity(r *http.Request) string {
+	if username, ok := middleware.GetUsernameFromContext(r.Context()); ok && username != "" {
+		return username
+	}
+	if hash := h.creatorIPHash(r); hash != nil {
+		return "viewer " + (*hash)[:min(len(*hash), 12)]
+	}
+	return "anonymous viewer"
+}
+
+// loadEmbeddablePaste is loadViewablePaste for the rendered views other sites
+// may embed, additionally refusing requests from pages on domains the paste
+// does not allow
+func (h *PasteHandler) loadEmbeddablePaste(w http.ResponseWriter, r *http.Request) (*models.Paste, bool) {
+	paste, ok := h.loadViewablePaste(w, r)
+	if !ok {
+		return nil, false
+	}
+	if !embedReferrerAllowed(r, paste) {
+		WriteError(w, ErrEmbedNotAllowed)
+		return nil, false
+	}
+	return paste, true
+}
+
+// embedReferrerAllowed reports whether a request for a rendered view came
+// from this site or a domain allowed to embed the paste. Requests without a
+// referrer, such as direct visits, are allowed; frame-ancestors keeps other
+// sites from framing the view regardless.
+func embedReferrerAllowed(r *http.Request, paste *models.Paste) bool {
+	if len(paste.EmbedDomains) == 0 || r.Referer() == "" {
+		return true
+	}
+	referrer, err := url.Parse(r.Referer())
+	if err != nil {
+		return false
+	}
+	self := r.Host
+	if hostname, _, err := net.SplitHostPort(r.Host); err == nil {
+		self = hostname
+	}
+	host := strings.ToLower(referrer.Hostname())
+	return host == strings.ToLower(self) || slices.Contains(paste.EmbedDomains, host)
+}
+
+// setFramePolicy sets the content security policy of a rendered view. For
+// pastes with embed domains it lets those domains frame the view, lifting
+// the X-Frame-Options: DENY set for every response.
+func setFramePolicy(w http.ResponseWriter, paste *models.Paste, policy string) {
+	if len(paste.EmbedDomains) > 0 {
+		ancestors := []string{"'self'"}
+		for _, domain := range paste.EmbedDomains {
+			ancestors = append(ancestors, "https://"+domain)
+		}
+		policy += "; frame-ancestors " + strings.Join(ancestors, " ")
+		w.Header().Del("X-Frame-Options")
+	}
+	w.Header().Set("Content-Security-Policy", policy)
+}
@@ -0,0 +1,298 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/LonleySailor/privatepaste/backend/internal/models"
+	"github.com/LonleySailor/privatepaste/backend/pkg/render"
+	"github.com/LonleySailor/privatepaste/backend/pkg/utils"
+	"github.com/gorilla/mux"
+)
+
+// createTestPaste creates a paste through the handler and returns its ID
+func createTestPaste(t *testing.T, handler *PasteHandler, reqBody CreatePasteRequest) string {
+	t.Helper()
+
+	body, _ := json.Marshal(reqBody)
+	req := httptest.NewRequest("POST", "/api/paste", bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+
+	rr := httptest.NewRecorder()
+	handler.Create(rr, req)
+
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("Expected status %d creating paste, got %d: %s", http.StatusCreated, rr.Code, rr.Body.String())
+	}
+
+	var response CreatePasteResponse
+	json.Unmarshal(rr.Body.Bytes(), &response)
+	return response.ID
+}
+
+// getPasteView issues a GET for a paste sub-resource handled by fn
+func getPasteView(fn http.HandlerFunc, id, query string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("GET", "/api/paste/"+id+query, nil)
+	req = mux.SetURLVars(req, map[string]string{"id": id})
+
+	rr := httptest.NewRecorder()
+	fn(rr, req)
+	return rr
+}
+
+func TestGetDiffJSON(t *testing.T) {
+	handler, _ := setupTestHandler()
+
+	id := createTestPaste(t, handler, CreatePasteRequest{
+		Content: "--- a.txt\n+++ a.txt\n@@ -1 +1 @@\n-old\n+new\n",
+		Kind:    "diff",
+	})
+
+	rr := getPasteView(handler.GetDiffJSON, id, "")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d", http.StatusOK, rr.Code)
+	}
+
+	var diff render.Diff
+	if err := json.Unmarshal(rr.Body.Bytes(), &diff); err != nil {
+		t.Fatalf("Failed to parse response: %v", err)
+	}
+
+	if diff.Stats.FilesChanged != 1 || diff.Stats.Additions != 1 || diff.Stats.Deletions != 1 {
+		t.Errorf("Unexpected diff stats: %+v", diff.Stats)
+	}
+}
+
+func TestGetDiffJSON_NotADiff(t *testing.T) {
+	handler, _ := setupTestHandler()
+
+	id := createTestPaste(t, handler, CreatePasteRequest{Content: "plain text"})
+
+	rr := getPasteView(handler.GetDiffJSON, id, "")
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected status %d, got %d", http.StatusBadRequest, rr.Code)
+	}
+}
+
+func TestCreatePaste_InvalidDiff(t *testing.T) {
+	handler, _ := setupTestHandler()
+
+	body, _ := json.Marshal(CreatePasteRequest{Content: "not a diff", Kind: "diff"})
+	req := httptest.NewRequest("POST", "/api/paste", bytes.NewBuffer(body))
+
+	rr := httptest.NewRecorder()
+	handler.Create(rr, req)
+
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected status %d, got %d", http.StatusBadRequest, rr.Code)
+	}
+}
+
+func TestGetTable(t *testing.T) {
+	handler, _ := setupTestHandler()
+
+	id := createTestPaste(t, handler, CreatePasteRequest{
+		Content:  "id,name\n1,a\n2,b\n3,c\n",
+		Language: "csv",
+	})
+
+	rr := getPasteView(handler.GetTable, id, "?limit=2")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d", http.StatusOK, rr.Code)
+	}
+
+	var table render.Table
+	if err := json.Unmarshal(rr.Body.Bytes(), &table); err != nil {
+		t.Fatalf("Failed to parse response: %v", err)
+	}
+
+	if len(table.Rows) != 2 || table.TotalRows != 3 || table.Columns[0].Type != render.ColumnInteger {
+		t.Errorf("Unexpected table preview: %+v", table)
+	}
+}
+
+func TestGetANSI(t *testing.T) {
+	handler, _ := setupTestHandler()
+
+	id := createTestPaste(t, handler, CreatePasteRequest{
+		Content: "\x1b[32mPASS\x1b[0m <done>\n",
+	})
+
+	rr := getPasteView(handler.GetANSI, id, "")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d", http.StatusOK, rr.Code)
+	}
+
+	body := rr.Body.String()
+	if !strings.Contains(body, `<span style="color:#0dbc79">PASS</span>`) || !strings.Contains(body, "&lt;done&gt;") {
+		t.Errorf("Unexpected ANSI rendering: %s", body)
+	}
+	if rr.Header().Get("Content-Security-Policy") == "" {
+		t.Error("Expected a content security policy header")
+	}
+}
+
+func TestGetMarkdown(t *testing.T) {
+	handler, mockRepo := setupTestHandler()
+
+	id := createTestPaste(t, handler, CreatePasteRequest{
+		Content:  "# Notes\n\n**bold** [link](javascript:alert(1)) <script>alert(1)</script>\n",
+		Language: "markdown",
+	})
+
+	rr := getPasteView(handler.GetMarkdown, id, "")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+	body := rr.Body.String()
+	if !strings.Contains(body, "<h1>Notes</h1>") || !strings.Contains(body, "<strong>bold</strong>") {
+		t.Errorf("Unexpected Markdown rendering: %s", body)
+	}
+	if strings.Contains(body, "<script>") || strings.Contains(body, "javascript:") {
+		t.Errorf("Expected sanitized HTML, got %s", body)
+	}
+	if rr.Header().Get("Content-Security-Policy") == "" {
+		t.Error("Expected a content security policy header")
+	}
+
+	// Only Markdown pastes render
+	plain := createTestPaste(t, handler, CreatePasteRequest{Content: "# not markdown", Language: "text"})
+	if rr := getPasteView(handler.GetMarkdown, plain, ""); rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected status %d for a text paste, got %d", http.StatusBadRequest, rr.Code)
+	}
+
+	// Password and expiry apply as for the raw content
+	protected := createTestPaste(t, handler, CreatePasteRequest{Content: "# secret", Language: "markdown", Password: "SecretPassword123!"})
+	if rr := getPasteView(handler.GetMarkdown, protected, ""); rr.Code != ErrPasswordRequired.Status {
+		t.Errorf("Expected status %d without the password, got %d", ErrPasswordRequired.Status, rr.Code)
+	}
+	if rr := getPasteView(handler.GetMarkdown, protected, "?password=wrong"); rr.Code != ErrInvalidPassword.Status {
+		t.Errorf("Expected status %d with a wrong password, got %d", ErrInvalidPassword.Status, rr.Code)
+	}
+	if rr := getPasteView(handler.GetMarkdown, protected, "?password=SecretPassword123!"); rr.Code != http.StatusOK {
+		t.Errorf("Expected status %d with the password, got %d", http.StatusOK, rr.Code)
+	}
+
+	expired := time.Now().Add(-time.Hour)
+	mockRepo.Create(&models.Paste{ID: "mdexp1", Content: "# gone", Language: "markdown", ExpiresAt: &expired})
+	if rr := getPasteView(handler.GetMarkdown, "mdexp1", ""); rr.Code != http.StatusGone {
+		t.Errorf("Expected status %d for an expired paste, got %d", http.StatusGone, rr.Code)
+	}
+}
+
+// mapRenderCache is a RenderCache signalling each view it stores
+type mapRenderCache struct {
+	mu     sync.Mutex
+	bodies map[string]string
+	stored chan string
+}
+
+func (c *mapRenderCache) Rendered(pasteID, view string) (string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	body, ok := c.bodies[pasteID+"/"+view]
+	return body, ok
+}
+
+func (c *mapRenderCache) SetRendered(pasteID, view, body string) {
+	c.mu.Lock()
+	c.bodies[pasteID+"/"+view] = body
+	c.mu.Unlock()
+	c.stored <- pasteID
+}
+
+func TestGetHTML_Prerendered(t *testing.T) {
+	handler, _ := setupTestHandler()
+	cache := &mapRenderCache{bodies: map[string]string{}, stored: make(chan string, 1)}
+	handler.SetRenderCache(cache, true)
+
+	id := createTestPaste(t, handler, CreatePasteRequest{Content: "func main() {}", Language: "go"})
+	select {
+	case stored := <-cache.stored:
+		if stored != id {
+			t.Fatalf("Expected paste %s to be prerendered, got %s", id, stored)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Expected the new paste to be prerendered")
+	}
+
+	// The cached rendering is served as is
+	cache.bodies[id+"/"+renderedHighlight] = "<pre>prerendered</pre>"
+	rr := getPasteView(handler.GetHTML, id, "")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d", http.StatusOK, rr.Code)
+	}
+	if !strings.Contains(rr.Body.String(), "<pre>prerendered</pre>") {
+		t.Errorf("Expected the prerendered body, got %s", rr.Body.String())
+	}
+}
+
+func TestGetANSI_EmbedDomains(t *testing.T) {
+	handler, _ := setupTestHandler()
+
+	id := createTestPaste(t, handler, CreatePasteRequest{
+		Content:      "\x1b[32mPASS\x1b[0m\n",
+		EmbedDomains: []string{"Docs.Example.com"},
+	})
+
+	tests := []struct {
+		referrer string
+		expected int
+	}{
+		{"", http.StatusOK},
+		{"https://docs.example.com/guide", http.StatusOK},
+		{"https://example.com/", http.StatusOK}, // The instance itself
+		{"https://evil.example.net/", http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest("GET", "/api/paste/"+id+"/ansi", nil)
+		req.Host = "example.com"
+		req.Header.Set("Referer", tt.referrer)
+		req = mux.SetURLVars(req, map[string]string{"id": id})
+		rr := httptest.NewRecorder()
+		handler.GetANSI(rr, req)
+
+		if rr.Code != tt.expected {
+			t.Errorf("Referrer %q: expected status %d, got %d", tt.referrer, tt.expected, rr.Code)
+		}
+		if rr.Code == http.StatusOK && !strings.HasSuffix(rr.Header().Get("Content-Security-Policy"), "frame-ancestors 'self' https://docs.example.com") {
+			t.Errorf("Expected the embed domain in frame-ancestors, got %q", rr.Header().Get("Content-Security-Policy"))
+		}
+	}
+}
+
+func TestGetANSI_Watermark(t *testing.T) {
+	handler, _ := setupTestHandler()
+	handler.SetIPHasher(utils.NewIPHasher("test-secret", 0))
+
+	id := createTestPaste(t, handler, CreatePasteRequest{Content: "secret\n", Watermark: true})
+
+	rr := getPasteView(handler.GetANSI, id, "")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d", http.StatusOK, rr.Code)
+	}
+	if rr.Header().Get("Cache-Control") != "private, no-store" {
+		t.Errorf("Expected a watermarked view not to be cached, got %q", rr.Header().Get("Cache-Control"))
+	}
+
+	body := rr.Body.String()
+	start := strings.Index(body, "data:image/svg+xml;base64,")
+	if start < 0 {
+		t.Fatalf("Expected a watermark overlay, got %s", body)
+	}
+	encoded := body[start+len("data:image/svg+xml;base64,"):]
+	svg, err := base64.StdEncoding.DecodeString(encoded[:strings.Index(encoded, "'")])
+	if err != nil {
+		t.Fatalf("Failed to decode watermark: %v", err)
+	}
+	if !strings.Contains(string(svg), "viewer ") || strings.Contains(string(svg), "192.0.2.1") {
+		t.Errorf("Expected the viewer's IP hash but not their IP, got %s", svg)
+	}
+}
@@ -12,4 +12,16 @@ type PasteRepositoryInterface interface {
 	Update(paste *models.Paste) error
 	DeleteExpired() (int64, error)
 	CountByUserID(userID int) (int, error)
+	RecordFailedUnlock(id string) (bool, error)
 }
+
+// JobEnqueuer queues background jobs such as webhook deliveries and email
+type JobEnqueuer interface {
+	Enqueue(jobType string, payload interface{}) error
+}
+
+// Both the SQL and in-memory repositories satisfy the interface
+var (
+	_ PasteRepositoryInterface = (*models.PasteRepository)(nil)
+	_ PasteRepositoryInterface = (*models.MemoryPasteRepository)(nil)
+)
@@ -0,0 +1,90 @@
+package handlers
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+
+	"github.com/LonleySailor/privatepaste/backend/pkg/utils"
+)
+
+// Response signing headers. The signature covers the paste's ID and the hash
+// of the response body, so a copy of a raw paste can be verified wherever it
+// was fetched from, and not passed off as another paste.
+const (
+	ContentSignatureHeader      = "X-Content-Signature"
+	ContentSignatureKeyIDHeader = "X-Content-Signature-Key-Id"
+)
+
+// SigningKeyPath is the well-known location of the response signing key
+const SigningKeyPath = "/.well-known/pastevault-signing-key"
+
+// SetResponseSigner enables signing raw and download responses
+func (h *PasteHandler) SetResponseSigner(signer *utils.ResponseSigner) {
+	h.responseSigner = signer
+}
+
+// ContentSigningFormat describes ContentSigningPayload for verifiers
+const ContentSigningFormat = "pastevault-content-v1\n<paste id>\n<hex sha-256 of body>\n"
+
+// ContentSigningPayload returns what the signature of a paste's raw content
+// is computed over: a version line, the paste ID and the hex SHA-256 of the
+// content, each ending in a newline
+func ContentSigningPayload(pasteID, content string) []byte {
+	sum := sha256.Sum256([]byte(content))
+	return []byte("pastevault-content-v1\n" + pasteID + "\n" + hex.EncodeToString(sum[:]) + "\n")
+}
+
+// signContent adds the detached signature of a paste's content to the
+// response headers; a nil signer adds nothing
+func signContent(w http.ResponseWriter, signer *utils.ResponseSigner, pasteID, content string) {
+	if signer == nil {
+		return
+	}
+	w.Header().Set(ContentSignatureHeader, signer.Sign(ContentSigningPayload(pasteID, content)))
+	w.Header().Set(ContentSignatureKeyIDHeader, signer.KeyID())
+}
+
+// SigningKeyResponse publishes the key raw content signatures verify against
+type SigningKeyResponse struct {
+	Algorithm string `json:"algorithm"`
+	KeyID     string `json:"key_id"`
+	PublicKey string `json:"public_key"` // Base64 raw Ed25519 public key
+	Header    string `json:"header"`     // Response header carrying the base64 signature
+	Payload   string `json:"payload"`    // What the signature is computed over
+}
+
+// SigningKeyHandler serves the response signing public key
+type SigningKeyHandler struct {
+	signer *utils.ResponseSigner
+}
+
+// NewSigningKeyHandler creates a new signing key handler
+func NewSigningKeyHandler(signer *utils.ResponseSigner) *SigningKeyHandler {
+	return &SigningKeyHandler{signer: signer}
+}
+
+// GetKey handles publishing the public key at SigningKeyPath
+func (h *SigningKeyHandler) GetKey(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		WriteError(w, &APIError{
+			Code:    "method_not_allowed",
+			Message: "Method not allowed",
+			Status:  http.StatusMethodNotAllowed,
+		})
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "public, max-age=3600")
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(SigningKeyResponse{
+		Algorithm: "ed25519",
+		KeyID:     h.signer.KeyID(),
+		PublicKey: h.signer.PublicKey(),
+		Header:    ContentSignatureHeader,
+		Payload:   ContentSigningFormat,
+	})
+}
@@ -0,0 +1,44 @@
+package handlers
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/json"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/LonleySailor/privatepaste/backend/internal/models"
+	"github.com/LonleySailor/privatepaste/backend/pkg/utils"
+	"github.com/gorilla/mux"
+)
+
+func TestResponseSigning(t *testing.T) {
+	signer, err := utils.LoadResponseSigner(filepath.Join(t.TempDir(), "signing.pem"))
+	if err != nil {
+		t.Fatalf("Failed to create signing key: %v", err)
+	}
+	handler, mockRepo := setupTestHandler()
+	handler.SetResponseSigner(signer)
+	mockRepo.Create(&models.Paste{ID: "sig001", Content: "mirrored content"})
+
+	rr := httptest.NewRecorder()
+	handler.GetRaw(rr, mux.SetURLVars(httptest.NewRequest("GET", "/api/paste/sig001/raw", nil), map[string]string{"id": "sig001"}))
+
+	rr2 := httptest.NewRecorder()
+	NewSigningKeyHandler(signer).GetKey(rr2, httptest.NewRequest("GET", SigningKeyPath, nil))
+	var key SigningKeyResponse
+	json.Unmarshal(rr2.Body.Bytes(), &key)
+
+	if rr.Header().Get(ContentSignatureKeyIDHeader) != key.KeyID {
+		t.Errorf("Expected the published key ID, got %q", rr.Header().Get(ContentSignatureKeyIDHeader))
+	}
+	publicKey, _ := base64.StdEncoding.DecodeString(key.PublicKey)
+	signature, _ := base64.StdEncoding.DecodeString(rr.Header().Get(ContentSignatureHeader))
+	if !ed25519.Verify(publicKey, ContentSigningPayload("sig001", rr.Body.String()), signature) {
+		t.Error("Expected the paste ID and response body to verify against the published key")
+	}
+	if ed25519.Verify(publicKey, ContentSigningPayload("sig002", rr.Body.String()), signature) {
+		t.Error("Expected the signature not to verify for another paste")
+	}
+}
@@ -0,0 +1,106 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/LonleySailor/privatepaste/backend/pkg/sandbox"
+	"github.com/LonleySailor/privatepaste/backend/pkg/validation"
+)
+
+// RunPasteRequest represents a request to execute a paste in the sandbox
+type RunPasteRequest struct {
+	Stdin string `json:"stdin,omitempty"`
+}
+
+// RunPasteResponse represents the result of executing a paste
+type RunPasteResponse struct {
+	Language string              `json:"language"`
+	Version  string              `json:"version"`
+	ExitCode int                 `json:"exit_code"`
+	Output   CreatePasteResponse `json:"output"` // Linked paste holding the execution output
+}
+
+// SetSandbox enables snippet execution through the given sandbox client
+func (h *PasteHandler) SetSandbox(client *sandbox.Client) {
+	h.sandbox = client
+}
+
+// Run handles executing a paste in the external sandbox and saving the output
+// as a new paste linked to the source
+func (h *PasteHandler) Run(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		WriteError(w, &APIError{
+			Code:    "method_not_allowed",
+			Message: "Method not allowed",
+			Status:  http.StatusMethodNotAllowed,
+		})
+		return
+	}
+
+	if h.sandbox == nil {
+		WriteError(w, &APIError{
+			Code:    "sandbox_disabled",
+			Message: "Snippet execution is not enabled on this server",
+			Status:  http.StatusServiceUnavailable,
+		})
+		return
+	}
+
+	source, ok := h.loadViewablePaste(w, r)
+	if !ok || !checkDerivation(w, r, source) {
+		return
+	}
+
+	// The body is optional; an empty body runs without stdin
+	var req RunPasteRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && err != io.EOF {
+		WriteError(w, ErrInvalidJSON)
+		return
+	}
+	if len(req.Stdin) > sandbox.MaxStdinBytes {
+		WriteValidationError(w, []validation.ValidationError{{Field: "stdin", Message: "must be at most 64 KiB"}})
+		return
+	}
+
+	result, err := h.sandbox.Execute(r.Context(), source.Language, source.Content, req.Stdin)
+	if errors.Is(err, sandbox.ErrUnsupportedLanguage) {
+		WriteError(w, &APIError{
+			Code:    "unsupported_language",
+			Message: "Paste language is not supported by the sandbox",
+			Status:  http.StatusUnprocessableEntity,
+		})
+		return
+	}
+	if err != nil {
+		WriteError(w, &APIError{
+			Code:    "sandbox_unavailable",
+			Message: "Sandbox runner is unavailable",
+			Status:  http.StatusBadGateway,
+		})
+		return
+	}
+
+	output := result.Output()
+	if output == "" {
+		output = "(no output)\n"
+	}
+
+	paste, ok := h.createDerivedPaste(w, r, source, output, "text")
+	if !ok {
+		return
+	}
+
+	response := RunPasteResponse{
+		Language: result.Language,
+		Version:  result.Version,
+		ExitCode: result.ExitCode(),
+		Output:   newCreatePasteResponse(paste),
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(response)
+}
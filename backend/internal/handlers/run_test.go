@@ -0,0 +1,35 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/LonleySailor/privatepaste/backend/internal/models"
+	"github.com/LonleySailor/privatepaste/backend/pkg/sandbox"
+	"github.com/gorilla/mux"
+)
+
+func TestRunPaste_StdinTooLarge(t *testing.T) {
+	runner := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("Expected oversized stdin to be refused before reaching the sandbox")
+	}))
+	defer runner.Close()
+
+	handler, repo := setupTestHandler()
+	handler.SetSandbox(sandbox.NewClient(runner.URL))
+	repo.Create(&models.Paste{ID: "run123", Content: "print(input())", Language: "python"})
+
+	body, _ := json.Marshal(RunPasteRequest{Stdin: strings.Repeat("x", sandbox.MaxStdinBytes+1)})
+	req := httptest.NewRequest("POST", "/api/paste/run123/run", bytes.NewReader(body))
+	req = mux.SetURLVars(req, map[string]string{"id": "run123"})
+	rr := httptest.NewRecorder()
+	handler.Run(rr, req)
+
+	if rr.Code != http.StatusBadRequest || !strings.Contains(rr.Body.String(), "stdin") {
+		t.Errorf("Expected a validation error for stdin, got %d: %s", rr.Code, rr.Body.String())
+	}
+}
@@ -0,0 +1,242 @@
+package handlers
+
+import (
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/LonleySailor/privatepaste/backend/internal/database"
+	"github.com/LonleySailor/privatepaste/backend/internal/middleware"
+	"github.com/LonleySailor/privatepaste/backend/internal/models"
+	"github.com/LonleySailor/privatepaste/backend/pkg/s3sig"
+	"github.com/LonleySailor/privatepaste/backend/pkg/validation"
+	"github.com/gorilla/mux"
+)
+
+// s3Region is the region S3 clients should sign for
+const s3Region = "us-east-1"
+
+// UserLookup finds accounts by username
+type UserLookup interface {
+	GetByUsername(username string) (*models.User, error)
+}
+
+// S3Handler serves raw pastes over an S3-compatible GET interface, where
+// the bucket is the owner's username and the object key the paste ID
+type S3Handler struct {
+	users     UserLookup
+	pasteRepo PasteRepositoryInterface
+	validator *validation.Validator
+	secret    []byte
+
+	// Resolves requesters' countries for country-restricted pastes; nil
+	// admits only their allowed networks
+	countryLookup middleware.CountryLookup
+}
+
+// NewS3Handler creates a new S3 gateway handler. Each user's secret access
+// key is derived from secret.
+func NewS3Handler(users UserLookup, pasteRepo PasteRepositoryInterface, validator *validation.Validator, secret string) *S3Handler {
+	return &S3Handler{
+		users:     users,
+		pasteRepo: pasteRepo,
+		validator: validator,
+		secret:    []byte(secret),
+	}
+}
+
+// SetCountryLookup enables serving country-restricted pastes to requesters
+// in their allowed countries
+func (h *S3Handler) SetCountryLookup(lookup middleware.CountryLookup) {
+	h.countryLookup = lookup
+}
+
+// S3CredentialsResponse holds the settings for S3 tools to presign requests
+type S3CredentialsResponse struct {
+	AccessKeyID     string `json:"access_key_id"`
+	SecretAccessKey string `json:"secret_access_key"`
+	Region          string `json:"region"`
+	Endpoint        string `json:"endpoint"`
+	Bucket          string `json:"bucket"`
+}
+
+// s3Error is the XML error body S3 clients expect
+type s3Error struct {
+	XMLName  xml.Name `xml:"Error"`
+	Code     string   `xml:"Code"`
+	Message  string   `xml:"Message"`
+	Resource string   `xml:"Resource"`
+}
+
+// writeS3Error writes an S3-style XML error response
+func writeS3Error(w http.ResponseWriter, r *http.Request, status int, code, message string) {
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(status)
+	if r.Method == http.MethodHead {
+		return
+	}
+	w.Write([]byte(xml.Header))
+	xml.NewEncoder(w).Encode(s3Error{Code: code, Message: message, Resource: r.URL.Path})
+}
+
+// writeS3RepositoryError writes the S3 error for a failed repository call,
+// mirroring WriteRepositoryError
+func writeS3RepositoryError(w http.ResponseWriter, r *http.Request, err error) {
+	if errors.Is(err, database.ErrCircuitOpen) {
+		writeS3Error(w, r, http.StatusServiceUnavailable, "ServiceUnavailable", "Please try again later.")
+		return
+	}
+	writeS3Error(w, r, http.StatusInternalServerError, "InternalError", "We encountered an internal error. Please try again.")
+}
+
+// secretKey returns the S3 secret access key of a user
+func (h *S3Handler) secretKey(userID int) string {
+	mac := hmac.New(sha256.New, h.secret)
+	mac.Write([]byte("s3:" + strconv.Itoa(userID)))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// Credentials handles returning the authenticated user's S3 credentials.
+// The access key ID is the username, which is also the bucket name.
+func (h *S3Handler) Credentials(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		WriteError(w, &APIError{
+			Code:    "method_not_allowed",
+			Message: "Method not allowed",
+			Status:  http.StatusMethodNotAllowed,
+		})
+		return
+	}
+
+	userID, ok := middleware.GetUserIDFromContext(r.Context())
+	username, hasUsername := middleware.GetUsernameFromContext(r.Context())
+	if !ok || !hasUsername {
+		WriteError(w, &APIError{
+			Code:    "unauthorized",
+			Message: "User ID not found in token",
+			Status:  http.StatusUnauthorized,
+		})
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(S3CredentialsResponse{
+		AccessKeyID:     username,
+		SecretAccessKey: h.secretKey(userID),
+		Region:          s3Region,
+		Endpoint:        siteBaseURL + "/s3",
+		Bucket:          username,
+	})
+}
+
+// GetObject handles GET and HEAD of /s3/{bucket}/{key}. Public and unlisted
+// pastes can be fetched anonymously, like objects in a public bucket. Private
+// pastes and pastes requiring signed URLs need a URL presigned with the
+// bucket owner's credentials. Password-protected pastes are never served,
+// and pastes limited to an access window or to networks or countries are
+// only served anonymously within them.
+func (h *S3Handler) GetObject(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		writeS3Error(w, r, http.StatusMethodNotAllowed, "MethodNotAllowed", "Only GET and HEAD are supported")
+		return
+	}
+
+	vars := mux.Vars(r)
+	if err := h.validator.ValidateID(vars["key"]); err != nil {
+		writeS3Error(w, r, http.StatusNotFound, "NoSuchKey", "The specified key does not exist.")
+		return
+	}
+
+	owner, err := h.users.GetByUsername(vars["bucket"])
+	if err != nil {
+		writeS3RepositoryError(w, r, err)
+		return
+	}
+	if owner == nil {
+		writeS3Error(w, r, http.StatusNotFound, "NoSuchBucket", "The specified bucket does not exist")
+		return
+	}
+	// Deactivated accounts' buckets are closed, even to presigned URLs
+	if !owner.IsActive() {
+		writeS3Error(w, r, http.StatusForbidden, "AccessDenied", "Access Denied")
+		return
+	}
+
+	signed := false
+	presigned, err := s3sig.Parse(r)
+	switch {
+	case err == s3sig.ErrMissing:
+	case err != nil:
+		writeS3Error(w, r, http.StatusBadRequest, "AuthorizationQueryParametersError", err.Error())
+		return
+	case presigned.AccessKey != owner.Username:
+		writeS3Error(w, r, http.StatusForbidden, "AccessDenied", "Access Denied")
+		return
+	default:
+		if err := presigned.Verify(r, h.secretKey(owner.ID), time.Now()); err == s3sig.ErrExpired {
+			writeS3Error(w, r, http.StatusForbidden, "AccessDenied", "Request has expired")
+			return
+		} else if err != nil {
+			writeS3Error(w, r, http.StatusForbidden, "SignatureDoesNotMatch", "The request signature we calculated does not match the signature you provided.")
+			return
+		}
+		signed = true
+	}
+
+	paste, err := h.pasteRepo.GetByID(vars["key"])
+	if err != nil {
+		writeS3RepositoryError(w, r, err)
+		return
+	}
+	if paste == nil || !paste.IsOwnedBy(owner.ID) || paste.IsExpired() || (paste.IsPrivate() && !signed) {
+		writeS3Error(w, r, http.StatusNotFound, "NoSuchKey", "The specified key does not exist.")
+		return
+	}
+	if paste.HasPassword() || (paste.RequireSignedURLs && !signed) {
+		writeS3Error(w, r, http.StatusForbidden, "AccessDenied", "Access Denied")
+		return
+	}
+	// A presigned request comes from the owner, who is not held to the
+	// paste's restrictions
+	if !signed && !s3Allowed(r, paste, h.countryLookup) {
+		writeS3Error(w, r, http.StatusForbidden, "AccessDenied", "Access Denied")
+		return
+	}
+	if paste.DoNotTrack {
+		middleware.SuppressAccessLog(r)
+	}
+	setRobotsHeader(w, paste)
+
+	sum := md5.Sum([]byte(paste.Content))
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Header().Set("Content-Length", strconv.Itoa(len(paste.Content)))
+	w.Header().Set("ETag", `"`+hex.EncodeToString(sum[:])+`"`)
+	w.Header().Set("Last-Modified", paste.CreatedAt.UTC().Format(http.TimeFormat))
+	if paste.IsRestricted() {
+		w.Header().Set("Cache-Control", middleware.CachePrivate)
+	}
+	w.WriteHeader(http.StatusOK)
+	if r.Method == http.MethodGet {
+		w.Write([]byte(paste.Content))
+	}
+}
+
+// s3Allowed reports whether an anonymous request falls within a paste's
+// access window and allowed locations
+func s3Allowed(r *http.Request, paste *models.Paste, lookup middleware.CountryLookup) bool {
+	if paste.AccessWindow != nil && !paste.AccessWindow.Contains(time.Now()) {
+		return false
+	}
+	return !paste.IsLocationRestricted() || locationAllowed(net.ParseIP(middleware.ClientIP(r)), paste, lookup)
+}
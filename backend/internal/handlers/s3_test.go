@@ -0,0 +1,92 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/LonleySailor/privatepaste/backend/internal/models"
+	"github.com/LonleySailor/privatepaste/backend/pkg/s3sig"
+	"github.com/LonleySailor/privatepaste/backend/pkg/validation"
+	"github.com/gorilla/mux"
+)
+
+type stubUsers map[string]*models.User
+
+func (s stubUsers) GetByUsername(username string) (*models.User, error) {
+	return s[username], nil
+}
+
+func TestS3GetObject(t *testing.T) {
+	pasteRepo := models.NewMemoryPasteRepository()
+	aliceID, bobID := 1, 2
+	pasteRepo.Create(&models.Paste{ID: "pub001", Content: "public", UserID: &aliceID, Visibility: models.VisibilityPublic})
+	pasteRepo.Create(&models.Paste{ID: "prv001", Content: "private", UserID: &aliceID, Visibility: models.VisibilityPrivate})
+	pasteRepo.Create(&models.Paste{ID: "bob001", Content: "bob's", UserID: &bobID, Visibility: models.VisibilityPublic})
+	pasteRepo.Create(&models.Paste{ID: "net001", Content: "internal", UserID: &aliceID, Visibility: models.VisibilityPublic,
+		AllowedNetworks: []string{"10.0.0.0/8"}})
+	now := time.Now().UTC()
+	closed := &models.AccessWindow{Start: (now.Hour()*60 + now.Minute() + 60) % 1440, End: (now.Hour()*60 + now.Minute() + 120) % 1440}
+	pasteRepo.Create(&models.Paste{ID: "win001", Content: "later", UserID: &aliceID, Visibility: models.VisibilityPublic, AccessWindow: closed})
+
+	users := stubUsers{"alice": {ID: aliceID, Username: "alice"}, "bob": {ID: bobID, Username: "bob"}}
+	handler := NewS3Handler(users, pasteRepo, validation.NewValidator(), "s3-secret")
+	router := mux.NewRouter()
+	router.HandleFunc("/s3/{bucket}/{key}", handler.GetObject)
+
+	get := func(target string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, httptest.NewRequest("GET", target, nil))
+		return rr
+	}
+	presign := func(path, accessKey string, secretKey string) string {
+		signed, err := s3sig.Presign("http://example.com"+path, accessKey, secretKey, s3Region, time.Now(), time.Hour)
+		if err != nil {
+			t.Fatalf("Presign failed: %v", err)
+		}
+		return signed
+	}
+
+	if rr := get("/s3/alice/pub001"); rr.Code != http.StatusOK || rr.Body.String() != "public" || rr.Header().Get("ETag") == "" {
+		t.Errorf("Expected an anonymous GET of a public paste, got %d %q", rr.Code, rr.Body.String())
+	}
+	if rr := get("/s3/alice/prv001"); rr.Code != http.StatusNotFound || !strings.Contains(rr.Body.String(), "<Code>NoSuchKey</Code>") {
+		t.Errorf("Expected an anonymous GET of a private paste to be NoSuchKey, got %d %s", rr.Code, rr.Body.String())
+	}
+	if rr := get("/s3/alice/bob001"); rr.Code != http.StatusNotFound {
+		t.Errorf("Expected another user's paste to be missing from the bucket, got %d", rr.Code)
+	}
+	if rr := get("/s3/nobody/pub001"); rr.Code != http.StatusNotFound || !strings.Contains(rr.Body.String(), "NoSuchBucket") {
+		t.Errorf("Expected an unknown bucket, got %d %s", rr.Code, rr.Body.String())
+	}
+
+	// Restricted pastes are held to their restrictions unless the owner presigned the request
+	for _, key := range []string{"net001", "win001"} {
+		if rr := get("/s3/alice/" + key); rr.Code != http.StatusForbidden {
+			t.Errorf("Expected an anonymous GET of restricted paste %s to be refused, got %d", key, rr.Code)
+		}
+		if rr := get(presign("/s3/alice/"+key, "alice", handler.secretKey(aliceID))); rr.Code != http.StatusOK || rr.Header().Get("Cache-Control") != "private, no-store" {
+			t.Errorf("Expected a private presigned GET of restricted paste %s, got %d %q", key, rr.Code, rr.Header().Get("Cache-Control"))
+		}
+	}
+
+	if rr := get(presign("/s3/alice/prv001", "alice", handler.secretKey(aliceID))); rr.Code != http.StatusOK || rr.Body.String() != "private" {
+		t.Errorf("Expected a presigned GET of a private paste, got %d %s", rr.Code, rr.Body.String())
+	}
+	if rr := get(presign("/s3/alice/prv001", "alice", "wrong")); rr.Code != http.StatusForbidden || !strings.Contains(rr.Body.String(), "SignatureDoesNotMatch") {
+		t.Errorf("Expected a bad signature to be rejected, got %d %s", rr.Code, rr.Body.String())
+	}
+	if rr := get(presign("/s3/alice/prv001", "bob", handler.secretKey(bobID))); rr.Code != http.StatusForbidden {
+		t.Errorf("Expected another user's credentials to be refused, got %d", rr.Code)
+	}
+
+	// A deactivated account's bucket is closed to everyone
+	users["alice"].DeactivatedAt = &now
+	for _, target := range []string{"/s3/alice/pub001", presign("/s3/alice/prv001", "alice", handler.secretKey(aliceID))} {
+		if rr := get(target); rr.Code != http.StatusForbidden || !strings.Contains(rr.Body.String(), "<Code>AccessDenied</Code>") {
+			t.Errorf("Expected a deactivated owner's bucket to deny %s, got %d %s", target, rr.Code, rr.Body.String())
+		}
+	}
+}
@@ -0,0 +1,120 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/LonleySailor/privatepaste/backend/internal/services"
+	"github.com/gorilla/mux"
+)
+
+// SchedulerHandler handles admin requests for scheduled jobs
+type SchedulerHandler struct {
+	scheduler *services.Scheduler
+}
+
+// NewSchedulerHandler creates a new scheduler handler
+func NewSchedulerHandler(scheduler *services.Scheduler) *SchedulerHandler {
+	return &SchedulerHandler{scheduler: scheduler}
+}
+
+// List handles listing scheduled jobs with their last-run status
+func (h *SchedulerHandler) List(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		WriteError(w, &APIError{
+			Code:    "method_not_allowed",
+			Message: "Method not allowed",
+			Status:  http.StatusMethodNotAllowed,
+		})
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"jobs": h.scheduler.Status(),
+	})
+}
+
+// Run handles triggering a scheduled job immediately
+func (h *SchedulerHandler) Run(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		WriteError(w, &APIError{
+			Code:    "method_not_allowed",
+			Message: "Method not allowed",
+			Status:  http.StatusMethodNotAllowed,
+		})
+		return
+	}
+
+	switch err := h.scheduler.RunNow(mux.Vars(r)["name"]); err {
+	case nil:
+		w.WriteHeader(http.StatusAccepted)
+	case services.ErrJobLocked:
+		WriteError(w, &APIError{
+			Code:    "job_locked",
+			Message: "Another job holding the same lock is running",
+			Status:  http.StatusConflict,
+		})
+	case services.ErrJobNotFound:
+		WriteError(w, &APIError{
+			Code:    "job_not_found",
+			Message: "Scheduled job not found",
+			Status:  http.StatusNotFound,
+		})
+	case services.ErrJobRunning:
+		WriteError(w, &APIError{
+			Code:    "job_running",
+			Message: "Scheduled job is already running",
+			Status:  http.StatusConflict,
+		})
+	case services.ErrReadOnly:
+		WriteError(w, &APIError{
+			Code:    "read_only",
+			Message: "Scheduled jobs do not run while writes are paused",
+			Status:  http.StatusServiceUnavailable,
+		})
+	default:
+		WriteError(w, ErrInternalServer)
+	}
+}
+
+// Pause handles pausing a scheduled job until it is resumed
+func (h *SchedulerHandler) Pause(w http.ResponseWriter, r *http.Request) {
+	h.setPaused(w, r, true)
+}
+
+// Resume handles resuming a paused scheduled job
+func (h *SchedulerHandler) Resume(w http.ResponseWriter, r *http.Request) {
+	h.setPaused(w, r, false)
+}
+
+// setPaused pauses or resumes the job named in the URL
+func (h *SchedulerHandler) setPaused(w http.ResponseWriter, r *http.Request, paused bool) {
+	if r.Method != http.MethodPost {
+		WriteError(w, &APIError{
+			Code:    "method_not_allowed",
+			Message: "Method not allowed",
+			Status:  http.StatusMethodNotAllowed,
+		})
+		return
+	}
+
+	name := mux.Vars(r)["name"]
+	var err error
+	if paused {
+		err = h.scheduler.Pause(name)
+	} else {
+		err = h.scheduler.Resume(name)
+	}
+	if err == services.ErrJobNotFound {
+		WriteError(w, &APIError{
+			Code:    "job_not_found",
+			Message: "Scheduled job not found",
+			Status:  http.StatusNotFound,
+		})
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
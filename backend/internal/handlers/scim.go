@@ -0,0 +1,473 @@
+package handlers
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/LonleySailor/privatepaste/backend/internal/models"
+	"github.com/LonleySailor/privatepaste/backend/pkg/validation"
+	"github.com/gorilla/mux"
+)
+
+// SCIM schema and message URNs (RFC 7643, RFC 7644)
+const (
+	scimUserSchema      = "urn:ietf:params:scim:schemas:core:2.0:User"
+	scimListSchema      = "urn:ietf:params:scim:api:messages:2.0:ListResponse"
+	scimErrorSchema     = "urn:ietf:params:scim:api:messages:2.0:Error"
+	scimContentType     = "application/scim+json"
+	scimDefaultPageSize = 100
+	scimMaxPageSize     = 500
+)
+
+// scimFilterPattern matches the only filters providers need to find users:
+// an equality test on userName or externalId
+var scimFilterPattern = regexp.MustCompile(`(?i)^\s*(userName|externalId)\s+eq\s+("(?:[^"\\]|\\.)*")\s*$`)
+
+// SCIMStore provisions users on behalf of an identity provider
+type SCIMStore interface {
+	List(filter models.SCIMFilter, offset, limit int) ([]*models.SCIMUser, int, error)
+	Get(id int) (*models.SCIMUser, error)
+	Create(user *models.SCIMUser) error
+	Update(user *models.SCIMUser) (bool, error)
+	Delete(id int) (bool, error)
+}
+
+// SCIMHandler serves a SCIM 2.0 Users endpoint so enterprise identity
+// providers can create, rename and deactivate users who then sign in
+// through single sign-on. A provisioned user's externalId must be the
+// subject the provider signs them in with for their sign-in to claim it.
+type SCIMHandler struct {
+	users     SCIMStore
+	validator *validation.Validator
+	secret    string
+}
+
+// NewSCIMHandler creates a new SCIM handler. Providers authenticate with
+// secret as a bearer token.
+func NewSCIMHandler(users SCIMStore, validator *validation.Validator, secret string) *SCIMHandler {
+	return &SCIMHandler{users: users, validator: validator, secret: secret}
+}
+
+// SCIMUserResource is a user as represented to the provider
+type SCIMUserResource struct {
+	Schemas    []string `json:"schemas"`
+	ID         string   `json:"id"`
+	ExternalID string   `json:"externalId,omitempty"`
+	UserName   string   `json:"userName"`
+	Active     bool     `json:"active"`
+	Meta       SCIMMeta `json:"meta"`
+}
+
+// SCIMMeta describes a resource
+type SCIMMeta struct {
+	ResourceType string `json:"resourceType"`
+	Created      string `json:"created"`
+	LastModified string `json:"lastModified"`
+	Location     string `json:"location"`
+}
+
+// SCIMListResponse is a page of users
+type SCIMListResponse struct {
+	Schemas      []string            `json:"schemas"`
+	TotalResults int                 `json:"totalResults"`
+	StartIndex   int                 `json:"startIndex"`
+	ItemsPerPage int                 `json:"itemsPerPage"`
+	Resources    []*SCIMUserResource `json:"Resources"`
+}
+
+// scimUserRequest is the part of a User resource PasteVault keeps. Other
+// attributes, such as names and emails, are accepted and ignored.
+type scimUserRequest struct {
+	UserName   string `json:"userName"`
+	ExternalID string `json:"externalId"`
+	Active     *bool  `json:"active"`
+}
+
+// scimPatchRequest is a PatchOp message
+type scimPatchRequest struct {
+	Operations []struct {
+		Op    string          `json:"op"`
+		Path  string          `json:"path"`
+		Value json.RawMessage `json:"value"`
+	} `json:"Operations"`
+}
+
+// scimError is an error response in the SCIM format
+type scimError struct {
+	status   int
+	scimType string
+	detail   string
+}
+
+func writeSCIMError(w http.ResponseWriter, err *scimError) {
+	body := map[string]interface{}{
+		"schemas": []string{scimErrorSchema},
+		"status":  strconv.Itoa(err.status),
+		"detail":  err.detail,
+	}
+	if err.scimType != "" {
+		body["scimType"] = err.scimType
+	}
+	w.Header().Set("Content-Type", scimContentType)
+	w.WriteHeader(err.status)
+	json.NewEncoder(w).Encode(body)
+}
+
+func writeSCIM(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", scimContentType)
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+var (
+	errSCIMNotFound = &scimError{status: http.StatusNotFound, detail: "User not found"}
+	errSCIMInternal = &scimError{status: http.StatusInternalServerError, detail: "An internal server error occurred"}
+	errSCIMConflict = &scimError{status: http.StatusConflict, scimType: "uniqueness", detail: "userName or externalId belongs to another user"}
+)
+
+// authenticate checks the provider's bearer token, writing an error if it
+// does not match
+func (h *SCIMHandler) authenticate(w http.ResponseWriter, r *http.Request) bool {
+	secret, _ := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if h.secret == "" || subtle.ConstantTimeCompare([]byte(secret), []byte(h.secret)) != 1 {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="scim"`)
+		writeSCIMError(w, &scimError{status: http.StatusUnauthorized, detail: "Invalid SCIM credentials"})
+		return false
+	}
+	return true
+}
+
+// userID parses the user ID in the URL, writing an error if it is invalid
+func (h *SCIMHandler) userID(w http.ResponseWriter, r *http.Request) (int, bool) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		writeSCIMError(w, errSCIMNotFound)
+		return 0, false
+	}
+	return id, true
+}
+
+// resource represents a provisioned user to the provider
+func (h *SCIMHandler) resource(user *models.SCIMUser) *SCIMUserResource {
+	id := strconv.Itoa(user.ID)
+	return &SCIMUserResource{
+		Schemas:    []string{scimUserSchema},
+		ID:         id,
+		ExternalID: user.ExternalID,
+		UserName:   user.UserName,
+		Active:     user.Active,
+		Meta: SCIMMeta{
+			ResourceType: "User",
+			Created:      user.CreatedAt.UTC().Format(time.RFC3339),
+			LastModified: user.UpdatedAt.UTC().Format(time.RFC3339),
+			Location:     "/scim/v2/Users/" + id,
+		},
+	}
+}
+
+// validate derives the local username from the provider's user name,
+// returning an error if the user cannot be stored
+func (h *SCIMHandler) validate(user *models.SCIMUser) *scimError {
+	user.UserName = strings.TrimSpace(user.UserName)
+	if user.UserName == "" {
+		return &scimError{status: http.StatusBadRequest, scimType: "invalidValue", detail: "userName is required"}
+	}
+	if len(user.ExternalID) > 255 {
+		return &scimError{status: http.StatusBadRequest, scimType: "invalidValue", detail: "externalId is too long"}
+	}
+	user.Username = ssoUsername(user.UserName)
+	if err := h.validator.ValidateUsername(user.Username); err != nil {
+		return &scimError{status: http.StatusBadRequest, scimType: "invalidValue", detail: "userName does not make a valid username: " + err.Message}
+	}
+	return nil
+}
+
+// ListUsers handles listing provisioned users, optionally filtered by
+// userName or externalId
+func (h *SCIMHandler) ListUsers(w http.ResponseWriter, r *http.Request) {
+	if !h.authenticate(w, r) {
+		return
+	}
+
+	query := r.URL.Query()
+	var filter models.SCIMFilter
+	if raw := query.Get("filter"); raw != "" {
+		var value string
+		match := scimFilterPattern.FindStringSubmatch(raw)
+		if match != nil {
+			if unquoted, err := strconv.Unquote(match[2]); err == nil {
+				value = unquoted
+			} else {
+				match = nil
+			}
+		}
+		if match == nil {
+			writeSCIMError(w, &scimError{status: http.StatusBadRequest, scimType: "invalidFilter", detail: "Only userName eq and externalId eq filters are supported"})
+			return
+		}
+		if strings.EqualFold(match[1], "userName") {
+			filter.UserName = value
+		} else {
+			filter.ExternalID = value
+		}
+	}
+
+	startIndex, count := 1, scimDefaultPageSize
+	if n, err := strconv.Atoi(query.Get("startIndex")); err == nil && n > 1 {
+		startIndex = n
+	}
+	if n, err := strconv.Atoi(query.Get("count")); err == nil {
+		count = min(max(n, 0), scimMaxPageSize)
+	}
+
+	users, total, err := h.users.List(filter, startIndex-1, count)
+	if err != nil {
+		writeSCIMError(w, errSCIMInternal)
+		return
+	}
+
+	response := SCIMListResponse{
+		Schemas:      []string{scimListSchema},
+		TotalResults: total,
+		StartIndex:   startIndex,
+		ItemsPerPage: len(users),
+		Resources:    make([]*SCIMUserResource, 0, len(users)),
+	}
+	for _, user := range users {
+		response.Resources = append(response.Resources, h.resource(user))
+	}
+	writeSCIM(w, http.StatusOK, response)
+}
+
+// GetUser handles retrieving a provisioned user
+func (h *SCIMHandler) GetUser(w http.ResponseWriter, r *http.Request) {
+	if !h.authenticate(w, r) {
+		return
+	}
+	id, ok := h.userID(w, r)
+	if !ok {
+		return
+	}
+
+	user, err := h.users.Get(id)
+	if err != nil {
+		writeSCIMError(w, errSCIMInternal)
+		return
+	}
+	if user == nil {
+		writeSCIMError(w, errSCIMNotFound)
+		return
+	}
+	writeSCIM(w, http.StatusOK, h.resource(user))
+}
+
+// CreateUser handles provisioning a new user
+func (h *SCIMHandler) CreateUser(w http.ResponseWriter, r *http.Request) {
+	if !h.authenticate(w, r) {
+		return
+	}
+
+	var req scimUserRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeSCIMError(w, &scimError{status: http.StatusBadRequest, scimType: "invalidSyntax", detail: "Invalid JSON in request body"})
+		return
+	}
+
+	user := &models.SCIMUser{UserName: req.UserName, ExternalID: req.ExternalID, Active: req.Active == nil || *req.Active}
+	if err := h.validate(user); err != nil {
+		writeSCIMError(w, err)
+		return
+	}
+	if err := h.users.Create(user); err != nil {
+		if errors.Is(err, models.ErrSCIMConflict) {
+			writeSCIMError(w, errSCIMConflict)
+			return
+		}
+		writeSCIMError(w, errSCIMInternal)
+		return
+	}
+
+	resource := h.resource(user)
+	w.Header().Set("Location", resource.Meta.Location)
+	writeSCIM(w, http.StatusCreated, resource)
+}
+
+// ReplaceUser handles replacing a provisioned user's attributes. An omitted
+// active attribute reactivates the user, as a replacement resets it.
+func (h *SCIMHandler) ReplaceUser(w http.ResponseWriter, r *http.Request) {
+	if !h.authenticate(w, r) {
+		return
+	}
+	id, ok := h.userID(w, r)
+	if !ok {
+		return
+	}
+
+	var req scimUserRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeSCIMError(w, &scimError{status: http.StatusBadRequest, scimType: "invalidSyntax", detail: "Invalid JSON in request body"})
+		return
+	}
+
+	user := &models.SCIMUser{ID: id, UserName: req.UserName, ExternalID: req.ExternalID, Active: req.Active == nil || *req.Active}
+	h.update(w, user)
+}
+
+// PatchUser handles changing some of a provisioned user's attributes, which
+// is how most providers rename and deactivate users
+func (h *SCIMHandler) PatchUser(w http.ResponseWriter, r *http.Request) {
+	if !h.authenticate(w, r) {
+		return
+	}
+	id, ok := h.userID(w, r)
+	if !ok {
+		return
+	}
+
+	var req scimPatchRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeSCIMError(w, &scimError{status: http.StatusBadRequest, scimType: "invalidSyntax", detail: "Invalid JSON in request body"})
+		return
+	}
+
+	user, err := h.users.Get(id)
+	if err != nil {
+		writeSCIMError(w, errSCIMInternal)
+		return
+	}
+	if user == nil {
+		writeSCIMError(w, errSCIMNotFound)
+		return
+	}
+
+	for _, op := range req.Operations {
+		var err *scimError
+		switch strings.ToLower(op.Op) {
+		case "add", "replace":
+			if op.Path == "" {
+				err = applySCIMValues(user, op.Value)
+			} else {
+				err = applySCIMValue(user, op.Path, op.Value)
+			}
+		case "remove":
+			if strings.EqualFold(op.Path, "externalId") {
+				user.ExternalID = ""
+			} else if strings.EqualFold(op.Path, "userName") || strings.EqualFold(op.Path, "active") {
+				err = &scimError{status: http.StatusBadRequest, scimType: "mutability", detail: op.Path + " cannot be removed"}
+			}
+		default:
+			err = &scimError{status: http.StatusBadRequest, scimType: "invalidSyntax", detail: "Unsupported patch operation " + strconv.Quote(op.Op)}
+		}
+		if err != nil {
+			writeSCIMError(w, err)
+			return
+		}
+	}
+
+	h.update(w, user)
+}
+
+// update stores a changed user and writes it back to the provider
+func (h *SCIMHandler) update(w http.ResponseWriter, user *models.SCIMUser) {
+	if err := h.validate(user); err != nil {
+		writeSCIMError(w, err)
+		return
+	}
+	found, err := h.users.Update(user)
+	if errors.Is(err, models.ErrSCIMConflict) {
+		writeSCIMError(w, errSCIMConflict)
+		return
+	}
+	if err != nil {
+		writeSCIMError(w, errSCIMInternal)
+		return
+	}
+	if !found {
+		writeSCIMError(w, errSCIMNotFound)
+		return
+	}
+
+	updated, err := h.users.Get(user.ID)
+	if err != nil || updated == nil {
+		writeSCIMError(w, errSCIMInternal)
+		return
+	}
+	writeSCIM(w, http.StatusOK, h.resource(updated))
+}
+
+// DeleteUser handles deprovisioning a user. The account is deactivated
+// rather than deleted so its pastes survive, and is no longer managed over
+// SCIM.
+func (h *SCIMHandler) DeleteUser(w http.ResponseWriter, r *http.Request) {
+	if !h.authenticate(w, r) {
+		return
+	}
+	id, ok := h.userID(w, r)
+	if !ok {
+		return
+	}
+
+	found, err := h.users.Delete(id)
+	if err != nil {
+		writeSCIMError(w, errSCIMInternal)
+		return
+	}
+	if !found {
+		writeSCIMError(w, errSCIMNotFound)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// applySCIMValues applies a patch value without a path, an object of
+// attributes
+func applySCIMValues(user *models.SCIMUser, raw json.RawMessage) *scimError {
+	var values map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &values); err != nil {
+		return &scimError{status: http.StatusBadRequest, scimType: "invalidValue", detail: "A patch without a path needs an object value"}
+	}
+	for path, value := range values {
+		if err := applySCIMValue(user, path, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// applySCIMValue sets one attribute. Attributes PasteVault does not keep are
+// ignored.
+func applySCIMValue(user *models.SCIMUser, path string, raw json.RawMessage) *scimError {
+	switch strings.ToLower(path) {
+	case "username":
+		if err := json.Unmarshal(raw, &user.UserName); err != nil {
+			return &scimError{status: http.StatusBadRequest, scimType: "invalidValue", detail: "userName must be a string"}
+		}
+	case "externalid":
+		if err := json.Unmarshal(raw, &user.ExternalID); err != nil {
+			return &scimError{status: http.StatusBadRequest, scimType: "invalidValue", detail: "externalId must be a string"}
+		}
+	case "active":
+		// Some providers send booleans as strings
+		var active interface{}
+		json.Unmarshal(raw, &active)
+		switch v := active.(type) {
+		case bool:
+			user.Active = v
+		case string:
+			parsed, err := strconv.ParseBool(v)
+			if err != nil {
+				return &scimError{status: http.StatusBadRequest, scimType: "invalidValue", detail: "active must be a boolean"}
+			}
+			user.Active = parsed
+		default:
+			return &scimError{status: http.StatusBadRequest, scimType: "invalidValue", detail: "active must be a boolean"}
+		}
+	}
+	return nil
+}
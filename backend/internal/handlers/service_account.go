@@ -0,0 +1,264 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"slices"
+	"strconv"
+
+	"github.com/LonleySailor/privatepaste/backend/internal/auth"
+	"github.com/LonleySailor/privatepaste/backend/internal/middleware"
+	"github.com/LonleySailor/privatepaste/backend/internal/models"
+	"github.com/LonleySailor/privatepaste/backend/pkg/validation"
+	"github.com/gorilla/mux"
+)
+
+// ServiceAccountStore manages service accounts and their credentials
+type ServiceAccountStore interface {
+	Create(name, description string, scopes []string, createdBy int) (string, *models.ServiceAccount, error)
+	List() ([]*models.ServiceAccount, error)
+	Disable(userID int) (bool, error)
+	Authenticate(clientID, secret string) (*models.ServiceAccount, error)
+}
+
+// ServiceAccountHandler handles service accounts: their management by
+// admins, and the client credentials grant they obtain tokens with
+type ServiceAccountHandler struct {
+	accounts     ServiceAccountStore
+	users        *models.UserRepository
+	tokenManager *auth.TokenManager
+	validator    *validation.Validator
+}
+
+// NewServiceAccountHandler creates a new service account handler
+func NewServiceAccountHandler(accounts ServiceAccountStore, users *models.UserRepository, tokenManager *auth.TokenManager, validator *validation.Validator) *ServiceAccountHandler {
+	return &ServiceAccountHandler{
+		accounts:     accounts,
+		users:        users,
+		tokenManager: tokenManager,
+		validator:    validator,
+	}
+}
+
+// CreateServiceAccountRequest represents a request to create a service account
+type CreateServiceAccountRequest struct {
+	Name        string   `json:"name"`
+	Description string   `json:"description,omitempty"`
+	Scopes      []string `json:"scopes"`
+}
+
+// CreateServiceAccountResponse includes the client secret, which is never
+// shown again
+type CreateServiceAccountResponse struct {
+	*models.ServiceAccount
+	ClientSecret string `json:"client_secret"`
+}
+
+// TokenRequest is a client credentials grant. The credentials may also be
+// sent with HTTP basic authentication.
+type TokenRequest struct {
+	GrantType    string `json:"grant_type"`
+	ClientID     string `json:"client_id"`
+	ClientSecret string `json:"client_secret"`
+}
+
+// TokenResponse carries a service account's access token
+type TokenResponse struct {
+	AccessToken string   `json:"access_token"`
+	TokenType   string   `json:"token_type"`
+	ExpiresAt   int64    `json:"expires_at"`
+	Scopes      []string `json:"scopes"`
+}
+
+// Create handles an admin creating a service account
+func (h *ServiceAccountHandler) Create(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		WriteError(w, &APIError{
+			Code:    "method_not_allowed",
+			Message: "Method not allowed",
+			Status:  http.StatusMethodNotAllowed,
+		})
+		return
+	}
+
+	adminID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		WriteError(w, &APIError{
+			Code:    "unauthorized",
+			Message: "User ID not found in token",
+			Status:  http.StatusUnauthorized,
+		})
+		return
+	}
+
+	var req CreateServiceAccountRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		WriteError(w, ErrInvalidJSON)
+		return
+	}
+
+	var errors validation.ValidationErrors
+	if err := h.validator.ValidateUsername(req.Name); err != nil {
+		errors.Add("name", err.Message)
+	}
+	if err := h.validator.ValidateString(req.Description, "description", false, 0, 500); err != nil {
+		errors.Add(err.Field, err.Message)
+	}
+	if len(req.Scopes) == 0 {
+		errors.Add("scopes", "At least one scope is required")
+	}
+	for _, scope := range req.Scopes {
+		if !slices.Contains(auth.ServiceScopes, scope) {
+			errors.Add("scopes", "Unknown scope "+strconv.Quote(scope))
+		}
+	}
+	if errors.HasErrors() {
+		WriteValidationError(w, errors)
+		return
+	}
+
+	exists, err := h.users.Exists(req.Name)
+	if err != nil {
+		WriteRepositoryError(w, err)
+		return
+	}
+	if exists {
+		WriteError(w, &APIError{
+			Code:    "username_exists",
+			Message: "Username already exists",
+			Status:  http.StatusConflict,
+		})
+		return
+	}
+
+	secret, account, err := h.accounts.Create(req.Name, req.Description, slices.Compact(slices.Sorted(slices.Values(req.Scopes))), adminID)
+	if err != nil {
+		WriteRepositoryError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(CreateServiceAccountResponse{ServiceAccount: account, ClientSecret: secret})
+}
+
+// List handles listing every service account
+func (h *ServiceAccountHandler) List(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		WriteError(w, &APIError{
+			Code:    "method_not_allowed",
+			Message: "Method not allowed",
+			Status:  http.StatusMethodNotAllowed,
+		})
+		return
+	}
+
+	accounts, err := h.accounts.List()
+	if err != nil {
+		WriteRepositoryError(w, err)
+		return
+	}
+	if accounts == nil {
+		accounts = []*models.ServiceAccount{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{"service_accounts": accounts})
+}
+
+// Disable handles disabling a service account. Tokens it already holds stay
+// valid until they expire.
+func (h *ServiceAccountHandler) Disable(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		WriteError(w, &APIError{
+			Code:    "method_not_allowed",
+			Message: "Method not allowed",
+			Status:  http.StatusMethodNotAllowed,
+		})
+		return
+	}
+
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		WriteError(w, &APIError{
+			Code:    "invalid_id",
+			Message: "Invalid service account ID",
+			Status:  http.StatusBadRequest,
+		})
+		return
+	}
+
+	disabled, err := h.accounts.Disable(id)
+	if err != nil {
+		WriteRepositoryError(w, err)
+		return
+	}
+	if !disabled {
+		WriteError(w, ErrServiceAccountNotFound)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Token handles the client credentials grant, exchanging a service account's
+// client ID and secret for an access token limited to its scopes
+func (h *ServiceAccountHandler) Token(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		WriteError(w, &APIError{
+			Code:    "method_not_allowed",
+			Message: "Method not allowed",
+			Status:  http.StatusMethodNotAllowed,
+		})
+		return
+	}
+
+	var req TokenRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		WriteError(w, ErrInvalidJSON)
+		return
+	}
+	if clientID, secret, ok := r.BasicAuth(); ok {
+		req.ClientID, req.ClientSecret = clientID, secret
+	}
+
+	if req.GrantType != "client_credentials" {
+		WriteError(w, &APIError{
+			Code:    "unsupported_grant_type",
+			Message: "Only the client_credentials grant is supported",
+			Status:  http.StatusBadRequest,
+		})
+		return
+	}
+
+	account, err := h.accounts.Authenticate(req.ClientID, req.ClientSecret)
+	if err != nil {
+		WriteRepositoryError(w, err)
+		return
+	}
+	if account == nil {
+		WriteError(w, &APIError{
+			Code:    "invalid_client",
+			Message: "Invalid client credentials",
+			Status:  http.StatusUnauthorized,
+		})
+		return
+	}
+
+	tokens, err := h.tokenManager.GenerateServiceToken(account.UserID, account.Name, account.Scopes)
+	if err != nil {
+		WriteError(w, ErrInternalServer)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(TokenResponse{
+		AccessToken: tokens.AccessToken,
+		TokenType:   "Bearer",
+		ExpiresAt:   tokens.ExpiresAt,
+		Scopes:      account.Scopes,
+	})
+}
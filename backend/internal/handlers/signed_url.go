@@ -0,0 +1,150 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/LonleySailor/privatepaste/backend/internal/models"
+	"github.com/LonleySailor/privatepaste/backend/pkg/utils"
+	"github.com/LonleySailor/privatepaste/backend/pkg/validation"
+)
+
+// Signed URL lifetimes
+const (
+	DefaultSignedURLTTL = time.Hour
+	MaxSignedURLTTL     = 7 * 24 * time.Hour
+)
+
+// SignedURLResponse represents signed raw and download URLs for a paste
+type SignedURLResponse struct {
+	RawURL      string `json:"raw_url"`
+	DownloadURL string `json:"download_url"`
+	ExpiresAt   string `json:"expires_at"`
+}
+
+// SetURLSigner enables signing raw and download URLs
+func (h *PasteHandler) SetURLSigner(signer *utils.URLSigner) {
+	h.urlSigner = signer
+}
+
+// checkSignedURL enforces the expires and signature query parameters on pastes
+// that require signed URLs. On failure it writes the error response and
+// returns false.
+func (h *PasteHandler) checkSignedURL(w http.ResponseWriter, r *http.Request, paste *models.Paste) bool {
+	if !paste.RequireSignedURLs {
+		return true
+	}
+	if h.urlSigner == nil {
+		WriteError(w, ErrSignatureInvalid)
+		return false
+	}
+
+	query := r.URL.Query()
+	switch h.urlSigner.Verify(paste.ID, query.Get("expires"), query.Get("signature")) {
+	case nil:
+		return true
+	case utils.ErrSignatureMissing:
+		WriteError(w, ErrSignatureRequired)
+	case utils.ErrSignatureExpired:
+		WriteError(w, ErrSignatureExpired)
+	default:
+		WriteError(w, ErrSignatureInvalid)
+	}
+	return false
+}
+
+// SignURL handles issuing expiring signed raw and download URLs for a paste.
+// The optional ttl query parameter sets the lifetime in seconds. Only the
+// paste's owner can sign: a signature grants access the paste's settings
+// otherwise refuse, such as emailing it.
+func (h *PasteHandler) SignURL(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		WriteError(w, &APIError{
+			Code:    "method_not_allowed",
+			Message: "Method not allowed",
+			Status:  http.StatusMethodNotAllowed,
+		})
+		return
+	}
+
+	if h.urlSigner == nil {
+		WriteError(w, &APIError{
+			Code:    "signed_urls_disabled",
+			Message: "Signed URLs are not enabled on this server",
+			Status:  http.StatusServiceUnavailable,
+		})
+		return
+	}
+
+	ttl := DefaultSignedURLTTL
+	if v := r.URL.Query().Get("ttl"); v != "" {
+		seconds, err := strconv.Atoi(v)
+		if err != nil || seconds <= 0 || time.Duration(seconds)*time.Second > MaxSignedURLTTL {
+			WriteValidationError(w, []validation.ValidationError{{
+				Field:   "ttl",
+				Message: "must be between 1 and " + strconv.Itoa(int(MaxSignedURLTTL.Seconds())) + " seconds",
+			}})
+			return
+		}
+		ttl = time.Duration(seconds) * time.Second
+	}
+
+	paste, ok := h.loadViewablePaste(w, r)
+	if !ok {
+		return
+	}
+	if !ownsPaste(r, paste) {
+		WriteError(w, &APIError{
+			Code:    "forbidden",
+			Message: "Only the paste's owner can sign its URLs",
+			Status:  http.StatusForbidden,
+		})
+		return
+	}
+
+	expires := time.Now().Add(ttl)
+	if paste.ExpiresAt != nil && paste.ExpiresAt.Before(expires) {
+		expires = *paste.ExpiresAt
+	}
+
+	query := url.Values{}
+	query.Set("expires", strconv.FormatInt(expires.Unix(), 10))
+	query.Set("signature", h.urlSigner.Sign(paste.ID, expires))
+
+	base := "/api/paste/" + paste.ID
+	response := SignedURLResponse{
+		RawURL:      base + "/raw?" + query.Encode(),
+		DownloadURL: base + "/download?" + query.Encode(),
+		ExpiresAt:   expires.Format(time.RFC3339),
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
+
+// GetDownload handles downloading a paste's raw content as a file attachment
+func (h *PasteHandler) GetDownload(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		WriteError(w, &APIError{
+			Code:    "method_not_allowed",
+			Message: "Method not allowed",
+			Status:  http.StatusMethodNotAllowed,
+		})
+		return
+	}
+
+	paste, ok := h.loadViewablePaste(w, r)
+	if !ok || !h.checkSignedURL(w, r, paste) {
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Header().Set("Content-Disposition", `attachment; filename="`+paste.ID+`.txt"`)
+	signContent(w, h.responseSigner, paste.ID, paste.Content)
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte(paste.Content))
+}
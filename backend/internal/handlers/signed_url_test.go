@@ -0,0 +1,112 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/LonleySailor/privatepaste/backend/internal/models"
+	"github.com/LonleySailor/privatepaste/backend/pkg/utils"
+	"github.com/gorilla/mux"
+)
+
+// signURL asks for signed URLs of a paste as the given user
+func signURL(handler *PasteHandler, id, query string, userID int) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("GET", "/api/paste/"+id+"/signed-url"+query, nil)
+	req = mux.SetURLVars(req, map[string]string{"id": id})
+	req = req.WithContext(context.WithValue(req.Context(), "userID", userID))
+
+	rr := httptest.NewRecorder()
+	handler.SignURL(rr, req)
+	return rr
+}
+
+func TestSignedURLs(t *testing.T) {
+	handler, repo := setupTestHandler()
+	handler.SetURLSigner(utils.NewURLSigner("test-secret"))
+
+	owner := 7
+	id := "sig123"
+	repo.Create(&models.Paste{ID: id, Content: "no hotlinking", RequireSignedURLs: true, UserID: &owner})
+
+	// Unsigned access is refused
+	rr := getPasteView(handler.GetRaw, id, "")
+	if rr.Code != http.StatusForbidden || !strings.Contains(rr.Body.String(), "signature_required") {
+		t.Fatalf("Expected signature_required, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	rr = getPasteView(handler.GetRaw, id, "?expires=9999999999&signature=forged")
+	if rr.Code != http.StatusForbidden || !strings.Contains(rr.Body.String(), "signature_invalid") {
+		t.Fatalf("Expected signature_invalid, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	// Only the owner can sign
+	rr = getPasteView(handler.SignURL, id, "?ttl=60")
+	if rr.Code != http.StatusForbidden {
+		t.Errorf("Expected status %d signing anonymously, got %d", http.StatusForbidden, rr.Code)
+	}
+	rr = signURL(handler, id, "?ttl=60", 8)
+	if rr.Code != http.StatusForbidden {
+		t.Errorf("Expected status %d signing another user's paste, got %d", http.StatusForbidden, rr.Code)
+	}
+
+	rr = signURL(handler, id, "?ttl=60", owner)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+	var signed SignedURLResponse
+	json.Unmarshal(rr.Body.Bytes(), &signed)
+
+	_, query, _ := strings.Cut(signed.RawURL, "?")
+	rr = getPasteView(handler.GetRaw, id, "?"+query)
+	if rr.Code != http.StatusOK || rr.Body.String() != "no hotlinking" {
+		t.Errorf("Expected signed raw access, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	rr = getPasteView(handler.GetDownload, id, "?"+query)
+	if rr.Code != http.StatusOK {
+		t.Errorf("Expected signed download access, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if disposition := rr.Header().Get("Content-Disposition"); !strings.HasPrefix(disposition, "attachment") {
+		t.Errorf("Expected attachment disposition, got %q", disposition)
+	}
+
+	// The rendered view shows all of the content, so it needs a signature too
+	if rr = getPasteView(handler.GetHTML, id, ""); rr.Code != http.StatusForbidden {
+		t.Errorf("Expected unsigned HTML view to be refused, got %d", rr.Code)
+	}
+	if rr = getPasteView(handler.GetHTML, id, "?"+query); rr.Code != http.StatusOK {
+		t.Errorf("Expected signed HTML view, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	// Signatures are bound to a single paste
+	other := createTestPaste(t, handler, CreatePasteRequest{Content: "other", RequireSignedURLs: true})
+	rr = getPasteView(handler.GetRaw, other, "?"+query)
+	if rr.Code != http.StatusForbidden {
+		t.Errorf("Expected signature for another paste to be rejected, got %d", rr.Code)
+	}
+
+	rr = signURL(handler, id, "?ttl=0", owner)
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected status %d for invalid ttl, got %d", http.StatusBadRequest, rr.Code)
+	}
+}
+
+func TestSignedURLs_NotRequired(t *testing.T) {
+	handler, _ := setupTestHandler()
+
+	id := createTestPaste(t, handler, CreatePasteRequest{Content: "public"})
+
+	rr := getPasteView(handler.GetRaw, id, "")
+	if rr.Code != http.StatusOK {
+		t.Errorf("Expected status %d, got %d", http.StatusOK, rr.Code)
+	}
+
+	rr = getPasteView(handler.SignURL, id, "")
+	if rr.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected status %d without a signer, got %d", http.StatusServiceUnavailable, rr.Code)
+	}
+}
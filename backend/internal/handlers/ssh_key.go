@@ -0,0 +1,283 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/LonleySailor/privatepaste/backend/internal/middleware"
+	"github.com/LonleySailor/privatepaste/backend/internal/models"
+	"github.com/LonleySailor/privatepaste/backend/internal/services"
+	"github.com/LonleySailor/privatepaste/backend/pkg/utils"
+	"github.com/LonleySailor/privatepaste/backend/pkg/validation"
+	"github.com/gorilla/mux"
+	"golang.org/x/crypto/ssh"
+)
+
+// maxSSHKeysPerUser limits how many SSH keys one account may hold
+const maxSSHKeysPerUser = 20
+
+// SSHKeyStore manages users' SSH public keys
+type SSHKeyStore interface {
+	Create(key *models.SSHKey) error
+	ListByUserID(userID int) ([]*models.SSHKey, error)
+	Delete(userID int, id int64) (bool, error)
+	Authenticate(fingerprint string) (*models.SSHKey, error)
+}
+
+// SSHKeyHandler handles managing the SSH keys used for SSH paste submission
+type SSHKeyHandler struct {
+	keys      SSHKeyStore
+	validator *validation.Validator
+}
+
+// NewSSHKeyHandler creates a new SSH key handler
+func NewSSHKeyHandler(keys SSHKeyStore, validator *validation.Validator) *SSHKeyHandler {
+	return &SSHKeyHandler{keys: keys, validator: validator}
+}
+
+// AddSSHKeyRequest represents a request to register an SSH public key. The
+// name defaults to the key's comment.
+type AddSSHKeyRequest struct {
+	Name      string `json:"name,omitempty"`
+	PublicKey string `json:"public_key"`
+}
+
+// Create handles registering an SSH public key for the authenticated user
+func (h *SSHKeyHandler) Create(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		WriteError(w, &APIError{
+			Code:    "method_not_allowed",
+			Message: "Method not allowed",
+			Status:  http.StatusMethodNotAllowed,
+		})
+		return
+	}
+
+	userID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		WriteError(w, &APIError{
+			Code:    "unauthorized",
+			Message: "User ID not found in token",
+			Status:  http.StatusUnauthorized,
+		})
+		return
+	}
+
+	var req AddSSHKeyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		WriteError(w, ErrInvalidJSON)
+		return
+	}
+
+	publicKey, comment, _, rest, err := ssh.ParseAuthorizedKey([]byte(req.PublicKey))
+	if err != nil || strings.TrimSpace(string(rest)) != "" {
+		WriteValidationError(w, []validation.ValidationError{{Field: "public_key", Message: "must be a single public key in authorized_keys format"}})
+		return
+	}
+	if req.Name == "" {
+		req.Name = comment
+	}
+	if err := h.validator.ValidateString(req.Name, "name", true, 1, 100); err != nil {
+		WriteValidationError(w, []validation.ValidationError{*err})
+		return
+	}
+
+	existing, err := h.keys.ListByUserID(userID)
+	if err != nil {
+		WriteRepositoryError(w, err)
+		return
+	}
+	if len(existing) >= maxSSHKeysPerUser {
+		WriteValidationError(w, []validation.ValidationError{{Field: "public_key", Message: "SSH key limit reached; remove an unused key first"}})
+		return
+	}
+
+	key := &models.SSHKey{
+		UserID:      userID,
+		Name:        req.Name,
+		Fingerprint: ssh.FingerprintSHA256(publicKey),
+		PublicKey:   strings.TrimSpace(string(ssh.MarshalAuthorizedKey(publicKey))),
+	}
+	if err := h.keys.Create(key); err != nil {
+		if errors.Is(err, models.ErrSSHKeyTaken) {
+			WriteError(w, &APIError{
+				Code:    "ssh_key_exists",
+				Message: "This SSH key is already registered",
+				Status:  http.StatusConflict,
+			})
+			return
+		}
+		WriteRepositoryError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(key)
+}
+
+// List handles listing the authenticated user's SSH keys
+func (h *SSHKeyHandler) List(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		WriteError(w, &APIError{
+			Code:    "method_not_allowed",
+			Message: "Method not allowed",
+			Status:  http.StatusMethodNotAllowed,
+		})
+		return
+	}
+
+	userID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		WriteError(w, &APIError{
+			Code:    "unauthorized",
+			Message: "User ID not found in token",
+			Status:  http.StatusUnauthorized,
+		})
+		return
+	}
+
+	keys, err := h.keys.ListByUserID(userID)
+	if err != nil {
+		WriteRepositoryError(w, err)
+		return
+	}
+	if keys == nil {
+		keys = []*models.SSHKey{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{"keys": keys})
+}
+
+// Delete handles removing one of the authenticated user's SSH keys
+func (h *SSHKeyHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		WriteError(w, &APIError{
+			Code:    "method_not_allowed",
+			Message: "Method not allowed",
+			Status:  http.StatusMethodNotAllowed,
+		})
+		return
+	}
+
+	userID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		WriteError(w, &APIError{
+			Code:    "unauthorized",
+			Message: "User ID not found in token",
+			Status:  http.StatusUnauthorized,
+		})
+		return
+	}
+
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		WriteError(w, &APIError{
+			Code:    "invalid_id",
+			Message: "Invalid SSH key ID",
+			Status:  http.StatusBadRequest,
+		})
+		return
+	}
+
+	deleted, err := h.keys.Delete(userID, id)
+	if err != nil {
+		WriteRepositoryError(w, err)
+		return
+	}
+	if !deleted {
+		WriteError(w, &APIError{
+			Code:    "ssh_key_not_found",
+			Message: "SSH key not found",
+			Status:  http.StatusNotFound,
+		})
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// SSHPasteBackend stores pastes submitted over SSH, implementing
+// sshpaste.Backend with the same rules as pastes created through the API
+type SSHPasteBackend struct {
+	keys        SSHKeyStore
+	pasteRepo   PasteRepositoryInterface
+	idGenerator *utils.IDGenerator
+	validator   *validation.Validator
+	readOnly    *middleware.ReadOnlyMode
+	creator     *PasteCreator
+}
+
+// NewSSHPasteBackend creates a new SSH paste backend
+func NewSSHPasteBackend(keys SSHKeyStore, pasteRepo PasteRepositoryInterface, idGenerator *utils.IDGenerator, validator *validation.Validator) *SSHPasteBackend {
+	return &SSHPasteBackend{
+		keys:        keys,
+		pasteRepo:   pasteRepo,
+		idGenerator: idGenerator,
+		validator:   validator,
+		creator:     NewPasteCreator(pasteRepo),
+	}
+}
+
+// SetPasteCreator replaces the default creator, which applies no content
+// policies, with one that enforces them
+func (b *SSHPasteBackend) SetPasteCreator(creator *PasteCreator) {
+	b.creator = creator
+}
+
+// SetReadOnlyMode refuses pastes while the instance's writes are paused
+func (b *SSHPasteBackend) SetReadOnlyMode(mode *middleware.ReadOnlyMode) {
+	b.readOnly = mode
+}
+
+// UserForKey returns the user who registered the key with a fingerprint
+func (b *SSHPasteBackend) UserForKey(fingerprint string) (int, bool, error) {
+	key, err := b.keys.Authenticate(fingerprint)
+	if err != nil || key == nil {
+		return 0, false, err
+	}
+	return key.UserID, true, nil
+}
+
+// CreatePaste stores an unlisted paste owned by the user and returns its URL.
+// Errors are safe to show to the SSH client.
+func (b *SSHPasteBackend) CreatePaste(userID int, content, language string) (string, error) {
+	if b.readOnly != nil && b.readOnly.Enabled() {
+		return "", errors.New("this instance is temporarily read-only; try again later")
+	}
+	if err := b.validator.ValidatePasteContent(content); err != nil {
+		return "", errors.New("content " + err.Message)
+	}
+	if err := b.validator.ValidateLanguage(language); err != nil {
+		return "", errors.New("language " + err.Message)
+	}
+
+	id, err := generatePasteID(b.idGenerator, b.pasteRepo, models.VisibilityUnlisted)
+	if err != nil {
+		return "", errors.New(ErrIDGenerationFailed.Message)
+	}
+
+	paste := &models.Paste{
+		ID:          id,
+		Content:     content,
+		Language:    language,
+		UserID:      &userID,
+		Kind:        models.KindText,
+		Visibility:  models.VisibilityUnlisted,
+		LineNumbers: true,
+	}
+	var violation *services.PolicyViolation
+	err = b.creator.CreateOwned(paste, services.PasteAttributes{})
+	if errors.As(err, &violation) {
+		return "", violation
+	}
+	if err != nil {
+		return "", errors.New(ErrInternalServer.Message)
+	}
+	return PasteURL(paste.ID), nil
+}
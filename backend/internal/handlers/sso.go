@@ -0,0 +1,427 @@
+package handlers
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/LonleySailor/privatepaste/backend/internal/auth"
+	"github.com/LonleySailor/privatepaste/backend/internal/models"
+	"github.com/LonleySailor/privatepaste/backend/pkg/ldap"
+	"github.com/LonleySailor/privatepaste/backend/pkg/oidc"
+	"github.com/LonleySailor/privatepaste/backend/pkg/validation"
+)
+
+// oidcCookie holds the state, nonce and PKCE verifier of a sign-in in
+// progress, binding the provider's callback to the browser that started it
+const oidcCookie = "pv_oidc"
+
+// ldapTimeout bounds connecting to the directory and each operation
+const ldapTimeout = 10 * time.Second
+
+// errSSOInvalidCredentials is returned for unknown users or wrong passwords
+var errSSOInvalidCredentials = errors.New("invalid credentials")
+
+// IdentityStore links users to their single sign-on identities, creating
+// users on first sign-in
+type IdentityStore interface {
+	Provision(provider, subject, username, role string) (*models.User, error)
+}
+
+// LDAPConfig describes the directory users sign in against. UserFilter
+// contains %s where the escaped username goes.
+type LDAPConfig struct {
+	URL               string
+	StartTLS          bool
+	BindDN            string
+	BindPassword      string
+	BaseDN            string
+	UserFilter        string
+	UsernameAttribute string
+	GroupAttribute    string
+}
+
+// GroupRoles maps single sign-on groups to roles. Groups match by name or
+// full DN, case-insensitively.
+type GroupRoles struct {
+	AdminGroups   []string // Members get the admin role; empty leaves roles alone
+	AllowedGroups []string // When set, only members may sign in
+}
+
+// role returns the role for a user in the given groups, empty when roles are
+// not managed by groups, and whether the user may sign in at all
+func (g GroupRoles) role(groups []string) (string, bool) {
+	if len(g.AllowedGroups) > 0 && !inAnyGroup(groups, g.AllowedGroups) {
+		return "", false
+	}
+	if len(g.AdminGroups) == 0 {
+		return "", true
+	}
+	if inAnyGroup(groups, g.AdminGroups) {
+		return models.RoleAdmin, true
+	}
+	return models.RoleUser, true
+}
+
+// inAnyGroup reports whether any of groups is one of wanted. A group given
+// as a DN such as cn=admins,ou=groups,dc=example,dc=org also matches by its
+// first value, admins.
+func inAnyGroup(groups, wanted []string) bool {
+	for _, group := range groups {
+		names := []string{group}
+		if rdn, _, ok := strings.Cut(group, ","); ok {
+			if _, value, ok := strings.Cut(rdn, "="); ok {
+				names = append(names, value)
+			}
+		}
+		for _, name := range names {
+			for _, w := range wanted {
+				if strings.EqualFold(strings.TrimSpace(name), w) {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// ssoIdentity is a user as described by a single sign-on provider
+type ssoIdentity struct {
+	provider string
+	subject  string // Stable identifier at the provider
+	username string
+	groups   []string
+}
+
+// SSOHandler handles signing in through an organization's OpenID Connect
+// provider or LDAP directory. Users are created on their first sign-in.
+type SSOHandler struct {
+	identities   IdentityStore
+	tokenManager *auth.TokenManager
+	validator    *validation.Validator
+	groups       GroupRoles
+
+	ldap *LDAPConfig
+
+	oidc              *oidc.Provider
+	oidcUsernameClaim string
+	oidcGroupsClaim   string
+	postLoginURL      string
+}
+
+// NewSSOHandler creates a new single sign-on handler. Providers are enabled
+// with SetLDAP and SetOIDC.
+func NewSSOHandler(identities IdentityStore, tokenManager *auth.TokenManager, validator *validation.Validator, groups GroupRoles) *SSOHandler {
+	return &SSOHandler{
+		identities:   identities,
+		tokenManager: tokenManager,
+		validator:    validator,
+		groups:       groups,
+	}
+}
+
+// SetLDAP enables signing in with directory credentials
+func (h *SSOHandler) SetLDAP(config LDAPConfig) {
+	h.ldap = &config
+}
+
+// SetOIDC enables signing in through an OpenID Connect provider. After
+// signing in, the browser is sent to postLoginURL with the tokens in the URL
+// fragment, or an error code when signing in failed.
+func (h *SSOHandler) SetOIDC(provider *oidc.Provider, usernameClaim, groupsClaim, postLoginURL string) {
+	h.oidc = provider
+	h.oidcUsernameClaim = usernameClaim
+	h.oidcGroupsClaim = groupsClaim
+	h.postLoginURL = postLoginURL
+}
+
+// LDAPLoginRequest carries directory credentials
+type LDAPLoginRequest struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+// LDAPLogin handles signing in with directory credentials
+func (h *SSOHandler) LDAPLogin(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		WriteError(w, &APIError{
+			Code:    "method_not_allowed",
+			Message: "Method not allowed",
+			Status:  http.StatusMethodNotAllowed,
+		})
+		return
+	}
+
+	var req LDAPLoginRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		WriteError(w, ErrInvalidJSON)
+		return
+	}
+	if req.Username == "" || req.Password == "" {
+		WriteError(w, &APIError{
+			Code:    "validation_failed",
+			Message: "Username and password required",
+			Status:  http.StatusBadRequest,
+		})
+		return
+	}
+
+	identity, err := h.authenticateLDAP(req.Username, req.Password)
+	if errors.Is(err, errSSOInvalidCredentials) {
+		WriteError(w, &APIError{
+			Code:    "invalid_credentials",
+			Message: "Invalid username or password",
+			Status:  http.StatusUnauthorized,
+		})
+		return
+	}
+	if err != nil {
+		log.Printf("LDAP sign-in failed: %v", err)
+		WriteError(w, ErrSSOUnavailable)
+		return
+	}
+
+	user, apiErr := h.provision(identity)
+	if apiErr != nil {
+		WriteError(w, apiErr)
+		return
+	}
+	tokenPair, err := h.tokenManager.GenerateTokenPair(user.ID, user.Username, user.Role)
+	if err != nil {
+		WriteError(w, ErrInternalServer)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(AuthResponse{
+		User:      UserResponse{ID: user.ID, Username: user.Username},
+		TokenPair: tokenPair,
+	})
+}
+
+// authenticateLDAP finds the user's entry with the search account, then
+// checks their password by binding as them
+func (h *SSOHandler) authenticateLDAP(username, password string) (*ssoIdentity, error) {
+	conn, err := ldap.Dial(h.ldap.URL, nil, ldapTimeout)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	if h.ldap.StartTLS {
+		if err := conn.StartTLS(nil); err != nil {
+			return nil, err
+		}
+	}
+	if h.ldap.BindDN != "" {
+		if err := conn.Bind(h.ldap.BindDN, h.ldap.BindPassword); err != nil {
+			return nil, err
+		}
+	}
+
+	entries, err := conn.Search(ldap.SearchRequest{
+		BaseDN:     h.ldap.BaseDN,
+		Filter:     strings.ReplaceAll(h.ldap.UserFilter, "%s", ldap.EscapeFilter(username)),
+		Attributes: []string{h.ldap.UsernameAttribute, h.ldap.GroupAttribute, "entryUUID"},
+		SizeLimit:  2,
+	})
+	if err != nil {
+		return nil, err
+	}
+	// Ambiguous matches are refused rather than guessed at
+	if len(entries) != 1 {
+		return nil, errSSOInvalidCredentials
+	}
+	entry := entries[0]
+
+	if err := conn.Bind(entry.DN, password); err != nil {
+		if ldap.IsInvalidCredentials(err) {
+			return nil, errSSOInvalidCredentials
+		}
+		return nil, err
+	}
+
+	identity := &ssoIdentity{
+		provider: models.IdentityProviderLDAP,
+		subject:  entry.Get("entryUUID"),
+		username: entry.Get(h.ldap.UsernameAttribute),
+		groups:   entry.Values(h.ldap.GroupAttribute),
+	}
+	// Directories without entryUUID are keyed by DN, which changes on rename
+	if identity.subject == "" {
+		identity.subject = strings.ToLower(entry.DN)
+	}
+	if identity.username == "" {
+		identity.username = username
+	}
+	return identity, nil
+}
+
+// OIDCLogin handles starting a sign-in by sending the browser to the
+// provider
+func (h *SSOHandler) OIDCLogin(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		WriteError(w, &APIError{
+			Code:    "method_not_allowed",
+			Message: "Method not allowed",
+			Status:  http.StatusMethodNotAllowed,
+		})
+		return
+	}
+
+	state, err1 := oidc.NewState()
+	nonce, err2 := oidc.NewState()
+	verifier, err3 := oidc.NewPKCEVerifier()
+	if err := errors.Join(err1, err2, err3); err != nil {
+		WriteError(w, ErrInternalServer)
+		return
+	}
+
+	authURL, err := h.oidc.AuthCodeURL(r.Context(), state, nonce, verifier)
+	if err != nil {
+		log.Printf("OIDC sign-in failed: %v", err)
+		WriteError(w, ErrSSOUnavailable)
+		return
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     oidcCookie,
+		Value:    state + "." + nonce + "." + verifier,
+		Path:     "/api/auth/oidc",
+		MaxAge:   600,
+		HttpOnly: true,
+		Secure:   true,
+		SameSite: http.SameSiteLaxMode, // Sent on the provider's redirect back
+	})
+	http.Redirect(w, r, authURL, http.StatusFound)
+}
+
+// OIDCCallback handles the provider sending the browser back with an
+// authorization code
+func (h *SSOHandler) OIDCCallback(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		WriteError(w, &APIError{
+			Code:    "method_not_allowed",
+			Message: "Method not allowed",
+			Status:  http.StatusMethodNotAllowed,
+		})
+		return
+	}
+
+	// The sign-in attempt is over either way
+	http.SetCookie(w, &http.Cookie{Name: oidcCookie, Path: "/api/auth/oidc", MaxAge: -1, HttpOnly: true, Secure: true})
+
+	query := r.URL.Query()
+	if query.Get("error") != "" {
+		h.finishOIDC(w, r, url.Values{"error": {"access_denied"}})
+		return
+	}
+
+	cookie, err := r.Cookie(oidcCookie)
+	var parts []string
+	if err == nil {
+		parts = strings.Split(cookie.Value, ".")
+	}
+	if len(parts) != 3 || subtle.ConstantTimeCompare([]byte(parts[0]), []byte(query.Get("state"))) != 1 {
+		h.finishOIDC(w, r, url.Values{"error": {"invalid_state"}})
+		return
+	}
+
+	claims, err := h.oidc.Exchange(r.Context(), query.Get("code"), parts[2], parts[1])
+	if err != nil {
+		log.Printf("OIDC sign-in failed: %v", err)
+		h.finishOIDC(w, r, url.Values{"error": {"sso_failed"}})
+		return
+	}
+
+	user, apiErr := h.provision(&ssoIdentity{
+		provider: models.IdentityProviderOIDC,
+		subject:  claims.String("sub"),
+		username: claims.String(h.oidcUsernameClaim),
+		groups:   claims.Strings(h.oidcGroupsClaim),
+	})
+	if apiErr != nil {
+		h.finishOIDC(w, r, url.Values{"error": {apiErr.Code}})
+		return
+	}
+	tokenPair, err := h.tokenManager.GenerateTokenPair(user.ID, user.Username, user.Role)
+	if err != nil {
+		h.finishOIDC(w, r, url.Values{"error": {"internal_error"}})
+		return
+	}
+
+	h.finishOIDC(w, r, url.Values{
+		"access_token":  {tokenPair.AccessToken},
+		"refresh_token": {tokenPair.RefreshToken},
+		"expires_at":    {strconv.FormatInt(tokenPair.ExpiresAt, 10)},
+	})
+}
+
+// finishOIDC sends the browser to the frontend with the outcome in the URL
+// fragment, which is never sent to servers or in referrers
+func (h *SSOHandler) finishOIDC(w http.ResponseWriter, r *http.Request, fragment url.Values) {
+	w.Header().Set("Cache-Control", "no-store")
+	http.Redirect(w, r, h.postLoginURL+"#"+fragment.Encode(), http.StatusFound)
+}
+
+// provision maps the identity's groups to a role and returns its user,
+// creating it on first sign-in
+func (h *SSOHandler) provision(identity *ssoIdentity) (*models.User, *APIError) {
+	role, allowed := h.groups.role(identity.groups)
+	if !allowed {
+		return nil, &APIError{
+			Code:    "sso_not_allowed",
+			Message: "Your account is not in a group allowed to sign in",
+			Status:  http.StatusForbidden,
+		}
+	}
+
+	username := ssoUsername(identity.username)
+	if identity.subject == "" || h.validator.ValidateUsername(username) != nil {
+		return nil, &APIError{
+			Code:    "sso_invalid_username",
+			Message: "Your single sign-on account has no usable username",
+			Status:  http.StatusForbidden,
+		}
+	}
+
+	user, err := h.identities.Provision(identity.provider, identity.subject, username, role)
+	if errors.Is(err, models.ErrIdentityUsernameTaken) {
+		return nil, &APIError{
+			Code:    "username_exists",
+			Message: "Username already belongs to a local account",
+			Status:  http.StatusConflict,
+		}
+	}
+	if err != nil {
+		return nil, ErrInternalServer
+	}
+	if !user.IsActive() {
+		return nil, ErrAccountDeactivated
+	}
+	return user, nil
+}
+
+// ssoUsername turns a provider's username, which may be an email address or
+// contain dots, into a valid local username
+func ssoUsername(name string) string {
+	if local, _, ok := strings.Cut(name, "@"); ok {
+		name = local
+	}
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '_', r == '-':
+			return r
+		case r == '.' || r == ' ':
+			return '-'
+		}
+		return -1
+	}, name)
+}
@@ -0,0 +1,48 @@
+package handlers
+
+import (
+	"testing"
+
+	"github.com/LonleySailor/privatepaste/backend/internal/models"
+)
+
+func TestGroupRoles(t *testing.T) {
+	groups := GroupRoles{AdminGroups: []string{"paste-admins"}, AllowedGroups: []string{"Engineering"}}
+
+	tests := []struct {
+		name    string
+		groups  []string
+		role    string
+		allowed bool
+	}{
+		{"admin by DN", []string{"cn=engineering,ou=groups,dc=example,dc=org", "cn=paste-admins,ou=groups,dc=example,dc=org"}, models.RoleAdmin, true},
+		{"user by name", []string{"engineering"}, models.RoleUser, true},
+		{"not allowed", []string{"paste-admins"}, "", false},
+		{"no groups", nil, "", false},
+	}
+	for _, tt := range tests {
+		role, allowed := groups.role(tt.groups)
+		if role != tt.role || allowed != tt.allowed {
+			t.Errorf("%s: got role %q allowed %v, want %q %v", tt.name, role, allowed, tt.role, tt.allowed)
+		}
+	}
+
+	// Without admin groups roles are left alone, and anyone may sign in
+	if role, allowed := (GroupRoles{}).role(nil); role != "" || !allowed {
+		t.Errorf("Expected unmanaged roles, got %q %v", role, allowed)
+	}
+}
+
+func TestSSOUsername(t *testing.T) {
+	tests := map[string]string{
+		"alice":                "alice",
+		"alice.smith@corp.com": "alice-smith",
+		"Bob Jones":            "Bob-Jones",
+		"émile+x":              "milex",
+	}
+	for in, want := range tests {
+		if got := ssoUsername(in); got != want {
+			t.Errorf("ssoUsername(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
@@ -0,0 +1,66 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/LonleySailor/privatepaste/backend/internal/models"
+)
+
+// StatsHandler handles paste lifecycle statistics
+type StatsHandler struct {
+	eventRepo *models.PasteEventRepository
+}
+
+// NewStatsHandler creates a new stats handler
+func NewStatsHandler(eventRepo *models.PasteEventRepository) *StatsHandler {
+	return &StatsHandler{eventRepo: eventRepo}
+}
+
+// DailyStatsResponse represents per-day paste activity and its totals
+type DailyStatsResponse struct {
+	Days   []*models.DailyStats `json:"days"`
+	Totals models.DailyStats    `json:"totals"`
+}
+
+// GetDailyStats handles reporting paste activity per day from the rolled-up
+// event log. The optional days query parameter sets the range (default 30).
+func (h *StatsHandler) GetDailyStats(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		WriteError(w, &APIError{
+			Code:    "method_not_allowed",
+			Message: "Method not allowed",
+			Status:  http.StatusMethodNotAllowed,
+		})
+		return
+	}
+
+	days := 30
+	if d := r.URL.Query().Get("days"); d != "" {
+		if parsed, err := strconv.Atoi(d); err == nil && parsed > 0 && parsed <= 366 {
+			days = parsed
+		}
+	}
+
+	since := time.Now().UTC().AddDate(0, 0, -(days - 1))
+	stats, err := h.eventRepo.GetDailyStats(since)
+	if err != nil {
+		WriteError(w, ErrInternalServer)
+		return
+	}
+
+	response := DailyStatsResponse{Days: stats}
+	for _, d := range stats {
+		response.Totals.Created += d.Created
+		response.Totals.Expired += d.Expired
+		response.Totals.Deleted += d.Deleted
+		response.Totals.Burned += d.Burned
+		response.Totals.BytesCreated += d.BytesCreated
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
@@ -0,0 +1,98 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/LonleySailor/privatepaste/backend/internal/middleware"
+	"github.com/LonleySailor/privatepaste/backend/internal/models"
+)
+
+// StorageHandler handles storage usage reporting
+type StorageHandler struct {
+	storageRepo *models.StorageRepository
+}
+
+// NewStorageHandler creates a new storage handler
+func NewStorageHandler(storageRepo *models.StorageRepository) *StorageHandler {
+	return &StorageHandler{storageRepo: storageRepo}
+}
+
+// InstanceStorageResponse represents instance-wide storage usage for admins
+type InstanceStorageResponse struct {
+	Total     *models.StorageUsage   `json:"total"`
+	Anonymous *models.StorageUsage   `json:"anonymous"`
+	TopUsers  []*models.StorageUsage `json:"top_users"`
+}
+
+// GetUserStorage handles reporting the authenticated user's storage usage
+func (h *StorageHandler) GetUserStorage(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		WriteError(w, &APIError{
+			Code:    "method_not_allowed",
+			Message: "Method not allowed",
+			Status:  http.StatusMethodNotAllowed,
+		})
+		return
+	}
+
+	userID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		WriteError(w, &APIError{
+			Code:    "unauthorized",
+			Message: "User ID not found in token",
+			Status:  http.StatusUnauthorized,
+		})
+		return
+	}
+
+	usage, err := h.storageRepo.GetUserUsage(userID)
+	if err != nil {
+		WriteError(w, ErrInternalServer)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(usage)
+}
+
+// GetInstanceStorage handles reporting instance-wide storage usage. The
+// optional limit query parameter sets how many top users are listed.
+func (h *StorageHandler) GetInstanceStorage(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		WriteError(w, &APIError{
+			Code:    "method_not_allowed",
+			Message: "Method not allowed",
+			Status:  http.StatusMethodNotAllowed,
+		})
+		return
+	}
+
+	limit := 10
+	if l := r.URL.Query().Get("limit"); l != "" {
+		if parsed, err := strconv.Atoi(l); err == nil && parsed > 0 && parsed <= 100 {
+			limit = parsed
+		}
+	}
+
+	var response InstanceStorageResponse
+	var err error
+	if response.Total, err = h.storageRepo.GetInstanceUsage(); err != nil {
+		WriteError(w, ErrInternalServer)
+		return
+	}
+	if response.Anonymous, err = h.storageRepo.GetAnonymousUsage(); err != nil {
+		WriteError(w, ErrInternalServer)
+		return
+	}
+	if response.TopUsers, err = h.storageRepo.TopUsers(limit); err != nil {
+		WriteError(w, ErrInternalServer)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
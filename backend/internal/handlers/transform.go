@@ -0,0 +1,194 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/LonleySailor/privatepaste/backend/internal/middleware"
+	"github.com/LonleySailor/privatepaste/backend/internal/models"
+	"github.com/LonleySailor/privatepaste/backend/internal/services"
+	"github.com/LonleySailor/privatepaste/backend/pkg/transform"
+	"github.com/LonleySailor/privatepaste/backend/pkg/validation"
+)
+
+// TransformPasteRequest represents a request to derive a paste through a transform pipeline
+type TransformPasteRequest struct {
+	Operations []string `json:"operations"`
+}
+
+// TransformOperationInfo describes an available transform operation
+type TransformOperationInfo struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+// ListTransforms handles listing the available transform operations
+func (h *PasteHandler) ListTransforms(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		WriteError(w, &APIError{
+			Code:    "method_not_allowed",
+			Message: "Method not allowed",
+			Status:  http.StatusMethodNotAllowed,
+		})
+		return
+	}
+
+	ops := transform.Operations()
+	response := make([]TransformOperationInfo, len(ops))
+	for i, op := range ops {
+		response[i] = TransformOperationInfo{Name: op.Name, Description: op.Description}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"operations": response,
+	})
+}
+
+// Transform handles running a paste through a transform pipeline and saving
+// the output as a new paste linked to the source
+func (h *PasteHandler) Transform(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		WriteError(w, &APIError{
+			Code:    "method_not_allowed",
+			Message: "Method not allowed",
+			Status:  http.StatusMethodNotAllowed,
+		})
+		return
+	}
+
+	source, ok := h.loadViewablePaste(w, r)
+	if !ok || !checkDerivation(w, r, source) {
+		return
+	}
+
+	var req TransformPasteRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		WriteError(w, ErrInvalidJSON)
+		return
+	}
+
+	var errors validation.ValidationErrors
+	if len(req.Operations) == 0 {
+		errors.Add("operations", "at least one operation is required")
+	} else if len(req.Operations) > transform.MaxOperations {
+		errors.Add("operations", "too many operations")
+	}
+	for _, name := range req.Operations {
+		if _, ok := transform.Lookup(name); !ok {
+			errors.Add("operations", "unknown operation: "+name)
+		}
+	}
+	if errors.HasErrors() {
+		WriteValidationError(w, errors)
+		return
+	}
+
+	result, err := transform.Run(source.Content, req.Operations)
+	if err != nil {
+		WriteError(w, &APIError{
+			Code:    "transform_failed",
+			Message: err.Error(),
+			Status:  http.StatusUnprocessableEntity,
+		})
+		return
+	}
+
+	if result.Content == "" {
+		WriteError(w, &APIError{
+			Code:    "transform_failed",
+			Message: "Transform produced empty content",
+			Status:  http.StatusUnprocessableEntity,
+		})
+		return
+	}
+
+	paste, ok := h.createDerivedPaste(w, r, source, result.Content, result.Language)
+	if !ok {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(newCreatePasteResponse(paste))
+}
+
+// checkDerivation refuses deriving a paste whose signed-URL, access window
+// or location restrictions would stop binding a copy owned by someone else:
+// its owner could sign URLs for the copy and serve it outside them. Only the
+// source's owner may derive such pastes. On refusal it writes the error
+// response and returns false.
+func checkDerivation(w http.ResponseWriter, r *http.Request, source *models.Paste) bool {
+	restricted := source.RequireSignedURLs || source.AccessWindow != nil || source.IsLocationRestricted()
+	if !restricted || ownsPaste(r, source) {
+		return true
+	}
+	WriteError(w, &APIError{
+		Code:    "forbidden",
+		Message: "Only the paste's owner can derive new pastes from it",
+		Status:  http.StatusForbidden,
+	})
+	return false
+}
+
+// createDerivedPaste saves content derived from source as a new paste linked to
+// it. The derived paste keeps the source's password, expiry, privacy and
+// visibility settings, and is held to the requester's content policy. It is
+// owned by the requester, so callers check the requester may derive the
+// source with checkDerivation first. An empty language keeps the source
+// language. On failure it writes the error response and returns false.
+func (h *PasteHandler) createDerivedPaste(w http.ResponseWriter, r *http.Request, source *models.Paste, content, language string) (*models.Paste, bool) {
+	// Check content size (1MB limit)
+	if len(content) > maxPasteSize {
+		WriteError(w, ErrContentTooLarge)
+		return nil, false
+	}
+
+	id, err := h.generateID(source.Visibility)
+	if err != nil {
+		WriteError(w, ErrIDGenerationFailed)
+		return nil, false
+	}
+
+	paste := &models.Paste{
+		ID:           id,
+		Content:      content,
+		Language:     source.Language,
+		ExpiresAt:    source.ExpiresAt,
+		PasswordHash: source.PasswordHash,
+		PasswordHint: source.PasswordHint,
+		DoNotTrack:   source.DoNotTrack,
+		NoIndex:      source.NoIndex,
+		Kind:         models.KindText,
+		Visibility:   source.Visibility,
+		Theme:        source.Theme,
+		LineNumbers:  source.LineNumbers,
+		WordWrap:     source.WordWrap,
+		ParentID:     &source.ID,
+
+		RequireSignedURLs: source.RequireSignedURLs,
+		MaxUnlockAttempts: source.MaxUnlockAttempts,
+		AccessWindow:      source.AccessWindow,
+		AllowedNetworks:   source.AllowedNetworks,
+		AllowedCountries:  source.AllowedCountries,
+		EmbedDomains:      source.EmbedDomains,
+		Watermark:         source.Watermark,
+	}
+	if language != "" {
+		paste.Language = language
+	}
+
+	// Handle user association if authenticated
+	if userID, ok := middleware.GetUserIDFromContext(r.Context()); ok {
+		paste.UserID = &userID
+	}
+
+	// The source's expiry was held to the policy when it was created
+	if err := h.creator.CreateFromRequest(r, paste, services.PasteAttributes{ExpiryKept: true}); err != nil {
+		writePolicyViolation(w, err)
+		return nil, false
+	}
+
+	return paste, true
+}
@@ -0,0 +1,140 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/LonleySailor/privatepaste/backend/internal/models"
+	"github.com/LonleySailor/privatepaste/backend/pkg/s3sig"
+	"github.com/LonleySailor/privatepaste/backend/pkg/sandbox"
+	"github.com/LonleySailor/privatepaste/backend/pkg/utils"
+	"github.com/LonleySailor/privatepaste/backend/pkg/validation"
+	"github.com/gorilla/mux"
+)
+
+// postTransform runs the transform handler against a paste
+func postTransform(handler *PasteHandler, id string, req TransformPasteRequest) *httptest.ResponseRecorder {
+	body, _ := json.Marshal(req)
+	r := httptest.NewRequest("POST", "/api/paste/"+id+"/transform", bytes.NewBuffer(body))
+	r = mux.SetURLVars(r, map[string]string{"id": id})
+
+	rr := httptest.NewRecorder()
+	handler.Transform(rr, r)
+	return rr
+}
+
+func TestTransform(t *testing.T) {
+	handler, mockRepo := setupTestHandler()
+
+	id := createTestPaste(t, handler, CreatePasteRequest{Content: "eyJhIjoxfQ==", DoNotTrack: true})
+
+	rr := postTransform(handler, id, TransformPasteRequest{Operations: []string{"base64-decode", "json-pretty"}})
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusCreated, rr.Code, rr.Body.String())
+	}
+
+	var response CreatePasteResponse
+	if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Failed to parse response: %v", err)
+	}
+
+	derived, _ := mockRepo.GetByID(response.ID)
+	if derived == nil || derived.Content != "{\n  \"a\": 1\n}" || derived.Language != "json" {
+		t.Fatalf("Unexpected derived paste: %+v", derived)
+	}
+	if !derived.DoNotTrack || derived.ExpiresAt == nil {
+		t.Error("Expected derived paste to inherit privacy and expiry settings")
+	}
+}
+
+func TestTransform_InvalidOperations(t *testing.T) {
+	handler, _ := setupTestHandler()
+
+	id := createTestPaste(t, handler, CreatePasteRequest{Content: "plain text"})
+
+	if rr := postTransform(handler, id, TransformPasteRequest{Operations: []string{"rot13"}}); rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected status %d for unknown operation, got %d", http.StatusBadRequest, rr.Code)
+	}
+	if rr := postTransform(handler, id, TransformPasteRequest{Operations: []string{"json-pretty"}}); rr.Code != http.StatusUnprocessableEntity {
+		t.Errorf("Expected status %d for failed transform, got %d", http.StatusUnprocessableEntity, rr.Code)
+	}
+}
+
+func TestTransform_LinksParent(t *testing.T) {
+	handler, mockRepo := setupTestHandler()
+
+	id := createTestPaste(t, handler, CreatePasteRequest{Content: "a%20b"})
+
+	rr := postTransform(handler, id, TransformPasteRequest{Operations: []string{"url-decode"}})
+	var response CreatePasteResponse
+	json.Unmarshal(rr.Body.Bytes(), &response)
+
+	derived, _ := mockRepo.GetByID(response.ID)
+	if derived == nil || derived.ParentID == nil || *derived.ParentID != id {
+		t.Errorf("Expected derived paste to link to %s, got %+v", id, derived)
+	}
+}
+
+func TestTransform_RestrictedSource(t *testing.T) {
+	runner := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("Expected a refused run not to reach the sandbox")
+	}))
+	defer runner.Close()
+
+	handler, repo := setupTestHandler()
+	handler.SetURLSigner(utils.NewURLSigner("test-secret"))
+	handler.SetSandbox(sandbox.NewClient(runner.URL))
+	aliceID, bobID := 7, 8
+	// The test client's address is in the allowed network, so bob can view it
+	repo.Create(&models.Paste{ID: "rst123", Content: "a%20b", Language: "python", UserID: &aliceID,
+		Visibility: models.VisibilityPublic, AllowedNetworks: []string{"192.0.2.0/24"}})
+
+	derive := func(userID int, path string, run http.HandlerFunc, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/api/paste/rst123/"+path, bytes.NewBufferString(body))
+		req = mux.SetURLVars(req, map[string]string{"id": "rst123"})
+		req = req.WithContext(context.WithValue(req.Context(), "userID", userID))
+		rr := httptest.NewRecorder()
+		run(rr, req)
+		return rr
+	}
+
+	if rr := derive(bobID, "transform", handler.Transform, `{"operations":["url-decode"]}`); rr.Code != http.StatusForbidden {
+		t.Errorf("Expected another user's transform to be refused, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if rr := derive(bobID, "run", handler.Run, `{}`); rr.Code != http.StatusForbidden {
+		t.Errorf("Expected another user's run to be refused, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	// With no copy of their own, bob can neither sign nor presign the content
+	var bobsCopy *models.Paste
+	pastes, _ := repo.GetByUserID(bobID, 100, 0)
+	for _, paste := range pastes {
+		if paste.ParentID != nil && *paste.ParentID == "rst123" {
+			bobsCopy = paste
+		}
+	}
+	if bobsCopy != nil {
+		t.Fatalf("Expected bob to own no copy of the restricted paste, got %+v", bobsCopy)
+	}
+	if rr := signURL(handler, "rst123", "", bobID); rr.Code != http.StatusForbidden {
+		t.Errorf("Expected bob not to sign the restricted paste, got %d", rr.Code)
+	}
+	s3Handler := NewS3Handler(stubUsers{"bob": {ID: bobID, Username: "bob"}}, repo, validation.NewValidator(), "s3-secret")
+	router := mux.NewRouter()
+	router.HandleFunc("/s3/{bucket}/{key}", s3Handler.GetObject)
+	presigned, _ := s3sig.Presign("http://example.com/s3/bob/rst123", "bob", s3Handler.secretKey(bobID), s3Region, time.Now(), time.Hour)
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest("GET", presigned, nil))
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("Expected bob's presigned URL not to serve the restricted paste, got %d", rr.Code)
+	}
+
+	if rr := derive(aliceID, "transform", handler.Transform, `{"operations":["url-decode"]}`); rr.Code != http.StatusCreated {
+		t.Errorf("Expected the owner to derive the paste, got %d: %s", rr.Code, rr.Body.String())
+	}
+}
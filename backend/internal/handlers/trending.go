@@ -0,0 +1,69 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/LonleySailor/privatepaste/backend/internal/models"
+)
+
+// TrendingHandler serves the trending pastes ranking for the discover page
+type TrendingHandler struct {
+	trending *models.TrendingRepository
+}
+
+// NewTrendingHandler creates a new trending handler
+func NewTrendingHandler(trending *models.TrendingRepository) *TrendingHandler {
+	return &TrendingHandler{trending: trending}
+}
+
+// TrendingItem represents a trending paste with its author and recent views
+type TrendingItem struct {
+	PasteListItem
+	Username string `json:"username,omitempty"` // Empty for anonymous pastes
+	Views    int64  `json:"views"`              // Views over the past week
+}
+
+// TrendingResponse lists trending pastes, highest ranked first
+type TrendingResponse struct {
+	Pastes []TrendingItem `json:"pastes"`
+}
+
+// List handles listing trending public pastes. The ranking is recomputed
+// periodically by a background job, so responses are publicly cacheable.
+// Supports a limit query parameter of up to 100.
+func (h *TrendingHandler) List(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		WriteError(w, &APIError{
+			Code:    "method_not_allowed",
+			Message: "Method not allowed",
+			Status:  http.StatusMethodNotAllowed,
+		})
+		return
+	}
+
+	limit := 20
+	if l, err := strconv.Atoi(r.URL.Query().Get("limit")); err == nil && l > 0 && l <= 100 {
+		limit = l
+	}
+
+	pastes, err := h.trending.List(limit)
+	if err != nil {
+		WriteRepositoryError(w, err)
+		return
+	}
+
+	items := make([]TrendingItem, len(pastes))
+	for i, paste := range pastes {
+		items[i] = TrendingItem{
+			PasteListItem: newPasteListItem(paste.Paste, false),
+			Username:      paste.Username,
+			Views:         paste.Views,
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(TrendingResponse{Pastes: items})
+}
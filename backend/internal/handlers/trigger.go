@@ -0,0 +1,156 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/LonleySailor/privatepaste/backend/internal/auth"
+	"github.com/LonleySailor/privatepaste/backend/internal/models"
+	"github.com/LonleySailor/privatepaste/backend/pkg/validation"
+)
+
+// Trigger page sizes
+const (
+	defaultTriggerPageSize = 50
+	maxTriggerPageSize     = 100
+)
+
+// TriggerSource lists events on a user's pastes after a cursor
+type TriggerSource interface {
+	NewPastes(userID int, after int64, limit int) ([]*models.TriggerEvent, error)
+	PasteViews(userID int, after int64, limit int) ([]*models.TriggerEvent, error)
+}
+
+// TriggerHandler serves polling triggers for no-code automation platforms
+// such as Zapier and IFTTT, which cannot receive webhooks from behind a
+// login. Requests are authenticated with an API key as the bearer token,
+// which must allow paste:read for anything but the connection test.
+//
+// Example recipes:
+//   - Zapier: a "New Paste" polling trigger on GET /api/triggers/new-paste
+//     feeding "Send Channel Message" in Slack posts each new paste's URL.
+//   - IFTTT: "If paste viewed" on GET /api/triggers/paste-viewed, then
+//     "Send me a notification", alerts you when a shared secret was opened.
+//   - Any platform: GET /api/triggers/me as the connection test.
+//
+// Items come newest first with a stable id for deduplication. Clients that
+// keep state pass the returned cursor as after to page forward without
+// missing events.
+type TriggerHandler struct {
+	triggers TriggerSource
+	keys     APIKeyAuthenticator
+}
+
+// NewTriggerHandler creates a new trigger handler
+func NewTriggerHandler(triggers TriggerSource, keys APIKeyAuthenticator) *TriggerHandler {
+	return &TriggerHandler{triggers: triggers, keys: keys}
+}
+
+// TriggerItem is one event returned by a polling trigger
+type TriggerItem struct {
+	ID         string `json:"id"` // Unique per trigger, for deduplication
+	PasteID    string `json:"paste_id"`
+	URL        string `json:"url"`
+	Language   string `json:"language,omitempty"`
+	Visibility string `json:"visibility"`
+	OccurredAt string `json:"occurred_at"`
+}
+
+// TriggerResponse is a page of trigger events, newest first
+type TriggerResponse struct {
+	Data   []TriggerItem `json:"data"`
+	Cursor string        `json:"cursor,omitempty"` // Pass as after to get later events
+}
+
+// Me handles the connection test automation platforms run when an API key
+// is added, returning the key's name
+func (h *TriggerHandler) Me(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		WriteError(w, &APIError{
+			Code:    "method_not_allowed",
+			Message: "Method not allowed",
+			Status:  http.StatusMethodNotAllowed,
+		})
+		return
+	}
+
+	apiKey, ok := authenticateAPIKey(w, h.keys, bearerAPIKey(r), "")
+	if !ok {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{"key_name": apiKey.Name})
+}
+
+// NewPaste handles the trigger fired by each new paste of the key's owner
+func (h *TriggerHandler) NewPaste(w http.ResponseWriter, r *http.Request) {
+	h.serveTrigger(w, r, h.triggers.NewPastes)
+}
+
+// PasteViewed handles the trigger fired by each view of the key's owner's
+// pastes. Views of pastes with do_not_track are never reported.
+func (h *TriggerHandler) PasteViewed(w http.ResponseWriter, r *http.Request) {
+	h.serveTrigger(w, r, h.triggers.PasteViews)
+}
+
+// serveTrigger writes a page of events from list for the authenticated key's
+// owner, honoring the after and limit query parameters
+func (h *TriggerHandler) serveTrigger(w http.ResponseWriter, r *http.Request, list func(userID int, after int64, limit int) ([]*models.TriggerEvent, error)) {
+	if r.Method != http.MethodGet {
+		WriteError(w, &APIError{
+			Code:    "method_not_allowed",
+			Message: "Method not allowed",
+			Status:  http.StatusMethodNotAllowed,
+		})
+		return
+	}
+
+	apiKey, ok := authenticateAPIKey(w, h.keys, bearerAPIKey(r), auth.ScopePasteRead)
+	if !ok {
+		return
+	}
+
+	query := r.URL.Query()
+	var after int64
+	if a := query.Get("after"); a != "" {
+		var err error
+		if after, err = strconv.ParseInt(a, 10, 64); err != nil || after < 0 {
+			WriteValidationError(w, []validation.ValidationError{{Field: "after", Message: "must be a cursor returned by this trigger"}})
+			return
+		}
+	}
+	limit := defaultTriggerPageSize
+	if l, err := strconv.Atoi(query.Get("limit")); err == nil && l > 0 && l <= maxTriggerPageSize {
+		limit = l
+	}
+
+	events, err := list(apiKey.UserID, after, limit)
+	if err != nil {
+		WriteRepositoryError(w, err)
+		return
+	}
+
+	response := TriggerResponse{Data: []TriggerItem{}, Cursor: query.Get("after")}
+	for _, event := range events {
+		response.Data = append(response.Data, TriggerItem{
+			ID:         strconv.FormatInt(event.ID, 10),
+			PasteID:    event.PasteID,
+			URL:        PasteURL(event.PasteID),
+			Language:   event.Language,
+			Visibility: event.Visibility,
+			OccurredAt: event.At.UTC().Format(time.RFC3339),
+		})
+	}
+	if len(events) > 0 {
+		response.Cursor = strconv.FormatInt(events[0].ID, 10)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
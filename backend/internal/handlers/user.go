@@ -3,6 +3,8 @@ package handlers
 import (
 	"encoding/json"
 	"net/http"
+	"strings"
+	"time"
 
 	"github.com/LonleySailor/privatepaste/backend/internal/auth"
 	"github.com/LonleySailor/privatepaste/backend/internal/middleware"
@@ -16,6 +18,9 @@ type UserHandler struct {
 	userRepo     *models.UserRepository
 	tokenManager *auth.TokenManager
 	validator    *validation.Validator
+
+	renameCooldown time.Duration
+	avatars        AvatarLookup
 }
 
 // NewUserHandler creates a new user handler
@@ -27,6 +32,17 @@ func NewUserHandler(userRepo *models.UserRepository, tokenManager *auth.TokenMan
 	}
 }
 
+// SetRenameCooldown sets how long a renamed user's old name stays reserved
+// for them. Zero, the default, releases it at once.
+func (h *UserHandler) SetRenameCooldown(cooldown time.Duration) {
+	h.renameCooldown = cooldown
+}
+
+// SetAvatars enables showing avatars on profiles
+func (h *UserHandler) SetAvatars(avatars AvatarLookup) {
+	h.avatars = avatars
+}
+
 // RegisterRequest represents a user registration request
 type RegisterRequest struct {
 	Username string `json:"username"`
@@ -44,10 +60,18 @@ type RefreshTokenRequest struct {
 	RefreshToken string `json:"refresh_token"`
 }
 
+// ChangeUsernameRequest represents a request to rename the current user.
+// The password confirms it is the account holder.
+type ChangeUsernameRequest struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
 // UserResponse represents a user response (without sensitive data)
 type UserResponse struct {
-	ID       int    `json:"id"`
-	Username string `json:"username"`
+	ID        int    `json:"id"`
+	Username  string `json:"username"`
+	AvatarURL string `json:"avatar_url,omitempty"`
 }
 
 // AuthResponse represents an authentication response
@@ -93,6 +117,9 @@ func (h *UserHandler) Register(w http.ResponseWriter, r *http.Request) {
 		})
 		return
 	}
+	if !h.checkUsernameHold(w, req.Username, 0) {
+		return
+	}
 
 	// Hash password with cost factor 14 as specified in Phase 4
 	hashedPassword, err := utils.HashPasswordWithCost(req.Password, 14)
@@ -113,7 +140,7 @@ func (h *UserHandler) Register(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Generate tokens
-	tokenPair, err := h.tokenManager.GenerateTokenPair(user.ID, user.Username)
+	tokenPair, err := h.tokenManager.GenerateTokenPair(user.ID, user.Username, user.Role)
 	if err != nil {
 		WriteError(w, ErrInternalServer)
 		return
@@ -186,8 +213,13 @@ func (h *UserHandler) Login(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if !user.IsActive() {
+		WriteError(w, ErrAccountDeactivated)
+		return
+	}
+
 	// Generate tokens
-	tokenPair, err := h.tokenManager.GenerateTokenPair(user.ID, user.Username)
+	tokenPair, err := h.tokenManager.GenerateTokenPair(user.ID, user.Username, user.Role)
 	if err != nil {
 		WriteError(w, ErrInternalServer)
 		return
@@ -260,8 +292,13 @@ func (h *UserHandler) RefreshToken(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if !user.IsActive() {
+		WriteError(w, ErrAccountDeactivated)
+		return
+	}
+
 	// Generate new token pair
-	tokenPair, err := h.tokenManager.GenerateTokenPair(user.ID, user.Username)
+	tokenPair, err := h.tokenManager.GenerateTokenPair(user.ID, user.Username, user.Role)
 	if err != nil {
 		WriteError(w, ErrInternalServer)
 		return
@@ -334,8 +371,139 @@ func (h *UserHandler) GetProfile(w http.ResponseWriter, r *http.Request) {
 		ID:       user.ID,
 		Username: user.Username,
 	}
+	if h.avatars != nil {
+		hash, err := h.avatars.GetHash(user.ID)
+		if err != nil {
+			WriteError(w, ErrInternalServer)
+			return
+		}
+		response.AvatarURL = AvatarURL(hash)
+	}
 
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
 	json.NewEncoder(w).Encode(response)
 }
+
+// ChangeUsername handles renaming the current user. Usernames are part of
+// issued tokens, so a new token pair is returned.
+func (h *UserHandler) ChangeUsername(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut {
+		WriteError(w, &APIError{
+			Code:    "method_not_allowed",
+			Message: "Method not allowed",
+			Status:  http.StatusMethodNotAllowed,
+		})
+		return
+	}
+
+	userID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		WriteError(w, &APIError{
+			Code:    "unauthorized",
+			Message: "User ID not found in token",
+			Status:  http.StatusUnauthorized,
+		})
+		return
+	}
+
+	var req ChangeUsernameRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		WriteError(w, ErrInvalidJSON)
+		return
+	}
+	if err := h.validator.ValidateNewUsername(req.Username); err != nil {
+		WriteValidationError(w, []validation.ValidationError{*err})
+		return
+	}
+
+	user, err := h.userRepo.GetByID(userID)
+	if err != nil {
+		WriteError(w, ErrInternalServer)
+		return
+	}
+	if user == nil {
+		WriteError(w, &APIError{
+			Code:    "user_not_found",
+			Message: "User not found",
+			Status:  http.StatusNotFound,
+		})
+		return
+	}
+	if !user.HasPassword() {
+		WriteError(w, &APIError{
+			Code:    "username_managed",
+			Message: "This account's username is managed by its identity provider",
+			Status:  http.StatusForbidden,
+		})
+		return
+	}
+	if err := utils.VerifyPassword(req.Password, user.PasswordHash); err != nil {
+		WriteError(w, &APIError{
+			Code:    "invalid_credentials",
+			Message: "Invalid password",
+			Status:  http.StatusUnauthorized,
+		})
+		return
+	}
+
+	// Changing only the case of one's own name is allowed
+	if !strings.EqualFold(req.Username, user.Username) {
+		exists, err := h.userRepo.Exists(req.Username)
+		if err != nil {
+			WriteError(w, ErrInternalServer)
+			return
+		}
+		if exists {
+			WriteError(w, &APIError{
+				Code:    "username_exists",
+				Message: "Username already exists",
+				Status:  http.StatusConflict,
+			})
+			return
+		}
+		if !h.checkUsernameHold(w, req.Username, user.ID) {
+			return
+		}
+	}
+
+	if err := h.userRepo.Rename(user, req.Username, h.renameCooldown); err != nil {
+		WriteError(w, ErrInternalServer)
+		return
+	}
+
+	tokenPair, err := h.tokenManager.GenerateTokenPair(user.ID, user.Username, user.Role)
+	if err != nil {
+		WriteError(w, ErrInternalServer)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(AuthResponse{
+		User: UserResponse{
+			ID:       user.ID,
+			Username: user.Username,
+		},
+		TokenPair: tokenPair,
+	})
+}
+
+// checkUsernameHold writes an error and returns false if the username was
+// recently given up by someone other than userID and is still held for them
+func (h *UserHandler) checkUsernameHold(w http.ResponseWriter, username string, userID int) bool {
+	held, err := h.userRepo.IsHeld(username, userID)
+	if err != nil {
+		WriteError(w, ErrInternalServer)
+		return false
+	}
+	if held {
+		WriteError(w, &APIError{
+			Code:    "username_held",
+			Message: "Username was recently released and is not yet available",
+			Status:  http.StatusConflict,
+		})
+		return false
+	}
+	return true
+}
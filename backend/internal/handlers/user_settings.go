@@ -0,0 +1,123 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/LonleySailor/privatepaste/backend/internal/middleware"
+	"github.com/LonleySailor/privatepaste/backend/internal/models"
+	"github.com/LonleySailor/privatepaste/backend/pkg/validation"
+)
+
+// UserSettingsStore stores users' defaults for new pastes
+type UserSettingsStore interface {
+	Get(userID int) (*models.UserSettings, error)
+	Set(settings *models.UserSettings) error
+}
+
+// UserSettingsHandler handles users' settings
+type UserSettingsHandler struct {
+	settings  UserSettingsStore
+	validator *validation.Validator
+	presets   ExpiryPresets
+}
+
+// NewUserSettingsHandler creates a new user settings handler
+func NewUserSettingsHandler(settings UserSettingsStore, validator *validation.Validator) *UserSettingsHandler {
+	return &UserSettingsHandler{settings: settings, validator: validator}
+}
+
+// SetExpiryPresets limits default expiries to the instance's presets when
+// they are enforced
+func (h *UserSettingsHandler) SetExpiryPresets(presets ExpiryPresets) {
+	h.presets = presets
+}
+
+// Get handles returning the authenticated user's settings
+func (h *UserSettingsHandler) Get(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		WriteError(w, &APIError{
+			Code:    "method_not_allowed",
+			Message: "Method not allowed",
+			Status:  http.StatusMethodNotAllowed,
+		})
+		return
+	}
+
+	userID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		WriteError(w, &APIError{
+			Code:    "unauthorized",
+			Message: "User ID not found in token",
+			Status:  http.StatusUnauthorized,
+		})
+		return
+	}
+
+	settings, err := h.settings.Get(userID)
+	if err != nil {
+		WriteRepositoryError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(settings)
+}
+
+// Update handles replacing the authenticated user's settings. Defaults are
+// validated like the paste options they stand in for.
+func (h *UserSettingsHandler) Update(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut {
+		WriteError(w, &APIError{
+			Code:    "method_not_allowed",
+			Message: "Method not allowed",
+			Status:  http.StatusMethodNotAllowed,
+		})
+		return
+	}
+
+	userID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		WriteError(w, &APIError{
+			Code:    "unauthorized",
+			Message: "User ID not found in token",
+			Status:  http.StatusUnauthorized,
+		})
+		return
+	}
+
+	var settings models.UserSettings
+	if err := json.NewDecoder(r.Body).Decode(&settings); err != nil {
+		WriteError(w, ErrInvalidJSON)
+		return
+	}
+	settings.UserID = userID
+
+	var errors validation.ValidationErrors
+	if _, err := h.validator.ValidateExpiryDuration(settings.DefaultExpiry); err != nil {
+		errors.Add("default_expiry", err.Message)
+	} else if settings.DefaultExpiry != "" && !h.presets.Allows(settings.DefaultExpiry) {
+		errors.Add("default_expiry", "must be one of: "+strings.Join(h.presets.Presets, ", "))
+	}
+	if err := h.validator.ValidateVisibility(settings.DefaultVisibility); err != nil {
+		errors.Add("default_visibility", err.Message)
+	}
+	if err := h.validator.ValidateLanguage(settings.DefaultLanguage); err != nil {
+		errors.Add("default_language", err.Message)
+	}
+	if errors.HasErrors() {
+		WriteValidationError(w, errors)
+		return
+	}
+
+	if err := h.settings.Set(&settings); err != nil {
+		WriteRepositoryError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(settings)
+}
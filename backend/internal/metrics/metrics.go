@@ -0,0 +1,333 @@
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// metric is implemented by every collector that can be exposed
+type metric interface {
+	name() string
+	write(w io.Writer)
+}
+
+// Registry holds metrics and renders them in the Prometheus text format
+type Registry struct {
+	mu      sync.RWMutex
+	metrics map[string]metric
+}
+
+// NewRegistry creates an empty registry
+func NewRegistry() *Registry {
+	return &Registry{metrics: make(map[string]metric)}
+}
+
+// Default is the registry used by the package-level constructors and Handler
+var Default = NewRegistry()
+
+// register adds m to the registry, panicking on duplicate names like other
+// metric libraries do, since that is always a programming error
+func (r *Registry) register(m metric) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, exists := r.metrics[m.name()]; exists {
+		panic("metrics: duplicate metric " + m.name())
+	}
+	r.metrics[m.name()] = m
+}
+
+// Write writes every metric in the Prometheus text exposition format
+func (r *Registry) Write(w io.Writer) {
+	r.mu.RLock()
+	names := make([]string, 0, len(r.metrics))
+	for name := range r.metrics {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	ms := make([]metric, len(names))
+	for i, name := range names {
+		ms[i] = r.metrics[name]
+	}
+	r.mu.RUnlock()
+
+	for _, m := range ms {
+		m.write(w)
+	}
+}
+
+// Handler serves the Default registry
+func Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		Default.Write(w)
+	})
+}
+
+// series stores values keyed by their label values
+type series struct {
+	metricName string
+	help       string
+	kind       string
+	labelNames []string
+
+	mu     sync.Mutex
+	values map[string]float64
+	labels map[string][]string
+}
+
+func newSeries(name, help, kind string, labelNames []string) *series {
+	return &series{
+		metricName: name,
+		help:       help,
+		kind:       kind,
+		labelNames: labelNames,
+		values:     make(map[string]float64),
+		labels:     make(map[string][]string),
+	}
+}
+
+func (s *series) name() string { return s.metricName }
+
+// update applies fn to the value identified by labelValues
+func (s *series) update(labelValues []string, fn func(float64) float64) {
+	if len(labelValues) != len(s.labelNames) {
+		panic(fmt.Sprintf("metrics: %s expects %d label values, got %d", s.metricName, len(s.labelNames), len(labelValues)))
+	}
+	key := strings.Join(labelValues, "\xff")
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.labels[key]; !ok {
+		s.labels[key] = append([]string(nil), labelValues...)
+	}
+	s.values[key] = fn(s.values[key])
+}
+
+// Value returns the current value for the given label values
+func (s *series) Value(labelValues ...string) float64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.values[strings.Join(labelValues, "\xff")]
+}
+
+func (s *series) write(w io.Writer) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", s.metricName, s.help, s.metricName, s.kind)
+	if len(s.labelNames) == 0 && len(s.values) == 0 {
+		fmt.Fprintf(w, "%s 0\n", s.metricName)
+		return
+	}
+
+	keys := make([]string, 0, len(s.values))
+	for key := range s.values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		fmt.Fprintf(w, "%s%s %s\n", s.metricName, formatLabels(s.labelNames, s.labels[key]), formatValue(s.values[key]))
+	}
+}
+
+// formatLabels renders a {name="value",...} label set
+func formatLabels(names, values []string) string {
+	if len(names) == 0 {
+		return ""
+	}
+	parts := make([]string, len(names))
+	for i, name := range names {
+		parts[i] = name + `="` + escapeLabel(values[i]) + `"`
+	}
+	return "{" + strings.Join(parts, ",") + "}"
+}
+
+// escapeLabel escapes a label value for the text format
+func escapeLabel(v string) string {
+	v = strings.ReplaceAll(v, `\`, `\\`)
+	v = strings.ReplaceAll(v, "\n", `\n`)
+	return strings.ReplaceAll(v, `"`, `\"`)
+}
+
+// formatValue renders a sample value
+func formatValue(v float64) string {
+	if math.IsInf(v, 1) {
+		return "+Inf"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// Counter is a monotonically increasing metric, optionally labelled
+type Counter struct {
+	*series
+}
+
+// NewCounter creates and registers a counter on the Default registry
+func NewCounter(name, help string, labelNames ...string) *Counter {
+	c := &Counter{newSeries(name, help, "counter", labelNames)}
+	Default.register(c)
+	return c
+}
+
+// Inc increments the counter for the given label values
+func (c *Counter) Inc(labelValues ...string) {
+	c.Add(1, labelValues...)
+}
+
+// Add increases the counter by v, which must not be negative
+func (c *Counter) Add(v float64, labelValues ...string) {
+	if v < 0 {
+		panic("metrics: counter cannot decrease")
+	}
+	c.update(labelValues, func(old float64) float64 { return old + v })
+}
+
+// Gauge is a metric that can go up and down, optionally labelled
+type Gauge struct {
+	*series
+}
+
+// NewGauge creates and registers a gauge on the Default registry
+func NewGauge(name, help string, labelNames ...string) *Gauge {
+	g := &Gauge{newSeries(name, help, "gauge", labelNames)}
+	Default.register(g)
+	return g
+}
+
+// Set sets the gauge for the given label values
+func (g *Gauge) Set(v float64, labelValues ...string) {
+	g.update(labelValues, func(float64) float64 { return v })
+}
+
+// Add adds v (which may be negative) to the gauge
+func (g *Gauge) Add(v float64, labelValues ...string) {
+	g.update(labelValues, func(old float64) float64 { return old + v })
+}
+
+// GaugeFunc is an unlabelled gauge whose value is computed at scrape time
+type GaugeFunc struct {
+	metricName string
+	help       string
+	fn         func() float64
+}
+
+// NewGaugeFunc creates and registers a gauge on the Default registry that
+// calls fn each time metrics are collected
+func NewGaugeFunc(name, help string, fn func() float64) *GaugeFunc {
+	g := &GaugeFunc{metricName: name, help: help, fn: fn}
+	Default.register(g)
+	return g
+}
+
+func (g *GaugeFunc) name() string { return g.metricName }
+
+func (g *GaugeFunc) write(w io.Writer) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s gauge\n%s %s\n", g.metricName, g.help, g.metricName, g.metricName, formatValue(g.fn()))
+}
+
+// DefaultBuckets are latency buckets in seconds suited to API requests
+var DefaultBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// histogramData holds the observations for one label set
+type histogramData struct {
+	labels []string
+	counts []uint64 // Cumulative count per bucket
+	count  uint64
+	sum    float64
+}
+
+// Histogram counts observations into buckets, optionally labelled
+type Histogram struct {
+	metricName string
+	help       string
+	labelNames []string
+	buckets    []float64
+
+	mu   sync.Mutex
+	data map[string]*histogramData
+}
+
+// NewHistogram creates and registers a histogram on the Default registry.
+// Buckets are upper bounds in increasing order; +Inf is added implicitly.
+func NewHistogram(name, help string, buckets []float64, labelNames ...string) *Histogram {
+	h := newHistogram(name, help, buckets, labelNames)
+	Default.register(h)
+	return h
+}
+
+func newHistogram(name, help string, buckets []float64, labelNames []string) *Histogram {
+	return &Histogram{
+		metricName: name,
+		help:       help,
+		labelNames: labelNames,
+		buckets:    append([]float64(nil), buckets...),
+		data:       make(map[string]*histogramData),
+	}
+}
+
+func (h *Histogram) name() string { return h.metricName }
+
+// Observe records v for the given label values
+func (h *Histogram) Observe(v float64, labelValues ...string) {
+	if len(labelValues) != len(h.labelNames) {
+		panic(fmt.Sprintf("metrics: %s expects %d label values, got %d", h.metricName, len(h.labelNames), len(labelValues)))
+	}
+	key := strings.Join(labelValues, "\xff")
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	d, ok := h.data[key]
+	if !ok {
+		d = &histogramData{labels: append([]string(nil), labelValues...), counts: make([]uint64, len(h.buckets))}
+		h.data[key] = d
+	}
+	for i, bound := range h.buckets {
+		if v <= bound {
+			d.counts[i]++
+		}
+	}
+	d.count++
+	d.sum += v
+}
+
+// Count returns how many observations were recorded for the given label values
+func (h *Histogram) Count(labelValues ...string) uint64 {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if d, ok := h.data[strings.Join(labelValues, "\xff")]; ok {
+		return d.count
+	}
+	return 0
+}
+
+func (h *Histogram) write(w io.Writer) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s histogram\n", h.metricName, h.help, h.metricName)
+
+	keys := make([]string, 0, len(h.data))
+	for key := range h.data {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	bucketNames := append(append([]string(nil), h.labelNames...), "le")
+	for _, key := range keys {
+		d := h.data[key]
+		for i, bound := range h.buckets {
+			labels := append(append([]string(nil), d.labels...), formatValue(bound))
+			fmt.Fprintf(w, "%s_bucket%s %d\n", h.metricName, formatLabels(bucketNames, labels), d.counts[i])
+		}
+		labels := append(append([]string(nil), d.labels...), "+Inf")
+		fmt.Fprintf(w, "%s_bucket%s %d\n", h.metricName, formatLabels(bucketNames, labels), d.count)
+		fmt.Fprintf(w, "%s_sum%s %s\n", h.metricName, formatLabels(h.labelNames, d.labels), formatValue(d.sum))
+		fmt.Fprintf(w, "%s_count%s %d\n", h.metricName, formatLabels(h.labelNames, d.labels), d.count)
+	}
+}
@@ -0,0 +1,84 @@
+package metrics
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestRegistryExposition(t *testing.T) {
+	registry := NewRegistry()
+
+	counter := &Counter{newSeries("test_requests_total", "Requests served.", "counter", []string{"code"})}
+	registry.register(counter)
+	gauge := &Gauge{newSeries("test_in_flight", "In-flight requests.", "gauge", nil)}
+	registry.register(gauge)
+
+	counter.Inc("200")
+	counter.Add(2, "200")
+	counter.Inc(`4"04`)
+	gauge.Set(3)
+	gauge.Add(-1)
+
+	var buf bytes.Buffer
+	registry.Write(&buf)
+	out := buf.String()
+
+	for _, want := range []string{
+		"# TYPE test_in_flight gauge\ntest_in_flight 2\n",
+		"# TYPE test_requests_total counter\n",
+		`test_requests_total{code="200"} 3`,
+		`test_requests_total{code="4\"04"} 1`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("Expected exposition to contain %q, got:\n%s", want, out)
+		}
+	}
+
+	// Metrics are sorted by name
+	if strings.Index(out, "test_in_flight") > strings.Index(out, "test_requests_total") {
+		t.Error("Expected metrics sorted by name")
+	}
+}
+
+func TestRegistryDuplicate(t *testing.T) {
+	registry := NewRegistry()
+	registry.register(&Counter{newSeries("dup_total", "x", "counter", nil)})
+
+	defer func() {
+		if recover() == nil {
+			t.Error("Expected duplicate registration to panic")
+		}
+	}()
+	registry.register(&Counter{newSeries("dup_total", "x", "counter", nil)})
+}
+
+func TestHistogramExposition(t *testing.T) {
+	registry := NewRegistry()
+
+	histogram := newHistogram("test_duration_seconds", "Request latency.", []float64{0.1, 1}, []string{"route"})
+	registry.register(histogram)
+	registry.register(&GaugeFunc{metricName: "test_depth", help: "Queue depth.", fn: func() float64 { return 7 }})
+
+	histogram.Observe(0.05, "/a")
+	histogram.Observe(0.5, "/a")
+	histogram.Observe(3, "/a")
+
+	var buf bytes.Buffer
+	registry.Write(&buf)
+	out := buf.String()
+
+	for _, want := range []string{
+		"# TYPE test_depth gauge\ntest_depth 7\n",
+		"# TYPE test_duration_seconds histogram\n",
+		`test_duration_seconds_bucket{route="/a",le="0.1"} 1`,
+		`test_duration_seconds_bucket{route="/a",le="1"} 2`,
+		`test_duration_seconds_bucket{route="/a",le="+Inf"} 3`,
+		`test_duration_seconds_sum{route="/a"} 3.55`,
+		`test_duration_seconds_count{route="/a"} 3`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("Expected exposition to contain %q, got:\n%s", want, out)
+		}
+	}
+}
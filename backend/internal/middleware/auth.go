@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"net/http"
+	"slices"
 	"strings"
 
 	"github.com/LonleySailor/privatepaste/backend/internal/auth"
@@ -21,6 +22,15 @@ func NewAuthMiddleware(tokenManager *auth.TokenManager) *AuthMiddleware {
 	}
 }
 
+// withClaims adds the user ID, username, role and scopes of a token to a
+// request context
+func withClaims(ctx context.Context, claims *auth.Claims) context.Context {
+	ctx = context.WithValue(ctx, "userID", claims.UserID)
+	ctx = context.WithValue(ctx, "username", claims.Username)
+	ctx = context.WithValue(ctx, "role", claims.Role)
+	return context.WithValue(ctx, "scopes", claims.Scopes())
+}
+
 // RequireAuth middleware that requires authentication
 func (a *AuthMiddleware) RequireAuth(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -51,10 +61,7 @@ func (a *AuthMiddleware) RequireAuth(next http.Handler) http.Handler {
 			return
 		}
 
-		// Add user ID and username to request context
-		ctx := context.WithValue(r.Context(), "userID", claims.UserID)
-		ctx = context.WithValue(ctx, "username", claims.Username)
-		next.ServeHTTP(w, r.WithContext(ctx))
+		next.ServeHTTP(w, r.WithContext(withClaims(r.Context(), claims)))
 	})
 }
 
@@ -72,10 +79,7 @@ func (a *AuthMiddleware) OptionalAuth(next http.Handler) http.Handler {
 					// Validate token
 					claims, err := a.tokenManager.ValidateAccessToken(token)
 					if err == nil {
-						// Add user ID and username to request context
-						ctx := context.WithValue(r.Context(), "userID", claims.UserID)
-						ctx = context.WithValue(ctx, "username", claims.Username)
-						r = r.WithContext(ctx)
+						r = r.WithContext(withClaims(r.Context(), claims))
 					}
 				}
 			}
@@ -85,6 +89,46 @@ func (a *AuthMiddleware) OptionalAuth(next http.Handler) http.Handler {
 	})
 }
 
+// RequireAdmin middleware that requires an authenticated admin. It must run
+// after RequireAuth, which places the role in the request context.
+func (a *AuthMiddleware) RequireAdmin(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if role, _ := GetRoleFromContext(r.Context()); role != "admin" {
+			http.Error(w, "Admin access required", http.StatusForbidden)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// RequireScope middleware refuses authenticated requests whose token has none
+// of the given scopes. Anonymous requests pass through, so where a user is
+// required it must run after RequireAuth.
+func (a *AuthMiddleware) RequireScope(scopes ...string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if _, ok := GetUserIDFromContext(r.Context()); ok && !HasScope(r.Context(), scopes...) {
+				http.Error(w, "Insufficient scope", http.StatusForbidden)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// HasScope reports whether the request's token has any of the given scopes
+func HasScope(ctx context.Context, scopes ...string) bool {
+	granted, _ := ctx.Value("scopes").([]string)
+	for _, scope := range scopes {
+		if slices.Contains(granted, scope) {
+			return true
+		}
+	}
+	return false
+}
+
 // GetUserIDFromContext extracts user ID from request context
 func GetUserIDFromContext(ctx context.Context) (int, bool) {
 	userID, ok := ctx.Value("userID").(int)
@@ -97,12 +141,30 @@ func GetUsernameFromContext(ctx context.Context) (string, bool) {
 	return username, ok
 }
 
+// GetRoleFromContext extracts the user role from request context
+func GetRoleFromContext(ctx context.Context) (string, bool) {
+	role, ok := ctx.Value("role").(string)
+	return role, ok
+}
+
 // Logging middleware for request logging
 func LoggingMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		// Simple request logging - can be enhanced with proper logging library
-		fmt.Printf("%s %s %s\n", r.Method, r.URL.Path, r.RemoteAddr)
+		// Log after the handler runs so it can suppress logging for do-not-track pastes
+		r, state := withAccessLogState(r)
 		next.ServeHTTP(w, r)
+		if state.suppressed {
+			return
+		}
+
+		// Omit the client address for viewers that opted out of tracking
+		remoteAddr := r.RemoteAddr
+		if !TrackingAllowed(r) {
+			remoteAddr = "-"
+		}
+
+		// Simple request logging - can be enhanced with proper logging library
+		fmt.Printf("%s %s %s\n", r.Method, r.URL.Path, remoteAddr)
 	})
 }
 
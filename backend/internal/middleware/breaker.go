@@ -0,0 +1,29 @@
+package middleware
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Breaker reports how long until a circuit breaker lets calls through again
+type Breaker interface {
+	RetryAfter() time.Duration
+}
+
+// CircuitBreaker rejects requests with 503 while the breaker is open, so they
+// fail fast instead of waiting on a broken database
+func CircuitBreaker(breaker Breaker) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if retryAfter := breaker.RetryAfter(); retryAfter > 0 {
+				w.Header().Set("Retry-After", strconv.Itoa(int(retryAfter.Seconds())+1))
+				writeJSONError(w, http.StatusServiceUnavailable, "service_unavailable",
+					"The service is temporarily unavailable; try again later")
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,219 @@
+package middleware
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/LonleySailor/privatepaste/backend/internal/models"
+	"github.com/gorilla/mux"
+)
+
+// Headers tagging cached responses so a CDN can purge them by key: Fastly
+// reads Surrogate-Key (space separated), Cloudflare reads Cache-Tag (comma
+// separated)
+const (
+	SurrogateKeyHeader = "Surrogate-Key"
+	CacheTagHeader     = "Cache-Tag"
+)
+
+// Cache-Control values shared by the cache policies
+const (
+	CacheNoStore    = "no-store"
+	CachePrivate    = "private, no-store"
+	CachePublicHour = "public, max-age=3600"
+	CacheListing    = "public, max-age=300"  // Public listings, which may lag a few minutes
+	CacheContent    = "public, max-age=3600" // Paste content, capped at the paste's lifetime with CacheUntil
+	CachePublicDay  = "public, max-age=86400"
+	CacheImmutable  = "public, max-age=31536000, immutable" // Responses addressed by a content hash
+)
+
+// CachePolicy is the caching behaviour for a route
+type CachePolicy struct {
+	CacheControl string
+	Vary         []string // Request headers the response depends on
+}
+
+// DefaultCachePolicies maps route templates to their cache policy. Routes not
+// listed here, including all JSON API responses, are never cached.
+func DefaultCachePolicies() map[string]CachePolicy {
+	content := CachePolicy{CacheControl: CacheContent}
+	rendered := CachePolicy{CacheControl: CachePublicHour}
+
+	return map[string]CachePolicy{
+		"/api/paste/{id}/raw":         content,
+		"/api/paste/{id}/download":    {CacheControl: CachePrivate},
+		"/api/paste/{id}/signature":   content,
+		"/api/paste/{id}/pdf":         rendered,
+		"/api/paste/{id}/image.png":   rendered,
+		"/api/paste/{id}/ansi":        rendered,
+		"/api/paste/{id}/html":        rendered,
+		"/api/paste/{id}/render":      rendered,
+		"/api/paste/{id}/diagram.svg": rendered,
+		"/api/transforms":             {CacheControl: CachePublicDay},
+		"/api/blob/{hash}":            {CacheControl: CacheImmutable},
+		"/api/avatars/{hash}":         {CacheControl: CacheImmutable},
+		"/api/pastes/trending":        {CacheControl: CacheListing},
+		"/api/pastes/language/{lang}": {CacheControl: CacheListing},
+		"/api/languages":              {CacheControl: CacheListing},
+		"/api/archive/{year}/{month}": {CacheControl: CacheListing}, // A day once the month is over
+	}
+}
+
+// CacheControl applies cache policies by route template so handlers do not
+// set caching headers themselves
+type CacheControl struct {
+	policies map[string]CachePolicy
+	fallback CachePolicy
+}
+
+// NewCacheControl creates the middleware; routes without a policy use
+// fallback
+func NewCacheControl(policies map[string]CachePolicy, fallback CachePolicy) *CacheControl {
+	return &CacheControl{policies: policies, fallback: fallback}
+}
+
+// Apply middleware that sets Cache-Control and Vary when the response
+// headers are written. Public policies are downgraded to private for
+// requests that carry credentials, a developer key, a paste password or a
+// URL signature, and error responses are never cached. A handler that sets
+// Cache-Control itself takes precedence. Publicly cacheable responses of a
+// paste or blob are tagged with its surrogate key.
+func (c *CacheControl) Apply(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		policy := c.policyFor(r)
+		if strings.HasPrefix(policy.CacheControl, "public") && personalized(r) {
+			policy = CachePolicy{CacheControl: CachePrivate, Vary: policy.Vary}
+		}
+
+		var keys []string
+		if strings.HasPrefix(policy.CacheControl, "public") {
+			keys = surrogateKeys(r)
+		}
+
+		next.ServeHTTP(&cacheWriter{ResponseWriter: w, policy: policy, surrogateKeys: keys}, r)
+	})
+}
+
+// policyFor returns the policy of the matched route; only GET and HEAD
+// responses are cacheable
+func (c *CacheControl) policyFor(r *http.Request) CachePolicy {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		return CachePolicy{CacheControl: CacheNoStore}
+	}
+	if policy, ok := c.policies[routeLabel(r)]; ok {
+		return policy
+	}
+	return c.fallback
+}
+
+// personalized reports whether a response may depend on who asked for it
+func personalized(r *http.Request) bool {
+	q := r.URL.Query()
+	return r.Header.Get("Authorization") != "" || r.Header.Get(DeveloperKeyHeader) != "" ||
+		q.Has("password") || q.Has("signature")
+}
+
+// surrogateKeys returns the surrogate keys of the paste, blob or archive
+// month a route serves
+func surrogateKeys(r *http.Request) []string {
+	vars := mux.Vars(r)
+	if id := vars["id"]; id != "" {
+		return []string{models.PasteSurrogateKey(id)}
+	}
+	if hash := vars["hash"]; hash != "" {
+		return []string{models.BlobSurrogateKey(hash)}
+	}
+	if month, err := time.Parse("2006/01", vars["year"]+"/"+vars["month"]); err == nil {
+		return []string{models.ArchiveSurrogateKey(month)}
+	}
+	return nil
+}
+
+// CacheUntil caps how long the response being written may be cached at the
+// time its content stops being served, such as when its paste expires. It
+// only shortens the route's policy; Cache-Control set by the handler is
+// left alone.
+func CacheUntil(w http.ResponseWriter, until time.Time) {
+	for {
+		if cw, ok := w.(*cacheWriter); ok {
+			if cw.until.IsZero() || until.Before(cw.until) {
+				cw.until = until
+			}
+			return
+		}
+		unwrapper, ok := w.(interface{ Unwrap() http.ResponseWriter })
+		if !ok {
+			return
+		}
+		w = unwrapper.Unwrap()
+	}
+}
+
+// capMaxAge lowers the max-age of a public Cache-Control value to the time
+// left until the content stops being served, or makes it no-store when too
+// little is left to be worth caching
+func capMaxAge(cacheControl string, until time.Time) string {
+	if until.IsZero() || !strings.HasPrefix(cacheControl, "public") {
+		return cacheControl
+	}
+	left := int(time.Until(until) / time.Second)
+	if left <= 0 {
+		return CacheNoStore
+	}
+	directives := strings.Split(cacheControl, ", ")
+	for i, directive := range directives {
+		if value, ok := strings.CutPrefix(directive, "max-age="); ok {
+			if maxAge, err := strconv.Atoi(value); err == nil && maxAge > left {
+				directives[i] = "max-age=" + strconv.Itoa(left)
+			}
+		}
+	}
+	return strings.Join(directives, ", ")
+}
+
+// cacheWriter sets the caching headers just before the status is written
+type cacheWriter struct {
+	http.ResponseWriter
+	policy        CachePolicy
+	surrogateKeys []string
+	until         time.Time // When the content stops being served; zero if never
+	wroteHeader   bool
+}
+
+// WriteHeader sets the caching headers and records the status code
+func (w *cacheWriter) WriteHeader(status int) {
+	if !w.wroteHeader {
+		w.wroteHeader = true
+		h := w.Header()
+		if h.Get("Cache-Control") == "" {
+			if status >= http.StatusBadRequest {
+				h.Set("Cache-Control", CacheNoStore)
+			} else {
+				h.Set("Cache-Control", capMaxAge(w.policy.CacheControl, w.until))
+			}
+		}
+		if len(w.surrogateKeys) > 0 && status < http.StatusBadRequest && strings.HasPrefix(h.Get("Cache-Control"), "public") {
+			h.Set(SurrogateKeyHeader, strings.Join(w.surrogateKeys, " "))
+			h.Set(CacheTagHeader, strings.Join(w.surrogateKeys, ","))
+		}
+		for _, header := range w.policy.Vary {
+			h.Add("Vary", header)
+		}
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+// Write writes the headers with an implicit 200 status if needed
+func (w *cacheWriter) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// Unwrap exposes the underlying writer to http.ResponseController
+func (w *cacheWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
@@ -0,0 +1,125 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+func newCacheTestRouter() *mux.Router {
+	policies := map[string]CachePolicy{
+		"/api/paste/{id}/raw":         {CacheControl: CacheContent, Vary: []string{"Accept"}},
+		"/api/archive/{year}/{month}": {CacheControl: CacheListing},
+	}
+	cache := NewCacheControl(policies, CachePolicy{CacheControl: CacheNoStore})
+
+	router := mux.NewRouter()
+	router.Use(cache.Apply)
+	ok := func(w http.ResponseWriter, r *http.Request) { w.Write([]byte("content")) }
+	router.HandleFunc("/api/paste/{id}/raw", func(w http.ResponseWriter, r *http.Request) {
+		switch mux.Vars(r)["id"] {
+		case "missing":
+			http.NotFound(w, r)
+			return
+		case "expiring":
+			CacheUntil(w, time.Now().Add(10*time.Minute+time.Second))
+		case "expired":
+			CacheUntil(w, time.Now())
+		}
+		ok(w, r)
+	})
+	router.HandleFunc("/api/paste/{id}", ok)
+	router.HandleFunc("/api/archive/{year}/{month}", ok)
+	router.HandleFunc("/api/custom", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "max-age=5")
+		ok(w, r)
+	})
+	return router
+}
+
+func TestCacheControlPolicies(t *testing.T) {
+	router := newCacheTestRouter()
+
+	tests := []struct {
+		name     string
+		path     string
+		auth     bool
+		want     string
+		wantVary string
+	}{
+		{"raw content is cacheable", "/api/paste/abc123/raw", false, CacheContent, "Accept"},
+		{"credentials make it private", "/api/paste/abc123/raw", true, CachePrivate, "Accept"},
+		{"password makes it private", "/api/paste/abc123/raw?password=x", false, CachePrivate, "Accept"},
+		{"errors are never cached", "/api/paste/missing/raw", false, CacheNoStore, "Accept"},
+		{"capped at the paste's expiry", "/api/paste/expiring/raw", false, "public, max-age=600", "Accept"},
+		{"expired content is not cached", "/api/paste/expired/raw", false, CacheNoStore, "Accept"},
+		{"JSON falls back to no-store", "/api/paste/abc123", false, CacheNoStore, ""},
+		{"handler override wins", "/api/custom", false, "max-age=5", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			if tt.auth {
+				req.Header.Set("Authorization", "Bearer token")
+			}
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, req)
+
+			if got := rec.Header().Get("Cache-Control"); got != tt.want {
+				t.Errorf("Expected Cache-Control %q, got %q", tt.want, got)
+			}
+			if got := rec.Header().Get("Vary"); got != tt.wantVary {
+				t.Errorf("Expected Vary %q, got %q", tt.wantVary, got)
+			}
+		})
+	}
+}
+
+func TestCacheControlOnlyCachesReads(t *testing.T) {
+	router := newCacheTestRouter()
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/paste/abc123/raw", nil))
+	if got := rec.Header().Get("Cache-Control"); got != CacheNoStore {
+		t.Errorf("Expected POST responses to be no-store, got %q", got)
+	}
+}
+
+func TestCacheControlSurrogateKeys(t *testing.T) {
+	router := newCacheTestRouter()
+
+	tests := []struct {
+		name string
+		path string
+		auth bool
+		want string
+	}{
+		{"cacheable content is tagged", "/api/paste/abc123/raw", false, "paste-abc123"},
+		{"private responses are not tagged", "/api/paste/abc123/raw", true, ""},
+		{"errors are not tagged", "/api/paste/missing/raw", false, ""},
+		{"uncached routes are not tagged", "/api/paste/abc123", false, ""},
+		{"archive months are tagged", "/api/archive/2026/09", false, "archive-2026-09"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			if tt.auth {
+				req.Header.Set("Authorization", "Bearer token")
+			}
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, req)
+
+			if got := rec.Header().Get(SurrogateKeyHeader); got != tt.want {
+				t.Errorf("Expected Surrogate-Key %q, got %q", tt.want, got)
+			}
+			if got := rec.Header().Get(CacheTagHeader); got != tt.want {
+				t.Errorf("Expected Cache-Tag %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,77 @@
+package middleware
+
+import (
+	"math/rand"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/LonleySailor/privatepaste/backend/internal/metrics"
+)
+
+var chaosInjected = metrics.NewCounter("pastevault_chaos_injected_total", "Faults injected by chaos mode, by kind.", "kind")
+
+// Chaos injects artificial latency and errors into a percentage of requests so
+// clients can be tested against a degraded backend. It must never be enabled
+// in production.
+type Chaos struct {
+	errorPercent   int
+	latencyPercent int
+	maxLatency     time.Duration
+
+	mu       sync.Mutex
+	randomFn func(n int) int
+}
+
+// NewChaos creates a fault injector. errorPercent and latencyPercent are the
+// share of requests (0-100) that fail with 503 or are delayed by up to maxLatency.
+func NewChaos(errorPercent, latencyPercent int, maxLatency time.Duration) *Chaos {
+	rng := rand.New(rand.NewSource(time.Now().UnixNano()))
+	return &Chaos{
+		errorPercent:   errorPercent,
+		latencyPercent: latencyPercent,
+		maxLatency:     maxLatency,
+		randomFn:       rng.Intn,
+	}
+}
+
+// Enabled reports whether any faults would be injected
+func (c *Chaos) Enabled() bool {
+	return c.errorPercent > 0 || (c.latencyPercent > 0 && c.maxLatency > 0)
+}
+
+// random returns a value in [0, n); the generator is not safe for concurrent use
+func (c *Chaos) random(n int) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.randomFn(n)
+}
+
+// Inject middleware that delays or fails requests at random. Affected
+// responses carry an X-Chaos-Injected header so they can be told apart from
+// real failures.
+func (c *Chaos) Inject(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if c.latencyPercent > 0 && c.maxLatency > 0 && c.random(100) < c.latencyPercent {
+			delay := time.Duration(c.random(int(c.maxLatency/time.Millisecond))+1) * time.Millisecond
+			chaosInjected.Inc("latency")
+			w.Header().Set("X-Chaos-Injected", "latency")
+
+			select {
+			case <-time.After(delay):
+			case <-r.Context().Done():
+				return
+			}
+		}
+
+		if c.errorPercent > 0 && c.random(100) < c.errorPercent {
+			chaosInjected.Inc("error")
+			w.Header().Set("X-Chaos-Injected", "error")
+			writeJSONError(w, http.StatusServiceUnavailable, "service_unavailable",
+				"The service is temporarily unavailable; try again later")
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
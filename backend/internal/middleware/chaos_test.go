@@ -0,0 +1,58 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestChaosInjectsErrors(t *testing.T) {
+	chaos := NewChaos(50, 0, 0)
+	rolls := []int{10, 90}
+	chaos.randomFn = func(n int) int {
+		roll := rolls[0]
+		rolls = rolls[1:]
+		return roll
+	}
+
+	handler := chaos.Inject(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/paste/abc", nil))
+	if rec.Code != http.StatusServiceUnavailable || rec.Header().Get("X-Chaos-Injected") != "error" {
+		t.Errorf("Expected injected 503, got %d", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/paste/abc", nil))
+	if rec.Code != http.StatusOK || rec.Header().Get("X-Chaos-Injected") != "" {
+		t.Errorf("Expected request to pass through, got %d", rec.Code)
+	}
+}
+
+func TestChaosInjectsLatency(t *testing.T) {
+	chaos := NewChaos(0, 100, 20*time.Millisecond)
+	chaos.randomFn = func(n int) int { return n - 1 }
+
+	handler := chaos.Inject(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	start := time.Now()
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/paste/abc", nil))
+	if elapsed := time.Since(start); elapsed < 20*time.Millisecond {
+		t.Errorf("Expected at least 20ms of injected latency, got %v", elapsed)
+	}
+	if rec.Code != http.StatusOK || rec.Header().Get("X-Chaos-Injected") != "latency" {
+		t.Errorf("Expected delayed success, got %d", rec.Code)
+	}
+}
+
+func TestChaosDisabled(t *testing.T) {
+	if NewChaos(0, 0, time.Second).Enabled() {
+		t.Error("Expected chaos to be disabled without percentages")
+	}
+	if !NewChaos(0, 10, time.Second).Enabled() {
+		t.Error("Expected latency injection to enable chaos")
+	}
+}
@@ -21,11 +21,14 @@ func SetupCORS(allowedOrigins []string, isDevelopment bool) *cors.Cors {
 			"Accept",
 			"Authorization",
 			"Content-Type",
+			DeveloperKeyHeader,
 			"X-CSRF-Token",
 			"X-Requested-With",
 		},
 		ExposedHeaders: []string{
 			"Link",
+			"X-RateLimit-Limit",
+			"X-RateLimit-Remaining",
 		},
 		AllowCredentials: true,
 		MaxAge:           300, // 5 minutes
@@ -44,3 +47,15 @@ func SetupCORS(allowedOrigins []string, isDevelopment bool) *cors.Cors {
 func CORSHandler(next http.Handler, corsMiddleware *cors.Cors) http.Handler {
 	return corsMiddleware.Handler(next)
 }
+
+// AllowAnyOrigin middleware lets any origin read the response, for endpoints
+// authenticated by a credential in the request body rather than cookies or
+// the Authorization header. Browsers refuse a wildcard origin with
+// credentials, so the global CORS credentials header is removed.
+func AllowAnyOrigin(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+		w.Header().Del("Access-Control-Allow-Credentials")
+		next.ServeHTTP(w, r)
+	})
+}
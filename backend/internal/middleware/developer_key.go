@@ -0,0 +1,89 @@
+package middleware
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/LonleySailor/privatepaste/backend/internal/models"
+)
+
+// DeveloperKeyHeader carries a developer key on public API requests
+const DeveloperKeyHeader = "X-API-Key"
+
+// DeveloperKeyStore authenticates developer keys and records their usage
+type DeveloperKeyStore interface {
+	Authenticate(key string) (*models.DeveloperKey, error)
+	RecordUsage(id int64, limited bool) error
+}
+
+// LimitDeveloperKeys middleware for requests carrying a developer key. Each
+// key is limited by PolicyDeveloperKey on top of the per-IP limits, and its
+// usage is recorded for the key's dashboard. Requests without a key pass
+// through; an unknown key is refused so typos do not go unnoticed.
+func (rl *RateLimiter) LimitDeveloperKeys(store DeveloperKeyStore) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key := r.Header.Get(DeveloperKeyHeader)
+			if key == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			devKey, err := store.Authenticate(key)
+			if err != nil {
+				writeJSONError(w, http.StatusServiceUnavailable, "service_unavailable",
+					"The service is temporarily unavailable; try again later")
+				return
+			}
+			if devKey == nil {
+				writeJSONError(w, http.StatusUnauthorized, "invalid_api_key", "Unknown or revoked developer key")
+				return
+			}
+
+			allowed, policy, retryAfter := rl.allow(PolicyDeveloperKey, developerKeyVisitor(devKey.ID))
+			if err := store.RecordUsage(devKey.ID, !allowed); err != nil {
+				log.Printf("Failed to record developer key usage: %v", err)
+			}
+			if !allowed {
+				writeRateLimitError(w, "rate_limit_exceeded", policy.Message, int64(policy.Limit), retryAfter)
+				return
+			}
+
+			limit, remaining, _ := rl.DeveloperKeyQuota(devKey.ID)
+			w.Header().Set("X-RateLimit-Limit", strconv.FormatInt(limit, 10))
+			w.Header().Set("X-RateLimit-Remaining", strconv.FormatInt(remaining, 10))
+
+			ctx := context.WithValue(r.Context(), "developerKeyID", devKey.ID)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// DeveloperKeyQuota returns a developer key's request limit, the requests it
+// has left in the current window and the time until the window resets
+func (rl *RateLimiter) DeveloperKeyQuota(id int64) (int64, int64, time.Duration) {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	policy := rl.policies[PolicyDeveloperKey]
+	now := time.Now()
+	counter, exists := rl.getOrCreateVisitor(developerKeyVisitor(id)).counters[PolicyDeveloperKey]
+	if !exists || !now.Before(counter.resetAt) {
+		return int64(policy.Limit), int64(policy.Limit), 0
+	}
+	return int64(policy.Limit), max(int64(policy.Limit)-counter.count, 0), counter.resetAt.Sub(now)
+}
+
+// developerKeyVisitor is the visitor key developer key usage is counted under
+func developerKeyVisitor(id int64) string {
+	return "devkey:" + strconv.FormatInt(id, 10)
+}
+
+// GetDeveloperKeyIDFromContext extracts the developer key ID from request context
+func GetDeveloperKeyIDFromContext(ctx context.Context) (int64, bool) {
+	id, ok := ctx.Value("developerKeyID").(int64)
+	return id, ok
+}
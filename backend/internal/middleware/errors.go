@@ -0,0 +1,51 @@
+package middleware
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// writeJSONError writes an error in the same shape as handlers.APIError, for
+// middleware that needs machine-readable error codes
+func writeJSONError(w http.ResponseWriter, status int, code, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{
+		"error":   code,
+		"message": message,
+	})
+}
+
+// rateLimitError is the body of a 429 response
+type rateLimitError struct {
+	Code       string `json:"error"`
+	Message    string `json:"message"`
+	Limit      int64  `json:"limit"`
+	Remaining  int64  `json:"remaining"`
+	RetryAfter int    `json:"retry_after"` // Seconds until the window resets
+}
+
+// writeRateLimitError writes a 429 response with a Retry-After header so
+// clients can back off until the limit resets
+func writeRateLimitError(w http.ResponseWriter, code, message string, limit int64, retryAfter time.Duration) {
+	seconds := int(retryAfter.Seconds())
+	if retryAfter > time.Duration(seconds)*time.Second {
+		seconds++
+	}
+	if seconds < 1 {
+		seconds = 1
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Retry-After", strconv.Itoa(seconds))
+	w.WriteHeader(http.StatusTooManyRequests)
+	json.NewEncoder(w).Encode(rateLimitError{
+		Code:       code,
+		Message:    message,
+		Limit:      limit,
+		Remaining:  0,
+		RetryAfter: seconds,
+	})
+}
@@ -0,0 +1,79 @@
+package middleware
+
+import (
+	"log"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// CountryLookup resolves an IP address to an ISO country code
+type CountryLookup interface {
+	Country(ip net.IP) (string, error)
+}
+
+// GeoRestriction blocks requests from countries outside an allow list or
+// inside a deny list
+type GeoRestriction struct {
+	lookup CountryLookup
+	allow  map[string]bool
+	deny   map[string]bool
+}
+
+// NewGeoRestriction creates a country restriction. An empty allow list allows
+// every country that is not denied.
+func NewGeoRestriction(lookup CountryLookup, allow, deny []string) *GeoRestriction {
+	return &GeoRestriction{
+		lookup: lookup,
+		allow:  countrySet(allow),
+		deny:   countrySet(deny),
+	}
+}
+
+// countrySet builds an upper-cased lookup set of country codes
+func countrySet(codes []string) map[string]bool {
+	set := make(map[string]bool)
+	for _, code := range codes {
+		if code = strings.ToUpper(strings.TrimSpace(code)); code != "" {
+			set[code] = true
+		}
+	}
+	return set
+}
+
+// Restrict middleware that rejects requests from restricted countries
+func (g *GeoRestriction) Restrict(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if code, message := g.Check(r); code != "" {
+			writeJSONError(w, http.StatusForbidden, code, message)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Check returns the error code and message for a request from a restricted
+// country, or an empty code when the request's country is allowed
+func (g *GeoRestriction) Check(r *http.Request) (code, message string) {
+	ip := net.ParseIP(ClientIP(r))
+
+	// Local and private networks never resolve to a country
+	if ip == nil || ip.IsLoopback() || ip.IsPrivate() {
+		return "", ""
+	}
+
+	country, err := g.lookup.Country(ip)
+	if err != nil {
+		// Fail open so a broken database does not take the service down
+		log.Printf("GeoIP lookup failed: %v", err)
+		return "", ""
+	}
+
+	if country == "" && len(g.allow) > 0 {
+		return "country_unknown", "Your location could not be determined and this action is restricted by country"
+	}
+	if g.deny[country] || (len(g.allow) > 0 && !g.allow[country]) {
+		return "country_not_allowed", "This action is not available in your country"
+	}
+	return "", ""
+}
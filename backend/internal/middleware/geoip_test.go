@@ -0,0 +1,62 @@
+package middleware
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// fakeLookup maps IPs to countries for tests
+type fakeLookup map[string]string
+
+func (f fakeLookup) Country(ip net.IP) (string, error) {
+	return f[ip.String()], nil
+}
+
+func TestGeoRestriction(t *testing.T) {
+	lookup := fakeLookup{"203.0.113.1": "DE", "203.0.113.2": "US", "203.0.113.3": "KP"}
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	tests := []struct {
+		name     string
+		allow    []string
+		deny     []string
+		ip       string
+		wantCode string
+	}{
+		{"no lists", nil, nil, "203.0.113.1", ""},
+		{"denied country", nil, []string{"kp"}, "203.0.113.3", "country_not_allowed"},
+		{"allowed country", []string{"DE", "US"}, nil, "203.0.113.2", ""},
+		{"outside allow list", []string{"DE"}, nil, "203.0.113.2", "country_not_allowed"},
+		{"unknown with allow list", []string{"DE"}, nil, "198.51.100.9", "country_unknown"},
+		{"private network", []string{"DE"}, nil, "10.0.0.5", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := NewGeoRestriction(lookup, tt.allow, tt.deny).Restrict(ok)
+
+			req := httptest.NewRequest("POST", "/api/paste", nil)
+			req.RemoteAddr = tt.ip + ":1234"
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+
+			if tt.wantCode == "" {
+				if rr.Code != http.StatusOK {
+					t.Errorf("Expected request to pass, got %d", rr.Code)
+				}
+				return
+			}
+
+			var body map[string]string
+			json.Unmarshal(rr.Body.Bytes(), &body)
+			if rr.Code != http.StatusForbidden || body["error"] != tt.wantCode {
+				t.Errorf("Expected %d %s, got %d %v", http.StatusForbidden, tt.wantCode, rr.Code, body)
+			}
+		})
+	}
+}
@@ -0,0 +1,59 @@
+package middleware
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/LonleySailor/privatepaste/backend/internal/metrics"
+	"github.com/gorilla/mux"
+)
+
+// HTTP metrics share the route and method labels so SLO rules can join them,
+// e.g. the 5xx ratio per route:
+//
+//	sum by (route) (rate(pastevault_http_requests_total{class="5xx"}[5m]))
+//	  / sum by (route) (rate(pastevault_http_requests_total[5m]))
+var (
+	httpRequests = metrics.NewCounter("pastevault_http_requests_total",
+		"HTTP requests served, by route template, method and status class.", "route", "method", "class")
+	httpErrors = metrics.NewCounter("pastevault_http_request_errors_total",
+		"HTTP requests that failed with a 5xx status, by route template and method.", "route", "method")
+	httpDuration = metrics.NewHistogram("pastevault_http_request_duration_seconds",
+		"HTTP request latency, by route template and method.", metrics.DefaultBuckets, "route", "method")
+)
+
+// Metrics records request counts, errors and latency per route. Routes are
+// labelled by their template (/api/paste/{id}) rather than the raw path to
+// keep label cardinality bounded.
+func Metrics(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec := newStatusRecorder(w)
+
+		next.ServeHTTP(rec, r)
+
+		route := routeLabel(r)
+		httpRequests.Inc(route, r.Method, statusClass(rec.status))
+		if rec.status >= http.StatusInternalServerError {
+			httpErrors.Inc(route, r.Method)
+		}
+		httpDuration.Observe(time.Since(start).Seconds(), route, r.Method)
+	})
+}
+
+// routeLabel returns the matched route template, or "other" for requests that
+// did not match a templated route
+func routeLabel(r *http.Request) string {
+	if route := mux.CurrentRoute(r); route != nil {
+		if tmpl, err := route.GetPathTemplate(); err == nil {
+			return tmpl
+		}
+	}
+	return "other"
+}
+
+// statusClass buckets a status code into 1xx-5xx
+func statusClass(status int) string {
+	return strconv.Itoa(status/100) + "xx"
+}
@@ -0,0 +1,34 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+)
+
+func TestMetricsLabelsByRouteTemplate(t *testing.T) {
+	router := mux.NewRouter()
+	router.Use(Metrics)
+	router.HandleFunc("/api/metrics-test/{id}", func(w http.ResponseWriter, r *http.Request) {
+		if mux.Vars(r)["id"] == "boom" {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	})
+
+	for _, id := range []string{"a1", "b2", "boom"} {
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/api/metrics-test/"+id, nil))
+	}
+
+	route := "/api/metrics-test/{id}"
+	if got := httpRequests.Value(route, http.MethodGet, "2xx"); got != 2 {
+		t.Errorf("Expected 2 successful requests, got %v", got)
+	}
+	if got := httpErrors.Value(route, http.MethodGet); got != 1 {
+		t.Errorf("Expected 1 error, got %v", got)
+	}
+	if got := httpDuration.Count(route, http.MethodGet); got != 3 {
+		t.Errorf("Expected 3 latency observations, got %d", got)
+	}
+}
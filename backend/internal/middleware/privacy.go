@@ -0,0 +1,34 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+)
+
+// accessLogKey is the context key for the per-request access log state
+type accessLogKey struct{}
+
+// accessLogState lets handlers opt a request out of access logging
+type accessLogState struct {
+	suppressed bool
+}
+
+// SuppressAccessLog disables the access log line for the current request.
+// Handlers call this once they know the request targets a do-not-track paste.
+func SuppressAccessLog(r *http.Request) {
+	if state, ok := r.Context().Value(accessLogKey{}).(*accessLogState); ok {
+		state.suppressed = true
+	}
+}
+
+// TrackingAllowed reports whether the client permits analytics for this request.
+// Viewers sending DNT: 1 or Sec-GPC: 1 are never counted.
+func TrackingAllowed(r *http.Request) bool {
+	return r.Header.Get("DNT") != "1" && r.Header.Get("Sec-GPC") != "1"
+}
+
+// withAccessLogState attaches a fresh access log state to the request context
+func withAccessLogState(r *http.Request) (*http.Request, *accessLogState) {
+	state := &accessLogState{}
+	return r.WithContext(context.WithValue(r.Context(), accessLogKey{}, state)), state
+}
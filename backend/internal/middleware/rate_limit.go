@@ -3,40 +3,93 @@ package middleware
 import (
 	"net"
 	"net/http"
+	"strconv"
 	"sync"
 	"time"
 )
 
-// RateLimiter implements basic in-memory rate limiting
+// Rate limit policy names, one per route family
+const (
+	PolicyPasteCreation  = "creation"
+	PolicyPasteRetrieval = "retrieval"
+	PolicyAuthentication = "auth"
+	PolicyRegistration   = "registration"
+	PolicyUnlock         = "unlock"
+	PolicyPasswordHint   = "password_hint" // Revealing password hints, checked by the paste handlers
+	PolicyComments       = "comments"
+	PolicyWebhooks       = "webhooks"
+	PolicyQuickPaste     = "quick"
+	PolicyEmail          = "email"
+	PolicyDeveloperKeys  = "developer_keys" // Creating developer keys, per IP
+	PolicyDeveloperKey   = "developer_key"  // All requests made with one developer key
+)
+
+// Policy limits how many requests a single IP may make within a window
+type Policy struct {
+	Limit   int           // Max requests per IP per window
+	Window  time.Duration // Time window for the limit
+	Message string        // Returned to clients that exceed the limit
+}
+
+// DefaultPolicies returns the limits for every route family. New route
+// families declare their policy here and wrap their handlers with Limit.
+func DefaultPolicies() map[string]Policy {
+	// From requirements:
+	// - 10 pastes per IP per hour, 100 requests per IP per hour
+	// - 5 login attempts per 15 minutes per IP
+	// - 3 registrations per hour per IP
+	return map[string]Policy{
+		PolicyPasteCreation:  {Limit: 10, Window: time.Hour, Message: "Rate limit exceeded for paste creation"},
+		PolicyPasteRetrieval: {Limit: 100, Window: time.Hour, Message: "Rate limit exceeded for paste retrieval"},
+		PolicyAuthentication: {Limit: 5, Window: 15 * time.Minute, Message: "Rate limit exceeded for authentication. Please try again in 15 minutes"},
+		PolicyRegistration:   {Limit: 3, Window: time.Hour, Message: "Rate limit exceeded for registration. Please try again later"},
+		PolicyUnlock:         {Limit: 10, Window: 15 * time.Minute, Message: "Too many password attempts. Please try again later"},
+		PolicyPasswordHint:   {Limit: 20, Window: time.Hour, Message: "Too many password hints requested"},
+		PolicyComments:       {Limit: 30, Window: time.Hour, Message: "Rate limit exceeded for comments"},
+		PolicyWebhooks:       {Limit: 20, Window: time.Hour, Message: "Rate limit exceeded for webhooks"},
+		PolicyQuickPaste:     {Limit: 60, Window: time.Hour, Message: "Rate limit exceeded for quick pastes"},
+		PolicyEmail:          {Limit: 10, Window: time.Hour, Message: "Rate limit exceeded for emailing pastes"},
+		PolicyDeveloperKeys:  {Limit: 3, Window: time.Hour, Message: "Rate limit exceeded for creating developer keys"},
+		PolicyDeveloperKey:   {Limit: 60, Window: time.Hour, Message: "Rate limit exceeded for this developer key"},
+	}
+}
+
+// RateLimiter implements basic in-memory rate limiting with named policies
 type RateLimiter struct {
 	visitors map[string]*visitor
+	policies map[string]Policy
 	mu       sync.RWMutex
 
-	// Configuration
-	pasteLimit        int           // Max pastes per IP per window
-	retrievalLimit    int           // Max retrieval requests per IP per window
-	authLimit         int           // Max authentication attempts per IP per window
-	registrationLimit int           // Max registration attempts per IP per window
-	window            time.Duration // Time window for rate limiting
+	bandwidthLimit  int64         // Max response bytes per IP or user per window; 0 disables
+	bandwidthWindow time.Duration // Time window for bandwidth limiting
 }
 
 type visitor struct {
-	pasteCount        int
-	retrievalCount    int
-	authCount         int
-	registrationCount int
-	lastSeen          time.Time
+	counters map[string]*windowCounter // Request counts keyed by policy name
+	bytes    windowCounter             // Response bytes served
+	lastSeen time.Time
+}
+
+// windowCounter counts usage within a fixed window
+type windowCounter struct {
+	count   int64
+	resetAt time.Time
 }
 
-// NewRateLimiter creates a new rate limiter with specified limits
-func NewRateLimiter(pasteLimit, retrievalLimit, authLimit, registrationLimit int, window time.Duration) *RateLimiter {
+// current resets the counter if its window has elapsed and returns it
+func (c *windowCounter) current(now time.Time, window time.Duration) *windowCounter {
+	if !now.Before(c.resetAt) {
+		c.count = 0
+		c.resetAt = now.Add(window)
+	}
+	return c
+}
+
+// NewRateLimiter creates a new rate limiter enforcing the given policies
+func NewRateLimiter(policies map[string]Policy) *RateLimiter {
 	rl := &RateLimiter{
-		visitors:          make(map[string]*visitor),
-		pasteLimit:        pasteLimit,
-		retrievalLimit:    retrievalLimit,
-		authLimit:         authLimit,
-		registrationLimit: registrationLimit,
-		window:            window,
+		visitors: make(map[string]*visitor),
+		policies: policies,
 	}
 
 	// Start cleanup goroutine
@@ -45,187 +98,208 @@ func NewRateLimiter(pasteLimit, retrievalLimit, authLimit, registrationLimit int
 	return rl
 }
 
-// NewDefaultRateLimiter creates a rate limiter with default settings from requirements
+// NewDefaultRateLimiter creates a rate limiter with the default policies
 func NewDefaultRateLimiter() *RateLimiter {
-	// From requirements:
-	// - 10 pastes per IP per hour, 100 requests per IP per hour
-	// - 5 login attempts per 15 minutes per IP
-	// - 3 registrations per hour per IP
-	return NewRateLimiter(10, 100, 5, 3, time.Hour)
+	return NewRateLimiter(DefaultPolicies())
 }
 
-// LimitPasteCreation middleware for limiting paste creation
-func (rl *RateLimiter) LimitPasteCreation(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		ip := getClientIP(r)
+// SetPolicy adds or replaces a named policy
+func (rl *RateLimiter) SetPolicy(name string, policy Policy) {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	rl.policies[name] = policy
+}
 
-		if !rl.allowPasteCreation(ip) {
-			http.Error(w, "Rate limit exceeded for paste creation", http.StatusTooManyRequests)
-			return
-		}
+// Limit returns middleware enforcing the named policy per client IP. It
+// panics if the policy is not registered so typos fail at startup.
+func (rl *RateLimiter) Limit(name string) func(http.Handler) http.Handler {
+	rl.mu.RLock()
+	_, exists := rl.policies[name]
+	rl.mu.RUnlock()
+	if !exists {
+		panic("rate limit policy not registered: " + name)
+	}
 
-		next.ServeHTTP(w, r)
-	})
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ip := ClientIP(r)
+
+			if allowed, policy, retryAfter := rl.allow(name, ip); !allowed {
+				writeRateLimitError(w, "rate_limit_exceeded", policy.Message, int64(policy.Limit), retryAfter)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
 }
 
-// LimitPasteRetrieval middleware for limiting paste retrieval
-func (rl *RateLimiter) LimitPasteRetrieval(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		ip := getClientIP(r)
+// Allow counts a request against the named policy for the client IP, for
+// handlers that limit a single response path rather than a whole route. It
+// reports whether the request is within the limit.
+func (rl *RateLimiter) Allow(name string, r *http.Request) bool {
+	allowed, _, _ := rl.allow(name, ClientIP(r))
+	return allowed
+}
 
-		if !rl.allowPasteRetrieval(ip) {
-			http.Error(w, "Rate limit exceeded for paste retrieval", http.StatusTooManyRequests)
-			return
-		}
+// LimitPasteCreation middleware for limiting paste creation
+func (rl *RateLimiter) LimitPasteCreation(next http.Handler) http.Handler {
+	return rl.Limit(PolicyPasteCreation)(next)
+}
 
-		next.ServeHTTP(w, r)
-	})
+// LimitPasteRetrieval middleware for limiting paste retrieval
+func (rl *RateLimiter) LimitPasteRetrieval(next http.Handler) http.Handler {
+	return rl.Limit(PolicyPasteRetrieval)(next)
 }
 
 // LimitAuthentication middleware for limiting authentication attempts
 func (rl *RateLimiter) LimitAuthentication(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		ip := getClientIP(r)
-
-		if !rl.allowAuthentication(ip) {
-			http.Error(w, "Rate limit exceeded for authentication. Please try again in 15 minutes", http.StatusTooManyRequests)
-			return
-		}
-
-		next.ServeHTTP(w, r)
-	})
+	return rl.Limit(PolicyAuthentication)(next)
 }
 
 // LimitRegistration middleware for limiting registration attempts
 func (rl *RateLimiter) LimitRegistration(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		ip := getClientIP(r)
-
-		if !rl.allowRegistration(ip) {
-			http.Error(w, "Rate limit exceeded for registration. Please try again later", http.StatusTooManyRequests)
-			return
-		}
-
-		next.ServeHTTP(w, r)
-	})
+	return rl.Limit(PolicyRegistration)(next)
 }
 
-// allowPasteCreation checks if paste creation is allowed for the given IP
-func (rl *RateLimiter) allowPasteCreation(ip string) bool {
+// allow checks and counts a request against the named policy for the given IP.
+// When the request is refused it also returns the time until the window resets.
+func (rl *RateLimiter) allow(name, ip string) (bool, Policy, time.Duration) {
 	rl.mu.Lock()
 	defer rl.mu.Unlock()
 
+	policy := rl.policies[name]
+	now := time.Now()
+
 	v := rl.getOrCreateVisitor(ip)
+	v.lastSeen = now
+
+	counter, exists := v.counters[name]
+	if !exists {
+		counter = &windowCounter{}
+		v.counters[name] = counter
+	}
 
-	if v.pasteCount >= rl.pasteLimit {
-		return false
+	if counter.current(now, policy.Window).count >= int64(policy.Limit) {
+		return false, policy, counter.resetAt.Sub(now)
 	}
 
-	v.pasteCount++
-	v.lastSeen = time.Now()
-	return true
+	counter.count++
+	return true, policy, 0
 }
 
-// allowPasteRetrieval checks if paste retrieval is allowed for the given IP
-func (rl *RateLimiter) allowPasteRetrieval(ip string) bool {
+// SetBandwidthLimit sets the maximum number of response bytes served to a
+// single IP or user per window. Zero disables bandwidth limiting.
+func (rl *RateLimiter) SetBandwidthLimit(bytes int64, window time.Duration) {
 	rl.mu.Lock()
 	defer rl.mu.Unlock()
+	rl.bandwidthLimit = bytes
+	rl.bandwidthWindow = window
+}
 
-	v := rl.getOrCreateVisitor(ip)
+// LimitBandwidth middleware for limiting the bytes served per window.
+// Authenticated users are tracked by account, everyone else by IP. Usage is
+// reported in the X-Bandwidth-Limit and X-Bandwidth-Remaining headers.
+func (rl *RateLimiter) LimitBandwidth(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := ClientIP(r)
+		if userID, ok := GetUserIDFromContext(r.Context()); ok {
+			key = "user:" + strconv.Itoa(userID)
+		}
 
-	if v.retrievalCount >= rl.retrievalLimit {
-		return false
-	}
+		limit, remaining, retryAfter := rl.bandwidthRemaining(key)
+		if limit == 0 {
+			next.ServeHTTP(w, r)
+			return
+		}
 
-	v.retrievalCount++
-	v.lastSeen = time.Now()
-	return true
+		w.Header().Set("X-Bandwidth-Limit", strconv.FormatInt(limit, 10))
+		w.Header().Set("X-Bandwidth-Remaining", strconv.FormatInt(remaining, 10))
+		if retryAfter > 0 {
+			writeRateLimitError(w, "bandwidth_quota_exceeded", "Bandwidth quota exceeded. Please try again later", limit, retryAfter)
+			return
+		}
+
+		rec := newStatusRecorder(w)
+		next.ServeHTTP(rec, r)
+		rl.recordBandwidth(key, rec.bytes)
+	})
 }
 
-// allowAuthentication checks if authentication is allowed for the given IP
-func (rl *RateLimiter) allowAuthentication(ip string) bool {
+// bandwidthRemaining returns the bandwidth limit and the bytes left for the
+// given key. When the quota is exhausted it also returns the time until it resets.
+func (rl *RateLimiter) bandwidthRemaining(key string) (int64, int64, time.Duration) {
 	rl.mu.Lock()
 	defer rl.mu.Unlock()
 
-	v := rl.getOrCreateVisitor(ip)
-
-	// Special rate limit for auth: 5 attempts per 15 minutes
-	if v.authCount >= rl.authLimit {
-		return false
+	if rl.bandwidthLimit <= 0 {
+		return 0, 0, 0
 	}
 
-	v.authCount++
-	v.lastSeen = time.Now()
-	return true
+	now := time.Now()
+	v := rl.getOrCreateVisitor(key)
+	v.lastSeen = now
+
+	remaining := rl.bandwidthLimit - v.bytes.current(now, rl.bandwidthWindow).count
+	if remaining <= 0 {
+		return rl.bandwidthLimit, 0, v.bytes.resetAt.Sub(now)
+	}
+	return rl.bandwidthLimit, remaining, 0
 }
 
-// allowRegistration checks if registration is allowed for the given IP
-func (rl *RateLimiter) allowRegistration(ip string) bool {
+// recordBandwidth adds served bytes to the given key's usage
+func (rl *RateLimiter) recordBandwidth(key string, bytes int64) {
 	rl.mu.Lock()
 	defer rl.mu.Unlock()
 
-	v := rl.getOrCreateVisitor(ip)
-
-	if v.registrationCount >= rl.registrationLimit {
-		return false
-	}
-
-	v.registrationCount++
-	v.lastSeen = time.Now()
-	return true
+	rl.getOrCreateVisitor(key).bytes.count += bytes
 }
 
-// getOrCreateVisitor gets or creates a visitor record for the given IP
-func (rl *RateLimiter) getOrCreateVisitor(ip string) *visitor {
-	v, exists := rl.visitors[ip]
+// getOrCreateVisitor gets or creates a visitor record for the given key
+func (rl *RateLimiter) getOrCreateVisitor(key string) *visitor {
+	v, exists := rl.visitors[key]
 	if !exists {
 		v = &visitor{
+			counters: make(map[string]*windowCounter),
 			lastSeen: time.Now(),
 		}
-		rl.visitors[ip] = v
+		rl.visitors[key] = v
 	}
 	return v
 }
 
-// cleanupVisitors periodically removes old visitor records
+// longestWindow returns the longest window of any policy or the bandwidth limit
+func (rl *RateLimiter) longestWindow() time.Duration {
+	longest := rl.bandwidthWindow
+	for _, policy := range rl.policies {
+		if policy.Window > longest {
+			longest = policy.Window
+		}
+	}
+	return longest
+}
+
+// cleanupVisitors periodically removes visitors idle for longer than every window
 func (rl *RateLimiter) cleanupVisitors() {
-	ticker := time.NewTicker(rl.window)
+	ticker := time.NewTicker(time.Minute)
 	defer ticker.Stop()
 
 	for range ticker.C {
 		rl.mu.Lock()
-		cutoff := time.Now().Add(-rl.window)
-		for ip, v := range rl.visitors {
+		cutoff := time.Now().Add(-rl.longestWindow())
+		for key, v := range rl.visitors {
 			if v.lastSeen.Before(cutoff) {
-				delete(rl.visitors, ip)
-			} else {
-				// Reset counters for visitors still within window
-				v.pasteCount = 0
-				v.retrievalCount = 0
-				v.authCount = 0
-				v.registrationCount = 0
+				delete(rl.visitors, key)
 			}
 		}
 		rl.mu.Unlock()
 	}
 }
 
-// getClientIP extracts the client IP address from the request
-func getClientIP(r *http.Request) string {
-	// Check X-Forwarded-For header first (for proxies)
-	if xff := r.Header.Get("X-Forwarded-For"); xff != "" {
-		// X-Forwarded-For can contain multiple IPs, take the first one
-		if ip := parseXForwardedFor(xff); ip != "" {
-			return ip
-		}
-	}
-
-	// Check X-Real-IP header
-	if xri := r.Header.Get("X-Real-IP"); xri != "" {
-		return xri
-	}
-
-	// Fall back to RemoteAddr
+// ClientIP returns the IP address of the client making the request.
+// Forwarding headers are only believed through RealIP, which rewrites the
+// remote address of requests from trusted proxies.
+func ClientIP(r *http.Request) string {
 	ip, _, err := net.SplitHostPort(r.RemoteAddr)
 	if err != nil {
 		return r.RemoteAddr
@@ -233,37 +307,23 @@ func getClientIP(r *http.Request) string {
 	return ip
 }
 
-// parseXForwardedFor parses the X-Forwarded-For header and returns the first valid IP
-func parseXForwardedFor(header string) string {
-	// Simple implementation: just take the first IP before comma
-	for i, c := range header {
-		if c == ',' {
-			ip := header[:i]
-			if net.ParseIP(ip) != nil {
-				return ip
-			}
-			break
-		}
-	}
-
-	// If no comma, try the whole string
-	if net.ParseIP(header) != nil {
-		return header
-	}
-
-	return ""
-}
-
 // GetStats returns current rate limiting statistics (for debugging)
 func (rl *RateLimiter) GetStats() map[string]interface{} {
 	rl.mu.RLock()
 	defer rl.mu.RUnlock()
 
+	policies := make(map[string]interface{}, len(rl.policies))
+	for name, policy := range rl.policies {
+		policies[name] = map[string]interface{}{
+			"limit":          policy.Limit,
+			"window_minutes": policy.Window.Minutes(),
+		}
+	}
+
 	stats := map[string]interface{}{
 		"total_visitors":  len(rl.visitors),
-		"paste_limit":     rl.pasteLimit,
-		"retrieval_limit": rl.retrievalLimit,
-		"window_hours":    rl.window.Hours(),
+		"policies":        policies,
+		"bandwidth_limit": rl.bandwidthLimit,
 	}
 
 	return stats
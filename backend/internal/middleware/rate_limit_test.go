@@ -0,0 +1,205 @@
+package middleware
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/LonleySailor/privatepaste/backend/internal/models"
+)
+
+func TestLimit_Policies(t *testing.T) {
+	rl := NewRateLimiter(map[string]Policy{
+		"strict": {Limit: 2, Window: time.Hour, Message: "slow down"},
+		"loose":  {Limit: 5, Window: time.Hour, Message: "slow down"},
+	})
+
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	strict := rl.Limit("strict")(ok)
+	loose := rl.Limit("loose")(ok)
+
+	request := func(h http.Handler) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.RemoteAddr = "203.0.113.9:4000"
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, req)
+		return rr
+	}
+
+	for i := 0; i < 2; i++ {
+		if rr := request(strict); rr.Code != http.StatusOK {
+			t.Fatalf("Request %d: expected 200, got %d", i+1, rr.Code)
+		}
+	}
+
+	rr := request(strict)
+	if rr.Code != http.StatusTooManyRequests {
+		t.Fatalf("Expected strict policy to trip, got %d", rr.Code)
+	}
+	if rr.Header().Get("Retry-After") != "3600" {
+		t.Errorf("Expected Retry-After of 3600, got %q", rr.Header().Get("Retry-After"))
+	}
+
+	var body rateLimitError
+	if err := json.Unmarshal(rr.Body.Bytes(), &body); err != nil {
+		t.Fatalf("Expected JSON body, got %q", rr.Body.String())
+	}
+	if body.Code != "rate_limit_exceeded" || body.Limit != 2 || body.Remaining != 0 || body.RetryAfter != 3600 {
+		t.Errorf("Unexpected error body: %+v", body)
+	}
+
+	// Policies are counted independently
+	if rr := request(loose); rr.Code != http.StatusOK {
+		t.Errorf("Expected loose policy to be unaffected, got %d", rr.Code)
+	}
+}
+
+func TestAllow(t *testing.T) {
+	rl := NewRateLimiter(map[string]Policy{"hint": {Limit: 1, Window: time.Hour}})
+	req := httptest.NewRequest("GET", "/", nil)
+	req.RemoteAddr = "203.0.113.9:4000"
+
+	if !rl.Allow("hint", req) {
+		t.Fatal("Expected the first request to be allowed")
+	}
+	if rl.Allow("hint", req) {
+		t.Error("Expected the second request to exceed the limit")
+	}
+	req.RemoteAddr = "203.0.113.10:4000"
+	if !rl.Allow("hint", req) {
+		t.Error("Expected other clients to be counted separately")
+	}
+}
+
+func TestLimit_UnknownPolicy(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("Expected unknown policy to panic")
+		}
+	}()
+	NewDefaultRateLimiter().Limit("missing")
+}
+
+func TestLimitBandwidth(t *testing.T) {
+	rl := NewDefaultRateLimiter()
+	rl.SetBandwidthLimit(100, time.Hour)
+
+	handler := rl.LimitBandwidth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(strings.Repeat("x", 60)))
+	}))
+
+	request := func(remoteAddr string, userID int) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/api/paste/abc123/raw", nil)
+		req.RemoteAddr = remoteAddr
+		if userID != 0 {
+			req = req.WithContext(context.WithValue(req.Context(), "userID", userID))
+		}
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		return rr
+	}
+
+	rr := request("203.0.113.9:4000", 0)
+	if rr.Code != http.StatusOK || rr.Header().Get("X-Bandwidth-Remaining") != "100" {
+		t.Fatalf("Expected first response with 100 bytes remaining, got %d %q", rr.Code, rr.Header().Get("X-Bandwidth-Remaining"))
+	}
+
+	rr = request("203.0.113.9:4000", 0)
+	if rr.Code != http.StatusOK || rr.Header().Get("X-Bandwidth-Remaining") != "40" {
+		t.Fatalf("Expected second response with 40 bytes remaining, got %d %q", rr.Code, rr.Header().Get("X-Bandwidth-Remaining"))
+	}
+
+	rr = request("203.0.113.9:4000", 0)
+	if rr.Code != http.StatusTooManyRequests {
+		t.Fatalf("Expected quota to be exhausted, got %d", rr.Code)
+	}
+	if rr.Header().Get("Retry-After") == "" {
+		t.Error("Expected Retry-After header when the quota is exhausted")
+	}
+	if rr.Header().Get("X-Bandwidth-Limit") != "100" {
+		t.Errorf("Expected X-Bandwidth-Limit 100, got %q", rr.Header().Get("X-Bandwidth-Limit"))
+	}
+
+	// Other IPs and authenticated users have their own quotas
+	if rr := request("198.51.100.7:4000", 0); rr.Code != http.StatusOK {
+		t.Errorf("Expected separate quota for another IP, got %d", rr.Code)
+	}
+	if rr := request("203.0.113.9:4000", 42); rr.Code != http.StatusOK {
+		t.Errorf("Expected separate quota for an authenticated user, got %d", rr.Code)
+	}
+}
+
+func TestLimitBandwidth_Disabled(t *testing.T) {
+	rl := NewDefaultRateLimiter()
+
+	handler := rl.LimitBandwidth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("content"))
+	}))
+
+	req := httptest.NewRequest("GET", "/api/paste/abc123/raw", nil)
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK || rr.Header().Get("X-Bandwidth-Limit") != "" {
+		t.Errorf("Expected no bandwidth limiting, got %d %q", rr.Code, rr.Header().Get("X-Bandwidth-Limit"))
+	}
+}
+
+type stubDeveloperKeys struct {
+	limited []bool
+}
+
+func (s *stubDeveloperKeys) Authenticate(key string) (*models.DeveloperKey, error) {
+	if key != "pvd_valid" {
+		return nil, nil
+	}
+	return &models.DeveloperKey{ID: 3}, nil
+}
+
+func (s *stubDeveloperKeys) RecordUsage(id int64, limited bool) error {
+	s.limited = append(s.limited, limited)
+	return nil
+}
+
+func TestLimitDeveloperKeys(t *testing.T) {
+	rl := NewRateLimiter(map[string]Policy{
+		PolicyDeveloperKey: {Limit: 2, Window: time.Hour, Message: "slow down"},
+	})
+	store := &stubDeveloperKeys{}
+	handler := rl.LimitDeveloperKeys(store)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := GetDeveloperKeyIDFromContext(r.Context()); !ok {
+			t.Error("Expected the key ID in the request context")
+		}
+	}))
+
+	request := func(key string) int {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Header.Set(DeveloperKeyHeader, key)
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		return rr.Code
+	}
+
+	for i := 0; i < 2; i++ {
+		if code := request("pvd_valid"); code != http.StatusOK {
+			t.Fatalf("Request %d: expected 200, got %d", i+1, code)
+		}
+	}
+	if code := request("pvd_valid"); code != http.StatusTooManyRequests {
+		t.Fatalf("Expected the key's limit to trip, got %d", code)
+	}
+	if len(store.limited) != 3 || store.limited[1] || !store.limited[2] {
+		t.Errorf("Expected usage to record the refused request, got %v", store.limited)
+	}
+	if limit, remaining, resetIn := rl.DeveloperKeyQuota(3); limit != 2 || remaining != 0 || resetIn <= 0 {
+		t.Errorf("Unexpected quota %d/%d (%v)", remaining, limit, resetIn)
+	}
+
+	if code := request("pvd_unknown"); code != http.StatusUnauthorized {
+		t.Errorf("Expected an unknown key to be refused, got %d", code)
+	}
+}
@@ -0,0 +1,82 @@
+package middleware
+
+import (
+	"net/http"
+	"path"
+	"slices"
+	"sync/atomic"
+)
+
+// ReadOnly rejects every request that could change state, for instances
+// running as a read-only mirror of another
+func ReadOnly(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if isReadRequest(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		writeJSONError(w, http.StatusForbidden, "read_only_mirror",
+			"This instance is a read-only mirror; create and manage pastes on the original instance")
+	})
+}
+
+// ReadOnlyMode temporarily pauses writes to the instance, for example while
+// its storage is migrated to Postgres or S3. Unlike a mirror, the instance
+// is expected to accept writes again, so rejected requests get a 503 that
+// clients should retry. It can be switched on and off while running.
+type ReadOnlyMode struct {
+	enabled atomic.Bool
+	exempt  []string
+}
+
+// NewReadOnlyMode creates the read-only switch. Requests to the exempt paths
+// are always let through, so admins can still log in and switch it off and
+// requests that only read, such as unlocking a paste, keep working. Exempt
+// paths are patterns as for path.Match, so "/api/paste/*/unlock" exempts
+// unlocking every paste.
+func NewReadOnlyMode(enabled bool, exempt ...string) *ReadOnlyMode {
+	m := &ReadOnlyMode{exempt: exempt}
+	m.enabled.Store(enabled)
+	return m
+}
+
+// Enabled reports whether writes are paused
+func (m *ReadOnlyMode) Enabled() bool {
+	return m.enabled.Load()
+}
+
+// SetEnabled pauses or resumes writes
+func (m *ReadOnlyMode) SetEnabled(enabled bool) {
+	m.enabled.Store(enabled)
+}
+
+// Guard middleware rejects requests that could change state while writes
+// are paused. Background jobs and writes made while reading, such as view
+// counts, check Enabled themselves.
+func (m *ReadOnlyMode) Guard(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !m.Enabled() || isReadRequest(r) || m.isExempt(r.URL.Path) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		writeJSONError(w, http.StatusServiceUnavailable, "read_only",
+			"This instance is temporarily read-only for maintenance; try again later")
+	})
+}
+
+// isExempt reports whether a path matches one of the exempt patterns
+func (m *ReadOnlyMode) isExempt(urlPath string) bool {
+	return slices.ContainsFunc(m.exempt, func(pattern string) bool {
+		matched, _ := path.Match(pattern, urlPath)
+		return matched
+	})
+}
+
+// isReadRequest reports whether a request's method cannot change state
+func isReadRequest(r *http.Request) bool {
+	switch r.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,60 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestReadOnly(t *testing.T) {
+	handler := ReadOnly(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	for method, want := range map[string]int{
+		http.MethodGet:    http.StatusOK,
+		http.MethodHead:   http.StatusOK,
+		http.MethodPost:   http.StatusForbidden,
+		http.MethodDelete: http.StatusForbidden,
+	} {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(method, "/api/paste", nil))
+		if rec.Code != want {
+			t.Errorf("Expected %s to get %d, got %d", method, want, rec.Code)
+		}
+	}
+}
+
+func TestReadOnlyMode(t *testing.T) {
+	mode := NewReadOnlyMode(true, "/api/admin/read-only", "/api/paste/*/unlock")
+	handler := mode.Guard(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	serve := func(method, path string) int {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(method, path, nil))
+		return rec.Code
+	}
+
+	if code := serve(http.MethodGet, "/api/paste/abc"); code != http.StatusOK {
+		t.Errorf("Expected reads to work, got %d", code)
+	}
+	if code := serve(http.MethodPost, "/api/paste"); code != http.StatusServiceUnavailable {
+		t.Errorf("Expected writes to get 503, got %d", code)
+	}
+	if code := serve(http.MethodPut, "/api/admin/read-only"); code != http.StatusOK {
+		t.Errorf("Expected exempt path to work, got %d", code)
+	}
+	if code := serve(http.MethodPost, "/api/paste/abc/unlock"); code != http.StatusOK {
+		t.Errorf("Expected exempt pattern to work, got %d", code)
+	}
+	if code := serve(http.MethodPost, "/api/paste/abc/run"); code != http.StatusServiceUnavailable {
+		t.Errorf("Expected other paste writes to get 503, got %d", code)
+	}
+
+	mode.SetEnabled(false)
+	if code := serve(http.MethodPost, "/api/paste"); code != http.StatusOK {
+		t.Errorf("Expected writes to work once disabled, got %d", code)
+	}
+}
@@ -0,0 +1,114 @@
+package middleware
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// ParseTrustedProxies parses reverse proxy addresses and CIDR ranges, as in
+// "10.0.0.0/8" or "192.0.2.1"
+func ParseTrustedProxies(entries []string) ([]*net.IPNet, error) {
+	var networks []*net.IPNet
+	for _, entry := range entries {
+		if !strings.Contains(entry, "/") {
+			ip := net.ParseIP(entry)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid trusted proxy %q", entry)
+			}
+			bits := 8 * net.IPv6len
+			if ip4 := ip.To4(); ip4 != nil {
+				ip, bits = ip4, 8*net.IPv4len
+			}
+			networks = append(networks, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, network, err := net.ParseCIDR(entry)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted proxy %q", entry)
+		}
+		networks = append(networks, network)
+	}
+	return networks, nil
+}
+
+// RealIP replaces the remote address of requests arriving through a trusted
+// reverse proxy with the client address the proxy reported, so ClientIP and
+// everything keyed on it see the client rather than the proxy. Forwarding
+// headers from anyone else are ignored, as clients can send whatever they
+// like in them. Requests over a Unix socket have no remote address and are
+// taken to come from a local proxy.
+func RealIP(trusted []*net.IPNet) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if ip := forwardedClient(r, trusted); ip != "" {
+				r.RemoteAddr = net.JoinHostPort(ip, "0")
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// forwardedClient returns the client address reported by the proxies a
+// request came through, or "" when it did not come through a trusted one.
+// X-Forwarded-For is read from the right, where each trusted proxy appended
+// the address it was connected from, up to the first address that is not a
+// trusted proxy; entries left of it were written by the client.
+func forwardedClient(r *http.Request, trusted []*net.IPNet) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	if peer := net.ParseIP(host); peer != nil && !isTrustedProxy(peer, trusted) {
+		return ""
+	}
+
+	if values := r.Header.Values("X-Forwarded-For"); len(values) > 0 {
+		entries := strings.Split(strings.Join(values, ","), ",")
+		client := ""
+		for i := len(entries) - 1; i >= 0; i-- {
+			ip := net.ParseIP(strings.TrimSpace(entries[i]))
+			if ip == nil {
+				break
+			}
+			client = ip.String()
+			if !isTrustedProxy(ip, trusted) {
+				break
+			}
+		}
+		return client
+	}
+
+	if ip := net.ParseIP(strings.TrimSpace(r.Header.Get("X-Real-IP"))); ip != nil {
+		return ip.String()
+	}
+	return ""
+}
+
+// isTrustedProxy reports whether ip is one of the trusted proxies
+func isTrustedProxy(ip net.IP, trusted []*net.IPNet) bool {
+	for _, network := range trusted {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// RequireToken middleware refuses requests that do not bear token as an
+// Authorization bearer token, for endpoints such as metrics that are for
+// operators only
+func RequireToken(token string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			given, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+			if !ok || subtle.ConstantTimeCompare([]byte(given), []byte(token)) != 1 {
+				writeJSONError(w, http.StatusUnauthorized, "unauthorized", "A valid token is required")
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
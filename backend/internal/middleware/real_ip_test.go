@@ -0,0 +1,81 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRealIP(t *testing.T) {
+	trusted, err := ParseTrustedProxies([]string{"10.0.0.0/8", "192.0.2.1"})
+	if err != nil {
+		t.Fatalf("Failed to parse trusted proxies: %v", err)
+	}
+
+	tests := []struct {
+		name       string
+		remoteAddr string
+		xff        string
+		realIP     string
+		want       string
+	}{
+		{"direct client", "203.0.113.9:4000", "", "", "203.0.113.9"},
+		{"spoofed from untrusted peer", "203.0.113.9:4000", "198.51.100.1", "198.51.100.2", "203.0.113.9"},
+		{"through a trusted proxy", "10.0.0.2:4000", "198.51.100.1", "", "198.51.100.1"},
+		{"spoofed entry left of the client", "10.0.0.2:4000", "1.2.3.4, 198.51.100.1", "", "198.51.100.1"},
+		{"chain of trusted proxies", "192.0.2.1:4000", "198.51.100.1, 10.0.0.7", "", "198.51.100.1"},
+		{"real IP from a trusted proxy", "10.0.0.2:4000", "", "198.51.100.2", "198.51.100.2"},
+		{"invalid entry", "10.0.0.2:4000", "not-an-ip", "", "10.0.0.2"},
+		{"unix socket", "@", "198.51.100.1", "", "198.51.100.1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got string
+			handler := RealIP(trusted)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				got = ClientIP(r)
+			}))
+
+			req := httptest.NewRequest("GET", "/api/paste/abc123", nil)
+			req.RemoteAddr = tt.remoteAddr
+			if tt.xff != "" {
+				req.Header.Set("X-Forwarded-For", tt.xff)
+			}
+			if tt.realIP != "" {
+				req.Header.Set("X-Real-IP", tt.realIP)
+			}
+			handler.ServeHTTP(httptest.NewRecorder(), req)
+
+			if got != tt.want {
+				t.Errorf("Expected client IP %s, got %s", tt.want, got)
+			}
+		})
+	}
+
+	if _, err := ParseTrustedProxies([]string{"10.0.0.0/33"}); err == nil {
+		t.Error("Expected an invalid range to be refused")
+	}
+}
+
+func TestRequireToken(t *testing.T) {
+	handler := RequireToken("secret")(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	for header, want := range map[string]int{
+		"":              http.StatusUnauthorized,
+		"Bearer wrong":  http.StatusUnauthorized,
+		"secret":        http.StatusUnauthorized,
+		"Bearer secret": http.StatusOK,
+	} {
+		req := httptest.NewRequest("GET", "/metrics", nil)
+		if header != "" {
+			req.Header.Set("Authorization", header)
+		}
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		if rr.Code != want {
+			t.Errorf("Authorization %q: expected status %d, got %d", header, want, rr.Code)
+		}
+	}
+}
@@ -0,0 +1,110 @@
+package middleware
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/LonleySailor/privatepaste/backend/internal/metrics"
+)
+
+var slowClientRejections = metrics.NewCounter("pastevault_slow_client_rejections_total",
+	"Requests rejected for an overlong request line (request_line) or a body arriving too slowly (slow_body).", "reason")
+
+// ErrSlowBody is returned by reads of a request body that stopped arriving
+// or took too long overall, so handlers can answer 408 instead of treating
+// the truncated body as malformed
+var ErrSlowBody = errors.New("request body arrived too slowly")
+
+// LimitRequestLine middleware refuses requests whose target is longer than
+// maxBytes with 414. The server's MaxHeaderBytes bounds how much of a
+// request line is read at all; this keeps long URLs within it out of the
+// handlers.
+func LimitRequestLine(maxBytes int) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if len(r.RequestURI) > maxBytes {
+				slowClientRejections.Inc("request_line")
+				w.Header().Set("Connection", "close")
+				writeJSONError(w, http.StatusRequestURITooLong, "request_line_too_long", "Request line is too long")
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// SlowBodyGuard defends endpoints accepting a request body against
+// slowloris-style clients that open a request and then trickle its body to
+// hold the connection. It is meant to wrap the whole server handler, so no
+// route is left unguarded.
+type SlowBodyGuard struct {
+	chunkTimeout time.Duration // Longest wait for the next chunk of the body
+	bodyTimeout  time.Duration // Longest time for the whole body
+}
+
+// NewSlowBodyGuard creates a guard allowing chunkTimeout between chunks of
+// a request body and bodyTimeout for all of it
+func NewSlowBodyGuard(chunkTimeout, bodyTimeout time.Duration) *SlowBodyGuard {
+	return &SlowBodyGuard{chunkTimeout: chunkTimeout, bodyTimeout: bodyTimeout}
+}
+
+// Protect middleware that sets a read deadline on the connection before each
+// read of the request body. Reads past it fail with ErrSlowBody, and the
+// server closes the connection after the response. Unless the body was read
+// to the end the deadline stays in place, so the server cannot be held
+// draining the rest of it either; it sets its own deadlines before reading
+// the next request.
+func (g *SlowBodyGuard) Protect(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Body != nil && r.Body != http.NoBody {
+			r.Body = &deadlineBody{
+				ReadCloser:   r.Body,
+				controller:   http.NewResponseController(w),
+				chunkTimeout: g.chunkTimeout,
+				deadline:     time.Now().Add(g.bodyTimeout),
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// deadlineBody is a request body read under per-chunk and overall deadlines
+type deadlineBody struct {
+	io.ReadCloser
+	controller   *http.ResponseController
+	chunkTimeout time.Duration
+	deadline     time.Time
+	err          error // Sticky once the client was too slow
+}
+
+func (b *deadlineBody) Read(p []byte) (int, error) {
+	if b.err != nil {
+		return 0, b.err
+	}
+
+	deadline := time.Now().Add(b.chunkTimeout)
+	if deadline.After(b.deadline) {
+		deadline = b.deadline
+	}
+	// Writers that cannot reach the connection, such as test recorders,
+	// leave the body unguarded
+	if err := b.controller.SetReadDeadline(deadline); err != nil && !errors.Is(err, http.ErrNotSupported) {
+		return 0, err
+	}
+
+	n, err := b.ReadCloser.Read(p)
+	if errors.Is(err, os.ErrDeadlineExceeded) {
+		slowClientRejections.Inc("slow_body")
+		b.err = ErrSlowBody
+		return n, b.err
+	}
+	if err == io.EOF {
+		// The server watches a fully read connection for the client going
+		// away; a leftover deadline would cancel the request instead
+		b.controller.SetReadDeadline(time.Time{})
+	}
+	return n, err
+}
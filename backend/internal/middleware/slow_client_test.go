@@ -0,0 +1,119 @@
+package middleware
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// slowBodyServer serves a handler reading the whole body through the guard,
+// answering 408 when the client was too slow
+func slowBodyServer(guard *SlowBodyGuard) *httptest.Server {
+	return httptest.NewServer(guard.Protect(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		if errors.Is(err, ErrSlowBody) {
+			w.WriteHeader(http.StatusRequestTimeout)
+			return
+		}
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		fmt.Fprintf(w, "%d", len(body))
+	})))
+}
+
+// trickle sends a request announcing a 10 byte body, then sends up to that
+// many bytes of it one per interval, and returns the response status
+func trickle(t *testing.T, server *httptest.Server, interval time.Duration, bytes int) int {
+	t.Helper()
+
+	conn, err := net.Dial("tcp", server.Listener.Addr().String())
+	if err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	defer conn.Close()
+
+	fmt.Fprintf(conn, "POST /api/paste HTTP/1.1\r\nHost: example.com\r\nContent-Length: 10\r\n\r\n")
+	for i := 0; i < bytes; i++ {
+		time.Sleep(interval)
+		if _, err := conn.Write([]byte("x")); err != nil {
+			break // The server gave up on the body
+		}
+	}
+
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	resp, err := http.ReadResponse(bufio.NewReader(conn), nil)
+	if err != nil {
+		t.Fatalf("Failed to read response: %v", err)
+	}
+	resp.Body.Close()
+	return resp.StatusCode
+}
+
+func TestSlowBodyGuard(t *testing.T) {
+	server := slowBodyServer(NewSlowBodyGuard(200*time.Millisecond, 5*time.Second))
+	defer server.Close()
+
+	// A client sending steadily gets through
+	if status := trickle(t, server, 10*time.Millisecond, 10); status != http.StatusOK {
+		t.Errorf("Expected status %d for a steady body, got %d", http.StatusOK, status)
+	}
+
+	// A client that stops sending halfway is cut off
+	start := time.Now()
+	if status := trickle(t, server, 10*time.Millisecond, 5); status != http.StatusRequestTimeout {
+		t.Errorf("Expected status %d for a stalled body, got %d", http.StatusRequestTimeout, status)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("Expected the stalled body to time out after the chunk timeout, took %v", elapsed)
+	}
+}
+
+func TestSlowBodyGuard_BodyTimeout(t *testing.T) {
+	server := slowBodyServer(NewSlowBodyGuard(time.Second, 300*time.Millisecond))
+	defer server.Close()
+
+	// Every chunk arrives in time, but the body as a whole does not
+	if status := trickle(t, server, 50*time.Millisecond, 10); status != http.StatusRequestTimeout {
+		t.Errorf("Expected status %d for a body trickled past the overall timeout, got %d", http.StatusRequestTimeout, status)
+	}
+}
+
+func TestSlowBodyGuard_Recorder(t *testing.T) {
+	// Without a connection to guard the body is read as is
+	handler := NewSlowBodyGuard(time.Millisecond, time.Millisecond).Protect(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		if err != nil || string(body) != "content" {
+			t.Errorf("Expected the body, got %q (%v)", body, err)
+		}
+	}))
+
+	req := httptest.NewRequest("POST", "/api/paste", strings.NewReader("content"))
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+}
+
+func TestLimitRequestLine(t *testing.T) {
+	handler := LimitRequestLine(64)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest("GET", "/api/paste/abc123", nil))
+	if rr.Code != http.StatusOK {
+		t.Errorf("Expected status %d for a short request line, got %d", http.StatusOK, rr.Code)
+	}
+
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest("GET", "/api/paste/abc123?q="+strings.Repeat("a", 64), nil))
+	if rr.Code != http.StatusRequestURITooLong {
+		t.Errorf("Expected status %d for a long request line, got %d", http.StatusRequestURITooLong, rr.Code)
+	}
+}
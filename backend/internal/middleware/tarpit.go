@@ -0,0 +1,150 @@
+package middleware
+
+import (
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/LonleySailor/privatepaste/backend/internal/metrics"
+)
+
+// Tarpit metrics
+var (
+	tarpitMisses  = metrics.NewCounter("pastevault_paste_not_found_total", "Paste lookups that returned 404.")
+	tarpitDelayed = metrics.NewCounter("pastevault_tarpit_delayed_requests_total", "Requests slowed down because the client looked like an ID scanner.")
+	tarpitBans    = metrics.NewCounter("pastevault_tarpit_bans_total", "Clients temporarily banned for ID scanning.")
+	tarpitBanned  = metrics.NewCounter("pastevault_tarpit_rejected_requests_total", "Requests rejected because the client was banned.")
+)
+
+// Tarpit slows down and eventually bans clients that produce many 404s on
+// paste lookups, which is the signature of sequential ID enumeration
+type Tarpit struct {
+	mu      sync.Mutex
+	clients map[string]*scanState
+
+	delayThreshold int           // Misses per window before responses are slowed
+	banThreshold   int           // Misses per window before the client is banned
+	window         time.Duration // Window misses are counted in
+	banDuration    time.Duration
+	stepDelay      time.Duration // Added delay per miss over the delay threshold
+	maxDelay       time.Duration
+}
+
+type scanState struct {
+	misses      int
+	windowStart time.Time
+	bannedUntil time.Time
+}
+
+// NewTarpit creates a tarpit with the given thresholds
+func NewTarpit(delayThreshold, banThreshold int, window, banDuration time.Duration) *Tarpit {
+	t := &Tarpit{
+		clients:        make(map[string]*scanState),
+		delayThreshold: delayThreshold,
+		banThreshold:   banThreshold,
+		window:         window,
+		banDuration:    banDuration,
+		stepDelay:      500 * time.Millisecond,
+		maxDelay:       10 * time.Second,
+	}
+
+	go t.cleanup()
+
+	return t
+}
+
+// Protect middleware that tarpits or rejects clients scanning for paste IDs
+func (t *Tarpit) Protect(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ip := ClientIP(r)
+
+		delay, retryAfter := t.check(ip)
+		if retryAfter > 0 {
+			tarpitBanned.Inc()
+			w.Header().Set("Retry-After", strconv.Itoa(int(retryAfter.Seconds())+1))
+			writeJSONError(w, http.StatusTooManyRequests, "temporarily_banned",
+				"Too many requests for missing pastes; try again later")
+			return
+		}
+
+		if delay > 0 {
+			tarpitDelayed.Inc()
+			select {
+			case <-time.After(delay):
+			case <-r.Context().Done():
+				return
+			}
+		}
+
+		rec := newStatusRecorder(w)
+		next.ServeHTTP(rec, r)
+
+		if rec.status == http.StatusNotFound {
+			tarpitMisses.Inc()
+			t.recordMiss(ip)
+		}
+	})
+}
+
+// check returns the delay to apply to ip and, if it is banned, how long remains
+func (t *Tarpit) check(ip string) (time.Duration, time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	state, ok := t.clients[ip]
+	if !ok {
+		return 0, 0
+	}
+
+	now := time.Now()
+	if now.Before(state.bannedUntil) {
+		return 0, state.bannedUntil.Sub(now)
+	}
+	if now.Sub(state.windowStart) > t.window || state.misses < t.delayThreshold {
+		return 0, 0
+	}
+
+	delay := time.Duration(state.misses-t.delayThreshold+1) * t.stepDelay
+	if delay > t.maxDelay {
+		delay = t.maxDelay
+	}
+	return delay, 0
+}
+
+// recordMiss counts a 404 for ip and bans it once the ban threshold is reached
+func (t *Tarpit) recordMiss(ip string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := time.Now()
+	state, ok := t.clients[ip]
+	if !ok || now.Sub(state.windowStart) > t.window {
+		state = &scanState{windowStart: now}
+		t.clients[ip] = state
+	}
+
+	state.misses++
+	if state.misses >= t.banThreshold && now.After(state.bannedUntil) {
+		state.bannedUntil = now.Add(t.banDuration)
+		state.misses = 0
+		state.windowStart = now
+		tarpitBans.Inc()
+	}
+}
+
+// cleanup periodically forgets clients whose window and ban have lapsed
+func (t *Tarpit) cleanup() {
+	for {
+		time.Sleep(time.Minute)
+
+		t.mu.Lock()
+		now := time.Now()
+		for ip, state := range t.clients {
+			if now.Sub(state.windowStart) > t.window && now.After(state.bannedUntil) {
+				delete(t.clients, ip)
+			}
+		}
+		t.mu.Unlock()
+	}
+}
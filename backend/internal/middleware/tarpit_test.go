@@ -0,0 +1,63 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestTarpit(t *testing.T) {
+	tarpit := NewTarpit(2, 4, time.Minute, time.Minute)
+	tarpit.stepDelay = time.Millisecond
+
+	notFound := tarpit.Protect(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.NotFound(w, r)
+	}))
+
+	request := func() *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/api/paste/abc123", nil)
+		req.RemoteAddr = "203.0.113.9:4000"
+		rr := httptest.NewRecorder()
+		notFound.ServeHTTP(rr, req)
+		return rr
+	}
+
+	for i := 0; i < 4; i++ {
+		if rr := request(); rr.Code != http.StatusNotFound {
+			t.Fatalf("Request %d: expected 404 before ban, got %d", i+1, rr.Code)
+		}
+	}
+
+	if delay, _ := tarpit.check("203.0.113.9"); delay != 0 {
+		t.Errorf("Expected ban to replace the delay, got %v", delay)
+	}
+
+	rr := request()
+	if rr.Code != http.StatusTooManyRequests {
+		t.Fatalf("Expected banned client to get 429, got %d", rr.Code)
+	}
+	if rr.Header().Get("Retry-After") == "" {
+		t.Error("Expected Retry-After header on ban")
+	}
+
+	// Other clients are unaffected
+	if delay, retry := tarpit.check("198.51.100.1"); delay != 0 || retry != 0 {
+		t.Error("Expected unrelated client to be unaffected")
+	}
+}
+
+func TestTarpit_Delay(t *testing.T) {
+	tarpit := NewTarpit(2, 100, time.Minute, time.Minute)
+
+	tarpit.recordMiss("203.0.113.9")
+	if delay, _ := tarpit.check("203.0.113.9"); delay != 0 {
+		t.Errorf("Expected no delay below threshold, got %v", delay)
+	}
+
+	tarpit.recordMiss("203.0.113.9")
+	tarpit.recordMiss("203.0.113.9")
+	if delay, _ := tarpit.check("203.0.113.9"); delay != 2*tarpit.stepDelay {
+		t.Errorf("Expected delay of %v, got %v", 2*tarpit.stepDelay, delay)
+	}
+}
@@ -0,0 +1,133 @@
+package models
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"slices"
+	"strings"
+	"time"
+)
+
+// apiKeyPrefix marks PasteVault API keys so leaked keys are easy to spot
+const apiKeyPrefix = "pv_"
+
+// APIKey is a long-lived credential a user creates for tools such as the
+// browser extension. The key itself is only shown once, when created.
+type APIKey struct {
+	ID         int64      `json:"id" db:"id"`
+	UserID     int        `json:"-" db:"user_id"`
+	Name       string     `json:"name" db:"name"`
+	Prefix     string     `json:"prefix" db:"prefix"` // Start of the key, to tell keys apart
+	Scopes     []string   `json:"scopes,omitempty" db:"scopes"`
+	CreatedAt  time.Time  `json:"created_at" db:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty" db:"last_used_at"`
+}
+
+// Allows reports whether the key may be used for the given scope. Keys
+// created without scopes may be used for anything.
+func (k *APIKey) Allows(scope string) bool {
+	return len(k.Scopes) == 0 || slices.Contains(k.Scopes, scope)
+}
+
+// hashAPIKey returns the stored form of an API key
+func hashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// APIKeyRepository handles database operations for API keys
+type APIKeyRepository struct {
+	db *sql.DB
+}
+
+// NewAPIKeyRepository creates a new API key repository
+func NewAPIKeyRepository(db *sql.DB) *APIKeyRepository {
+	return &APIKeyRepository{db: db}
+}
+
+// Create generates and stores a new key for a user, limited to the given
+// scopes if there are any, returning the key along with its record. Only a
+// hash of the key is stored.
+func (r *APIKeyRepository) Create(userID int, name string, scopes []string) (string, *APIKey, error) {
+	secret := make([]byte, 24)
+	if _, err := rand.Read(secret); err != nil {
+		return "", nil, err
+	}
+	key := apiKeyPrefix + hex.EncodeToString(secret)
+
+	apiKey := &APIKey{UserID: userID, Name: name, Prefix: key[:len(apiKeyPrefix)+8], Scopes: scopes}
+	query := `
+		INSERT INTO api_keys (user_id, name, prefix, key_hash, scopes)
+		VALUES (?, ?, ?, ?, ?)
+		RETURNING id, created_at`
+
+	if err := r.db.QueryRow(query, userID, name, apiKey.Prefix, hashAPIKey(key), strings.Join(scopes, " ")).Scan(&apiKey.ID, &apiKey.CreatedAt); err != nil {
+		return "", nil, err
+	}
+	return key, apiKey, nil
+}
+
+// ListByUserID returns a user's API keys, newest first
+func (r *APIKeyRepository) ListByUserID(userID int) ([]*APIKey, error) {
+	query := `
+		SELECT id, user_id, name, prefix, scopes, created_at, last_used_at
+		FROM api_keys WHERE user_id = ?
+		ORDER BY created_at DESC, id DESC`
+
+	rows, err := r.db.Query(query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var keys []*APIKey
+	for rows.Next() {
+		key := &APIKey{}
+		var scopes string
+		if err := rows.Scan(&key.ID, &key.UserID, &key.Name, &key.Prefix, &scopes, &key.CreatedAt, &key.LastUsedAt); err != nil {
+			return nil, err
+		}
+		key.Scopes = strings.Fields(scopes)
+		keys = append(keys, key)
+	}
+	return keys, rows.Err()
+}
+
+// Delete revokes one of a user's API keys, reporting whether it existed
+func (r *APIKeyRepository) Delete(userID int, id int64) (bool, error) {
+	result, err := r.db.Exec(`DELETE FROM api_keys WHERE id = ? AND user_id = ?`, id, userID)
+	if err != nil {
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	return n > 0, err
+}
+
+// Authenticate looks up the record for a key and records its use. It returns
+// nil if the key is unknown or its owner is deactivated.
+func (r *APIKeyRepository) Authenticate(key string) (*APIKey, error) {
+	if !strings.HasPrefix(key, apiKeyPrefix) {
+		return nil, nil
+	}
+
+	apiKey := &APIKey{}
+	query := `
+		UPDATE api_keys SET last_used_at = ?
+		WHERE key_hash = ? AND user_id NOT IN (SELECT id FROM users WHERE deactivated_at IS NOT NULL)
+		RETURNING id, user_id, name, prefix, scopes, created_at, last_used_at`
+
+	var scopes string
+	err := r.db.QueryRow(query, time.Now().UTC(), hashAPIKey(key)).Scan(
+		&apiKey.ID, &apiKey.UserID, &apiKey.Name, &apiKey.Prefix, &scopes, &apiKey.CreatedAt, &apiKey.LastUsedAt,
+	)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	apiKey.Scopes = strings.Fields(scopes)
+	return apiKey, nil
+}
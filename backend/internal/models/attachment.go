@@ -0,0 +1,198 @@
+package models
+
+import (
+	"database/sql"
+	"time"
+)
+
+// Attachment is a small file attached to a paste. Its content is stored once
+// per distinct file, keyed by its SHA-256 digest.
+type Attachment struct {
+	PasteID     string
+	Name        string
+	ContentType string
+	Size        int64
+	SHA256      string
+	CreatedAt   time.Time
+}
+
+// AttachmentRepository handles database operations for paste attachments.
+// Their contents are kept in a BlobStore, inline in the database unless
+// another store is set.
+type AttachmentRepository struct {
+	db    *sql.DB
+	blobs BlobStore
+}
+
+// NewAttachmentRepository creates a new attachment repository
+func NewAttachmentRepository(db *sql.DB) *AttachmentRepository {
+	return &AttachmentRepository{db: db, blobs: NewInlineBlobStore(db)}
+}
+
+// SetBlobStore changes where attachment contents are stored
+func (r *AttachmentRepository) SetBlobStore(blobs BlobStore) {
+	r.blobs = blobs
+}
+
+// Put stores an attachment, replacing any previous one of the same name on
+// the paste. The content is stored before the attachment refers to it.
+func (r *AttachmentRepository) Put(attachment *Attachment, data []byte) error {
+	attachment.SHA256 = ContentSHA256(string(data))
+	attachment.Size = int64(len(data))
+	attachment.CreatedAt = time.Now().UTC()
+
+	// Content stored again is no longer waiting to be removed
+	if _, err := r.db.Exec(`DELETE FROM blob_deletions WHERE hash = ?`, attachment.SHA256); err != nil {
+		return err
+	}
+	if err := r.blobs.Put(attachment.SHA256, data); err != nil {
+		return err
+	}
+
+	var previous string
+	err := r.db.QueryRow(`SELECT hash FROM paste_attachments WHERE paste_id = ? AND name = ?`, attachment.PasteID, attachment.Name).Scan(&previous)
+	if err != nil && err != sql.ErrNoRows {
+		return err
+	}
+	_, err = r.db.Exec(`
+		INSERT INTO paste_attachments (paste_id, name, content_type, size, hash, created_at) VALUES (?, ?, ?, ?, ?, ?)
+		ON CONFLICT(paste_id, name) DO UPDATE SET
+			content_type = excluded.content_type, size = excluded.size, hash = excluded.hash, created_at = excluded.created_at`,
+		attachment.PasteID, attachment.Name, attachment.ContentType, attachment.Size, attachment.SHA256, attachment.CreatedAt)
+	if err != nil {
+		return err
+	}
+	if previous != "" && previous != attachment.SHA256 {
+		return r.deleteUnusedBlob(previous)
+	}
+	return nil
+}
+
+// Get retrieves a paste's attachment and its content by name, returning nil
+// if there is none
+func (r *AttachmentRepository) Get(pasteID, name string) (*Attachment, []byte, error) {
+	attachment := &Attachment{}
+	query := `
+		SELECT paste_id, name, content_type, size, hash, created_at
+		FROM paste_attachments WHERE paste_id = ? AND name = ?`
+
+	err := r.db.QueryRow(query, pasteID, name).Scan(
+		&attachment.PasteID,
+		&attachment.Name,
+		&attachment.ContentType,
+		&attachment.Size,
+		&attachment.SHA256,
+		&attachment.CreatedAt,
+	)
+	if err == sql.ErrNoRows {
+		return nil, nil, nil
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	data, err := r.blobs.Get(attachment.SHA256)
+	if err != nil || data == nil {
+		return nil, nil, err
+	}
+	return attachment, data, nil
+}
+
+// List retrieves a paste's attachments ordered by name
+func (r *AttachmentRepository) List(pasteID string) ([]*Attachment, error) {
+	query := `
+		SELECT paste_id, name, content_type, size, hash, created_at
+		FROM paste_attachments WHERE paste_id = ? ORDER BY name`
+
+	rows, err := r.db.Query(query, pasteID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var attachments []*Attachment
+	for rows.Next() {
+		attachment := &Attachment{}
+		if err := rows.Scan(
+			&attachment.PasteID,
+			&attachment.Name,
+			&attachment.ContentType,
+			&attachment.Size,
+			&attachment.SHA256,
+			&attachment.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		attachments = append(attachments, attachment)
+	}
+	return attachments, rows.Err()
+}
+
+// Delete removes a paste's attachment, reporting whether it existed
+func (r *AttachmentRepository) Delete(pasteID, name string) (bool, error) {
+	var hash string
+	err := r.db.QueryRow(`DELETE FROM paste_attachments WHERE paste_id = ? AND name = ? RETURNING hash`, pasteID, name).Scan(&hash)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, r.deleteUnusedBlob(hash)
+}
+
+// deleteUnusedBlob removes content no attachment refers to any more
+func (r *AttachmentRepository) deleteUnusedBlob(hash string) error {
+	var used bool
+	if err := r.db.QueryRow(`SELECT EXISTS (SELECT 1 FROM paste_attachments WHERE hash = ?)`, hash).Scan(&used); err != nil {
+		return err
+	}
+	if used {
+		return nil
+	}
+	return r.blobs.Delete(hash)
+}
+
+// PurgeDeletedBlobs removes up to limit contents recorded as unused when
+// their last attachment was deleted, including along with its paste, from
+// the blob store. It returns how many records it handled.
+func (r *AttachmentRepository) PurgeDeletedBlobs(limit int) (int, error) {
+	hashes, err := r.hashes(`SELECT hash FROM blob_deletions ORDER BY deleted_at LIMIT ?`, limit)
+	if err != nil {
+		return 0, err
+	}
+	for _, hash := range hashes {
+		if err := r.deleteUnusedBlob(hash); err != nil {
+			return 0, err
+		}
+		if _, err := r.db.Exec(`DELETE FROM blob_deletions WHERE hash = ?`, hash); err != nil {
+			return 0, err
+		}
+	}
+	return len(hashes), nil
+}
+
+// BlobHashes returns up to limit digests of stored attachment contents
+// ordered after the given one, for paging through all of them
+func (r *AttachmentRepository) BlobHashes(after string, limit int) ([]string, error) {
+	return r.hashes(`SELECT DISTINCT hash FROM paste_attachments WHERE hash > ? ORDER BY hash LIMIT ?`, after, limit)
+}
+
+// hashes runs a query selecting content digests
+func (r *AttachmentRepository) hashes(query string, args ...interface{}) ([]string, error) {
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var hashes []string
+	for rows.Next() {
+		var hash string
+		if err := rows.Scan(&hash); err != nil {
+			return nil, err
+		}
+		hashes = append(hashes, hash)
+	}
+	return hashes, rows.Err()
+}
@@ -0,0 +1,67 @@
+package models
+
+import (
+	"database/sql"
+	"time"
+)
+
+// Audited admin actions
+const (
+	AuditLegalExport = "legal_export" // Export of a user's data for a legal request
+)
+
+// AuditEvent is an entry in the audit log of sensitive admin actions
+type AuditEvent struct {
+	ID           int64     `json:"id"`
+	ActorID      *int      `json:"actor_id,omitempty"` // Admin who acted
+	Action       string    `json:"action"`
+	TargetUserID *int      `json:"target_user_id,omitempty"`
+	Details      string    `json:"details,omitempty"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// AuditRepository handles database operations for the audit log, which is
+// only ever appended to
+type AuditRepository struct {
+	db *sql.DB
+}
+
+// NewAuditRepository creates a new audit repository
+func NewAuditRepository(db *sql.DB) *AuditRepository {
+	return &AuditRepository{db: db}
+}
+
+// Record appends an event to the audit log, setting its ID and time
+func (r *AuditRepository) Record(event *AuditEvent) error {
+	query := `
+		INSERT INTO audit_log (actor_id, action, target_user_id, details)
+		VALUES (?, ?, ?, ?)
+		RETURNING id, created_at`
+
+	return r.db.QueryRow(query, event.ActorID, event.Action, event.TargetUserID, event.Details).Scan(&event.ID, &event.CreatedAt)
+}
+
+// ListByUserID returns the events a user took part in, as actor or target,
+// oldest first
+func (r *AuditRepository) ListByUserID(userID int) ([]*AuditEvent, error) {
+	query := `
+		SELECT id, actor_id, action, target_user_id, details, created_at
+		FROM audit_log WHERE actor_id = ? OR target_user_id = ?
+		ORDER BY id`
+
+	rows, err := r.db.Query(query, userID, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	events := []*AuditEvent{}
+	for rows.Next() {
+		event := &AuditEvent{}
+		if err := rows.Scan(&event.ID, &event.ActorID, &event.Action, &event.TargetUserID, &event.Details, &event.CreatedAt); err != nil {
+			return nil, err
+		}
+		events = append(events, event)
+	}
+	return events, rows.Err()
+}
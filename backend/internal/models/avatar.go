@@ -0,0 +1,94 @@
+package models
+
+import (
+	"database/sql"
+	"time"
+)
+
+// AvatarRepository handles database operations for user avatars. Images are
+// stored once per distinct content, keyed by their SHA-256 digest, so they
+// can be served from content-addressed URLs.
+type AvatarRepository struct {
+	db *sql.DB
+}
+
+// NewAvatarRepository creates a new avatar repository
+func NewAvatarRepository(db *sql.DB) *AvatarRepository {
+	return &AvatarRepository{db: db}
+}
+
+// Set stores a user's avatar, replacing any previous one, and returns the
+// digest it is addressed by
+func (r *AvatarRepository) Set(userID int, image []byte) (string, error) {
+	hash := ContentSHA256(string(image))
+
+	tx, err := r.db.Begin()
+	if err != nil {
+		return "", err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`INSERT OR IGNORE INTO avatar_blobs (hash, data) VALUES (?, ?)`, hash, image); err != nil {
+		return "", err
+	}
+	_, err = tx.Exec(`
+		INSERT INTO user_avatars (user_id, hash, updated_at) VALUES (?, ?, ?)
+		ON CONFLICT(user_id) DO UPDATE SET hash = excluded.hash, updated_at = excluded.updated_at`,
+		userID, hash, time.Now().UTC())
+	if err != nil {
+		return "", err
+	}
+	if err := deleteUnusedAvatars(tx); err != nil {
+		return "", err
+	}
+	return hash, tx.Commit()
+}
+
+// Delete removes a user's avatar, reporting whether they had one
+func (r *AvatarRepository) Delete(userID int) (bool, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(`DELETE FROM user_avatars WHERE user_id = ?`, userID)
+	if err != nil {
+		return false, err
+	}
+	if n, err := result.RowsAffected(); err != nil || n == 0 {
+		return false, err
+	}
+	if err := deleteUnusedAvatars(tx); err != nil {
+		return false, err
+	}
+	return true, tx.Commit()
+}
+
+// GetHash retrieves the digest of a user's avatar, returning "" if they have
+// none
+func (r *AvatarRepository) GetHash(userID int) (string, error) {
+	var hash string
+	err := r.db.QueryRow(`SELECT hash FROM user_avatars WHERE user_id = ?`, userID).Scan(&hash)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	return hash, err
+}
+
+// GetByHash retrieves an avatar image by its digest, returning nil if no
+// user has it
+func (r *AvatarRepository) GetByHash(hash string) ([]byte, error) {
+	var image []byte
+	err := r.db.QueryRow(`SELECT data FROM avatar_blobs WHERE hash = ?`, hash).Scan(&image)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return image, err
+}
+
+// deleteUnusedAvatars removes images no user has as their avatar any more
+func deleteUnusedAvatars(tx *sql.Tx) error {
+	_, err := tx.Exec(`DELETE FROM avatar_blobs WHERE hash NOT IN (SELECT hash FROM user_avatars)`)
+	return err
+}
@@ -0,0 +1,46 @@
+package models
+
+import "database/sql"
+
+// BlobStore keeps attachment contents keyed by their SHA-256 digest. The
+// attachment rows stay in the database whichever store holds the contents.
+type BlobStore interface {
+	Put(hash string, data []byte) error
+	Get(hash string) ([]byte, error) // Returns nil if there is no such content
+	Delete(hash string) error
+}
+
+// InlineBlobStore keeps contents in the database itself, the default
+type InlineBlobStore struct {
+	db *sql.DB
+}
+
+// NewInlineBlobStore creates a blob store in the attachment_blobs table
+func NewInlineBlobStore(db *sql.DB) *InlineBlobStore {
+	return &InlineBlobStore{db: db}
+}
+
+// Put stores content unless it is already stored
+func (s *InlineBlobStore) Put(hash string, data []byte) error {
+	_, err := s.db.Exec(`INSERT OR IGNORE INTO attachment_blobs (hash, data) VALUES (?, ?)`, hash, data)
+	return err
+}
+
+// Get retrieves content by its digest
+func (s *InlineBlobStore) Get(hash string) ([]byte, error) {
+	var data []byte
+	err := s.db.QueryRow(`SELECT data FROM attachment_blobs WHERE hash = ?`, hash).Scan(&data)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return data, err
+}
+
+// Delete removes content no attachment refers to. Triggers already do so
+// when the last attachment using it goes, so this rarely finds anything.
+func (s *InlineBlobStore) Delete(hash string) error {
+	_, err := s.db.Exec(`
+		DELETE FROM attachment_blobs
+		WHERE hash = ? AND NOT EXISTS (SELECT 1 FROM paste_attachments WHERE hash = ?)`, hash, hash)
+	return err
+}
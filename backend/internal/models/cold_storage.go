@@ -0,0 +1,249 @@
+package models
+
+import (
+	"bytes"
+	"compress/gzip"
+	"database/sql"
+	"io"
+	"time"
+)
+
+// coldContentColumn selects the compressed content of a cold paste, or NULL
+// for a hot one; it follows content in pasteColumns
+const coldContentColumn = `CASE WHEN cold_size IS NOT NULL THEN (SELECT data FROM paste_cold WHERE paste_cold.paste_id = pastes.id) END`
+
+// compressContent gzips paste content for the cold tier
+func compressContent(content string) ([]byte, error) {
+	var buf bytes.Buffer
+	zw, err := gzip.NewWriterLevel(&buf, gzip.BestCompression)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := io.WriteString(zw, content); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// decompressContent reverses compressContent
+func decompressContent(data []byte) (string, error) {
+	zr, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return "", err
+	}
+	defer zr.Close()
+	content, err := io.ReadAll(zr)
+	return string(content), err
+}
+
+// ArchiveCold moves up to limit unexpired pastes last read before the given
+// time to the compressed cold tier, returning how many were moved. A paste
+// never viewed counts as read when it was created. Cold pastes are read
+// transparently and stay in full-text search; GetByID moves them back when
+// they are read again. Up to limit cold pastes archived before the index
+// kept them are indexed again first.
+func (r *PasteRepository) ArchiveCold(unreadBefore time.Time, limit int) (int, error) {
+	if err := r.indexColdBacklog(limit); err != nil {
+		return 0, err
+	}
+
+	rows, err := r.db.Query(`
+		SELECT id, content FROM pastes
+		WHERE cold_size IS NULL AND content != ''
+			AND (expires_at IS NULL OR expires_at > datetime('now'))
+			AND COALESCE((SELECT MAX(day) FROM paste_views WHERE paste_views.paste_id = pastes.id), date(created_at)) < ?
+		LIMIT ?`, unreadBefore.UTC().Format("2006-01-02"), limit)
+	if err != nil {
+		return 0, err
+	}
+	contents := map[string]string{}
+	for rows.Next() {
+		var id, content string
+		if err := rows.Scan(&id, &content); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		contents[id] = content
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	tx, err := r.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	archived := 0
+	for id, content := range contents {
+		data, err := compressContent(content)
+		if err != nil {
+			return 0, err
+		}
+		// Pastes edited since they were selected stay hot
+		result, err := tx.Exec(`UPDATE pastes SET content = '', cold_size = ? WHERE id = ? AND content = ?`,
+			len(content), id, content)
+		if err != nil {
+			return 0, err
+		}
+		n, err := result.RowsAffected()
+		if err != nil {
+			return 0, err
+		}
+		if n == 0 {
+			continue
+		}
+		_, err = tx.Exec(`
+			INSERT INTO paste_cold (paste_id, data, indexed) VALUES (?, ?, 1)
+			ON CONFLICT(paste_id) DO UPDATE SET data = excluded.data, indexed = 1, archived_at = CURRENT_TIMESTAMP`,
+			id, data)
+		if err != nil {
+			return 0, err
+		}
+		archived++
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	return archived, nil
+}
+
+// rehydrate moves a paste read from the cold tier back to the hot tier
+func (r *PasteRepository) rehydrate(paste *Paste) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// The full-text triggers leave moves between tiers alone, so a paste
+	// whose terms were lost is indexed before it turns hot
+	var indexed bool
+	err = tx.QueryRow(`SELECT indexed FROM paste_cold WHERE paste_id = ?`, paste.ID).Scan(&indexed)
+	if err != nil && err != sql.ErrNoRows {
+		return err
+	}
+	if err == nil && !indexed {
+		if err := indexCold(tx, paste.ID, paste.Content); err != nil {
+			return err
+		}
+	}
+
+	if _, err := tx.Exec(`UPDATE pastes SET content = ?, cold_size = NULL WHERE id = ? AND cold_size IS NOT NULL`, paste.Content, paste.ID); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`DELETE FROM paste_cold WHERE paste_id = ?`, paste.ID); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	paste.Cold = false
+	return nil
+}
+
+// thawCold moves the cold pastes matching condition back to the hot tier.
+// The full-text index drops a paste's terms by reading its content, so cold
+// pastes are thawed before they are changed or deleted. Pastes whose cold
+// copy is missing are left as they are.
+func (r *PasteRepository) thawCold(condition string, args ...interface{}) error {
+	rows, err := r.db.Query(`SELECT id, `+coldContentColumn+` FROM pastes WHERE cold_size IS NOT NULL AND `+condition, args...)
+	if err != nil {
+		return err
+	}
+	var pastes []*Paste
+	for rows.Next() {
+		var id string
+		var data []byte
+		if err := rows.Scan(&id, &data); err != nil {
+			rows.Close()
+			return err
+		}
+		if data == nil {
+			continue
+		}
+		content, err := decompressContent(data)
+		if err != nil {
+			rows.Close()
+			return err
+		}
+		pastes = append(pastes, &Paste{ID: id, Content: content, Cold: true})
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, paste := range pastes {
+		if err := r.rehydrate(paste); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// indexColdBacklog indexes up to limit cold pastes archived before the
+// full-text index kept the terms of cold pastes
+func (r *PasteRepository) indexColdBacklog(limit int) error {
+	rows, err := r.db.Query(`
+		SELECT paste_id, data FROM paste_cold
+		WHERE NOT indexed AND paste_id IN (SELECT id FROM pastes WHERE cold_size IS NOT NULL)
+		LIMIT ?`, limit)
+	if err != nil {
+		return err
+	}
+	contents := map[string]string{}
+	for rows.Next() {
+		var id string
+		var data []byte
+		if err := rows.Scan(&id, &data); err != nil {
+			rows.Close()
+			return err
+		}
+		content, err := decompressContent(data)
+		if err != nil {
+			rows.Close()
+			return err
+		}
+		contents[id] = content
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	if len(contents) == 0 {
+		return nil
+	}
+
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for id, content := range contents {
+		if err := indexCold(tx, id, content); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// indexCold replaces the full-text entry of a cold paste, made from its
+// blank content, with one made from its decompressed content
+func indexCold(tx *sql.Tx, id, content string) error {
+	if _, err := tx.Exec(`DELETE FROM pastes_fts WHERE docid = (SELECT rowid FROM pastes WHERE id = ?)`, id); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`INSERT INTO pastes_fts (docid, content, language) SELECT rowid, ?, language FROM pastes WHERE id = ?`, content, id); err != nil {
+		return err
+	}
+	_, err := tx.Exec(`UPDATE paste_cold SET indexed = 1 WHERE paste_id = ?`, id)
+	return err
+}
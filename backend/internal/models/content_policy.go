@@ -0,0 +1,123 @@
+package models
+
+import (
+	"database/sql"
+	"strings"
+	"time"
+)
+
+// RoleAnonymous is the role content policies apply to pastes created
+// without an account
+const RoleAnonymous = "anonymous"
+
+// PolicyRoles are the roles content policies can be assigned to
+var PolicyRoles = []string{RoleAnonymous, RoleUser, RoleAdmin, RoleService}
+
+// ContentPolicy limits the pastes a role may create
+type ContentPolicy struct {
+	Role             string     `json:"role"`
+	MaxSize          int        `json:"max_size"`         // Bytes; 0 leaves the instance limit
+	AllowedExpiries  []string   `json:"allowed_expiries"` // Durations or "never"; empty allows any
+	AllowPublic      bool       `json:"allow_public"`
+	AllowAttachments bool       `json:"allow_attachments"`
+	UpdatedAt        *time.Time `json:"updated_at,omitempty"` // Unset for the default policy
+}
+
+// DefaultContentPolicy is the policy of a role no admin has assigned one:
+// everything the instance allows is permitted
+func DefaultContentPolicy(role string) *ContentPolicy {
+	return &ContentPolicy{
+		Role:             role,
+		AllowedExpiries:  []string{},
+		AllowPublic:      true,
+		AllowAttachments: true,
+	}
+}
+
+// ContentPolicyRepository handles database operations for content policies
+type ContentPolicyRepository struct {
+	db *sql.DB
+}
+
+// NewContentPolicyRepository creates a new content policy repository
+func NewContentPolicyRepository(db *sql.DB) *ContentPolicyRepository {
+	return &ContentPolicyRepository{db: db}
+}
+
+const selectContentPolicySQL = `
+	SELECT role, max_size, allowed_expiries, allow_public, allow_attachments, updated_at
+	FROM content_policies`
+
+func scanContentPolicy(row interface{ Scan(...interface{}) error }) (*ContentPolicy, error) {
+	policy := &ContentPolicy{}
+	var expiries string
+	err := row.Scan(&policy.Role, &policy.MaxSize, &expiries, &policy.AllowPublic, &policy.AllowAttachments, &policy.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	policy.AllowedExpiries = []string{}
+	if expiries != "" {
+		policy.AllowedExpiries = strings.Split(expiries, ",")
+	}
+	return policy, nil
+}
+
+// Get retrieves the policy assigned to a role, returning nil if there is none
+func (r *ContentPolicyRepository) Get(role string) (*ContentPolicy, error) {
+	policy, err := scanContentPolicy(r.db.QueryRow(selectContentPolicySQL+` WHERE role = ?`, role))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return policy, err
+}
+
+// List retrieves every assigned policy
+func (r *ContentPolicyRepository) List() ([]*ContentPolicy, error) {
+	rows, err := r.db.Query(selectContentPolicySQL + ` ORDER BY role`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	policies := []*ContentPolicy{}
+	for rows.Next() {
+		policy, err := scanContentPolicy(rows)
+		if err != nil {
+			return nil, err
+		}
+		policies = append(policies, policy)
+	}
+	return policies, rows.Err()
+}
+
+// Set assigns a policy to its role, replacing any previous one
+func (r *ContentPolicyRepository) Set(policy *ContentPolicy) error {
+	now := time.Now().UTC()
+	query := `
+		INSERT INTO content_policies (role, max_size, allowed_expiries, allow_public, allow_attachments, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?)
+		ON CONFLICT(role) DO UPDATE SET
+			max_size = excluded.max_size,
+			allowed_expiries = excluded.allowed_expiries,
+			allow_public = excluded.allow_public,
+			allow_attachments = excluded.allow_attachments,
+			updated_at = excluded.updated_at`
+
+	_, err := r.db.Exec(query, policy.Role, policy.MaxSize, strings.Join(policy.AllowedExpiries, ","),
+		policy.AllowPublic, policy.AllowAttachments, now)
+	if err == nil {
+		policy.UpdatedAt = &now
+	}
+	return err
+}
+
+// Delete removes the policy assigned to a role, restoring the default, and
+// reports whether there was one
+func (r *ContentPolicyRepository) Delete(role string) (bool, error) {
+	result, err := r.db.Exec(`DELETE FROM content_policies WHERE role = ?`, role)
+	if err != nil {
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	return n > 0, err
+}
@@ -0,0 +1,164 @@
+package models
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"strings"
+	"time"
+)
+
+// developerKeyPrefix tells developer keys apart from account API keys
+const developerKeyPrefix = "pvd_"
+
+// DeveloperKey is a self-service key for anonymous developers using the
+// public API of an open instance. It is not tied to an account and only
+// identifies the caller for rate limiting and usage reporting. The key itself
+// is only shown once, when created.
+type DeveloperKey struct {
+	ID         int64      `json:"id" db:"id"`
+	Name       string     `json:"name" db:"name"`
+	Prefix     string     `json:"prefix" db:"prefix"`
+	CreatedAt  time.Time  `json:"created_at" db:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty" db:"last_used_at"`
+}
+
+// DeveloperKeyUsage is the number of requests made with a key on one day
+type DeveloperKeyUsage struct {
+	Day      string `json:"day"`
+	Requests int64  `json:"requests"`
+	Limited  int64  `json:"limited"` // Requests refused by the key's rate limit
+}
+
+// DeveloperKeyRepository handles database operations for developer keys
+type DeveloperKeyRepository struct {
+	db *sql.DB
+}
+
+// NewDeveloperKeyRepository creates a new developer key repository
+func NewDeveloperKeyRepository(db *sql.DB) *DeveloperKeyRepository {
+	return &DeveloperKeyRepository{db: db}
+}
+
+// Create generates and stores a new developer key, returning the key along
+// with its record. Only a hash of the key is stored.
+func (r *DeveloperKeyRepository) Create(name string) (string, *DeveloperKey, error) {
+	secret := make([]byte, 24)
+	if _, err := rand.Read(secret); err != nil {
+		return "", nil, err
+	}
+	key := developerKeyPrefix + hex.EncodeToString(secret)
+
+	devKey := &DeveloperKey{Name: name, Prefix: key[:len(developerKeyPrefix)+8]}
+	query := `
+		INSERT INTO developer_keys (name, prefix, key_hash)
+		VALUES (?, ?, ?)
+		RETURNING id, created_at`
+
+	if err := r.db.QueryRow(query, name, devKey.Prefix, hashAPIKey(key)).Scan(&devKey.ID, &devKey.CreatedAt); err != nil {
+		return "", nil, err
+	}
+	return key, devKey, nil
+}
+
+// Authenticate looks up the record for a key. It returns nil if the key is
+// unknown. Use is recorded separately by RecordUsage.
+func (r *DeveloperKeyRepository) Authenticate(key string) (*DeveloperKey, error) {
+	if !strings.HasPrefix(key, developerKeyPrefix) {
+		return nil, nil
+	}
+
+	devKey := &DeveloperKey{}
+	query := `
+		SELECT id, name, prefix, created_at, last_used_at
+		FROM developer_keys WHERE key_hash = ?`
+
+	err := r.db.QueryRow(query, hashAPIKey(key)).Scan(
+		&devKey.ID, &devKey.Name, &devKey.Prefix, &devKey.CreatedAt, &devKey.LastUsedAt,
+	)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return devKey, nil
+}
+
+// GetByID retrieves a developer key by its ID, returning nil if it does not
+// exist
+func (r *DeveloperKeyRepository) GetByID(id int64) (*DeveloperKey, error) {
+	devKey := &DeveloperKey{}
+	query := `
+		SELECT id, name, prefix, created_at, last_used_at
+		FROM developer_keys WHERE id = ?`
+
+	err := r.db.QueryRow(query, id).Scan(
+		&devKey.ID, &devKey.Name, &devKey.Prefix, &devKey.CreatedAt, &devKey.LastUsedAt,
+	)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return devKey, nil
+}
+
+// RecordUsage counts a request made with a key towards today's usage
+func (r *DeveloperKeyRepository) RecordUsage(id int64, limited bool) error {
+	now := time.Now().UTC()
+	limitedCount := 0
+	if limited {
+		limitedCount = 1
+	}
+
+	query := `
+		INSERT INTO developer_key_usage (key_id, day, requests, limited)
+		VALUES (?, ?, 1, ?)
+		ON CONFLICT (key_id, day) DO UPDATE SET
+			requests = requests + 1,
+			limited = limited + excluded.limited`
+	if _, err := r.db.Exec(query, id, now.Format("2006-01-02"), limitedCount); err != nil {
+		return err
+	}
+
+	_, err := r.db.Exec(`UPDATE developer_keys SET last_used_at = ? WHERE id = ?`, now, id)
+	return err
+}
+
+// GetUsage returns a key's daily usage since the given day, oldest first
+func (r *DeveloperKeyRepository) GetUsage(id int64, since time.Time) ([]*DeveloperKeyUsage, error) {
+	query := `
+		SELECT day, requests, limited
+		FROM developer_key_usage
+		WHERE key_id = ? AND day >= ?
+		ORDER BY day`
+
+	rows, err := r.db.Query(query, id, since.UTC().Format("2006-01-02"))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	days := []*DeveloperKeyUsage{}
+	for rows.Next() {
+		d := &DeveloperKeyUsage{}
+		if err := rows.Scan(&d.Day, &d.Requests, &d.Limited); err != nil {
+			return nil, err
+		}
+		days = append(days, d)
+	}
+	return days, rows.Err()
+}
+
+// Delete revokes a developer key and its usage history, reporting whether
+// it existed
+func (r *DeveloperKeyRepository) Delete(id int64) (bool, error) {
+	result, err := r.db.Exec(`DELETE FROM developer_keys WHERE id = ?`, id)
+	if err != nil {
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	return n > 0, err
+}
@@ -0,0 +1,105 @@
+package models
+
+import (
+	"database/sql"
+	"time"
+)
+
+// FollowRepository handles database operations for users following each
+// other and the feeds that follows produce
+type FollowRepository struct {
+	db *sql.DB
+}
+
+// NewFollowRepository creates a new follow repository
+func NewFollowRepository(db *sql.DB) *FollowRepository {
+	return &FollowRepository{db: db}
+}
+
+// Follow makes followerID follow followeeID; following someone twice is not
+// an error
+func (r *FollowRepository) Follow(followerID, followeeID int) error {
+	_, err := r.db.Exec(`INSERT OR IGNORE INTO follows (follower_id, followee_id, created_at) VALUES (?, ?, ?)`,
+		followerID, followeeID, time.Now().UTC())
+	return err
+}
+
+// Unfollow stops followerID following followeeID, reporting whether they did
+func (r *FollowRepository) Unfollow(followerID, followeeID int) (bool, error) {
+	result, err := r.db.Exec(`DELETE FROM follows WHERE follower_id = ? AND followee_id = ?`, followerID, followeeID)
+	if err != nil {
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	return n > 0, err
+}
+
+// ListFollowing returns the usernames a user follows, alphabetically
+func (r *FollowRepository) ListFollowing(followerID int) ([]string, error) {
+	query := `
+		SELECT u.username
+		FROM follows f
+		JOIN users u ON u.id = f.followee_id
+		WHERE f.follower_id = ?
+		ORDER BY u.username COLLATE NOCASE`
+
+	rows, err := r.db.Query(query, followerID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	usernames := []string{}
+	for rows.Next() {
+		var username string
+		if err := rows.Scan(&username); err != nil {
+			return nil, err
+		}
+		usernames = append(usernames, username)
+	}
+	return usernames, rows.Err()
+}
+
+// feedConditions selects the pastes in a follower's feed: publicly listed
+// pastes by active users the follower follows
+const feedConditions = `
+	WHERE user_id IN (
+		SELECT f.followee_id FROM follows f
+		JOIN users u ON u.id = f.followee_id
+		WHERE f.follower_id = ? AND u.deactivated_at IS NULL
+	)
+	AND ` + publiclyListed
+
+// Feed returns a page of the pastes in a follower's feed, newest first,
+// together with the total number of pastes in it
+func (r *FollowRepository) Feed(followerID, limit, offset int) ([]*ListedPaste, int, error) {
+	var total int
+	if err := r.db.QueryRow(`SELECT COUNT(*) FROM pastes `+feedConditions, followerID).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	query := `
+		SELECT ` + pasteColumns + `, (SELECT username FROM users WHERE users.id = pastes.user_id)
+		FROM pastes
+		` + feedConditions + `
+		ORDER BY created_at DESC, rowid DESC
+		LIMIT ? OFFSET ?`
+
+	rows, err := r.db.Query(query, followerID, limit, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	pastes := []*ListedPaste{}
+	for rows.Next() {
+		entry := &ListedPaste{}
+		paste, err := scanPaste(withExtraColumns(rows, &entry.Username))
+		if err != nil {
+			return nil, 0, err
+		}
+		entry.Paste = paste
+		pastes = append(pastes, entry)
+	}
+	return pastes, total, rows.Err()
+}
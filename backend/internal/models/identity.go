@@ -0,0 +1,127 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// Single sign-on providers users can be linked to
+const (
+	IdentityProviderOIDC = "oidc"
+	IdentityProviderLDAP = "ldap"
+)
+
+// ErrIdentityUsernameTaken is returned when a new single sign-on user's
+// username belongs to an account that is not linked to them
+var ErrIdentityUsernameTaken = errors.New("username belongs to another account")
+
+// IdentityRepository handles database operations for users' links to single
+// sign-on providers
+type IdentityRepository struct {
+	db *sql.DB
+}
+
+// NewIdentityRepository creates a new identity repository
+func NewIdentityRepository(db *sql.DB) *IdentityRepository {
+	return &IdentityRepository{db: db}
+}
+
+// Provision returns the user linked to an identity at a provider, creating
+// the user on their first sign-in. A non-empty role is applied to the user
+// on every sign-in so it follows the provider's groups. An existing local
+// account with the same username is never linked, so a directory entry
+// cannot take it over. A user provisioned over SCIM is linked when its
+// externalId is the subject; usernames are derived from claims users may
+// change, so they never decide which account is claimed. Deactivated users
+// are returned for the caller to refuse.
+func (r *IdentityRepository) Provision(provider, subject, username, role string) (*User, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	now := time.Now().UTC()
+	user := &User{}
+	query := `
+		SELECT u.id, u.username, u.password_hash, u.role, u.created_at, u.deactivated_at
+		FROM user_identities i
+		JOIN users u ON u.id = i.user_id
+		WHERE i.provider = ? AND i.subject = ?`
+
+	err = tx.QueryRow(query, provider, subject).Scan(&user.ID, &user.Username, &user.PasswordHash, &user.Role, &user.CreatedAt, &user.DeactivatedAt)
+	switch {
+	case err == sql.ErrNoRows:
+		// Users provisioned over SCIM are claimed by their first sign-in
+		// from a provider they are not yet linked to, when the provider
+		// gave them its subject as their externalId
+		query = `
+			SELECT u.id, u.username, u.password_hash, u.role, u.created_at, u.deactivated_at
+			FROM users u
+			JOIN scim_users s ON s.user_id = u.id
+			WHERE s.external_id = ? AND NOT EXISTS (
+				SELECT 1 FROM user_identities i WHERE i.user_id = u.id AND i.provider = ?
+			)`
+		err = tx.QueryRow(query, subject, provider).Scan(&user.ID, &user.Username, &user.PasswordHash, &user.Role, &user.CreatedAt, &user.DeactivatedAt)
+		if err == nil {
+			if role != "" && role != user.Role {
+				if _, err := tx.Exec(`UPDATE users SET role = ? WHERE id = ?`, role, user.ID); err != nil {
+					return nil, err
+				}
+				user.Role = role
+			}
+			_, err = tx.Exec(`INSERT INTO user_identities (provider, subject, user_id, last_login_at) VALUES (?, ?, ?, ?)`,
+				provider, subject, user.ID, now)
+			if err != nil {
+				return nil, err
+			}
+			break
+		}
+		if err != sql.ErrNoRows {
+			return nil, err
+		}
+
+		var taken bool
+		if err := tx.QueryRow(`
+			SELECT EXISTS(SELECT 1 FROM users WHERE username = ? COLLATE NOCASE)
+				OR EXISTS(SELECT 1 FROM username_holds WHERE username = ? AND held_until > ?)`, username, username, now).Scan(&taken); err != nil {
+			return nil, err
+		}
+		if taken {
+			return nil, ErrIdentityUsernameTaken
+		}
+		if role == "" {
+			role = RoleUser
+		}
+		user = &User{Username: username, PasswordHash: disabledPasswordHash, Role: role}
+		err = tx.QueryRow(`INSERT INTO users (username, password_hash, role) VALUES (?, ?, ?) RETURNING id, created_at`,
+			username, disabledPasswordHash, role).Scan(&user.ID, &user.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		_, err = tx.Exec(`INSERT INTO user_identities (provider, subject, user_id, last_login_at) VALUES (?, ?, ?, ?)`,
+			provider, subject, user.ID, now)
+		if err != nil {
+			return nil, err
+		}
+	case err != nil:
+		return nil, err
+	default:
+		if role != "" && role != user.Role {
+			if _, err := tx.Exec(`UPDATE users SET role = ? WHERE id = ?`, role, user.ID); err != nil {
+				return nil, err
+			}
+			user.Role = role
+		}
+		_, err = tx.Exec(`UPDATE user_identities SET last_login_at = ? WHERE provider = ? AND subject = ?`, now, provider, subject)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
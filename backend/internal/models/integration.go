@@ -0,0 +1,191 @@
+package models
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"net/url"
+	"time"
+)
+
+// webhookSecretPrefix marks webhook signing secrets
+const webhookSecretPrefix = "whsec_"
+
+// integrationColumns are the columns scanned by scanIntegration
+const integrationColumns = `id, user_id, name, platform, webhook_url, created_at,
+	COALESCE(signing_secret, ''), COALESCE(previous_signing_secret, ''), previous_secret_expires_at`
+
+// Integration is a chat platform incoming webhook a user can share pastes to
+type Integration struct {
+	ID         int64     `json:"id" db:"id"`
+	UserID     int       `json:"-" db:"user_id"`
+	Name       string    `json:"name" db:"name"`
+	Platform   string    `json:"platform" db:"platform"`
+	WebhookURL string    `json:"-" db:"webhook_url"` // Acts as a secret, never exposed
+	CreatedAt  time.Time `json:"created_at" db:"created_at"`
+
+	// Deliveries are signed with SigningSecret, and with the previous secret
+	// until PreviousSecretExpiresAt so receivers can rotate without downtime
+	SigningSecret           string     `json:"-" db:"signing_secret"`
+	PreviousSigningSecret   string     `json:"-" db:"previous_signing_secret"`
+	PreviousSecretExpiresAt *time.Time `json:"-" db:"previous_secret_expires_at"`
+}
+
+// SigningSecrets returns the secrets deliveries made at now are signed with,
+// current secret first
+func (i *Integration) SigningSecrets(now time.Time) []string {
+	var secrets []string
+	if i.SigningSecret != "" {
+		secrets = append(secrets, i.SigningSecret)
+	}
+	if i.PreviousSigningSecret != "" && i.PreviousSecretExpiresAt != nil && now.Before(*i.PreviousSecretExpiresAt) {
+		secrets = append(secrets, i.PreviousSigningSecret)
+	}
+	return secrets
+}
+
+// newWebhookSecret generates a random webhook signing secret
+func newWebhookSecret() (string, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", err
+	}
+	return webhookSecretPrefix + hex.EncodeToString(secret), nil
+}
+
+// scanIntegration scans a row selected with integrationColumns
+func scanIntegration(row interface{ Scan(...interface{}) error }) (*Integration, error) {
+	i := &Integration{}
+	err := row.Scan(&i.ID, &i.UserID, &i.Name, &i.Platform, &i.WebhookURL, &i.CreatedAt,
+		&i.SigningSecret, &i.PreviousSigningSecret, &i.PreviousSecretExpiresAt)
+	return i, err
+}
+
+// WebhookHost returns the webhook's host, enough to recognise it in listings
+func (i *Integration) WebhookHost() string {
+	u, err := url.Parse(i.WebhookURL)
+	if err != nil {
+		return ""
+	}
+	return u.Host
+}
+
+// IntegrationRepository handles database operations for chat integrations
+type IntegrationRepository struct {
+	db *sql.DB
+}
+
+// NewIntegrationRepository creates a new integration repository
+func NewIntegrationRepository(db *sql.DB) *IntegrationRepository {
+	return &IntegrationRepository{db: db}
+}
+
+// Create stores a new integration with a freshly generated signing secret
+func (r *IntegrationRepository) Create(integration *Integration) error {
+	secret, err := newWebhookSecret()
+	if err != nil {
+		return err
+	}
+	integration.SigningSecret = secret
+
+	query := `
+		INSERT INTO integrations (user_id, name, platform, webhook_url, signing_secret)
+		VALUES (?, ?, ?, ?, ?)
+		RETURNING id, created_at`
+
+	return r.db.QueryRow(query, integration.UserID, integration.Name, integration.Platform, integration.WebhookURL, secret).
+		Scan(&integration.ID, &integration.CreatedAt)
+}
+
+// ListByUserID returns a user's integrations ordered by name
+func (r *IntegrationRepository) ListByUserID(userID int) ([]*Integration, error) {
+	query := `
+		SELECT ` + integrationColumns + `
+		FROM integrations WHERE user_id = ?
+		ORDER BY name`
+
+	rows, err := r.db.Query(query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var integrations []*Integration
+	for rows.Next() {
+		i, err := scanIntegration(rows)
+		if err != nil {
+			return nil, err
+		}
+		integrations = append(integrations, i)
+	}
+	return integrations, rows.Err()
+}
+
+// GetByName retrieves one of a user's integrations by name, returning nil if
+// it does not exist
+func (r *IntegrationRepository) GetByName(userID int, name string) (*Integration, error) {
+	query := `
+		SELECT ` + integrationColumns + `
+		FROM integrations WHERE user_id = ? AND name = ?`
+
+	i, err := scanIntegration(r.db.QueryRow(query, userID, name))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return i, nil
+}
+
+// GetByID retrieves one of a user's integrations, returning nil if it does
+// not exist
+func (r *IntegrationRepository) GetByID(userID int, id int64) (*Integration, error) {
+	query := `
+		SELECT ` + integrationColumns + `
+		FROM integrations WHERE user_id = ? AND id = ?`
+
+	i, err := scanIntegration(r.db.QueryRow(query, userID, id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return i, nil
+}
+
+// RotateSecret replaces the signing secret of one of a user's integrations
+// and returns the new secret. The old secret keeps signing deliveries for
+// grace. It returns an empty secret if the integration does not exist.
+func (r *IntegrationRepository) RotateSecret(userID int, id int64, grace time.Duration) (string, error) {
+	secret, err := newWebhookSecret()
+	if err != nil {
+		return "", err
+	}
+
+	query := `
+		UPDATE integrations SET
+			previous_signing_secret = signing_secret,
+			previous_secret_expires_at = ?,
+			signing_secret = ?
+		WHERE user_id = ? AND id = ?`
+	result, err := r.db.Exec(query, time.Now().UTC().Add(grace), secret, userID, id)
+	if err != nil {
+		return "", err
+	}
+	if n, err := result.RowsAffected(); err != nil || n == 0 {
+		return "", err
+	}
+	return secret, nil
+}
+
+// Delete removes one of a user's integrations, reporting whether it existed
+func (r *IntegrationRepository) Delete(userID int, id int64) (bool, error) {
+	result, err := r.db.Exec(`DELETE FROM integrations WHERE id = ? AND user_id = ?`, id, userID)
+	if err != nil {
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	return n > 0, err
+}
@@ -0,0 +1,205 @@
+package models
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+// maxIntegritySamples is how many affected rows are listed per issue
+const maxIntegritySamples = 10
+
+// IntegrityIssue is the result of one integrity check: how many rows are
+// affected, a few of them, and how many were repaired if repair was asked for
+type IntegrityIssue struct {
+	Check       string   `json:"check"`
+	Description string   `json:"description"`
+	Count       int      `json:"count"`
+	Samples     []string `json:"samples,omitempty"`
+	Repairable  bool     `json:"repairable"`
+	Repaired    int64    `json:"repaired,omitempty"`
+}
+
+// integrityCheck finds inconsistent rows with a query returning an
+// identifying sample per row, and fixes them with repair, if it can be
+// fixed without guessing
+type integrityCheck struct {
+	name        string
+	description string
+	query       string
+	repair      string
+}
+
+// integrityChecks are the checks run by IntegrityRepository, most of them
+// for rows whose foreign keys were not enforced when they were written
+var integrityChecks = []integrityCheck{
+	{
+		name:        "orphaned_attachments",
+		description: "Attachments of pastes that no longer exist; repair deletes them",
+		query:       `SELECT paste_id || '/' || name FROM paste_attachments WHERE paste_id NOT IN (SELECT id FROM pastes)`,
+		repair:      `DELETE FROM paste_attachments WHERE paste_id NOT IN (SELECT id FROM pastes)`,
+	},
+	{
+		name:        "pastes_of_deleted_users",
+		description: "Pastes owned by users that no longer exist; repair makes them anonymous, as deleting the user would have",
+		query:       `SELECT id FROM pastes WHERE user_id IS NOT NULL AND user_id NOT IN (SELECT id FROM users)`,
+		repair:      `UPDATE pastes SET user_id = NULL WHERE user_id IS NOT NULL AND user_id NOT IN (SELECT id FROM users)`,
+	},
+	{
+		name:        "invalid_expiry",
+		description: "Pastes whose expiry is not a valid time; repair expires them as of their creation so cleanup removes them",
+		query:       `SELECT id FROM pastes WHERE expires_at IS NOT NULL AND julianday(expires_at) IS NULL`,
+		repair:      `UPDATE pastes SET expires_at = created_at WHERE expires_at IS NOT NULL AND julianday(expires_at) IS NULL`,
+	},
+	{
+		name:        "missing_attachment_blobs",
+		description: "Attachments whose contents are missing from the blob store; repair deletes them",
+		query:       `SELECT paste_id || '/' || name FROM paste_attachments WHERE hash NOT IN (SELECT hash FROM attachment_blobs)`,
+		repair:      `DELETE FROM paste_attachments WHERE hash NOT IN (SELECT hash FROM attachment_blobs)`,
+	},
+	{
+		name:        "unreferenced_attachment_blobs",
+		description: "Stored attachment contents no attachment refers to; repair deletes them",
+		query:       `SELECT hash FROM attachment_blobs WHERE hash NOT IN (SELECT hash FROM paste_attachments)`,
+		repair:      `DELETE FROM attachment_blobs WHERE hash NOT IN (SELECT hash FROM paste_attachments)`,
+	},
+	{
+		name:        "missing_avatar_blobs",
+		description: "Avatars whose images are missing from the blob store; repair removes them so users get the default",
+		query:       `SELECT CAST(user_id AS TEXT) FROM user_avatars WHERE hash NOT IN (SELECT hash FROM avatar_blobs)`,
+		repair:      `DELETE FROM user_avatars WHERE hash NOT IN (SELECT hash FROM avatar_blobs)`,
+	},
+	{
+		name:        "unreferenced_avatar_blobs",
+		description: "Stored avatar images no user has, left behind by deleted accounts; repair deletes them",
+		query:       `SELECT hash FROM avatar_blobs WHERE hash NOT IN (SELECT hash FROM user_avatars)`,
+		repair:      `DELETE FROM avatar_blobs WHERE hash NOT IN (SELECT hash FROM user_avatars)`,
+	},
+	{
+		name:        "missing_cold_content",
+		description: "Pastes in the cold tier whose compressed content is missing; restore it from a backup",
+		query:       `SELECT id FROM pastes WHERE cold_size IS NOT NULL AND id NOT IN (SELECT paste_id FROM paste_cold)`,
+	},
+	{
+		name:        "stale_cold_content",
+		description: "Cold copies of content for pastes back in the hot tier; repair deletes them",
+		query:       `SELECT paste_id FROM paste_cold WHERE paste_id IN (SELECT id FROM pastes WHERE cold_size IS NULL)`,
+		repair:      `DELETE FROM paste_cold WHERE paste_id IN (SELECT id FROM pastes WHERE cold_size IS NULL)`,
+	},
+	{
+		name:        "foreign_key_violations",
+		description: "Other rows referring to rows that no longer exist; these need to be looked at by hand",
+		query: `SELECT v."table" || ' row ' || COALESCE(v.rowid, '?') || ' -> ' || v.parent FROM pragma_foreign_key_check() v
+			WHERE v."table" NOT IN ('paste_attachments', 'pastes')`,
+	},
+}
+
+// IntegrityRepository checks the database for rows that are inconsistent
+// with each other and optionally repairs them
+type IntegrityRepository struct {
+	db *sql.DB
+}
+
+// NewIntegrityRepository creates a new integrity repository
+func NewIntegrityRepository(db *sql.DB) *IntegrityRepository {
+	return &IntegrityRepository{db: db}
+}
+
+// Check runs every integrity check, returning one issue per check that
+// found something. With repair, repairable issues are fixed in a single
+// transaction; issues found by later checks may be caused by earlier
+// ones, so a repair should be followed by another check.
+func (r *IntegrityRepository) Check(repair bool) ([]*IntegrityIssue, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	issues := []*IntegrityIssue{}
+	for _, check := range integrityChecks {
+		issue := &IntegrityIssue{Check: check.name, Description: check.description, Repairable: check.repair != ""}
+		if err := collectSamples(tx, check.query, issue); err != nil {
+			return nil, fmt.Errorf("%s: %w", check.name, err)
+		}
+		if issue.Count == 0 {
+			continue
+		}
+		if repair && issue.Repairable {
+			result, err := tx.Exec(check.repair)
+			if err != nil {
+				return nil, fmt.Errorf("repairing %s: %w", check.name, err)
+			}
+			if issue.Repaired, err = result.RowsAffected(); err != nil {
+				return nil, err
+			}
+		}
+		issues = append(issues, issue)
+	}
+
+	// Blob contents are checked against the digest they are stored under
+	for _, check := range []string{"corrupt_attachment_blobs", "corrupt_avatar_blobs"} {
+		table := strings.TrimPrefix(check, "corrupt_")
+		corrupt, err := corruptBlobs(tx, table)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", check, err)
+		}
+		if len(corrupt) > 0 {
+			issues = append(issues, &IntegrityIssue{
+				Check:       check,
+				Description: "Stored contents that do not match their digest; restore them from a backup",
+				Count:       len(corrupt),
+				Samples:     corrupt[:min(len(corrupt), maxIntegritySamples)],
+			})
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return issues, nil
+}
+
+// collectSamples counts the rows returned by query, keeping the first few
+func collectSamples(tx *sql.Tx, query string, issue *IntegrityIssue) error {
+	rows, err := tx.Query(query)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var sample string
+		if err := rows.Scan(&sample); err != nil {
+			return err
+		}
+		if issue.Count < maxIntegritySamples {
+			issue.Samples = append(issue.Samples, sample)
+		}
+		issue.Count++
+	}
+	return rows.Err()
+}
+
+// corruptBlobs returns the hashes of contents in a blob table whose SHA-256
+// digest differs from the hash they are stored under
+func corruptBlobs(tx *sql.Tx, table string) ([]string, error) {
+	rows, err := tx.Query(`SELECT hash, data FROM ` + table + ` ORDER BY hash`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var corrupt []string
+	for rows.Next() {
+		var hash string
+		var data []byte
+		if err := rows.Scan(&hash, &data); err != nil {
+			return nil, err
+		}
+		if ContentSHA256(string(data)) != hash {
+			corrupt = append(corrupt, hash)
+		}
+	}
+	return corrupt, rows.Err()
+}
@@ -0,0 +1,194 @@
+package models
+
+import (
+	"database/sql"
+	"time"
+)
+
+// Job is a unit of background work, such as a webhook delivery or an email,
+// persisted so it survives restarts
+type Job struct {
+	ID          int64     `json:"id" db:"id"`
+	Type        string    `json:"type" db:"type"`
+	Payload     string    `json:"payload" db:"payload"` // JSON encoded
+	Status      string    `json:"status" db:"status"`
+	Attempts    int       `json:"attempts" db:"attempts"`
+	MaxAttempts int       `json:"max_attempts" db:"max_attempts"`
+	RunAt       time.Time `json:"run_at" db:"run_at"`
+	LastError   string    `json:"last_error,omitempty" db:"last_error"`
+	CreatedAt   time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at" db:"updated_at"`
+}
+
+// Job statuses. Dead jobs exhausted their attempts and wait for an admin.
+const (
+	JobPending = "pending"
+	JobRunning = "running"
+	JobDone    = "done"
+	JobDead    = "dead"
+)
+
+// jobColumns lists the columns selected for a full job row, in scan order
+const jobColumns = `id, type, payload, status, attempts, max_attempts, run_at, last_error, created_at, updated_at`
+
+// scanJob scans a row selected with jobColumns into a Job
+func scanJob(row rowScanner) (*Job, error) {
+	job := &Job{}
+	err := row.Scan(
+		&job.ID,
+		&job.Type,
+		&job.Payload,
+		&job.Status,
+		&job.Attempts,
+		&job.MaxAttempts,
+		&job.RunAt,
+		&job.LastError,
+		&job.CreatedAt,
+		&job.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return job, nil
+}
+
+// JobRepository handles database operations for background jobs. Times are
+// stored in UTC so they compare correctly as text.
+type JobRepository struct {
+	db *sql.DB
+}
+
+// NewJobRepository creates a new job repository
+func NewJobRepository(db *sql.DB) *JobRepository {
+	return &JobRepository{db: db}
+}
+
+// Enqueue stores a new pending job that may run from runAt
+func (r *JobRepository) Enqueue(job *Job) error {
+	query := `
+		INSERT INTO jobs (type, payload, status, max_attempts, run_at)
+		VALUES (?, ?, ?, ?, ?)
+		RETURNING id, created_at, updated_at`
+
+	job.Status = JobPending
+	job.RunAt = job.RunAt.UTC()
+	return r.db.QueryRow(query, job.Type, job.Payload, job.Status, job.MaxAttempts, job.RunAt).
+		Scan(&job.ID, &job.CreatedAt, &job.UpdatedAt)
+}
+
+// ClaimNext marks the next due pending job as running and returns it, or nil
+// if no job is due
+func (r *JobRepository) ClaimNext() (*Job, error) {
+	query := `
+		UPDATE jobs
+		SET status = ?, attempts = attempts + 1, updated_at = ?
+		WHERE id = (
+			SELECT id FROM jobs
+			WHERE status = ? AND run_at <= ?
+			ORDER BY run_at
+			LIMIT 1
+		)
+		RETURNING ` + jobColumns
+
+	now := time.Now().UTC()
+	job, err := scanJob(r.db.QueryRow(query, JobRunning, now, JobPending, now))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return job, err
+}
+
+// Complete marks a job as done
+func (r *JobRepository) Complete(id int64) error {
+	_, err := r.db.Exec(`UPDATE jobs SET status = ?, last_error = '', updated_at = ? WHERE id = ?`,
+		JobDone, time.Now().UTC(), id)
+	return err
+}
+
+// Reschedule returns a failed job to the queue to run again at runAt
+func (r *JobRepository) Reschedule(id int64, runAt time.Time, lastError string) error {
+	_, err := r.db.Exec(`UPDATE jobs SET status = ?, run_at = ?, last_error = ?, updated_at = ? WHERE id = ?`,
+		JobPending, runAt.UTC(), lastError, time.Now().UTC(), id)
+	return err
+}
+
+// Bury moves a job that exhausted its attempts to the dead-letter list
+func (r *JobRepository) Bury(id int64, lastError string) error {
+	_, err := r.db.Exec(`UPDATE jobs SET status = ?, last_error = ?, updated_at = ? WHERE id = ?`,
+		JobDead, lastError, time.Now().UTC(), id)
+	return err
+}
+
+// Retry requeues a dead job with a fresh set of attempts. It returns false if
+// the job does not exist or is not dead.
+func (r *JobRepository) Retry(id int64) (bool, error) {
+	now := time.Now().UTC()
+	result, err := r.db.Exec(`UPDATE jobs SET status = ?, attempts = 0, run_at = ?, updated_at = ? WHERE id = ? AND status = ?`,
+		JobPending, now, now, id, JobDead)
+	if err != nil {
+		return false, err
+	}
+	rows, err := result.RowsAffected()
+	return rows > 0, err
+}
+
+// RequeueRunning returns jobs left running by a previous process to the queue
+func (r *JobRepository) RequeueRunning() (int64, error) {
+	result, err := r.db.Exec(`UPDATE jobs SET status = ?, updated_at = ? WHERE status = ?`,
+		JobPending, time.Now().UTC(), JobRunning)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// CountDue returns how many pending jobs are due to run, i.e. the queue backlog
+func (r *JobRepository) CountDue() (int, error) {
+	var count int
+	err := r.db.QueryRow(`SELECT COUNT(*) FROM jobs WHERE status = ? AND run_at <= ?`,
+		JobPending, time.Now().UTC()).Scan(&count)
+	return count, err
+}
+
+// CountByStatus returns how many jobs have the given status
+func (r *JobRepository) CountByStatus(status string) (int, error) {
+	var count int
+	err := r.db.QueryRow(`SELECT COUNT(*) FROM jobs WHERE status = ?`, status).Scan(&count)
+	return count, err
+}
+
+// ListDead returns dead jobs, most recently failed first
+func (r *JobRepository) ListDead(limit, offset int) ([]*Job, error) {
+	query := `
+		SELECT ` + jobColumns + `
+		FROM jobs
+		WHERE status = ?
+		ORDER BY updated_at DESC
+		LIMIT ? OFFSET ?`
+
+	rows, err := r.db.Query(query, JobDead, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	jobs := []*Job{}
+	for rows.Next() {
+		job, err := scanJob(rows)
+		if err != nil {
+			return nil, err
+		}
+		jobs = append(jobs, job)
+	}
+
+	return jobs, rows.Err()
+}
+
+// DeleteFinished removes completed jobs last updated before the cutoff
+func (r *JobRepository) DeleteFinished(before time.Time) (int64, error) {
+	result, err := r.db.Exec(`DELETE FROM jobs WHERE status = ? AND updated_at < ?`, JobDone, before.UTC())
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
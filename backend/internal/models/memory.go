@@ -0,0 +1,267 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ErrPasteExists is returned by MemoryPasteRepository.Create for a duplicate ID
+var ErrPasteExists = errors.New("paste already exists")
+
+// MemoryPasteRepository is an in-memory paste repository with the same
+// semantics as PasteRepository, for handler tests and running without a
+// database. Pastes are copied on the way in and out so callers cannot modify
+// stored state behind its back.
+type MemoryPasteRepository struct {
+	mu     sync.RWMutex
+	pastes map[string]*memoryPaste
+	seq    int64
+	now    func() time.Time
+}
+
+// memoryPaste keeps insertion order to break ties between equal timestamps
+type memoryPaste struct {
+	paste *Paste
+	seq   int64
+}
+
+// NewMemoryPasteRepository creates an empty in-memory paste repository
+func NewMemoryPasteRepository() *MemoryPasteRepository {
+	return &MemoryPasteRepository{
+		pastes: make(map[string]*memoryPaste),
+		now:    time.Now,
+	}
+}
+
+// clonePaste copies a paste including the values behind its pointer fields
+func clonePaste(p *Paste) *Paste {
+	c := *p
+	if p.ExpiresAt != nil {
+		t := *p.ExpiresAt
+		c.ExpiresAt = &t
+	}
+	if p.PasswordHash != nil {
+		s := *p.PasswordHash
+		c.PasswordHash = &s
+	}
+	if p.UserID != nil {
+		id := *p.UserID
+		c.UserID = &id
+	}
+	if p.ParentID != nil {
+		s := *p.ParentID
+		c.ParentID = &s
+	}
+	if p.CreatorIPHash != nil {
+		s := *p.CreatorIPHash
+		c.CreatorIPHash = &s
+	}
+	if p.MaxUnlockAttempts != nil {
+		n := *p.MaxUnlockAttempts
+		c.MaxUnlockAttempts = &n
+	}
+	if p.AccessWindow != nil {
+		w := *p.AccessWindow
+		c.AccessWindow = &w
+	}
+	c.AllowedNetworks = append([]string(nil), p.AllowedNetworks...)
+	c.AllowedCountries = append([]string(nil), p.AllowedCountries...)
+	c.EmbedDomains = append([]string(nil), p.EmbedDomains...)
+	return &c
+}
+
+// expired reports whether a paste is past its expiry time
+func (r *MemoryPasteRepository) expired(p *Paste) bool {
+	return p.ExpiresAt != nil && !p.ExpiresAt.After(r.now())
+}
+
+// Create stores a new paste and sets its creation time
+func (r *MemoryPasteRepository) Create(paste *Paste) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, exists := r.pastes[paste.ID]; exists {
+		return ErrPasteExists
+	}
+	paste.CreatedAt = r.now()
+	paste.ContentSHA256 = ContentSHA256(paste.Content)
+	r.seq++
+	r.pastes[paste.ID] = &memoryPaste{paste: clonePaste(paste), seq: r.seq}
+	return nil
+}
+
+// GetByID retrieves a paste by its ID, returning nil if it does not exist.
+// Like PasteRepository it returns expired pastes that have not been cleaned up.
+func (r *MemoryPasteRepository) GetByID(id string) (*Paste, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	stored, ok := r.pastes[id]
+	if !ok {
+		return nil, nil
+	}
+	return clonePaste(stored.paste), nil
+}
+
+// GetByContentHash retrieves a paste with the given content digest that may
+// be served by its hash, as PasteRepository does, returning nil if there is
+// none
+func (r *MemoryPasteRepository) GetByContentHash(hash string) (*Paste, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, stored := range r.pastes {
+		p := stored.paste
+		if p.ContentSHA256 == hash && !p.IsPrivate() && !p.HasPassword() && !p.RequireSignedURLs &&
+			p.AccessWindow == nil && !p.IsLocationRestricted() && !r.expired(p) {
+			return clonePaste(p), nil
+		}
+	}
+	return nil, nil
+}
+
+// GetByUserID retrieves a page of a user's pastes, newest first
+func (r *MemoryPasteRepository) GetByUserID(userID int, limit, offset int) ([]*Paste, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var owned []*memoryPaste
+	for _, stored := range r.pastes {
+		if stored.paste.UserID != nil && *stored.paste.UserID == userID {
+			owned = append(owned, stored)
+		}
+	}
+	sort.Slice(owned, func(i, j int) bool {
+		a, b := owned[i], owned[j]
+		if !a.paste.CreatedAt.Equal(b.paste.CreatedAt) {
+			return a.paste.CreatedAt.After(b.paste.CreatedAt)
+		}
+		return a.seq > b.seq
+	})
+
+	if offset >= len(owned) {
+		return nil, nil
+	}
+	owned = owned[offset:]
+	if limit >= 0 && limit < len(owned) {
+		owned = owned[:limit]
+	}
+
+	pastes := make([]*Paste, len(owned))
+	for i, stored := range owned {
+		pastes[i] = clonePaste(stored.paste)
+	}
+	return pastes, nil
+}
+
+// ListByUserIDAfter returns up to limit of a user's pastes with IDs greater
+// than afterID, in ID order
+func (r *MemoryPasteRepository) ListByUserIDAfter(userID int, afterID string, limit int) ([]*Paste, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var pastes []*Paste
+	for id, stored := range r.pastes {
+		if id > afterID && stored.paste.UserID != nil && *stored.paste.UserID == userID {
+			pastes = append(pastes, clonePaste(stored.paste))
+		}
+	}
+	sort.Slice(pastes, func(i, j int) bool { return pastes[i].ID < pastes[j].ID })
+	if limit >= 0 && limit < len(pastes) {
+		pastes = pastes[:limit]
+	}
+	return pastes, nil
+}
+
+// Update updates the editable fields of a paste. It returns sql.ErrNoRows if
+// the paste does not exist or has expired.
+func (r *MemoryPasteRepository) Update(paste *Paste) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stored, ok := r.pastes[paste.ID]
+	if !ok || r.expired(stored.paste) {
+		return sql.ErrNoRows
+	}
+
+	updated := clonePaste(paste)
+	p := stored.paste
+	p.Content = updated.Content
+	p.ContentSHA256 = ContentSHA256(updated.Content)
+	paste.ContentSHA256 = p.ContentSHA256
+	p.Language = updated.Language
+	p.ExpiresAt = updated.ExpiresAt
+	p.PasswordHash = updated.PasswordHash
+	p.Theme = updated.Theme
+	p.LineNumbers = updated.LineNumbers
+	p.WordWrap = updated.WordWrap
+	return nil
+}
+
+// Delete deletes a paste by its ID
+func (r *MemoryPasteRepository) Delete(id string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delete(r.pastes, id)
+	return nil
+}
+
+// RecordFailedUnlock counts a wrong password for a paste, destroying it once
+// it reaches its maximum number of failed unlock attempts
+func (r *MemoryPasteRepository) RecordFailedUnlock(id string) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stored, ok := r.pastes[id]
+	if !ok || stored.paste.MaxUnlockAttempts == nil {
+		return false, nil
+	}
+	stored.paste.FailedUnlocks++
+	if stored.paste.FailedUnlocks < *stored.paste.MaxUnlockAttempts {
+		return false, nil
+	}
+	delete(r.pastes, id)
+	return true, nil
+}
+
+// DeleteExpired deletes all expired pastes
+func (r *MemoryPasteRepository) DeleteExpired() (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var deleted int64
+	for id, stored := range r.pastes {
+		if r.expired(stored.paste) {
+			delete(r.pastes, id)
+			deleted++
+		}
+	}
+	return deleted, nil
+}
+
+// Exists checks if a paste ID already exists
+func (r *MemoryPasteRepository) Exists(id string) (bool, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	_, ok := r.pastes[id]
+	return ok, nil
+}
+
+// CountByUserID returns the total number of pastes for a user
+func (r *MemoryPasteRepository) CountByUserID(userID int) (int, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	count := 0
+	for _, stored := range r.pastes {
+		if stored.paste.UserID != nil && *stored.paste.UserID == userID {
+			count++
+		}
+	}
+	return count, nil
+}
@@ -0,0 +1,103 @@
+package models
+
+import (
+	"database/sql"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestMemoryPasteRepositoryUserPagination(t *testing.T) {
+	repo := NewMemoryPasteRepository()
+	clock := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	repo.now = func() time.Time { return clock }
+
+	owner, other := 1, 2
+	for i := 0; i < 5; i++ {
+		clock = clock.Add(time.Minute)
+		if err := repo.Create(&Paste{ID: fmt.Sprintf("own%03d", i), Content: "x", UserID: &owner}); err != nil {
+			t.Fatalf("Failed to create paste: %v", err)
+		}
+	}
+	repo.Create(&Paste{ID: "oth000", Content: "x", UserID: &other})
+	repo.Create(&Paste{ID: "anon00", Content: "x"})
+
+	if err := repo.Create(&Paste{ID: "own000", Content: "dup"}); err != ErrPasteExists {
+		t.Errorf("Expected ErrPasteExists for a duplicate ID, got %v", err)
+	}
+
+	page, _ := repo.GetByUserID(owner, 2, 1)
+	if len(page) != 2 || page[0].ID != "own003" || page[1].ID != "own002" {
+		t.Errorf("Expected own003 and own002 newest first, got %v", page)
+	}
+	if count, _ := repo.CountByUserID(owner); count != 5 {
+		t.Errorf("Expected 5 pastes for the owner, got %d", count)
+	}
+	if page, _ := repo.GetByUserID(owner, 10, 10); len(page) != 0 {
+		t.Errorf("Expected an empty page past the end, got %d pastes", len(page))
+	}
+}
+
+func TestMemoryPasteRepositoryExpiry(t *testing.T) {
+	repo := NewMemoryPasteRepository()
+	now := time.Now()
+	past, future := now.Add(-time.Minute), now.Add(time.Hour)
+
+	repo.Create(&Paste{ID: "old001", Content: "x", ExpiresAt: &past})
+	repo.Create(&Paste{ID: "new001", Content: "x", ExpiresAt: &future})
+	repo.Create(&Paste{ID: "keep01", Content: "x"})
+
+	// Expired pastes are still readable until cleanup, but cannot be edited
+	if paste, _ := repo.GetByID("old001"); paste == nil {
+		t.Error("Expected expired paste to be returned before cleanup")
+	}
+	if err := repo.Update(&Paste{ID: "old001", Content: "y"}); err != sql.ErrNoRows {
+		t.Errorf("Expected sql.ErrNoRows updating an expired paste, got %v", err)
+	}
+
+	if deleted, _ := repo.DeleteExpired(); deleted != 1 {
+		t.Errorf("Expected 1 expired paste deleted, got %d", deleted)
+	}
+	if exists, _ := repo.Exists("old001"); exists {
+		t.Error("Expected expired paste to be gone")
+	}
+	if exists, _ := repo.Exists("new001"); !exists {
+		t.Error("Expected unexpired paste to remain")
+	}
+}
+
+func TestMemoryPasteRepositoryContentHash(t *testing.T) {
+	repo := NewMemoryPasteRepository()
+	repo.Create(&Paste{ID: "win001", Content: "shared", Visibility: VisibilityPublic, AccessWindow: &AccessWindow{Start: 0, End: 60}})
+	repo.Create(&Paste{ID: "net001", Content: "shared", Visibility: VisibilityPublic, AllowedNetworks: []string{"10.0.0.0/8"}})
+	repo.Create(&Paste{ID: "geo001", Content: "shared", Visibility: VisibilityPublic, AllowedCountries: []string{"DE"}})
+
+	// Pastes limited to an access window, networks or countries are not
+	// served by their hash
+	hash := ContentSHA256("shared")
+	if paste, _ := repo.GetByContentHash(hash); paste != nil {
+		t.Errorf("Expected restricted pastes not to be found by hash, got %s", paste.ID)
+	}
+
+	repo.Create(&Paste{ID: "pub001", Content: "shared", Visibility: VisibilityPublic})
+	if paste, _ := repo.GetByContentHash(hash); paste == nil || paste.ID != "pub001" {
+		t.Errorf("Expected the unrestricted paste by hash, got %+v", paste)
+	}
+}
+
+func TestMemoryPasteRepositoryCopies(t *testing.T) {
+	repo := NewMemoryPasteRepository()
+	paste := &Paste{ID: "abc123", Content: "original"}
+	repo.Create(paste)
+
+	paste.Content = "changed by caller"
+	stored, _ := repo.GetByID("abc123")
+	if stored.Content != "original" {
+		t.Error("Expected the repository to keep its own copy on create")
+	}
+
+	stored.Content = "changed again"
+	if again, _ := repo.GetByID("abc123"); again.Content != "original" {
+		t.Error("Expected the repository to return copies")
+	}
+}
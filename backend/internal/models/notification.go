@@ -0,0 +1,241 @@
+package models
+
+import (
+	"database/sql"
+	"strings"
+	"time"
+)
+
+// Channels the weekly digest can be delivered through
+const (
+	DigestOff     = "off"
+	DigestEmail   = "email"   // To the user's verified email address
+	DigestWebhook = "webhook" // To one of the user's integrations
+)
+
+// NotificationPreferences are a user's choices about what they are sent
+type NotificationPreferences struct {
+	UserID              int        `json:"-" db:"user_id"`
+	WeeklyDigest        string     `json:"weekly_digest" db:"weekly_digest"`
+	DigestIntegrationID *int64     `json:"digest_integration_id,omitempty" db:"digest_integration_id"`
+	LastDigestAt        *time.Time `json:"last_digest_at,omitempty" db:"last_digest_at"`
+}
+
+// DigestRecipient is a user due a weekly digest along with where to send it
+type DigestRecipient struct {
+	UserID        int
+	Username      string
+	Channel       string
+	Email         string // Verified address, empty if the user has none
+	IntegrationID *int64
+}
+
+// ExpiringPaste is a paste listed in a digest because it expires soon
+type ExpiringPaste struct {
+	ID        string    `json:"id"`
+	Language  string    `json:"language,omitempty"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// ActivityDigest summarizes a user's paste activity over a period
+type ActivityDigest struct {
+	Views        int64            `json:"views"`
+	TopPasteID   string           `json:"top_paste_id,omitempty"` // Most viewed paste of the period
+	TopViews     int64            `json:"top_views,omitempty"`
+	ExpiringSoon []*ExpiringPaste `json:"expiring_soon"`
+	Storage      *StorageUsage    `json:"storage"`
+}
+
+// NotificationRepository handles database operations for notification
+// preferences and the activity summarized in digests
+type NotificationRepository struct {
+	db *sql.DB
+}
+
+// NewNotificationRepository creates a new notification repository
+func NewNotificationRepository(db *sql.DB) *NotificationRepository {
+	return &NotificationRepository{db: db}
+}
+
+// GetPreferences returns a user's notification preferences; users who never
+// set any have every notification off
+func (r *NotificationRepository) GetPreferences(userID int) (*NotificationPreferences, error) {
+	prefs := &NotificationPreferences{UserID: userID, WeeklyDigest: DigestOff}
+	query := `
+		SELECT weekly_digest, digest_integration_id, last_digest_at
+		FROM notification_preferences WHERE user_id = ?`
+
+	err := r.db.QueryRow(query, userID).Scan(&prefs.WeeklyDigest, &prefs.DigestIntegrationID, &prefs.LastDigestAt)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, err
+	}
+	return prefs, nil
+}
+
+// SetPreferences stores a user's notification preferences. When the digest is
+// first turned on it is sent at the next run.
+func (r *NotificationRepository) SetPreferences(prefs *NotificationPreferences) error {
+	query := `
+		INSERT INTO notification_preferences (user_id, weekly_digest, digest_integration_id)
+		VALUES (?, ?, ?)
+		ON CONFLICT (user_id) DO UPDATE SET
+			weekly_digest = excluded.weekly_digest,
+			digest_integration_id = excluded.digest_integration_id,
+			updated_at = CURRENT_TIMESTAMP`
+
+	_, err := r.db.Exec(query, prefs.UserID, prefs.WeeklyDigest, prefs.DigestIntegrationID)
+	return err
+}
+
+// DueDigests returns up to limit users with the digest on whose last one was
+// sent before the given time, or who never had one
+func (r *NotificationRepository) DueDigests(sentBefore time.Time, limit int) ([]*DigestRecipient, error) {
+	query := `
+		SELECT u.id, u.username, n.weekly_digest, COALESCE(u.verified_email, ''), n.digest_integration_id
+		FROM notification_preferences n
+		JOIN users u ON u.id = n.user_id
+		WHERE n.weekly_digest != ? AND (n.last_digest_at IS NULL OR n.last_digest_at < ?)
+		ORDER BY n.last_digest_at IS NOT NULL, n.last_digest_at
+		LIMIT ?`
+
+	rows, err := r.db.Query(query, DigestOff, sentBefore.UTC(), limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var recipients []*DigestRecipient
+	for rows.Next() {
+		d := &DigestRecipient{}
+		if err := rows.Scan(&d.UserID, &d.Username, &d.Channel, &d.Email, &d.IntegrationID); err != nil {
+			return nil, err
+		}
+		recipients = append(recipients, d)
+	}
+	return recipients, rows.Err()
+}
+
+// MarkDigestSent records when a user's digest was last sent, or skipped
+func (r *NotificationRepository) MarkDigestSent(userID int, at time.Time) error {
+	_, err := r.db.Exec(`UPDATE notification_preferences SET last_digest_at = ? WHERE user_id = ?`, at.UTC(), userID)
+	return err
+}
+
+// ViewCount is how often a paste has been viewed, in total and as raw
+// content; the rest were JSON fetches
+type ViewCount struct {
+	Total int64
+	Raw   int64
+}
+
+// RecordView counts a view of a paste towards today's total, and towards
+// its raw views if the raw content was fetched
+func (r *NotificationRepository) RecordView(pasteID string, raw bool) error {
+	rawViews := 0
+	if raw {
+		rawViews = 1
+	}
+	query := `
+		INSERT INTO paste_views (paste_id, day, views, raw_views)
+		VALUES (?, ?, 1, ?)
+		ON CONFLICT (paste_id, day) DO UPDATE SET views = views + 1, raw_views = raw_views + excluded.raw_views`
+
+	_, err := r.db.Exec(query, pasteID, time.Now().UTC().Format("2006-01-02"), rawViews)
+	return err
+}
+
+// ViewCounts returns the all-time views of each of the given pastes. Pastes
+// never viewed are left out.
+func (r *NotificationRepository) ViewCounts(pasteIDs []string) (map[string]ViewCount, error) {
+	counts := map[string]ViewCount{}
+	if len(pasteIDs) == 0 {
+		return counts, nil
+	}
+
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(pasteIDs)), ", ")
+	args := make([]interface{}, len(pasteIDs))
+	for i, id := range pasteIDs {
+		args[i] = id
+	}
+	rows, err := r.db.Query(`
+		SELECT paste_id, SUM(views), SUM(raw_views)
+		FROM paste_views WHERE paste_id IN (`+placeholders+`)
+		GROUP BY paste_id`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id string
+		var count ViewCount
+		if err := rows.Scan(&id, &count.Total, &count.Raw); err != nil {
+			return nil, err
+		}
+		counts[id] = count
+	}
+	return counts, rows.Err()
+}
+
+// BuildDigest summarizes a user's activity: views of their pastes since the
+// given day, pastes expiring before expiringBefore and their storage usage
+func (r *NotificationRepository) BuildDigest(userID int, since, expiringBefore time.Time) (*ActivityDigest, error) {
+	digest := &ActivityDigest{ExpiringSoon: []*ExpiringPaste{}}
+
+	query := `
+		SELECT v.paste_id, SUM(v.views) AS total
+		FROM paste_views v
+		JOIN pastes p ON p.id = v.paste_id
+		WHERE p.user_id = ? AND v.day >= ?
+		GROUP BY v.paste_id
+		ORDER BY total DESC, v.paste_id`
+
+	rows, err := r.db.Query(query, userID, since.UTC().Format("2006-01-02"))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id string
+		var views int64
+		if err := rows.Scan(&id, &views); err != nil {
+			return nil, err
+		}
+		if digest.TopPasteID == "" {
+			digest.TopPasteID, digest.TopViews = id, views
+		}
+		digest.Views += views
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	query = `
+		SELECT id, COALESCE(language, ''), expires_at
+		FROM pastes
+		WHERE user_id = ? AND expires_at IS NOT NULL AND expires_at > datetime('now') AND expires_at <= ?
+		ORDER BY expires_at
+		LIMIT 20`
+
+	expiring, err := r.db.Query(query, userID, expiringBefore.UTC())
+	if err != nil {
+		return nil, err
+	}
+	defer expiring.Close()
+	for expiring.Next() {
+		p := &ExpiringPaste{}
+		if err := expiring.Scan(&p.ID, &p.Language, &p.ExpiresAt); err != nil {
+			return nil, err
+		}
+		digest.ExpiringSoon = append(digest.ExpiringSoon, p)
+	}
+	if err := expiring.Err(); err != nil {
+		return nil, err
+	}
+
+	digest.Storage, err = NewStorageRepository(r.db).GetUserUsage(userID)
+	if err != nil {
+		return nil, err
+	}
+	return digest, nil
+}
@@ -1,24 +1,256 @@
 package models
 
 import (
+	"crypto/sha256"
 	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strings"
 	"time"
+
+	"github.com/LonleySailor/privatepaste/backend/pkg/simhash"
 )
 
 // Paste represents a paste in the system
 type Paste struct {
-	ID           string     `json:"id" db:"id"`
-	Content      string     `json:"content" db:"content"`
-	Language     string     `json:"language,omitempty" db:"language"`
-	CreatedAt    time.Time  `json:"created_at" db:"created_at"`
-	ExpiresAt    *time.Time `json:"expires_at,omitempty" db:"expires_at"`
-	PasswordHash *string    `json:"-" db:"password_hash"` // Never expose password hash in JSON
-	UserID       *int       `json:"user_id,omitempty" db:"user_id"`
+	ID            string     `json:"id" db:"id"`
+	Content       string     `json:"content" db:"content"`
+	Language      string     `json:"language,omitempty" db:"language"`
+	CreatedAt     time.Time  `json:"created_at" db:"created_at"`
+	ExpiresAt     *time.Time `json:"expires_at,omitempty" db:"expires_at"`
+	PasswordHash  *string    `json:"-" db:"password_hash"` // Never expose password hash in JSON
+	PasswordHint  string     `json:"-" db:"password_hint"` // Shown, rate limited, when the password is requested
+	UserID        *int       `json:"user_id,omitempty" db:"user_id"`
+	DoNotTrack    bool       `json:"do_not_track" db:"do_not_track"`     // Disables view counting and access logging
+	Kind          string     `json:"kind" db:"kind"`                     // Content type: "text" or "diff"
+	Visibility    string     `json:"visibility" db:"visibility"`         // "public", "unlisted" or "private"
+	ParentID      *string    `json:"parent_id,omitempty" db:"parent_id"` // Source paste this one was derived from
+	CreatorIPHash *string    `json:"-" db:"creator_ip_hash"`             // Salted hash, only exposed to admins
+	ContentSHA256 string     `json:"-" db:"content_sha256"`              // Hex digest of Content, set by the repository
+
+	// Raw and download access requires an expiring signed URL
+	RequireSignedURLs bool `json:"require_signed_urls" db:"require_signed_urls"`
+
+	// Asks search engines not to index the paste and keeps it out of public
+	// listings even when public
+	NoIndex bool `json:"noindex" db:"noindex"`
+
+	// Failed unlock attempts after which the paste is destroyed; nil allows
+	// any number
+	MaxUnlockAttempts *int `json:"max_unlock_attempts,omitempty" db:"max_unlock_attempts"`
+	FailedUnlocks     int  `json:"-" db:"failed_unlocks"`
+
+	// Daily UTC time range outside of which only the owner may view the
+	// paste; nil allows viewing at any time
+	AccessWindow *AccessWindow `json:"access_window,omitempty"`
+
+	// Only clients in one of these CIDR ranges or ISO countries may view the
+	// paste, besides its owner; both empty allows everyone
+	AllowedNetworks  []string `json:"allowed_networks,omitempty" db:"allowed_networks"`
+	AllowedCountries []string `json:"allowed_countries,omitempty" db:"allowed_countries"`
+
+	// Domains whose pages may embed the paste's rendered views; empty leaves
+	// embedding unrestricted by referrer and forbids framing
+	EmbedDomains []string `json:"embed_domains,omitempty" db:"embed_domains"`
+
+	// Overlay rendered HTML views with the viewer's identity
+	Watermark bool `json:"watermark" db:"watermark"`
+
+	// Render preferences chosen by the creator
+	Theme       string `json:"theme,omitempty" db:"theme"`
+	LineNumbers bool   `json:"line_numbers" db:"line_numbers"`
+	WordWrap    bool   `json:"word_wrap" db:"word_wrap"`
+
+	// Optional detached signature of Content supplied by the creator
+	Signature       string `json:"-" db:"signature"`
+	SignatureFormat string `json:"signature_format,omitempty" db:"signature_format"` // "pgp" or "minisign"
+
+	// Content was read from the compressed cold tier
+	Cold bool `json:"-"`
+}
+
+// pasteColumns lists the columns selected for a full paste row, in scan order
+const pasteColumns = `id, content, ` + coldContentColumn + `, language, created_at, expires_at, password_hash, user_id, do_not_track,
+	theme, line_numbers, word_wrap, kind, parent_id, creator_ip_hash, visibility, require_signed_urls,
+	noindex, COALESCE(password_hint, ''), max_unlock_attempts, failed_unlocks,
+	access_window_start, access_window_end, COALESCE(allowed_networks, ''), COALESCE(allowed_countries, ''),
+	COALESCE(embed_domains, ''), watermark, COALESCE(content_sha256, ''), COALESCE(signature, ''), COALESCE(signature_format, '')`
+
+// rowScanner is satisfied by both *sql.Row and *sql.Rows
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// extraColumnScanner scans columns selected after pasteColumns
+type extraColumnScanner struct {
+	row   rowScanner
+	extra []interface{}
+}
+
+// withExtraColumns lets scanPaste read a row with more columns than
+// pasteColumns, storing the rest in extra
+func withExtraColumns(row rowScanner, extra ...interface{}) rowScanner {
+	return extraColumnScanner{row: row, extra: extra}
+}
+
+// Scan scans the paste columns into dest and the rest into the extras
+func (s extraColumnScanner) Scan(dest ...interface{}) error {
+	return s.row.Scan(append(dest, s.extra...)...)
+}
+
+// scanPaste scans a row selected with pasteColumns into a Paste
+func scanPaste(row rowScanner) (*Paste, error) {
+	paste := &Paste{}
+	var windowStart, windowEnd sql.NullInt64
+	var allowedNetworks, allowedCountries, embedDomains string
+	var coldContent []byte
+	err := row.Scan(
+		&paste.ID,
+		&paste.Content,
+		&coldContent,
+		&paste.Language,
+		&paste.CreatedAt,
+		&paste.ExpiresAt,
+		&paste.PasswordHash,
+		&paste.UserID,
+		&paste.DoNotTrack,
+		&paste.Theme,
+		&paste.LineNumbers,
+		&paste.WordWrap,
+		&paste.Kind,
+		&paste.ParentID,
+		&paste.CreatorIPHash,
+		&paste.Visibility,
+		&paste.RequireSignedURLs,
+		&paste.NoIndex,
+		&paste.PasswordHint,
+		&paste.MaxUnlockAttempts,
+		&paste.FailedUnlocks,
+		&windowStart,
+		&windowEnd,
+		&allowedNetworks,
+		&allowedCountries,
+		&embedDomains,
+		&paste.Watermark,
+		&paste.ContentSHA256,
+		&paste.Signature,
+		&paste.SignatureFormat,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if coldContent != nil {
+		if paste.Content, err = decompressContent(coldContent); err != nil {
+			return nil, fmt.Errorf("reading cold content of paste %s: %w", paste.ID, err)
+		}
+		paste.Cold = true
+	}
+	if windowStart.Valid && windowEnd.Valid {
+		paste.AccessWindow = &AccessWindow{Start: int(windowStart.Int64), End: int(windowEnd.Int64)}
+	}
+	paste.AllowedNetworks = splitList(allowedNetworks)
+	paste.AllowedCountries = splitList(allowedCountries)
+	paste.EmbedDomains = splitList(embedDomains)
+	return paste, nil
+}
+
+// splitList splits a comma-separated column, returning nil for an empty one
+func splitList(value string) []string {
+	if value == "" {
+		return nil
+	}
+	return strings.Split(value, ",")
+}
+
+// ContentSHA256 returns the hex SHA-256 digest content is addressed by
+func ContentSHA256(content string) string {
+	sum := sha256.Sum256([]byte(content))
+	return hex.EncodeToString(sum[:])
+}
+
+// contentSimhash returns the SimHash fingerprint of content as stored, in
+// a signed column
+func contentSimhash(content string) int64 {
+	return int64(simhash.Fingerprint(content))
+}
+
+// PasteSurrogateKey is the CDN surrogate key tagging cached responses of a
+// paste, so they can be purged when it is deleted or expires
+func PasteSurrogateKey(id string) string {
+	return "paste-" + id
+}
+
+// BlobSurrogateKey is the CDN surrogate key tagging cached responses
+// addressed by a content hash
+func BlobSurrogateKey(hash string) string {
+	return "blob-" + hash
+}
+
+// ArchiveSurrogateKey is the CDN surrogate key tagging cached archive pages
+// of the month (UTC) containing t, so they can be purged when one of its
+// pastes is deleted or expires
+func ArchiveSurrogateKey(t time.Time) string {
+	return "archive-" + t.UTC().Format("2006-01")
+}
+
+// Paste kinds describing how content should be interpreted
+const (
+	KindText = "text"
+	KindDiff = "diff"
+)
+
+// Paste visibilities. Public pastes may appear in listings, unlisted ones are
+// reachable only by link, and private ones only by their owner.
+const (
+	VisibilityPublic   = "public"
+	VisibilityUnlisted = "unlisted"
+	VisibilityPrivate  = "private"
+)
+
+// publiclyListed is the condition for a paste to appear in public listings
+// and feeds: public, not expired, not marked noindex, and readable from
+// anywhere without a password or signed URL
+const publiclyListed = `visibility = 'public' AND (password_hash IS NULL OR password_hash = '')
+	AND require_signed_urls = 0 AND noindex = 0 AND allowed_networks IS NULL AND allowed_countries IS NULL AND (expires_at IS NULL OR expires_at > datetime('now'))`
+
+// ListedPaste is a publicly listed paste together with its author's
+// username, which is empty for anonymous pastes
+type ListedPaste struct {
+	*Paste
+	Username string
+}
+
+// Detached signature formats
+const (
+	SignatureFormatPGP      = "pgp"
+	SignatureFormatMinisign = "minisign"
+)
+
+// ReadReplica picks the connection used for read-only queries
+type ReadReplica interface {
+	ReadDB() *sql.DB
+}
+
+// PasteCache keeps recently created and read pastes in memory. Pastes are
+// copied in and out, so callers may modify the ones they get.
+type PasteCache interface {
+	Get(id string) (*Paste, bool)
+	Add(paste *Paste)
+	Remove(id string)
+}
+
+// ReadOnlySwitch reports whether writes to the instance are paused
+type ReadOnlySwitch interface {
+	Enabled() bool
 }
 
 // PasteRepository handles database operations for pastes
 type PasteRepository struct {
-	db *sql.DB
+	db       *sql.DB
+	replica  ReadReplica    // Optional; nil sends reads to the primary
+	cache    PasteCache     // Optional; nil reads every paste from the database
+	readOnly ReadOnlySwitch // Optional; while enabled, reads leave cold pastes cold
 }
 
 // NewPasteRepository creates a new paste repository
@@ -26,13 +258,51 @@ func NewPasteRepository(db *sql.DB) *PasteRepository {
 	return &PasteRepository{db: db}
 }
 
+// SetReadReplica routes lookups and listings through the given replica
+func (r *PasteRepository) SetReadReplica(replica ReadReplica) {
+	r.replica = replica
+}
+
+// SetCache serves lookups by ID from the given cache, which new pastes are
+// added to as they are created. Pastes changed or deleted through the
+// repository are removed from it; changes made around the repository show
+// once the cached copy expires.
+func (r *PasteRepository) SetCache(cache PasteCache) {
+	r.cache = cache
+}
+
+// SetReadOnlyMode stops lookups from moving cold pastes back to the hot
+// tier while writes are paused
+func (r *PasteRepository) SetReadOnlyMode(mode ReadOnlySwitch) {
+	r.readOnly = mode
+}
+
+// reader returns the connection for read-only queries
+func (r *PasteRepository) reader() *sql.DB {
+	if r.replica != nil {
+		return r.replica.ReadDB()
+	}
+	return r.db
+}
+
 // Create creates a new paste in the database
 func (r *PasteRepository) Create(paste *Paste) error {
 	query := `
-		INSERT INTO pastes (id, content, language, expires_at, password_hash, user_id)
-		VALUES (?, ?, ?, ?, ?, ?)
+		INSERT INTO pastes (id, content, language, expires_at, password_hash, user_id, do_not_track,
+			theme, line_numbers, word_wrap, kind, parent_id, creator_ip_hash, visibility,
+			require_signed_urls, noindex, password_hint, max_unlock_attempts, access_window_start, access_window_end,
+			allowed_networks, allowed_countries, embed_domains, watermark, content_sha256, signature, signature_format,
+			content_simhash)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, NULLIF(?, ''), ?, ?, ?,
+			NULLIF(?, ''), NULLIF(?, ''), NULLIF(?, ''), ?, ?, NULLIF(?, ''), NULLIF(?, ''), ?)
 		RETURNING created_at`
 
+	var windowStart, windowEnd *int
+	if paste.AccessWindow != nil {
+		windowStart, windowEnd = &paste.AccessWindow.Start, &paste.AccessWindow.End
+	}
+
+	paste.ContentSHA256 = ContentSHA256(paste.Content)
 	err := r.db.QueryRow(
 		query,
 		paste.ID,
@@ -41,29 +311,59 @@ func (r *PasteRepository) Create(paste *Paste) error {
 		paste.ExpiresAt,
 		paste.PasswordHash,
 		paste.UserID,
+		paste.DoNotTrack,
+		paste.Theme,
+		paste.LineNumbers,
+		paste.WordWrap,
+		paste.Kind,
+		paste.ParentID,
+		paste.CreatorIPHash,
+		paste.Visibility,
+		paste.RequireSignedURLs,
+		paste.NoIndex,
+		paste.PasswordHint,
+		paste.MaxUnlockAttempts,
+		windowStart,
+		windowEnd,
+		strings.Join(paste.AllowedNetworks, ","),
+		strings.Join(paste.AllowedCountries, ","),
+		strings.Join(paste.EmbedDomains, ","),
+		paste.Watermark,
+		paste.ContentSHA256,
+		paste.Signature,
+		paste.SignatureFormat,
+		contentSimhash(paste.Content),
 	).Scan(&paste.CreatedAt)
+	if err != nil {
+		return err
+	}
 
-	return err
+	// The first views of a paste follow shortly after it is shared
+	if r.cache != nil {
+		r.cache.Add(paste)
+	}
+	return nil
 }
 
 // GetByID retrieves a paste by its ID
 func (r *PasteRepository) GetByID(id string) (*Paste, error) {
-	paste := &Paste{}
+	if r.cache != nil {
+		if paste, ok := r.cache.Get(id); ok {
+			return paste, nil
+		}
+	}
+
 	query := `
-		SELECT id, content, language, created_at, expires_at, password_hash, user_id 
+		SELECT ` + pasteColumns + `
 		FROM pastes 
 		WHERE id = ?`
 
-	err := r.db.QueryRow(query, id).Scan(
-		&paste.ID,
-		&paste.Content,
-		&paste.Language,
-		&paste.CreatedAt,
-		&paste.ExpiresAt,
-		&paste.PasswordHash,
-		&paste.UserID,
-	)
-
+	reader := r.reader()
+	paste, err := scanPaste(reader.QueryRow(query, id))
+	if err == sql.ErrNoRows && reader != r.db {
+		// The paste may be too new to have reached the replica
+		paste, err = scanPaste(r.db.QueryRow(query, id))
+	}
 	if err == sql.ErrNoRows {
 		return nil, nil
 	}
@@ -71,19 +371,28 @@ func (r *PasteRepository) GetByID(id string) (*Paste, error) {
 		return nil, err
 	}
 
+	// Pastes read again move back to the hot tier. The content has been
+	// read either way, so a failed move is retried on the next read.
+	if paste.Cold && (r.readOnly == nil || !r.readOnly.Enabled()) {
+		r.rehydrate(paste)
+	}
+
+	if r.cache != nil {
+		r.cache.Add(paste)
+	}
 	return paste, nil
 }
 
 // GetByUserID retrieves all pastes by a user ID
 func (r *PasteRepository) GetByUserID(userID int, limit, offset int) ([]*Paste, error) {
 	query := `
-		SELECT id, content, language, created_at, expires_at, password_hash, user_id 
+		SELECT ` + pasteColumns + `
 		FROM pastes 
 		WHERE user_id = ?
 		ORDER BY created_at DESC
 		LIMIT ? OFFSET ?`
 
-	rows, err := r.db.Query(query, userID, limit, offset)
+	rows, err := r.reader().Query(query, userID, limit, offset)
 	if err != nil {
 		return nil, err
 	}
@@ -91,16 +400,7 @@ func (r *PasteRepository) GetByUserID(userID int, limit, offset int) ([]*Paste,
 
 	var pastes []*Paste
 	for rows.Next() {
-		paste := &Paste{}
-		err := rows.Scan(
-			&paste.ID,
-			&paste.Content,
-			&paste.Language,
-			&paste.CreatedAt,
-			&paste.ExpiresAt,
-			&paste.PasswordHash,
-			&paste.UserID,
-		)
+		paste, err := scanPaste(rows)
 		if err != nil {
 			return nil, err
 		}
@@ -110,19 +410,115 @@ func (r *PasteRepository) GetByUserID(userID int, limit, offset int) ([]*Paste,
 	return pastes, rows.Err()
 }
 
+// ListByUserIDAfter returns up to limit of a user's pastes with IDs greater
+// than afterID, in ID order. Paging by key keeps results stable while the
+// user creates or deletes pastes, unlike offset pagination.
+func (r *PasteRepository) ListByUserIDAfter(userID int, afterID string, limit int) ([]*Paste, error) {
+	query := `
+		SELECT ` + pasteColumns + `
+		FROM pastes
+		WHERE user_id = ? AND id > ?
+		ORDER BY id
+		LIMIT ?`
+
+	rows, err := r.reader().Query(query, userID, afterID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var pastes []*Paste
+	for rows.Next() {
+		paste, err := scanPaste(rows)
+		if err != nil {
+			return nil, err
+		}
+		pastes = append(pastes, paste)
+	}
+
+	return pastes, rows.Err()
+}
+
+// GetByContentHash retrieves a paste whose content has the given SHA-256
+// digest and may be served by its hash: it is not private, password protected,
+// restricted to signed URLs, an access window or certain networks, or
+// expired. It returns nil if there is none.
+func (r *PasteRepository) GetByContentHash(hash string) (*Paste, error) {
+	query := `
+		SELECT ` + pasteColumns + `
+		FROM pastes
+		WHERE content_sha256 = ? AND visibility != 'private'
+			AND (password_hash IS NULL OR password_hash = '') AND require_signed_urls = 0
+			AND access_window_start IS NULL AND allowed_networks IS NULL AND allowed_countries IS NULL
+			AND (expires_at IS NULL OR expires_at > datetime('now'))
+		LIMIT 1`
+
+	paste, err := scanPaste(r.reader().QueryRow(query, hash))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return paste, nil
+}
+
+// BackfillContentHashes computes the content digest and SimHash
+// fingerprint of up to limit pastes created before either existed,
+// returning how many were updated
+func (r *PasteRepository) BackfillContentHashes(limit int) (int64, error) {
+	rows, err := r.db.Query(`SELECT id, content FROM pastes WHERE content_sha256 IS NULL OR content_simhash IS NULL LIMIT ?`, limit)
+	if err != nil {
+		return 0, err
+	}
+	contents := make(map[string]string)
+	for rows.Next() {
+		var id, content string
+		if err := rows.Scan(&id, &content); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		contents[id] = content
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	var updated int64
+	for id, content := range contents {
+		if _, err := r.db.Exec(`UPDATE pastes SET content_sha256 = ?, content_simhash = ? WHERE id = ?`, ContentSHA256(content), contentSimhash(content), id); err != nil {
+			return updated, err
+		}
+		updated++
+	}
+	return updated, nil
+}
+
 // Update updates a paste's content (only if not expired)
 func (r *PasteRepository) Update(paste *Paste) error {
 	query := `
 		UPDATE pastes 
-		SET content = ?, language = ?, expires_at = ?, password_hash = ?
+		SET content = ?, cold_size = NULL, content_sha256 = ?, content_simhash = ?, language = ?, expires_at = ?, password_hash = ?,
+			theme = ?, line_numbers = ?, word_wrap = ?
 		WHERE id = ? AND (expires_at IS NULL OR expires_at > datetime('now'))`
 
+	defer r.uncache(paste.ID)
+	if err := r.thawCold("id = ?", paste.ID); err != nil {
+		return err
+	}
+	paste.ContentSHA256 = ContentSHA256(paste.Content)
 	result, err := r.db.Exec(
 		query,
 		paste.Content,
+		paste.ContentSHA256,
+		contentSimhash(paste.Content),
 		paste.Language,
 		paste.ExpiresAt,
 		paste.PasswordHash,
+		paste.Theme,
+		paste.LineNumbers,
+		paste.WordWrap,
 		paste.ID,
 	)
 	if err != nil {
@@ -138,19 +534,66 @@ func (r *PasteRepository) Update(paste *Paste) error {
 		return sql.ErrNoRows // Paste not found or expired
 	}
 
-	return nil
+	// The new content is hot; drop any cold copy of the old content
+	_, err = r.db.Exec(`DELETE FROM paste_cold WHERE paste_id = ?`, paste.ID)
+	return err
 }
 
 // Delete deletes a paste by its ID
 func (r *PasteRepository) Delete(id string) error {
+	defer r.uncache(id)
+	if err := r.thawCold("id = ?", id); err != nil {
+		return err
+	}
 	query := `DELETE FROM pastes WHERE id = ?`
 	_, err := r.db.Exec(query, id)
 	return err
 }
 
+// RecordFailedUnlock counts a wrong password for a paste, destroying it once
+// it reaches its maximum number of failed unlock attempts. It reports
+// whether the paste was destroyed.
+func (r *PasteRepository) RecordFailedUnlock(id string) (bool, error) {
+	defer r.uncache(id)
+	if err := r.thawCold("id = ?", id); err != nil {
+		return false, err
+	}
+	tx, err := r.db.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	var failed, max int
+	err = tx.QueryRow(`
+		UPDATE pastes SET failed_unlocks = failed_unlocks + 1
+		WHERE id = ? AND max_unlock_attempts IS NOT NULL
+		RETURNING failed_unlocks, max_unlock_attempts`, id).Scan(&failed, &max)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	destroyed := failed >= max
+	if destroyed {
+		if _, err := tx.Exec(`DELETE FROM pastes WHERE id = ?`, id); err != nil {
+			return false, err
+		}
+	}
+	return destroyed, tx.Commit()
+}
+
+// expiredCondition matches pastes past their expiry
+const expiredCondition = `expires_at IS NOT NULL AND expires_at <= datetime('now')`
+
 // DeleteExpired deletes all expired pastes
 func (r *PasteRepository) DeleteExpired() (int64, error) {
-	query := `DELETE FROM pastes WHERE expires_at IS NOT NULL AND expires_at <= datetime('now')`
+	if err := r.thawCold(expiredCondition); err != nil {
+		return 0, err
+	}
+	query := `DELETE FROM pastes WHERE ` + expiredCondition
 	result, err := r.db.Exec(query)
 	if err != nil {
 		return 0, err
@@ -159,6 +602,84 @@ func (r *PasteRepository) DeleteExpired() (int64, error) {
 	return result.RowsAffected()
 }
 
+// uncache removes a changed paste from the cache
+func (r *PasteRepository) uncache(id string) {
+	if r.cache != nil {
+		r.cache.Remove(id)
+	}
+}
+
+// surrogateKeys returns the surrogate keys of a paste, its content hash and,
+// for public pastes, the archive month it was created in
+func surrogateKeys(id, contentSHA256 string, public bool, createdAt time.Time) []string {
+	keys := []string{PasteSurrogateKey(id)}
+	if contentSHA256 != "" {
+		keys = append(keys, BlobSurrogateKey(contentSHA256))
+	}
+	if public {
+		keys = append(keys, ArchiveSurrogateKey(createdAt))
+	}
+	return keys
+}
+
+// DeletedPaste identifies a paste removed by DeleteExpiredPastes
+type DeletedPaste struct {
+	ID            string
+	ContentSHA256 string
+	Public        bool
+	CreatedAt     time.Time
+}
+
+// SurrogateKeys returns the CDN surrogate keys of the deleted paste's
+// cached responses
+func (d DeletedPaste) SurrogateKeys() []string {
+	return surrogateKeys(d.ID, d.ContentSHA256, d.Public, d.CreatedAt)
+}
+
+// DeleteExpiredPastes deletes all expired pastes like DeleteExpired, and
+// returns the pastes it removed
+func (r *PasteRepository) DeleteExpiredPastes() ([]DeletedPaste, error) {
+	if err := r.thawCold(expiredCondition); err != nil {
+		return nil, err
+	}
+	query := `
+		DELETE FROM pastes WHERE ` + expiredCondition + `
+		RETURNING id, COALESCE(content_sha256, ''), visibility = 'public', strftime('%Y-%m-%dT%H:%M:%SZ', created_at)`
+	rows, err := r.db.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var deleted []DeletedPaste
+	for rows.Next() {
+		var d DeletedPaste
+		var createdAt sql.NullString
+		if err := rows.Scan(&d.ID, &d.ContentSHA256, &d.Public, &createdAt); err != nil {
+			return nil, err
+		}
+		d.CreatedAt, _ = time.Parse(time.RFC3339, createdAt.String)
+		r.uncache(d.ID)
+		deleted = append(deleted, d)
+	}
+	return deleted, rows.Err()
+}
+
+// ExpiredBacklogAge returns how long the oldest expired paste has been waiting
+// for cleanup, or zero if none are waiting
+func (r *PasteRepository) ExpiredBacklogAge() (time.Duration, error) {
+	query := `
+		SELECT COALESCE(MAX((julianday('now') - julianday(expires_at)) * 86400), 0)
+		FROM pastes
+		WHERE expires_at IS NOT NULL AND expires_at <= datetime('now')`
+
+	var seconds float64
+	if err := r.db.QueryRow(query).Scan(&seconds); err != nil {
+		return 0, err
+	}
+	return time.Duration(seconds * float64(time.Second)), nil
+}
+
 // Exists checks if a paste ID already exists
 func (r *PasteRepository) Exists(id string) (bool, error) {
 	var count int
@@ -171,10 +692,300 @@ func (r *PasteRepository) Exists(id string) (bool, error) {
 func (r *PasteRepository) CountByUserID(userID int) (int, error) {
 	var count int
 	query := `SELECT COUNT(*) FROM pastes WHERE user_id = ?`
-	err := r.db.QueryRow(query, userID).Scan(&count)
+	err := r.reader().QueryRow(query, userID).Scan(&count)
 	return count, err
 }
 
+// LanguageCount is the number of publicly listed pastes in a language
+type LanguageCount struct {
+	Language string `json:"language"`
+	Count    int    `json:"count"`
+}
+
+// CountPublicByLanguage returns how many publicly listed pastes each
+// language has, most used first. Languages are compared ignoring case and
+// reported in lowercase.
+func (r *PasteRepository) CountPublicByLanguage() ([]LanguageCount, error) {
+	query := `
+		SELECT LOWER(language) AS lang, COUNT(*) AS total
+		FROM pastes
+		WHERE language IS NOT NULL AND language != '' AND ` + publiclyListed + `
+		GROUP BY lang
+		ORDER BY total DESC, lang`
+
+	rows, err := r.reader().Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	counts := []LanguageCount{}
+	for rows.Next() {
+		var c LanguageCount
+		if err := rows.Scan(&c.Language, &c.Count); err != nil {
+			return nil, err
+		}
+		counts = append(counts, c)
+	}
+	return counts, rows.Err()
+}
+
+// ListPublicByLanguage returns a page of the publicly listed pastes in a
+// language, ignoring case, newest first, together with their total number
+func (r *PasteRepository) ListPublicByLanguage(language string, limit, offset int) ([]*ListedPaste, int, error) {
+	where := `WHERE language = ? COLLATE NOCASE AND ` + publiclyListed
+
+	var total int
+	if err := r.reader().QueryRow(`SELECT COUNT(*) FROM pastes `+where, language).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	query := `
+		SELECT ` + pasteColumns + `, COALESCE((SELECT username FROM users WHERE users.id = pastes.user_id), '')
+		FROM pastes
+		` + where + `
+		ORDER BY created_at DESC, rowid DESC
+		LIMIT ? OFFSET ?`
+
+	rows, err := r.reader().Query(query, language, limit, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	pastes := []*ListedPaste{}
+	for rows.Next() {
+		entry := &ListedPaste{}
+		paste, err := scanPaste(withExtraColumns(rows, &entry.Username))
+		if err != nil {
+			return nil, 0, err
+		}
+		entry.Paste = paste
+		pastes = append(pastes, entry)
+	}
+	return pastes, total, rows.Err()
+}
+
+// ListPublicCreatedBetween returns up to limit publicly listed pastes created
+// in [start, end) with IDs greater than afterID, in ID order. Paging by key
+// keeps pages stable for clients walking the archive.
+func (r *PasteRepository) ListPublicCreatedBetween(start, end time.Time, afterID string, limit int) ([]*ListedPaste, error) {
+	query := `
+		SELECT ` + pasteColumns + `, COALESCE((SELECT username FROM users WHERE users.id = pastes.user_id), '')
+		FROM pastes
+		WHERE created_at >= ? AND created_at < ? AND id > ? AND ` + publiclyListed + `
+		ORDER BY id
+		LIMIT ?`
+
+	rows, err := r.reader().Query(query,
+		start.UTC().Format("2006-01-02 15:04:05"), end.UTC().Format("2006-01-02 15:04:05"), afterID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	pastes := []*ListedPaste{}
+	for rows.Next() {
+		entry := &ListedPaste{}
+		paste, err := scanPaste(withExtraColumns(rows, &entry.Username))
+		if err != nil {
+			return nil, err
+		}
+		entry.Paste = paste
+		pastes = append(pastes, entry)
+	}
+	return pastes, rows.Err()
+}
+
+// PasteSearchFilter narrows a search over pastes. Zero values are ignored.
+type PasteSearchFilter struct {
+	Query           string // Full-text query over content and language
+	UserID          int
+	Username        string
+	CreatorIPHashes []string // Any of the hashes an IP may be stored under
+	Language        string
+	Since           *time.Time
+	Until           *time.Time
+	Unexpired       bool // Leave out pastes that have expired but not yet been deleted
+	Limit           int
+	Offset          int
+}
+
+// Search finds pastes matching the filter, newest first, and returns the
+// page of results together with the total number of matches
+func (r *PasteRepository) Search(filter PasteSearchFilter) ([]*Paste, int, error) {
+	var conditions []string
+	var args []interface{}
+
+	if filter.Query != "" {
+		conditions = append(conditions, "rowid IN (SELECT docid FROM pastes_fts WHERE pastes_fts MATCH ?)")
+		args = append(args, ftsQuery(filter.Query))
+	}
+	if filter.UserID != 0 {
+		conditions = append(conditions, "user_id = ?")
+		args = append(args, filter.UserID)
+	}
+	if filter.Username != "" {
+		conditions = append(conditions, "user_id = (SELECT id FROM users WHERE username = ? COLLATE NOCASE)")
+		args = append(args, filter.Username)
+	}
+	if len(filter.CreatorIPHashes) > 0 {
+		placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(filter.CreatorIPHashes)), ", ")
+		conditions = append(conditions, "creator_ip_hash IN ("+placeholders+")")
+		for _, hash := range filter.CreatorIPHashes {
+			args = append(args, hash)
+		}
+	}
+	if filter.Language != "" {
+		conditions = append(conditions, "language = ?")
+		args = append(args, filter.Language)
+	}
+	if filter.Since != nil {
+		conditions = append(conditions, "created_at >= ?")
+		args = append(args, filter.Since.UTC().Format("2006-01-02 15:04:05"))
+	}
+	if filter.Until != nil {
+		conditions = append(conditions, "created_at < ?")
+		args = append(args, filter.Until.UTC().Format("2006-01-02 15:04:05"))
+	}
+	if filter.Unexpired {
+		conditions = append(conditions, "(expires_at IS NULL OR expires_at > datetime('now'))")
+	}
+
+	where := ""
+	if len(conditions) > 0 {
+		where = "WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	var total int
+	if err := r.db.QueryRow(`SELECT COUNT(*) FROM pastes `+where, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	query := `
+		SELECT ` + pasteColumns + `
+		FROM pastes
+		` + where + `
+		ORDER BY created_at DESC
+		LIMIT ? OFFSET ?`
+
+	rows, err := r.db.Query(query, append(args, filter.Limit, filter.Offset)...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	var pastes []*Paste
+	for rows.Next() {
+		paste, err := scanPaste(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		pastes = append(pastes, paste)
+	}
+
+	return pastes, total, rows.Err()
+}
+
+// SimilarPaste is a paste found by FindSimilar together with the number of
+// bits its content fingerprint differs by; 0 for identical content
+type SimilarPaste struct {
+	*Paste
+	Distance int
+}
+
+// FingerprintByContentSHA256 returns the content fingerprint of any paste
+// with the given content digest, reporting false if there is none
+func (r *PasteRepository) FingerprintByContentSHA256(hash string) (uint64, bool, error) {
+	var fingerprint int64
+	err := r.reader().QueryRow(`SELECT content_simhash FROM pastes WHERE content_sha256 = ? AND content_simhash IS NOT NULL LIMIT 1`, hash).Scan(&fingerprint)
+	if err == sql.ErrNoRows {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, err
+	}
+	return uint64(fingerprint), true, nil
+}
+
+// FindSimilar returns up to limit pastes, including expired and private
+// ones, whose content fingerprint is within maxDistance bits of the given
+// one, closest first and then newest first. Candidates are looked up by
+// band, so distances beyond simhash.MaxBandedDistance are not supported.
+func (r *PasteRepository) FindSimilar(fingerprint uint64, maxDistance, limit int) ([]*SimilarPaste, error) {
+	if maxDistance > simhash.MaxBandedDistance {
+		return nil, fmt.Errorf("distance %d exceeds the supported %d", maxDistance, simhash.MaxBandedDistance)
+	}
+
+	rows, err := r.reader().Query(`
+		SELECT id, content_simhash, created_at FROM pastes
+		WHERE content_simhash & 65535 = ? OR (content_simhash >> 16) & 65535 = ?
+			OR (content_simhash >> 32) & 65535 = ? OR (content_simhash >> 48) & 65535 = ?`,
+		simhash.Band(fingerprint, 0), simhash.Band(fingerprint, 1), simhash.Band(fingerprint, 2), simhash.Band(fingerprint, 3))
+	if err != nil {
+		return nil, err
+	}
+	type candidate struct {
+		id        string
+		distance  int
+		createdAt time.Time
+	}
+	var candidates []candidate
+	for rows.Next() {
+		var c candidate
+		var stored int64
+		if err := rows.Scan(&c.id, &stored, &c.createdAt); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		if c.distance = simhash.Distance(fingerprint, uint64(stored)); c.distance <= maxDistance {
+			candidates = append(candidates, c)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	sort.Slice(candidates, func(i, j int) bool {
+		if candidates[i].distance != candidates[j].distance {
+			return candidates[i].distance < candidates[j].distance
+		}
+		return candidates[i].createdAt.After(candidates[j].createdAt)
+	})
+	if len(candidates) > limit {
+		candidates = candidates[:limit]
+	}
+
+	similar := make([]*SimilarPaste, 0, len(candidates))
+	for _, c := range candidates {
+		paste, err := r.GetByID(c.id)
+		if err != nil {
+			return nil, err
+		}
+		if paste != nil {
+			similar = append(similar, &SimilarPaste{Paste: paste, Distance: c.distance})
+		}
+	}
+	return similar, nil
+}
+
+// ftsQuery turns free text into an FTS query matching every term literally,
+// so user input cannot inject FTS operators or cause syntax errors
+func ftsQuery(text string) string {
+	terms := strings.Fields(text)
+	for i, term := range terms {
+		terms[i] = `"` + strings.ReplaceAll(term, `"`, `""`) + `"`
+	}
+	return strings.Join(terms, " ")
+}
+
+// SurrogateKeys returns the CDN surrogate keys of the paste's cached
+// responses
+func (p *Paste) SurrogateKeys() []string {
+	return surrogateKeys(p.ID, p.ContentSHA256, p.Visibility == VisibilityPublic, p.CreatedAt)
+}
+
 // IsExpired checks if a paste has expired
 func (p *Paste) IsExpired() bool {
 	if p.ExpiresAt == nil {
@@ -183,7 +994,59 @@ func (p *Paste) IsExpired() bool {
 	return time.Now().After(*p.ExpiresAt)
 }
 
+// IsDiff checks if a paste holds a unified diff
+func (p *Paste) IsDiff() bool {
+	return p.Kind == KindDiff || p.Language == "diff" || p.Language == "patch"
+}
+
+// IsOwnedBy checks if the paste belongs to the given user
+func (p *Paste) IsOwnedBy(userID int) bool {
+	return p.UserID != nil && *p.UserID == userID
+}
+
+// IsLocationRestricted checks if only certain networks or countries may view
+// the paste
+func (p *Paste) IsLocationRestricted() bool {
+	return len(p.AllowedNetworks) > 0 || len(p.AllowedCountries) > 0
+}
+
+// IsRestricted checks if whether the paste may be viewed, or how it looks,
+// depends on when, where from or in which page it is viewed, so a copy must
+// not be cached or served elsewhere
+func (p *Paste) IsRestricted() bool {
+	return p.AccessWindow != nil || p.IsLocationRestricted() || len(p.EmbedDomains) > 0 || p.Watermark
+}
+
+// IsPrivate checks if only the owner may view the paste
+func (p *Paste) IsPrivate() bool {
+	return p.Visibility == VisibilityPrivate
+}
+
 // HasPassword checks if a paste is password protected
 func (p *Paste) HasPassword() bool {
 	return p.PasswordHash != nil && *p.PasswordHash != ""
 }
+
+// AccessWindow is a daily range of UTC times, in minutes after midnight,
+// during which a paste may be viewed. A window whose end is before its start
+// spans midnight.
+type AccessWindow struct {
+	Start int `json:"start"`
+	End   int `json:"end"`
+}
+
+// Contains reports whether t falls within the window, including its start
+// but not its end
+func (w AccessWindow) Contains(t time.Time) bool {
+	t = t.UTC()
+	minute := t.Hour()*60 + t.Minute()
+	if w.Start <= w.End {
+		return minute >= w.Start && minute < w.End
+	}
+	return minute >= w.Start || minute < w.End
+}
+
+// String formats the window as "HH:MM-HH:MM"
+func (w AccessWindow) String() string {
+	return fmt.Sprintf("%02d:%02d-%02d:%02d", w.Start/60, w.Start%60, w.End/60, w.End%60)
+}
@@ -0,0 +1,122 @@
+package models
+
+import (
+	"database/sql"
+	"time"
+)
+
+// Paste lifecycle events recorded by triggers on the pastes table
+const (
+	EventCreate = "create"
+	EventExpire = "expire"
+	EventDelete = "delete"
+	EventBurn   = "burn" // Reserved for pastes destroyed after being read
+)
+
+// DailyStats aggregates the lifecycle events of a single UTC day
+type DailyStats struct {
+	Day          string `json:"day"`
+	Created      int64  `json:"created"`
+	Expired      int64  `json:"expired"`
+	Deleted      int64  `json:"deleted"`
+	Burned       int64  `json:"burned"`
+	BytesCreated int64  `json:"bytes_created"`
+}
+
+// PasteEvent is a recorded lifecycle event of a paste
+type PasteEvent struct {
+	ID        int64     `json:"id"`
+	PasteID   string    `json:"paste_id"`
+	Event     string    `json:"event"`
+	Language  string    `json:"language,omitempty"`
+	Bytes     int64     `json:"bytes"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// PasteEventRepository reads the paste event log and maintains its daily rollup
+type PasteEventRepository struct {
+	db *sql.DB
+}
+
+// NewPasteEventRepository creates a new paste event repository
+func NewPasteEventRepository(db *sql.DB) *PasteEventRepository {
+	return &PasteEventRepository{db: db}
+}
+
+// Rollup recomputes the daily aggregates from the last rolled-up day onwards,
+// so a partially rolled day is completed on the next run. It returns the
+// number of aggregate rows written.
+func (r *PasteEventRepository) Rollup() (int64, error) {
+	query := `
+		INSERT OR REPLACE INTO paste_stats_daily (day, event, count, bytes)
+		SELECT date(created_at), event, COUNT(*), SUM(bytes)
+		FROM paste_events
+		WHERE created_at >= (SELECT COALESCE(MAX(day), '') FROM paste_stats_daily)
+		GROUP BY date(created_at), event`
+
+	result, err := r.db.Exec(query)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// GetDailyStats returns the rolled-up stats for every day since the given
+// time, oldest first. Days without any events are omitted.
+func (r *PasteEventRepository) GetDailyStats(since time.Time) ([]*DailyStats, error) {
+	query := `
+		SELECT day,
+			SUM(CASE WHEN event = 'create' THEN count ELSE 0 END),
+			SUM(CASE WHEN event = 'expire' THEN count ELSE 0 END),
+			SUM(CASE WHEN event = 'delete' THEN count ELSE 0 END),
+			SUM(CASE WHEN event = 'burn' THEN count ELSE 0 END),
+			SUM(CASE WHEN event = 'create' THEN bytes ELSE 0 END)
+		FROM paste_stats_daily
+		WHERE day >= ?
+		GROUP BY day
+		ORDER BY day`
+
+	rows, err := r.db.Query(query, since.UTC().Format("2006-01-02"))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	days := []*DailyStats{}
+	for rows.Next() {
+		d := &DailyStats{}
+		if err := rows.Scan(&d.Day, &d.Created, &d.Expired, &d.Deleted, &d.Burned, &d.BytesCreated); err != nil {
+			return nil, err
+		}
+		days = append(days, d)
+	}
+
+	return days, rows.Err()
+}
+
+// ListByUserID returns the recorded events of a user's current pastes,
+// oldest first. Events of pastes with do_not_track are not linked to them
+// and so are left out.
+func (r *PasteEventRepository) ListByUserID(userID int) ([]*PasteEvent, error) {
+	query := `
+		SELECT e.id, e.paste_id, e.event, e.language, e.bytes, e.created_at
+		FROM paste_events e JOIN pastes p ON p.id = e.paste_id
+		WHERE p.user_id = ?
+		ORDER BY e.id`
+
+	rows, err := r.db.Query(query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	events := []*PasteEvent{}
+	for rows.Next() {
+		event := &PasteEvent{}
+		if err := rows.Scan(&event.ID, &event.PasteID, &event.Event, &event.Language, &event.Bytes, &event.CreatedAt); err != nil {
+			return nil, err
+		}
+		events = append(events, event)
+	}
+	return events, rows.Err()
+}
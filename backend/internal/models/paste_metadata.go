@@ -0,0 +1,51 @@
+package models
+
+import (
+	"database/sql"
+)
+
+// Keys of generated paste metadata
+const (
+	MetadataTitle   = "title"
+	MetadataSummary = "summary"
+)
+
+// PasteMetadataRepository stores key/value metadata generated about pastes
+// by post-create hooks
+type PasteMetadataRepository struct {
+	db *sql.DB
+}
+
+// NewPasteMetadataRepository creates a new paste metadata repository
+func NewPasteMetadataRepository(db *sql.DB) *PasteMetadataRepository {
+	return &PasteMetadataRepository{db: db}
+}
+
+// Set stores a metadata value of a paste, replacing any previous value of
+// the key
+func (r *PasteMetadataRepository) Set(pasteID, key, value string) error {
+	_, err := r.db.Exec(`
+		INSERT INTO paste_metadata (paste_id, key, value) VALUES (?, ?, ?)
+		ON CONFLICT(paste_id, key) DO UPDATE SET value = excluded.value, updated_at = CURRENT_TIMESTAMP`,
+		pasteID, key, value)
+	return err
+}
+
+// Get returns all metadata of a paste, which is empty if it has none
+func (r *PasteMetadataRepository) Get(pasteID string) (map[string]string, error) {
+	rows, err := r.db.Query(`SELECT key, value FROM paste_metadata WHERE paste_id = ?`, pasteID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	metadata := map[string]string{}
+	for rows.Next() {
+		var key, value string
+		if err := rows.Scan(&key, &value); err != nil {
+			return nil, err
+		}
+		metadata[key] = value
+	}
+	return metadata, rows.Err()
+}
@@ -0,0 +1,78 @@
+package models
+
+import (
+	"database/sql"
+	"time"
+)
+
+// RetentionReport counts what one anonymization pass scrubbed
+type RetentionReport struct {
+	IPHashes          int64 // Pastes whose creator IP hash was cleared
+	ViewLogEntries    int64 // Logged individual paste views deleted
+	WebhookDeliveries int64 // Delivery logs whose bodies and responses were cleared
+	AuditDetails      int64 // Audit events whose free-text details were cleared
+}
+
+// Total returns how many records were scrubbed
+func (r *RetentionReport) Total() int64 {
+	return r.IPHashes + r.ViewLogEntries + r.WebhookDeliveries + r.AuditDetails
+}
+
+// RetentionRepository scrubs personal data that has outlived the retention
+// period. The data-minimization policy it enforces is:
+//
+//   - Creator IP hashes are cleared from pastes; they only serve abuse
+//     handling of recent pastes.
+//   - The per-view access log is deleted; daily view counts are kept.
+//   - Webhook delivery logs keep their event, status and latency but lose
+//     the request body, response excerpt and error, which may quote pastes.
+//   - Audit events keep who did what to whom and when, but lose their
+//     free-text details, such as the reason given for a legal export.
+//
+// Aggregates (daily view counts, paste stats and event counts) are never
+// touched, so statistics stay correct after data is scrubbed.
+type RetentionRepository struct {
+	db *sql.DB
+}
+
+// NewRetentionRepository creates a new retention repository
+func NewRetentionRepository(db *sql.DB) *RetentionRepository {
+	return &RetentionRepository{db: db}
+}
+
+// Anonymize scrubs personal data recorded before the given time, all or
+// nothing, reporting how much was scrubbed
+func (r *RetentionRepository) Anonymize(before time.Time) (*RetentionReport, error) {
+	cutoff := before.UTC().Format("2006-01-02 15:04:05")
+
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	report := &RetentionReport{}
+	for _, step := range []struct {
+		query string
+		count *int64
+	}{
+		{`UPDATE pastes SET creator_ip_hash = NULL WHERE creator_ip_hash IS NOT NULL AND created_at < ?`, &report.IPHashes},
+		{`DELETE FROM paste_view_log WHERE viewed_at < ?`, &report.ViewLogEntries},
+		{`UPDATE webhook_deliveries SET body = '', error = ''
+			WHERE (body != '' OR error != '') AND created_at < ?`, &report.WebhookDeliveries},
+		{`UPDATE audit_log SET details = '' WHERE details != '' AND created_at < ?`, &report.AuditDetails},
+	} {
+		result, err := tx.Exec(step.query, cutoff)
+		if err != nil {
+			return nil, err
+		}
+		if *step.count, err = result.RowsAffected(); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return report, nil
+}
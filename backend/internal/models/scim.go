@@ -0,0 +1,253 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+)
+
+// ErrSCIMConflict is returned when a provisioned user's username or external
+// ID belongs to another user
+var ErrSCIMConflict = errors.New("username or external ID belongs to another user")
+
+// SCIMUser is a user managed by an identity provider over SCIM
+type SCIMUser struct {
+	ID         int
+	UserName   string // As known to the provider, often an email address
+	Username   string // The local username single sign-on matches
+	ExternalID string
+	Active     bool
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+}
+
+// SCIMFilter narrows a listing of provisioned users. Empty fields match
+// every user; user names are compared case-insensitively.
+type SCIMFilter struct {
+	UserName   string
+	ExternalID string
+}
+
+// SCIMRepository handles database operations for users provisioned over
+// SCIM. Only users it created are visible to it, so a provider cannot take
+// over local accounts.
+type SCIMRepository struct {
+	db             *sql.DB
+	renameCooldown time.Duration // How long a renamed user's old username is held
+}
+
+// NewSCIMRepository creates a new SCIM repository
+func NewSCIMRepository(db *sql.DB) *SCIMRepository {
+	return &SCIMRepository{db: db}
+}
+
+// SetRenameCooldown sets how long a renamed user's old username stays held
+// for them, as for renames by the user; zero releases it at once
+func (r *SCIMRepository) SetRenameCooldown(cooldown time.Duration) {
+	r.renameCooldown = cooldown
+}
+
+const selectSCIMUserSQL = `
+	SELECT u.id, s.user_name, u.username, COALESCE(s.external_id, ''), u.deactivated_at, u.created_at, s.updated_at
+	FROM scim_users s
+	JOIN users u ON u.id = s.user_id`
+
+func scanSCIMUser(row interface{ Scan(...interface{}) error }) (*SCIMUser, error) {
+	user := &SCIMUser{}
+	var deactivatedAt *time.Time
+	err := row.Scan(&user.ID, &user.UserName, &user.Username, &user.ExternalID, &deactivatedAt, &user.CreatedAt, &user.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	user.Active = deactivatedAt == nil
+	return user, nil
+}
+
+// List returns a page of provisioned users matching filter, oldest first,
+// along with the total number of matches
+func (r *SCIMRepository) List(filter SCIMFilter, offset, limit int) ([]*SCIMUser, int, error) {
+	where := ` WHERE 1 = 1`
+	var args []interface{}
+	if filter.UserName != "" {
+		where += ` AND s.user_name = ? COLLATE NOCASE`
+		args = append(args, filter.UserName)
+	}
+	if filter.ExternalID != "" {
+		where += ` AND s.external_id = ?`
+		args = append(args, filter.ExternalID)
+	}
+
+	var total int
+	if err := r.db.QueryRow(`SELECT COUNT(*) FROM scim_users s`+where, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	rows, err := r.db.Query(selectSCIMUserSQL+where+` ORDER BY u.id LIMIT ? OFFSET ?`, append(args, limit, offset)...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	users := []*SCIMUser{}
+	for rows.Next() {
+		user, err := scanSCIMUser(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		users = append(users, user)
+	}
+	return users, total, rows.Err()
+}
+
+// Get retrieves a provisioned user, returning nil if there is none with
+// the ID
+func (r *SCIMRepository) Get(id int) (*SCIMUser, error) {
+	user, err := scanSCIMUser(r.db.QueryRow(selectSCIMUserSQL+` WHERE u.id = ?`, id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return user, err
+}
+
+// Create provisions a new user, filling in its ID and timestamps.
+// Provisioned users have no password and sign in through single sign-on.
+func (r *SCIMRepository) Create(user *SCIMUser) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := checkSCIMConflict(tx, user); err != nil {
+		return err
+	}
+
+	var deactivatedAt *time.Time
+	if !user.Active {
+		now := time.Now().UTC()
+		deactivatedAt = &now
+	}
+	err = tx.QueryRow(`INSERT INTO users (username, password_hash, role, deactivated_at) VALUES (?, ?, ?, ?) RETURNING id, created_at`,
+		user.Username, disabledPasswordHash, RoleUser, deactivatedAt).Scan(&user.ID, &user.CreatedAt)
+	if err != nil {
+		return err
+	}
+	err = tx.QueryRow(`INSERT INTO scim_users (user_id, user_name, external_id) VALUES (?, ?, ?) RETURNING updated_at`,
+		user.ID, user.UserName, nullIfEmpty(user.ExternalID)).Scan(&user.UpdatedAt)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// Update renames, re-links or (de)activates a provisioned user, reporting
+// whether it exists. Deactivated users keep their pastes but cannot sign in.
+func (r *SCIMRepository) Update(user *SCIMUser) (bool, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	var exists bool
+	if err := tx.QueryRow(`SELECT EXISTS(SELECT 1 FROM scim_users WHERE user_id = ?)`, user.ID).Scan(&exists); err != nil || !exists {
+		return false, err
+	}
+	if err := checkSCIMConflict(tx, user); err != nil {
+		return false, err
+	}
+
+	now := time.Now().UTC()
+	if err := holdUsername(tx, user, now, r.renameCooldown); err != nil {
+		return false, err
+	}
+	query := `UPDATE users SET username = ?, deactivated_at = NULL WHERE id = ?`
+	args := []interface{}{user.Username, user.ID}
+	if !user.Active {
+		query = `UPDATE users SET username = ?, deactivated_at = COALESCE(deactivated_at, ?) WHERE id = ?`
+		args = []interface{}{user.Username, now, user.ID}
+	}
+	if _, err := tx.Exec(query, args...); err != nil {
+		return false, err
+	}
+	_, err = tx.Exec(`UPDATE scim_users SET user_name = ?, external_id = ?, updated_at = ? WHERE user_id = ?`,
+		user.UserName, nullIfEmpty(user.ExternalID), now, user.ID)
+	if err != nil {
+		return false, err
+	}
+	user.UpdatedAt = now
+	return true, tx.Commit()
+}
+
+// Delete deactivates a provisioned user and stops managing it, reporting
+// whether it existed. The account and its pastes are kept for an
+// administrator to deal with.
+func (r *SCIMRepository) Delete(id int) (bool, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(`DELETE FROM scim_users WHERE user_id = ?`, id)
+	if err != nil {
+		return false, err
+	}
+	if n, err := result.RowsAffected(); err != nil || n == 0 {
+		return false, err
+	}
+	_, err = tx.Exec(`UPDATE users SET deactivated_at = COALESCE(deactivated_at, ?) WHERE id = ?`, time.Now().UTC(), id)
+	if err != nil {
+		return false, err
+	}
+	return true, tx.Commit()
+}
+
+// checkSCIMConflict returns ErrSCIMConflict if a user other than the given
+// one holds its local username, user name or external ID, or has recently
+// renamed away from the local username
+func checkSCIMConflict(tx *sql.Tx, user *SCIMUser) error {
+	var taken bool
+	query := `
+		SELECT EXISTS(SELECT 1 FROM users WHERE username = ? COLLATE NOCASE AND id != ?)
+			OR EXISTS(SELECT 1 FROM scim_users WHERE user_name = ? COLLATE NOCASE AND user_id != ?)
+			OR EXISTS(SELECT 1 FROM scim_users WHERE external_id = ? AND user_id != ?)
+			OR EXISTS(SELECT 1 FROM username_holds WHERE username = ? AND user_id != ? AND held_until > ?)`
+	err := tx.QueryRow(query, user.Username, user.ID, user.UserName, user.ID, nullIfEmpty(user.ExternalID), user.ID,
+		user.Username, user.ID, time.Now().UTC()).Scan(&taken)
+	if err != nil {
+		return err
+	}
+	if taken {
+		return ErrSCIMConflict
+	}
+	return nil
+}
+
+// holdUsername holds a provisioned user's current username for them when it
+// is about to change, like UserRepository.Rename, and releases any hold on
+// the new one
+func holdUsername(tx *sql.Tx, user *SCIMUser, now time.Time, cooldown time.Duration) error {
+	var current string
+	if err := tx.QueryRow(`SELECT username FROM users WHERE id = ?`, user.ID).Scan(&current); err != nil {
+		return err
+	}
+	if cooldown > 0 && !strings.EqualFold(current, user.Username) {
+		_, err := tx.Exec(`INSERT OR REPLACE INTO username_holds (username, user_id, held_until) VALUES (?, ?, ?)`,
+			current, user.ID, now.Add(cooldown))
+		if err != nil {
+			return err
+		}
+	}
+	_, err := tx.Exec(`DELETE FROM username_holds WHERE username = ?`, user.Username)
+	return err
+}
+
+// nullIfEmpty stores empty strings as NULL
+func nullIfEmpty(s string) interface{} {
+	if s == "" {
+		return nil
+	}
+	return s
+}
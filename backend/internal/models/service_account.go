@@ -0,0 +1,160 @@
+package models
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"strings"
+	"time"
+)
+
+// Prefixes of service account credentials, so leaked ones are easy to spot
+const (
+	serviceClientIDPrefix = "svc_"
+	serviceSecretPrefix   = "pvs_"
+)
+
+// ServiceAccount is a non-interactive account used by automation such as CI.
+// Its pastes are owned by the backing user, so they are attributable to it.
+type ServiceAccount struct {
+	UserID      int        `json:"id" db:"user_id"`
+	Name        string     `json:"name" db:"username"`
+	Description string     `json:"description" db:"description"`
+	Scopes      []string   `json:"scopes" db:"scopes"`
+	ClientID    string     `json:"client_id" db:"client_id"`
+	CreatedBy   *int       `json:"created_by,omitempty" db:"created_by"`
+	CreatedAt   time.Time  `json:"created_at" db:"created_at"`
+	LastUsedAt  *time.Time `json:"last_used_at,omitempty" db:"last_used_at"`
+	DisabledAt  *time.Time `json:"disabled_at,omitempty" db:"disabled_at"`
+}
+
+// ServiceAccountRepository handles database operations for service accounts
+type ServiceAccountRepository struct {
+	db *sql.DB
+}
+
+// NewServiceAccountRepository creates a new service account repository
+func NewServiceAccountRepository(db *sql.DB) *ServiceAccountRepository {
+	return &ServiceAccountRepository{db: db}
+}
+
+// randomToken returns prefix followed by n random bytes in hex
+func randomToken(prefix string, n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return prefix + hex.EncodeToString(b), nil
+}
+
+// Create creates a service account and its backing user, returning the
+// client secret along with the record. Only a hash of the secret is stored.
+func (r *ServiceAccountRepository) Create(name, description string, scopes []string, createdBy int) (string, *ServiceAccount, error) {
+	clientID, err := randomToken(serviceClientIDPrefix, 8)
+	if err != nil {
+		return "", nil, err
+	}
+	secret, err := randomToken(serviceSecretPrefix, 24)
+	if err != nil {
+		return "", nil, err
+	}
+
+	tx, err := r.db.Begin()
+	if err != nil {
+		return "", nil, err
+	}
+	defer tx.Rollback()
+
+	account := &ServiceAccount{Name: name, Description: description, Scopes: scopes, ClientID: clientID, CreatedBy: &createdBy}
+	err = tx.QueryRow(`INSERT INTO users (username, password_hash, role) VALUES (?, ?, ?) RETURNING id`,
+		name, disabledPasswordHash, RoleService).Scan(&account.UserID)
+	if err != nil {
+		return "", nil, err
+	}
+
+	query := `
+		INSERT INTO service_accounts (user_id, description, scopes, client_id, secret_hash, created_by)
+		VALUES (?, ?, ?, ?, ?, ?)
+		RETURNING created_at`
+
+	err = tx.QueryRow(query, account.UserID, description, strings.Join(scopes, " "), clientID, hashAPIKey(secret), createdBy).Scan(&account.CreatedAt)
+	if err != nil {
+		return "", nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return "", nil, err
+	}
+	return secret, account, nil
+}
+
+const selectServiceAccountSQL = `
+	SELECT s.user_id, u.username, s.description, s.scopes, s.client_id, s.created_by, s.created_at, s.last_used_at, s.disabled_at
+	FROM service_accounts s
+	JOIN users u ON u.id = s.user_id`
+
+func scanServiceAccount(scanner interface{ Scan(...any) error }) (*ServiceAccount, error) {
+	account := &ServiceAccount{}
+	var scopes string
+	err := scanner.Scan(&account.UserID, &account.Name, &account.Description, &scopes, &account.ClientID,
+		&account.CreatedBy, &account.CreatedAt, &account.LastUsedAt, &account.DisabledAt)
+	if err != nil {
+		return nil, err
+	}
+	account.Scopes = strings.Fields(scopes)
+	return account, nil
+}
+
+// List returns every service account, oldest first
+func (r *ServiceAccountRepository) List() ([]*ServiceAccount, error) {
+	rows, err := r.db.Query(selectServiceAccountSQL + ` ORDER BY s.user_id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var accounts []*ServiceAccount
+	for rows.Next() {
+		account, err := scanServiceAccount(rows)
+		if err != nil {
+			return nil, err
+		}
+		accounts = append(accounts, account)
+	}
+	return accounts, rows.Err()
+}
+
+// Disable stops a service account from obtaining tokens, reporting whether it
+// existed. The account and its pastes are kept for auditing.
+func (r *ServiceAccountRepository) Disable(userID int) (bool, error) {
+	result, err := r.db.Exec(`UPDATE service_accounts SET disabled_at = COALESCE(disabled_at, ?) WHERE user_id = ?`, time.Now().UTC(), userID)
+	if err != nil {
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	return n > 0, err
+}
+
+// Authenticate looks up the enabled service account with the given client
+// credentials and records its use. It returns nil if they do not match one.
+func (r *ServiceAccountRepository) Authenticate(clientID, secret string) (*ServiceAccount, error) {
+	if !strings.HasPrefix(clientID, serviceClientIDPrefix) || !strings.HasPrefix(secret, serviceSecretPrefix) {
+		return nil, nil
+	}
+
+	result, err := r.db.Exec(`
+		UPDATE service_accounts SET last_used_at = ?
+		WHERE client_id = ? AND secret_hash = ? AND disabled_at IS NULL`,
+		time.Now().UTC(), clientID, hashAPIKey(secret))
+	if err != nil {
+		return nil, err
+	}
+	if n, err := result.RowsAffected(); err != nil || n == 0 {
+		return nil, err
+	}
+
+	account, err := scanServiceAccount(r.db.QueryRow(selectServiceAccountSQL+` WHERE s.client_id = ?`, clientID))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return account, err
+}
@@ -0,0 +1,105 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// ErrSSHKeyTaken is returned when a public key is already registered, by the
+// same or another account
+var ErrSSHKeyTaken = errors.New("SSH key is already registered")
+
+// SSHKey is a public key a user registers to submit pastes over SSH
+type SSHKey struct {
+	ID          int64      `json:"id" db:"id"`
+	UserID      int        `json:"-" db:"user_id"`
+	Name        string     `json:"name" db:"name"`
+	Fingerprint string     `json:"fingerprint" db:"fingerprint"` // SHA256 fingerprint as printed by ssh-keygen -l
+	PublicKey   string     `json:"public_key" db:"public_key"`   // authorized_keys format, without a comment
+	CreatedAt   time.Time  `json:"created_at" db:"created_at"`
+	LastUsedAt  *time.Time `json:"last_used_at,omitempty" db:"last_used_at"`
+}
+
+// SSHKeyRepository handles database operations for SSH keys
+type SSHKeyRepository struct {
+	db *sql.DB
+}
+
+// NewSSHKeyRepository creates a new SSH key repository
+func NewSSHKeyRepository(db *sql.DB) *SSHKeyRepository {
+	return &SSHKeyRepository{db: db}
+}
+
+// Create stores a new SSH key for a user
+func (r *SSHKeyRepository) Create(key *SSHKey) error {
+	var exists bool
+	if err := r.db.QueryRow(`SELECT EXISTS(SELECT 1 FROM ssh_keys WHERE fingerprint = ?)`, key.Fingerprint).Scan(&exists); err != nil {
+		return err
+	}
+	if exists {
+		return ErrSSHKeyTaken
+	}
+
+	query := `
+		INSERT INTO ssh_keys (user_id, name, fingerprint, public_key)
+		VALUES (?, ?, ?, ?)
+		RETURNING id, created_at`
+
+	return r.db.QueryRow(query, key.UserID, key.Name, key.Fingerprint, key.PublicKey).Scan(&key.ID, &key.CreatedAt)
+}
+
+// ListByUserID returns a user's SSH keys, newest first
+func (r *SSHKeyRepository) ListByUserID(userID int) ([]*SSHKey, error) {
+	query := `
+		SELECT id, user_id, name, fingerprint, public_key, created_at, last_used_at
+		FROM ssh_keys WHERE user_id = ?
+		ORDER BY created_at DESC, id DESC`
+
+	rows, err := r.db.Query(query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var keys []*SSHKey
+	for rows.Next() {
+		key := &SSHKey{}
+		if err := rows.Scan(&key.ID, &key.UserID, &key.Name, &key.Fingerprint, &key.PublicKey, &key.CreatedAt, &key.LastUsedAt); err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	return keys, rows.Err()
+}
+
+// Delete removes one of a user's SSH keys, reporting whether it existed
+func (r *SSHKeyRepository) Delete(userID int, id int64) (bool, error) {
+	result, err := r.db.Exec(`DELETE FROM ssh_keys WHERE id = ? AND user_id = ?`, id, userID)
+	if err != nil {
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	return n > 0, err
+}
+
+// Authenticate looks up the key with a fingerprint and records its use. It
+// returns nil if no active account holds the key.
+func (r *SSHKeyRepository) Authenticate(fingerprint string) (*SSHKey, error) {
+	key := &SSHKey{}
+	query := `
+		UPDATE ssh_keys SET last_used_at = ?
+		WHERE fingerprint = ? AND user_id NOT IN (SELECT id FROM users WHERE deactivated_at IS NOT NULL)
+		RETURNING id, user_id, name, fingerprint, public_key, created_at, last_used_at`
+
+	err := r.db.QueryRow(query, time.Now().UTC(), fingerprint).Scan(
+		&key.ID, &key.UserID, &key.Name, &key.Fingerprint, &key.PublicKey, &key.CreatedAt, &key.LastUsedAt,
+	)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return key, nil
+}
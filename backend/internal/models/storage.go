@@ -0,0 +1,76 @@
+package models
+
+import "database/sql"
+
+// StorageUsage summarizes the pastes stored by a user or the whole instance
+type StorageUsage struct {
+	UserID     int   `json:"user_id,omitempty"`
+	PasteCount int64 `json:"paste_count"`
+	Bytes      int64 `json:"bytes"`
+}
+
+// StorageRepository reads the storage usage counters maintained by triggers
+// on the pastes table
+type StorageRepository struct {
+	db *sql.DB
+}
+
+// NewStorageRepository creates a new storage repository
+func NewStorageRepository(db *sql.DB) *StorageRepository {
+	return &StorageRepository{db: db}
+}
+
+// GetUserUsage returns the storage used by a user; users without pastes use nothing
+func (r *StorageRepository) GetUserUsage(userID int) (*StorageUsage, error) {
+	usage := &StorageUsage{UserID: userID}
+	query := `SELECT paste_count, bytes FROM storage_usage WHERE user_id = ?`
+
+	err := r.db.QueryRow(query, userID).Scan(&usage.PasteCount, &usage.Bytes)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, err
+	}
+	return usage, nil
+}
+
+// GetInstanceUsage returns the storage used by all pastes, including anonymous ones
+func (r *StorageRepository) GetInstanceUsage() (*StorageUsage, error) {
+	usage := &StorageUsage{}
+	query := `SELECT COALESCE(SUM(paste_count), 0), COALESCE(SUM(bytes), 0) FROM storage_usage`
+
+	if err := r.db.QueryRow(query).Scan(&usage.PasteCount, &usage.Bytes); err != nil {
+		return nil, err
+	}
+	return usage, nil
+}
+
+// GetAnonymousUsage returns the storage used by pastes without an owner
+func (r *StorageRepository) GetAnonymousUsage() (*StorageUsage, error) {
+	return r.GetUserUsage(0)
+}
+
+// TopUsers returns the registered users using the most storage
+func (r *StorageRepository) TopUsers(limit int) ([]*StorageUsage, error) {
+	query := `
+		SELECT user_id, paste_count, bytes
+		FROM storage_usage
+		WHERE user_id != 0 AND paste_count > 0
+		ORDER BY bytes DESC
+		LIMIT ?`
+
+	rows, err := r.db.Query(query, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	users := []*StorageUsage{}
+	for rows.Next() {
+		usage := &StorageUsage{}
+		if err := rows.Scan(&usage.UserID, &usage.PasteCount, &usage.Bytes); err != nil {
+			return nil, err
+		}
+		users = append(users, usage)
+	}
+
+	return users, rows.Err()
+}
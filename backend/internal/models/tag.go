@@ -0,0 +1,148 @@
+package models
+
+import (
+	"database/sql"
+	"strings"
+)
+
+// TagCount is a tag of a user's together with how many of their pastes
+// carry it
+type TagCount struct {
+	Name  string `json:"name"`
+	Count int    `json:"count"`
+}
+
+// TagRepository stores the tags users put on their pastes
+type TagRepository struct {
+	db *sql.DB
+}
+
+// NewTagRepository creates a new tag repository
+func NewTagRepository(db *sql.DB) *TagRepository {
+	return &TagRepository{db: db}
+}
+
+// SetPasteTags replaces the tags of a paste owned by userID. Tags no paste
+// of the user carries any more are removed.
+func (r *TagRepository) SetPasteTags(pasteID string, userID int, names []string) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`DELETE FROM paste_tags WHERE paste_id = ?`, pasteID); err != nil {
+		return err
+	}
+	for _, name := range names {
+		var tagID int64
+		err := tx.QueryRow(`
+			INSERT INTO tags (user_id, name) VALUES (?, ?)
+			ON CONFLICT(user_id, name) DO UPDATE SET name = excluded.name
+			RETURNING id`, userID, name).Scan(&tagID)
+		if err != nil {
+			return err
+		}
+		if _, err := tx.Exec(`INSERT OR IGNORE INTO paste_tags (paste_id, tag_id) VALUES (?, ?)`, pasteID, tagID); err != nil {
+			return err
+		}
+	}
+	if _, err := tx.Exec(`DELETE FROM tags WHERE user_id = ? AND id NOT IN (SELECT tag_id FROM paste_tags)`, userID); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// ListByPasteIDs returns the tags of each of the given pastes in name
+// order. Pastes without tags are left out.
+func (r *TagRepository) ListByPasteIDs(pasteIDs []string) (map[string][]string, error) {
+	tags := map[string][]string{}
+	if len(pasteIDs) == 0 {
+		return tags, nil
+	}
+
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(pasteIDs)), ", ")
+	args := make([]interface{}, len(pasteIDs))
+	for i, id := range pasteIDs {
+		args[i] = id
+	}
+	rows, err := r.db.Query(`
+		SELECT pt.paste_id, t.name FROM paste_tags pt
+		JOIN tags t ON t.id = pt.tag_id
+		WHERE pt.paste_id IN (`+placeholders+`)
+		ORDER BY t.name`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var pasteID, name string
+		if err := rows.Scan(&pasteID, &name); err != nil {
+			return nil, err
+		}
+		tags[pasteID] = append(tags[pasteID], name)
+	}
+	return tags, rows.Err()
+}
+
+// ListByUserID returns the tags on a user's pastes with how many pastes
+// carry each, most used first
+func (r *TagRepository) ListByUserID(userID int) ([]TagCount, error) {
+	rows, err := r.db.Query(`
+		SELECT t.name, COUNT(*) FROM tags t
+		JOIN paste_tags pt ON pt.tag_id = t.id
+		WHERE t.user_id = ?
+		GROUP BY t.id
+		ORDER BY COUNT(*) DESC, t.name`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tags := []TagCount{}
+	for rows.Next() {
+		var tag TagCount
+		if err := rows.Scan(&tag.Name, &tag.Count); err != nil {
+			return nil, err
+		}
+		tags = append(tags, tag)
+	}
+	return tags, rows.Err()
+}
+
+// ListPastes returns a page of a user's pastes carrying a tag, newest
+// first, together with how many pastes carry it in total
+func (r *TagRepository) ListPastes(userID int, tag string, limit, offset int) ([]*Paste, int, error) {
+	const where = `
+		WHERE user_id = ? AND id IN (
+			SELECT pt.paste_id FROM paste_tags pt
+			JOIN tags t ON t.id = pt.tag_id
+			WHERE t.user_id = ? AND t.name = ?)`
+
+	var total int
+	if err := r.db.QueryRow(`SELECT COUNT(*) FROM pastes`+where, userID, userID, tag).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	rows, err := r.db.Query(`
+		SELECT `+pasteColumns+`
+		FROM pastes`+where+`
+		ORDER BY created_at DESC
+		LIMIT ? OFFSET ?`, userID, userID, tag, limit, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	var pastes []*Paste
+	for rows.Next() {
+		paste, err := scanPaste(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		pastes = append(pastes, paste)
+	}
+	return pastes, total, rows.Err()
+}
@@ -0,0 +1,120 @@
+package models
+
+import (
+	"database/sql"
+	"time"
+)
+
+// DailyViews is the number of times a paste was viewed on one day
+type DailyViews struct {
+	PasteID string
+	Day     time.Time
+	Views   int64
+}
+
+// TrendingScore is a paste's place in the trending ranking
+type TrendingScore struct {
+	PasteID string
+	Score   float64
+	Views   int64 // Views within the ranking window
+}
+
+// TrendingPaste is a trending paste together with its author, if any
+type TrendingPaste struct {
+	*Paste
+	Username string
+	Views    int64
+}
+
+// TrendingRepository handles database operations for the trending pastes
+// ranking, which a background job recomputes from daily view counts
+type TrendingRepository struct {
+	db *sql.DB
+}
+
+// NewTrendingRepository creates a new trending repository
+func NewTrendingRepository(db *sql.DB) *TrendingRepository {
+	return &TrendingRepository{db: db}
+}
+
+// RecentViews returns the daily view counts since the given day of pastes
+// that are publicly listed
+func (r *TrendingRepository) RecentViews(since time.Time) ([]DailyViews, error) {
+	query := `
+		SELECT paste_id, day, views
+		FROM paste_views
+		WHERE day >= ? AND paste_id IN (SELECT id FROM pastes WHERE ` + publiclyListed + `)`
+
+	rows, err := r.db.Query(query, since.UTC().Format("2006-01-02"))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var views []DailyViews
+	for rows.Next() {
+		var v DailyViews
+		var day string
+		if err := rows.Scan(&v.PasteID, &day, &v.Views); err != nil {
+			return nil, err
+		}
+		if v.Day, err = time.Parse("2006-01-02", day); err != nil {
+			return nil, err
+		}
+		views = append(views, v)
+	}
+	return views, rows.Err()
+}
+
+// Replace stores a new ranking in place of the previous one
+func (r *TrendingRepository) Replace(ranking []TrendingScore) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`DELETE FROM trending_pastes`); err != nil {
+		return err
+	}
+	now := time.Now().UTC()
+	for _, entry := range ranking {
+		_, err := tx.Exec(`INSERT INTO trending_pastes (paste_id, score, views, computed_at) VALUES (?, ?, ?, ?)`,
+			entry.PasteID, entry.Score, entry.Views, now)
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// List returns up to limit trending pastes, highest ranked first. Pastes
+// that stopped being publicly listed since the ranking was computed are
+// left out.
+func (r *TrendingRepository) List(limit int) ([]*TrendingPaste, error) {
+	query := `
+		SELECT ` + pasteColumns + `, COALESCE((SELECT username FROM users WHERE users.id = pastes.user_id), ''), t.views
+		FROM trending_pastes t
+		JOIN pastes ON pastes.id = t.paste_id
+		WHERE ` + publiclyListed + `
+		ORDER BY t.score DESC, t.paste_id
+		LIMIT ?`
+
+	rows, err := r.db.Query(query, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	pastes := []*TrendingPaste{}
+	for rows.Next() {
+		entry := &TrendingPaste{}
+		paste, err := scanPaste(withExtraColumns(rows, &entry.Username, &entry.Views))
+		if err != nil {
+			return nil, err
+		}
+		entry.Paste = paste
+		pastes = append(pastes, entry)
+	}
+	return pastes, rows.Err()
+}
@@ -0,0 +1,98 @@
+package models
+
+import (
+	"database/sql"
+	"slices"
+	"time"
+)
+
+// TriggerEvent is something that happened to one of a user's pastes, as
+// reported to polling automation platforms. IDs increase with every event of
+// a kind, so they double as cursors.
+type TriggerEvent struct {
+	ID         int64
+	PasteID    string
+	Language   string
+	Visibility string
+	At         time.Time
+}
+
+// TriggerRepository reads the paste event and view logs for polling triggers
+type TriggerRepository struct {
+	db *sql.DB
+}
+
+// NewTriggerRepository creates a new trigger repository
+func NewTriggerRepository(db *sql.DB) *TriggerRepository {
+	return &TriggerRepository{db: db}
+}
+
+// NewPastes returns creations of a user's pastes that still exist, newest
+// first. See eventsAfter for how the cursor is applied.
+func (r *TriggerRepository) NewPastes(userID int, after int64, limit int) ([]*TriggerEvent, error) {
+	query := `
+		SELECT e.id, p.id, COALESCE(p.language, ''), p.visibility, e.created_at
+		FROM paste_events e JOIN pastes p ON p.id = e.paste_id
+		WHERE e.event = 'create' AND p.user_id = ?
+			AND (p.expires_at IS NULL OR p.expires_at > datetime('now'))`
+
+	return r.eventsAfter(query, "e.id", userID, after, limit)
+}
+
+// PasteViews returns views of a user's pastes that still exist, newest
+// first. See eventsAfter for how the cursor is applied.
+func (r *TriggerRepository) PasteViews(userID int, after int64, limit int) ([]*TriggerEvent, error) {
+	query := `
+		SELECT v.id, p.id, COALESCE(p.language, ''), p.visibility, v.viewed_at
+		FROM paste_view_log v JOIN pastes p ON p.id = v.paste_id
+		WHERE p.user_id = ?
+			AND (p.expires_at IS NULL OR p.expires_at > datetime('now'))`
+
+	return r.eventsAfter(query, "v.id", userID, after, limit)
+}
+
+// PruneViews deletes logged views older than before, returning how many
+// were deleted
+func (r *TriggerRepository) PruneViews(before time.Time) (int64, error) {
+	result, err := r.db.Exec(`DELETE FROM paste_view_log WHERE viewed_at < ?`, before.UTC().Format("2006-01-02 15:04:05"))
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// eventsAfter completes a trigger query ordered by idColumn. Without a
+// cursor it returns the latest events. With one it returns the oldest events
+// after it, so a client that falls behind catches up page by page instead of
+// skipping events; either way the page is returned newest first.
+func (r *TriggerRepository) eventsAfter(query, idColumn string, userID int, after int64, limit int) ([]*TriggerEvent, error) {
+	order := " DESC"
+	if after > 0 {
+		query += " AND " + idColumn + " > ?"
+		order = " ASC"
+	}
+	query += " ORDER BY " + idColumn + order + " LIMIT ?"
+
+	args := []interface{}{userID}
+	if after > 0 {
+		args = append(args, after)
+	}
+	rows, err := r.db.Query(query, append(args, limit)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	events := []*TriggerEvent{}
+	for rows.Next() {
+		event := &TriggerEvent{}
+		if err := rows.Scan(&event.ID, &event.PasteID, &event.Language, &event.Visibility, &event.At); err != nil {
+			return nil, err
+		}
+		events = append(events, event)
+	}
+	if after > 0 {
+		slices.Reverse(events)
+	}
+	return events, rows.Err()
+}
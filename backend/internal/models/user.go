@@ -2,6 +2,8 @@ package models
 
 import (
 	"database/sql"
+	"errors"
+	"strings"
 	"time"
 )
 
@@ -10,7 +12,38 @@ type User struct {
 	ID           int       `json:"id" db:"id"`
 	Username     string    `json:"username" db:"username"`
 	PasswordHash string    `json:"-" db:"password_hash"` // Never expose password hash in JSON
+	Role         string    `json:"role" db:"role"`
 	CreatedAt    time.Time `json:"created_at" db:"created_at"`
+	// DeactivatedAt is set while the account is suspended by provisioning
+	DeactivatedAt *time.Time `json:"-" db:"deactivated_at"`
+}
+
+// User roles
+const (
+	RoleUser    = "user"
+	RoleAdmin   = "admin"
+	RoleService = "service" // Service accounts used by automation
+)
+
+// disabledPasswordHash is stored for accounts that never sign in with a
+// password, such as service accounts and single sign-on users; it matches no
+// password
+const disabledPasswordHash = "!"
+
+// IsAdmin checks if the user has the admin role
+func (u *User) IsAdmin() bool {
+	return u.Role == RoleAdmin
+}
+
+// IsActive reports whether the user may sign in
+func (u *User) IsActive() bool {
+	return u.DeactivatedAt == nil
+}
+
+// HasPassword reports whether the user can sign in with a password, rather
+// than only through single sign-on or client credentials
+func (u *User) HasPassword() bool {
+	return u.PasswordHash != disabledPasswordHash
 }
 
 // UserRepository handles database operations for users
@@ -28,22 +61,24 @@ func (r *UserRepository) Create(user *User) error {
 	query := `
 		INSERT INTO users (username, password_hash)
 		VALUES (?, ?)
-		RETURNING id, created_at`
+		RETURNING id, role, created_at`
 
-	err := r.db.QueryRow(query, user.Username, user.PasswordHash).Scan(&user.ID, &user.CreatedAt)
+	err := r.db.QueryRow(query, user.Username, user.PasswordHash).Scan(&user.ID, &user.Role, &user.CreatedAt)
 	return err
 }
 
 // GetByID retrieves a user by their ID
 func (r *UserRepository) GetByID(id int) (*User, error) {
 	user := &User{}
-	query := `SELECT id, username, password_hash, created_at FROM users WHERE id = ?`
+	query := `SELECT id, username, password_hash, role, created_at, deactivated_at FROM users WHERE id = ?`
 
 	err := r.db.QueryRow(query, id).Scan(
 		&user.ID,
 		&user.Username,
 		&user.PasswordHash,
+		&user.Role,
 		&user.CreatedAt,
+		&user.DeactivatedAt,
 	)
 
 	if err == sql.ErrNoRows {
@@ -56,16 +91,18 @@ func (r *UserRepository) GetByID(id int) (*User, error) {
 	return user, nil
 }
 
-// GetByUsername retrieves a user by their username
+// GetByUsername retrieves a user by their username, ignoring case
 func (r *UserRepository) GetByUsername(username string) (*User, error) {
 	user := &User{}
-	query := `SELECT id, username, password_hash, created_at FROM users WHERE username = ?`
+	query := `SELECT id, username, password_hash, role, created_at, deactivated_at FROM users WHERE username = ? COLLATE NOCASE`
 
 	err := r.db.QueryRow(query, username).Scan(
 		&user.ID,
 		&user.Username,
 		&user.PasswordHash,
+		&user.Role,
 		&user.CreatedAt,
+		&user.DeactivatedAt,
 	)
 
 	if err == sql.ErrNoRows {
@@ -96,10 +133,107 @@ func (r *UserRepository) Delete(id int) error {
 	return err
 }
 
-// Exists checks if a username already exists
+// Exists checks if a username already exists, ignoring case
 func (r *UserRepository) Exists(username string) (bool, error) {
 	var count int
-	query := `SELECT COUNT(*) FROM users WHERE username = ?`
+	query := `SELECT COUNT(*) FROM users WHERE username = ? COLLATE NOCASE`
 	err := r.db.QueryRow(query, username).Scan(&count)
 	return count > 0, err
 }
+
+// IsHeld reports whether a username was recently given up by a user other
+// than the given one and is still reserved for them
+func (r *UserRepository) IsHeld(username string, userID int) (bool, error) {
+	var held bool
+	query := `SELECT EXISTS(SELECT 1 FROM username_holds WHERE username = ? AND user_id != ? AND held_until > ?)`
+	err := r.db.QueryRow(query, username, userID, time.Now().UTC()).Scan(&held)
+	return held, err
+}
+
+// Rename changes a user's username. Unless cooldown is zero, the old name is
+// held for the user until the cooldown passes so nobody else can take it;
+// taking back one's own held name releases the hold.
+func (r *UserRepository) Rename(user *User, username string, cooldown time.Duration) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if cooldown > 0 && !strings.EqualFold(username, user.Username) {
+		_, err = tx.Exec(`INSERT OR REPLACE INTO username_holds (username, user_id, held_until) VALUES (?, ?, ?)`,
+			user.Username, user.ID, time.Now().UTC().Add(cooldown))
+		if err != nil {
+			return err
+		}
+	}
+	if _, err := tx.Exec(`DELETE FROM username_holds WHERE username = ?`, username); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`UPDATE users SET username = ? WHERE id = ?`, username, user.ID); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	user.Username = username
+	return nil
+}
+
+// SetRoleByUsername assigns a role to the named user, reporting whether the user exists
+func (r *UserRepository) SetRoleByUsername(username, role string) (bool, error) {
+	query := `UPDATE users SET role = ? WHERE username = ? COLLATE NOCASE`
+	result, err := r.db.Exec(query, role, username)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	return affected > 0, err
+}
+
+// ErrEmailTaken is returned when a verified email address already belongs to
+// another account
+var ErrEmailTaken = errors.New("email address belongs to another account")
+
+// SetVerifiedEmail records a verified email address for a user, replacing
+// any previous one
+func (r *UserRepository) SetVerifiedEmail(userID int, email string) error {
+	email = strings.ToLower(email)
+
+	var owner int
+	err := r.db.QueryRow(`SELECT id FROM users WHERE verified_email = ?`, email).Scan(&owner)
+	if err == nil && owner != userID {
+		return ErrEmailTaken
+	}
+	if err != nil && err != sql.ErrNoRows {
+		return err
+	}
+
+	_, err = r.db.Exec(`UPDATE users SET verified_email = ? WHERE id = ?`, email, userID)
+	return err
+}
+
+// GetByVerifiedEmail retrieves the user with the given verified email
+// address, returning nil if there is none
+func (r *UserRepository) GetByVerifiedEmail(email string) (*User, error) {
+	user := &User{}
+	query := `SELECT id, username, password_hash, role, created_at, deactivated_at FROM users WHERE verified_email = ?`
+
+	err := r.db.QueryRow(query, strings.ToLower(email)).Scan(
+		&user.ID,
+		&user.Username,
+		&user.PasswordHash,
+		&user.Role,
+		&user.CreatedAt,
+		&user.DeactivatedAt,
+	)
+
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return user, nil
+}
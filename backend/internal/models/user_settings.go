@@ -0,0 +1,51 @@
+package models
+
+import "database/sql"
+
+// UserSettings are a user's defaults for new pastes, applied when a create
+// request leaves the option out. Empty values mean no default.
+type UserSettings struct {
+	UserID            int    `json:"-" db:"user_id"`
+	DefaultExpiry     string `json:"default_expiry" db:"default_expiry"` // Duration string like "7d", or "never"
+	DefaultVisibility string `json:"default_visibility" db:"default_visibility"`
+	DefaultLanguage   string `json:"default_language" db:"default_language"`
+}
+
+// UserSettingsRepository handles database operations for user settings
+type UserSettingsRepository struct {
+	db *sql.DB
+}
+
+// NewUserSettingsRepository creates a new user settings repository
+func NewUserSettingsRepository(db *sql.DB) *UserSettingsRepository {
+	return &UserSettingsRepository{db: db}
+}
+
+// Get returns a user's settings; users who never saved any have no defaults
+func (r *UserSettingsRepository) Get(userID int) (*UserSettings, error) {
+	settings := &UserSettings{UserID: userID}
+	query := `
+		SELECT default_expiry, default_visibility, default_language
+		FROM user_settings WHERE user_id = ?`
+
+	err := r.db.QueryRow(query, userID).Scan(&settings.DefaultExpiry, &settings.DefaultVisibility, &settings.DefaultLanguage)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, err
+	}
+	return settings, nil
+}
+
+// Set stores a user's settings, replacing any previous ones
+func (r *UserSettingsRepository) Set(settings *UserSettings) error {
+	query := `
+		INSERT INTO user_settings (user_id, default_expiry, default_visibility, default_language)
+		VALUES (?, ?, ?, ?)
+		ON CONFLICT (user_id) DO UPDATE SET
+			default_expiry = excluded.default_expiry,
+			default_visibility = excluded.default_visibility,
+			default_language = excluded.default_language,
+			updated_at = CURRENT_TIMESTAMP`
+
+	_, err := r.db.Exec(query, settings.UserID, settings.DefaultExpiry, settings.DefaultVisibility, settings.DefaultLanguage)
+	return err
+}
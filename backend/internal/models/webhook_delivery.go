@@ -0,0 +1,127 @@
+package models
+
+import (
+	"database/sql"
+	"encoding/json"
+	"time"
+)
+
+// maxDeliveriesPerIntegration is how many delivery attempts are kept for
+// each integration; older ones are dropped as new ones are recorded
+const maxDeliveriesPerIntegration = 100
+
+// Webhook delivery events
+const (
+	WebhookEventShare  = "share"
+	WebhookEventTest   = "test"
+	WebhookEventDigest = "digest"
+)
+
+// WebhookDeliveryAttempt records one attempt to deliver a webhook, for users
+// debugging their integrations
+type WebhookDeliveryAttempt struct {
+	ID            int64           `json:"id" db:"id"`
+	IntegrationID int64           `json:"-" db:"integration_id"`
+	Event         string          `json:"event" db:"event"`
+	Body          json.RawMessage `json:"-" db:"body"` // Kept for redelivery
+	Success       bool            `json:"success" db:"success"`
+	StatusCode    *int            `json:"status_code,omitempty" db:"status_code"` // Nil if no response was received
+	LatencyMS     int64           `json:"latency_ms" db:"latency_ms"`
+	Error         string          `json:"error,omitempty" db:"error"`
+	CreatedAt     time.Time       `json:"created_at" db:"created_at"`
+}
+
+// WebhookDeliveryRepository handles database operations for the webhook
+// delivery log
+type WebhookDeliveryRepository struct {
+	db *sql.DB
+}
+
+// NewWebhookDeliveryRepository creates a new webhook delivery repository
+func NewWebhookDeliveryRepository(db *sql.DB) *WebhookDeliveryRepository {
+	return &WebhookDeliveryRepository{db: db}
+}
+
+// Record stores a delivery attempt and drops the integration's oldest
+// attempts beyond the retention limit
+func (r *WebhookDeliveryRepository) Record(attempt *WebhookDeliveryAttempt) error {
+	query := `
+		INSERT INTO webhook_deliveries (integration_id, event, body, success, status_code, latency_ms, error)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+		RETURNING id, created_at`
+
+	err := r.db.QueryRow(query, attempt.IntegrationID, attempt.Event, string(attempt.Body), attempt.Success,
+		attempt.StatusCode, attempt.LatencyMS, attempt.Error,
+	).Scan(&attempt.ID, &attempt.CreatedAt)
+	if err != nil {
+		return err
+	}
+
+	_, err = r.db.Exec(`
+		DELETE FROM webhook_deliveries
+		WHERE integration_id = ? AND id <= (
+			SELECT id FROM webhook_deliveries WHERE integration_id = ?
+			ORDER BY id DESC LIMIT 1 OFFSET ?
+		)`, attempt.IntegrationID, attempt.IntegrationID, maxDeliveriesPerIntegration)
+	return err
+}
+
+// ListByIntegration returns an integration's delivery attempts, newest first
+func (r *WebhookDeliveryRepository) ListByIntegration(integrationID int64, limit, offset int) ([]*WebhookDeliveryAttempt, error) {
+	query := `
+		SELECT id, integration_id, event, body, success, status_code, latency_ms, error, created_at
+		FROM webhook_deliveries WHERE integration_id = ?
+		ORDER BY id DESC
+		LIMIT ? OFFSET ?`
+
+	rows, err := r.db.Query(query, integrationID, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var attempts []*WebhookDeliveryAttempt
+	for rows.Next() {
+		a, err := scanWebhookDelivery(rows)
+		if err != nil {
+			return nil, err
+		}
+		attempts = append(attempts, a)
+	}
+	return attempts, rows.Err()
+}
+
+// CountByIntegration returns how many delivery attempts are kept for an
+// integration
+func (r *WebhookDeliveryRepository) CountByIntegration(integrationID int64) (int, error) {
+	var count int
+	err := r.db.QueryRow(`SELECT COUNT(*) FROM webhook_deliveries WHERE integration_id = ?`, integrationID).Scan(&count)
+	return count, err
+}
+
+// GetByID retrieves one of an integration's delivery attempts, returning nil
+// if it does not exist
+func (r *WebhookDeliveryRepository) GetByID(integrationID, id int64) (*WebhookDeliveryAttempt, error) {
+	query := `
+		SELECT id, integration_id, event, body, success, status_code, latency_ms, error, created_at
+		FROM webhook_deliveries WHERE integration_id = ? AND id = ?`
+
+	a, err := scanWebhookDelivery(r.db.QueryRow(query, integrationID, id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+// scanWebhookDelivery scans a delivery attempt row
+func scanWebhookDelivery(row interface{ Scan(...interface{}) error }) (*WebhookDeliveryAttempt, error) {
+	a := &WebhookDeliveryAttempt{}
+	var body string
+	err := row.Scan(&a.ID, &a.IntegrationID, &a.Event, &body, &a.Success, &a.StatusCode,
+		&a.LatencyMS, &a.Error, &a.CreatedAt)
+	a.Body = json.RawMessage(body)
+	return a, err
+}
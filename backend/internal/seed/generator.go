@@ -0,0 +1,201 @@
+// Package seed generates deterministic synthetic users and pastes for load
+// testing and development
+package seed
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"strings"
+	"time"
+
+	"github.com/LonleySailor/privatepaste/backend/internal/models"
+	"github.com/LonleySailor/privatepaste/backend/pkg/utils"
+)
+
+// weighted is a value picked with a relative weight
+type weighted[T any] struct {
+	value  T
+	weight int
+}
+
+// Language mix, roughly following what a developer pastebin sees
+var seedLanguages = []weighted[string]{
+	{"", 20}, {"text", 10}, {"go", 10}, {"javascript", 12}, {"python", 14},
+	{"bash", 8}, {"sql", 6}, {"json", 8}, {"rust", 4}, {"java", 4}, {"diff", 4},
+}
+
+// Expiry mix; a zero duration never expires and a negative one is already
+// expired, so cleanup has a backlog to work through
+var seedExpiries = []weighted[time.Duration]{
+	{0, 40}, {time.Hour, 10}, {24 * time.Hour, 20}, {7 * 24 * time.Hour, 15},
+	{30 * 24 * time.Hour, 10}, {-time.Hour, 5},
+}
+
+var seedVisibilities = []weighted[string]{
+	{models.VisibilityPublic, 70}, {models.VisibilityUnlisted, 20}, {models.VisibilityPrivate, 10},
+}
+
+// Line templates per language; %d is replaced with a line-specific number
+var seedLines = map[string][]string{
+	"go":         {"func handle%d(w http.ResponseWriter, r *http.Request) {", "\tif err := run(%d); err != nil {", "\t\treturn fmt.Errorf(\"step %d: %w\", err)", "}"},
+	"javascript": {"const value%d = await fetch(`/api/items/%d`);", "if (!response.ok) throw new Error('failed %d');", "export function render%d(props) {", "}"},
+	"python":     {"def process_%d(items):", "    for item in items[:%d]:", "        print(f\"item {item} of %d\")", "    return None"},
+	"bash":       {"for i in $(seq 1 %d); do", "  curl -s http://localhost:8080/api/health > /dev/null", "done", "echo \"finished %d\""},
+	"sql":        {"SELECT id, name FROM users WHERE id = %d;", "UPDATE accounts SET balance = balance - %d WHERE id = 1;", "-- migration step %d", "COMMIT;"},
+	"json":       {"  \"id\": %d,", "  \"name\": \"item-%d\",", "  \"tags\": [\"seed\", \"%d\"],", "  \"active\": true"},
+	"rust":       {"fn compute_%d(x: u64) -> u64 {", "    let y = x * %d;", "    y + %d", "}"},
+	"java":       {"public int compute%d(int x) {", "    return x * %d;", "}", "// helper %d"},
+	"diff":       {"@@ -%d,3 +%d,3 @@", "-old line %d", "+new line %d", " context"},
+}
+
+var seedProse = []string{
+	"Error: connection refused after %d retries",
+	"TODO: follow up on ticket %d before the release",
+	"2025-01-01T12:00:00Z INFO request served in %dms",
+	"Notes from the meeting, item %d: ship the fix",
+}
+
+// Generator produces deterministic synthetic pastes; the same seed always
+// yields the same sequence
+type Generator struct {
+	rng *rand.Rand
+	now time.Time
+}
+
+// NewGenerator creates a generator for seed, with expiries relative to now
+func NewGenerator(seed int64, now time.Time) *Generator {
+	return &Generator{rng: rand.New(rand.NewSource(seed)), now: now}
+}
+
+// pick chooses a value according to its weight
+func pick[T any](rng *rand.Rand, choices []weighted[T]) T {
+	total := 0
+	for _, c := range choices {
+		total += c.weight
+	}
+	n := rng.Intn(total)
+	for _, c := range choices {
+		if n < c.weight {
+			return c.value
+		}
+		n -= c.weight
+	}
+	return choices[len(choices)-1].value
+}
+
+// ID returns a random ID from the paste charset
+func (g *Generator) ID(length int) string {
+	b := make([]byte, length)
+	for i := range b {
+		b[i] = utils.Charset[g.rng.Intn(len(utils.Charset))]
+	}
+	return string(b)
+}
+
+// contentSize returns a log-normal size in bytes with a median around 1KB,
+// capped below the paste size limit
+func (g *Generator) contentSize() int {
+	size := int(math.Exp(g.rng.NormFloat64()*1.2 + math.Log(1024)))
+	if size < 16 {
+		size = 16
+	}
+	if size > 900000 {
+		size = 900000
+	}
+	return size
+}
+
+// content builds roughly size bytes of text that looks like the language
+func (g *Generator) content(language string, size int) string {
+	lines, ok := seedLines[language]
+	if !ok {
+		lines = seedProse
+	}
+
+	var b strings.Builder
+	for b.Len() < size {
+		line := lines[g.rng.Intn(len(lines))]
+		if strings.Contains(line, "%d") {
+			line = strings.ReplaceAll(line, "%d", fmt.Sprint(g.rng.Intn(1000)))
+		}
+		b.WriteString(line)
+		b.WriteByte('\n')
+	}
+	return b.String()
+}
+
+// Paste generates a paste owned by one of userIDs, or anonymous if there are none
+func (g *Generator) Paste(userIDs []int) *models.Paste {
+	language := pick(g.rng, seedLanguages)
+	visibility := pick(g.rng, seedVisibilities)
+
+	paste := &models.Paste{
+		Content:    g.content(language, g.contentSize()),
+		Language:   language,
+		Kind:       models.KindText,
+		Visibility: visibility,
+		DoNotTrack: g.rng.Intn(20) == 0,
+	}
+	if language == "diff" {
+		paste.Kind = models.KindDiff
+	}
+
+	// Unlisted and private pastes use long IDs, as the API assigns them
+	if visibility == models.VisibilityPublic {
+		paste.ID = g.ID(utils.IDLength)
+	} else {
+		paste.ID = g.ID(utils.LongIDLength)
+	}
+
+	if expiry := pick(g.rng, seedExpiries); expiry != 0 {
+		expiresAt := g.now.Add(expiry)
+		paste.ExpiresAt = &expiresAt
+	}
+
+	// Private pastes need an owner; others are anonymous 30% of the time
+	if len(userIDs) > 0 && (visibility == models.VisibilityPrivate || g.rng.Intn(10) >= 3) {
+		userID := userIDs[g.rng.Intn(len(userIDs))]
+		paste.UserID = &userID
+	} else if visibility == models.VisibilityPrivate {
+		paste.Visibility = models.VisibilityUnlisted
+	}
+
+	return paste
+}
+
+// PasteStore is the subset of the paste repository needed to store seed data
+type PasteStore interface {
+	Create(paste *models.Paste) error
+	Exists(id string) (bool, error)
+}
+
+// CreatePastes generates and stores n pastes owned by userIDs, calling
+// progress (if set) after each one. It returns the total content size.
+func (g *Generator) CreatePastes(store PasteStore, n int, userIDs []int, progress func(done int)) (int64, error) {
+	var bytes int64
+	for i := 0; i < n; i++ {
+		paste := g.Paste(userIDs)
+		// Regenerate the rare ID that collides with an existing paste
+		for {
+			exists, err := store.Exists(paste.ID)
+			if err != nil {
+				return bytes, fmt.Errorf("failed to check paste ID: %w", err)
+			}
+			if !exists {
+				break
+			}
+			paste.ID = g.ID(len(paste.ID))
+		}
+
+		if err := store.Create(paste); err != nil {
+			return bytes, fmt.Errorf("failed to create paste %d: %w", i, err)
+		}
+		bytes += int64(len(paste.Content))
+
+		if progress != nil {
+			progress(i + 1)
+		}
+	}
+	return bytes, nil
+}
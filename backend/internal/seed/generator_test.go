@@ -0,0 +1,41 @@
+package seed
+
+import (
+	"testing"
+	"time"
+
+	"github.com/LonleySailor/privatepaste/backend/internal/models"
+)
+
+func TestGeneratorIsDeterministic(t *testing.T) {
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	a, b := NewGenerator(42, now), NewGenerator(42, now)
+	userIDs := []int{1, 2, 3}
+
+	for i := 0; i < 50; i++ {
+		pa, pb := a.Paste(userIDs), b.Paste(userIDs)
+		if pa.ID != pb.ID || pa.Content != pb.Content || pa.Language != pb.Language {
+			t.Fatalf("Paste %d differs between generators with the same seed", i)
+		}
+	}
+}
+
+func TestGeneratedPastesAreValid(t *testing.T) {
+	gen := NewGenerator(7, time.Now())
+
+	for i := 0; i < 500; i++ {
+		paste := gen.Paste(nil)
+		if len(paste.Content) == 0 || len(paste.Content) > 1000000 {
+			t.Fatalf("Content size %d outside the paste limit", len(paste.Content))
+		}
+		if paste.Visibility == models.VisibilityPrivate {
+			t.Fatal("Expected anonymous pastes never to be private")
+		}
+		if paste.Visibility == models.VisibilityPublic && len(paste.ID) != 6 {
+			t.Fatalf("Expected a 6 character ID for a public paste, got %q", paste.ID)
+		}
+		if paste.Visibility != models.VisibilityPublic && len(paste.ID) != 20 {
+			t.Fatalf("Expected a long ID for a %s paste, got %q", paste.Visibility, paste.ID)
+		}
+	}
+}
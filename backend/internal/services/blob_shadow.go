@@ -0,0 +1,132 @@
+package services
+
+import (
+	"bytes"
+	"log"
+	"sync"
+
+	"github.com/LonleySailor/privatepaste/backend/internal/metrics"
+	"github.com/LonleySailor/privatepaste/backend/internal/models"
+)
+
+var blobShadowResults = metrics.NewCounter("pastevault_blob_shadow_total",
+	"Operations mirrored to the shadow blob store, by operation and result (ok, match, missing, mismatch, error, skipped).", "op", "result")
+
+// maxShadowReads bounds the shadow reads compared at once; reads beyond it
+// are skipped rather than queued
+const maxShadowReads = 8
+
+// ShadowBlobStore de-risks moving attachment contents to a new store, such
+// as from the database to S3. Every write goes to both stores and every read
+// is served from the primary, then compared in the background with what the
+// shadow returns. Failures of the shadow never fail a request; they and any
+// divergence are counted in pastevault_blob_shadow_total. Once the shadow
+// has been backfilled and reads stop diverging, it can become the primary.
+//
+// Contents removed by database triggers, when pastes are deleted or expire,
+// reach both stores through the blob deletion job.
+type ShadowBlobStore struct {
+	primary models.BlobStore
+	shadow  models.BlobStore
+	reads   chan struct{}
+	wg      sync.WaitGroup
+}
+
+// NewShadowBlobStore creates a store mirroring primary to shadow
+func NewShadowBlobStore(primary, shadow models.BlobStore) *ShadowBlobStore {
+	return &ShadowBlobStore{primary: primary, shadow: shadow, reads: make(chan struct{}, maxShadowReads)}
+}
+
+// Put stores content in the primary, then the shadow
+func (s *ShadowBlobStore) Put(hash string, data []byte) error {
+	if err := s.primary.Put(hash, data); err != nil {
+		return err
+	}
+	if err := s.shadow.Put(hash, data); err != nil {
+		log.Printf("Shadow blob store failed to store %s: %v", hash, err)
+		blobShadowResults.Inc("put", "error")
+		return nil
+	}
+	blobShadowResults.Inc("put", "ok")
+	return nil
+}
+
+// Get returns content from the primary, comparing the shadow's copy in the
+// background
+func (s *ShadowBlobStore) Get(hash string) ([]byte, error) {
+	data, err := s.primary.Get(hash)
+	if err != nil {
+		return nil, err
+	}
+
+	select {
+	case s.reads <- struct{}{}:
+	default:
+		blobShadowResults.Inc("get", "skipped")
+		return data, nil
+	}
+	s.wg.Add(1)
+	go func() {
+		defer func() {
+			<-s.reads
+			s.wg.Done()
+		}()
+		blobShadowResults.Inc("get", compareShadow(hash, data, s.shadow))
+	}()
+	return data, nil
+}
+
+// Delete removes content from the primary, then the shadow
+func (s *ShadowBlobStore) Delete(hash string) error {
+	if err := s.primary.Delete(hash); err != nil {
+		return err
+	}
+	if err := s.shadow.Delete(hash); err != nil {
+		log.Printf("Shadow blob store failed to delete %s: %v", hash, err)
+		blobShadowResults.Inc("delete", "error")
+		return nil
+	}
+	blobShadowResults.Inc("delete", "ok")
+	return nil
+}
+
+// Backfill copies content the shadow is missing from the primary, reporting
+// whether it copied anything
+func (s *ShadowBlobStore) Backfill(hash string) (bool, error) {
+	existing, err := s.shadow.Get(hash)
+	if err != nil || existing != nil {
+		return false, err
+	}
+	data, err := s.primary.Get(hash)
+	if err != nil || data == nil {
+		return false, err
+	}
+	if err := s.shadow.Put(hash, data); err != nil {
+		blobShadowResults.Inc("backfill", "error")
+		return false, err
+	}
+	blobShadowResults.Inc("backfill", "ok")
+	return true, nil
+}
+
+// Wait blocks until background comparisons have finished
+func (s *ShadowBlobStore) Wait() {
+	s.wg.Wait()
+}
+
+// compareShadow reads hash from the shadow and classifies it against what
+// the primary returned
+func compareShadow(hash string, primary []byte, shadow models.BlobStore) string {
+	data, err := shadow.Get(hash)
+	switch {
+	case err != nil:
+		log.Printf("Shadow blob store failed to read %s: %v", hash, err)
+		return "error"
+	case data == nil && primary != nil:
+		return "missing"
+	case !bytes.Equal(data, primary):
+		log.Printf("Shadow blob store diverges from the primary for %s", hash)
+		return "mismatch"
+	}
+	return "match"
+}
@@ -0,0 +1,146 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/LonleySailor/privatepaste/backend/internal/database"
+	"github.com/LonleySailor/privatepaste/backend/internal/models"
+)
+
+// memoryBlobs is an in-memory blob store that can be made to fail
+type memoryBlobs struct {
+	mu    sync.Mutex
+	blobs map[string][]byte
+	err   error
+}
+
+func (m *memoryBlobs) Put(hash string, data []byte) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.err != nil {
+		return m.err
+	}
+	m.blobs[hash] = data
+	return nil
+}
+
+func (m *memoryBlobs) Get(hash string) ([]byte, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.blobs[hash], m.err
+}
+
+func (m *memoryBlobs) Delete(hash string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.blobs, hash)
+	return m.err
+}
+
+func TestShadowBlobStore(t *testing.T) {
+	primary := &memoryBlobs{blobs: map[string][]byte{}}
+	shadow := &memoryBlobs{blobs: map[string][]byte{}}
+	store := NewShadowBlobStore(primary, shadow)
+
+	if err := store.Put("a", []byte("alpha")); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+	if string(shadow.blobs["a"]) != "alpha" {
+		t.Error("Expected writes to reach the shadow")
+	}
+
+	// Content written before shadowing began, and content that diverged
+	primary.blobs["old"] = []byte("old")
+	primary.blobs["b"] = []byte("beta")
+	shadow.blobs["b"] = []byte("corrupted")
+
+	before := map[string]float64{}
+	for _, result := range []string{"match", "missing", "mismatch", "error"} {
+		before[result] = blobShadowResults.Value("get", result)
+	}
+	for _, hash := range []string{"a", "old", "b"} {
+		data, err := store.Get(hash)
+		if err != nil || string(data) != string(primary.blobs[hash]) {
+			t.Errorf("Expected reads served from the primary, got %q, %v", data, err)
+		}
+	}
+	store.Wait()
+	for result, want := range map[string]float64{"match": 1, "missing": 1, "mismatch": 1, "error": 0} {
+		if got := blobShadowResults.Value("get", result) - before[result]; got != want {
+			t.Errorf("Expected %v shadow reads to %s, got %v", want, result, got)
+		}
+	}
+
+	// A failing shadow never fails requests
+	shadow.err = errors.New("bucket unreachable")
+	if err := store.Put("c", []byte("gamma")); err != nil {
+		t.Errorf("Expected a shadow failure to be ignored, got %v", err)
+	}
+	if data, err := store.Get("c"); err != nil || string(data) != "gamma" {
+		t.Errorf("Expected the primary's content, got %q, %v", data, err)
+	}
+	if err := store.Delete("c"); err != nil {
+		t.Errorf("Expected a shadow failure to be ignored, got %v", err)
+	}
+	store.Wait()
+}
+
+func TestShadowBlobStore_Backfill(t *testing.T) {
+	primary := &memoryBlobs{blobs: map[string][]byte{"old": []byte("old")}}
+	shadow := &memoryBlobs{blobs: map[string][]byte{}}
+	store := NewShadowBlobStore(primary, shadow)
+
+	if copied, err := store.Backfill("old"); err != nil || !copied {
+		t.Fatalf("Expected missing content copied to the shadow, got %v, %v", copied, err)
+	}
+	if string(shadow.blobs["old"]) != "old" {
+		t.Errorf("Expected the shadow to hold the content, got %q", shadow.blobs["old"])
+	}
+	if copied, err := store.Backfill("old"); err != nil || copied {
+		t.Errorf("Expected content already in the shadow to be left alone, got %v, %v", copied, err)
+	}
+}
+
+func TestBlobDeletionJob(t *testing.T) {
+	db, err := database.NewSQLiteDB(":memory:")
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	shadow := &memoryBlobs{blobs: map[string][]byte{}}
+	attachmentRepo := models.NewAttachmentRepository(db.DB)
+	attachmentRepo.SetBlobStore(NewShadowBlobStore(models.NewInlineBlobStore(db.DB), shadow))
+	pasteRepo := models.NewPasteRepository(db.DB)
+	if err := pasteRepo.Create(&models.Paste{ID: "att", Content: "x", Kind: models.KindText, Visibility: models.VisibilityPublic}); err != nil {
+		t.Fatalf("Failed to create paste: %v", err)
+	}
+	attachment := &models.Attachment{PasteID: "att", Name: "notes.txt", ContentType: "text/plain"}
+	if err := attachmentRepo.Put(attachment, []byte("notes")); err != nil {
+		t.Fatalf("Failed to store attachment: %v", err)
+	}
+
+	// Deleting the paste removes the attachment by trigger, out of reach of
+	// the blob store
+	if err := pasteRepo.Delete("att"); err != nil {
+		t.Fatalf("Failed to delete paste: %v", err)
+	}
+	if shadow.blobs[attachment.SHA256] == nil {
+		t.Fatal("Expected the shadow to still hold the content before the job runs")
+	}
+
+	if err := NewBlobDeletionJob(attachmentRepo).Run(context.Background()); err != nil {
+		t.Fatalf("Blob deletion job failed: %v", err)
+	}
+	if shadow.blobs[attachment.SHA256] != nil {
+		t.Error("Expected the job to remove the content from the shadow")
+	}
+	var pending int
+	db.DB.QueryRow(`SELECT COUNT(*) FROM blob_deletions`).Scan(&pending)
+	if pending != 0 {
+		t.Errorf("Expected no deletions left pending, got %d", pending)
+	}
+}
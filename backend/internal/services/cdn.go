@@ -0,0 +1,96 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// JobTypeCDNPurge is the job type purging cached responses at the CDN
+const JobTypeCDNPurge = "cdn_purge"
+
+// Supported CDN providers
+const (
+	CDNFastly     = "fastly"
+	CDNCloudflare = "cloudflare"
+)
+
+// cdnPurgeTimeout bounds a single purge request
+const cdnPurgeTimeout = 10 * time.Second
+
+// cdnPurgeBatch is the most keys sent in one purge request; Cloudflare
+// accepts up to 30 tags per call
+const cdnPurgeBatch = 30
+
+// CDN API endpoints; variables so tests can point them at a stub
+var (
+	fastlyAPIURL     = "https://api.fastly.com"
+	cloudflareAPIURL = "https://api.cloudflare.com/client/v4"
+)
+
+// CDNPurge is the payload of a purge job: the surrogate keys to invalidate
+type CDNPurge struct {
+	Keys []string `json:"keys"`
+}
+
+// NewCDNPurger returns the job handler that purges surrogate keys through
+// the provider's API. serviceID is the Fastly service ID or the Cloudflare
+// zone ID. Cloudflare only purges by tag on Enterprise plans.
+func NewCDNPurger(provider, serviceID, token string, client *http.Client) (JobHandler, error) {
+	if provider != CDNFastly && provider != CDNCloudflare {
+		return nil, fmt.Errorf("unsupported CDN provider %q", provider)
+	}
+	if serviceID == "" || token == "" {
+		return nil, fmt.Errorf("%s purging needs a service ID and an API token", provider)
+	}
+	if client == nil {
+		client = &http.Client{Timeout: cdnPurgeTimeout}
+	}
+
+	return func(ctx context.Context, payload []byte) error {
+		var purge CDNPurge
+		if err := json.Unmarshal(payload, &purge); err != nil {
+			return fmt.Errorf("invalid purge payload: %w", err)
+		}
+		if len(purge.Keys) == 0 {
+			return nil
+		}
+
+		var req *http.Request
+		var err error
+		if provider == CDNFastly {
+			req, err = http.NewRequestWithContext(ctx, http.MethodPost, fastlyAPIURL+"/service/"+url.PathEscape(serviceID)+"/purge", nil)
+			if err != nil {
+				return err
+			}
+			req.Header.Set("Fastly-Key", token)
+			req.Header.Set("Surrogate-Key", strings.Join(purge.Keys, " "))
+		} else {
+			body, _ := json.Marshal(map[string][]string{"tags": purge.Keys})
+			req, err = http.NewRequestWithContext(ctx, http.MethodPost, cloudflareAPIURL+"/zones/"+url.PathEscape(serviceID)+"/purge_cache", bytes.NewReader(body))
+			if err != nil {
+				return err
+			}
+			req.Header.Set("Authorization", "Bearer "+token)
+			req.Header.Set("Content-Type", "application/json")
+		}
+
+		resp, err := client.Do(req)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+		io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+		if resp.StatusCode < 200 || resp.StatusCode > 299 {
+			return fmt.Errorf("%s purge responded with status %d", provider, resp.StatusCode)
+		}
+		return nil
+	}, nil
+}
@@ -0,0 +1,57 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCDNPurger(t *testing.T) {
+	var got *http.Request
+	var body string
+	status := http.StatusOK
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		got, body = r, string(b)
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+	defer func(fastly, cloudflare string) { fastlyAPIURL, cloudflareAPIURL = fastly, cloudflare }(fastlyAPIURL, cloudflareAPIURL)
+	fastlyAPIURL, cloudflareAPIURL = server.URL, server.URL
+
+	payload, _ := json.Marshal(CDNPurge{Keys: []string{"paste-abc123", "blob-ff00"}})
+
+	fastly, err := NewCDNPurger(CDNFastly, "svc1", "token", server.Client())
+	if err != nil {
+		t.Fatalf("NewCDNPurger failed: %v", err)
+	}
+	if err := fastly(context.Background(), payload); err != nil {
+		t.Fatalf("Fastly purge failed: %v", err)
+	}
+	if got.URL.Path != "/service/svc1/purge" || got.Header.Get("Fastly-Key") != "token" || got.Header.Get("Surrogate-Key") != "paste-abc123 blob-ff00" {
+		t.Errorf("Unexpected Fastly request %s %v", got.URL.Path, got.Header)
+	}
+
+	cloudflare, err := NewCDNPurger(CDNCloudflare, "zone1", "token", server.Client())
+	if err != nil {
+		t.Fatalf("NewCDNPurger failed: %v", err)
+	}
+	if err := cloudflare(context.Background(), payload); err != nil {
+		t.Fatalf("Cloudflare purge failed: %v", err)
+	}
+	if got.URL.Path != "/zones/zone1/purge_cache" || got.Header.Get("Authorization") != "Bearer token" || body != `{"tags":["paste-abc123","blob-ff00"]}` {
+		t.Errorf("Unexpected Cloudflare request %s %q", got.URL.Path, body)
+	}
+
+	status = http.StatusForbidden
+	if err := fastly(context.Background(), payload); err == nil {
+		t.Error("Expected a non-2xx response to fail the attempt")
+	}
+
+	if _, err := NewCDNPurger("akamai", "svc1", "token", nil); err == nil {
+		t.Error("Expected an unsupported provider to be rejected")
+	}
+}
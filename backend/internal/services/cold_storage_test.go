@@ -0,0 +1,127 @@
+package services
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/LonleySailor/privatepaste/backend/internal/database"
+	"github.com/LonleySailor/privatepaste/backend/internal/models"
+)
+
+func TestColdStorageJob(t *testing.T) {
+	db, err := database.NewSQLiteDB(":memory:")
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	pasteRepo := models.NewPasteRepository(db.DB)
+	content := strings.Repeat("cold storage keeps this line\n", 100)
+	for _, id := range []string{"cold", "viewed", "fresh"} {
+		if err := pasteRepo.Create(&models.Paste{ID: id, Content: content, Kind: models.KindText, Visibility: models.VisibilityPublic}); err != nil {
+			t.Fatalf("Failed to create paste: %v", err)
+		}
+	}
+	old := time.Now().Add(-100 * 24 * time.Hour).UTC().Format("2006-01-02 15:04:05")
+	for _, stmt := range []string{
+		`UPDATE pastes SET created_at = '` + old + `' WHERE id IN ('cold', 'viewed')`,
+		`INSERT INTO paste_views (paste_id, day, views) VALUES ('viewed', '` + time.Now().UTC().Format("2006-01-02") + `', 1)`,
+	} {
+		if _, err := db.DB.Exec(stmt); err != nil {
+			t.Fatalf("Failed to seed %q: %v", stmt, err)
+		}
+	}
+
+	var bytesBefore int
+	db.DB.QueryRow(`SELECT bytes FROM storage_usage WHERE user_id = 0`).Scan(&bytesBefore)
+
+	job := NewColdStorageJob(pasteRepo, 90*24*time.Hour)
+	if err := job.Run(context.Background()); err != nil {
+		t.Fatalf("Cold storage job failed: %v", err)
+	}
+
+	var hot string
+	var coldSize, stored int
+	db.DB.QueryRow(`SELECT content, COALESCE(cold_size, 0) FROM pastes WHERE id = 'cold'`).Scan(&hot, &coldSize)
+	db.DB.QueryRow(`SELECT length(data) FROM paste_cold WHERE paste_id = 'cold'`).Scan(&stored)
+	if hot != "" || coldSize != len(content) || stored == 0 || stored >= len(content) {
+		t.Fatalf("Expected the unread paste compressed into the cold tier, got %d hot bytes, cold size %d, %d stored", len(hot), coldSize, stored)
+	}
+	var coldPastes, bytesAfter int
+	db.DB.QueryRow(`SELECT COUNT(*) FROM paste_cold`).Scan(&coldPastes)
+	db.DB.QueryRow(`SELECT bytes FROM storage_usage WHERE user_id = 0`).Scan(&bytesAfter)
+	if coldPastes != 1 {
+		t.Errorf("Expected only the unread paste to be archived, got %d", coldPastes)
+	}
+	if bytesAfter != bytesBefore {
+		t.Errorf("Expected storage usage unchanged by archiving, got %d then %d", bytesBefore, bytesAfter)
+	}
+
+	// Reading the paste returns its content and moves it back
+	paste, err := pasteRepo.GetByID("cold")
+	if err != nil || paste == nil {
+		t.Fatalf("Failed to read cold paste: %v", err)
+	}
+	if paste.Content != content {
+		t.Errorf("Expected the cold paste's content, got %d bytes", len(paste.Content))
+	}
+	db.DB.QueryRow(`SELECT content FROM pastes WHERE id = 'cold'`).Scan(&hot)
+	db.DB.QueryRow(`SELECT COUNT(*) FROM paste_cold`).Scan(&coldPastes)
+	if hot != content || coldPastes != 0 {
+		t.Errorf("Expected the paste rehydrated, got %d hot bytes and %d cold pastes", len(hot), coldPastes)
+	}
+	db.DB.QueryRow(`SELECT bytes FROM storage_usage WHERE user_id = 0`).Scan(&bytesAfter)
+	if bytesAfter != bytesBefore {
+		t.Errorf("Expected storage usage unchanged by rehydrating, got %d then %d", bytesBefore, bytesAfter)
+	}
+}
+
+func TestColdStorageJob_Search(t *testing.T) {
+	db, err := database.NewSQLiteDB(":memory:")
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	pasteRepo := models.NewPasteRepository(db.DB)
+	for _, id := range []string{"cold", "expiring"} {
+		if err := pasteRepo.Create(&models.Paste{ID: id, Content: "glacier notes", Kind: models.KindText, Visibility: models.VisibilityPublic}); err != nil {
+			t.Fatalf("Failed to create paste: %v", err)
+		}
+	}
+	old := time.Now().Add(-100 * 24 * time.Hour).UTC().Format("2006-01-02 15:04:05")
+	if _, err := db.DB.Exec(`UPDATE pastes SET created_at = ?`, old); err != nil {
+		t.Fatalf("Failed to age pastes: %v", err)
+	}
+	if err := NewColdStorageJob(pasteRepo, 90*24*time.Hour).Run(context.Background()); err != nil {
+		t.Fatalf("Cold storage job failed: %v", err)
+	}
+
+	matches := func() int {
+		var n int
+		db.DB.QueryRow(`SELECT COUNT(*) FROM pastes_fts WHERE pastes_fts MATCH 'glacier'`).Scan(&n)
+		return n
+	}
+	if _, total, err := pasteRepo.Search(models.PasteSearchFilter{Query: "glacier"}); err != nil || total != 2 {
+		t.Errorf("Expected both cold pastes found by search, got %d (%v)", total, err)
+	}
+
+	// Edited and deleted cold pastes leave no terms behind
+	if err := pasteRepo.Update(&models.Paste{ID: "cold", Content: "edited"}); err != nil {
+		t.Fatalf("Failed to edit cold paste: %v", err)
+	}
+	if _, err := db.DB.Exec(`UPDATE pastes SET expires_at = ? WHERE id = 'expiring'`, old); err != nil {
+		t.Fatalf("Failed to expire paste: %v", err)
+	}
+	if _, err := pasteRepo.DeleteExpired(); err != nil {
+		t.Fatalf("Failed to delete expired pastes: %v", err)
+	}
+	if n := matches(); n != 0 {
+		t.Errorf("Expected no terms left of the old content, got %d matches", n)
+	}
+	if _, total, _ := pasteRepo.Search(models.PasteSearchFilter{Query: "edited"}); total != 1 {
+		t.Errorf("Expected the edited paste found by its new content, got %d", total)
+	}
+}
@@ -0,0 +1,177 @@
+package services
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/LonleySailor/privatepaste/backend/internal/models"
+)
+
+// contentPolicyTTL bounds how long a policy is cached, so changes made on
+// another instance take effect
+const contentPolicyTTL = 30 * time.Second
+
+// Codes of policy violations
+const (
+	ViolationContentTooLarge = "content_too_large"
+	ViolationExpiry          = "expiry_not_allowed"
+	ViolationVisibility      = "visibility_not_allowed"
+	ViolationAttachments     = "attachments_not_allowed"
+)
+
+// PolicyViolation is returned when a paste breaks its creator's role policy
+type PolicyViolation struct {
+	Code    string
+	Message string
+}
+
+func (v *PolicyViolation) Error() string {
+	return v.Message
+}
+
+// PasteAttributes describes a paste being created for policy checks
+type PasteAttributes struct {
+	Size        int
+	Expiry      string // A duration, or empty or "never" for no expiry
+	ExpiresAt   bool   // An absolute expiry time was chosen instead
+	ExpiryKept  bool   // The expiry is carried over from a paste already held to the policy
+	Visibility  string
+	Attachments int // Files uploaded along with the paste
+}
+
+// ContentPolicyService looks up and enforces the content policies of roles
+type ContentPolicyService struct {
+	policies *models.ContentPolicyRepository
+
+	mu     sync.Mutex
+	cached map[string]cachedPolicy
+}
+
+type cachedPolicy struct {
+	policy  *models.ContentPolicy
+	expires time.Time
+}
+
+// NewContentPolicyService creates a service for the policies in the
+// repository
+func NewContentPolicyService(policies *models.ContentPolicyRepository) *ContentPolicyService {
+	return &ContentPolicyService{policies: policies, cached: map[string]cachedPolicy{}}
+}
+
+// Policy returns the policy of a role, or the default policy if none is
+// assigned
+func (s *ContentPolicyService) Policy(role string) (*models.ContentPolicy, error) {
+	s.mu.Lock()
+	entry, ok := s.cached[role]
+	s.mu.Unlock()
+	if ok && time.Now().Before(entry.expires) {
+		return entry.policy, nil
+	}
+
+	policy, err := s.policies.Get(role)
+	if err != nil {
+		return nil, err
+	}
+	if policy == nil {
+		policy = models.DefaultContentPolicy(role)
+	}
+
+	s.mu.Lock()
+	s.cached[role] = cachedPolicy{policy: policy, expires: time.Now().Add(contentPolicyTTL)}
+	s.mu.Unlock()
+	return policy, nil
+}
+
+// Policies returns the policy of every role
+func (s *ContentPolicyService) Policies() ([]*models.ContentPolicy, error) {
+	assigned, err := s.policies.List()
+	if err != nil {
+		return nil, err
+	}
+
+	policies := make([]*models.ContentPolicy, 0, len(models.PolicyRoles))
+	for _, role := range models.PolicyRoles {
+		policy := models.DefaultContentPolicy(role)
+		for _, p := range assigned {
+			if p.Role == role {
+				policy = p
+			}
+		}
+		policies = append(policies, policy)
+	}
+	return policies, nil
+}
+
+// SetPolicy assigns a policy to its role
+func (s *ContentPolicyService) SetPolicy(policy *models.ContentPolicy) error {
+	if err := s.policies.Set(policy); err != nil {
+		return err
+	}
+	s.forget(policy.Role)
+	return nil
+}
+
+// ResetPolicy restores the default policy of a role
+func (s *ContentPolicyService) ResetPolicy(role string) error {
+	if _, err := s.policies.Delete(role); err != nil {
+		return err
+	}
+	s.forget(role)
+	return nil
+}
+
+func (s *ContentPolicyService) forget(role string) {
+	s.mu.Lock()
+	delete(s.cached, role)
+	s.mu.Unlock()
+}
+
+// CheckPaste returns a *PolicyViolation if a paste breaks the policy
+func CheckPaste(policy *models.ContentPolicy, paste PasteAttributes) error {
+	if policy.MaxSize > 0 && paste.Size > policy.MaxSize {
+		return &PolicyViolation{
+			Code:    ViolationContentTooLarge,
+			Message: fmt.Sprintf("Pastes are limited to %d bytes for your account", policy.MaxSize),
+		}
+	}
+
+	if len(policy.AllowedExpiries) > 0 && !paste.ExpiryKept {
+		expiry := paste.Expiry
+		if expiry == "" {
+			expiry = "never"
+		}
+		if paste.ExpiresAt || !slices.Contains(policy.AllowedExpiries, expiry) {
+			return &PolicyViolation{
+				Code:    ViolationExpiry,
+				Message: "Expiry must be one of " + strings.Join(policy.AllowedExpiries, ", ") + " for your account",
+			}
+		}
+	}
+
+	if paste.Visibility == models.VisibilityPublic && !policy.AllowPublic {
+		return &PolicyViolation{
+			Code:    ViolationVisibility,
+			Message: "Your account cannot create public pastes",
+		}
+	}
+
+	if paste.Attachments > 0 {
+		return CheckAttachment(policy)
+	}
+	return nil
+}
+
+// CheckAttachment returns a *PolicyViolation if the policy forbids
+// attaching files to pastes
+func CheckAttachment(policy *models.ContentPolicy) error {
+	if !policy.AllowAttachments {
+		return &PolicyViolation{
+			Code:    ViolationAttachments,
+			Message: "Your account cannot upload attachments",
+		}
+	}
+	return nil
+}
@@ -0,0 +1,150 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/LonleySailor/privatepaste/backend/internal/models"
+	"github.com/LonleySailor/privatepaste/backend/pkg/chatshare"
+)
+
+// digestPeriod is how often a user receives the weekly digest, and the
+// window of activity it covers
+const digestPeriod = 7 * 24 * time.Hour
+
+// digestBatch caps the digests sent in one run; the rest go out next run
+const digestBatch = 500
+
+// NewDigestJob creates the scheduled job that sends weekly activity digests
+// to users who opted in. It runs daily and sends each user a digest once a
+// week, by email or to one of their integrations. pasteURL builds the links
+// in the digest.
+func NewDigestJob(notifications *models.NotificationRepository, integrations *models.IntegrationRepository, queue *JobQueue, pasteURL func(id string) string) ScheduledJob {
+	return ScheduledJob{
+		Name:     "notification-digest",
+		Interval: 24 * time.Hour,
+		Jitter:   time.Hour,
+		Run: func(ctx context.Context) error {
+			return sendDigests(ctx, notifications, integrations, queue, pasteURL)
+		},
+	}
+}
+
+// sendDigests queues the digests of every user due one. A user whose digest
+// cannot be delivered, for want of a verified email or integration, is
+// skipped until next week.
+func sendDigests(ctx context.Context, notifications *models.NotificationRepository, integrations *models.IntegrationRepository, queue *JobQueue, pasteURL func(string) string) error {
+	now := time.Now()
+	recipients, err := notifications.DueDigests(now.Add(-digestPeriod), digestBatch)
+	if err != nil {
+		return err
+	}
+
+	sent := 0
+	for _, recipient := range recipients {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		digest, err := notifications.BuildDigest(recipient.UserID, now.Add(-digestPeriod), now.Add(digestPeriod))
+		if err != nil {
+			return err
+		}
+		text := FormatDigest(recipient.Username, digest, pasteURL)
+
+		switch recipient.Channel {
+		case models.DigestEmail:
+			if recipient.Email == "" {
+				log.Printf("Skipping digest for user %d: no verified email address", recipient.UserID)
+				break
+			}
+			if err := queue.Enqueue(JobTypeEmail, EmailMessage{To: recipient.Email, Subject: "Your weekly PasteVault digest", Body: text}); err != nil {
+				log.Printf("Failed to queue digest email for user %d: %v", recipient.UserID, err)
+				break
+			}
+			sent++
+		case models.DigestWebhook:
+			var integration *models.Integration
+			if recipient.IntegrationID != nil {
+				integration, err = integrations.GetByID(recipient.UserID, *recipient.IntegrationID)
+				if err != nil {
+					return err
+				}
+			}
+			if integration == nil {
+				log.Printf("Skipping digest for user %d: no integration to deliver to", recipient.UserID)
+				break
+			}
+			body, err := chatshare.TextPayload(integration.Platform, text)
+			if err != nil {
+				log.Printf("Failed to format digest for user %d: %v", recipient.UserID, err)
+				break
+			}
+			if err := queue.Enqueue(JobTypeWebhook, WebhookDelivery{
+				URL:           integration.WebhookURL,
+				Body:          body,
+				Secrets:       integration.SigningSecrets(now),
+				IntegrationID: integration.ID,
+				Event:         models.WebhookEventDigest,
+			}); err != nil {
+				log.Printf("Failed to queue digest webhook for user %d: %v", recipient.UserID, err)
+				break
+			}
+			sent++
+		}
+
+		if err := notifications.MarkDigestSent(recipient.UserID, now); err != nil {
+			return err
+		}
+	}
+
+	if len(recipients) > 0 {
+		log.Printf("Digest run completed: %d of %d digests queued", sent, len(recipients))
+	}
+	return nil
+}
+
+// FormatDigest renders a digest as the plain text sent by email and to chat
+func FormatDigest(username string, digest *models.ActivityDigest, pasteURL func(string) string) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Your week on PasteVault, %s\n\n", username)
+
+	fmt.Fprintf(&b, "Views: %d\n", digest.Views)
+	if digest.TopPasteID != "" {
+		fmt.Fprintf(&b, "Most viewed: %s (%d views)\n", pasteURL(digest.TopPasteID), digest.TopViews)
+	}
+	if digest.Storage != nil {
+		fmt.Fprintf(&b, "Storage used: %d pastes, %s\n", digest.Storage.PasteCount, formatBytes(digest.Storage.Bytes))
+	}
+
+	if len(digest.ExpiringSoon) > 0 {
+		b.WriteString("\nExpiring in the next 7 days:\n")
+		for _, paste := range digest.ExpiringSoon {
+			line := "- " + pasteURL(paste.ID)
+			if paste.Language != "" {
+				line += " (" + paste.Language + ")"
+			}
+			fmt.Fprintf(&b, "%s on %s\n", line, paste.ExpiresAt.UTC().Format("Mon 2 Jan 15:04 MST"))
+		}
+	}
+
+	b.WriteString("\nYou can turn this digest off in your notification settings.\n")
+	return b.String()
+}
+
+// formatBytes renders a size with a binary unit
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
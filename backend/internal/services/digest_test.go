@@ -0,0 +1,39 @@
+package services
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/LonleySailor/privatepaste/backend/internal/models"
+)
+
+func TestFormatDigest(t *testing.T) {
+	pasteURL := func(id string) string { return "https://paste.example/" + id }
+	expires := time.Date(2026, 10, 20, 15, 4, 0, 0, time.UTC)
+	digest := &models.ActivityDigest{
+		Views:        12,
+		TopPasteID:   "abc",
+		TopViews:     9,
+		ExpiringSoon: []*models.ExpiringPaste{{ID: "def", Language: "go", ExpiresAt: expires}},
+		Storage:      &models.StorageUsage{PasteCount: 3, Bytes: 1536},
+	}
+
+	text := FormatDigest("alice", digest, pasteURL)
+	for _, want := range []string{
+		"alice",
+		"Views: 12",
+		"https://paste.example/abc (9 views)",
+		"3 pastes, 1.5 KiB",
+		"https://paste.example/def (go) on Tue 20 Oct 15:04 UTC",
+	} {
+		if !strings.Contains(text, want) {
+			t.Errorf("Expected %q in digest:\n%s", want, text)
+		}
+	}
+
+	quiet := FormatDigest("bob", &models.ActivityDigest{Storage: &models.StorageUsage{}}, pasteURL)
+	if strings.Contains(quiet, "Most viewed") || strings.Contains(quiet, "Expiring") {
+		t.Errorf("Expected empty sections to be left out:\n%s", quiet)
+	}
+}
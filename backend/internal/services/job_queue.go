@@ -0,0 +1,193 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/LonleySailor/privatepaste/backend/internal/models"
+)
+
+// Job types handled by the queue
+const (
+	JobTypeWebhook = "webhook"
+	JobTypeEmail   = "email"
+)
+
+// JobHandler performs a job given its JSON payload. Returning an error
+// schedules a retry with backoff until the job's attempts run out.
+type JobHandler func(ctx context.Context, payload []byte) error
+
+// JobQueue runs persistent background jobs on a pool of workers
+type JobQueue struct {
+	jobRepo      *models.JobRepository
+	handlers     map[string]JobHandler
+	workers      int
+	pollInterval time.Duration
+	maxAttempts  int
+	baseBackoff  time.Duration
+	maxBackoff   time.Duration
+	readOnly     models.ReadOnlySwitch // Optional; while enabled, no jobs are claimed
+
+	stopChan chan struct{}
+	wg       sync.WaitGroup
+}
+
+// NewJobQueue creates a job queue with the given number of workers
+func NewJobQueue(jobRepo *models.JobRepository, workers int) *JobQueue {
+	return &JobQueue{
+		jobRepo:      jobRepo,
+		handlers:     make(map[string]JobHandler),
+		workers:      workers,
+		pollInterval: time.Second,
+		maxAttempts:  8,
+		baseBackoff:  30 * time.Second,
+		maxBackoff:   6 * time.Hour,
+		stopChan:     make(chan struct{}),
+	}
+}
+
+// Register sets the handler for a job type. Handlers must be registered
+// before Start.
+func (q *JobQueue) Register(jobType string, handler JobHandler) {
+	q.handlers[jobType] = handler
+}
+
+// SetReadOnlyMode holds jobs in the queue while writes are paused
+func (q *JobQueue) SetReadOnlyMode(mode models.ReadOnlySwitch) {
+	q.readOnly = mode
+}
+
+// Enqueue persists a job to run as soon as a worker is free
+func (q *JobQueue) Enqueue(jobType string, payload interface{}) error {
+	return q.EnqueueAt(jobType, payload, time.Now())
+}
+
+// EnqueueAt persists a job to run no earlier than runAt
+func (q *JobQueue) EnqueueAt(jobType string, payload interface{}, runAt time.Time) error {
+	if _, ok := q.handlers[jobType]; !ok {
+		return fmt.Errorf("no handler registered for job type %q", jobType)
+	}
+
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to encode job payload: %w", err)
+	}
+
+	return q.jobRepo.Enqueue(&models.Job{
+		Type:        jobType,
+		Payload:     string(data),
+		MaxAttempts: q.maxAttempts,
+		RunAt:       runAt,
+	})
+}
+
+// Start recovers jobs interrupted by a previous shutdown and starts the workers
+func (q *JobQueue) Start() {
+	log.Println("Starting job queue...")
+
+	if n, err := q.jobRepo.RequeueRunning(); err != nil {
+		log.Printf("Error requeueing interrupted jobs: %v", err)
+	} else if n > 0 {
+		log.Printf("Requeued %d interrupted jobs", n)
+	}
+
+	for i := 0; i < q.workers; i++ {
+		q.wg.Add(1)
+		go q.work()
+	}
+
+	log.Printf("Job queue started with %d workers", q.workers)
+}
+
+// Stop stops the workers, waiting for running jobs to finish
+func (q *JobQueue) Stop() {
+	close(q.stopChan)
+	q.wg.Wait()
+	log.Println("Job queue stopped")
+}
+
+// work claims and runs jobs until the queue is stopped
+func (q *JobQueue) work() {
+	defer q.wg.Done()
+
+	for {
+		ran := q.runNext()
+
+		// Keep draining while there is work; otherwise wait for the next poll
+		wait := q.pollInterval
+		if ran {
+			wait = 0
+		}
+		select {
+		case <-q.stopChan:
+			return
+		case <-time.After(wait):
+		}
+	}
+}
+
+// runNext claims and runs a single due job, reporting whether one was found
+func (q *JobQueue) runNext() bool {
+	if q.readOnly != nil && q.readOnly.Enabled() {
+		return false
+	}
+	job, err := q.jobRepo.ClaimNext()
+	if err != nil {
+		log.Printf("Error claiming job: %v", err)
+		return false
+	}
+	if job == nil {
+		return false
+	}
+
+	handler, ok := q.handlers[job.Type]
+	if !ok {
+		q.fail(job, fmt.Errorf("no handler registered for job type %q", job.Type))
+		return true
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	if err := handler(ctx, []byte(job.Payload)); err != nil {
+		q.fail(job, err)
+		return true
+	}
+
+	if err := q.jobRepo.Complete(job.ID); err != nil {
+		log.Printf("Error completing job %d: %v", job.ID, err)
+	}
+	return true
+}
+
+// fail reschedules a job with exponential backoff or buries it once its
+// attempts are exhausted
+func (q *JobQueue) fail(job *models.Job, jobErr error) {
+	if job.Attempts >= job.MaxAttempts {
+		log.Printf("Job %d (%s) failed permanently after %d attempts: %v", job.ID, job.Type, job.Attempts, jobErr)
+		if err := q.jobRepo.Bury(job.ID, jobErr.Error()); err != nil {
+			log.Printf("Error burying job %d: %v", job.ID, err)
+		}
+		return
+	}
+
+	if err := q.jobRepo.Reschedule(job.ID, time.Now().Add(q.backoff(job.Attempts)), jobErr.Error()); err != nil {
+		log.Printf("Error rescheduling job %d: %v", job.ID, err)
+	}
+}
+
+// backoff returns the delay before retrying after the given number of attempts
+func (q *JobQueue) backoff(attempts int) time.Duration {
+	delay := q.baseBackoff
+	for i := 1; i < attempts && delay < q.maxBackoff; i++ {
+		delay *= 2
+	}
+	if delay > q.maxBackoff {
+		delay = q.maxBackoff
+	}
+	return delay
+}
@@ -0,0 +1,107 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/LonleySailor/privatepaste/backend/internal/database"
+	"github.com/LonleySailor/privatepaste/backend/internal/models"
+)
+
+func setupJobQueue(t *testing.T) (*JobQueue, *models.JobRepository) {
+	t.Helper()
+
+	db, err := database.NewSQLiteDB(":memory:")
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	jobRepo := models.NewJobRepository(db.DB)
+	return NewJobQueue(jobRepo, 1), jobRepo
+}
+
+func TestJobQueue_RunsJobs(t *testing.T) {
+	queue, _ := setupJobQueue(t)
+
+	var received string
+	queue.Register(JobTypeWebhook, func(ctx context.Context, payload []byte) error {
+		received = string(payload)
+		return nil
+	})
+
+	if err := queue.Enqueue(JobTypeWebhook, map[string]string{"url": "https://example.com/hook"}); err != nil {
+		t.Fatalf("Failed to enqueue job: %v", err)
+	}
+
+	if !queue.runNext() {
+		t.Fatal("Expected a job to run")
+	}
+	if received != `{"url":"https://example.com/hook"}` {
+		t.Errorf("Unexpected payload %q", received)
+	}
+	if queue.runNext() {
+		t.Error("Expected completed job not to run again")
+	}
+}
+
+func TestJobQueue_RetriesThenBuries(t *testing.T) {
+	queue, jobRepo := setupJobQueue(t)
+	queue.maxAttempts = 2
+	queue.baseBackoff = 0
+
+	attempts := 0
+	queue.Register(JobTypeEmail, func(ctx context.Context, payload []byte) error {
+		attempts++
+		return errors.New("smtp unavailable")
+	})
+
+	if err := queue.Enqueue(JobTypeEmail, "hello"); err != nil {
+		t.Fatalf("Failed to enqueue job: %v", err)
+	}
+
+	for queue.runNext() {
+	}
+	if attempts != 2 {
+		t.Fatalf("Expected 2 attempts, got %d", attempts)
+	}
+
+	dead, err := jobRepo.ListDead(10, 0)
+	if err != nil || len(dead) != 1 {
+		t.Fatalf("Expected one dead job, got %d (%v)", len(dead), err)
+	}
+	if dead[0].LastError != "smtp unavailable" {
+		t.Errorf("Expected last error to be recorded, got %q", dead[0].LastError)
+	}
+
+	// Retrying requeues the job with fresh attempts
+	if ok, err := jobRepo.Retry(dead[0].ID); !ok || err != nil {
+		t.Fatalf("Expected retry to succeed, got %v %v", ok, err)
+	}
+	if !queue.runNext() || attempts != 3 {
+		t.Errorf("Expected retried job to run, got %d attempts", attempts)
+	}
+}
+
+func TestJobQueue_Backoff(t *testing.T) {
+	queue := NewJobQueue(nil, 1)
+
+	if d := queue.backoff(1); d != 30*time.Second {
+		t.Errorf("Expected first backoff of 30s, got %v", d)
+	}
+	if d := queue.backoff(3); d != 2*time.Minute {
+		t.Errorf("Expected third backoff of 2m, got %v", d)
+	}
+	if d := queue.backoff(100); d != 6*time.Hour {
+		t.Errorf("Expected backoff to be capped at 6h, got %v", d)
+	}
+}
+
+func TestJobQueue_UnknownType(t *testing.T) {
+	queue := NewJobQueue(nil, 1)
+	if err := queue.Enqueue("unknown", nil); err == nil {
+		t.Error("Expected enqueueing an unregistered job type to fail")
+	}
+}
@@ -0,0 +1,48 @@
+package services
+
+import "sync"
+
+// Lock names shared by jobs that must not run concurrently
+const (
+	// LockMaintenance serializes jobs that rewrite or snapshot the database,
+	// such as cleanup and backups
+	LockMaintenance = "maintenance"
+)
+
+// NamedLocks is an in-process advisory lock service keyed by name
+type NamedLocks struct {
+	mu   sync.Mutex
+	held map[string]string // Lock name to holder
+}
+
+// NewNamedLocks creates an empty lock service
+func NewNamedLocks() *NamedLocks {
+	return &NamedLocks{held: make(map[string]string)}
+}
+
+// TryLock acquires the named lock for holder without waiting. It returns false
+// if the lock is already held.
+func (l *NamedLocks) TryLock(name, holder string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if _, held := l.held[name]; held {
+		return false
+	}
+	l.held[name] = holder
+	return true
+}
+
+// Unlock releases the named lock
+func (l *NamedLocks) Unlock(name string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	delete(l.held, name)
+}
+
+// Holder returns who holds the named lock, or "" if it is free
+func (l *NamedLocks) Holder(name string) string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.held[name]
+}
@@ -0,0 +1,79 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// EmailMessage is the payload of an email job
+type EmailMessage struct {
+	To      string `json:"to"`
+	Subject string `json:"subject"`
+	Body    string `json:"body"` // Plain text
+}
+
+// Mailer sends plain text email through an SMTP server. STARTTLS is used
+// whenever the server offers it.
+type Mailer struct {
+	addr string
+	auth smtp.Auth
+	from string
+}
+
+// NewMailer creates a mailer for the SMTP server at host:port. Without a
+// username the server is used unauthenticated.
+func NewMailer(host string, port int, username, password, from string) *Mailer {
+	m := &Mailer{
+		addr: net.JoinHostPort(host, strconv.Itoa(port)),
+		from: from,
+	}
+	if username != "" {
+		m.auth = smtp.PlainAuth("", username, password, host)
+	}
+	return m
+}
+
+// Send delivers a message
+func (m *Mailer) Send(msg EmailMessage) error {
+	to, err := mail.ParseAddress(msg.To)
+	if err != nil {
+		return fmt.Errorf("invalid recipient: %w", err)
+	}
+	return smtp.SendMail(m.addr, m.auth, m.from, []string{to.Address}, m.compose(to, msg))
+}
+
+// compose builds the RFC 5322 message. Header values are stripped of line
+// breaks so message fields cannot inject headers.
+func (m *Mailer) compose(to *mail.Address, msg EmailMessage) []byte {
+	clean := strings.NewReplacer("\r", "", "\n", " ")
+
+	var b strings.Builder
+	b.WriteString("From: " + clean.Replace(m.from) + "\r\n")
+	b.WriteString("To: " + to.String() + "\r\n")
+	b.WriteString("Subject: " + mime.QEncoding.Encode("utf-8", clean.Replace(msg.Subject)) + "\r\n")
+	b.WriteString("Date: " + time.Now().Format(time.RFC1123Z) + "\r\n")
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	b.WriteString("Content-Transfer-Encoding: 8bit\r\n\r\n")
+	b.WriteString(strings.ReplaceAll(strings.ReplaceAll(msg.Body, "\r\n", "\n"), "\n", "\r\n"))
+	return []byte(b.String())
+}
+
+// NewEmailSender returns the job handler that sends queued email
+func NewEmailSender(m *Mailer) JobHandler {
+	return func(ctx context.Context, payload []byte) error {
+		var msg EmailMessage
+		if err := json.Unmarshal(payload, &msg); err != nil {
+			return fmt.Errorf("invalid email payload: %w", err)
+		}
+		return m.Send(msg)
+	}
+}
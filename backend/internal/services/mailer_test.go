@@ -0,0 +1,28 @@
+package services
+
+import (
+	"net/mail"
+	"strings"
+	"testing"
+)
+
+func TestMailerCompose(t *testing.T) {
+	m := NewMailer("smtp.example.com", 587, "", "", "pastevault@example.com")
+	to, _ := mail.ParseAddress("friend@example.com")
+
+	msg := string(m.compose(to, EmailMessage{
+		Subject: "héllo\r\nBcc: victim@example.com",
+		Body:    "line one\nline two",
+	}))
+
+	headers, body, _ := strings.Cut(msg, "\r\n\r\n")
+	if strings.Contains(headers, "\r\nBcc:") {
+		t.Errorf("Expected line breaks in the subject to be removed:\n%s", headers)
+	}
+	if !strings.Contains(headers, "Subject: =?utf-8?q?") {
+		t.Errorf("Expected a non-ASCII subject to be encoded:\n%s", headers)
+	}
+	if body != "line one\r\nline two" {
+		t.Errorf("Expected CRLF line endings in the body, got %q", body)
+	}
+}
@@ -0,0 +1,136 @@
+package services
+
+import (
+	"container/list"
+	"sync"
+	"time"
+
+	"github.com/LonleySailor/privatepaste/backend/internal/metrics"
+	"github.com/LonleySailor/privatepaste/backend/internal/models"
+)
+
+var pasteCacheLookups = metrics.NewCounter("pastevault_paste_cache_total",
+	"Paste cache lookups, by what was looked up (paste or rendered) and result (hit or miss).", "lookup", "result")
+
+// pasteCacheEntry is a cached paste and the views rendered from it
+type pasteCacheEntry struct {
+	paste    *models.Paste
+	rendered map[string]string
+	expires  time.Time
+}
+
+// PasteCache is an in-memory LRU cache of pastes, with entries expiring
+// after a fixed time so changes made around the repository show. Rendered
+// views of a paste are cached with it and dropped when it changes.
+type PasteCache struct {
+	mu       sync.Mutex
+	capacity int
+	ttl      time.Duration
+	order    *list.List // Of paste IDs, most recently used first
+	entries  map[string]*list.Element
+	now      func() time.Time
+}
+
+// NewPasteCache creates a cache holding up to capacity pastes for ttl each
+func NewPasteCache(capacity int, ttl time.Duration) *PasteCache {
+	return &PasteCache{
+		capacity: capacity,
+		ttl:      ttl,
+		order:    list.New(),
+		entries:  map[string]*list.Element{},
+		now:      time.Now,
+	}
+}
+
+// lookup returns the unexpired entry of a paste, marking it recently used.
+// The caller holds the lock.
+func (c *PasteCache) lookup(id string) *pasteCacheEntry {
+	elem, ok := c.entries[id]
+	if !ok {
+		return nil
+	}
+	entry := elem.Value.(*pasteCacheEntry)
+	if c.now().After(entry.expires) {
+		c.order.Remove(elem)
+		delete(c.entries, id)
+		return nil
+	}
+	c.order.MoveToFront(elem)
+	return entry
+}
+
+// Get returns a copy of a cached paste
+func (c *PasteCache) Get(id string) (*models.Paste, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry := c.lookup(id)
+	if entry == nil {
+		pasteCacheLookups.Inc("paste", "miss")
+		return nil, false
+	}
+	pasteCacheLookups.Inc("paste", "hit")
+	paste := *entry.paste
+	return &paste, true
+}
+
+// Add caches a copy of a paste, replacing any cached views of it and
+// evicting the least recently used paste when the cache is full
+func (c *PasteCache) Add(paste *models.Paste) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	stored := *paste
+	entry := &pasteCacheEntry{paste: &stored, expires: c.now().Add(c.ttl)}
+	if elem, ok := c.entries[paste.ID]; ok {
+		elem.Value = entry
+		c.order.MoveToFront(elem)
+		return
+	}
+	c.entries[paste.ID] = c.order.PushFront(entry)
+	for c.order.Len() > c.capacity {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*pasteCacheEntry).paste.ID)
+	}
+}
+
+// Remove drops a paste and its rendered views from the cache
+func (c *PasteCache) Remove(id string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if elem, ok := c.entries[id]; ok {
+		c.order.Remove(elem)
+		delete(c.entries, id)
+	}
+}
+
+// Rendered returns a cached view of a paste, such as its highlighted HTML
+func (c *PasteCache) Rendered(pasteID, view string) (string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if entry := c.lookup(pasteID); entry != nil {
+		if body, ok := entry.rendered[view]; ok {
+			pasteCacheLookups.Inc("rendered", "hit")
+			return body, true
+		}
+	}
+	pasteCacheLookups.Inc("rendered", "miss")
+	return "", false
+}
+
+// SetRendered caches a view of a paste. Views are only kept while the paste
+// itself is cached, so they never outlive a change to it.
+func (c *PasteCache) SetRendered(pasteID, view, body string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if entry := c.lookup(pasteID); entry != nil {
+		if entry.rendered == nil {
+			entry.rendered = map[string]string{}
+		}
+		entry.rendered[view] = body
+	}
+}
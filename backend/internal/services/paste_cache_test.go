@@ -0,0 +1,57 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"github.com/LonleySailor/privatepaste/backend/internal/models"
+)
+
+func TestPasteCache(t *testing.T) {
+	cache := NewPasteCache(2, time.Minute)
+	now := time.Now()
+	cache.now = func() time.Time { return now }
+
+	cache.Add(&models.Paste{ID: "a", Content: "first"})
+	cache.Add(&models.Paste{ID: "b", Content: "second"})
+
+	// Pastes are copied, so callers cannot change the cached one
+	paste, ok := cache.Get("a")
+	if !ok || paste.Content != "first" {
+		t.Fatalf("Expected paste a to be cached, got %v", paste)
+	}
+	paste.Content = "changed"
+	if paste, _ := cache.Get("a"); paste.Content != "first" {
+		t.Errorf("Expected the cached copy unchanged, got %q", paste.Content)
+	}
+
+	// a was used last, so adding a third evicts b
+	cache.Add(&models.Paste{ID: "c", Content: "third"})
+	if _, ok := cache.Get("b"); ok {
+		t.Error("Expected the least recently used paste to be evicted")
+	}
+
+	cache.SetRendered("a", "highlight", "<pre>first</pre>")
+	cache.SetRendered("b", "highlight", "<pre>second</pre>")
+	if body, ok := cache.Rendered("a", "highlight"); !ok || body != "<pre>first</pre>" {
+		t.Errorf("Expected the rendered view of a, got %q", body)
+	}
+	if _, ok := cache.Rendered("b", "highlight"); ok {
+		t.Error("Expected no view to be kept for an uncached paste")
+	}
+
+	// Changing a paste drops its views
+	cache.Add(&models.Paste{ID: "a", Content: "edited"})
+	if _, ok := cache.Rendered("a", "highlight"); ok {
+		t.Error("Expected views to be dropped when the paste is replaced")
+	}
+	cache.Remove("a")
+	if _, ok := cache.Get("a"); ok {
+		t.Error("Expected a removed paste to be gone")
+	}
+
+	now = now.Add(2 * time.Minute)
+	if _, ok := cache.Get("c"); ok {
+		t.Error("Expected an expired paste to be gone")
+	}
+}
@@ -0,0 +1,177 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/LonleySailor/privatepaste/backend/internal/models"
+)
+
+// JobTypePasteHooks is the job type running post-create hooks on a new paste
+const JobTypePasteHooks = "paste_hooks"
+
+// PasteHook is run in the background after a paste is created. A hook that
+// returns an error is retried with the job, together with any hooks that
+// already ran, so hooks must be safe to run more than once.
+type PasteHook interface {
+	Name() string
+	AfterCreate(ctx context.Context, paste *models.Paste) error
+}
+
+// PasteHookRun is the payload of a hook job: the paste that was created
+type PasteHookRun struct {
+	PasteID string `json:"paste_id"`
+}
+
+// PasteLoader looks up the paste a hook job runs for
+type PasteLoader interface {
+	GetByID(id string) (*models.Paste, error)
+}
+
+// NewPasteHookRunner returns the job handler running hooks on a new paste.
+// Pastes deleted or expired before the job runs are skipped.
+func NewPasteHookRunner(pastes PasteLoader, hooks ...PasteHook) JobHandler {
+	return func(ctx context.Context, payload []byte) error {
+		var run PasteHookRun
+		if err := json.Unmarshal(payload, &run); err != nil {
+			return fmt.Errorf("invalid paste hook payload: %w", err)
+		}
+
+		paste, err := pastes.GetByID(run.PasteID)
+		if err != nil {
+			return err
+		}
+		if paste == nil || paste.IsExpired() {
+			return nil
+		}
+
+		var errs []error
+		for _, hook := range hooks {
+			if err := hook.AfterCreate(ctx, paste); err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", hook.Name(), err))
+			}
+		}
+		return errors.Join(errs...)
+	}
+}
+
+// summaryTimeout bounds a single request to the summarization service
+const summaryTimeout = 30 * time.Second
+
+// summaryMaxContent is how much of a paste is sent to be summarized
+const summaryMaxContent = 32 * 1024
+
+// Limits on the generated metadata kept, in characters
+const (
+	maxSummaryTitle = 100
+	maxSummaryText  = 1000
+)
+
+// SummaryStore stores the generated title and summary of pastes
+type SummaryStore interface {
+	Get(pasteID string) (map[string]string, error)
+	Set(pasteID, key, value string) error
+}
+
+// SummaryHook asks an external service for a title and summary of each new
+// paste and stores them as its metadata. The service receives a JSON POST
+// of {"id", "language", "content"} and answers {"title", "summary"}; either
+// may be empty. Password-protected pastes are never sent, and pastes that
+// already have a title are left alone.
+type SummaryHook struct {
+	url      string
+	token    string // Sent as the bearer token, if set
+	client   *http.Client
+	metadata SummaryStore
+}
+
+// NewSummaryHook creates a hook calling the summarization service at url.
+// A nil client uses a default with a timeout.
+func NewSummaryHook(url, token string, client *http.Client, metadata SummaryStore) *SummaryHook {
+	if client == nil {
+		client = &http.Client{Timeout: summaryTimeout}
+	}
+	return &SummaryHook{url: url, token: token, client: client, metadata: metadata}
+}
+
+// Name identifies the hook in errors
+func (h *SummaryHook) Name() string {
+	return "summary"
+}
+
+// AfterCreate generates and stores the paste's title and summary
+func (h *SummaryHook) AfterCreate(ctx context.Context, paste *models.Paste) error {
+	if paste.HasPassword() || strings.TrimSpace(paste.Content) == "" {
+		return nil
+	}
+	existing, err := h.metadata.Get(paste.ID)
+	if err != nil {
+		return err
+	}
+	if existing[models.MetadataTitle] != "" {
+		return nil
+	}
+
+	content := paste.Content
+	if len(content) > summaryMaxContent {
+		content = strings.ToValidUTF8(content[:summaryMaxContent], "")
+	}
+	body, err := json.Marshal(map[string]string{"id": paste.ID, "language": paste.Language, "content": content})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, h.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if h.token != "" {
+		req.Header.Set("Authorization", "Bearer "+h.token)
+	}
+
+	resp, err := h.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("summarization service responded %d", resp.StatusCode)
+	}
+
+	var generated struct {
+		Title   string `json:"title"`
+		Summary string `json:"summary"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 64*1024)).Decode(&generated); err != nil {
+		return fmt.Errorf("invalid summarization response: %w", err)
+	}
+
+	// The summary is stored first so a stored title always means done
+	if summary := truncateRunes(strings.TrimSpace(generated.Summary), maxSummaryText); summary != "" {
+		if err := h.metadata.Set(paste.ID, models.MetadataSummary, summary); err != nil {
+			return err
+		}
+	}
+	if title := truncateRunes(strings.Join(strings.Fields(generated.Title), " "), maxSummaryTitle); title != "" {
+		if err := h.metadata.Set(paste.ID, models.MetadataTitle, title); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// truncateRunes shortens s to at most n characters
+func truncateRunes(s string, n int) string {
+	if runes := []rune(s); len(runes) > n {
+		return string(runes[:n])
+	}
+	return s
+}
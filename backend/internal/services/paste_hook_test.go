@@ -0,0 +1,79 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/LonleySailor/privatepaste/backend/internal/models"
+)
+
+type memoryPastes map[string]*models.Paste
+
+func (m memoryPastes) GetByID(id string) (*models.Paste, error) {
+	return m[id], nil
+}
+
+type memoryMetadata map[string]map[string]string
+
+func (m memoryMetadata) Get(pasteID string) (map[string]string, error) {
+	return m[pasteID], nil
+}
+
+func (m memoryMetadata) Set(pasteID, key, value string) error {
+	if m[pasteID] == nil {
+		m[pasteID] = map[string]string{}
+	}
+	m[pasteID][key] = value
+	return nil
+}
+
+func TestSummaryHook(t *testing.T) {
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		var req map[string]string
+		json.NewDecoder(r.Body).Decode(&req)
+		w.Write([]byte(`{"title":"  Nginx\nconfig  ","summary":"Reverse proxy for ` + req["language"] + `."}`))
+	}))
+	defer server.Close()
+
+	hash := "hash"
+	pastes := memoryPastes{
+		"plain":    {ID: "plain", Content: "server { listen 80; }", Language: "nginx"},
+		"password": {ID: "password", Content: "secret", PasswordHash: &hash},
+	}
+	metadata := memoryMetadata{}
+	run := NewPasteHookRunner(pastes, NewSummaryHook(server.URL, "secret", server.Client(), metadata))
+
+	for _, id := range []string{"plain", "password", "deleted"} {
+		payload, _ := json.Marshal(PasteHookRun{PasteID: id})
+		if err := run(context.Background(), payload); err != nil {
+			t.Fatalf("Hooks on %s failed: %v", id, err)
+		}
+	}
+	if got := metadata["plain"]; got["title"] != "Nginx config" || got["summary"] != "Reverse proxy for nginx." {
+		t.Errorf("Unexpected metadata %+v", got)
+	}
+	if calls != 1 || metadata["password"] != nil {
+		t.Errorf("Expected only the plain paste to be summarized, got %d calls", calls)
+	}
+
+	// Titled pastes are not summarized again, so retries are harmless
+	payload, _ := json.Marshal(PasteHookRun{PasteID: "plain"})
+	run(context.Background(), payload)
+	if calls != 1 {
+		t.Errorf("Expected a titled paste to be skipped, got %d calls", calls)
+	}
+
+	failing := NewPasteHookRunner(pastes, NewSummaryHook(server.URL, "wrong", server.Client(), memoryMetadata{}))
+	if err := failing(context.Background(), payload); err == nil {
+		t.Error("Expected a rejected request to fail the job")
+	}
+}
@@ -0,0 +1,79 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/LonleySailor/privatepaste/backend/internal/database"
+	"github.com/LonleySailor/privatepaste/backend/internal/models"
+)
+
+func TestAnonymizationJob(t *testing.T) {
+	db, err := database.NewSQLiteDB(":memory:")
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	old := time.Now().Add(-100 * 24 * time.Hour).UTC().Format("2006-01-02 15:04:05")
+	for _, stmt := range []string{
+		`INSERT INTO pastes (id, content, creator_ip_hash, created_at) VALUES ('old', 'a', 'hash-old', '` + old + `')`,
+		`INSERT INTO pastes (id, content, creator_ip_hash) VALUES ('new', 'b', 'hash-new')`,
+		`INSERT INTO paste_views (paste_id, day, views) VALUES ('old', '2020-01-01', 3)`,
+		`UPDATE paste_view_log SET viewed_at = '` + old + `'`,
+		`INSERT INTO paste_views (paste_id, day, views) VALUES ('new', '2020-01-02', 1)`,
+		`INSERT INTO audit_log (action, details, created_at) VALUES ('legal_export', 'case 1', '` + old + `')`,
+		`INSERT INTO audit_log (action, details) VALUES ('legal_export', 'case 2')`,
+	} {
+		if _, err := db.DB.Exec(stmt); err != nil {
+			t.Fatalf("Failed to seed %q: %v", stmt, err)
+		}
+	}
+
+	retentionRepo := models.NewRetentionRepository(db.DB)
+	job := NewAnonymizationJob(retentionRepo, 90*24*time.Hour)
+	if err := job.Run(context.Background()); err != nil {
+		t.Fatalf("Anonymization failed: %v", err)
+	}
+
+	var oldHash, newHash *string
+	db.DB.QueryRow(`SELECT creator_ip_hash FROM pastes WHERE id = 'old'`).Scan(&oldHash)
+	db.DB.QueryRow(`SELECT creator_ip_hash FROM pastes WHERE id = 'new'`).Scan(&newHash)
+	if oldHash != nil || newHash == nil || *newHash != "hash-new" {
+		t.Errorf("Expected only the old IP hash to be cleared, got %v and %v", oldHash, newHash)
+	}
+
+	var logged, views int
+	db.DB.QueryRow(`SELECT COUNT(*) FROM paste_view_log`).Scan(&logged)
+	db.DB.QueryRow(`SELECT SUM(views) FROM paste_views`).Scan(&views)
+	if logged != 1 || views != 4 {
+		t.Errorf("Expected the old logged view deleted and counts kept, got %d logged and %d views", logged, views)
+	}
+
+	var events int
+	var details []string
+	db.DB.QueryRow(`SELECT COUNT(*) FROM audit_log`).Scan(&events)
+	rows, err := db.DB.Query(`SELECT details FROM audit_log ORDER BY id`)
+	if err != nil {
+		t.Fatalf("Failed to read audit log: %v", err)
+	}
+	for rows.Next() {
+		var d string
+		rows.Scan(&d)
+		details = append(details, d)
+	}
+	rows.Close()
+	if events != 2 || len(details) != 2 || details[0] != "" || details[1] != "case 2" {
+		t.Errorf("Expected old audit details scrubbed and events kept, got %d events %q", events, details)
+	}
+
+	// A second pass finds nothing left to scrub
+	report, err := retentionRepo.Anonymize(time.Now().Add(-90 * 24 * time.Hour))
+	if err != nil {
+		t.Fatalf("Anonymization failed: %v", err)
+	}
+	if report.Total() != 0 {
+		t.Errorf("Expected nothing left to scrub, got %+v", report)
+	}
+}
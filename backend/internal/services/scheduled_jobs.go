@@ -0,0 +1,219 @@
+package services
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/LonleySailor/privatepaste/backend/internal/database"
+	"github.com/LonleySailor/privatepaste/backend/internal/models"
+)
+
+// NewCleanupJob creates the scheduled job that removes expired pastes. When
+// purgeQueue is not nil, their cached copies are purged from the CDN.
+func NewCleanupJob(pasteRepo *models.PasteRepository, purgeQueue *JobQueue) ScheduledJob {
+	return ScheduledJob{
+		Name:     "cleanup",
+		Interval: time.Hour, // Run cleanup every hour
+		Jitter:   5 * time.Minute,
+		Lock:     LockMaintenance,
+		Run: func(ctx context.Context) error {
+			return cleanupExpiredPastes(pasteRepo, purgeQueue)
+		},
+	}
+}
+
+// cleanupExpiredPastes removes all expired pastes from the database
+func cleanupExpiredPastes(pasteRepo *models.PasteRepository, purgeQueue *JobQueue) error {
+	log.Println("Running expired paste cleanup...")
+
+	deleted, err := pasteRepo.DeleteExpiredPastes()
+	if err != nil {
+		return err
+	}
+	deletedCount := len(deleted)
+
+	if purgeQueue != nil && deletedCount > 0 {
+		var keys []string
+		for _, paste := range deleted {
+			keys = append(keys, paste.SurrogateKeys()...)
+		}
+		// Purge in batches; Fastly and Cloudflare cap keys per request
+		for start := 0; start < len(keys); start += cdnPurgeBatch {
+			end := min(start+cdnPurgeBatch, len(keys))
+			if err := purgeQueue.Enqueue(JobTypeCDNPurge, CDNPurge{Keys: keys[start:end]}); err != nil {
+				log.Printf("Failed to queue CDN purge of expired pastes: %v", err)
+			}
+		}
+	}
+
+	if deletedCount > 0 {
+		log.Printf("Cleanup completed: %d expired pastes deleted", deletedCount)
+	} else {
+		log.Println("Cleanup completed: no expired pastes found")
+	}
+	return nil
+}
+
+// NewJobPruneJob creates the scheduled job that deletes completed background
+// jobs older than retention
+func NewJobPruneJob(jobRepo *models.JobRepository, retention time.Duration) ScheduledJob {
+	return ScheduledJob{
+		Name:     "job-prune",
+		Interval: 24 * time.Hour,
+		Jitter:   time.Hour,
+		Run: func(ctx context.Context) error {
+			deleted, err := jobRepo.DeleteFinished(time.Now().Add(-retention))
+			if err != nil {
+				return err
+			}
+			if deleted > 0 {
+				log.Printf("Pruned %d completed jobs", deleted)
+			}
+			return nil
+		},
+	}
+}
+
+// NewViewLogPruneJob creates the scheduled job that deletes logged paste
+// views older than retention; polling triggers only need recent ones
+func NewViewLogPruneJob(triggerRepo *models.TriggerRepository, retention time.Duration) ScheduledJob {
+	return ScheduledJob{
+		Name:     "view-log-prune",
+		Interval: 24 * time.Hour,
+		Jitter:   time.Hour,
+		Run: func(ctx context.Context) error {
+			deleted, err := triggerRepo.PruneViews(time.Now().Add(-retention))
+			if err != nil {
+				return err
+			}
+			if deleted > 0 {
+				log.Printf("Pruned %d logged paste views", deleted)
+			}
+			return nil
+		},
+	}
+}
+
+// NewAnonymizationJob creates the scheduled job enforcing the data
+// retention policy: personal data older than retention is scrubbed as
+// described on models.RetentionRepository, while aggregate counts are kept
+func NewAnonymizationJob(retentionRepo *models.RetentionRepository, retention time.Duration) ScheduledJob {
+	return ScheduledJob{
+		Name:     "anonymize",
+		Interval: 24 * time.Hour,
+		Jitter:   time.Hour,
+		Run: func(ctx context.Context) error {
+			report, err := retentionRepo.Anonymize(time.Now().Add(-retention))
+			if err != nil {
+				return err
+			}
+			if report.Total() > 0 {
+				log.Printf("Anonymized expired data: %d IP hashes, %d logged views, %d webhook deliveries, %d audit details",
+					report.IPHashes, report.ViewLogEntries, report.WebhookDeliveries, report.AuditDetails)
+			}
+			return nil
+		},
+	}
+}
+
+// blobDeletionBatch is how many removed attachment contents the blob
+// deletion job handles per query
+const blobDeletionBatch = 500
+
+// NewBlobDeletionJob creates the scheduled job that removes attachment
+// contents left unused by deleted and expired pastes from the blob store,
+// such as a shadow bucket the database triggers cannot reach
+func NewBlobDeletionJob(attachmentRepo *models.AttachmentRepository) ScheduledJob {
+	return ScheduledJob{
+		Name:     "blob-deletions",
+		Interval: 15 * time.Minute,
+		Jitter:   time.Minute,
+		Run: func(ctx context.Context) error {
+			total := 0
+			for ctx.Err() == nil {
+				n, err := attachmentRepo.PurgeDeletedBlobs(blobDeletionBatch)
+				if err != nil {
+					return err
+				}
+				total += n
+				if n < blobDeletionBatch {
+					break
+				}
+			}
+			if total > 0 {
+				log.Printf("Removed %d unused attachment contents", total)
+			}
+			return ctx.Err()
+		},
+	}
+}
+
+// coldStorageBatch is how many pastes the cold storage job moves per
+// transaction
+const coldStorageBatch = 500
+
+// NewColdStorageJob creates the scheduled job that moves pastes unread for
+// longer than unread to the compressed cold tier. The space they free in
+// the database is reclaimed by the vacuum job.
+func NewColdStorageJob(pasteRepo *models.PasteRepository, unread time.Duration) ScheduledJob {
+	return ScheduledJob{
+		Name:     "cold-storage",
+		Interval: 24 * time.Hour,
+		Jitter:   time.Hour,
+		Lock:     LockMaintenance,
+		Run: func(ctx context.Context) error {
+			total := 0
+			for ctx.Err() == nil {
+				archived, err := pasteRepo.ArchiveCold(time.Now().Add(-unread), coldStorageBatch)
+				if err != nil {
+					return err
+				}
+				total += archived
+				if archived < coldStorageBatch {
+					break
+				}
+			}
+			if total > 0 {
+				log.Printf("Moved %d unread pastes to cold storage", total)
+			}
+			return ctx.Err()
+		},
+	}
+}
+
+// NewVacuumJob creates the scheduled job that reclaims space left behind by
+// expired and deleted pastes once it exceeds a tenth of the database file
+func NewVacuumJob(db *database.Database) ScheduledJob {
+	return ScheduledJob{
+		Name:     "vacuum",
+		Interval: 6 * time.Hour,
+		Jitter:   30 * time.Minute,
+		Lock:     LockMaintenance,
+		Run: func(ctx context.Context) error {
+			report, err := db.Vacuum(0.1)
+			if err != nil {
+				return err
+			}
+			if report.Vacuumed {
+				log.Printf("Vacuum completed: reclaimed %d bytes (%d -> %d)",
+					report.Reclaimed(), report.SizeBefore, report.SizeAfter)
+			}
+			return nil
+		},
+	}
+}
+
+// NewStatsRollupJob creates the scheduled job that folds the paste event log
+// into daily aggregates for the stats endpoints
+func NewStatsRollupJob(eventRepo *models.PasteEventRepository) ScheduledJob {
+	return ScheduledJob{
+		Name:     "stats-rollup",
+		Interval: time.Hour,
+		Jitter:   5 * time.Minute,
+		Run: func(ctx context.Context) error {
+			_, err := eventRepo.Rollup()
+			return err
+		},
+	}
+}
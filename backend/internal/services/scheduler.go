@@ -0,0 +1,275 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"log"
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/LonleySailor/privatepaste/backend/internal/metrics"
+	"github.com/LonleySailor/privatepaste/backend/internal/models"
+)
+
+// Scheduler metrics, labelled by job name
+var (
+	scheduledRuns        = metrics.NewCounter("pastevault_scheduler_runs_total", "Scheduled job runs, by job and result.", "job", "result")
+	scheduledLastSuccess = metrics.NewGauge("pastevault_scheduler_last_success_timestamp_seconds", "Unix time of the last successful run of each scheduled job.", "job")
+)
+
+// Errors returned when triggering scheduled jobs manually
+var (
+	ErrJobNotFound = errors.New("scheduled job not found")
+	ErrJobRunning  = errors.New("scheduled job is already running")
+	ErrJobLocked   = errors.New("scheduled job is blocked by another job holding its lock")
+	ErrReadOnly    = errors.New("scheduled jobs do not run while writes are paused")
+)
+
+// ScheduledJob is a task run periodically by the Scheduler
+type ScheduledJob struct {
+	Name     string
+	Interval time.Duration
+	Jitter   time.Duration // Random extra delay added to each interval
+	Lock     string        // Optional lock shared with jobs that must not run concurrently
+	Run      func(ctx context.Context) error
+}
+
+// JobStatus reports the state of a scheduled job
+type JobStatus struct {
+	Name         string     `json:"name"`
+	Interval     string     `json:"interval"`
+	Running      bool       `json:"running"`
+	Paused       bool       `json:"paused"`
+	Runs         int        `json:"runs"`
+	Skipped      int        `json:"skipped"` // Ticks skipped because the job was running, locked or paused
+	LastRun      *time.Time `json:"last_run,omitempty"`
+	LastDuration string     `json:"last_duration,omitempty"`
+	LastError    string     `json:"last_error,omitempty"`
+	NextRun      *time.Time `json:"next_run,omitempty"`
+}
+
+// scheduledEntry tracks a registered job and its run history
+type scheduledEntry struct {
+	job    ScheduledJob
+	status JobStatus
+}
+
+// Scheduler runs registered jobs on their own intervals. A job never overlaps
+// with itself or with jobs sharing its lock: ticks that arrive while it is
+// running, locked out or paused are skipped, as are all ticks while the
+// instance's writes are paused.
+type Scheduler struct {
+	mu       sync.Mutex
+	entries  map[string]*scheduledEntry
+	locks    *NamedLocks
+	readOnly models.ReadOnlySwitch // Optional
+
+	ctx      context.Context
+	cancel   context.CancelFunc
+	wg       sync.WaitGroup
+	randomFn func(n int64) int64
+}
+
+// NewScheduler creates an empty scheduler
+func NewScheduler() *Scheduler {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Scheduler{
+		entries:  make(map[string]*scheduledEntry),
+		locks:    NewNamedLocks(),
+		ctx:      ctx,
+		cancel:   cancel,
+		randomFn: rand.Int63n,
+	}
+}
+
+// Register adds a job to the scheduler. Jobs must be registered before Start.
+func (s *Scheduler) Register(job ScheduledJob) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.entries[job.Name] = &scheduledEntry{
+		job: job,
+		status: JobStatus{
+			Name:     job.Name,
+			Interval: job.Interval.String(),
+		},
+	}
+}
+
+// SetReadOnlyMode stops jobs from running while writes are paused
+func (s *Scheduler) SetReadOnlyMode(mode models.ReadOnlySwitch) {
+	s.readOnly = mode
+}
+
+// writesPaused reports whether jobs are held back because writes are paused
+func (s *Scheduler) writesPaused() bool {
+	return s.readOnly != nil && s.readOnly.Enabled()
+}
+
+// Locks returns the lock service shared by scheduled jobs, so other
+// components can coordinate with them
+func (s *Scheduler) Locks() *NamedLocks {
+	return s.locks
+}
+
+// Start starts a background loop for every registered job
+func (s *Scheduler) Start() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, entry := range s.entries {
+		s.wg.Add(1)
+		go s.loop(entry)
+	}
+
+	log.Printf("Scheduler started with %d jobs", len(s.entries))
+}
+
+// Stop stops scheduling jobs and waits for running ones to finish
+func (s *Scheduler) Stop() {
+	s.cancel()
+	s.wg.Wait()
+	log.Println("Scheduler stopped")
+}
+
+// RunNow runs the named job immediately in the background
+func (s *Scheduler) RunNow(name string) error {
+	s.mu.Lock()
+	entry, exists := s.entries[name]
+	if !exists {
+		s.mu.Unlock()
+		return ErrJobNotFound
+	}
+	if s.writesPaused() {
+		s.mu.Unlock()
+		return ErrReadOnly
+	}
+	if err := s.claim(entry); err != nil {
+		s.mu.Unlock()
+		return err
+	}
+	s.mu.Unlock()
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		s.execute(entry)
+	}()
+	return nil
+}
+
+// Status returns the status of every job, sorted by name
+func (s *Scheduler) Status() []JobStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	statuses := make([]JobStatus, 0, len(s.entries))
+	for _, entry := range s.entries {
+		statuses = append(statuses, entry.status)
+	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Name < statuses[j].Name })
+	return statuses
+}
+
+// loop waits out each interval plus jitter and runs the job
+func (s *Scheduler) loop(entry *scheduledEntry) {
+	defer s.wg.Done()
+
+	for {
+		delay := entry.job.Interval
+		if entry.job.Jitter > 0 {
+			delay += time.Duration(s.randomFn(int64(entry.job.Jitter)))
+		}
+
+		next := time.Now().Add(delay)
+		s.mu.Lock()
+		entry.status.NextRun = &next
+		s.mu.Unlock()
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-s.ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+
+		s.mu.Lock()
+		if entry.status.Paused || s.writesPaused() || s.claim(entry) != nil {
+			entry.status.Skipped++
+			s.mu.Unlock()
+			continue
+		}
+		s.mu.Unlock()
+
+		s.execute(entry)
+	}
+}
+
+// claim marks the job running and takes its lock. The caller must hold s.mu.
+func (s *Scheduler) claim(entry *scheduledEntry) error {
+	if entry.status.Running {
+		return ErrJobRunning
+	}
+	if entry.job.Lock != "" && !s.locks.TryLock(entry.job.Lock, entry.job.Name) {
+		return ErrJobLocked
+	}
+	entry.status.Running = true
+	return nil
+}
+
+// Pause stops the named job from running on its schedule until resumed
+func (s *Scheduler) Pause(name string) error {
+	return s.setPaused(name, true)
+}
+
+// Resume lets a paused job run on its schedule again
+func (s *Scheduler) Resume(name string) error {
+	return s.setPaused(name, false)
+}
+
+// setPaused updates the paused flag of the named job
+func (s *Scheduler) setPaused(name string, paused bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry, exists := s.entries[name]
+	if !exists {
+		return ErrJobNotFound
+	}
+	entry.status.Paused = paused
+	return nil
+}
+
+// execute runs a job that has already been claimed and records the outcome
+func (s *Scheduler) execute(entry *scheduledEntry) {
+	start := time.Now()
+	err := entry.job.Run(s.ctx)
+	duration := time.Since(start)
+
+	if entry.job.Lock != "" {
+		s.locks.Unlock(entry.job.Lock)
+	}
+
+	if err != nil {
+		log.Printf("Scheduled job %s failed: %v", entry.job.Name, err)
+		scheduledRuns.Inc(entry.job.Name, "error")
+	} else {
+		scheduledRuns.Inc(entry.job.Name, "success")
+		scheduledLastSuccess.Set(float64(time.Now().Unix()), entry.job.Name)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry.status.Running = false
+	entry.status.Runs++
+	entry.status.LastRun = &start
+	entry.status.LastDuration = duration.String()
+	entry.status.LastError = ""
+	if err != nil {
+		entry.status.LastError = err.Error()
+	}
+}
@@ -0,0 +1,187 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// stubReadOnly is a read-only switch tests can flip
+type stubReadOnly struct {
+	enabled atomic.Bool
+}
+
+func (s *stubReadOnly) Enabled() bool {
+	return s.enabled.Load()
+}
+
+func TestScheduler_RunsJobsOnInterval(t *testing.T) {
+	scheduler := NewScheduler()
+
+	ran := make(chan struct{}, 10)
+	scheduler.Register(ScheduledJob{
+		Name:     "tick",
+		Interval: 10 * time.Millisecond,
+		Run: func(ctx context.Context) error {
+			ran <- struct{}{}
+			return nil
+		},
+	})
+	scheduler.Start()
+
+	for i := 0; i < 2; i++ {
+		select {
+		case <-ran:
+		case <-time.After(time.Second):
+			t.Fatal("Expected job to run on its interval")
+		}
+	}
+	scheduler.Stop()
+
+	status := scheduler.Status()
+	if len(status) != 1 || status[0].Runs < 2 || status[0].LastRun == nil {
+		t.Errorf("Unexpected status: %+v", status)
+	}
+}
+
+func TestScheduler_RunNow(t *testing.T) {
+	scheduler := NewScheduler()
+
+	release := make(chan struct{})
+	scheduler.Register(ScheduledJob{
+		Name:     "slow",
+		Interval: time.Hour,
+		Run: func(ctx context.Context) error {
+			<-release
+			return errors.New("disk full")
+		},
+	})
+
+	if err := scheduler.RunNow("slow"); err != nil {
+		t.Fatalf("Expected job to start, got %v", err)
+	}
+	if err := scheduler.RunNow("slow"); err != ErrJobRunning {
+		t.Errorf("Expected ErrJobRunning while the job runs, got %v", err)
+	}
+	if err := scheduler.RunNow("missing"); err != ErrJobNotFound {
+		t.Errorf("Expected ErrJobNotFound, got %v", err)
+	}
+
+	close(release)
+	scheduler.Stop()
+
+	status := scheduler.Status()[0]
+	if status.Running || status.Runs != 1 || status.LastError != "disk full" {
+		t.Errorf("Unexpected status after run: %+v", status)
+	}
+}
+
+func TestScheduler_SharedLock(t *testing.T) {
+	scheduler := NewScheduler()
+
+	release := make(chan struct{})
+	scheduler.Register(ScheduledJob{
+		Name:     "backup",
+		Interval: time.Hour,
+		Lock:     LockMaintenance,
+		Run: func(ctx context.Context) error {
+			<-release
+			return nil
+		},
+	})
+	scheduler.Register(ScheduledJob{
+		Name:     "cleanup",
+		Interval: time.Hour,
+		Lock:     LockMaintenance,
+		Run:      func(ctx context.Context) error { return nil },
+	})
+
+	if err := scheduler.RunNow("backup"); err != nil {
+		t.Fatalf("Expected backup to start, got %v", err)
+	}
+	if err := scheduler.RunNow("cleanup"); err != ErrJobLocked {
+		t.Errorf("Expected cleanup to be locked out during the backup, got %v", err)
+	}
+	if holder := scheduler.Locks().Holder(LockMaintenance); holder != "backup" {
+		t.Errorf("Expected backup to hold the lock, got %q", holder)
+	}
+
+	close(release)
+	scheduler.Stop()
+
+	if holder := scheduler.Locks().Holder(LockMaintenance); holder != "" {
+		t.Errorf("Expected lock to be released, got %q", holder)
+	}
+}
+
+func TestScheduler_Pause(t *testing.T) {
+	scheduler := NewScheduler()
+
+	ran := make(chan struct{}, 10)
+	scheduler.Register(ScheduledJob{
+		Name:     "tick",
+		Interval: 5 * time.Millisecond,
+		Run: func(ctx context.Context) error {
+			ran <- struct{}{}
+			return nil
+		},
+	})
+
+	if err := scheduler.Pause("tick"); err != nil {
+		t.Fatalf("Expected pause to succeed, got %v", err)
+	}
+	scheduler.Start()
+
+	time.Sleep(30 * time.Millisecond)
+	if len(ran) != 0 {
+		t.Fatalf("Expected paused job not to run, ran %d times", len(ran))
+	}
+
+	scheduler.Resume("tick")
+	select {
+	case <-ran:
+	case <-time.After(time.Second):
+		t.Error("Expected resumed job to run")
+	}
+	scheduler.Stop()
+
+	if err := scheduler.Pause("missing"); err != ErrJobNotFound {
+		t.Errorf("Expected ErrJobNotFound, got %v", err)
+	}
+}
+
+func TestScheduler_ReadOnly(t *testing.T) {
+	scheduler := NewScheduler()
+	readOnly := &stubReadOnly{}
+	readOnly.enabled.Store(true)
+	scheduler.SetReadOnlyMode(readOnly)
+
+	ran := make(chan struct{}, 10)
+	scheduler.Register(ScheduledJob{
+		Name:     "tick",
+		Interval: 5 * time.Millisecond,
+		Run: func(ctx context.Context) error {
+			ran <- struct{}{}
+			return nil
+		},
+	})
+	scheduler.Start()
+
+	if err := scheduler.RunNow("tick"); err != ErrReadOnly {
+		t.Errorf("Expected ErrReadOnly while writes are paused, got %v", err)
+	}
+	time.Sleep(30 * time.Millisecond)
+	if len(ran) != 0 {
+		t.Fatalf("Expected no jobs to run while writes are paused, ran %d times", len(ran))
+	}
+
+	readOnly.enabled.Store(false)
+	select {
+	case <-ran:
+	case <-time.After(time.Second):
+		t.Error("Expected the job to run once writes resumed")
+	}
+	scheduler.Stop()
+}
@@ -0,0 +1,69 @@
+package services
+
+import (
+	"context"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/LonleySailor/privatepaste/backend/internal/models"
+)
+
+// Trending ranking parameters
+const (
+	trendingWindow   = 7 * 24 * time.Hour // Views older than this are ignored
+	trendingHalfLife = 24 * time.Hour     // Views count half as much per half-life of age
+	trendingSize     = 100                // Pastes kept in the ranking
+)
+
+// NewTrendingJob creates the scheduled job that ranks publicly listed pastes
+// by their recent views for the trending listing
+func NewTrendingJob(trending *models.TrendingRepository) ScheduledJob {
+	return ScheduledJob{
+		Name:     "trending",
+		Interval: 15 * time.Minute,
+		Jitter:   time.Minute,
+		Run: func(ctx context.Context) error {
+			now := time.Now().UTC()
+			views, err := trending.RecentViews(now.Add(-trendingWindow))
+			if err != nil {
+				return err
+			}
+			return trending.Replace(RankTrending(views, now, trendingSize))
+		},
+	}
+}
+
+// RankTrending scores pastes by their views weighted by recency, halving the
+// weight of a day's views for every trendingHalfLife since midday that day,
+// and returns the n highest scores
+func RankTrending(views []models.DailyViews, now time.Time, n int) []models.TrendingScore {
+	scores := make(map[string]*models.TrendingScore)
+	for _, v := range views {
+		age := max(now.Sub(v.Day.Add(12*time.Hour)), 0)
+		weight := math.Pow(0.5, float64(age)/float64(trendingHalfLife))
+
+		score := scores[v.PasteID]
+		if score == nil {
+			score = &models.TrendingScore{PasteID: v.PasteID}
+			scores[v.PasteID] = score
+		}
+		score.Score += float64(v.Views) * weight
+		score.Views += v.Views
+	}
+
+	ranking := make([]models.TrendingScore, 0, len(scores))
+	for _, score := range scores {
+		ranking = append(ranking, *score)
+	}
+	sort.Slice(ranking, func(i, j int) bool {
+		if ranking[i].Score != ranking[j].Score {
+			return ranking[i].Score > ranking[j].Score
+		}
+		return ranking[i].PasteID < ranking[j].PasteID
+	})
+	if len(ranking) > n {
+		ranking = ranking[:n]
+	}
+	return ranking
+}
@@ -0,0 +1,35 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"github.com/LonleySailor/privatepaste/backend/internal/models"
+)
+
+func TestRankTrending(t *testing.T) {
+	now := time.Date(2025, 3, 10, 12, 0, 0, 0, time.UTC)
+	day := func(daysAgo int) time.Time {
+		return time.Date(2025, 3, 10-daysAgo, 0, 0, 0, 0, time.UTC)
+	}
+
+	ranking := RankTrending([]models.DailyViews{
+		{PasteID: "old", Day: day(3), Views: 50},   // Worth 50/8
+		{PasteID: "fresh", Day: day(0), Views: 10}, // Worth 10
+		{PasteID: "steady", Day: day(1), Views: 8}, // Worth 4...
+		{PasteID: "steady", Day: day(0), Views: 4}, // ...plus 4
+		{PasteID: "cold", Day: day(6), Views: 1},
+	}, now, 3)
+
+	if len(ranking) != 3 {
+		t.Fatalf("Expected the ranking to be cut to 3 pastes, got %d", len(ranking))
+	}
+	for i, id := range []string{"fresh", "steady", "old"} {
+		if ranking[i].PasteID != id {
+			t.Errorf("Expected %s at position %d, got %s", id, i, ranking[i].PasteID)
+		}
+	}
+	if ranking[1].Views != 12 || ranking[1].Score != 8 {
+		t.Errorf("Expected steady to have 12 views scoring 8, got %+v", ranking[1])
+	}
+}
@@ -0,0 +1,199 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/LonleySailor/privatepaste/backend/internal/models"
+	"github.com/LonleySailor/privatepaste/backend/pkg/utils"
+)
+
+// webhookTimeout bounds a single delivery attempt
+const webhookTimeout = 10 * time.Second
+
+// Headers carrying the signature of a webhook delivery. The signature header
+// holds one v1=<hex HMAC-SHA256> entry per active secret, computed over
+// "<timestamp>.<body>" so receivers can reject replayed deliveries.
+const (
+	WebhookTimestampHeader = "X-PasteVault-Timestamp"
+	WebhookSignatureHeader = "X-PasteVault-Signature"
+)
+
+// WebhookDelivery is the payload of a webhook job: a JSON body to post to URL,
+// signed with each of Secrets. Attempts for an integration are recorded in
+// its delivery log.
+type WebhookDelivery struct {
+	URL           string          `json:"url"`
+	Body          json.RawMessage `json:"body"`
+	Secrets       []string        `json:"secrets,omitempty"`
+	IntegrationID int64           `json:"integration_id,omitempty"`
+	Event         string          `json:"event,omitempty"`
+}
+
+// WebhookResult describes a delivery attempt that received a response
+type WebhookResult struct {
+	StatusCode int
+	Duration   time.Duration
+}
+
+// DeliveryLog records webhook delivery attempts
+type DeliveryLog interface {
+	Record(attempt *models.WebhookDeliveryAttempt) error
+}
+
+// ErrWebhookAddressBlocked is returned for a delivery to a host resolving to
+// a loopback, private, link-local or otherwise non-public address
+var ErrWebhookAddressBlocked = errors.New("webhook host resolves to a non-public address")
+
+// NewWebhookClient returns the client used for deliveries unless another is
+// given. Webhook URLs are chosen by users, so it refuses to connect to
+// non-public addresses, checking the address actually dialed so a name
+// cannot be pointed at an internal service after validation, and it does
+// not follow redirects; a redirect fails the attempt like any other non-2xx
+// response. It never uses a proxy, which would dial on its behalf.
+func NewWebhookClient() *http.Client {
+	dialer := &net.Dialer{
+		Timeout: webhookTimeout,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if !utils.IsPublicIP(net.ParseIP(host)) {
+				return ErrWebhookAddressBlocked
+			}
+			return nil
+		},
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+
+	return &http.Client{
+		Timeout:   webhookTimeout,
+		Transport: transport,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+// SignWebhook returns the v1 signature of a delivery body sent at timestamp
+func SignWebhook(secret string, timestamp time.Time, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp.Unix(), 10) + "."))
+	mac.Write(body)
+	return "v1=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// NewWebhookDeliverer returns the job handler that posts webhook deliveries.
+// Any response other than 2xx fails the attempt so the queue retries it.
+// Every attempt is recorded in deliveries unless it is nil. A nil client
+// uses NewWebhookClient.
+func NewWebhookDeliverer(client *http.Client, deliveries DeliveryLog) JobHandler {
+	if client == nil {
+		client = NewWebhookClient()
+	}
+
+	return func(ctx context.Context, payload []byte) error {
+		var delivery WebhookDelivery
+		if err := json.Unmarshal(payload, &delivery); err != nil {
+			return fmt.Errorf("invalid webhook payload: %w", err)
+		}
+
+		start := time.Now()
+		result, err := DeliverWebhook(ctx, client, delivery)
+		RecordDelivery(deliveries, delivery, result, time.Since(start), err)
+		return err
+	}
+}
+
+// RecordDelivery adds an attempt to deliver an integration's webhook to its
+// delivery log. Failing to record is logged, not returned, so it never
+// causes a redelivery.
+func RecordDelivery(deliveries DeliveryLog, delivery WebhookDelivery, result *WebhookResult, elapsed time.Duration, err error) {
+	if deliveries == nil || delivery.IntegrationID == 0 {
+		return
+	}
+
+	attempt := &models.WebhookDeliveryAttempt{
+		IntegrationID: delivery.IntegrationID,
+		Event:         delivery.Event,
+		Body:          delivery.Body,
+		Success:       err == nil,
+		LatencyMS:     elapsed.Milliseconds(),
+	}
+	if result != nil {
+		attempt.StatusCode = &result.StatusCode
+		attempt.LatencyMS = result.Duration.Milliseconds()
+	}
+	if err != nil {
+		attempt.Error = err.Error()
+	}
+	if err := deliveries.Record(attempt); err != nil {
+		log.Printf("Failed to record webhook delivery for integration %d: %v", delivery.IntegrationID, err)
+	}
+}
+
+// DeliverWebhook posts a delivery once, signing it at send time. It returns
+// an error for anything but a 2xx response; the result is set whenever a
+// response was received. A nil client uses NewWebhookClient.
+func DeliverWebhook(ctx context.Context, client *http.Client, delivery WebhookDelivery) (*WebhookResult, error) {
+	if client == nil {
+		client = NewWebhookClient()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, delivery.URL, bytes.NewReader(delivery.Body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if len(delivery.Secrets) > 0 {
+		now := time.Now()
+		signatures := make([]string, len(delivery.Secrets))
+		for i, secret := range delivery.Secrets {
+			signatures[i] = SignWebhook(secret, now, delivery.Body)
+		}
+		req.Header.Set(WebhookTimestampHeader, strconv.FormatInt(now.Unix(), 10))
+		req.Header.Set(WebhookSignatureHeader, strings.Join(signatures, ","))
+	}
+
+	start := time.Now()
+	resp, err := client.Do(req)
+	if err != nil {
+		// Webhook URLs are secrets; keep them out of the job's last error
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			return nil, urlErr.Err
+		}
+		return nil, err
+	}
+	defer resp.Body.Close()
+	// The response body is never kept: endpoints are chosen by users, so what
+	// they answer is not ours to store or show back
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	result := &WebhookResult{
+		StatusCode: resp.StatusCode,
+		Duration:   time.Since(start),
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return result, fmt.Errorf("webhook responded with status %d", resp.StatusCode)
+	}
+	return result, nil
+}
@@ -0,0 +1,91 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWebhookDeliverer(t *testing.T) {
+	var received string
+	status := http.StatusOK
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		received = string(body)
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+
+	deliver := NewWebhookDeliverer(server.Client(), nil)
+	payload, _ := json.Marshal(WebhookDelivery{URL: server.URL + "/secret-token", Body: json.RawMessage(`{"text":"hi"}`)})
+
+	if err := deliver(context.Background(), payload); err != nil {
+		t.Fatalf("Delivery failed: %v", err)
+	}
+	if received != `{"text":"hi"}` {
+		t.Errorf("Unexpected body %q", received)
+	}
+
+	status = http.StatusTooManyRequests
+	if err := deliver(context.Background(), payload); err == nil {
+		t.Error("Expected a non-2xx response to fail the attempt")
+	}
+
+	server.Close()
+	err := deliver(context.Background(), payload)
+	if err == nil || strings.Contains(err.Error(), "secret-token") {
+		t.Errorf("Expected a connection error without the webhook URL, got %v", err)
+	}
+}
+
+func TestWebhookDeliverer_Signatures(t *testing.T) {
+	var header http.Header
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header = r.Header
+	}))
+	defer server.Close()
+
+	delivery := WebhookDelivery{URL: server.URL, Body: json.RawMessage(`{"text":"hi"}`), Secrets: []string{"new", "old"}}
+	result, err := DeliverWebhook(context.Background(), server.Client(), delivery)
+	if err != nil || result.StatusCode != http.StatusOK {
+		t.Fatalf("Delivery failed: %v", err)
+	}
+
+	timestamp, err := strconv.ParseInt(header.Get(WebhookTimestampHeader), 10, 64)
+	if err != nil {
+		t.Fatalf("Expected a timestamp header, got %q", header.Get(WebhookTimestampHeader))
+	}
+	sentAt := time.Unix(timestamp, 0)
+	want := SignWebhook("new", sentAt, delivery.Body) + "," + SignWebhook("old", sentAt, delivery.Body)
+	if got := header.Get(WebhookSignatureHeader); got != want {
+		t.Errorf("Expected signatures %q, got %q", want, got)
+	}
+}
+
+func TestNewWebhookClient_RefusesInternalAddresses(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("Expected the delivery to be refused before connecting")
+	}))
+	defer server.Close()
+
+	delivery := WebhookDelivery{URL: server.URL, Body: json.RawMessage(`{}`)}
+	result, err := DeliverWebhook(context.Background(), NewWebhookClient(), delivery)
+	if !errors.Is(err, ErrWebhookAddressBlocked) {
+		t.Fatalf("Expected ErrWebhookAddressBlocked for a loopback server, got %v", err)
+	}
+	if result != nil {
+		t.Errorf("Expected no result, got %+v", result)
+	}
+
+	// Redirects are not followed, so they cannot lead somewhere internal
+	if err := NewWebhookClient().CheckRedirect(nil, nil); err != http.ErrUseLastResponse {
+		t.Errorf("Expected redirects to be refused, got %v", err)
+	}
+}
@@ -1,25 +1,62 @@
 package main
 
 import (
+	"database/sql"
+	"flag"
 	"log"
+	"net"
 	"net/http"
+	"net/url"
 	"os"
 	"os/signal"
+	"slices"
 	"strings"
 	"syscall"
+	"time"
 
 	"github.com/LonleySailor/privatepaste/backend/internal/auth"
 	"github.com/LonleySailor/privatepaste/backend/internal/config"
 	"github.com/LonleySailor/privatepaste/backend/internal/database"
 	"github.com/LonleySailor/privatepaste/backend/internal/handlers"
+	"github.com/LonleySailor/privatepaste/backend/internal/metrics"
 	"github.com/LonleySailor/privatepaste/backend/internal/middleware"
 	"github.com/LonleySailor/privatepaste/backend/internal/models"
 	"github.com/LonleySailor/privatepaste/backend/internal/services"
+	"github.com/LonleySailor/privatepaste/backend/pkg/clamd"
+	"github.com/LonleySailor/privatepaste/backend/pkg/geoip"
+	"github.com/LonleySailor/privatepaste/backend/pkg/gist"
+	"github.com/LonleySailor/privatepaste/backend/pkg/importer"
+	"github.com/LonleySailor/privatepaste/backend/pkg/listener"
+	"github.com/LonleySailor/privatepaste/backend/pkg/oidc"
+	"github.com/LonleySailor/privatepaste/backend/pkg/render"
+	"github.com/LonleySailor/privatepaste/backend/pkg/s3store"
+	"github.com/LonleySailor/privatepaste/backend/pkg/sandbox"
+	"github.com/LonleySailor/privatepaste/backend/pkg/sshpaste"
 	"github.com/LonleySailor/privatepaste/backend/pkg/utils"
 	"github.com/LonleySailor/privatepaste/backend/pkg/validation"
 	"github.com/gorilla/mux"
 )
 
+// attachmentShadowStore returns the store keeping attachment contents both
+// in the database and in the shadow bucket, or nil when no shadow bucket is
+// configured. ATTACHMENT_BLOB_PRIMARY picks which of them serves reads.
+func attachmentShadowStore(cfg *config.Config, db *sql.DB) *services.ShadowBlobStore {
+	if cfg.AttachmentShadowS3Bucket == "" {
+		return nil
+	}
+	inline := models.NewInlineBlobStore(db)
+	bucket := s3store.New(cfg.AttachmentShadowS3Endpoint, cfg.AttachmentShadowS3Bucket, cfg.AttachmentShadowS3Prefix,
+		cfg.AttachmentShadowS3Region, cfg.AttachmentShadowS3AccessKey, cfg.AttachmentShadowS3SecretKey, nil)
+	switch cfg.AttachmentBlobPrimary {
+	case config.BlobPrimaryDatabase:
+		return services.NewShadowBlobStore(inline, bucket)
+	case config.BlobPrimaryS3:
+		return services.NewShadowBlobStore(bucket, inline)
+	}
+	log.Fatalf("Invalid ATTACHMENT_BLOB_PRIMARY %q; use %s or %s", cfg.AttachmentBlobPrimary, config.BlobPrimaryDatabase, config.BlobPrimaryS3)
+	return nil
+}
+
 // setupStaticRoutes configures static file serving with SPA fallback support
 func setupStaticRoutes(router *mux.Router, staticDir string) {
 	// Create a file server for the entire static directory
@@ -64,14 +101,39 @@ func setupStaticRoutes(router *mux.Router, staticDir string) {
 }
 
 func main() {
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "migrate-data":
+			runMigrateData(config.Load(), os.Args[2:])
+			return
+		case "check-integrity":
+			runCheckIntegrity(config.Load(), os.Args[2:])
+			return
+		case "backfill-blobs":
+			runBackfillBlobs(config.Load(), os.Args[2:])
+			return
+		}
+	}
+
+	devMode := flag.Bool("dev", false, "run with an in-memory database, seeded example data and relaxed CORS")
+	flag.Parse()
+
 	// Load configuration
 	cfg := config.Load()
+	if *devMode {
+		applyDevMode(cfg)
+	}
 
 	log.Printf("Starting PrivatePaste API server...")
 	log.Printf("Environment: %s", cfg.Environment)
 	log.Printf("Port: %s", cfg.Port)
+	log.Printf("Data directory: %s", cfg.DataDir)
 	log.Printf("Database: %s", cfg.DatabasePath)
 
+	if err := cfg.PrepareDataDir(); err != nil {
+		log.Fatalf("Failed to prepare data directory: %v", err)
+	}
+
 	// Initialize database
 	db, err := database.NewSQLiteDB(cfg.DatabasePath)
 	if err != nil {
@@ -79,29 +141,442 @@ func main() {
 	}
 	defer db.Close()
 
+	db.ConfigurePool(database.PoolConfig{
+		MaxOpenConns:    cfg.DBMaxOpenConns,
+		MaxIdleConns:    cfg.DBMaxIdleConns,
+		ConnMaxLifetime: time.Duration(cfg.DBConnMaxLifetimeMinutes) * time.Minute,
+	})
+	poolMonitor := database.NewPoolMonitor(db.DB, 15*time.Second)
+	poolMonitor.Start()
+	defer poolMonitor.Stop()
+
 	// Initialize repositories
 	userRepo := models.NewUserRepository(db.DB)
 	pasteRepo := models.NewPasteRepository(db.DB)
+	jobRepo := models.NewJobRepository(db.DB)
+	storageRepo := models.NewStorageRepository(db.DB)
+	eventRepo := models.NewPasteEventRepository(db.DB)
+	apiKeyRepo := models.NewAPIKeyRepository(db.DB)
+	pasteMetadataRepo := models.NewPasteMetadataRepository(db.DB)
+	developerKeyRepo := models.NewDeveloperKeyRepository(db.DB)
+	sshKeyRepo := models.NewSSHKeyRepository(db.DB)
+	integrationRepo := models.NewIntegrationRepository(db.DB)
+	webhookDeliveryRepo := models.NewWebhookDeliveryRepository(db.DB)
+	notificationRepo := models.NewNotificationRepository(db.DB)
+	userSettingsRepo := models.NewUserSettingsRepository(db.DB)
+	serviceAccountRepo := models.NewServiceAccountRepository(db.DB)
+
+	// Serve lookups and listings from a read replica (optional)
+	if cfg.ReadReplicaPath != "" {
+		replica, err := database.OpenSQLiteReplica(cfg.ReadReplicaPath, db.DB, time.Duration(cfg.ReadReplicaMaxLagSeconds)*time.Second)
+		if err != nil {
+			log.Fatalf("Failed to open read replica: %v", err)
+		}
+		defer replica.Close()
+		replica.Start(10 * time.Second)
+		pasteRepo.SetReadReplica(replica)
+		log.Printf("Read replica: %s", cfg.ReadReplicaPath)
+	}
+
+	// Keep recently created and read pastes in memory (optional)
+	var pasteCache *services.PasteCache
+	if cfg.PasteCacheSize > 0 {
+		pasteCache = services.NewPasteCache(cfg.PasteCacheSize, time.Duration(cfg.PasteCacheTTLSeconds)*time.Second)
+		pasteRepo.SetCache(pasteCache)
+	}
+
+	// Writes can be paused, as during a storage migration; admins must still
+	// be able to log in and switch it off, and requests that only read keep
+	// working
+	readOnlyMode := middleware.NewReadOnlyMode(cfg.ReadOnly,
+		"/api/auth/login", "/api/auth/refresh", "/api/auth/introspect", "/api/admin/read-only",
+		"/api/paste/*/unlock", "/api/validate/password")
+	if cfg.ReadOnly {
+		log.Println("Starting in read-only mode; writes are paused until an admin disables it")
+	}
+	pasteRepo.SetReadOnlyMode(readOnlyMode)
+
+	// Promote configured admins
+	for _, username := range cfg.AdminUsernames {
+		found, err := userRepo.SetRoleByUsername(username, models.RoleAdmin)
+		if err != nil {
+			log.Fatalf("Failed to promote admin %s: %v", username, err)
+		}
+		if !found {
+			log.Printf("Admin user %s does not exist yet; restart after registering it", username)
+		}
+	}
+
+	// Hash pastes created before content addressing
+	var backfilled int64
+	for {
+		n, err := pasteRepo.BackfillContentHashes(500)
+		if err != nil {
+			log.Fatalf("Failed to backfill paste content hashes: %v", err)
+		}
+		if backfilled += n; n == 0 {
+			break
+		}
+	}
+	if backfilled > 0 {
+		log.Printf("Computed content hashes for %d existing pastes", backfilled)
+	}
 
 	// Initialize utilities & services
 	idGenerator := utils.NewIDGenerator()
 	validator := validation.NewValidator()
+	if cfg.UsernameMinLength < 1 || cfg.UsernameMinLength > 50 {
+		log.Fatalf("USERNAME_MIN_LENGTH must be between 1 and 50")
+	}
+	reservedUsernames := cfg.ReservedUsernames
+	if len(reservedUsernames) == 0 {
+		reservedUsernames = validation.DefaultReservedUsernames
+	}
+	// Configured admins may register under a reserved name
+	reservedUsernames = slices.DeleteFunc(slices.Clone(reservedUsernames), func(name string) bool {
+		return slices.ContainsFunc(cfg.AdminUsernames, func(admin string) bool { return strings.EqualFold(admin, name) })
+	})
+	validator.SetUsernamePolicy(cfg.UsernameMinLength, reservedUsernames)
 	tokenManager := auth.NewTokenManager(cfg.JWTSecret, cfg.RefreshJWTSecret)
+	accessLifetime := time.Duration(cfg.AccessTokenMinutes) * time.Minute
+	refreshLifetime := time.Duration(cfg.RefreshTokenHours) * time.Hour
+	if accessLifetime <= 0 || refreshLifetime < accessLifetime {
+		log.Fatalf("Invalid token lifetimes: access %v, refresh %v", accessLifetime, refreshLifetime)
+	}
+	tokenManager.SetLifetimes(accessLifetime, refreshLifetime)
+	tokenManager.SetLeeway(time.Duration(cfg.JWTLeewaySeconds) * time.Second)
+	tokenManager.SetAudience(cfg.JWTIssuer, cfg.JWTAudience)
+	ipHasher := utils.NewIPHasher(cfg.IPHashSecret, time.Duration(cfg.IPHashRotationDays)*24*time.Hour)
+
+	if *devMode {
+		if err := seedDevData(userRepo, pasteRepo, tokenManager); err != nil {
+			log.Fatalf("Failed to seed dev data: %v", err)
+		}
+	}
 
 	// Initialize middleware
 	authMiddleware := middleware.NewAuthMiddleware(tokenManager)
 	corsMiddleware := middleware.SetupCORS(cfg.CORSOrigins, cfg.IsDevelopment())
-	rateLimiter := middleware.NewDefaultRateLimiter() // Will be enhanced later
+	rateLimiter := middleware.NewDefaultRateLimiter()
+	rateLimiter.SetBandwidthLimit(int64(cfg.BandwidthLimitMB)<<20, time.Hour)
+
+	tarpit := middleware.NewTarpit(
+		cfg.TarpitDelayThreshold,
+		cfg.TarpitBanThreshold,
+		time.Duration(cfg.TarpitWindowMinutes)*time.Minute,
+		time.Duration(cfg.TarpitBanMinutes)*time.Minute,
+	)
+
+	// Country restrictions for paste creation and registration, and country
+	// lookups for pastes their creators restricted to countries (optional)
+	restrictCountry := func(next http.Handler) http.Handler { return next }
+	var countryRestriction *middleware.GeoRestriction
+	var countryLookup middleware.CountryLookup
+	if cfg.GeoIPDatabasePath != "" {
+		geoReader, err := geoip.Open(cfg.GeoIPDatabasePath)
+		if err != nil {
+			log.Fatalf("Failed to open GeoIP database: %v", err)
+		}
+		defer geoReader.Close()
+		countryRestriction = middleware.NewGeoRestriction(geoReader, cfg.GeoIPAllow, cfg.GeoIPDeny)
+		restrictCountry = countryRestriction.Restrict
+		countryLookup = geoReader
+	}
+
+	// Inject latency and errors into API requests for client testing (optional)
+	chaos := middleware.NewChaos(cfg.ChaosErrorPercent, cfg.ChaosLatencyPercent, time.Duration(cfg.ChaosMaxLatencyMillis)*time.Millisecond)
+	if chaos.Enabled() && cfg.IsProduction() {
+		log.Println("Chaos mode is not available in production; ignoring CHAOS_* settings")
+	} else if chaos.Enabled() {
+		log.Printf("Chaos mode enabled: %d%% errors, %d%% delayed up to %dms",
+			cfg.ChaosErrorPercent, cfg.ChaosLatencyPercent, cfg.ChaosMaxLatencyMillis)
+	}
+
+	// Fail fast with 503s while the database is failing
+	dbBreaker := database.NewBreaker(cfg.DBBreakerThreshold, time.Duration(cfg.DBBreakerCooldownSeconds)*time.Second)
+	failFast := middleware.CircuitBreaker(dbBreaker)
+
+	// Request bodies fail when they stall, so slow clients cannot hold
+	// connections on any route accepting one
+	slowBody := middleware.NewSlowBodyGuard(time.Duration(cfg.BodyChunkTimeoutSeconds)*time.Second, time.Duration(cfg.BodyReadTimeoutSeconds)*time.Second)
 
 	// Initialize handlers
 	userHandler := handlers.NewUserHandler(userRepo, tokenManager, validator)
-	pasteHandler := handlers.NewPasteHandler(pasteRepo, idGenerator, validator)
+	userHandler.SetRenameCooldown(time.Duration(cfg.RenameCooldownDays) * 24 * time.Hour)
+	avatarRepo := models.NewAvatarRepository(db.DB)
+	userHandler.SetAvatars(avatarRepo)
+	pasteHandlerRepo := handlers.NewBreakerPasteRepository(pasteRepo, dbBreaker)
+	if cfg.MirrorUpstreamURL != "" {
+		// Read-through mirror of another instance; upstream failures must not
+		// trip the database breaker, so the mirror wraps it
+		if u, err := url.Parse(cfg.MirrorUpstreamURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			log.Fatalf("Invalid MIRROR_UPSTREAM_URL %q", cfg.MirrorUpstreamURL)
+		}
+		pasteHandlerRepo = handlers.NewMirrorPasteRepository(pasteHandlerRepo, cfg.MirrorUpstreamURL,
+			time.Duration(cfg.MirrorCacheTTLSeconds)*time.Second, nil)
+		log.Printf("Running as a read-only mirror of %s", cfg.MirrorUpstreamURL)
+	}
+	pasteHandler := handlers.NewPasteHandler(pasteHandlerRepo, idGenerator, validator)
+	pasteHandler.SetIPHasher(ipHasher)
+	pasteHandler.SetViewRecorder(notificationRepo)
+	pasteHandler.SetUserSettings(userSettingsRepo)
+	pasteHandler.SetHintLimiter(rateLimiter)
+	pasteHandler.SetReadOnlyMode(readOnlyMode)
+	if pasteCache != nil {
+		pasteHandler.SetRenderCache(pasteCache, cfg.PrerenderHTML)
+	}
+	if countryLookup != nil {
+		pasteHandler.SetCountryLookup(countryLookup)
+		pasteHandler.SetCountryRestriction(countryRestriction)
+	}
+	contentPolicies := services.NewContentPolicyService(models.NewContentPolicyRepository(db.DB))
+	pasteHandler.SetContentPolicies(contentPolicies)
+	// Pastes created outside the paste API are held to the same policies
+	pasteCreator := handlers.NewPasteCreator(pasteRepo)
+	pasteCreator.SetContentPolicies(contentPolicies)
+	pasteCreator.SetUserRoles(userRepo)
+	pasteCreator.SetIPHasher(ipHasher)
+	if countryRestriction != nil {
+		pasteCreator.SetCountryRestriction(countryRestriction)
+	}
+	anonymousExpiry, err := handlers.NewAnonymousExpiryPolicy(cfg.AnonymousDefaultExpiry, cfg.AnonymousAllowNever, validator)
+	if err != nil {
+		log.Fatalf("Invalid anonymous expiry configuration: %v", err)
+	}
+	pasteHandler.SetAnonymousExpiryPolicy(anonymousExpiry)
+	expiryPresets, err := handlers.NewExpiryPresets(cfg.ExpiryPresets, cfg.StrictExpiry, validator)
+	if err != nil {
+		log.Fatalf("Invalid expiry presets: %v", err)
+	}
+	if anonymousExpiry.Default != "" && !expiryPresets.Allows(anonymousExpiry.Default) {
+		log.Fatalf("Anonymous default expiry %q is not one of the strict expiry presets", anonymousExpiry.Default)
+	}
+	pasteHandler.SetExpiryPresets(expiryPresets)
+	urlSigner := utils.NewURLSigner(cfg.URLSigningSecret)
+	pasteHandler.SetURLSigner(urlSigner)
+	if cfg.DiagramRendererURL != "" {
+		pasteHandler.SetDiagramRenderer(render.NewDiagramRenderer(cfg.DiagramRendererURL))
+	}
+	if cfg.SandboxURL != "" {
+		pasteHandler.SetSandbox(sandbox.NewClient(cfg.SandboxURL))
+	}
+	pasteHandler.SetGistClient(gist.NewClient(gist.GitHubAPI))
+	attachmentRepo := models.NewAttachmentRepository(db.DB)
+	if shadowStore := attachmentShadowStore(cfg, db.DB); shadowStore != nil {
+		log.Printf("Attachment contents are shadowed to bucket %s; the primary is the %s", cfg.AttachmentShadowS3Bucket, cfg.AttachmentBlobPrimary)
+		attachmentRepo.SetBlobStore(shadowStore)
+	}
+	var attachmentLimits *handlers.AttachmentLimits
+	if cfg.AttachmentMaxCount > 0 {
+		attachmentLimits = &handlers.AttachmentLimits{
+			MaxSize:  int64(cfg.AttachmentMaxSize),
+			MaxCount: cfg.AttachmentMaxCount,
+		}
+		pasteHandler.SetAttachments(attachmentRepo, *attachmentLimits)
+		if cfg.ClamdAddress != "" {
+			pasteHandler.SetAttachmentScanner(clamd.NewClient(cfg.ClamdAddress))
+		}
+	}
 	healthHandler := handlers.NewHealthHandler(db.DB)
+	healthHandler.SetBreaker(dbBreaker)
+	adminHandler := handlers.NewAdminHandler(pasteRepo, jobRepo, ipHasher)
+	storageHandler := handlers.NewStorageHandler(storageRepo)
+	statsHandler := handlers.NewStatsHandler(eventRepo)
+	exportHandler := handlers.NewExportHandler(pasteRepo)
+	importHandler := handlers.NewImportHandler(pasteRepo, idGenerator, validator, importer.NewFetcher(nil))
+	importHandler.SetPasteCreator(pasteCreator)
+	apiKeyHandler := handlers.NewAPIKeyHandler(apiKeyRepo, validator)
+	developerKeyHandler := handlers.NewDeveloperKeyHandler(developerKeyRepo, rateLimiter, validator)
+	quickHandler := handlers.NewQuickHandler(pasteRepo, apiKeyRepo, idGenerator, validator)
+	quickHandler.SetPasteCreator(pasteCreator)
+	triggerRepo := models.NewTriggerRepository(db.DB)
+	triggerHandler := handlers.NewTriggerHandler(triggerRepo, apiKeyRepo)
+	mcpHandler := handlers.NewMCPHandler(pasteRepo, apiKeyRepo, idGenerator, validator)
+	mcpHandler.SetPasteCreator(pasteCreator)
+	sshKeyHandler := handlers.NewSSHKeyHandler(sshKeyRepo, validator)
+	blobHandler := handlers.NewBlobHandler(pasteRepo)
+	avatarHandler := handlers.NewAvatarHandler(avatarRepo)
+	followHandler := handlers.NewFollowHandler(models.NewFollowRepository(db.DB), userRepo)
+	trendingRepo := models.NewTrendingRepository(db.DB)
+	trendingHandler := handlers.NewTrendingHandler(trendingRepo)
+	languageHandler := handlers.NewLanguageHandler(pasteRepo, validator)
+	archiveHandler := handlers.NewArchiveHandler(pasteRepo)
+
+	// Sign raw content so mirrors can be verified against the origin (optional)
+	var responseSigner *utils.ResponseSigner
+	if cfg.ResponseSigningKeyPath != "" {
+		responseSigner, err = utils.LoadResponseSigner(cfg.ResponseSigningKeyPath)
+		if err != nil {
+			log.Fatalf("Failed to load response signing key: %v", err)
+		}
+		pasteHandler.SetResponseSigner(responseSigner)
+		blobHandler.SetResponseSigner(responseSigner)
+	}
+	legalExportHandler := handlers.NewLegalExportHandler(handlers.LegalExportSources{
+		Users:       userRepo,
+		Pastes:      pasteRepo,
+		Attachments: attachmentRepo,
+		APIKeys:     apiKeyRepo,
+		SSHKeys:     sshKeyRepo,
+		Events:      eventRepo,
+		Audit:       models.NewAuditRepository(db.DB),
+	}, responseSigner, validator)
+	s3Handler := handlers.NewS3Handler(userRepo, pasteRepo, validator, cfg.S3GatewaySecret)
+	if countryLookup != nil {
+		s3Handler.SetCountryLookup(countryLookup)
+	}
 
 	// Initialize services
-	cleanupService := services.NewCleanupService(pasteRepo)
-	cleanupService.Start()
-	defer cleanupService.Stop()
+	// Persistent queue for webhook deliveries, emails and CDN purges
+	jobQueue := services.NewJobQueue(jobRepo, cfg.JobWorkers)
+	jobQueue.SetReadOnlyMode(readOnlyMode)
+	jobQueue.Register(services.JobTypeWebhook, services.NewWebhookDeliverer(nil, webhookDeliveryRepo))
+
+	// Purge cached copies of deleted and expired pastes at the CDN (optional)
+	var purgeQueue *services.JobQueue
+	if cfg.CDNProvider != "" {
+		purger, err := services.NewCDNPurger(cfg.CDNProvider, cfg.CDNServiceID, cfg.CDNAPIToken, nil)
+		if err != nil {
+			log.Fatalf("Invalid CDN configuration: %v", err)
+		}
+		jobQueue.Register(services.JobTypeCDNPurge, purger)
+		purgeQueue = jobQueue
+		pasteHandler.SetPurgeQueue(purgeQueue)
+	}
+
+	// Outgoing email (optional)
+	var emailQueue handlers.JobEnqueuer
+	if cfg.SMTPHost != "" {
+		jobQueue.Register(services.JobTypeEmail, services.NewEmailSender(
+			services.NewMailer(cfg.SMTPHost, cfg.SMTPPort, cfg.SMTPUsername, cfg.SMTPPassword, cfg.MailFrom),
+		))
+		emailQueue = jobQueue
+	}
+
+	// Post-create hooks: titles and summaries from an external service (optional)
+	var pasteHooks []services.PasteHook
+	if cfg.SummaryHookURL != "" {
+		pasteHooks = append(pasteHooks, services.NewSummaryHook(cfg.SummaryHookURL, cfg.SummaryHookToken, nil, pasteMetadataRepo))
+	}
+	if len(pasteHooks) > 0 {
+		jobQueue.Register(services.JobTypePasteHooks, services.NewPasteHookRunner(pasteRepo, pasteHooks...))
+		pasteHandler.SetHookQueue(jobQueue)
+		pasteCreator.SetHookQueue(jobQueue)
+	}
+	pasteHandler.SetPasteMetadata(pasteMetadataRepo)
+	pasteHandler.SetTagStore(models.NewTagRepository(db.DB))
+	pasteHandler.SetPasteSearcher(pasteRepo)
+
+	scheduler := services.NewScheduler()
+	scheduler.SetReadOnlyMode(readOnlyMode)
+	scheduler.Register(services.NewCleanupJob(pasteRepo, purgeQueue))
+	scheduler.Register(services.NewJobPruneJob(jobRepo, 7*24*time.Hour))
+	scheduler.Register(services.NewVacuumJob(db))
+	scheduler.Register(services.NewStatsRollupJob(eventRepo))
+	scheduler.Register(services.NewDigestJob(notificationRepo, integrationRepo, jobQueue, handlers.PasteURL))
+	scheduler.Register(services.NewTrendingJob(trendingRepo))
+	scheduler.Register(services.NewViewLogPruneJob(triggerRepo, 30*24*time.Hour))
+	scheduler.Register(services.NewBlobDeletionJob(attachmentRepo))
+	if cfg.DataRetentionDays > 0 {
+		scheduler.Register(services.NewAnonymizationJob(models.NewRetentionRepository(db.DB), time.Duration(cfg.DataRetentionDays)*24*time.Hour))
+	}
+	if cfg.ColdStorageDays > 0 {
+		scheduler.Register(services.NewColdStorageJob(pasteRepo, time.Duration(cfg.ColdStorageDays)*24*time.Hour))
+	}
+	scheduler.Start()
+	defer scheduler.Stop()
+	schedulerHandler := handlers.NewSchedulerHandler(scheduler)
+	integrationHandler := handlers.NewIntegrationHandler(integrationRepo, webhookDeliveryRepo, pasteRepo, jobQueue, validator)
+	notificationHandler := handlers.NewNotificationHandler(notificationRepo, integrationRepo)
+	userSettingsHandler := handlers.NewUserSettingsHandler(userSettingsRepo, validator)
+	userSettingsHandler.SetExpiryPresets(expiryPresets)
+	emailHandler := handlers.NewEmailHandler(pasteRepo, emailQueue, urlSigner, validator)
+	accountEmailHandler := handlers.NewAccountEmailHandler(userRepo, emailQueue, urlSigner)
+
+	// Single sign-on through an OpenID Connect provider or LDAP directory (optional)
+	var ssoProviders []string
+	ssoHandler := handlers.NewSSOHandler(models.NewIdentityRepository(db.DB), tokenManager, validator, handlers.GroupRoles{
+		AdminGroups:   cfg.SSOAdminGroups,
+		AllowedGroups: cfg.SSOAllowedGroups,
+	})
+	if cfg.OIDCIssuerURL != "" {
+		if cfg.OIDCClientID == "" || cfg.OIDCRedirectURL == "" {
+			log.Fatalf("OIDC_CLIENT_ID and OIDC_REDIRECT_URL are required with OIDC_ISSUER_URL")
+		}
+		ssoHandler.SetOIDC(oidc.NewProvider(oidc.Config{
+			Issuer:       cfg.OIDCIssuerURL,
+			ClientID:     cfg.OIDCClientID,
+			ClientSecret: cfg.OIDCClientSecret,
+			RedirectURL:  cfg.OIDCRedirectURL,
+			Scopes:       cfg.OIDCScopes,
+		}, nil), cfg.OIDCUsernameClaim, cfg.OIDCGroupsClaim, cfg.OIDCPostLoginURL)
+		ssoProviders = append(ssoProviders, models.IdentityProviderOIDC)
+	}
+	if cfg.LDAPURL != "" {
+		if cfg.LDAPBaseDN == "" || !strings.Contains(cfg.LDAPUserFilter, "%s") {
+			log.Fatalf("LDAP_BASE_DN is required with LDAP_URL, and LDAP_USER_FILTER must contain %%s")
+		}
+		ssoHandler.SetLDAP(handlers.LDAPConfig{
+			URL:               cfg.LDAPURL,
+			StartTLS:          cfg.LDAPStartTLS,
+			BindDN:            cfg.LDAPBindDN,
+			BindPassword:      cfg.LDAPBindPassword,
+			BaseDN:            cfg.LDAPBaseDN,
+			UserFilter:        cfg.LDAPUserFilter,
+			UsernameAttribute: cfg.LDAPUsernameAttribute,
+			GroupAttribute:    cfg.LDAPGroupAttribute,
+		})
+		ssoProviders = append(ssoProviders, models.IdentityProviderLDAP)
+	}
+
+	readOnlyHandler := handlers.NewReadOnlyHandler(readOnlyMode)
+	integrityHandler := handlers.NewIntegrityHandler(models.NewIntegrityRepository(db.DB))
+
+	capabilitiesHandler := handlers.NewCapabilitiesHandler(handlers.Capabilities{
+		Expiry:          expiryPresets,
+		AnonymousExpiry: anonymousExpiry,
+		ReadOnly:        cfg.MirrorUpstreamURL != "",
+		Email:           emailQueue != nil,
+		SignedResponses: responseSigner != nil,
+		Diagrams:        cfg.DiagramRendererURL != "",
+		Sandbox:         cfg.SandboxURL != "",
+		Attachments:     attachmentLimits,
+		SSO:             ssoProviders,
+	})
+	capabilitiesHandler.SetReadOnlyMode(readOnlyMode)
+	inboundEmailHandler := handlers.NewInboundEmailHandler(userRepo, pasteRepo, idGenerator, validator, emailQueue, cfg.InboundEmailSecret)
+	inboundEmailHandler.SetPasteCreator(pasteCreator)
+	contentPolicyHandler := handlers.NewContentPolicyHandler(contentPolicies, validator)
+	scimRepo := models.NewSCIMRepository(db.DB)
+	scimRepo.SetRenameCooldown(time.Duration(cfg.RenameCooldownDays) * 24 * time.Hour)
+	scimHandler := handlers.NewSCIMHandler(scimRepo, validator, cfg.SCIMToken)
+	introspectionHandler := handlers.NewIntrospectionHandler(tokenManager, userRepo, cfg.IntrospectionSecret)
+	serviceAccountHandler := handlers.NewServiceAccountHandler(serviceAccountRepo, userRepo, tokenManager, validator)
+
+	// Backlog gauges computed at scrape time; -1 signals a failed query
+	metrics.NewGaugeFunc("pastevault_cleanup_lag_seconds", "How long the oldest expired paste has been waiting for cleanup.", func() float64 {
+		age, err := pasteRepo.ExpiredBacklogAge()
+		if err != nil {
+			return -1
+		}
+		return age.Seconds()
+	})
+	metrics.NewGaugeFunc("pastevault_job_queue_depth", "Pending background jobs that are due to run.", func() float64 {
+		count, err := jobRepo.CountDue()
+		if err != nil {
+			return -1
+		}
+		return float64(count)
+	})
+	metrics.NewGaugeFunc("pastevault_job_queue_dead", "Background jobs in the dead-letter list.", func() float64 {
+		count, err := jobRepo.CountByStatus(models.JobDead)
+		if err != nil {
+			return -1
+		}
+		return float64(count)
+	})
+	jobQueue.Start()
+	defer jobQueue.Stop()
 
 	// Setup router
 	router := mux.NewRouter()
@@ -109,54 +584,290 @@ func main() {
 	// Apply global middleware
 	router.Use(middleware.SecurityHeaders)   // Add security headers
 	router.Use(middleware.LoggingMiddleware) // Use a proper structured logger
+	router.Use(middleware.Metrics)           // Per-route request, error and latency metrics
 	router.Use(middleware.RecoveryMiddleware)
+	if cfg.MirrorUpstreamURL != "" {
+		router.Use(middleware.ReadOnly) // Pastes are created on the upstream
+	}
+	router.Use(readOnlyMode.Guard)
 
 	// API routes
 	api := router.PathPrefix("/api").Subrouter()
+	api.Use(middleware.NewCacheControl(middleware.DefaultCachePolicies(), middleware.CachePolicy{CacheControl: middleware.CacheNoStore}).Apply)
+	if chaos.Enabled() && !cfg.IsProduction() {
+		api.Use(chaos.Inject)
+	}
+	api.Use(rateLimiter.LimitDeveloperKeys(developerKeyRepo)) // Per-key limits for X-API-Key requests
 
 	// Health check endpoints
 	api.HandleFunc("/health", healthHandler.BasicHealth).Methods("GET")
 	api.HandleFunc("/health/detailed", healthHandler.DetailedHealth).Methods("GET")
+	router.HandleFunc("/readyz", healthHandler.Ready).Methods("GET")
+
+	// Limits, expiry choices and optional features of this instance
+	api.HandleFunc("/instance", capabilitiesHandler.Get).Methods("GET")
+	api.HandleFunc("/capabilities", capabilitiesHandler.Get).Methods("GET")
+
+	// Transform operations available to POST /api/paste/{id}/transform
+	api.HandleFunc("/transforms", pasteHandler.ListTransforms).Methods("GET")
+	api.Handle("/validate/password", rateLimiter.LimitPasteRetrieval(http.HandlerFunc(pasteHandler.CheckPasswordStrength))).Methods("POST")
+	api.Handle("/blob/{hash}", failFast(rateLimiter.LimitPasteRetrieval(http.HandlerFunc(blobHandler.GetBlob)))).Methods("GET", "HEAD")
+	api.Handle("/avatars/{hash}", failFast(rateLimiter.LimitPasteRetrieval(http.HandlerFunc(avatarHandler.Get)))).Methods("GET", "HEAD")
+	api.Handle("/pastes/trending", failFast(rateLimiter.LimitPasteRetrieval(http.HandlerFunc(trendingHandler.List)))).Methods("GET")
+	api.Handle("/pastes/language/{lang}", failFast(rateLimiter.LimitPasteRetrieval(http.HandlerFunc(languageHandler.ListPastes)))).Methods("GET")
+	api.Handle("/languages", failFast(rateLimiter.LimitPasteRetrieval(http.HandlerFunc(languageHandler.ListLanguages)))).Methods("GET")
+	api.Handle("/archive/{year}/{month}", failFast(rateLimiter.LimitPasteRetrieval(http.HandlerFunc(archiveHandler.GetMonth)))).Methods("GET")
+
+	// Self-service developer keys for the public API, separate from account keys
+	api.Handle("/developer/keys", rateLimiter.Limit(middleware.PolicyDeveloperKeys)(http.HandlerFunc(developerKeyHandler.Create))).Methods("POST")
+	api.HandleFunc("/developer/key", developerKeyHandler.Usage).Methods("GET")
+	api.HandleFunc("/developer/key", developerKeyHandler.Revoke).Methods("DELETE")
+
+	// Public key for verifying signed raw content
+	if responseSigner != nil {
+		router.HandleFunc(handlers.SigningKeyPath, handlers.NewSigningKeyHandler(responseSigner).GetKey).Methods("GET")
+	}
+
+	// Prometheus metrics, on the main port only behind a token
+	if cfg.MetricsToken != "" {
+		router.Handle("/metrics", middleware.RequireToken(cfg.MetricsToken)(metrics.Handler())).Methods("GET")
+	}
 
 	// Public paste routes
 	pasteRouter := api.PathPrefix("/paste").Subrouter()
-	pasteRouter.Handle("", rateLimiter.LimitPasteCreation(http.HandlerFunc(pasteHandler.Create))).Methods("POST")
+	pasteRouter.Use(failFast)                    // Reject requests while the database breaker is open
+	pasteRouter.Use(tarpit.Protect)              // Slow down and ban ID scanners
+	pasteRouter.Use(authMiddleware.OptionalAuth) // Associate pastes with logged-in users
+	pasteRouter.Use(rateLimiter.LimitBandwidth)  // Cap bytes served per IP or user
+	pasteRouter.Handle("", authMiddleware.RequireScope(auth.ScopeUser, auth.ScopePasteCreate)(restrictCountry(rateLimiter.LimitPasteCreation(http.HandlerFunc(pasteHandler.Create))))).Methods("POST")
 	pasteRouter.Handle("/{id}", rateLimiter.LimitPasteRetrieval(http.HandlerFunc(pasteHandler.GetByID))).Methods("GET")
 	pasteRouter.Handle("/{id}/raw", rateLimiter.LimitPasteRetrieval(http.HandlerFunc(pasteHandler.GetRaw))).Methods("GET")
-	pasteRouter.HandleFunc("/{id}/unlock", pasteHandler.GetByIDWithPassword).Methods("POST")
+	pasteRouter.Handle("/{id}/download", rateLimiter.LimitPasteRetrieval(http.HandlerFunc(pasteHandler.GetDownload))).Methods("GET")
+	pasteRouter.Handle("/{id}/signature", rateLimiter.LimitPasteRetrieval(http.HandlerFunc(pasteHandler.GetSignature))).Methods("GET")
+	pasteRouter.Handle("/{id}/signed-url", rateLimiter.LimitPasteRetrieval(http.HandlerFunc(pasteHandler.SignURL))).Methods("GET")
+	pasteRouter.Handle("/{id}/pdf", rateLimiter.LimitPasteRetrieval(http.HandlerFunc(pasteHandler.GetPDF))).Methods("GET")
+	pasteRouter.Handle("/{id}/image.png", rateLimiter.LimitPasteRetrieval(http.HandlerFunc(pasteHandler.GetImage))).Methods("GET")
+	pasteRouter.Handle("/{id}/diff-json", rateLimiter.LimitPasteRetrieval(http.HandlerFunc(pasteHandler.GetDiffJSON))).Methods("GET")
+	pasteRouter.Handle("/{id}/table", rateLimiter.LimitPasteRetrieval(http.HandlerFunc(pasteHandler.GetTable))).Methods("GET")
+	pasteRouter.Handle("/{id}/notebook", rateLimiter.LimitPasteRetrieval(http.HandlerFunc(pasteHandler.GetNotebook))).Methods("GET")
+	pasteRouter.Handle("/{id}/ansi", rateLimiter.LimitPasteRetrieval(http.HandlerFunc(pasteHandler.GetANSI))).Methods("GET")
+	pasteRouter.Handle("/{id}/html", rateLimiter.LimitPasteRetrieval(http.HandlerFunc(pasteHandler.GetHTML))).Methods("GET")
+	pasteRouter.Handle("/{id}/render", rateLimiter.LimitPasteRetrieval(http.HandlerFunc(pasteHandler.GetMarkdown))).Methods("GET")
+	pasteRouter.Handle("/{id}/diagram.svg", rateLimiter.LimitPasteRetrieval(http.HandlerFunc(pasteHandler.GetDiagram))).Methods("GET")
+	pasteRouter.Handle("/{id}/transform", rateLimiter.LimitPasteCreation(http.HandlerFunc(pasteHandler.Transform))).Methods("POST")
+	pasteRouter.Handle("/{id}/export/gist", rateLimiter.LimitPasteCreation(http.HandlerFunc(pasteHandler.ExportGist))).Methods("POST")
+	pasteRouter.Handle("/{id}/run", rateLimiter.LimitPasteCreation(http.HandlerFunc(pasteHandler.Run))).Methods("POST")
+	pasteRouter.Handle("/{id}/email", rateLimiter.Limit(middleware.PolicyEmail)(http.HandlerFunc(emailHandler.EmailPaste))).Methods("POST")
+	pasteRouter.Handle("/{id}/archive.zip", rateLimiter.LimitPasteRetrieval(http.HandlerFunc(pasteHandler.GetArchive))).Methods("GET")
+	pasteRouter.Handle("/{id}/attachments/{name}", rateLimiter.LimitPasteRetrieval(http.HandlerFunc(pasteHandler.GetAttachment))).Methods("GET")
+	pasteRouter.Handle("/{id}/unlock", rateLimiter.Limit(middleware.PolicyUnlock)(http.HandlerFunc(pasteHandler.GetByIDWithPassword))).Methods("POST")
 
 	// Auth routes with rate limiting
 	authRouter := api.PathPrefix("/auth").Subrouter()
-	authRouter.Handle("/register", rateLimiter.LimitRegistration(http.HandlerFunc(userHandler.Register))).Methods("POST")
+	authRouter.Use(failFast)
+	authRouter.Handle("/register", restrictCountry(rateLimiter.LimitRegistration(http.HandlerFunc(userHandler.Register)))).Methods("POST")
 	authRouter.Handle("/login", rateLimiter.LimitAuthentication(http.HandlerFunc(userHandler.Login))).Methods("POST")
 	authRouter.HandleFunc("/refresh", userHandler.RefreshToken).Methods("POST")
-	authRouter.HandleFunc("/logout", userHandler.Logout).Methods("POST") // Placeholder
+	authRouter.HandleFunc("/logout", userHandler.Logout).Methods("POST")         // Placeholder
+	authRouter.HandleFunc("/token", serviceAccountHandler.Token).Methods("POST") // Service accounts
+	if cfg.IntrospectionSecret != "" {
+		// Service-to-service, authenticated by the introspection secret
+		authRouter.HandleFunc("/introspect", introspectionHandler.Introspect).Methods("POST")
+	}
+
+	// Polling triggers for automation platforms, authenticated by an API key
+	triggers := api.PathPrefix("/triggers").Subrouter()
+	triggers.Use(failFast)
+	triggers.Use(rateLimiter.LimitPasteRetrieval)
+	triggers.HandleFunc("/me", triggerHandler.Me).Methods("GET")
+	triggers.HandleFunc("/new-paste", triggerHandler.NewPaste).Methods("GET")
+	triggers.HandleFunc("/paste-viewed", triggerHandler.PasteViewed).Methods("GET")
+
+	// Quick paste for the browser extension, authenticated by an API key in
+	// the form body so it works as a CORS simple request from any origin
+	api.Handle("/quick", failFast(middleware.AllowAnyOrigin(rateLimiter.Limit(middleware.PolicyQuickPaste)(http.HandlerFunc(quickHandler.Create))))).Methods("POST")
+
+	// Tool server for AI assistants, authenticated by a scoped API key
+	api.Handle("/mcp", failFast(rateLimiter.Limit(middleware.PolicyQuickPaste)(http.HandlerFunc(mcpHandler.Serve)))).Methods("POST")
+
+	// Email verification links and the inbound email webhook (optional)
+	api.Handle("/user/email/verify", failFast(http.HandlerFunc(accountEmailHandler.Verify))).Methods("GET")
+	if cfg.InboundEmailSecret != "" {
+		api.Handle("/inbound/email", failFast(http.HandlerFunc(inboundEmailHandler.Receive))).Methods("POST")
+	}
+
+	// Routes service accounts may use as well as people, given the scope
+	automation := api.PathPrefix("").Subrouter()
+	automation.Use(failFast)
+	automation.Use(authMiddleware.RequireAuth)
+	automation.Handle("/paste/{id}", authMiddleware.RequireScope(auth.ScopeUser, auth.ScopePasteDelete)(http.HandlerFunc(pasteHandler.Delete))).Methods("DELETE")
 
-	// Protected routes (require authentication)
+	// Protected routes (require authentication as a person)
 	protected := api.PathPrefix("").Subrouter()
+	protected.Use(failFast)
 	protected.Use(authMiddleware.RequireAuth)
+	protected.Use(authMiddleware.RequireScope(auth.ScopeUser))
 
 	// Protected user routes
 	protected.HandleFunc("/user/profile", userHandler.GetProfile).Methods("GET")
+	protected.HandleFunc("/user/username", userHandler.ChangeUsername).Methods("PUT")
+	protected.Handle("/user/avatar", rateLimiter.LimitPasteCreation(http.HandlerFunc(avatarHandler.Upload))).Methods("PUT")
+	protected.HandleFunc("/user/avatar", avatarHandler.Delete).Methods("DELETE")
+	protected.HandleFunc("/user/following", followHandler.List).Methods("GET")
+	protected.HandleFunc("/user/following/{username}", followHandler.Follow).Methods("PUT")
+	protected.HandleFunc("/user/following/{username}", followHandler.Unfollow).Methods("DELETE")
+	protected.HandleFunc("/user/feed", followHandler.Feed).Methods("GET")
 	protected.HandleFunc("/user/pastes", pasteHandler.GetUserPastes).Methods("GET")
+	protected.HandleFunc("/user/pastes/search", pasteHandler.SearchUserPastes).Methods("GET")
+	protected.HandleFunc("/user/tags", pasteHandler.GetUserTags).Methods("GET")
+	protected.HandleFunc("/user/storage", storageHandler.GetUserStorage).Methods("GET")
+	protected.HandleFunc("/user/export", exportHandler.ExportUserPastes).Methods("GET")
+	protected.Handle("/user/import", rateLimiter.LimitPasteCreation(http.HandlerFunc(importHandler.Import))).Methods("POST")
+	protected.HandleFunc("/user/api-keys", apiKeyHandler.List).Methods("GET")
+	protected.HandleFunc("/user/api-keys", apiKeyHandler.Create).Methods("POST")
+	protected.HandleFunc("/user/api-keys/{id}", apiKeyHandler.Delete).Methods("DELETE")
+	protected.HandleFunc("/user/ssh-keys", sshKeyHandler.List).Methods("GET")
+	protected.HandleFunc("/user/ssh-keys", sshKeyHandler.Create).Methods("POST")
+	protected.HandleFunc("/user/ssh-keys/{id}", sshKeyHandler.Delete).Methods("DELETE")
+	if cfg.S3GatewaySecret != "" {
+		protected.HandleFunc("/user/s3-credentials", s3Handler.Credentials).Methods("GET")
+	}
+	protected.Handle("/user/email", rateLimiter.Limit(middleware.PolicyEmail)(http.HandlerFunc(accountEmailHandler.RequestVerification))).Methods("POST")
+	protected.HandleFunc("/user/settings", userSettingsHandler.Get).Methods("GET")
+	protected.HandleFunc("/user/settings", userSettingsHandler.Update).Methods("PUT")
+	protected.HandleFunc("/user/notifications", notificationHandler.Get).Methods("GET")
+	protected.HandleFunc("/user/notifications", notificationHandler.Update).Methods("PUT")
+	protected.HandleFunc("/user/integrations", integrationHandler.List).Methods("GET")
+	protected.HandleFunc("/user/integrations", integrationHandler.Create).Methods("POST")
+	protected.HandleFunc("/user/integrations/{id}", integrationHandler.Delete).Methods("DELETE")
+	protected.HandleFunc("/user/integrations/{id}/rotate-secret", integrationHandler.RotateSecret).Methods("POST")
+	protected.Handle("/user/integrations/{id}/test", rateLimiter.Limit(middleware.PolicyWebhooks)(http.HandlerFunc(integrationHandler.SendTest))).Methods("POST")
+	protected.HandleFunc("/user/integrations/{id}/deliveries", integrationHandler.ListDeliveries).Methods("GET")
+	protected.Handle("/user/integrations/{id}/deliveries/{delivery}/redeliver", rateLimiter.Limit(middleware.PolicyWebhooks)(http.HandlerFunc(integrationHandler.Redeliver))).Methods("POST")
 
 	// Protected paste routes
-	protected.HandleFunc("/paste/{id}", pasteHandler.Delete).Methods("DELETE")
+	protected.Handle("/paste/{id}/attachments/{name}", rateLimiter.LimitPasteCreation(http.HandlerFunc(pasteHandler.UploadAttachment))).Methods("PUT")
+	protected.HandleFunc("/paste/{id}/attachments/{name}", pasteHandler.DeleteAttachment).Methods("DELETE")
+	protected.Handle("/paste/{id}/share/{integration}", rateLimiter.Limit(middleware.PolicyWebhooks)(http.HandlerFunc(integrationHandler.Share))).Methods("POST")
 	// protected.HandleFunc("/paste/{id}", pasteHandler.Update).Methods("PATCH") // TODO
 
+	// Admin routes (require the admin role)
+	admin := protected.PathPrefix("/admin").Subrouter()
+	admin.Use(authMiddleware.RequireAdmin)
+	admin.HandleFunc("/pastes/search", adminHandler.SearchPastes).Methods("GET")
+	admin.HandleFunc("/pastes/duplicates", adminHandler.FindDuplicates).Methods("POST")
+	admin.HandleFunc("/users/{username}/legal-export", legalExportHandler.Export).Methods("POST")
+	admin.HandleFunc("/storage", storageHandler.GetInstanceStorage).Methods("GET")
+	admin.HandleFunc("/stats/daily", statsHandler.GetDailyStats).Methods("GET")
+	admin.HandleFunc("/jobs/dead", adminHandler.ListDeadJobs).Methods("GET")
+	admin.HandleFunc("/jobs/{id}/retry", adminHandler.RetryJob).Methods("POST")
+	admin.HandleFunc("/service-accounts", serviceAccountHandler.List).Methods("GET")
+	admin.HandleFunc("/service-accounts", serviceAccountHandler.Create).Methods("POST")
+	admin.HandleFunc("/service-accounts/{id}", serviceAccountHandler.Disable).Methods("DELETE")
+	admin.HandleFunc("/content-policies", contentPolicyHandler.List).Methods("GET")
+	admin.HandleFunc("/content-policies/{role}", contentPolicyHandler.Set).Methods("PUT")
+	admin.HandleFunc("/content-policies/{role}", contentPolicyHandler.Reset).Methods("DELETE")
+	admin.HandleFunc("/integrity", integrityHandler.Check).Methods("GET")
+	admin.HandleFunc("/integrity/repair", integrityHandler.Repair).Methods("POST")
+	admin.HandleFunc("/read-only", readOnlyHandler.Get).Methods("GET")
+	admin.HandleFunc("/read-only", readOnlyHandler.Set).Methods("PUT")
+	admin.HandleFunc("/scheduler", schedulerHandler.List).Methods("GET")
+	admin.HandleFunc("/scheduler/{name}/run", schedulerHandler.Run).Methods("POST")
+	admin.HandleFunc("/scheduler/{name}/pause", schedulerHandler.Pause).Methods("POST")
+	admin.HandleFunc("/scheduler/{name}/resume", schedulerHandler.Resume).Methods("POST")
+
+	// Optional SCIM 2.0 provisioning for identity providers, authenticated by
+	// the SCIM token
+	if cfg.SCIMToken != "" {
+		scim := router.PathPrefix("/scim/v2").Subrouter()
+		scim.HandleFunc("/Users", scimHandler.ListUsers).Methods("GET")
+		scim.HandleFunc("/Users", scimHandler.CreateUser).Methods("POST")
+		scim.HandleFunc("/Users/{id}", scimHandler.GetUser).Methods("GET")
+		scim.HandleFunc("/Users/{id}", scimHandler.ReplaceUser).Methods("PUT")
+		scim.HandleFunc("/Users/{id}", scimHandler.PatchUser).Methods("PATCH")
+		scim.HandleFunc("/Users/{id}", scimHandler.DeleteUser).Methods("DELETE")
+	}
+
+	// Optional S3-compatible gateway for raw pastes: /s3/{username}/{paste id}
+	if cfg.S3GatewaySecret != "" {
+		router.Handle("/s3/{bucket}/{key}", failFast(rateLimiter.LimitPasteRetrieval(http.HandlerFunc(s3Handler.GetObject)))).Methods("GET", "HEAD")
+	}
+
 	// Serve static files (React frontend) with SPA fallback
 	staticDir := "./frontend/dist/"
 	if _, err := os.Stat(staticDir); err == nil {
 		setupStaticRoutes(router, staticDir)
 	}
 
-	// Wrap router with CORS
-	handler := middleware.CORSHandler(router, corsMiddleware)
-
-	// Start server
+	// Wrap router with CORS, refusing overlong request lines before routing,
+	// guard every request body against slow clients, and take client
+	// addresses from trusted proxies before anything uses them
+	trustedProxies, err := middleware.ParseTrustedProxies(cfg.TrustedProxies)
+	if err != nil {
+		log.Fatalf("Invalid TRUSTED_PROXIES: %v", err)
+	}
+	handler := middleware.LimitRequestLine(cfg.MaxRequestLineBytes)(middleware.CORSHandler(router, corsMiddleware))
+	handler = middleware.RealIP(trustedProxies)(slowBody.Protect(handler))
+
+	// Start server on an inherited systemd socket, a Unix socket or the TCP port
+	ln, where, err := listener.Listen(listener.Options{
+		Port:       cfg.Port,
+		SocketPath: cfg.UnixSocketPath,
+		SocketMode: cfg.UnixSocketMode,
+	})
+	if err != nil {
+		log.Fatalf("Failed to listen: %v", err)
+	}
 	server := &http.Server{
-		Addr:    ":" + cfg.Port,
-		Handler: handler,
+		Handler:           handler,
+		ReadHeaderTimeout: time.Duration(cfg.ReadHeaderTimeoutSeconds) * time.Second,
+		IdleTimeout:       time.Duration(cfg.IdleTimeoutSeconds) * time.Second,
+		MaxHeaderBytes:    cfg.MaxHeaderBytes,
+	}
+
+	// Optional SSH paste submission server (ssh paste@host < file)
+	var sshListener net.Listener
+	if cfg.SSHPort != "" && cfg.MirrorUpstreamURL != "" {
+		log.Println("SSH paste server disabled: read-only mirrors do not accept pastes")
+	} else if cfg.SSHPort != "" {
+		hostKey, err := sshpaste.LoadHostKey(cfg.SSHHostKeyPath)
+		if err != nil {
+			log.Fatalf("Failed to load SSH host key: %v", err)
+		}
+		sshListener, err = net.Listen("tcp", ":"+cfg.SSHPort)
+		if err != nil {
+			log.Fatalf("Failed to listen for SSH: %v", err)
+		}
+		sshBackend := handlers.NewSSHPasteBackend(sshKeyRepo, pasteRepo, idGenerator, validator)
+		sshBackend.SetReadOnlyMode(readOnlyMode)
+		sshBackend.SetPasteCreator(pasteCreator)
+		sshServer := sshpaste.NewServer(hostKey, sshBackend)
+		go func() {
+			if err := sshServer.Serve(sshListener); err != nil {
+				log.Printf("SSH paste server stopped: %v", err)
+			}
+		}()
+		log.Printf("SSH paste server listening on port %s", cfg.SSHPort)
+	}
+
+	// Optional internal listener for Prometheus metrics
+	var metricsServer *http.Server
+	if cfg.MetricsAddr != "" {
+		metricsMux := http.NewServeMux()
+		metricsMux.Handle("GET /metrics", metrics.Handler())
+		metricsServer = &http.Server{
+			Addr:              cfg.MetricsAddr,
+			Handler:           metricsMux,
+			ReadHeaderTimeout: time.Duration(cfg.ReadHeaderTimeoutSeconds) * time.Second,
+		}
+		go func() {
+			if err := metricsServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				log.Printf("Metrics server stopped: %v", err)
+			}
+		}()
+		log.Printf("Metrics available on %s/metrics", cfg.MetricsAddr)
+	} else if cfg.MetricsToken == "" {
+		log.Println("Metrics are not exposed; set METRICS_ADDR or METRICS_TOKEN to serve them")
 	}
 
 	// Graceful shutdown
@@ -166,15 +877,23 @@ func main() {
 		<-sigChan
 
 		log.Println("Shutting down server...")
+		if sshListener != nil {
+			sshListener.Close()
+		}
+		if metricsServer != nil {
+			metricsServer.Close()
+		}
 		if err := server.Close(); err != nil {
 			log.Printf("Error shutting down server: %v", err)
 		}
 	}()
 
-	log.Printf("Server starting on port %s", cfg.Port)
-	log.Printf("Health check available at: http://localhost:%s/api/health", cfg.Port)
+	log.Printf("Server starting on %s", where)
+	if strings.HasPrefix(where, "port ") {
+		log.Printf("Health check available at: http://localhost:%s/api/health", cfg.Port)
+	}
 
-	if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+	if err := server.Serve(ln); err != nil && err != http.ErrServerClosed {
 		log.Fatalf("Server failed to start: %v", err)
 	}
 
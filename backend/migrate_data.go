@@ -0,0 +1,98 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"flag"
+	"log"
+	"os"
+	"os/signal"
+	"slices"
+	"syscall"
+
+	"github.com/LonleySailor/privatepaste/backend/internal/config"
+	"github.com/LonleySailor/privatepaste/backend/internal/database"
+)
+
+// runMigrateData implements the migrate-data command, which copies the
+// SQLite database into another database and verifies the copy:
+//
+//	READ_ONLY=true ./pastevault-server &
+//	MIGRATE_TO_DSN=postgres://... ./pastevault-server migrate-data
+//
+// An interrupted run is resumed by running it again. Postgres destinations
+// get the tables of the current schema created if they are missing.
+func runMigrateData(cfg *config.Config, args []string) {
+	flags := flag.NewFlagSet("migrate-data", flag.ExitOnError)
+	from := flags.String("from", cfg.DatabasePath, "SQLite database to copy")
+	toDriver := flags.String("to-driver", cfg.MigrateToDriver, "database/sql driver of the destination: sqlite3 or postgres")
+	to := flags.String("to", cfg.MigrateToDSN, "destination data source name; defaults to MIGRATE_TO_DSN")
+	batch := flags.Int("batch", database.DefaultMigrationBatch, "rows copied per transaction")
+	verifyOnly := flags.Bool("verify-only", false, "only compare row counts and content hashes")
+	flags.Parse(args)
+
+	if *to == "" {
+		log.Fatal("No destination given; set MIGRATE_TO_DSN or -to")
+	}
+	if !slices.Contains(sql.Drivers(), *toDriver) {
+		log.Fatalf("Database driver %q is not linked into this build", *toDriver)
+	}
+
+	src, err := sql.Open(database.DriverSQLite, "file:"+*from+"?mode=ro")
+	if err != nil {
+		log.Fatalf("Failed to open source database: %v", err)
+	}
+	defer src.Close()
+	dst, err := sql.Open(*toDriver, *to)
+	if err != nil {
+		log.Fatalf("Failed to open destination database: %v", err)
+	}
+	defer dst.Close()
+	if *toDriver == database.DriverSQLite {
+		dst.SetMaxOpenConns(1)
+	}
+	for name, db := range map[string]*sql.DB{"source": src, "destination": dst} {
+		if err := db.Ping(); err != nil {
+			log.Fatalf("Failed to connect to the %s database: %v", name, err)
+		}
+	}
+
+	migration, err := database.NewDataMigration(src, *toDriver, dst, *batch, log.Printf)
+	if err != nil {
+		log.Fatalf("Failed to start migration: %v", err)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	if !*verifyOnly {
+		log.Printf("Copying %s to %s", *from, *toDriver)
+		if err := migration.Copy(ctx); err != nil {
+			log.Fatalf("Migration stopped, run again to resume: %v", err)
+		}
+	}
+
+	checks, err := migration.Verify(ctx)
+	if err != nil {
+		log.Fatalf("Failed to verify migration: %v", err)
+	}
+	failed := 0
+	for _, check := range checks {
+		if !check.OK() {
+			failed++
+			log.Printf("MISMATCH %s: %d rows (hash %.12s) in source, %d rows (hash %.12s) in destination",
+				check.Table, check.SourceRows, check.SourceHash, check.DestRows, check.DestHash)
+		}
+	}
+	if failed > 0 {
+		log.Printf("Verification failed for %d of %d tables", failed, len(checks))
+		os.Exit(1)
+	}
+
+	if !*verifyOnly {
+		if err := migration.Finish(ctx); err != nil {
+			log.Fatalf("Failed to clean up migration progress: %v", err)
+		}
+	}
+	log.Printf("Verified %d tables: row counts and content hashes match", len(checks))
+}
@@ -0,0 +1,161 @@
+// Package chatshare formats paste share messages for chat platform incoming
+// webhooks: Slack, Discord and Matrix (through a hookshot generic webhook).
+package chatshare
+
+import (
+	"encoding/json"
+	"errors"
+	"net"
+	"net/url"
+	"strings"
+
+	"github.com/LonleySailor/privatepaste/backend/pkg/utils"
+)
+
+// Supported platforms
+const (
+	PlatformSlack   = "slack"
+	PlatformDiscord = "discord"
+	PlatformMatrix  = "matrix"
+)
+
+var (
+	// ErrUnknownPlatform is returned for a platform that is not supported
+	ErrUnknownPlatform = errors.New("platform must be slack, discord or matrix")
+
+	// ErrInvalidWebhookURL is returned when a URL is not an incoming webhook
+	// for its platform
+	ErrInvalidWebhookURL = errors.New("not a valid incoming webhook URL for the platform")
+)
+
+// Message describes a shared paste
+type Message struct {
+	Title    string
+	Language string
+	URL      string
+	SharedBy string
+}
+
+// ValidateWebhookURL checks that a webhook URL uses HTTPS and, for Slack and
+// Discord, points at the platform's own webhook endpoint on the default
+// port. Matrix homeservers are self-hosted, so any HTTPS URL is accepted
+// except for local names and non-public IP addresses; names resolving to
+// those are refused when the delivery connects.
+func ValidateWebhookURL(platform, webhookURL string) error {
+	u, err := url.Parse(webhookURL)
+	if err != nil || u.Scheme != "https" || u.Host == "" || u.User != nil {
+		return ErrInvalidWebhookURL
+	}
+
+	host := strings.ToLower(u.Hostname())
+	switch platform {
+	case PlatformSlack:
+		if host != "hooks.slack.com" || u.Port() != "" || !strings.HasPrefix(u.Path, "/services/") {
+			return ErrInvalidWebhookURL
+		}
+	case PlatformDiscord:
+		if (host != "discord.com" && host != "discordapp.com") || u.Port() != "" || !strings.HasPrefix(u.Path, "/api/webhooks/") {
+			return ErrInvalidWebhookURL
+		}
+	case PlatformMatrix:
+		if ip := net.ParseIP(host); ip != nil && !utils.IsPublicIP(ip) {
+			return ErrInvalidWebhookURL
+		}
+		if host == "localhost" || strings.HasSuffix(host, ".localhost") || strings.HasSuffix(host, ".local") || strings.HasSuffix(host, ".internal") {
+			return ErrInvalidWebhookURL
+		}
+	default:
+		return ErrUnknownPlatform
+	}
+	return nil
+}
+
+// summary is the plain text line shared on every platform
+func (m Message) summary() string {
+	text := m.Title
+	if m.Language != "" {
+		text += " (" + m.Language + ")"
+	}
+	if m.SharedBy != "" {
+		text += " shared by " + m.SharedBy
+	}
+	return text
+}
+
+// Payload returns the JSON body to post to the platform's webhook
+func Payload(platform string, m Message) ([]byte, error) {
+	switch platform {
+	case PlatformSlack:
+		// mrkdwn link syntax: <url|text>
+		return json.Marshal(map[string]interface{}{
+			"text": m.summary() + ": " + m.URL,
+			"blocks": []map[string]interface{}{{
+				"type": "section",
+				"text": map[string]string{
+					"type": "mrkdwn",
+					"text": "*<" + m.URL + "|" + slackEscape(m.Title) + ">*\n" + slackEscape(strings.TrimSpace(strings.TrimPrefix(m.summary(), m.Title))),
+				},
+			}},
+		})
+	case PlatformDiscord:
+		embed := map[string]interface{}{
+			"title": truncate(m.Title, 256),
+			"url":   m.URL,
+		}
+		if m.Language != "" {
+			embed["fields"] = []map[string]interface{}{{"name": "Language", "value": m.Language, "inline": true}}
+		}
+		if m.SharedBy != "" {
+			embed["footer"] = map[string]string{"text": "Shared by " + m.SharedBy}
+		}
+		return json.Marshal(map[string]interface{}{
+			"embeds":           []interface{}{embed},
+			"allowed_mentions": map[string]interface{}{"parse": []string{}},
+		})
+	case PlatformMatrix:
+		return json.Marshal(map[string]string{
+			"text": m.summary() + ": " + m.URL,
+			"html": `<a href="` + htmlEscape(m.URL) + `">` + htmlEscape(m.Title) + "</a>" + htmlEscape(strings.TrimPrefix(m.summary(), m.Title)),
+		})
+	}
+	return nil, ErrUnknownPlatform
+}
+
+// TextPayload returns the JSON body posting a plain text notice, such as a
+// digest, to the platform's webhook. Mentions are not expanded.
+func TextPayload(platform, text string) ([]byte, error) {
+	switch platform {
+	case PlatformSlack:
+		return json.Marshal(map[string]string{"text": slackEscape(text)})
+	case PlatformDiscord:
+		return json.Marshal(map[string]interface{}{
+			"content":          truncate(text, 2000),
+			"allowed_mentions": map[string]interface{}{"parse": []string{}},
+		})
+	case PlatformMatrix:
+		return json.Marshal(map[string]string{
+			"text": text,
+			"html": strings.ReplaceAll(htmlEscape(text), "\n", "<br>"),
+		})
+	}
+	return nil, ErrUnknownPlatform
+}
+
+// slackEscape escapes the characters Slack treats as control sequences
+func slackEscape(s string) string {
+	return strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;").Replace(s)
+}
+
+// htmlEscape escapes text for the Matrix HTML body
+func htmlEscape(s string) string {
+	return strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;", `"`, "&quot;").Replace(s)
+}
+
+// truncate shortens s to at most n runes
+func truncate(s string, n int) string {
+	runes := []rune(s)
+	if len(runes) <= n {
+		return s
+	}
+	return string(runes[:n-1]) + "…"
+}
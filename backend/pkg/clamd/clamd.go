@@ -0,0 +1,89 @@
+package clamd
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+)
+
+// Limits applied to scans
+const (
+	scanTimeout = 30 * time.Second
+	chunkSize   = 64 << 10
+)
+
+// Client scans data for malware with a ClamAV daemon using its INSTREAM
+// command
+type Client struct {
+	network string
+	address string
+}
+
+// NewClient creates a client for the clamd listening at address, either
+// host:port or the path of a unix socket
+func NewClient(address string) *Client {
+	network := "tcp"
+	if strings.HasPrefix(address, "/") {
+		network = "unix"
+	}
+	return &Client{network: network, address: address}
+}
+
+// Scan streams data to clamd, returning the name of the threat found in it
+// or "" if it is clean
+func (c *Client) Scan(ctx context.Context, data []byte) (string, error) {
+	dialer := net.Dialer{Timeout: scanTimeout}
+	conn, err := dialer.DialContext(ctx, c.network, c.address)
+	if err != nil {
+		return "", fmt.Errorf("clamd unavailable: %w", err)
+	}
+	defer conn.Close()
+
+	deadline := time.Now().Add(scanTimeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	conn.SetDeadline(deadline)
+
+	// Each chunk is prefixed with its length; an empty chunk ends the stream
+	if _, err := conn.Write([]byte("zINSTREAM\x00")); err != nil {
+		return "", fmt.Errorf("clamd unavailable: %w", err)
+	}
+	for len(data) > 0 {
+		chunk := data[:min(len(data), chunkSize)]
+		data = data[len(chunk):]
+		if err := binary.Write(conn, binary.BigEndian, uint32(len(chunk))); err != nil {
+			return "", fmt.Errorf("clamd unavailable: %w", err)
+		}
+		if _, err := conn.Write(chunk); err != nil {
+			return "", fmt.Errorf("clamd unavailable: %w", err)
+		}
+	}
+	if err := binary.Write(conn, binary.BigEndian, uint32(0)); err != nil {
+		return "", fmt.Errorf("clamd unavailable: %w", err)
+	}
+
+	reply, err := bufio.NewReader(conn).ReadString(0)
+	if err != nil {
+		return "", fmt.Errorf("failed to read clamd reply: %w", err)
+	}
+	return parseReply(strings.TrimRight(reply, "\x00\n"))
+}
+
+// parseReply interprets a scan reply such as "stream: OK" or
+// "stream: Eicar-Signature FOUND"
+func parseReply(reply string) (string, error) {
+	result := strings.TrimPrefix(reply, "stream: ")
+	switch {
+	case result == "OK":
+		return "", nil
+	case strings.HasSuffix(result, " FOUND"):
+		return strings.TrimSuffix(result, " FOUND"), nil
+	default:
+		return "", fmt.Errorf("clamd scan failed: %s", reply)
+	}
+}
@@ -0,0 +1,79 @@
+package clamd
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"io"
+	"net"
+	"testing"
+)
+
+// serveOnce accepts one INSTREAM scan and replies as clamd would, finding
+// a threat in any stream containing "EICAR"
+func serveOnce(t *testing.T, listener net.Listener) {
+	conn, err := listener.Accept()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	r := bufio.NewReader(conn)
+	if command, err := r.ReadString(0); err != nil || command != "zINSTREAM\x00" {
+		t.Errorf("Unexpected command %q", command)
+		return
+	}
+	var stream bytes.Buffer
+	for {
+		var size uint32
+		if err := binary.Read(r, binary.BigEndian, &size); err != nil {
+			t.Errorf("Failed to read chunk size: %v", err)
+			return
+		}
+		if size == 0 {
+			break
+		}
+		if _, err := io.CopyN(&stream, r, int64(size)); err != nil {
+			t.Errorf("Failed to read chunk: %v", err)
+			return
+		}
+	}
+	if bytes.Contains(stream.Bytes(), []byte("EICAR")) {
+		conn.Write([]byte("stream: Eicar-Test-Signature FOUND\x00"))
+	} else {
+		conn.Write([]byte("stream: OK\x00"))
+	}
+}
+
+func TestScan(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	defer listener.Close()
+
+	client := NewClient(listener.Addr().String())
+	for _, tc := range []struct {
+		data   []byte
+		threat string
+	}{
+		{bytes.Repeat([]byte("clean "), 20000), ""},
+		{[]byte("X5O!P%@AP[4\\PZX54(P^)7CC)7}$EICAR-STANDARD-ANTIVIRUS-TEST-FILE!$H+H*"), "Eicar-Test-Signature"},
+	} {
+		go serveOnce(t, listener)
+		threat, err := client.Scan(context.Background(), tc.data)
+		if err != nil || threat != tc.threat {
+			t.Errorf("Expected threat %q, got %q (%v)", tc.threat, threat, err)
+		}
+	}
+}
+
+func TestParseReply(t *testing.T) {
+	if _, err := parseReply("stream: Size limit exceeded. ERROR"); err == nil {
+		t.Error("Expected an error reply to fail the scan")
+	}
+	if _, err := NewClient("127.0.0.1:1").Scan(context.Background(), []byte("x")); err == nil {
+		t.Error("Expected an unreachable daemon to fail the scan")
+	}
+}
@@ -0,0 +1,52 @@
+package geoip
+
+import (
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/oschwald/maxminddb-golang"
+)
+
+// Reader resolves IP addresses to ISO 3166-1 alpha-2 country codes using a
+// MaxMind GeoIP2/GeoLite2 Country or City database
+type Reader struct {
+	db *maxminddb.Reader
+}
+
+// countryRecord holds the fields read from the database
+type countryRecord struct {
+	Country struct {
+		ISOCode string `maxminddb:"iso_code"`
+	} `maxminddb:"country"`
+	RegisteredCountry struct {
+		ISOCode string `maxminddb:"iso_code"`
+	} `maxminddb:"registered_country"`
+}
+
+// Open opens the MaxMind database at path
+func Open(path string) (*Reader, error) {
+	db, err := maxminddb.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open GeoIP database: %w", err)
+	}
+	return &Reader{db: db}, nil
+}
+
+// Country returns the country code for ip, or an empty string if the address
+// is not in the database
+func (r *Reader) Country(ip net.IP) (string, error) {
+	var record countryRecord
+	if err := r.db.Lookup(ip, &record); err != nil {
+		return "", err
+	}
+	if record.Country.ISOCode != "" {
+		return strings.ToUpper(record.Country.ISOCode), nil
+	}
+	return strings.ToUpper(record.RegisteredCountry.ISOCode), nil
+}
+
+// Close closes the underlying database
+func (r *Reader) Close() error {
+	return r.db.Close()
+}
@@ -0,0 +1,120 @@
+// Package gist creates GitHub gists on behalf of users with their own
+// access tokens.
+package gist
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// GitHubAPI is the base URL of the public GitHub REST API
+const GitHubAPI = "https://api.github.com"
+
+// Limits applied to gist requests
+const (
+	requestTimeout  = 30 * time.Second
+	maxResponseSize = 1 << 20
+)
+
+var (
+	// ErrUnauthorized is returned when GitHub rejects the token or it lacks
+	// the gist scope
+	ErrUnauthorized = errors.New("GitHub rejected the token; it needs the gist scope")
+
+	// ErrRejected is returned when GitHub refuses the gist itself, for
+	// example because a file is too large
+	ErrRejected = errors.New("GitHub refused to create the gist")
+)
+
+// Gist is a gist to create: file names mapped to their content
+type Gist struct {
+	Description string
+	Public      bool
+	Files       map[string]string
+}
+
+// Created describes a newly created gist
+type Created struct {
+	ID  string `json:"id"`
+	URL string `json:"html_url"`
+}
+
+// Client creates gists through the GitHub REST API
+type Client struct {
+	baseURL string
+	client  *http.Client
+}
+
+// NewClient creates a client for the GitHub API at baseURL, normally GitHubAPI
+func NewClient(baseURL string) *Client {
+	return &Client{
+		baseURL: strings.TrimRight(baseURL, "/"),
+		client:  &http.Client{Timeout: requestTimeout},
+	}
+}
+
+// createRequest is the GitHub create gist request body
+type createRequest struct {
+	Description string                `json:"description,omitempty"`
+	Public      bool                  `json:"public"`
+	Files       map[string]createFile `json:"files"`
+}
+
+type createFile struct {
+	Content string `json:"content"`
+}
+
+// Create creates a gist owned by the user the token belongs to
+func (c *Client) Create(ctx context.Context, token string, gist Gist) (*Created, error) {
+	files := make(map[string]createFile, len(gist.Files))
+	for name, content := range gist.Files {
+		files[name] = createFile{Content: content}
+	}
+	body, err := json.Marshal(createRequest{Description: gist.Description, Public: gist.Public, Files: files})
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseURL+"/gists", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-GitHub-Api-Version", "2022-11-28")
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("GitHub unavailable: %w", err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseSize))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read GitHub response: %w", err)
+	}
+
+	switch {
+	case resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden || resp.StatusCode == http.StatusNotFound:
+		// GitHub answers 404 rather than 403 when a token lacks the scope
+		return nil, ErrUnauthorized
+	case resp.StatusCode == http.StatusUnprocessableEntity:
+		return nil, ErrRejected
+	case resp.StatusCode != http.StatusCreated:
+		return nil, fmt.Errorf("GitHub returned status %d", resp.StatusCode)
+	}
+
+	var created Created
+	if err := json.Unmarshal(data, &created); err != nil || created.URL == "" {
+		return nil, fmt.Errorf("invalid GitHub response: %s", data)
+	}
+	return &created, nil
+}
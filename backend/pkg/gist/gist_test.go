@@ -0,0 +1,40 @@
+package gist
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCreate(t *testing.T) {
+	var received createRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/gists" || r.Header.Get("Authorization") != "Bearer good-token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		json.NewDecoder(r.Body).Decode(&received)
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"id":"abc123","html_url":"https://gist.github.com/abc123"}`))
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL)
+	created, err := client.Create(context.Background(), "good-token", Gist{
+		Description: "notes",
+		Files:       map[string]string{"main.go": "package main", "README.md": "# hi"},
+	})
+	if err != nil || created.URL != "https://gist.github.com/abc123" {
+		t.Fatalf("Expected the gist to be created, got %+v (%v)", created, err)
+	}
+	if received.Public || received.Description != "notes" || received.Files["main.go"].Content != "package main" || len(received.Files) != 2 {
+		t.Errorf("Unexpected request: %+v", received)
+	}
+
+	if _, err := client.Create(context.Background(), "bad-token", Gist{Files: map[string]string{"a.txt": "a"}}); !errors.Is(err, ErrUnauthorized) {
+		t.Errorf("Expected a rejected token to be reported, got %v", err)
+	}
+}
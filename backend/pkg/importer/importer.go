@@ -0,0 +1,314 @@
+// Package importer reads pastes exported from other paste services:
+// pastebin.com and Ghostbin archives, and raw paste URLs on those sites.
+package importer
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+)
+
+// Limits applied while reading archives and fetching URLs
+const (
+	MaxPasteSize  = 1048576 // Same limit as pastes created through the API
+	MaxPastes     = 500     // Pastes read from a single archive
+	fetchTimeout  = 15 * time.Second
+	pastebinIndex = "pastes.xml"
+)
+
+// Visibilities mapped from the source service, matching the paste model
+const (
+	VisibilityPublic   = "public"
+	VisibilityUnlisted = "unlisted"
+	VisibilityPrivate  = "private"
+)
+
+var (
+	// ErrUnsupportedURL is returned for URLs that are not a pastebin.com or
+	// Ghostbin paste
+	ErrUnsupportedURL = errors.New("not a pastebin.com or Ghostbin paste URL")
+
+	// ErrTooLarge is returned when a paste exceeds MaxPasteSize
+	ErrTooLarge = errors.New("paste exceeds maximum size")
+)
+
+// Paste is a paste read from another service, ready to be stored
+type Paste struct {
+	Source     string // Archive entry name or URL the paste came from
+	Title      string
+	Content    string
+	Language   string
+	Visibility string
+	ExpiresAt  *time.Time
+}
+
+// pastebinEntry is one <paste> element of the pastebin.com API paste list,
+// which is included in an archive as pastes.xml
+type pastebinEntry struct {
+	Key        string `xml:"paste_key"`
+	Title      string `xml:"paste_title"`
+	ExpireDate int64  `xml:"paste_expire_date"`
+	Private    int    `xml:"paste_private"`
+	Format     string `xml:"paste_format_short"`
+}
+
+// pastebinFormats maps pastebin.com syntax names that differ from ours
+var pastebinFormats = map[string]string{
+	"text":        "",
+	"html5":       "html",
+	"html4strict": "html",
+	"cpp-qt":      "cpp",
+	"email":       "",
+	"postgresql":  "sql",
+	"mysql":       "sql",
+}
+
+// extensionLanguages infers a language from a file extension for archive
+// entries without pastebin.com metadata
+var extensionLanguages = map[string]string{
+	".go": "go", ".js": "javascript", ".ts": "typescript", ".py": "python", ".rs": "rust",
+	".java": "java", ".rb": "ruby", ".sh": "bash", ".sql": "sql", ".json": "json",
+	".yaml": "yaml", ".yml": "yaml", ".md": "markdown", ".html": "html", ".css": "css",
+	".c": "c", ".cpp": "cpp", ".diff": "diff", ".patch": "diff", ".php": "php",
+}
+
+// LanguageForFilename infers a paste language from a file name's extension,
+// returning "" when it is not recognised
+func LanguageForFilename(name string) string {
+	return extensionLanguages[strings.ToLower(path.Ext(name))]
+}
+
+// PastebinLanguage maps a pastebin.com syntax name to a PasteVault language
+func PastebinLanguage(format string) string {
+	format = strings.ToLower(strings.TrimSpace(format))
+	if lang, ok := pastebinFormats[format]; ok {
+		return lang
+	}
+	return format
+}
+
+// PastebinVisibility maps pastebin.com's paste_private value
+func PastebinVisibility(private int) string {
+	switch private {
+	case 1:
+		return VisibilityUnlisted
+	case 2:
+		return VisibilityPrivate
+	default:
+		return VisibilityPublic
+	}
+}
+
+// ReadArchive reads the pastes in a zip archive. Each file is one paste. When
+// the archive contains a pastebin.com paste list (pastes.xml), files named
+// after a paste key take their title, syntax, visibility and expiry from it;
+// other files are public, never expire and get a language from their
+// extension. Pastes that have already expired are skipped.
+func ReadArchive(data []byte, now time.Time) ([]Paste, error) {
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, fmt.Errorf("invalid archive: %w", err)
+	}
+
+	meta := make(map[string]pastebinEntry)
+	for _, f := range zr.File {
+		if path.Base(f.Name) != pastebinIndex {
+			continue
+		}
+		raw, err := readEntry(f)
+		if err != nil {
+			return nil, err
+		}
+		if meta, err = parsePastebinList(raw); err != nil {
+			return nil, err
+		}
+	}
+
+	var pastes []Paste
+	for _, f := range zr.File {
+		if f.FileInfo().IsDir() || path.Base(f.Name) == pastebinIndex {
+			continue
+		}
+		if len(pastes) == MaxPastes {
+			return nil, fmt.Errorf("archive contains more than %d pastes", MaxPastes)
+		}
+
+		content, err := readEntry(f)
+		if err != nil {
+			return nil, err
+		}
+
+		base := path.Base(f.Name)
+		ext := path.Ext(base)
+		paste := Paste{
+			Source:     f.Name,
+			Title:      strings.TrimSuffix(base, ext),
+			Content:    string(content),
+			Language:   LanguageForFilename(base),
+			Visibility: VisibilityPublic,
+		}
+		if entry, ok := meta[strings.TrimSuffix(base, ext)]; ok {
+			if entry.ExpireDate > 0 {
+				expiresAt := time.Unix(entry.ExpireDate, 0)
+				if !expiresAt.After(now) {
+					continue
+				}
+				paste.ExpiresAt = &expiresAt
+			}
+			if entry.Title != "" {
+				paste.Title = entry.Title
+			}
+			paste.Language = PastebinLanguage(entry.Format)
+			paste.Visibility = PastebinVisibility(entry.Private)
+		}
+		pastes = append(pastes, paste)
+	}
+	return pastes, nil
+}
+
+// readEntry reads an archive file, enforcing MaxPasteSize
+func readEntry(f *zip.File) ([]byte, error) {
+	rc, err := f.Open()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", f.Name, err)
+	}
+	defer rc.Close()
+
+	data, err := io.ReadAll(io.LimitReader(rc, MaxPasteSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", f.Name, err)
+	}
+	if len(data) > MaxPasteSize {
+		return nil, fmt.Errorf("%s: %w", f.Name, ErrTooLarge)
+	}
+	return data, nil
+}
+
+// parsePastebinList parses the pastebin.com API list response, which is a
+// sequence of <paste> elements without a root element
+func parsePastebinList(data []byte) (map[string]pastebinEntry, error) {
+	dec := xml.NewDecoder(bytes.NewReader(data))
+	entries := make(map[string]pastebinEntry)
+	for {
+		var entry pastebinEntry
+		err := dec.Decode(&entry)
+		if err == io.EOF {
+			return entries, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s: %w", pastebinIndex, err)
+		}
+		entries[entry.Key] = entry
+	}
+}
+
+// rawURL returns the raw content URL for a pastebin.com or Ghostbin paste
+// URL. Only these hosts are fetched so the importer cannot be used to make
+// requests to arbitrary servers.
+func rawURL(pasteURL string) (string, string, error) {
+	u, err := url.Parse(pasteURL)
+	if err != nil || (u.Scheme != "https" && u.Scheme != "http") {
+		return "", "", ErrUnsupportedURL
+	}
+
+	parts := strings.FieldsFunc(u.Path, func(r rune) bool { return r == '/' })
+	switch siteHost(u.Hostname()) {
+	case "pastebin.com":
+		// pastebin.com/<key> or pastebin.com/raw/<key>
+		if len(parts) == 2 && parts[0] == "raw" {
+			parts = parts[1:]
+		}
+		if len(parts) == 1 {
+			return "https://pastebin.com/raw/" + parts[0], parts[0], nil
+		}
+	case "ghostbin.com", "ghostbin.co":
+		// ghostbin.com/paste/<id> or ghostbin.com/paste/<id>/raw
+		if len(parts) == 3 && parts[2] == "raw" {
+			parts = parts[:2]
+		}
+		if len(parts) == 2 && parts[0] == "paste" {
+			return "https://" + u.Hostname() + "/paste/" + parts[1] + "/raw", parts[1], nil
+		}
+	}
+	return "", "", ErrUnsupportedURL
+}
+
+// siteHost normalizes a host name for comparison with the supported sites
+func siteHost(host string) string {
+	return strings.TrimPrefix(strings.ToLower(host), "www.")
+}
+
+// allowedHost reports whether a host belongs to a supported paste site
+func allowedHost(host string) bool {
+	switch siteHost(host) {
+	case "pastebin.com", "ghostbin.com", "ghostbin.co":
+		return true
+	}
+	return false
+}
+
+// Fetcher downloads pastes from their raw URLs
+type Fetcher struct {
+	client *http.Client
+}
+
+// NewFetcher creates a fetcher. A nil client uses one with a short timeout
+// that does not follow redirects off the paste sites.
+func NewFetcher(client *http.Client) *Fetcher {
+	if client == nil {
+		client = &http.Client{
+			Timeout: fetchTimeout,
+			CheckRedirect: func(req *http.Request, via []*http.Request) error {
+				if !allowedHost(req.URL.Hostname()) {
+					return ErrUnsupportedURL
+				}
+				if len(via) >= 3 {
+					return errors.New("too many redirects")
+				}
+				return nil
+			},
+		}
+	}
+	return &Fetcher{client: client}
+}
+
+// Fetch downloads a paste by its pastebin.com or Ghostbin URL. Raw URLs carry
+// no metadata, so the paste is public and never expires.
+func (f *Fetcher) Fetch(pasteURL string) (*Paste, error) {
+	raw, key, err := rawURL(pasteURL)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := f.client.Get(raw)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetching %s: status %d", raw, resp.StatusCode)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, MaxPasteSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > MaxPasteSize {
+		return nil, ErrTooLarge
+	}
+
+	return &Paste{
+		Source:     pasteURL,
+		Title:      key,
+		Content:    string(data),
+		Visibility: VisibilityPublic,
+	}, nil
+}
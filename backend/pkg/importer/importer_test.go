@@ -0,0 +1,143 @@
+package importer
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func buildArchive(t *testing.T, files map[string]string) []byte {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for name, content := range files {
+		f, err := zw.Create(name)
+		if err != nil {
+			t.Fatalf("Failed to create %s: %v", name, err)
+		}
+		f.Write([]byte(content))
+	}
+	zw.Close()
+	return buf.Bytes()
+}
+
+func TestReadArchive_PastebinMetadata(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	list := fmt.Sprintf(`<paste><paste_key>AbCd1234</paste_key><paste_title>notes</paste_title>
+<paste_expire_date>%d</paste_expire_date><paste_private>1</paste_private><paste_format_short>html5</paste_format_short></paste>
+<paste><paste_key>Old00000</paste_key><paste_expire_date>%d</paste_expire_date><paste_private>0</paste_private><paste_format_short>text</paste_format_short></paste>`,
+		now.Add(time.Hour).Unix(), now.Add(-time.Hour).Unix())
+
+	data := buildArchive(t, map[string]string{
+		"pastes.xml":       list,
+		"AbCd1234.txt":     "<p>hi</p>",
+		"Old00000.txt":     "expired",
+		"ghostbin/main.go": "package main",
+	})
+
+	pastes, err := ReadArchive(data, now)
+	if err != nil {
+		t.Fatalf("Failed to read archive: %v", err)
+	}
+	if len(pastes) != 2 {
+		t.Fatalf("Expected 2 pastes, got %d: %+v", len(pastes), pastes)
+	}
+
+	byTitle := make(map[string]Paste)
+	for _, p := range pastes {
+		byTitle[p.Title] = p
+	}
+
+	notes, ok := byTitle["notes"]
+	if !ok {
+		t.Fatal("Expected the pastebin paste to take its title from pastes.xml")
+	}
+	if notes.Language != "html" || notes.Visibility != VisibilityUnlisted {
+		t.Errorf("Unexpected mapping: %+v", notes)
+	}
+	if notes.ExpiresAt == nil || !notes.ExpiresAt.Equal(now.Add(time.Hour)) {
+		t.Errorf("Expected expiry to be carried over, got %v", notes.ExpiresAt)
+	}
+
+	plain, ok := byTitle["main"]
+	if !ok || plain.Language != "go" || plain.Visibility != VisibilityPublic || plain.ExpiresAt != nil {
+		t.Errorf("Unexpected paste without metadata: %+v", plain)
+	}
+}
+
+func TestReadArchive_Errors(t *testing.T) {
+	if _, err := ReadArchive([]byte("not a zip"), time.Now()); err == nil {
+		t.Error("Expected error for invalid archive")
+	}
+
+	large := buildArchive(t, map[string]string{"big.txt": strings.Repeat("a", MaxPasteSize+1)})
+	if _, err := ReadArchive(large, time.Now()); !errors.Is(err, ErrTooLarge) {
+		t.Errorf("Expected ErrTooLarge, got %v", err)
+	}
+}
+
+func TestPastebinVisibility(t *testing.T) {
+	for private, want := range map[int]string{0: VisibilityPublic, 1: VisibilityUnlisted, 2: VisibilityPrivate} {
+		if got := PastebinVisibility(private); got != want {
+			t.Errorf("PastebinVisibility(%d) = %q, want %q", private, got, want)
+		}
+	}
+}
+
+func TestRawURL(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"https://pastebin.com/AbCd1234", "https://pastebin.com/raw/AbCd1234"},
+		{"https://www.pastebin.com/raw/AbCd1234", "https://pastebin.com/raw/AbCd1234"},
+		{"https://ghostbin.com/paste/xyz", "https://ghostbin.com/paste/xyz/raw"},
+		{"https://ghostbin.co/paste/xyz/raw", "https://ghostbin.co/paste/xyz/raw"},
+		{"https://example.com/AbCd1234", ""},
+		{"http://169.254.169.254/latest", ""},
+		{"file:///etc/passwd", ""},
+		{"https://pastebin.com/u/someone/pastes", ""},
+	}
+
+	for _, tt := range tests {
+		got, _, err := rawURL(tt.in)
+		if tt.want == "" {
+			if !errors.Is(err, ErrUnsupportedURL) {
+				t.Errorf("rawURL(%q): expected ErrUnsupportedURL, got %q, %v", tt.in, got, err)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("rawURL(%q) = %q, %v; want %q", tt.in, got, err, tt.want)
+		}
+	}
+}
+
+// roundTripFunc serves fetches without network access
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(r *http.Request) (*http.Response, error) { return f(r) }
+
+func TestFetcher_Fetch(t *testing.T) {
+	var requested string
+	client := &http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		requested = r.URL.String()
+		return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader("hello"))}, nil
+	})}
+
+	paste, err := NewFetcher(client).Fetch("https://pastebin.com/AbCd1234")
+	if err != nil {
+		t.Fatalf("Fetch failed: %v", err)
+	}
+	if requested != "https://pastebin.com/raw/AbCd1234" {
+		t.Errorf("Expected the raw URL to be fetched, got %s", requested)
+	}
+	if paste.Content != "hello" || paste.Title != "AbCd1234" || paste.Visibility != VisibilityPublic {
+		t.Errorf("Unexpected paste: %+v", paste)
+	}
+}
@@ -0,0 +1,181 @@
+package ldap
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// BER tag classes and the constructed bit
+const (
+	classUniversal   = 0x00
+	classApplication = 0x40
+	classContext     = 0x80
+	constructed      = 0x20
+)
+
+// Universal tags used by LDAP
+const (
+	tagBoolean     = 0x01
+	tagInteger     = 0x02
+	tagOctetString = 0x04
+	tagEnumerated  = 0x0a
+	tagSequence    = 0x10 | constructed
+	tagSet         = 0x11 | constructed
+)
+
+// maxPacketSize bounds the responses read from a server
+const maxPacketSize = 16 << 20
+
+// packet is a decoded BER element. Constructed elements have children;
+// primitive ones a value.
+type packet struct {
+	tag      byte
+	value    []byte
+	children []*packet
+}
+
+func (p *packet) constructed() bool {
+	return p.tag&constructed != 0
+}
+
+// child returns the i-th child, or an error for malformed packets
+func (p *packet) child(i int) (*packet, error) {
+	if i >= len(p.children) {
+		return nil, errors.New("ldap: malformed response")
+	}
+	return p.children[i], nil
+}
+
+// integer decodes a primitive INTEGER or ENUMERATED value
+func (p *packet) integer() int64 {
+	var n int64
+	for i, b := range p.value {
+		if i == 0 && b&0x80 != 0 {
+			n = -1
+		}
+		n = n<<8 | int64(b)
+	}
+	return n
+}
+
+// encode serializes an element with its tag and length
+func encode(tag byte, value []byte) []byte {
+	out := []byte{tag}
+	switch n := len(value); {
+	case n < 0x80:
+		out = append(out, byte(n))
+	default:
+		var length []byte
+		for ; n > 0; n >>= 8 {
+			length = append([]byte{byte(n)}, length...)
+		}
+		out = append(out, 0x80|byte(len(length)))
+		out = append(out, length...)
+	}
+	return append(out, value...)
+}
+
+// encodeConstructed serializes a constructed element from encoded children
+func encodeConstructed(tag byte, children ...[]byte) []byte {
+	var value []byte
+	for _, child := range children {
+		value = append(value, child...)
+	}
+	return encode(tag|constructed, value)
+}
+
+// encodeInteger serializes an INTEGER or ENUMERATED in minimal two's complement
+func encodeInteger(tag byte, n int64) []byte {
+	var value []byte
+	for {
+		value = append([]byte{byte(n)}, value...)
+		if (n < 0x80 && n >= -0x80) || len(value) == 8 {
+			break
+		}
+		n >>= 8
+	}
+	return encode(tag, value)
+}
+
+func encodeString(tag byte, s string) []byte {
+	return encode(tag, []byte(s))
+}
+
+func encodeBoolean(b bool) []byte {
+	if b {
+		return encode(tagBoolean, []byte{0xff})
+	}
+	return encode(tagBoolean, []byte{0x00})
+}
+
+// readPacket reads and decodes one element
+func readPacket(r *bufio.Reader) (*packet, error) {
+	tag, err := r.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+	if tag&0x1f == 0x1f {
+		return nil, errors.New("ldap: multi-byte tags are not supported")
+	}
+
+	first, err := r.ReadByte()
+	if err != nil {
+		return nil, unexpectedEOF(err)
+	}
+	length := int(first)
+	if first&0x80 != 0 {
+		n := int(first & 0x7f)
+		if n == 0 || n > 4 {
+			return nil, fmt.Errorf("ldap: unsupported length encoding 0x%02x", first)
+		}
+		length = 0
+		for range n {
+			b, err := r.ReadByte()
+			if err != nil {
+				return nil, unexpectedEOF(err)
+			}
+			length = length<<8 | int(b)
+		}
+	}
+	if length > maxPacketSize {
+		return nil, fmt.Errorf("ldap: response of %d bytes is too large", length)
+	}
+
+	value := make([]byte, length)
+	if _, err := io.ReadFull(r, value); err != nil {
+		return nil, unexpectedEOF(err)
+	}
+	return decode(tag, value)
+}
+
+// decode builds an element from its tag and value, decoding the children of
+// constructed elements
+func decode(tag byte, value []byte) (*packet, error) {
+	p := &packet{tag: tag, value: value}
+	if !p.constructed() {
+		return p, nil
+	}
+
+	r := bufio.NewReader(bytes.NewReader(value))
+	for {
+		child, err := readPacket(r)
+		if err == io.EOF {
+			return p, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		p.children = append(p.children, child)
+	}
+}
+
+// unexpectedEOF reports running out of input partway through an element
+func unexpectedEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
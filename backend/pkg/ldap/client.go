@@ -0,0 +1,327 @@
+// Package ldap is a minimal LDAPv3 client supporting what directory
+// authentication needs: simple binds, StartTLS and subtree searches.
+package ldap
+
+import (
+	"bufio"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Protocol operation tags (RFC 4511 section 4.2 onwards)
+const (
+	opBindRequest       = classApplication | constructed | 0
+	opBindResponse      = classApplication | constructed | 1
+	opUnbindRequest     = classApplication | 2
+	opSearchRequest     = classApplication | constructed | 3
+	opSearchEntry       = classApplication | constructed | 4
+	opSearchDone        = classApplication | constructed | 5
+	opSearchReference   = classApplication | constructed | 19
+	opExtendedRequest   = classApplication | constructed | 23
+	opExtendedResponse  = classApplication | constructed | 24
+	authSimple          = classContext | 0
+	extendedRequestName = classContext | 0
+)
+
+// startTLSOID is the extended operation upgrading a connection to TLS
+const startTLSOID = "1.3.6.1.4.1.1466.20037"
+
+// Result codes callers may want to tell apart
+const (
+	ResultSuccess            = 0
+	ResultInvalidCredentials = 49
+)
+
+// Error is a non-success result returned by the server
+type Error struct {
+	Code    int64
+	Message string
+}
+
+func (e *Error) Error() string {
+	if e.Message == "" {
+		return fmt.Sprintf("ldap: result code %d", e.Code)
+	}
+	return fmt.Sprintf("ldap: result code %d: %s", e.Code, e.Message)
+}
+
+// IsInvalidCredentials reports whether err is a failed bind
+func IsInvalidCredentials(err error) bool {
+	var ldapErr *Error
+	return errors.As(err, &ldapErr) && ldapErr.Code == ResultInvalidCredentials
+}
+
+// Entry is a directory entry returned by a search
+type Entry struct {
+	DN         string
+	Attributes map[string][]string // Keyed by lowercase attribute name
+}
+
+// Get returns the first value of an attribute, or an empty string
+func (e *Entry) Get(attr string) string {
+	if values := e.Attributes[strings.ToLower(attr)]; len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+// Values returns every value of an attribute
+func (e *Entry) Values(attr string) []string {
+	return e.Attributes[strings.ToLower(attr)]
+}
+
+// Conn is a connection to a directory server. It is not safe for
+// concurrent use.
+type Conn struct {
+	conn    net.Conn
+	r       *bufio.Reader
+	timeout time.Duration
+	host    string
+	nextID  int64
+}
+
+// Dial connects to an ldap:// or ldaps:// URL. tlsConfig may be nil for the
+// defaults; timeout bounds the connection and each operation.
+func Dial(rawURL string, tlsConfig *tls.Config, timeout time.Duration) (*Conn, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("ldap: invalid URL: %w", err)
+	}
+
+	host := u.Hostname()
+	port := u.Port()
+	dialer := &net.Dialer{Timeout: timeout}
+	var conn net.Conn
+	switch u.Scheme {
+	case "ldap":
+		if port == "" {
+			port = "389"
+		}
+		conn, err = dialer.Dial("tcp", net.JoinHostPort(host, port))
+	case "ldaps":
+		if port == "" {
+			port = "636"
+		}
+		conn, err = tls.DialWithDialer(dialer, "tcp", net.JoinHostPort(host, port), withServerName(tlsConfig, host))
+	default:
+		return nil, fmt.Errorf("ldap: unsupported URL scheme %q", u.Scheme)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &Conn{conn: conn, r: bufio.NewReader(conn), timeout: timeout, host: host, nextID: 1}, nil
+}
+
+// withServerName returns a TLS configuration verifying the given host
+func withServerName(tlsConfig *tls.Config, host string) *tls.Config {
+	if tlsConfig == nil {
+		tlsConfig = &tls.Config{}
+	} else {
+		tlsConfig = tlsConfig.Clone()
+	}
+	if tlsConfig.ServerName == "" {
+		tlsConfig.ServerName = host
+	}
+	return tlsConfig
+}
+
+// Close unbinds and closes the connection
+func (c *Conn) Close() error {
+	c.send(encode(opUnbindRequest, nil))
+	return c.conn.Close()
+}
+
+// StartTLS upgrades a plain connection to TLS
+func (c *Conn) StartTLS(tlsConfig *tls.Config) error {
+	response, err := c.roundTrip(encodeConstructed(opExtendedRequest, encodeString(extendedRequestName, startTLSOID)), opExtendedResponse)
+	if err != nil {
+		return err
+	}
+	if err := resultError(response); err != nil {
+		return err
+	}
+
+	tlsConn := tls.Client(c.conn, withServerName(tlsConfig, c.host))
+	if c.timeout > 0 {
+		tlsConn.SetDeadline(time.Now().Add(c.timeout))
+	}
+	if err := tlsConn.Handshake(); err != nil {
+		return err
+	}
+	c.conn = tlsConn
+	c.r = bufio.NewReader(tlsConn)
+	return nil
+}
+
+// Bind authenticates the connection with a simple bind. An empty password
+// would be an unauthenticated bind, which servers accept for any DN, so it is
+// refused here.
+func (c *Conn) Bind(dn, password string) error {
+	if password == "" {
+		return &Error{Code: ResultInvalidCredentials, Message: "empty password"}
+	}
+
+	request := encodeConstructed(opBindRequest,
+		encodeInteger(tagInteger, 3),
+		encodeString(tagOctetString, dn),
+		encodeString(authSimple, password),
+	)
+	response, err := c.roundTrip(request, opBindResponse)
+	if err != nil {
+		return err
+	}
+	return resultError(response)
+}
+
+// SearchRequest describes a subtree search
+type SearchRequest struct {
+	BaseDN     string
+	Filter     string
+	Attributes []string
+	SizeLimit  int64
+}
+
+// Search runs a subtree search and returns the matching entries
+func (c *Conn) Search(req SearchRequest) ([]*Entry, error) {
+	filter, err := compileFilter(req.Filter)
+	if err != nil {
+		return nil, err
+	}
+
+	var attributes [][]byte
+	for _, attr := range req.Attributes {
+		attributes = append(attributes, encodeString(tagOctetString, attr))
+	}
+	timeLimit := int64(c.timeout / time.Second)
+
+	request := encodeConstructed(opSearchRequest,
+		encodeString(tagOctetString, req.BaseDN),
+		encodeInteger(tagEnumerated, 2), // wholeSubtree
+		encodeInteger(tagEnumerated, 0), // neverDerefAliases
+		encodeInteger(tagInteger, req.SizeLimit),
+		encodeInteger(tagInteger, timeLimit),
+		encodeBoolean(false),
+		filter,
+		encodeConstructed(tagSequence, attributes...),
+	)
+
+	id, err := c.send(request)
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []*Entry
+	for {
+		op, err := c.receive(id)
+		if err != nil {
+			return nil, err
+		}
+		switch op.tag {
+		case opSearchEntry:
+			entry, err := parseEntry(op)
+			if err != nil {
+				return nil, err
+			}
+			entries = append(entries, entry)
+		case opSearchReference:
+			// Referrals to other servers are not followed
+		case opSearchDone:
+			return entries, resultError(op)
+		default:
+			return nil, fmt.Errorf("ldap: unexpected response 0x%02x to search", op.tag)
+		}
+	}
+}
+
+// send writes a request in a new message, returning its ID
+func (c *Conn) send(op []byte) (int64, error) {
+	id := c.nextID
+	c.nextID++
+	if c.timeout > 0 {
+		c.conn.SetDeadline(time.Now().Add(c.timeout))
+	}
+	_, err := c.conn.Write(encodeConstructed(tagSequence, encodeInteger(tagInteger, id), op))
+	return id, err
+}
+
+// receive reads the next message, returning its protocol operation
+func (c *Conn) receive(id int64) (*packet, error) {
+	message, err := readPacket(c.r)
+	if err != nil {
+		return nil, err
+	}
+	if message.tag != tagSequence || len(message.children) < 2 {
+		return nil, errors.New("ldap: malformed message")
+	}
+	if got := message.children[0].integer(); got != id {
+		return nil, fmt.Errorf("ldap: response to message %d while waiting for %d", got, id)
+	}
+	return message.children[1], nil
+}
+
+// roundTrip sends a request and reads its single response
+func (c *Conn) roundTrip(op []byte, responseTag byte) (*packet, error) {
+	id, err := c.send(op)
+	if err != nil {
+		return nil, err
+	}
+	response, err := c.receive(id)
+	if err != nil {
+		return nil, err
+	}
+	if response.tag != responseTag {
+		return nil, fmt.Errorf("ldap: unexpected response 0x%02x", response.tag)
+	}
+	return response, nil
+}
+
+// resultError returns the error reported by an LDAPResult, if any
+func resultError(result *packet) error {
+	code, err := result.child(0)
+	if err != nil {
+		return err
+	}
+	if code.integer() == ResultSuccess {
+		return nil
+	}
+	ldapErr := &Error{Code: code.integer()}
+	if message, err := result.child(2); err == nil {
+		ldapErr.Message = string(message.value)
+	}
+	return ldapErr
+}
+
+// parseEntry decodes a SearchResultEntry
+func parseEntry(op *packet) (*Entry, error) {
+	dn, err := op.child(0)
+	if err != nil {
+		return nil, err
+	}
+	attributes, err := op.child(1)
+	if err != nil {
+		return nil, err
+	}
+
+	entry := &Entry{DN: string(dn.value), Attributes: map[string][]string{}}
+	for _, attribute := range attributes.children {
+		name, err := attribute.child(0)
+		if err != nil {
+			return nil, err
+		}
+		values, err := attribute.child(1)
+		if err != nil {
+			return nil, err
+		}
+		key := strings.ToLower(string(name.value))
+		for _, value := range values.children {
+			entry.Attributes[key] = append(entry.Attributes[key], string(value.value))
+		}
+	}
+	return entry, nil
+}
@@ -0,0 +1,209 @@
+package ldap
+
+import (
+	"bufio"
+	"bytes"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+func decodeFilter(t *testing.T, filter string) *packet {
+	t.Helper()
+	encoded, err := compileFilter(filter)
+	if err != nil {
+		t.Fatalf("compileFilter(%q) failed: %v", filter, err)
+	}
+	p, err := readPacket(bufio.NewReader(bytes.NewReader(encoded)))
+	if err != nil {
+		t.Fatalf("Failed to decode filter %q: %v", filter, err)
+	}
+	return p
+}
+
+func TestCompileFilter(t *testing.T) {
+	p := decodeFilter(t, "(&(objectClass=person)(!(uid=al\\2aice))(mail=*)(cn=ab*c*d))")
+	if p.tag != filterAnd || len(p.children) != 4 {
+		t.Fatalf("Expected an and of 4 filters, got tag 0x%02x with %d", p.tag, len(p.children))
+	}
+
+	equality := p.children[0]
+	if equality.tag != filterEqualityMatch || string(equality.children[1].value) != "person" {
+		t.Errorf("Unexpected equality filter %+v", equality)
+	}
+	not := p.children[1]
+	if not.tag != filterNot || string(not.children[0].children[1].value) != "al*ice" {
+		t.Errorf("Expected a negated match of the unescaped value, got %+v", not)
+	}
+	if present := p.children[2]; present.tag != filterPresent || string(present.value) != "mail" {
+		t.Errorf("Unexpected presence filter %+v", present)
+	}
+	substrings := p.children[3].children[1].children
+	if len(substrings) != 3 || substrings[0].tag != substringInitial || substrings[1].tag != substringAny || substrings[2].tag != substringFinal {
+		t.Errorf("Unexpected substrings %+v", substrings)
+	}
+
+	// A bare item is accepted without parentheses
+	if p := decodeFilter(t, "uid=alice"); p.tag != filterEqualityMatch {
+		t.Errorf("Expected an equality filter, got 0x%02x", p.tag)
+	}
+
+	for _, invalid := range []string{"(uid=alice", "(&(uid=a)", "(=x)", "(uid=\\zz)", "(uid:dn:=x)"} {
+		if _, err := compileFilter(invalid); err == nil {
+			t.Errorf("Expected %q to be rejected", invalid)
+		}
+	}
+}
+
+func TestEscapeFilter(t *testing.T) {
+	if got := EscapeFilter("*)(uid=*"); got != "\\2a\\29\\28uid=\\2a" {
+		t.Errorf("Unexpected escaping: %q", got)
+	}
+	// Escaped input matches literally
+	p := decodeFilter(t, "(uid="+EscapeFilter("a*(b)")+")")
+	if p.tag != filterEqualityMatch || string(p.children[1].value) != "a*(b)" {
+		t.Errorf("Expected a literal match, got %+v", p)
+	}
+}
+
+func TestEncodeInteger(t *testing.T) {
+	tests := map[int64][]byte{
+		0:    {0x02, 0x01, 0x00},
+		127:  {0x02, 0x01, 0x7f},
+		128:  {0x02, 0x02, 0x00, 0x80},
+		-1:   {0x02, 0x01, 0xff},
+		1000: {0x02, 0x02, 0x03, 0xe8},
+	}
+	for n, want := range tests {
+		got := encodeInteger(tagInteger, n)
+		if !bytes.Equal(got, want) {
+			t.Errorf("encodeInteger(%d) = % x, want % x", n, got, want)
+		}
+		p, _ := decode(got[0], got[2:])
+		if p.integer() != n {
+			t.Errorf("Decoded %d, want %d", p.integer(), n)
+		}
+	}
+
+	// Long form lengths round trip
+	value := bytes.Repeat([]byte{'x'}, 300)
+	p, err := readPacket(bufio.NewReader(bytes.NewReader(encode(tagOctetString, value))))
+	if err != nil || !bytes.Equal(p.value, value) {
+		t.Errorf("Expected a 300 byte value back, got %d bytes, %v", len(p.value), err)
+	}
+}
+
+// fakeDirectory serves binds and searches over a small set of accounts
+func fakeDirectory(t *testing.T, passwords map[string]string, entries []*Entry) string {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				r := bufio.NewReader(conn)
+				for {
+					message, err := readPacket(r)
+					if err != nil {
+						return
+					}
+					id := message.children[0].integer()
+					op := message.children[1]
+					reply := func(tag byte, children ...[]byte) {
+						conn.Write(encodeConstructed(tagSequence, encodeInteger(tagInteger, id), encodeConstructed(tag, children...)))
+					}
+					ldapResult := func(code int64) [][]byte {
+						return [][]byte{encodeInteger(tagEnumerated, code), encodeString(tagOctetString, ""), encodeString(tagOctetString, "")}
+					}
+
+					switch op.tag {
+					case opBindRequest:
+						dn, password := string(op.children[1].value), string(op.children[2].value)
+						code := int64(ResultInvalidCredentials)
+						if want, ok := passwords[dn]; ok && want == password {
+							code = ResultSuccess
+						}
+						reply(opBindResponse, ldapResult(code)...)
+					case opSearchRequest:
+						filter := op.children[6]
+						for _, entry := range entries {
+							// Only equality filters on uid are understood
+							if filter.tag == filterEqualityMatch && string(filter.children[1].value) != entry.Get("uid") {
+								continue
+							}
+							var attributes [][]byte
+							for name, values := range entry.Attributes {
+								var encoded [][]byte
+								for _, value := range values {
+									encoded = append(encoded, encodeString(tagOctetString, value))
+								}
+								attributes = append(attributes, encodeConstructed(tagSequence, encodeString(tagOctetString, name), encodeConstructed(tagSet, encoded...)))
+							}
+							reply(opSearchEntry, encodeString(tagOctetString, entry.DN), encodeConstructed(tagSequence, attributes...))
+						}
+						reply(opSearchDone, ldapResult(ResultSuccess)...)
+					case opUnbindRequest:
+						return
+					}
+				}
+			}()
+		}
+	}()
+
+	return "ldap://" + listener.Addr().String()
+}
+
+func TestBindAndSearch(t *testing.T) {
+	alice := &Entry{DN: "uid=alice,ou=people,dc=example,dc=org", Attributes: map[string][]string{
+		"uid":      {"alice"},
+		"memberof": {"cn=admins,ou=groups,dc=example,dc=org", "cn=dev,ou=groups,dc=example,dc=org"},
+	}}
+	url := fakeDirectory(t, map[string]string{
+		"cn=reader,dc=example,dc=org": "reader-secret",
+		alice.DN:                      "alice-secret",
+	}, []*Entry{alice})
+
+	conn, err := Dial(url, nil, 5*time.Second)
+	if err != nil {
+		t.Fatalf("Dial failed: %v", err)
+	}
+	defer conn.Close()
+
+	if err := conn.Bind("cn=reader,dc=example,dc=org", "wrong"); !IsInvalidCredentials(err) {
+		t.Errorf("Expected invalid credentials, got %v", err)
+	}
+	if err := conn.Bind(alice.DN, ""); !IsInvalidCredentials(err) {
+		t.Errorf("Expected an empty password to be refused, got %v", err)
+	}
+	if err := conn.Bind("cn=reader,dc=example,dc=org", "reader-secret"); err != nil {
+		t.Fatalf("Bind failed: %v", err)
+	}
+
+	entries, err := conn.Search(SearchRequest{BaseDN: "dc=example,dc=org", Filter: "(uid=alice)", Attributes: []string{"uid", "memberOf"}})
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	if len(entries) != 1 || entries[0].DN != alice.DN || len(entries[0].Values("memberOf")) != 2 {
+		t.Fatalf("Unexpected entries %+v", entries)
+	}
+	if !strings.HasPrefix(entries[0].Values("MEMBEROF")[0], "cn=admins") {
+		t.Errorf("Expected attribute names to be case-insensitive")
+	}
+
+	if entries, _ := conn.Search(SearchRequest{BaseDN: "dc=example,dc=org", Filter: "(uid=bob)"}); len(entries) != 0 {
+		t.Errorf("Expected no entries for an unknown user, got %d", len(entries))
+	}
+	if err := conn.Bind(alice.DN, "alice-secret"); err != nil {
+		t.Errorf("Expected the user's bind to succeed: %v", err)
+	}
+}
@@ -0,0 +1,193 @@
+package ldap
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+// Filter choice tags (RFC 4511 section 4.5.1)
+const (
+	filterAnd            = classContext | constructed | 0
+	filterOr             = classContext | constructed | 1
+	filterNot            = classContext | constructed | 2
+	filterEqualityMatch  = classContext | constructed | 3
+	filterSubstrings     = classContext | constructed | 4
+	filterGreaterOrEqual = classContext | constructed | 5
+	filterLessOrEqual    = classContext | constructed | 6
+	filterPresent        = classContext | 7
+	filterApproxMatch    = classContext | constructed | 8
+)
+
+// Substring filter parts
+const (
+	substringInitial = classContext | 0
+	substringAny     = classContext | 1
+	substringFinal   = classContext | 2
+)
+
+// EscapeFilter escapes a value for use in a search filter (RFC 4515), so
+// user input such as a username cannot change the filter's meaning
+func EscapeFilter(value string) string {
+	var b strings.Builder
+	for i := 0; i < len(value); i++ {
+		switch c := value[i]; c {
+		case '*', '(', ')', '\\', 0:
+			fmt.Fprintf(&b, "\\%02x", c)
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
+
+// compileFilter encodes a string search filter such as
+// (&(objectClass=person)(uid=alice)). Extensible matches are not supported.
+func compileFilter(filter string) ([]byte, error) {
+	filter = strings.TrimSpace(filter)
+	if !strings.HasPrefix(filter, "(") {
+		filter = "(" + filter + ")"
+	}
+	encoded, rest, err := parseFilter(filter)
+	if err != nil {
+		return nil, err
+	}
+	if rest != "" {
+		return nil, fmt.Errorf("ldap: unexpected %q after filter", rest)
+	}
+	return encoded, nil
+}
+
+// parseFilter encodes the parenthesized filter at the start of s, returning
+// what follows it
+func parseFilter(s string) ([]byte, string, error) {
+	if !strings.HasPrefix(s, "(") {
+		return nil, "", fmt.Errorf("ldap: filter %q must start with (", s)
+	}
+	s = s[1:]
+
+	switch {
+	case strings.HasPrefix(s, "&"), strings.HasPrefix(s, "|"):
+		tag := byte(filterAnd)
+		if s[0] == '|' {
+			tag = filterOr
+		}
+		s = s[1:]
+		var children [][]byte
+		for strings.HasPrefix(s, "(") {
+			child, rest, err := parseFilter(s)
+			if err != nil {
+				return nil, "", err
+			}
+			children = append(children, child)
+			s = rest
+		}
+		if !strings.HasPrefix(s, ")") {
+			return nil, "", fmt.Errorf("ldap: unterminated filter")
+		}
+		return encodeConstructed(tag, children...), s[1:], nil
+
+	case strings.HasPrefix(s, "!"):
+		child, rest, err := parseFilter(s[1:])
+		if err != nil {
+			return nil, "", err
+		}
+		if !strings.HasPrefix(rest, ")") {
+			return nil, "", fmt.Errorf("ldap: unterminated filter")
+		}
+		return encodeConstructed(filterNot, child), rest[1:], nil
+	}
+
+	end := strings.IndexByte(s, ')')
+	if end < 0 {
+		return nil, "", fmt.Errorf("ldap: unterminated filter")
+	}
+	encoded, err := parseItem(s[:end])
+	if err != nil {
+		return nil, "", err
+	}
+	return encoded, s[end+1:], nil
+}
+
+// parseItem encodes a simple filter item such as uid=alice or cn=ali*
+func parseItem(item string) ([]byte, error) {
+	eq := strings.IndexByte(item, '=')
+	if eq <= 0 {
+		return nil, fmt.Errorf("ldap: invalid filter item %q", item)
+	}
+	attr, value := item[:eq], item[eq+1:]
+
+	tag := byte(filterEqualityMatch)
+	switch attr[len(attr)-1] {
+	case '>':
+		tag, attr = filterGreaterOrEqual, attr[:len(attr)-1]
+	case '<':
+		tag, attr = filterLessOrEqual, attr[:len(attr)-1]
+	case '~':
+		tag, attr = filterApproxMatch, attr[:len(attr)-1]
+	case ':':
+		return nil, fmt.Errorf("ldap: extensible match %q is not supported", item)
+	}
+	if attr == "" {
+		return nil, fmt.Errorf("ldap: invalid filter item %q", item)
+	}
+
+	if tag == filterEqualityMatch && value == "*" {
+		return encodeString(filterPresent, attr), nil
+	}
+	if tag == filterEqualityMatch && strings.Contains(value, "*") {
+		parts := strings.Split(value, "*")
+		var substrings [][]byte
+		for i, part := range parts {
+			if part == "" {
+				continue
+			}
+			unescaped, err := unescapeFilterValue(part)
+			if err != nil {
+				return nil, err
+			}
+			partTag := byte(substringAny)
+			switch i {
+			case 0:
+				partTag = substringInitial
+			case len(parts) - 1:
+				partTag = substringFinal
+			}
+			substrings = append(substrings, encodeString(partTag, unescaped))
+		}
+		return encodeConstructed(filterSubstrings,
+			encodeString(tagOctetString, attr),
+			encodeConstructed(tagSequence, substrings...),
+		), nil
+	}
+
+	unescaped, err := unescapeFilterValue(value)
+	if err != nil {
+		return nil, err
+	}
+	return encodeConstructed(tag, encodeString(tagOctetString, attr), encodeString(tagOctetString, unescaped)), nil
+}
+
+// unescapeFilterValue decodes \XX escapes in a filter value
+func unescapeFilterValue(value string) (string, error) {
+	if !strings.Contains(value, "\\") {
+		return value, nil
+	}
+	var b strings.Builder
+	for i := 0; i < len(value); i++ {
+		if value[i] != '\\' {
+			b.WriteByte(value[i])
+			continue
+		}
+		if i+3 > len(value) {
+			return "", fmt.Errorf("ldap: invalid escape in %q", value)
+		}
+		decoded, err := hex.DecodeString(value[i+1 : i+3])
+		if err != nil {
+			return "", fmt.Errorf("ldap: invalid escape in %q", value)
+		}
+		b.Write(decoded)
+		i += 2
+	}
+	return b.String(), nil
+}
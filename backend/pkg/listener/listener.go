@@ -0,0 +1,106 @@
+// Package listener opens the socket the HTTP server accepts connections on:
+// a socket inherited through systemd socket activation, a Unix domain
+// socket, or a TCP port.
+package listener
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"net"
+	"os"
+	"strconv"
+)
+
+// listenFDsStart is the first file descriptor passed by systemd (SD_LISTEN_FDS_START)
+const listenFDsStart = 3
+
+// Options describes where to listen when no socket is inherited
+type Options struct {
+	Port       string      // TCP port, used when SocketPath is empty
+	SocketPath string      // Unix domain socket path
+	SocketMode fs.FileMode // Permissions applied to the Unix socket
+}
+
+// Listen returns the listener to serve on and a description of it for logs.
+// A systemd-activated socket takes precedence over SocketPath and Port.
+func Listen(opts Options) (net.Listener, string, error) {
+	if l, err := systemdListener(); err != nil || l != nil {
+		if err != nil {
+			return nil, "", err
+		}
+		return l, "systemd socket " + l.Addr().String(), nil
+	}
+
+	if opts.SocketPath != "" {
+		l, err := unixListener(opts.SocketPath, opts.SocketMode)
+		if err != nil {
+			return nil, "", err
+		}
+		return l, "unix socket " + opts.SocketPath, nil
+	}
+
+	l, err := net.Listen("tcp", ":"+opts.Port)
+	if err != nil {
+		return nil, "", err
+	}
+	return l, "port " + opts.Port, nil
+}
+
+// systemdListener returns the first socket passed via LISTEN_FDS, or nil if
+// the process was not socket activated. The variables are cleared so child
+// processes do not inherit them.
+func systemdListener() (net.Listener, error) {
+	pid, err := strconv.Atoi(os.Getenv("LISTEN_PID"))
+	if err != nil || pid != os.Getpid() {
+		return nil, nil
+	}
+	n, err := strconv.Atoi(os.Getenv("LISTEN_FDS"))
+	if err != nil || n < 1 {
+		return nil, nil
+	}
+	defer func() {
+		os.Unsetenv("LISTEN_PID")
+		os.Unsetenv("LISTEN_FDS")
+		os.Unsetenv("LISTEN_FDNAMES")
+	}()
+
+	if n > 1 {
+		return nil, fmt.Errorf("expected 1 systemd socket, got %d", n)
+	}
+
+	file := os.NewFile(uintptr(listenFDsStart), "systemd-socket")
+	defer file.Close() // FileListener dups the descriptor
+	l, err := net.FileListener(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to use systemd socket: %w", err)
+	}
+	return l, nil
+}
+
+// unixListener listens on a Unix socket, replacing a stale socket file left
+// by an unclean shutdown but refusing to remove any other kind of file
+func unixListener(path string, mode fs.FileMode) (net.Listener, error) {
+	if info, err := os.Lstat(path); err == nil {
+		if info.Mode()&fs.ModeSocket == 0 {
+			return nil, fmt.Errorf("%s exists and is not a socket", path)
+		}
+		if err := os.Remove(path); err != nil {
+			return nil, fmt.Errorf("failed to remove stale socket: %w", err)
+		}
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+
+	l, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	if mode != 0 {
+		if err := os.Chmod(path, mode); err != nil {
+			l.Close()
+			return nil, fmt.Errorf("failed to set socket permissions: %w", err)
+		}
+	}
+	return l, nil
+}
@@ -0,0 +1,86 @@
+package listener
+
+import (
+	"context"
+	"io"
+	"io/fs"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestListenUnixSocket(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "pastevault.sock")
+
+	// A stale socket from an earlier run is replaced
+	stale, err := net.Listen("unix", path)
+	if err != nil {
+		t.Fatalf("Failed to create stale socket: %v", err)
+	}
+	stale.(*net.UnixListener).SetUnlinkOnClose(false)
+	stale.Close()
+
+	l, desc, err := Listen(Options{Port: "0", SocketPath: path, SocketMode: 0o660})
+	if err != nil {
+		t.Fatalf("Listen failed: %v", err)
+	}
+	if desc != "unix socket "+path {
+		t.Errorf("Unexpected description %q", desc)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil || info.Mode().Perm() != 0o660 {
+		t.Errorf("Expected socket mode 660, got %v (err %v)", info.Mode().Perm(), err)
+	}
+
+	server := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "ok")
+	})}
+	go server.Serve(l)
+	defer server.Close()
+
+	client := &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, "unix", path)
+		},
+	}}
+	resp, err := client.Get("http://unix/")
+	if err != nil {
+		t.Fatalf("Request over unix socket failed: %v", err)
+	}
+	defer resp.Body.Close()
+	if body, _ := io.ReadAll(resp.Body); string(body) != "ok" {
+		t.Errorf("Unexpected response %q", body)
+	}
+}
+
+func TestListenRefusesToReplaceRegularFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "not-a-socket")
+	if err := os.WriteFile(path, []byte("data"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, _, err := Listen(Options{SocketPath: path}); err == nil {
+		t.Fatal("Expected an error for a path that is not a socket")
+	}
+	if info, err := os.Stat(path); err != nil || info.Mode()&fs.ModeSocket != 0 {
+		t.Error("Expected the regular file to be left alone")
+	}
+}
+
+func TestListenIgnoresOtherProcessFDs(t *testing.T) {
+	// LISTEN_FDS meant for another process must not be used
+	t.Setenv("LISTEN_PID", "1")
+	t.Setenv("LISTEN_FDS", "1")
+
+	l, desc, err := Listen(Options{Port: "0"})
+	if err != nil {
+		t.Fatalf("Listen failed: %v", err)
+	}
+	defer l.Close()
+	if desc != "port 0" {
+		t.Errorf("Expected a TCP listener, got %q", desc)
+	}
+}
@@ -0,0 +1,178 @@
+package oidc
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// fakeProvider is an OpenID Connect provider issuing ID tokens for one code
+type fakeProvider struct {
+	server    *httptest.Server
+	key       *rsa.PrivateKey
+	code      string
+	challenge string
+	nonce     string
+	claims    jwt.MapClaims
+}
+
+func newFakeProvider(t *testing.T) *fakeProvider {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	p := &fakeProvider{key: key, code: "the-code"}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 p.server.URL,
+			"authorization_endpoint": p.server.URL + "/authorize",
+			"token_endpoint":         p.server.URL + "/token",
+			"jwks_uri":               p.server.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{"keys": []map[string]string{{
+			"kty": "RSA",
+			"kid": "key-1",
+			"use": "sig",
+			"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}}})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		clientID, secret, _ := r.BasicAuth()
+		verifier := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+		if clientID != "pastevault" || secret != "client-secret" || r.PostForm.Get("code") != p.code ||
+			base64.RawURLEncoding.EncodeToString(verifier[:]) != p.challenge {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]string{"id_token": p.sign(t, p.claims)})
+	})
+	p.server = httptest.NewServer(mux)
+	t.Cleanup(p.server.Close)
+	return p
+}
+
+func (p *fakeProvider) sign(t *testing.T, claims jwt.MapClaims) string {
+	t.Helper()
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = "key-1"
+	signed, err := token.SignedString(p.key)
+	if err != nil {
+		t.Fatalf("Failed to sign token: %v", err)
+	}
+	return signed
+}
+
+func TestAuthorizationCodeFlow(t *testing.T) {
+	fake := newFakeProvider(t)
+	provider := NewProvider(Config{
+		Issuer:       fake.server.URL + "/",
+		ClientID:     "pastevault",
+		ClientSecret: "client-secret",
+		RedirectURL:  "https://paste.example.com/api/auth/oidc/callback",
+		Scopes:       []string{"openid", "profile", "groups"},
+	}, nil)
+	ctx := context.Background()
+
+	verifier, _ := NewPKCEVerifier()
+	authURL, err := provider.AuthCodeURL(ctx, "state-1", "nonce-1", verifier)
+	if err != nil {
+		t.Fatalf("AuthCodeURL failed: %v", err)
+	}
+	u, _ := url.Parse(authURL)
+	query := u.Query()
+	if !strings.HasPrefix(authURL, fake.server.URL+"/authorize?") || query.Get("state") != "state-1" || query.Get("scope") != "openid profile groups" {
+		t.Errorf("Unexpected authorization URL %s", authURL)
+	}
+	fake.challenge = query.Get("code_challenge")
+
+	now := time.Now()
+	fake.claims = jwt.MapClaims{
+		"iss":                fake.server.URL,
+		"aud":                "pastevault",
+		"sub":                "user-123",
+		"exp":                now.Add(time.Hour).Unix(),
+		"iat":                now.Unix(),
+		"nonce":              "nonce-1",
+		"preferred_username": "alice",
+		"groups":             []string{"engineering", "admins"},
+	}
+	claims, err := provider.Exchange(ctx, "the-code", verifier, "nonce-1")
+	if err != nil {
+		t.Fatalf("Exchange failed: %v", err)
+	}
+	if claims.String("sub") != "user-123" || claims.String("preferred_username") != "alice" {
+		t.Errorf("Unexpected claims %v", claims)
+	}
+	if groups := claims.Strings("groups"); len(groups) != 2 || groups[1] != "admins" {
+		t.Errorf("Unexpected groups %v", groups)
+	}
+
+	if _, err := provider.Exchange(ctx, "the-code", "wrong-verifier", "nonce-1"); err == nil {
+		t.Error("Expected a wrong PKCE verifier to be refused")
+	}
+	if _, err := provider.Exchange(ctx, "the-code", verifier, "other-nonce"); err == nil {
+		t.Error("Expected a mismatched nonce to be refused")
+	}
+}
+
+func TestVerifyRejectsInvalidTokens(t *testing.T) {
+	fake := newFakeProvider(t)
+	provider := NewProvider(Config{Issuer: fake.server.URL, ClientID: "pastevault"}, nil)
+	ctx := context.Background()
+
+	valid := func() jwt.MapClaims {
+		return jwt.MapClaims{
+			"iss": fake.server.URL, "aud": "pastevault", "sub": "user-123", "nonce": "n",
+			"exp": time.Now().Add(time.Hour).Unix(), "iat": time.Now().Unix(),
+		}
+	}
+	if _, err := provider.Verify(ctx, fake.sign(t, valid()), "n"); err != nil {
+		t.Fatalf("Expected a valid token to verify: %v", err)
+	}
+
+	tests := map[string]func(jwt.MapClaims){
+		"other audience": func(c jwt.MapClaims) { c["aud"] = "someone-else" },
+		"other issuer":   func(c jwt.MapClaims) { c["iss"] = "https://evil.example.com" },
+		"expired":        func(c jwt.MapClaims) { c["exp"] = time.Now().Add(-time.Hour).Unix() },
+		"no subject":     func(c jwt.MapClaims) { delete(c, "sub") },
+	}
+	for name, mutate := range tests {
+		claims := valid()
+		mutate(claims)
+		if _, err := provider.Verify(ctx, fake.sign(t, claims), "n"); err == nil {
+			t.Errorf("%s: expected the token to be refused", name)
+		}
+	}
+
+	// Tokens signed by another key are refused
+	other, _ := rsa.GenerateKey(rand.Reader, 2048)
+	forged, _ := jwt.NewWithClaims(jwt.SigningMethodRS256, valid()).SignedString(other)
+	if _, err := provider.Verify(ctx, forged, "n"); err == nil {
+		t.Error("Expected a forged token to be refused")
+	}
+	// So are unsigned ones
+	unsigned, _ := jwt.NewWithClaims(jwt.SigningMethodNone, valid()).SignedString(jwt.UnsafeAllowNoneSignatureType)
+	if _, err := provider.Verify(ctx, unsigned, "n"); err == nil {
+		t.Error("Expected an unsigned token to be refused")
+	}
+}
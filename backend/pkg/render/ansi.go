@@ -0,0 +1,208 @@
+package render
+
+import (
+	"fmt"
+	"html"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// ansiEscape matches ANSI CSI escape sequences
+var ansiEscape = regexp.MustCompile(`\x1b\[[0-9;?]*[ -/]*[@-~]`)
+
+// ansiOSC matches operating system commands such as terminal title changes
+var ansiOSC = regexp.MustCompile(`\x1b\][^\x07\x1b]*(\x07|\x1b\\)`)
+
+// ansiPalette holds the 16 standard terminal colors (xterm defaults)
+var ansiPalette = [16]string{
+	"#000000", "#cd3131", "#0dbc79", "#e5e510", "#2472c8", "#bc3fbc", "#11a8cd", "#e5e5e5",
+	"#666666", "#f14c4c", "#23d18b", "#f5f543", "#3b8eea", "#d670d6", "#29b8db", "#ffffff",
+}
+
+// ansiStyle is the current SGR graphics state
+type ansiStyle struct {
+	fg, bg                       string
+	bold, dim, italic, underline bool
+}
+
+// css renders the style as an inline CSS declaration list
+func (s ansiStyle) css() string {
+	var parts []string
+	if s.fg != "" {
+		parts = append(parts, "color:"+s.fg)
+	}
+	if s.bg != "" {
+		parts = append(parts, "background:"+s.bg)
+	}
+	if s.bold {
+		parts = append(parts, "font-weight:bold")
+	}
+	if s.dim {
+		parts = append(parts, "opacity:0.7")
+	}
+	if s.italic {
+		parts = append(parts, "font-style:italic")
+	}
+	if s.underline {
+		parts = append(parts, "text-decoration:underline")
+	}
+	return strings.Join(parts, ";")
+}
+
+// StripANSI removes all ANSI escape sequences from text
+func StripANSI(text string) string {
+	return ansiEscape.ReplaceAllString(ansiOSC.ReplaceAllString(text, ""), "")
+}
+
+// ANSIToHTML converts terminal output containing ANSI SGR color codes into an
+// escaped <pre> block with inline styles. Non-color escape sequences are dropped.
+func ANSIToHTML(content string, theme Theme) string {
+	content = ansiOSC.ReplaceAllString(content, "")
+
+	var b strings.Builder
+	fmt.Fprintf(&b, `<pre class="ansi" style="background:%s;color:%s">`, Hex(theme.Background), Hex(theme.Foreground))
+
+	var style ansiStyle
+	for i, line := range SplitLines(content) {
+		if i > 0 {
+			b.WriteByte('\n')
+		}
+
+		// Carriage returns overwrite the line (progress bars); keep the final state
+		if idx := strings.LastIndexByte(line, '\r'); idx >= 0 {
+			line = line[idx+1:]
+		}
+
+		pos := 0
+		for _, loc := range ansiEscape.FindAllStringIndex(line, -1) {
+			writeANSISpan(&b, line[pos:loc[0]], style)
+			seq := line[loc[0]:loc[1]]
+			if strings.HasSuffix(seq, "m") {
+				style = applySGR(style, seq[2:len(seq)-1])
+			}
+			pos = loc[1]
+		}
+		writeANSISpan(&b, line[pos:], style)
+	}
+
+	b.WriteString("</pre>")
+	return b.String()
+}
+
+// writeANSISpan writes escaped text wrapped in a span for the active style
+func writeANSISpan(b *strings.Builder, text string, style ansiStyle) {
+	if text == "" {
+		return
+	}
+	css := style.css()
+	if css == "" {
+		b.WriteString(html.EscapeString(text))
+		return
+	}
+	fmt.Fprintf(b, `<span style="%s">%s</span>`, css, html.EscapeString(text))
+}
+
+// applySGR updates the style with a semicolon separated SGR parameter list
+func applySGR(style ansiStyle, params string) ansiStyle {
+	if params == "" {
+		return ansiStyle{}
+	}
+
+	codes := strings.Split(params, ";")
+	for i := 0; i < len(codes); i++ {
+		code, err := strconv.Atoi(codes[i])
+		if err != nil {
+			continue
+		}
+		switch {
+		case code == 0:
+			style = ansiStyle{}
+		case code == 1:
+			style.bold = true
+		case code == 2:
+			style.dim = true
+		case code == 3:
+			style.italic = true
+		case code == 4:
+			style.underline = true
+		case code == 22:
+			style.bold, style.dim = false, false
+		case code == 23:
+			style.italic = false
+		case code == 24:
+			style.underline = false
+		case code >= 30 && code <= 37:
+			style.fg = ansiPalette[code-30]
+		case code >= 90 && code <= 97:
+			style.fg = ansiPalette[code-90+8]
+		case code == 39:
+			style.fg = ""
+		case code >= 40 && code <= 47:
+			style.bg = ansiPalette[code-40]
+		case code >= 100 && code <= 107:
+			style.bg = ansiPalette[code-100+8]
+		case code == 49:
+			style.bg = ""
+		case code == 38 || code == 48:
+			color, consumed := extendedColor(codes[i+1:])
+			i += consumed
+			if color != "" {
+				if code == 38 {
+					style.fg = color
+				} else {
+					style.bg = color
+				}
+			}
+		}
+	}
+	return style
+}
+
+// extendedColor parses the arguments of a 38/48 sequence (5;n or 2;r;g;b) and
+// returns the CSS color and the number of parameters consumed
+func extendedColor(args []string) (string, int) {
+	if len(args) == 0 {
+		return "", 0
+	}
+	switch args[0] {
+	case "5":
+		if len(args) < 2 {
+			return "", len(args)
+		}
+		n, err := strconv.Atoi(args[1])
+		if err != nil || n < 0 || n > 255 {
+			return "", 2
+		}
+		return xterm256(n), 2
+	case "2":
+		if len(args) < 4 {
+			return "", len(args)
+		}
+		var rgbVals [3]int
+		for j := 0; j < 3; j++ {
+			v, err := strconv.Atoi(args[1+j])
+			if err != nil || v < 0 || v > 255 {
+				return "", 4
+			}
+			rgbVals[j] = v
+		}
+		return fmt.Sprintf("#%02x%02x%02x", rgbVals[0], rgbVals[1], rgbVals[2]), 4
+	}
+	return "", 1
+}
+
+// xterm256 maps an xterm 256-color index to a CSS color
+func xterm256(n int) string {
+	switch {
+	case n < 16:
+		return ansiPalette[n]
+	case n < 232:
+		n -= 16
+		levels := [6]int{0, 95, 135, 175, 215, 255}
+		return fmt.Sprintf("#%02x%02x%02x", levels[n/36], levels[(n/6)%6], levels[n%6])
+	default:
+		gray := 8 + (n-232)*10
+		return fmt.Sprintf("#%02x%02x%02x", gray, gray, gray)
+	}
+}
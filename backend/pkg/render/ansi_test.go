@@ -0,0 +1,37 @@
+package render
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestANSIToHTML(t *testing.T) {
+	out := ANSIToHTML("\x1b[1;31mFAIL\x1b[0m ok <tag>\n\x1b[38;5;208morange\x1b[39m", ThemeByName(""))
+
+	if !strings.Contains(out, `<span style="color:#cd3131;font-weight:bold">FAIL</span>`) {
+		t.Errorf("Expected bold red span, got %s", out)
+	}
+	if !strings.Contains(out, " ok &lt;tag&gt;") {
+		t.Errorf("Expected plain text to be escaped, got %s", out)
+	}
+	if !strings.Contains(out, `<span style="color:#ff8700">orange</span>`) {
+		t.Errorf("Expected 256-color span, got %s", out)
+	}
+	if strings.Contains(out, "\x1b") {
+		t.Error("Expected all escape sequences to be removed")
+	}
+}
+
+func TestANSIToHTML_CarriageReturn(t *testing.T) {
+	out := ANSIToHTML("progress 10%\rprogress 100%", ThemeByName(""))
+
+	if strings.Contains(out, "10%progress") || !strings.Contains(out, "progress 100%") {
+		t.Errorf("Expected only the final carriage-return segment, got %s", out)
+	}
+}
+
+func TestStripANSI(t *testing.T) {
+	if got := StripANSI("\x1b]0;title\x07\x1b[32mgreen\x1b[0m"); got != "green" {
+		t.Errorf("Expected escape sequences to be stripped, got %q", got)
+	}
+}
@@ -0,0 +1,56 @@
+package render
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	_ "image/gif" // Register decoders for the accepted upload formats
+	_ "image/jpeg"
+	"image/png"
+
+	"golang.org/x/image/draw"
+)
+
+// Avatar dimensions in pixels
+const (
+	AvatarSize         = 256  // Width and height of stored avatars
+	maxAvatarDimension = 4096 // Larger uploads are refused before decoding
+)
+
+// ErrUnsupportedAvatar is returned for uploads that are not a PNG, JPEG or
+// GIF image within the maximum dimensions
+var ErrUnsupportedAvatar = errors.New("avatar must be a PNG, JPEG or GIF image of at most 4096x4096 pixels")
+
+// ResizeAvatar crops an uploaded image to a centered square and scales it
+// to AvatarSize, returning it encoded as PNG. Only the first frame of an
+// animated GIF is kept.
+func ResizeAvatar(data []byte) ([]byte, error) {
+	// Check the dimensions from the header first so a small file cannot
+	// make the decoder allocate a huge image
+	config, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil || (format != "png" && format != "jpeg" && format != "gif") {
+		return nil, ErrUnsupportedAvatar
+	}
+	if config.Width == 0 || config.Height == 0 || config.Width > maxAvatarDimension || config.Height > maxAvatarDimension {
+		return nil, ErrUnsupportedAvatar
+	}
+
+	src, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, ErrUnsupportedAvatar
+	}
+
+	b := src.Bounds()
+	side := min(b.Dx(), b.Dy())
+	x, y := b.Min.X+(b.Dx()-side)/2, b.Min.Y+(b.Dy()-side)/2
+	crop := image.Rect(x, y, x+side, y+side)
+
+	dst := image.NewRGBA(image.Rect(0, 0, AvatarSize, AvatarSize))
+	draw.CatmullRom.Scale(dst, dst.Bounds(), src, crop, draw.Src, nil)
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, dst); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
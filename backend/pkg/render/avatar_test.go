@@ -0,0 +1,49 @@
+package render
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"testing"
+)
+
+func TestResizeAvatar(t *testing.T) {
+	// A wide image whose center is red and whose sides are blue
+	src := image.NewRGBA(image.Rect(0, 0, 600, 200))
+	for y := 0; y < 200; y++ {
+		for x := 0; x < 600; x++ {
+			c := color.RGBA{B: 255, A: 255}
+			if x >= 200 && x < 400 {
+				c = color.RGBA{R: 255, A: 255}
+			}
+			src.Set(x, y, c)
+		}
+	}
+	var upload bytes.Buffer
+	png.Encode(&upload, src)
+
+	data, err := ResizeAvatar(upload.Bytes())
+	if err != nil {
+		t.Fatalf("Failed to resize avatar: %v", err)
+	}
+	img, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("Output is not a valid PNG: %v", err)
+	}
+	if b := img.Bounds(); b.Dx() != AvatarSize || b.Dy() != AvatarSize {
+		t.Errorf("Expected a %dx%d avatar, got %v", AvatarSize, AvatarSize, b)
+	}
+	if r, _, b, _ := img.At(0, AvatarSize/2).RGBA(); r>>8 != 255 || b != 0 {
+		t.Error("Expected the image to be cropped to its center")
+	}
+
+	if _, err := ResizeAvatar([]byte("<svg></svg>")); err != ErrUnsupportedAvatar {
+		t.Errorf("Expected non-image uploads to be refused, got %v", err)
+	}
+	var huge bytes.Buffer
+	png.Encode(&huge, image.NewGray(image.Rect(0, 0, maxAvatarDimension+1, 1)))
+	if _, err := ResizeAvatar(huge.Bytes()); err != ErrUnsupportedAvatar {
+		t.Errorf("Expected oversized images to be refused, got %v", err)
+	}
+}
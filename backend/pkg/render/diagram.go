@@ -0,0 +1,91 @@
+package render
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Limits applied to calls to the diagram rendering service
+const (
+	diagramTimeout     = 10 * time.Second
+	maxDiagramSVGBytes = 5 << 20
+)
+
+// SVGContentSecurityPolicy is served with rendered diagrams. Diagram SVGs may
+// embed styles and fonts but must never execute scripts.
+const SVGContentSecurityPolicy = "default-src 'none'; img-src data:; style-src 'unsafe-inline'; font-src data:"
+
+// ErrInvalidDiagram is returned when the renderer rejects the diagram source
+var ErrInvalidDiagram = errors.New("invalid diagram source")
+
+// diagramTypes maps paste languages onto the diagram types understood by the renderer
+var diagramTypes = map[string]string{
+	"mermaid":  "mermaid",
+	"mmd":      "mermaid",
+	"plantuml": "plantuml",
+	"puml":     "plantuml",
+	"uml":      "plantuml",
+	"graphviz": "graphviz",
+	"dot":      "graphviz",
+}
+
+// DiagramType returns the renderer diagram type for a paste language, or
+// false if the language is not a supported diagram language
+func DiagramType(language string) (string, bool) {
+	t, ok := diagramTypes[strings.ToLower(strings.TrimSpace(language))]
+	return t, ok
+}
+
+// DiagramRenderer renders diagram sources to SVG using a Kroki-compatible
+// service, so diagram tooling runs outside the API process
+type DiagramRenderer struct {
+	baseURL string
+	client  *http.Client
+}
+
+// NewDiagramRenderer creates a renderer for the service at baseURL
+func NewDiagramRenderer(baseURL string) *DiagramRenderer {
+	return &DiagramRenderer{
+		baseURL: strings.TrimRight(baseURL, "/"),
+		client:  &http.Client{Timeout: diagramTimeout},
+	}
+}
+
+// RenderSVG renders source of the given diagram type to an SVG document
+func (d *DiagramRenderer) RenderSVG(ctx context.Context, diagramType, source string) ([]byte, error) {
+	url := fmt.Sprintf("%s/%s/svg", d.baseURL, diagramType)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, strings.NewReader(source))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "text/plain; charset=utf-8")
+	req.Header.Set("Accept", "image/svg+xml")
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("diagram renderer unavailable: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusBadRequest {
+		return nil, ErrInvalidDiagram
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("diagram renderer returned status %d", resp.StatusCode)
+	}
+
+	svg, err := io.ReadAll(io.LimitReader(resp.Body, maxDiagramSVGBytes+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read diagram: %w", err)
+	}
+	if len(svg) > maxDiagramSVGBytes {
+		return nil, fmt.Errorf("rendered diagram exceeds %d bytes", maxDiagramSVGBytes)
+	}
+
+	return svg, nil
+}
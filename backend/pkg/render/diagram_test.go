@@ -0,0 +1,52 @@
+package render
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestDiagramType(t *testing.T) {
+	if typ, ok := DiagramType("Mermaid"); !ok || typ != "mermaid" {
+		t.Errorf("Expected mermaid diagram type, got %q", typ)
+	}
+	if typ, ok := DiagramType("puml"); !ok || typ != "plantuml" {
+		t.Errorf("Expected plantuml diagram type, got %q", typ)
+	}
+	if _, ok := DiagramType("go"); ok {
+		t.Error("Expected go not to be a diagram language")
+	}
+}
+
+func TestRenderSVG(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if r.URL.Path != "/mermaid/svg" {
+			http.NotFound(w, r)
+			return
+		}
+		if string(body) == "broken" {
+			http.Error(w, "syntax error", http.StatusBadRequest)
+			return
+		}
+		w.Write([]byte("<svg></svg>"))
+	}))
+	defer server.Close()
+
+	renderer := NewDiagramRenderer(server.URL + "/")
+
+	svg, err := renderer.RenderSVG(context.Background(), "mermaid", "graph TD; A-->B")
+	if err != nil {
+		t.Fatalf("Failed to render diagram: %v", err)
+	}
+	if string(svg) != "<svg></svg>" {
+		t.Errorf("Unexpected SVG: %s", svg)
+	}
+
+	if _, err := renderer.RenderSVG(context.Background(), "mermaid", "broken"); !errors.Is(err, ErrInvalidDiagram) {
+		t.Errorf("Expected ErrInvalidDiagram, got %v", err)
+	}
+}
@@ -0,0 +1,195 @@
+package render
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// DiffLine is a single line inside a diff hunk
+type DiffLine struct {
+	Type      string `json:"type"` // "context", "add" or "delete"
+	OldNumber int    `json:"old_number,omitempty"`
+	NewNumber int    `json:"new_number,omitempty"`
+	Content   string `json:"content"`
+}
+
+// DiffHunk is a contiguous block of changes introduced by an @@ header
+type DiffHunk struct {
+	Header   string     `json:"header"`
+	OldStart int        `json:"old_start"`
+	OldLines int        `json:"old_lines"`
+	NewStart int        `json:"new_start"`
+	NewLines int        `json:"new_lines"`
+	Lines    []DiffLine `json:"lines"`
+}
+
+// DiffFile holds the hunks for one file in a unified diff
+type DiffFile struct {
+	OldPath   string     `json:"old_path"`
+	NewPath   string     `json:"new_path"`
+	Status    string     `json:"status"` // "added", "deleted", "renamed" or "modified"
+	Additions int        `json:"additions"`
+	Deletions int        `json:"deletions"`
+	Hunks     []DiffHunk `json:"hunks"`
+}
+
+// DiffStats summarizes a parsed diff
+type DiffStats struct {
+	FilesChanged int `json:"files_changed"`
+	Additions    int `json:"additions"`
+	Deletions    int `json:"deletions"`
+}
+
+// Diff is the structured form of a unified diff
+type Diff struct {
+	Files []DiffFile `json:"files"`
+	Stats DiffStats  `json:"stats"`
+}
+
+// ParseUnifiedDiff parses unified diff text (as produced by diff -u or git diff).
+// It returns an error if the content contains no file headers or hunks.
+func ParseUnifiedDiff(content string) (*Diff, error) {
+	diff := &Diff{Files: []DiffFile{}}
+	var file *DiffFile
+	var hunk *DiffHunk
+	oldLine, newLine := 0, 0
+
+	startFile := func() {
+		diff.Files = append(diff.Files, DiffFile{Status: "modified", Hunks: []DiffHunk{}})
+		file = &diff.Files[len(diff.Files)-1]
+		hunk = nil
+	}
+
+	for _, line := range SplitLines(content) {
+		switch {
+		case strings.HasPrefix(line, "diff --git "):
+			startFile()
+			parts := strings.Fields(line)
+			if len(parts) >= 4 {
+				file.OldPath = strings.TrimPrefix(parts[2], "a/")
+				file.NewPath = strings.TrimPrefix(parts[3], "b/")
+			}
+		case strings.HasPrefix(line, "--- ") && (hunk == nil || hunk.remaining() == 0):
+			// A git header already opened the file; plain diffs start a new one here
+			if file == nil || len(file.Hunks) > 0 {
+				startFile()
+			}
+			file.OldPath = diffPath(line[4:], "a/")
+			if file.OldPath == "/dev/null" {
+				file.Status = "added"
+			}
+		case strings.HasPrefix(line, "+++ ") && file != nil && hunk == nil:
+			file.NewPath = diffPath(line[4:], "b/")
+			if file.NewPath == "/dev/null" {
+				file.Status = "deleted"
+			}
+		case strings.HasPrefix(line, "rename from ") && file != nil:
+			file.OldPath = strings.TrimPrefix(line, "rename from ")
+			file.Status = "renamed"
+		case strings.HasPrefix(line, "rename to ") && file != nil:
+			file.NewPath = strings.TrimPrefix(line, "rename to ")
+			file.Status = "renamed"
+		case strings.HasPrefix(line, "new file mode") && file != nil:
+			file.Status = "added"
+		case strings.HasPrefix(line, "deleted file mode") && file != nil:
+			file.Status = "deleted"
+		case strings.HasPrefix(line, "@@"):
+			if file == nil {
+				startFile()
+			}
+			h, err := parseHunkHeader(line)
+			if err != nil {
+				return nil, err
+			}
+			file.Hunks = append(file.Hunks, h)
+			hunk = &file.Hunks[len(file.Hunks)-1]
+			oldLine, newLine = h.OldStart, h.NewStart
+		case hunk != nil && strings.HasPrefix(line, "+"):
+			hunk.Lines = append(hunk.Lines, DiffLine{Type: "add", NewNumber: newLine, Content: line[1:]})
+			newLine++
+			file.Additions++
+		case hunk != nil && strings.HasPrefix(line, "-"):
+			hunk.Lines = append(hunk.Lines, DiffLine{Type: "delete", OldNumber: oldLine, Content: line[1:]})
+			oldLine++
+			file.Deletions++
+		case hunk != nil && (strings.HasPrefix(line, " ") || line == ""):
+			if line != "" {
+				line = line[1:]
+			}
+			hunk.Lines = append(hunk.Lines, DiffLine{Type: "context", OldNumber: oldLine, NewNumber: newLine, Content: line})
+			oldLine++
+			newLine++
+		}
+	}
+
+	hunks := 0
+	for _, f := range diff.Files {
+		hunks += len(f.Hunks)
+		diff.Stats.Additions += f.Additions
+		diff.Stats.Deletions += f.Deletions
+	}
+	if hunks == 0 {
+		return nil, fmt.Errorf("no diff hunks found")
+	}
+	diff.Stats.FilesChanged = len(diff.Files)
+
+	return diff, nil
+}
+
+// remaining returns how many old/new lines the hunk header still expects
+func (h *DiffHunk) remaining() int {
+	oldSeen, newSeen := 0, 0
+	for _, l := range h.Lines {
+		if l.Type != "add" {
+			oldSeen++
+		}
+		if l.Type != "delete" {
+			newSeen++
+		}
+	}
+	return (h.OldLines - oldSeen) + (h.NewLines - newSeen)
+}
+
+// diffPath strips the a/ or b/ prefix and any trailing timestamp from a file header
+func diffPath(header, prefix string) string {
+	if i := strings.IndexByte(header, '\t'); i >= 0 {
+		header = header[:i]
+	}
+	return strings.TrimPrefix(strings.TrimSpace(header), prefix)
+}
+
+// parseHunkHeader parses "@@ -l,s +l,s @@ optional section"
+func parseHunkHeader(line string) (DiffHunk, error) {
+	h := DiffHunk{Header: line, Lines: []DiffLine{}}
+
+	fields := strings.Fields(line)
+	if len(fields) < 3 || !strings.HasPrefix(fields[1], "-") || !strings.HasPrefix(fields[2], "+") {
+		return h, fmt.Errorf("invalid hunk header: %q", line)
+	}
+
+	var err error
+	if h.OldStart, h.OldLines, err = parseRange(fields[1][1:]); err != nil {
+		return h, err
+	}
+	if h.NewStart, h.NewLines, err = parseRange(fields[2][1:]); err != nil {
+		return h, err
+	}
+	return h, nil
+}
+
+// parseRange parses "start,count" where count defaults to 1
+func parseRange(s string) (int, int, error) {
+	startStr, countStr, hasCount := strings.Cut(s, ",")
+	start, err := strconv.Atoi(startStr)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid hunk range: %q", s)
+	}
+	count := 1
+	if hasCount {
+		if count, err = strconv.Atoi(countStr); err != nil {
+			return 0, 0, fmt.Errorf("invalid hunk range: %q", s)
+		}
+	}
+	return start, count, nil
+}
@@ -0,0 +1,80 @@
+package render
+
+import "testing"
+
+const sampleGitDiff = `diff --git a/main.go b/main.go
+index 83db48f..bf269f4 100644
+--- a/main.go
++++ b/main.go
+@@ -1,4 +1,5 @@
+ package main
+ 
+-import "fmt"
++import (
++	"fmt"
++)
+ func main() {}
+diff --git a/new.txt b/new.txt
+new file mode 100644
+--- /dev/null
++++ b/new.txt
+@@ -0,0 +1 @@
++hello
+`
+
+func TestParseUnifiedDiff(t *testing.T) {
+	diff, err := ParseUnifiedDiff(sampleGitDiff)
+	if err != nil {
+		t.Fatalf("Failed to parse diff: %v", err)
+	}
+
+	if diff.Stats.FilesChanged != 2 {
+		t.Errorf("Expected 2 files changed, got %d", diff.Stats.FilesChanged)
+	}
+	if diff.Stats.Additions != 4 || diff.Stats.Deletions != 1 {
+		t.Errorf("Expected +4/-1, got +%d/-%d", diff.Stats.Additions, diff.Stats.Deletions)
+	}
+
+	first := diff.Files[0]
+	if first.OldPath != "main.go" || first.NewPath != "main.go" || first.Status != "modified" {
+		t.Errorf("Unexpected first file header: %+v", first)
+	}
+
+	lines := first.Hunks[0].Lines
+	if lines[2].Type != "delete" || lines[2].OldNumber != 3 {
+		t.Errorf("Expected deletion of old line 3, got %+v", lines[2])
+	}
+	if lines[3].Type != "add" || lines[3].NewNumber != 3 {
+		t.Errorf("Expected addition of new line 3, got %+v", lines[3])
+	}
+
+	if diff.Files[1].Status != "added" || diff.Files[1].NewPath != "new.txt" {
+		t.Errorf("Expected new.txt to be added, got %+v", diff.Files[1])
+	}
+}
+
+func TestParseUnifiedDiff_PlainMultiFile(t *testing.T) {
+	content := "--- a.txt\t2024-01-01\n+++ a.txt\t2024-01-02\n@@ -1 +1 @@\n-old\n+new\n" +
+		"--- b.txt\n+++ b.txt\n@@ -1,2 +1 @@\n--- not a header\n kept\n"
+
+	diff, err := ParseUnifiedDiff(content)
+	if err != nil {
+		t.Fatalf("Failed to parse diff: %v", err)
+	}
+
+	if len(diff.Files) != 2 {
+		t.Fatalf("Expected 2 files, got %d", len(diff.Files))
+	}
+	if diff.Files[0].OldPath != "a.txt" {
+		t.Errorf("Expected timestamp to be stripped from path, got %q", diff.Files[0].OldPath)
+	}
+	if diff.Files[1].Deletions != 1 || diff.Files[1].Hunks[0].Lines[0].Content != "-- not a header" {
+		t.Errorf("Expected '---' inside a hunk to be a deletion, got %+v", diff.Files[1].Hunks[0].Lines)
+	}
+}
+
+func TestParseUnifiedDiff_NotADiff(t *testing.T) {
+	if _, err := ParseUnifiedDiff("just some text\nwithout hunks"); err == nil {
+		t.Error("Expected error for content without hunks")
+	}
+}
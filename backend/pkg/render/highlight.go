@@ -0,0 +1,326 @@
+package render
+
+import (
+	"strings"
+	"unicode"
+)
+
+// TokenKind classifies a highlighted span of source text
+type TokenKind int
+
+const (
+	TokenText TokenKind = iota
+	TokenKeyword
+	TokenString
+	TokenComment
+	TokenNumber
+)
+
+// Token is a span of text with a single highlight class
+type Token struct {
+	Kind TokenKind
+	Text string
+}
+
+// Line is a single source line split into highlight tokens
+type Line []Token
+
+// syntax describes the lexical rules used to highlight a language
+type syntax struct {
+	keywords     map[string]bool
+	lineComments []string
+	blockComment [2]string
+	quotes       string
+}
+
+// words builds a keyword set from a space separated list
+func words(list string) map[string]bool {
+	set := make(map[string]bool)
+	for _, w := range strings.Fields(list) {
+		set[w] = true
+	}
+	return set
+}
+
+var (
+	cStyleSyntax = syntax{
+		keywords: words(`auto break case char const continue default do double else enum extern
+			float for goto if int long register return short signed sizeof static struct switch
+			typedef union unsigned void volatile while class public private protected new delete
+			this namespace template typename using virtual bool true false nullptr include define`),
+		lineComments: []string{"//"},
+		blockComment: [2]string{"/*", "*/"},
+		quotes:       `"'`,
+	}
+
+	syntaxes = map[string]syntax{
+		"go": {
+			keywords: words(`break case chan const continue default defer else fallthrough for func go
+				goto if import interface map package range return select struct switch type var
+				true false nil iota`),
+			lineComments: []string{"//"},
+			blockComment: [2]string{"/*", "*/"},
+			quotes:       "\"'`",
+		},
+		"javascript": {
+			keywords: words(`async await break case catch class const continue debugger default delete
+				do else export extends finally for function if import in instanceof let new of return
+				super switch this throw try typeof var void while with yield true false null undefined
+				interface type enum implements readonly`),
+			lineComments: []string{"//"},
+			blockComment: [2]string{"/*", "*/"},
+			quotes:       "\"'`",
+		},
+		"python": {
+			keywords: words(`and as assert async await break class continue def del elif else except
+				finally for from global if import in is lambda nonlocal not or pass raise return try
+				while with yield True False None self`),
+			lineComments: []string{"#"},
+			quotes:       `"'`,
+		},
+		"rust": {
+			keywords: words(`as async await break const continue crate dyn else enum extern false fn
+				for if impl in let loop match mod move mut pub ref return self Self static struct super
+				trait true type unsafe use where while`),
+			lineComments: []string{"//"},
+			blockComment: [2]string{"/*", "*/"},
+			quotes:       `"`,
+		},
+		"java": {
+			keywords: words(`abstract assert boolean break byte case catch char class const continue
+				default do double else enum extends final finally float for if implements import
+				instanceof int interface long native new package private protected public return short
+				static super switch synchronized this throw throws try void volatile while true false null`),
+			lineComments: []string{"//"},
+			blockComment: [2]string{"/*", "*/"},
+			quotes:       `"'`,
+		},
+		"ruby": {
+			keywords: words(`alias and begin break case class def defined do else elsif end ensure false
+				for if in module next nil not or redo rescue retry return self super then true undef
+				unless until when while yield require`),
+			lineComments: []string{"#"},
+			quotes:       `"'`,
+		},
+		"bash": {
+			keywords: words(`if then else elif fi case esac for while until do done in function return
+				local export readonly echo exit set unset`),
+			lineComments: []string{"#"},
+			quotes:       `"'`,
+		},
+		"sql": {
+			keywords: words(`select from where insert into values update set delete create table drop
+				alter add index primary key foreign references join left right inner outer on group
+				by order having limit offset and or not null is as distinct union all case when then
+				else end begin commit rollback SELECT FROM WHERE INSERT INTO VALUES UPDATE SET DELETE
+				CREATE TABLE DROP ALTER ADD INDEX PRIMARY KEY FOREIGN REFERENCES JOIN LEFT RIGHT INNER
+				OUTER ON GROUP BY ORDER HAVING LIMIT OFFSET AND OR NOT NULL IS AS DISTINCT UNION ALL
+				CASE WHEN THEN ELSE END BEGIN COMMIT ROLLBACK`),
+			lineComments: []string{"--"},
+			blockComment: [2]string{"/*", "*/"},
+			quotes:       `'"`,
+		},
+		"c": cStyleSyntax,
+	}
+
+	// languageAliases maps common language names onto a shared syntax
+	languageAliases = map[string]string{
+		"golang":     "go",
+		"js":         "javascript",
+		"jsx":        "javascript",
+		"ts":         "javascript",
+		"tsx":        "javascript",
+		"typescript": "javascript",
+		"py":         "python",
+		"rs":         "rust",
+		"kotlin":     "java",
+		"csharp":     "java",
+		"c#":         "java",
+		"rb":         "ruby",
+		"sh":         "bash",
+		"shell":      "bash",
+		"zsh":        "bash",
+		"cpp":        "c",
+		"c++":        "c",
+		"h":          "c",
+		"php":        "c",
+	}
+)
+
+// lookupSyntax returns the syntax rules for a language, or false if the
+// language is unknown and should be rendered as plain text
+func lookupSyntax(language string) (syntax, bool) {
+	lang := strings.ToLower(strings.TrimSpace(language))
+	if alias, ok := languageAliases[lang]; ok {
+		lang = alias
+	}
+	s, ok := syntaxes[lang]
+	return s, ok
+}
+
+// ExpandTabs replaces tab characters with spaces aligned to 4-column stops
+func ExpandTabs(line string) string {
+	if !strings.Contains(line, "\t") {
+		return line
+	}
+	var b strings.Builder
+	col := 0
+	for _, r := range line {
+		if r == '\t' {
+			spaces := 4 - col%4
+			b.WriteString(strings.Repeat(" ", spaces))
+			col += spaces
+			continue
+		}
+		b.WriteRune(r)
+		col++
+	}
+	return b.String()
+}
+
+// SplitLines splits content into lines, normalizing line endings
+func SplitLines(content string) []string {
+	content = strings.ReplaceAll(content, "\r\n", "\n")
+	content = strings.TrimSuffix(content, "\n")
+	return strings.Split(content, "\n")
+}
+
+// Highlight tokenizes content line by line using a lightweight lexer for the
+// given language. Unknown languages produce a single text token per line.
+func Highlight(content, language string) []Line {
+	rawLines := SplitLines(content)
+	lines := make([]Line, 0, len(rawLines))
+
+	syn, known := lookupSyntax(language)
+	inBlock := false
+
+	for _, raw := range rawLines {
+		raw = ExpandTabs(raw)
+		if !known {
+			lines = append(lines, Line{{Kind: TokenText, Text: raw}})
+			continue
+		}
+		var line Line
+		line, inBlock = highlightLine(raw, syn, inBlock)
+		lines = append(lines, line)
+	}
+
+	return lines
+}
+
+// highlightLine tokenizes a single line, carrying block comment state
+func highlightLine(raw string, syn syntax, inBlock bool) (Line, bool) {
+	var line Line
+	var text strings.Builder
+
+	emit := func(kind TokenKind, s string) {
+		if s == "" {
+			return
+		}
+		if kind == TokenText {
+			text.WriteString(s)
+			return
+		}
+		if text.Len() > 0 {
+			line = append(line, Token{Kind: TokenText, Text: text.String()})
+			text.Reset()
+		}
+		line = append(line, Token{Kind: kind, Text: s})
+	}
+
+	i := 0
+	for i < len(raw) {
+		rest := raw[i:]
+
+		// Continue or open a block comment
+		if inBlock || (syn.blockComment[0] != "" && strings.HasPrefix(rest, syn.blockComment[0])) {
+			start := 0
+			if !inBlock {
+				start = len(syn.blockComment[0])
+			}
+			end := strings.Index(rest[start:], syn.blockComment[1])
+			if end < 0 {
+				emit(TokenComment, rest)
+				return flush(line, &text), true
+			}
+			end += start + len(syn.blockComment[1])
+			emit(TokenComment, rest[:end])
+			inBlock = false
+			i += end
+			continue
+		}
+
+		// Line comments run to the end of the line
+		isComment := false
+		for _, prefix := range syn.lineComments {
+			if strings.HasPrefix(rest, prefix) {
+				isComment = true
+				break
+			}
+		}
+		if isComment {
+			emit(TokenComment, rest)
+			break
+		}
+
+		c := raw[i]
+		switch {
+		case strings.IndexByte(syn.quotes, c) >= 0:
+			end := i + 1
+			for end < len(raw) && raw[end] != c {
+				if raw[end] == '\\' {
+					end++
+				}
+				end++
+			}
+			if end < len(raw) {
+				end++
+			} else {
+				end = len(raw)
+			}
+			emit(TokenString, raw[i:end])
+			i = end
+		case c >= '0' && c <= '9':
+			end := i
+			for end < len(raw) && (isWordByte(raw[end]) || raw[end] == '.') {
+				end++
+			}
+			emit(TokenNumber, raw[i:end])
+			i = end
+		case isWordByte(c):
+			end := i
+			for end < len(raw) && isWordByte(raw[end]) {
+				end++
+			}
+			word := raw[i:end]
+			if syn.keywords[word] {
+				emit(TokenKeyword, word)
+			} else {
+				emit(TokenText, word)
+			}
+			i = end
+		default:
+			emit(TokenText, raw[i:i+1])
+			i++
+		}
+	}
+
+	return flush(line, &text), inBlock
+}
+
+// flush appends any pending plain text to the line
+func flush(line Line, text *strings.Builder) Line {
+	if text.Len() > 0 {
+		line = append(line, Token{Kind: TokenText, Text: text.String()})
+		text.Reset()
+	}
+	if line == nil {
+		line = Line{}
+	}
+	return line
+}
+
+// isWordByte reports whether b can be part of an identifier
+func isWordByte(b byte) bool {
+	return b == '_' || b >= 0x80 || unicode.IsLetter(rune(b)) || unicode.IsDigit(rune(b))
+}
@@ -0,0 +1,54 @@
+package render
+
+import "testing"
+
+func TestHighlight_Go(t *testing.T) {
+	lines := Highlight("func main() { // entry\n\treturn \"hi\" }", "golang")
+
+	if len(lines) != 2 {
+		t.Fatalf("Expected 2 lines, got %d", len(lines))
+	}
+
+	kinds := map[TokenKind]string{}
+	for _, line := range lines {
+		for _, tok := range line {
+			kinds[tok.Kind] += tok.Text
+		}
+	}
+
+	if kinds[TokenKeyword] != "funcreturn" {
+		t.Errorf("Expected keywords func and return, got %q", kinds[TokenKeyword])
+	}
+	if kinds[TokenComment] != "// entry" {
+		t.Errorf("Expected line comment, got %q", kinds[TokenComment])
+	}
+	if kinds[TokenString] != `"hi"` {
+		t.Errorf("Expected string literal, got %q", kinds[TokenString])
+	}
+}
+
+func TestHighlight_BlockCommentSpansLines(t *testing.T) {
+	lines := Highlight("a /* start\nstill comment\nend */ b", "c")
+
+	if lines[1][0].Kind != TokenComment {
+		t.Errorf("Expected middle line to be a comment, got %v", lines[1])
+	}
+	last := lines[2]
+	if last[0].Kind != TokenComment || last[len(last)-1].Kind != TokenText {
+		t.Errorf("Expected comment then text on last line, got %v", last)
+	}
+}
+
+func TestHighlight_UnknownLanguage(t *testing.T) {
+	lines := Highlight("if x then y", "klingon")
+
+	if len(lines) != 1 || len(lines[0]) != 1 || lines[0][0].Kind != TokenText {
+		t.Errorf("Expected a single plain text token, got %v", lines)
+	}
+}
+
+func TestExpandTabs(t *testing.T) {
+	if got := ExpandTabs("a\tb"); got != "a   b" {
+		t.Errorf("Expected tab stop at column 4, got %q", got)
+	}
+}
@@ -0,0 +1,78 @@
+package render
+
+import (
+	"encoding/base64"
+	"fmt"
+	"html"
+	"strings"
+)
+
+// HTMLContentSecurityPolicy is the policy served with rendered HTML documents.
+// Rendered pages never need scripts; only inline styles and data: images.
+const HTMLContentSecurityPolicy = "default-src 'none'; img-src data:; style-src 'unsafe-inline'"
+
+// HighlightHTML renders highlighted lines as an escaped <pre> block with inline
+// theme colors, so the output needs no external stylesheet
+func HighlightHTML(lines []Line, theme Theme, lineNumbers bool) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, `<pre class="code" style="background:%s;color:%s">`, Hex(theme.Background), Hex(theme.Foreground))
+
+	width := len(fmt.Sprintf("%d", len(lines)))
+	for i, line := range lines {
+		if lineNumbers {
+			fmt.Fprintf(&b, `<span class="ln" style="color:%s">%*d  </span>`, Hex(theme.LineNumber), width, i+1)
+		}
+		for _, tok := range line {
+			if tok.Kind == TokenText {
+				b.WriteString(html.EscapeString(tok.Text))
+				continue
+			}
+			fmt.Fprintf(&b, `<span style="color:%s">%s</span>`, Hex(theme.ColorFor(tok.Kind)), html.EscapeString(tok.Text))
+		}
+		if i < len(lines)-1 {
+			b.WriteByte('\n')
+		}
+	}
+
+	b.WriteString("</pre>")
+	return b.String()
+}
+
+// HTMLDocument wraps rendered body markup in a standalone page using the theme
+func HTMLDocument(title string, theme Theme, body string) string {
+	return fmt.Sprintf(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>%s</title>
+<style>
+body { margin: 0; padding: 1.5rem; background: %s; color: %s; font-family: system-ui, sans-serif; }
+pre { padding: 0.75rem; overflow-x: auto; font-family: ui-monospace, monospace; font-size: 0.85rem; }
+.cell { margin-bottom: 1rem; }
+.prompt { font-family: ui-monospace, monospace; font-size: 0.75rem; color: %s; }
+.output { margin: 0; }
+img { max-width: 100%%; }
+.markdown { max-width: 48rem; line-height: 1.6; }
+.markdown code { font-family: ui-monospace, monospace; font-size: 0.85em; }
+.markdown blockquote { margin: 0; padding-left: 1rem; border-left: 3px solid %s; color: %s; }
+.markdown a { color: inherit; }
+</style>
+</head>
+<body>
+%s
+</body>
+</html>
+`, html.EscapeString(title), Hex(theme.Background), Hex(theme.Foreground), Hex(theme.Comment), Hex(theme.Comment), Hex(theme.Comment), body)
+}
+
+// WatermarkOverlay returns an element tiling text diagonally across the whole
+// page, faintly and above the content, so screenshots show who took them. It
+// is drawn with a data: SVG background since rendered documents allow no
+// scripts, and ignores the pointer so the content stays selectable.
+func WatermarkOverlay(text string, theme Theme) string {
+	svg := fmt.Sprintf(`<svg xmlns="http://www.w3.org/2000/svg" width="360" height="180">`+
+		`<text x="180" y="90" text-anchor="middle" transform="rotate(-30 180 90)" font-family="sans-serif" font-size="14" fill="%s" fill-opacity="0.18">%s</text>`+
+		`</svg>`, Hex(theme.Foreground), html.EscapeString(text))
+	return fmt.Sprintf(`<div class="watermark" aria-hidden="true" style="position:fixed;inset:0;z-index:1000;pointer-events:none;background-image:url('data:image/svg+xml;base64,%s')"></div>`,
+		base64.StdEncoding.EncodeToString([]byte(svg)))
+}
@@ -0,0 +1,181 @@
+package render
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+
+	"golang.org/x/image/draw"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/basicfont"
+	"golang.org/x/image/math/fixed"
+)
+
+// Snippet image layout in unscaled pixels
+const (
+	imageFrame       = 24 // Outer padding around the window
+	imagePadding     = 16 // Padding inside the window
+	imageTitleBar    = 24 // Height of the window title bar with the traffic lights
+	imageCornerSize  = 8
+	imageMaxColumns  = 120
+	imageScale       = 2 // Upscale factor so text stays crisp on high-DPI screens
+	DefaultImageRows = 30
+	MaxImageRows     = 100
+)
+
+// ImageOptions controls how a snippet image is rendered
+type ImageOptions struct {
+	Theme       Theme
+	LineNumbers bool
+	MaxLines    int
+}
+
+// RenderPNG renders the first MaxLines lines of content as a carbon-style,
+// syntax-highlighted PNG image
+func RenderPNG(w io.Writer, content, language string, opts ImageOptions) error {
+	if opts.MaxLines <= 0 {
+		opts.MaxLines = DefaultImageRows
+	}
+
+	lines := Highlight(content, language)
+	truncated := len(lines) > opts.MaxLines
+	if truncated {
+		lines = lines[:opts.MaxLines]
+	}
+
+	face := basicfont.Face7x13
+	advance := face.Advance
+	lineHeight := face.Height + 3
+
+	gutter := 0
+	if opts.LineNumbers {
+		gutter = len(fmt.Sprintf("%d", len(lines))) + 2
+	}
+
+	columns := 0
+	for _, line := range lines {
+		n := 0
+		for _, tok := range line {
+			n += len([]rune(tok.Text))
+		}
+		if n > columns {
+			columns = n
+		}
+	}
+	if columns > imageMaxColumns {
+		columns = imageMaxColumns
+	}
+	if columns < 20 {
+		columns = 20
+	}
+
+	rows := len(lines)
+	if truncated {
+		rows++
+	}
+
+	winWidth := 2*imagePadding + (gutter+columns)*advance
+	winHeight := imageTitleBar + 2*imagePadding + rows*lineHeight
+	width, height := winWidth+2*imageFrame, winHeight+2*imageFrame
+
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.Draw(img, img.Bounds(), image.NewUniform(frameColor(opts.Theme)), image.Point{}, draw.Src)
+
+	window := image.Rect(imageFrame, imageFrame, imageFrame+winWidth, imageFrame+winHeight)
+	fillRoundedRect(img, window, imageCornerSize, opts.Theme.Background)
+
+	// Traffic light buttons
+	for i, c := range []color.RGBA{rgb(0xff, 0x5f, 0x56), rgb(0xff, 0xbd, 0x2e), rgb(0x27, 0xc9, 0x3f)} {
+		fillCircle(img, window.Min.X+imagePadding+i*18, window.Min.Y+imageTitleBar/2+2, 6, c)
+	}
+
+	drawer := &font.Drawer{Dst: img, Face: face}
+	baseline := window.Min.Y + imageTitleBar + imagePadding + face.Ascent
+
+	for i, line := range lines {
+		x := window.Min.X + imagePadding
+		if gutter > 0 {
+			label := fmt.Sprintf("%*d  ", gutter-2, i+1)
+			x = drawText(drawer, x, baseline, label, opts.Theme.LineNumber)
+		}
+
+		remaining := columns
+		for _, tok := range line {
+			text := []rune(tok.Text)
+			if len(text) > remaining {
+				text = text[:remaining]
+			}
+			x = drawText(drawer, x, baseline, string(text), opts.Theme.ColorFor(tok.Kind))
+			remaining -= len(text)
+			if remaining == 0 {
+				break
+			}
+		}
+		baseline += lineHeight
+	}
+
+	if truncated {
+		drawText(drawer, window.Min.X+imagePadding, baseline, "...", opts.Theme.Comment)
+	}
+
+	scaled := image.NewRGBA(image.Rect(0, 0, width*imageScale, height*imageScale))
+	draw.NearestNeighbor.Scale(scaled, scaled.Bounds(), img, img.Bounds(), draw.Src, nil)
+
+	return png.Encode(w, scaled)
+}
+
+// drawText draws text at the given position and returns the next x position
+func drawText(d *font.Drawer, x, baseline int, text string, c color.RGBA) int {
+	d.Src = image.NewUniform(c)
+	d.Dot = fixed.P(x, baseline)
+	d.DrawString(text)
+	return d.Dot.X.Round()
+}
+
+// frameColor picks a backdrop that contrasts with the theme background
+func frameColor(t Theme) color.RGBA {
+	bg := t.Background
+	if int(bg.R)+int(bg.G)+int(bg.B) > 3*128 {
+		return rgb(0xab, 0xb8, 0xc3)
+	}
+	return rgb(0x4a, 0x55, 0x68)
+}
+
+// fillRoundedRect fills r with c, leaving the corners rounded by radius
+func fillRoundedRect(img *image.RGBA, r image.Rectangle, radius int, c color.RGBA) {
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			cx, cy := x, y
+			switch {
+			case x < r.Min.X+radius:
+				cx = r.Min.X + radius
+			case x >= r.Max.X-radius:
+				cx = r.Max.X - radius - 1
+			}
+			switch {
+			case y < r.Min.Y+radius:
+				cy = r.Min.Y + radius
+			case y >= r.Max.Y-radius:
+				cy = r.Max.Y - radius - 1
+			}
+			dx, dy := x-cx, y-cy
+			if dx*dx+dy*dy <= radius*radius {
+				img.SetRGBA(x, y, c)
+			}
+		}
+	}
+}
+
+// fillCircle draws a filled circle centered at (cx, cy)
+func fillCircle(img *image.RGBA, cx, cy, radius int, c color.RGBA) {
+	for y := cy - radius; y <= cy+radius; y++ {
+		for x := cx - radius; x <= cx+radius; x++ {
+			dx, dy := x-cx, y-cy
+			if dx*dx+dy*dy <= radius*radius {
+				img.SetRGBA(x, y, c)
+			}
+		}
+	}
+}
@@ -0,0 +1,33 @@
+package render
+
+import (
+	"bytes"
+	"image/png"
+	"strings"
+	"testing"
+)
+
+func TestRenderPNG(t *testing.T) {
+	var buf bytes.Buffer
+	content := strings.Repeat("fmt.Println(\"hello\")\n", 50)
+
+	err := RenderPNG(&buf, content, "go", ImageOptions{
+		Theme:       ThemeByName("dracula"),
+		LineNumbers: true,
+		MaxLines:    10,
+	})
+	if err != nil {
+		t.Fatalf("Failed to render PNG: %v", err)
+	}
+
+	img, err := png.Decode(&buf)
+	if err != nil {
+		t.Fatalf("Output is not a valid PNG: %v", err)
+	}
+
+	// 10 lines plus the truncation marker must fit; 50 lines would be far taller
+	bounds := img.Bounds()
+	if bounds.Dy() > 2*imageScale*(imageFrame+imageTitleBar+imagePadding+11*16) {
+		t.Errorf("Image height %d suggests MaxLines was ignored", bounds.Dy())
+	}
+}
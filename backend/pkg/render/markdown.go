@@ -0,0 +1,564 @@
+package render
+
+import (
+	"fmt"
+	"html"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// maxMarkdownDepth bounds the nesting of block quotes, lists and emphasis,
+// so hostile input cannot recurse without limit
+const maxMarkdownDepth = 16
+
+var (
+	mdHeading  = regexp.MustCompile(`^ {0,3}(#{1,6})(?:[ \t]+(.*?))?(?:[ \t]+#+)?[ \t]*$`)
+	mdFence    = regexp.MustCompile("^( {0,3})(`{3,}|~{3,})[ \t]*([^`\\s]*)")
+	mdListItem = regexp.MustCompile(`^( {0,3})([-*+]|\d{1,9}[.)])([ \t]+|$)`)
+	mdSetext   = regexp.MustCompile(`^ {0,3}(=+|-+)[ \t]*$`)
+	mdAutolink = regexp.MustCompile(`^<((?:https?://|mailto:)[^\s<>]+)>`)
+	mdDataImg  = regexp.MustCompile(`^data:image/(?:png|gif|jpeg|webp);base64,[A-Za-z0-9+/=]+$`)
+)
+
+// RenderMarkdown renders Markdown as an HTML fragment. It covers the common
+// CommonMark blocks (headings, paragraphs, lists, block quotes, fenced and
+// indented code, rules) and inlines (emphasis, code, links, images,
+// autolinks) plus ~~strikethrough~~. The output is sanitized by
+// construction: raw HTML is escaped rather than passed through, links are
+// limited to http, https, mailto and relative URLs, and images are only
+// shown for data: URLs, the only ones rendered documents may load; others
+// become links. Fenced code is highlighted with the theme.
+func RenderMarkdown(content string, theme Theme) string {
+	content = strings.ReplaceAll(content, "\r\n", "\n")
+	lines := strings.Split(strings.TrimRight(content, "\n"), "\n")
+	for i, line := range lines {
+		lines[i] = expandLeadingTabs(line)
+	}
+
+	var b strings.Builder
+	b.WriteString(`<div class="markdown">`)
+	renderMarkdownBlocks(&b, lines, theme, false, 0)
+	b.WriteString(`</div>`)
+	return b.String()
+}
+
+// expandLeadingTabs replaces tabs in the indentation of a line with spaces
+// up to the next multiple of four, so indentation can be counted in spaces
+func expandLeadingTabs(line string) string {
+	if !strings.Contains(line, "\t") {
+		return line
+	}
+	var b strings.Builder
+	col := 0
+	for i := 0; i < len(line); i++ {
+		switch line[i] {
+		case ' ':
+			b.WriteByte(' ')
+			col++
+		case '\t':
+			n := 4 - col%4
+			b.WriteString(strings.Repeat(" ", n))
+			col += n
+		default:
+			b.WriteString(line[i:])
+			return b.String()
+		}
+	}
+	return b.String()
+}
+
+func isBlankLine(line string) bool {
+	return strings.TrimSpace(line) == ""
+}
+
+// indentOf counts the leading spaces of a line
+func indentOf(line string) int {
+	return len(line) - len(strings.TrimLeft(line, " "))
+}
+
+// isThematicBreak reports whether a line is a rule of three or more -, *
+// or _ characters
+func isThematicBreak(line string) bool {
+	if indentOf(line) > 3 {
+		return false
+	}
+	s := strings.ReplaceAll(strings.TrimSpace(line), " ", "")
+	if len(s) < 3 || !strings.ContainsRune("-*_", rune(s[0])) {
+		return false
+	}
+	return strings.Count(s, s[:1]) == len(s)
+}
+
+// isBlockQuote reports whether a line starts a block quote
+func isBlockQuote(line string) bool {
+	return indentOf(line) <= 3 && strings.HasPrefix(strings.TrimLeft(line, " "), ">")
+}
+
+// interruptsParagraph reports whether a line ends the paragraph before it.
+// Like CommonMark, only bullets and lists starting at 1 with content do, so
+// a wrapped line such as "2019. was a good year" stays in its paragraph.
+func interruptsParagraph(line string) bool {
+	if mdFence.MatchString(line) || mdHeading.MatchString(line) || isThematicBreak(line) || isBlockQuote(line) {
+		return true
+	}
+	m := mdListItem.FindStringSubmatch(line)
+	if m == nil || isBlankLine(line[len(m[0]):]) {
+		return false
+	}
+	marker := m[2]
+	return marker == "-" || marker == "*" || marker == "+" || marker[:len(marker)-1] == "1"
+}
+
+// renderMarkdownBlocks renders a run of block-level lines. Tight list items
+// render their paragraphs without <p> wrappers.
+func renderMarkdownBlocks(b *strings.Builder, lines []string, theme Theme, tight bool, depth int) {
+	if depth > maxMarkdownDepth {
+		fmt.Fprintf(b, "<p>%s</p>", html.EscapeString(strings.Join(lines, "\n")))
+		return
+	}
+
+	for i := 0; i < len(lines); {
+		line := lines[i]
+		switch {
+		case isBlankLine(line):
+			i++
+		case mdFence.MatchString(line):
+			i = renderFencedCode(b, lines, i, theme)
+		case indentOf(line) >= 4:
+			var code []string
+			for i < len(lines) && (indentOf(lines[i]) >= 4 || isBlankLine(lines[i])) {
+				code = append(code, strings.TrimPrefix(lines[i], "    "))
+				i++
+			}
+			for len(code) > 0 && isBlankLine(code[len(code)-1]) {
+				code = code[:len(code)-1]
+			}
+			fmt.Fprintf(b, "<pre><code>%s\n</code></pre>", html.EscapeString(strings.Join(code, "\n")))
+		case mdHeading.MatchString(line):
+			m := mdHeading.FindStringSubmatch(line)
+			level := len(m[1])
+			fmt.Fprintf(b, "<h%d>", level)
+			renderMarkdownInline(b, strings.TrimSpace(m[2]), depth)
+			fmt.Fprintf(b, "</h%d>", level)
+			i++
+		case isThematicBreak(line):
+			b.WriteString("<hr>")
+			i++
+		case isBlockQuote(line):
+			var quoted []string
+			for i < len(lines) && isBlockQuote(lines[i]) {
+				l := strings.TrimPrefix(strings.TrimLeft(lines[i], " "), ">")
+				quoted = append(quoted, strings.TrimPrefix(l, " "))
+				i++
+			}
+			b.WriteString("<blockquote>")
+			renderMarkdownBlocks(b, quoted, theme, false, depth+1)
+			b.WriteString("</blockquote>")
+		case mdListItem.MatchString(line):
+			i = renderMarkdownList(b, lines, i, theme, depth)
+		default:
+			i = renderParagraph(b, lines, i, tight, depth)
+		}
+	}
+}
+
+// renderFencedCode renders the fenced code block starting at lines[start]
+// and returns the index of the line after it
+func renderFencedCode(b *strings.Builder, lines []string, start int, theme Theme) int {
+	m := mdFence.FindStringSubmatch(lines[start])
+	indent, fence, language := len(m[1]), m[2], m[3]
+
+	var code []string
+	i := start + 1
+	for ; i < len(lines); i++ {
+		l := lines[i]
+		if trimmed := strings.TrimSpace(l); indentOf(l) <= 3 && strings.HasPrefix(trimmed, fence) &&
+			strings.Count(trimmed, fence[:1]) == len(trimmed) {
+			i++
+			break
+		}
+		for n := 0; n < indent && strings.HasPrefix(l, " "); n++ {
+			l = l[1:]
+		}
+		code = append(code, l)
+	}
+
+	b.WriteString(HighlightHTML(Highlight(strings.Join(code, "\n"), language), theme, false))
+	return i
+}
+
+// renderParagraph renders the paragraph starting at lines[start], or a
+// setext heading when it is underlined, and returns the index of the line
+// after it
+func renderParagraph(b *strings.Builder, lines []string, start int, tight bool, depth int) int {
+	var text []string
+	i := start
+	for ; i < len(lines) && !isBlankLine(lines[i]); i++ {
+		if i > start {
+			if m := mdSetext.FindStringSubmatch(lines[i]); m != nil {
+				level := 1
+				if m[1][0] == '-' {
+					level = 2
+				}
+				fmt.Fprintf(b, "<h%d>", level)
+				renderMarkdownInline(b, strings.Join(text, "\n"), depth)
+				fmt.Fprintf(b, "</h%d>", level)
+				return i + 1
+			}
+			if interruptsParagraph(lines[i]) {
+				break
+			}
+		}
+		text = append(text, strings.TrimLeft(lines[i], " "))
+	}
+
+	// Two trailing spaces make a hard line break, as a trailing backslash does
+	for j := range text {
+		if j < len(text)-1 && strings.HasSuffix(text[j], "  ") {
+			text[j] = strings.TrimRight(text[j], " ") + `\`
+		} else {
+			text[j] = strings.TrimRight(text[j], " ")
+		}
+	}
+
+	if !tight {
+		b.WriteString("<p>")
+	}
+	renderMarkdownInline(b, strings.Join(text, "\n"), depth)
+	if !tight {
+		b.WriteString("</p>")
+	}
+	return i
+}
+
+// renderMarkdownList renders the list starting at lines[start] and returns
+// the index of the line after it. A blank line between items or inside one
+// makes the list loose, wrapping item paragraphs in <p>.
+func renderMarkdownList(b *strings.Builder, lines []string, start int, theme Theme, depth int) int {
+	first := mdListItem.FindStringSubmatch(lines[start])
+	ordered := first[2][0] >= '0' && first[2][0] <= '9'
+	delimiter := first[2][len(first[2])-1]
+
+	// sameList reports whether a marker continues this list
+	sameList := func(m []string) bool {
+		return m != nil && (m[2][0] >= '0' && m[2][0] <= '9') == ordered && m[2][len(m[2])-1] == delimiter
+	}
+
+	var items [][]string
+	loose := false
+	i := start
+	for i < len(lines) {
+		m := mdListItem.FindStringSubmatch(lines[i])
+		if !sameList(m) {
+			break
+		}
+		contentIndent := len(m[0])
+		if m[3] == "" || len(m[3]) > 4 {
+			contentIndent = len(m[1]) + len(m[2]) + 1
+		}
+		item := []string{strings.TrimLeft(lines[i][len(m[0]):], " ")}
+		i++
+
+		for i < len(lines) {
+			l := lines[i]
+			if isBlankLine(l) {
+				next := i
+				for next < len(lines) && isBlankLine(lines[next]) {
+					next++
+				}
+				if next < len(lines) && indentOf(lines[next]) >= contentIndent {
+					for ; i < next; i++ {
+						item = append(item, "")
+					}
+					loose = true
+					continue
+				}
+				break
+			}
+			if indentOf(l) >= contentIndent {
+				item = append(item, l[contentIndent:])
+			} else if mdListItem.MatchString(l) || interruptsParagraph(l) {
+				break
+			} else {
+				// Lazy continuation of the item's paragraph
+				item = append(item, strings.TrimLeft(l, " "))
+			}
+			i++
+		}
+		items = append(items, item)
+
+		if i < len(lines) && isBlankLine(lines[i]) {
+			next := i
+			for next < len(lines) && isBlankLine(lines[next]) {
+				next++
+			}
+			if next >= len(lines) || !sameList(mdListItem.FindStringSubmatch(lines[next])) {
+				break
+			}
+			loose = true
+			i = next
+		}
+	}
+
+	tag := "ul"
+	if ordered {
+		tag = "ol"
+	}
+	b.WriteString("<" + tag)
+	if ordered {
+		if n, err := strconv.Atoi(first[2][:len(first[2])-1]); err == nil && n != 1 {
+			fmt.Fprintf(b, ` start="%d"`, n)
+		}
+	}
+	b.WriteString(">")
+	for _, item := range items {
+		b.WriteString("<li>")
+		renderMarkdownBlocks(b, item, theme, !loose, depth+1)
+		b.WriteString("</li>")
+	}
+	b.WriteString("</" + tag + ">")
+	return i
+}
+
+// isASCIIPunct reports whether a byte may be backslash-escaped
+func isASCIIPunct(c byte) bool {
+	return strings.IndexByte("!\"#$%&'()*+,-./:;<=>?@[\\]^_`{|}~", c) >= 0
+}
+
+func isAlnum(c byte) bool {
+	return c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n'
+}
+
+// markdownSpecials are the bytes that may start an inline construct
+const markdownSpecials = "\\`![<*_~"
+
+// renderMarkdownInline renders inline Markdown, escaping all other text.
+// Delimiters that found no closer are remembered, so input full of
+// unmatched ones renders in linear time.
+func renderMarkdownInline(b *strings.Builder, s string, depth int) {
+	if depth > maxMarkdownDepth {
+		b.WriteString(html.EscapeString(s))
+		return
+	}
+
+	unclosed := map[string]bool{}
+	for i := 0; i < len(s); {
+		c := s[i]
+		switch {
+		case c == '\\' && i+1 < len(s) && s[i+1] == '\n':
+			b.WriteString("<br>\n")
+			i += 2
+		case c == '\\' && i+1 < len(s) && isASCIIPunct(s[i+1]):
+			b.WriteString(html.EscapeString(s[i+1 : i+2]))
+			i += 2
+		case c == '`':
+			n := len(s[i:]) - len(strings.TrimLeft(s[i:], "`"))
+			ticks := s[i : i+n]
+			if end := findCodeSpanEnd(s, i+n, ticks, unclosed); end >= 0 {
+				code := strings.ReplaceAll(s[i+n:end], "\n", " ")
+				if len(code) > 1 && code[0] == ' ' && code[len(code)-1] == ' ' && strings.TrimSpace(code) != "" {
+					code = code[1 : len(code)-1]
+				}
+				fmt.Fprintf(b, "<code>%s</code>", html.EscapeString(code))
+				i = end + n
+				continue
+			}
+			b.WriteString(ticks)
+			i += n
+		case c == '!' && i+1 < len(s) && s[i+1] == '[':
+			if text, dest, end, ok := parseMarkdownLink(s, i+1, unclosed); ok {
+				writeMarkdownImage(b, text, dest)
+				i = end
+				continue
+			}
+			b.WriteByte('!')
+			i++
+		case c == '[':
+			if text, dest, end, ok := parseMarkdownLink(s, i, unclosed); ok {
+				writeMarkdownLink(b, text, dest, depth)
+				i = end
+				continue
+			}
+			b.WriteByte('[')
+			i++
+		case c == '<':
+			if m := mdAutolink.FindStringSubmatch(s[i:]); m != nil {
+				fmt.Fprintf(b, `<a href="%s" rel="nofollow noopener noreferrer">%s</a>`, html.EscapeString(m[1]), html.EscapeString(m[1]))
+				i += len(m[0])
+				continue
+			}
+			b.WriteString("&lt;")
+			i++
+		case c == '*' || c == '_' || c == '~':
+			if end, ok := renderEmphasis(b, s, i, depth, unclosed); ok {
+				i = end
+				continue
+			}
+			run := len(s[i:]) - len(strings.TrimLeft(s[i:], s[i:i+1]))
+			b.WriteString(s[i : i+run])
+			i += run
+		default:
+			end := len(s)
+			if j := strings.IndexAny(s[i+1:], markdownSpecials); j >= 0 {
+				end = i + 1 + j
+			}
+			b.WriteString(html.EscapeString(s[i:end]))
+			i = end
+		}
+	}
+}
+
+// findCodeSpanEnd returns the start of the backtick run closing a code span
+// opened by ticks, or -1
+func findCodeSpanEnd(s string, from int, ticks string, unclosed map[string]bool) int {
+	if unclosed[ticks] {
+		return -1
+	}
+	for from < len(s) {
+		j := strings.Index(s[from:], ticks)
+		if j < 0 {
+			break
+		}
+		j += from
+		run := len(s[j:]) - len(strings.TrimLeft(s[j:], "`"))
+		if run == len(ticks) {
+			return j
+		}
+		from = j + run
+	}
+	unclosed[ticks] = true
+	return -1
+}
+
+// renderEmphasis renders the emphasis, strong emphasis or strikethrough
+// opened at s[i] and returns the index after its closer. Underscores only
+// count at word boundaries, so snake_case names stay as they are.
+func renderEmphasis(b *strings.Builder, s string, i, depth int, unclosed map[string]bool) (int, bool) {
+	c := s[i]
+	run := len(s[i:]) - len(strings.TrimLeft(s[i:], s[i:i+1]))
+
+	var delim, tag string
+	switch {
+	case c == '~' && run == 2:
+		delim, tag = "~~", "del"
+	case c == '~':
+		return 0, false
+	case run >= 2:
+		delim, tag = s[i:i+2], "strong"
+	default:
+		delim, tag = s[i:i+1], "em"
+	}
+
+	start := i + len(delim)
+	if start >= len(s) || isSpace(s[start]) || unclosed[delim] {
+		return 0, false
+	}
+	if c == '_' && i > 0 && isAlnum(s[i-1]) {
+		return 0, false
+	}
+
+	for from := start + 1; ; {
+		j := strings.Index(s[from:], delim)
+		if j < 0 {
+			unclosed[delim] = true
+			return 0, false
+		}
+		j += from
+		// Close at the end of a run, so ***both*** nests em inside strong
+		for j+len(delim) < len(s) && s[j+len(delim)] == c {
+			j++
+		}
+		after := j + len(delim)
+		if !isSpace(s[j-1]) && (c != '_' || after >= len(s) || !isAlnum(s[after])) {
+			fmt.Fprintf(b, "<%s>", tag)
+			renderMarkdownInline(b, s[start:j], depth+1)
+			fmt.Fprintf(b, "</%s>", tag)
+			return after, true
+		}
+		from = after
+	}
+}
+
+// parseMarkdownLink parses [text](destination "title") with the opening
+// bracket at s[i], returning the index after it. Link text cannot contain
+// brackets. The title is accepted but not rendered.
+func parseMarkdownLink(s string, i int, unclosed map[string]bool) (text, dest string, end int, ok bool) {
+	j := strings.IndexAny(s[i+1:], "[]")
+	if j < 0 || s[i+1+j] != ']' {
+		return "", "", 0, false
+	}
+	j += i + 1
+	if j+1 >= len(s) || s[j+1] != '(' || unclosed[")"] {
+		return "", "", 0, false
+	}
+	k := strings.IndexByte(s[j+2:], ')')
+	if k < 0 {
+		unclosed[")"] = true
+		return "", "", 0, false
+	}
+	k += j + 2
+	// One level of parentheses inside the destination, as in Wikipedia URLs
+	if strings.Count(s[j+2:k], "(") > 0 {
+		if next := strings.IndexByte(s[k+1:], ')'); next >= 0 && !strings.ContainsAny(s[k+1:k+1+next], " \n") {
+			k += next + 1
+		}
+	}
+
+	dest = strings.TrimSpace(s[j+2 : k])
+	if sp := strings.IndexAny(dest, " \t\n"); sp >= 0 {
+		dest = dest[:sp]
+	}
+	dest = strings.TrimSuffix(strings.TrimPrefix(dest, "<"), ">")
+	return s[i+1 : j], dest, k + 1, true
+}
+
+// safeLinkURL reports whether a link destination is safe to render: a
+// relative URL or an http, https or mailto one. url.Parse lowercases the
+// scheme and rejects control characters, so obfuscated javascript: URLs
+// are refused too.
+func safeLinkURL(dest string) bool {
+	u, err := url.Parse(dest)
+	if err != nil {
+		return false
+	}
+	switch u.Scheme {
+	case "", "http", "https", "mailto":
+		return true
+	}
+	return false
+}
+
+// writeMarkdownLink writes a link, or just its text when its destination is
+// not safe
+func writeMarkdownLink(b *strings.Builder, text, dest string, depth int) {
+	if !safeLinkURL(dest) {
+		renderMarkdownInline(b, text, depth+1)
+		return
+	}
+	fmt.Fprintf(b, `<a href="%s" rel="nofollow noopener noreferrer">`, html.EscapeString(dest))
+	renderMarkdownInline(b, text, depth+1)
+	b.WriteString("</a>")
+}
+
+// writeMarkdownImage writes an image embedded as a data: URL, or a link to
+// any other image, since rendered documents may not load external ones
+func writeMarkdownImage(b *strings.Builder, alt, dest string) {
+	if mdDataImg.MatchString(dest) {
+		fmt.Fprintf(b, `<img src="%s" alt="%s">`, dest, html.EscapeString(alt))
+		return
+	}
+	if alt == "" {
+		alt = "image"
+	}
+	if !safeLinkURL(dest) {
+		b.WriteString(html.EscapeString(alt))
+		return
+	}
+	fmt.Fprintf(b, `<a href="%s" rel="nofollow noopener noreferrer">%s</a>`, html.EscapeString(dest), html.EscapeString(alt))
+}
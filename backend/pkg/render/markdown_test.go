@@ -0,0 +1,106 @@
+package render
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// renderMarkdownBody renders Markdown without the wrapping div
+func renderMarkdownBody(content string) string {
+	out := RenderMarkdown(content, ThemeByName(""))
+	return strings.TrimSuffix(strings.TrimPrefix(out, `<div class="markdown">`), `</div>`)
+}
+
+func TestRenderMarkdown(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{"heading", "# Title #", "<h1>Title</h1>"},
+		{"setext heading", "Title\n-----", "<h2>Title</h2>"},
+		{"hashtag is not a heading", "#tag", "<p>#tag</p>"},
+		{"paragraphs", "one\ntwo\n\nthree", "<p>one\ntwo</p><p>three</p>"},
+		{"hard break", "one  \ntwo", "<p>one<br>\ntwo</p>"},
+		{"emphasis", "*a* **b** ***c*** ~~d~~", "<p><em>a</em> <strong>b</strong> <strong><em>c</em></strong> <del>d</del></p>"},
+		{"snake case", "some_long_name and _em_", "<p>some_long_name and <em>em</em></p>"},
+		{"unmatched delimiters", "2 * 3 ** 4 `tick", "<p>2 * 3 ** 4 `tick</p>"},
+		{"code span", "use `a < b` here", "<p>use <code>a &lt; b</code> here</p>"},
+		{"escapes", `\*not em\*`, "<p>*not em*</p>"},
+		{"link", "[site](https://example.com \"Title\")", `<p><a href="https://example.com" rel="nofollow noopener noreferrer">site</a></p>`},
+		{"link with parentheses", "[x](https://en.wikipedia.org/wiki/Go_(language))", `<p><a href="https://en.wikipedia.org/wiki/Go_(language)" rel="nofollow noopener noreferrer">x</a></p>`},
+		{"autolink", "<https://example.com/a?b=1&c=2>", `<p><a href="https://example.com/a?b=1&amp;c=2" rel="nofollow noopener noreferrer">https://example.com/a?b=1&amp;c=2</a></p>`},
+		{"external image becomes a link", "![logo](https://example.com/a.png)", `<p><a href="https://example.com/a.png" rel="nofollow noopener noreferrer">logo</a></p>`},
+		{"data image", "![dot](data:image/png;base64,iVBORw0KGgo=)", `<p><img src="data:image/png;base64,iVBORw0KGgo=" alt="dot"></p>`},
+		{"rule", "a\n\n***\n\nb", "<p>a</p><hr><p>b</p>"},
+		{"block quote", "> quoted\n> > nested", "<blockquote><p>quoted</p><blockquote><p>nested</p></blockquote></blockquote>"},
+		{"tight list", "- a\n- b\n  - c", "<ul><li>a</li><li>b<ul><li>c</li></ul></li></ul>"},
+		{"loose list", "1. a\n\n2. b", "<ol><li><p>a</p></li><li><p>b</p></li></ol>"},
+		{"ordered start", "3) a\n4) b", `<ol start="3"><li>a</li><li>b</li></ol>`},
+		{"year is not a list", "It was\n2019. A good year", "<p>It was\n2019. A good year</p>"},
+		{"indented code", "    <b>code</b>", "<pre><code>&lt;b&gt;code&lt;/b&gt;\n</code></pre>"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := renderMarkdownBody(tt.input); got != tt.expected {
+				t.Errorf("RenderMarkdown(%q)\n got: %s\nwant: %s", tt.input, got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestRenderMarkdown_FencedCode(t *testing.T) {
+	out := renderMarkdownBody("```go\nfunc main() {}\n```\nafter")
+	if !strings.HasPrefix(out, `<pre class="code"`) || !strings.Contains(out, "func") {
+		t.Errorf("Expected a highlighted code block, got %s", out)
+	}
+	if !strings.HasSuffix(out, "<p>after</p>") {
+		t.Errorf("Expected the paragraph after the fence, got %s", out)
+	}
+}
+
+func TestRenderMarkdown_Sanitized(t *testing.T) {
+	inputs := []string{
+		"<script>alert(1)</script>",
+		`<img src=x onerror="alert(1)">`,
+		"[click](javascript:alert(1))",
+		"[click](JaVaScRiPt:alert(1))",
+		"[click](java\tscript:alert(1))",
+		"[click](data:text/html;base64,PHNjcmlwdD4=)",
+		"![x](javascript:alert(1))",
+		`[x](https://example.com/"onmouseover="alert(1))`,
+		"<javascript:alert(1)>",
+		"```\"><script>\nx\n```",
+	}
+
+	// Escaped text is harmless; only live markup is checked for
+	unsafe := []string{"<script", "<img src=x", `href="javascript`, `href="data:`, `onmouseover="`}
+	for _, input := range inputs {
+		out := strings.ToLower(RenderMarkdown(input, ThemeByName("")))
+		for _, bad := range unsafe {
+			if strings.Contains(out, bad) {
+				t.Errorf("RenderMarkdown(%q) contains %q: %s", input, bad, out)
+			}
+		}
+	}
+}
+
+func TestRenderMarkdown_Pathological(t *testing.T) {
+	inputs := []string{
+		strings.Repeat("*a ", 50000),
+		strings.Repeat("[a](", 50000),
+		strings.Repeat("`` ` ", 50000),
+		strings.Repeat("> ", 5000) + "deep",
+		strings.Repeat("- ", 5000) + "deep",
+	}
+
+	for _, input := range inputs {
+		start := time.Now()
+		RenderMarkdown(input, ThemeByName(""))
+		if elapsed := time.Since(start); elapsed > 2*time.Second {
+			t.Errorf("Rendering %q... took %v", input[:10], elapsed)
+		}
+	}
+}
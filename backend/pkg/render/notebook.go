@@ -0,0 +1,149 @@
+package render
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"html"
+	"strings"
+)
+
+// notebook is the subset of the Jupyter nbformat v4 schema we render
+type notebook struct {
+	NBFormat int `json:"nbformat"`
+	Metadata struct {
+		KernelSpec struct {
+			Language string `json:"language"`
+		} `json:"kernelspec"`
+		LanguageInfo struct {
+			Name string `json:"name"`
+		} `json:"language_info"`
+	} `json:"metadata"`
+	Cells []notebookCell `json:"cells"`
+}
+
+type notebookCell struct {
+	CellType       string           `json:"cell_type"`
+	Source         multilineString  `json:"source"`
+	ExecutionCount *int             `json:"execution_count"`
+	Outputs        []notebookOutput `json:"outputs"`
+}
+
+type notebookOutput struct {
+	OutputType string                     `json:"output_type"`
+	Name       string                     `json:"name"`
+	Text       multilineString            `json:"text"`
+	Data       map[string]json.RawMessage `json:"data"`
+	EName      string                     `json:"ename"`
+	EValue     string                     `json:"evalue"`
+	Traceback  []string                   `json:"traceback"`
+}
+
+// multilineString accepts nbformat text fields stored as a string or a list of lines
+type multilineString string
+
+// UnmarshalJSON implements json.Unmarshaler
+func (m *multilineString) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err == nil {
+		*m = multilineString(s)
+		return nil
+	}
+	var lines []string
+	if err := json.Unmarshal(data, &lines); err != nil {
+		return err
+	}
+	*m = multilineString(strings.Join(lines, ""))
+	return nil
+}
+
+// IsNotebook reports whether content looks like a Jupyter notebook document
+func IsNotebook(content string) bool {
+	trimmed := strings.TrimSpace(content)
+	if !strings.HasPrefix(trimmed, "{") {
+		return false
+	}
+	var nb notebook
+	if err := json.Unmarshal([]byte(trimmed), &nb); err != nil {
+		return false
+	}
+	return nb.NBFormat >= 4 && nb.Cells != nil
+}
+
+// RenderNotebook converts a Jupyter notebook into sanitized HTML body markup.
+// All notebook text is escaped; HTML and JavaScript outputs are never passed
+// through, only their plain-text alternatives.
+func RenderNotebook(content string, theme Theme) (string, error) {
+	var nb notebook
+	if err := json.Unmarshal([]byte(content), &nb); err != nil {
+		return "", fmt.Errorf("invalid notebook JSON: %w", err)
+	}
+	if nb.NBFormat < 4 {
+		return "", fmt.Errorf("unsupported notebook format %d", nb.NBFormat)
+	}
+
+	language := nb.Metadata.LanguageInfo.Name
+	if language == "" {
+		language = nb.Metadata.KernelSpec.Language
+	}
+	if language == "" {
+		language = "python"
+	}
+
+	var b strings.Builder
+	for _, cell := range nb.Cells {
+		b.WriteString(`<div class="cell">`)
+		switch cell.CellType {
+		case "code":
+			prompt := " "
+			if cell.ExecutionCount != nil {
+				prompt = fmt.Sprintf("%d", *cell.ExecutionCount)
+			}
+			fmt.Fprintf(&b, `<div class="prompt">In [%s]:</div>`, prompt)
+			b.WriteString(HighlightHTML(Highlight(string(cell.Source), language), theme, false))
+			for _, out := range cell.Outputs {
+				b.WriteString(renderNotebookOutput(out))
+			}
+		case "markdown":
+			fmt.Fprintf(&b, `<div class="markdown">%s</div>`, strings.ReplaceAll(html.EscapeString(string(cell.Source)), "\n", "<br>"))
+		default:
+			fmt.Fprintf(&b, `<pre class="raw">%s</pre>`, html.EscapeString(string(cell.Source)))
+		}
+		b.WriteString("</div>\n")
+	}
+
+	return b.String(), nil
+}
+
+// renderNotebookOutput renders a single cell output
+func renderNotebookOutput(out notebookOutput) string {
+	switch out.OutputType {
+	case "stream":
+		return fmt.Sprintf(`<pre class="output %s">%s</pre>`, html.EscapeString(out.Name), html.EscapeString(string(out.Text)))
+	case "error":
+		trace := StripANSI(strings.Join(out.Traceback, "\n"))
+		if trace == "" {
+			trace = out.EName + ": " + out.EValue
+		}
+		return fmt.Sprintf(`<pre class="output error">%s</pre>`, html.EscapeString(trace))
+	case "execute_result", "display_data":
+		for _, mime := range []string{"image/png", "image/jpeg"} {
+			if raw, ok := out.Data[mime]; ok {
+				var data multilineString
+				if json.Unmarshal(raw, &data) == nil {
+					encoded := strings.Join(strings.Fields(string(data)), "")
+					if _, err := base64.StdEncoding.DecodeString(encoded); err == nil {
+						return fmt.Sprintf(`<img class="output" alt="output" src="data:%s;base64,%s">`, mime, encoded)
+					}
+				}
+			}
+		}
+		if raw, ok := out.Data["text/plain"]; ok {
+			var text multilineString
+			if json.Unmarshal(raw, &text) == nil {
+				return fmt.Sprintf(`<pre class="output">%s</pre>`, html.EscapeString(string(text)))
+			}
+		}
+	}
+	return ""
+}
@@ -0,0 +1,49 @@
+package render
+
+import (
+	"strings"
+	"testing"
+)
+
+const sampleNotebook = `{
+ "nbformat": 4,
+ "metadata": {"language_info": {"name": "python"}},
+ "cells": [
+  {"cell_type": "markdown", "source": ["# Title\n", "<script>alert(1)</script>"]},
+  {"cell_type": "code", "execution_count": 1, "source": "print('hi')",
+   "outputs": [
+    {"output_type": "stream", "name": "stdout", "text": ["hi\n"]},
+    {"output_type": "display_data", "data": {"text/html": "<b onclick=x>bad</b>", "text/plain": "fallback"}},
+    {"output_type": "error", "ename": "ValueError", "evalue": "x", "traceback": ["\u001b[0;31mValueError\u001b[0m: x"]}
+   ]}
+ ]
+}`
+
+func TestRenderNotebook(t *testing.T) {
+	if !IsNotebook(sampleNotebook) {
+		t.Fatal("Expected sample to be detected as a notebook")
+	}
+
+	out, err := RenderNotebook(sampleNotebook, ThemeByName(""))
+	if err != nil {
+		t.Fatalf("Failed to render notebook: %v", err)
+	}
+
+	if strings.Contains(out, "<script>") || strings.Contains(out, "onclick") {
+		t.Error("Expected notebook HTML to be sanitized")
+	}
+	for _, want := range []string{"In [1]:", "hi\n", "fallback", "ValueError: x"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("Expected output to contain %q", want)
+		}
+	}
+}
+
+func TestIsNotebook(t *testing.T) {
+	if IsNotebook(`{"cells": []}`) {
+		t.Error("Expected JSON without nbformat to be rejected")
+	}
+	if IsNotebook("print('not json')") {
+		t.Error("Expected non-JSON content to be rejected")
+	}
+}
@@ -0,0 +1,206 @@
+package render
+
+import (
+	"bytes"
+	"fmt"
+	"image/color"
+	"strings"
+)
+
+// PDF page geometry in points (A4 portrait)
+const (
+	pdfPageWidth    = 595.0
+	pdfPageHeight   = 842.0
+	pdfMargin       = 40.0
+	pdfFontSize     = 9.0
+	pdfLineHeight   = 11.0
+	pdfCharWidth    = pdfFontSize * 0.6 // Courier glyphs are 600/1000 em wide
+	pdfHeaderHeight = 24.0
+)
+
+// PDFOptions controls how a paste is laid out in a PDF export
+type PDFOptions struct {
+	Title       string
+	Theme       Theme
+	LineNumbers bool
+}
+
+// pdfRow is one printed row; long source lines wrap onto several rows
+type pdfRow struct {
+	number int // Source line number, 0 for continuation rows
+	tokens Line
+}
+
+// RenderPDF renders highlighted content as a paginated PDF document using the
+// built-in Courier font, so no font embedding is required.
+func RenderPDF(content, language string, opts PDFOptions) []byte {
+	lines := Highlight(content, language)
+
+	gutter := 0
+	if opts.LineNumbers {
+		gutter = len(fmt.Sprintf("%d", len(lines))) + 2
+	}
+	usableWidth, usableHeight := pdfPageWidth-2*pdfMargin, pdfPageHeight-2*pdfMargin-pdfHeaderHeight
+	columns := int(usableWidth/pdfCharWidth) - gutter
+	rowsPerPage := int(usableHeight / pdfLineHeight)
+
+	var rows []pdfRow
+	for i, line := range lines {
+		for j, chunk := range wrapLine(line, columns) {
+			row := pdfRow{tokens: chunk}
+			if j == 0 {
+				row.number = i + 1
+			}
+			rows = append(rows, row)
+		}
+	}
+
+	var pages [][]pdfRow
+	for start := 0; start < len(rows); start += rowsPerPage {
+		end := start + rowsPerPage
+		if end > len(rows) {
+			end = len(rows)
+		}
+		pages = append(pages, rows[start:end])
+	}
+	if len(pages) == 0 {
+		pages = append(pages, nil)
+	}
+
+	streams := make([]string, len(pages))
+	for i, page := range pages {
+		streams[i] = pdfPageStream(page, i+1, len(pages), gutter, opts)
+	}
+
+	return assemblePDF(streams, opts.Title)
+}
+
+// wrapLine splits a token line into rows of at most width characters
+func wrapLine(line Line, width int) []Line {
+	if width < 1 {
+		width = 1
+	}
+	var rows []Line
+	var current Line
+	used := 0
+	for _, tok := range line {
+		text := []rune(tok.Text)
+		for len(text) > 0 {
+			room := width - used
+			if room == 0 {
+				rows = append(rows, current)
+				current, used = nil, 0
+				room = width
+			}
+			n := len(text)
+			if n > room {
+				n = room
+			}
+			current = append(current, Token{Kind: tok.Kind, Text: string(text[:n])})
+			used += n
+			text = text[n:]
+		}
+	}
+	return append(rows, current)
+}
+
+// pdfPageStream builds the content stream for a single page
+func pdfPageStream(rows []pdfRow, pageNum, pageCount, gutter int, opts PDFOptions) string {
+	var b strings.Builder
+
+	// Page background
+	fmt.Fprintf(&b, "%s rg 0 0 %.0f %.0f re f\n", pdfColor(opts.Theme.Background), pdfPageWidth, pdfPageHeight)
+
+	// Header with title and page counter
+	top := pdfPageHeight - pdfMargin
+	fmt.Fprintf(&b, "BT /F1 %.0f Tf %s rg 1 0 0 1 %.0f %.1f Tm (%s) Tj ET\n",
+		pdfFontSize, pdfColor(opts.Theme.Comment), pdfMargin, top,
+		pdfEscape(fmt.Sprintf("%s  -  page %d of %d", opts.Title, pageNum, pageCount)))
+
+	y := top - pdfHeaderHeight
+	for _, row := range rows {
+		b.WriteString("BT ")
+		fmt.Fprintf(&b, "/F1 %.0f Tf 1 0 0 1 %.0f %.1f Tm ", pdfFontSize, pdfMargin, y)
+		if gutter > 0 {
+			label := ""
+			if row.number > 0 {
+				label = fmt.Sprintf("%d", row.number)
+			}
+			label = strings.Repeat(" ", gutter-2-len(label)) + label + "  "
+			fmt.Fprintf(&b, "%s rg (%s) Tj ", pdfColor(opts.Theme.LineNumber), pdfEscape(label))
+		}
+		for _, tok := range row.tokens {
+			fmt.Fprintf(&b, "%s rg (%s) Tj ", pdfColor(opts.Theme.ColorFor(tok.Kind)), pdfEscape(tok.Text))
+		}
+		b.WriteString("ET\n")
+		y -= pdfLineHeight
+	}
+
+	return b.String()
+}
+
+// assemblePDF writes the document objects and cross-reference table
+func assemblePDF(streams []string, title string) []byte {
+	var buf bytes.Buffer
+	var offsets []int
+
+	writeObj := func(body string) {
+		offsets = append(offsets, buf.Len())
+		fmt.Fprintf(&buf, "%d 0 obj\n%s\nendobj\n", len(offsets), body)
+	}
+
+	buf.WriteString("%PDF-1.4\n%\xe2\xe3\xcf\xd3\n")
+
+	// Objects 1-4 are fixed; pages start at object 5 as (page, contents) pairs
+	kids := make([]string, len(streams))
+	for i := range streams {
+		kids[i] = fmt.Sprintf("%d 0 R", 5+2*i)
+	}
+
+	writeObj("<< /Type /Catalog /Pages 2 0 R >>")
+	writeObj(fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(streams)))
+	writeObj("<< /Type /Font /Subtype /Type1 /BaseFont /Courier /Encoding /WinAnsiEncoding >>")
+	writeObj(fmt.Sprintf("<< /Title (%s) /Producer (PrivatePaste) >>", pdfEscape(title)))
+
+	for i, stream := range streams {
+		writeObj(fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %.0f %.0f] /Resources << /Font << /F1 3 0 R >> >> /Contents %d 0 R >>",
+			pdfPageWidth, pdfPageHeight, 6+2*i))
+		writeObj(fmt.Sprintf("<< /Length %d >>\nstream\n%s\nendstream", len(stream)+1, stream))
+	}
+
+	xref := buf.Len()
+	fmt.Fprintf(&buf, "xref\n0 %d\n0000000000 65535 f \n", len(offsets)+1)
+	for _, off := range offsets {
+		fmt.Fprintf(&buf, "%010d 00000 n \n", off)
+	}
+	fmt.Fprintf(&buf, "trailer\n<< /Size %d /Root 1 0 R /Info 4 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(offsets)+1, xref)
+
+	return buf.Bytes()
+}
+
+// pdfColor formats a color as PDF RGB operands
+func pdfColor(c color.RGBA) string {
+	return fmt.Sprintf("%.3f %.3f %.3f", float64(c.R)/255, float64(c.G)/255, float64(c.B)/255)
+}
+
+// pdfEscape escapes a string for a PDF literal, mapping characters outside
+// Latin-1 to '?' since the standard fonts only cover WinAnsi
+func pdfEscape(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		switch {
+		case r == '(' || r == ')' || r == '\\':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r < 0x20:
+			b.WriteByte(' ')
+		case r < 0x80:
+			b.WriteRune(r)
+		case r <= 0xff:
+			fmt.Fprintf(&b, "\\%03o", r)
+		default:
+			b.WriteByte('?')
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,50 @@
+package render
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestRenderPDF(t *testing.T) {
+	pdf := RenderPDF("package main\n\nfunc main() {}\n", "go", PDFOptions{
+		Title:       "Paste abc123",
+		Theme:       ThemeByName("monokai"),
+		LineNumbers: true,
+	})
+
+	if !bytes.HasPrefix(pdf, []byte("%PDF-1.4")) {
+		t.Error("Expected PDF header")
+	}
+	if !bytes.HasSuffix(pdf, []byte("%%EOF\n")) {
+		t.Error("Expected PDF trailer")
+	}
+	if !bytes.Contains(pdf, []byte("/Count 1")) {
+		t.Error("Expected a single page for short content")
+	}
+}
+
+func TestRenderPDF_Pagination(t *testing.T) {
+	content := strings.Repeat("line\n", 200)
+	pdf := RenderPDF(content, "", PDFOptions{Theme: ThemeByName("")})
+
+	if !bytes.Contains(pdf, []byte("/Count 3")) {
+		t.Error("Expected 200 lines to span 3 pages")
+	}
+}
+
+func TestPDFEscape(t *testing.T) {
+	testCases := map[string]string{
+		"plain":      "plain",
+		"f(x)":       `f\(x\)`,
+		`back\slash`: `back\\slash`,
+		"café":       `caf\351`,
+		"日本":         "??",
+	}
+
+	for input, expected := range testCases {
+		if got := pdfEscape(input); got != expected {
+			t.Errorf("pdfEscape(%q) = %q, want %q", input, got, expected)
+		}
+	}
+}
@@ -0,0 +1,196 @@
+package render
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Column types inferred for tabular data
+const (
+	ColumnInteger = "integer"
+	ColumnFloat   = "float"
+	ColumnBoolean = "boolean"
+	ColumnDate    = "date"
+	ColumnString  = "string"
+)
+
+// TableColumn describes one column of a parsed table
+type TableColumn struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+}
+
+// Table is a parsed CSV/TSV document with typed cell values
+type Table struct {
+	Columns   []TableColumn   `json:"columns"`
+	Rows      [][]interface{} `json:"rows"`
+	TotalRows int             `json:"total_rows"`
+	Truncated bool            `json:"truncated"`
+}
+
+// dateLayouts are the date formats recognized during type inference
+var dateLayouts = []string{time.RFC3339, "2006-01-02", "2006-01-02 15:04:05"}
+
+// ParseTable parses delimited content, treating the first record as a header
+// when hasHeader is set. Only the first limit data rows are returned, but
+// types are inferred from those rows and TotalRows counts every record.
+func ParseTable(content string, delimiter rune, hasHeader bool, limit int) (*Table, error) {
+	reader := csv.NewReader(strings.NewReader(content))
+	reader.Comma = delimiter
+	reader.FieldsPerRecord = -1 // Tolerate ragged rows
+	reader.LazyQuotes = true
+
+	var header []string
+	var records [][]string
+	total := 0
+	width := 0
+
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse table: %w", err)
+		}
+
+		if hasHeader && header == nil {
+			header = record
+			if len(record) > width {
+				width = len(record)
+			}
+			continue
+		}
+
+		total++
+		if len(records) < limit {
+			records = append(records, record)
+			if len(record) > width {
+				width = len(record)
+			}
+		}
+	}
+
+	if width == 0 {
+		return nil, fmt.Errorf("table is empty")
+	}
+
+	table := &Table{
+		Columns:   make([]TableColumn, width),
+		Rows:      make([][]interface{}, len(records)),
+		TotalRows: total,
+		Truncated: total > len(records),
+	}
+
+	for col := range table.Columns {
+		name := fmt.Sprintf("column_%d", col+1)
+		if col < len(header) && strings.TrimSpace(header[col]) != "" {
+			name = strings.TrimSpace(header[col])
+		}
+		table.Columns[col] = TableColumn{Name: name, Type: inferColumnType(records, col)}
+	}
+
+	for i, record := range records {
+		row := make([]interface{}, width)
+		for col := range row {
+			if col < len(record) {
+				row[col] = convertCell(record[col], table.Columns[col].Type)
+			}
+		}
+		table.Rows[i] = row
+	}
+
+	return table, nil
+}
+
+// inferColumnType picks the narrowest type that fits every non-empty cell
+func inferColumnType(records [][]string, col int) string {
+	candidates := []string{ColumnInteger, ColumnFloat, ColumnBoolean, ColumnDate}
+	seen := false
+
+	for _, record := range records {
+		if col >= len(record) {
+			continue
+		}
+		cell := strings.TrimSpace(record[col])
+		if cell == "" {
+			continue
+		}
+		seen = true
+
+		remaining := candidates[:0]
+		for _, t := range candidates {
+			if cellMatches(cell, t) {
+				remaining = append(remaining, t)
+			}
+		}
+		candidates = remaining
+		if len(candidates) == 0 {
+			return ColumnString
+		}
+	}
+
+	if !seen {
+		return ColumnString
+	}
+	return candidates[0]
+}
+
+// cellMatches reports whether a cell can be represented as the given type
+func cellMatches(cell, columnType string) bool {
+	switch columnType {
+	case ColumnInteger:
+		_, err := strconv.ParseInt(cell, 10, 64)
+		return err == nil
+	case ColumnFloat:
+		_, err := strconv.ParseFloat(cell, 64)
+		return err == nil
+	case ColumnBoolean:
+		_, err := strconv.ParseBool(cell)
+		return err == nil && !strings.ContainsAny(cell, "01") // Don't treat 0/1 columns as booleans
+	case ColumnDate:
+		for _, layout := range dateLayouts {
+			if _, err := time.Parse(layout, cell); err == nil {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// convertCell converts a raw cell into a JSON value of the column type
+func convertCell(raw, columnType string) interface{} {
+	cell := strings.TrimSpace(raw)
+	if cell == "" {
+		return nil
+	}
+
+	switch columnType {
+	case ColumnInteger:
+		v, _ := strconv.ParseInt(cell, 10, 64)
+		return v
+	case ColumnFloat:
+		v, _ := strconv.ParseFloat(cell, 64)
+		return v
+	case ColumnBoolean:
+		v, _ := strconv.ParseBool(cell)
+		return v
+	}
+	return raw
+}
+
+// TableDelimiter returns the field delimiter for a tabular language, or false
+// if the language is not tabular
+func TableDelimiter(language string) (rune, bool) {
+	switch strings.ToLower(language) {
+	case "csv":
+		return ',', true
+	case "tsv":
+		return '\t', true
+	}
+	return 0, false
+}
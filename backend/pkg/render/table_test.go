@@ -0,0 +1,57 @@
+package render
+
+import "testing"
+
+func TestParseTable(t *testing.T) {
+	content := "name,age,score,active,joined\nalice,30,9.5,true,2024-01-02\nbob,,7,false,2023-12-31\n"
+
+	table, err := ParseTable(content, ',', true, 100)
+	if err != nil {
+		t.Fatalf("Failed to parse table: %v", err)
+	}
+
+	expected := []TableColumn{
+		{"name", ColumnString},
+		{"age", ColumnInteger},
+		{"score", ColumnFloat},
+		{"active", ColumnBoolean},
+		{"joined", ColumnDate},
+	}
+	for i, col := range expected {
+		if table.Columns[i] != col {
+			t.Errorf("Column %d: expected %+v, got %+v", i, col, table.Columns[i])
+		}
+	}
+
+	if table.Rows[0][1] != int64(30) {
+		t.Errorf("Expected integer cell, got %#v", table.Rows[0][1])
+	}
+	if table.Rows[1][1] != nil {
+		t.Errorf("Expected empty cell to be null, got %#v", table.Rows[1][1])
+	}
+}
+
+func TestParseTable_LimitAndTSV(t *testing.T) {
+	content := "a\tb\n1\tx\n2\ty\n3\tz\n"
+
+	table, err := ParseTable(content, '\t', true, 2)
+	if err != nil {
+		t.Fatalf("Failed to parse table: %v", err)
+	}
+
+	if len(table.Rows) != 2 || table.TotalRows != 3 || !table.Truncated {
+		t.Errorf("Expected 2 of 3 rows with truncation, got %d of %d (truncated=%v)",
+			len(table.Rows), table.TotalRows, table.Truncated)
+	}
+}
+
+func TestParseTable_NoHeader(t *testing.T) {
+	table, err := ParseTable("1,2\n3,4\n", ',', false, 10)
+	if err != nil {
+		t.Fatalf("Failed to parse table: %v", err)
+	}
+
+	if table.Columns[0].Name != "column_1" || table.TotalRows != 2 {
+		t.Errorf("Expected generated column names and 2 rows, got %+v", table)
+	}
+}
@@ -0,0 +1,97 @@
+package render
+
+import (
+	"fmt"
+	"image/color"
+)
+
+// Theme is a color palette used when rendering highlighted code
+type Theme struct {
+	Name       string
+	Background color.RGBA
+	Foreground color.RGBA
+	Keyword    color.RGBA
+	String     color.RGBA
+	Comment    color.RGBA
+	Number     color.RGBA
+	LineNumber color.RGBA
+}
+
+// DefaultTheme is used when a paste has no theme preference
+const DefaultTheme = "github-light"
+
+// rgb is a shorthand for an opaque color
+func rgb(r, g, b uint8) color.RGBA {
+	return color.RGBA{R: r, G: g, B: b, A: 0xff}
+}
+
+// themes holds the palettes for every theme accepted by validation.AllowedThemes
+var themes = map[string]Theme{
+	"github-light": {
+		Background: rgb(0xff, 0xff, 0xff), Foreground: rgb(0x24, 0x29, 0x2e),
+		Keyword: rgb(0xd7, 0x3a, 0x49), String: rgb(0x03, 0x2f, 0x62),
+		Comment: rgb(0x6a, 0x73, 0x7d), Number: rgb(0x00, 0x5c, 0xc5), LineNumber: rgb(0xba, 0xbb, 0xbc),
+	},
+	"github-dark": {
+		Background: rgb(0x0d, 0x11, 0x17), Foreground: rgb(0xc9, 0xd1, 0xd9),
+		Keyword: rgb(0xff, 0x7b, 0x72), String: rgb(0xa5, 0xd6, 0xff),
+		Comment: rgb(0x8b, 0x94, 0x9e), Number: rgb(0x79, 0xc0, 0xff), LineNumber: rgb(0x48, 0x4f, 0x58),
+	},
+	"monokai": {
+		Background: rgb(0x27, 0x28, 0x22), Foreground: rgb(0xf8, 0xf8, 0xf2),
+		Keyword: rgb(0xf9, 0x26, 0x72), String: rgb(0xe6, 0xdb, 0x74),
+		Comment: rgb(0x75, 0x71, 0x5e), Number: rgb(0xae, 0x81, 0xff), LineNumber: rgb(0x90, 0x90, 0x8a),
+	},
+	"dracula": {
+		Background: rgb(0x28, 0x2a, 0x36), Foreground: rgb(0xf8, 0xf8, 0xf2),
+		Keyword: rgb(0xff, 0x79, 0xc6), String: rgb(0xf1, 0xfa, 0x8c),
+		Comment: rgb(0x62, 0x72, 0xa4), Number: rgb(0xbd, 0x93, 0xf9), LineNumber: rgb(0x62, 0x72, 0xa4),
+	},
+	"solarized-light": {
+		Background: rgb(0xfd, 0xf6, 0xe3), Foreground: rgb(0x65, 0x7b, 0x83),
+		Keyword: rgb(0x85, 0x99, 0x00), String: rgb(0x2a, 0xa1, 0x98),
+		Comment: rgb(0x93, 0xa1, 0xa1), Number: rgb(0xd3, 0x36, 0x82), LineNumber: rgb(0x93, 0xa1, 0xa1),
+	},
+	"solarized-dark": {
+		Background: rgb(0x00, 0x2b, 0x36), Foreground: rgb(0x83, 0x94, 0x96),
+		Keyword: rgb(0x85, 0x99, 0x00), String: rgb(0x2a, 0xa1, 0x98),
+		Comment: rgb(0x58, 0x6e, 0x75), Number: rgb(0xd3, 0x36, 0x82), LineNumber: rgb(0x58, 0x6e, 0x75),
+	},
+	"nord": {
+		Background: rgb(0x2e, 0x34, 0x40), Foreground: rgb(0xd8, 0xde, 0xe9),
+		Keyword: rgb(0x81, 0xa1, 0xc1), String: rgb(0xa3, 0xbe, 0x8c),
+		Comment: rgb(0x61, 0x6e, 0x88), Number: rgb(0xb4, 0x8e, 0xad), LineNumber: rgb(0x4c, 0x56, 0x6a),
+	},
+}
+
+// ThemeByName returns the named theme, falling back to DefaultTheme
+func ThemeByName(name string) Theme {
+	t, ok := themes[name]
+	if !ok {
+		name = DefaultTheme
+		t = themes[name]
+	}
+	t.Name = name
+	return t
+}
+
+// ColorFor returns the theme color for a token kind
+func (t Theme) ColorFor(kind TokenKind) color.RGBA {
+	switch kind {
+	case TokenKeyword:
+		return t.Keyword
+	case TokenString:
+		return t.String
+	case TokenComment:
+		return t.Comment
+	case TokenNumber:
+		return t.Number
+	default:
+		return t.Foreground
+	}
+}
+
+// Hex formats a color as a CSS hex string
+func Hex(c color.RGBA) string {
+	return fmt.Sprintf("#%02x%02x%02x", c.R, c.G, c.B)
+}
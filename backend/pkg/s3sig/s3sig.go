@@ -0,0 +1,214 @@
+// Package s3sig creates and verifies AWS Signature Version 4 presigned URLs,
+// the query string form produced by S3 tooling such as `aws s3 presign`.
+package s3sig
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Signature parameters
+const (
+	Algorithm  = "AWS4-HMAC-SHA256"
+	MaxExpires = 7 * 24 * time.Hour
+	timeFormat = "20060102T150405Z"
+	dateFormat = "20060102"
+	clockSkew  = 15 * time.Minute
+	service    = "s3"
+	terminator = "aws4_request"
+)
+
+// Errors returned when verifying presigned URLs
+var (
+	ErrMissing  = errors.New("request is not presigned")
+	ErrInvalid  = errors.New("malformed presigned URL")
+	ErrExpired  = errors.New("presigned URL has expired")
+	ErrMismatch = errors.New("signature does not match")
+)
+
+// Presigned is the signature carried by a presigned request
+type Presigned struct {
+	AccessKey     string
+	Region        string
+	date          time.Time
+	expires       time.Duration
+	signedHeaders []string
+	signature     string
+}
+
+// Parse reads the presigned URL parameters of a request. It returns
+// ErrMissing if the request carries none.
+func Parse(r *http.Request) (*Presigned, error) {
+	query := r.URL.Query()
+	if query.Get("X-Amz-Algorithm") == "" && query.Get("X-Amz-Signature") == "" {
+		return nil, ErrMissing
+	}
+	if query.Get("X-Amz-Algorithm") != Algorithm {
+		return nil, ErrInvalid
+	}
+
+	// Credential is <access key>/<yyyymmdd>/<region>/s3/aws4_request
+	credential := strings.Split(query.Get("X-Amz-Credential"), "/")
+	if len(credential) != 5 || credential[0] == "" || credential[3] != service || credential[4] != terminator {
+		return nil, ErrInvalid
+	}
+
+	date, err := time.Parse(timeFormat, query.Get("X-Amz-Date"))
+	if err != nil || date.Format(dateFormat) != credential[1] {
+		return nil, ErrInvalid
+	}
+
+	seconds, err := strconv.Atoi(query.Get("X-Amz-Expires"))
+	if err != nil || seconds < 1 || time.Duration(seconds)*time.Second > MaxExpires {
+		return nil, ErrInvalid
+	}
+
+	signedHeaders := strings.Split(query.Get("X-Amz-SignedHeaders"), ";")
+	hasHost := false
+	for _, h := range signedHeaders {
+		hasHost = hasHost || h == "host"
+	}
+	signature := query.Get("X-Amz-Signature")
+	if !hasHost || signature == "" {
+		return nil, ErrInvalid
+	}
+
+	return &Presigned{
+		AccessKey:     credential[0],
+		Region:        credential[2],
+		date:          date,
+		expires:       time.Duration(seconds) * time.Second,
+		signedHeaders: signedHeaders,
+		signature:     signature,
+	}, nil
+}
+
+// Verify checks the signature against the secret key for p.AccessKey and
+// that the URL is valid at now
+func (p *Presigned) Verify(r *http.Request, secretKey string, now time.Time) error {
+	expected := signature(secretKey, p.date, p.Region, canonicalRequest(r, p.signedHeaders))
+	if !hmac.Equal([]byte(expected), []byte(p.signature)) {
+		return ErrMismatch
+	}
+	if now.Before(p.date.Add(-clockSkew)) || now.After(p.date.Add(p.expires)) {
+		return ErrExpired
+	}
+	return nil
+}
+
+// Presign returns a GET URL for rawURL signed with the given credentials,
+// valid for expires from now
+func Presign(rawURL, accessKey, secretKey, region string, now time.Time, expires time.Duration) (string, error) {
+	return PresignMethod(http.MethodGet, rawURL, accessKey, secretKey, region, now, expires)
+}
+
+// PresignMethod is like Presign for requests of any method, such as PUT
+// or DELETE. The payload is not signed.
+func PresignMethod(method, rawURL, accessKey, secretKey, region string, now time.Time, expires time.Duration) (string, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", err
+	}
+	now = now.UTC()
+
+	query := u.Query()
+	query.Set("X-Amz-Algorithm", Algorithm)
+	query.Set("X-Amz-Credential", strings.Join([]string{accessKey, now.Format(dateFormat), region, service, terminator}, "/"))
+	query.Set("X-Amz-Date", now.Format(timeFormat))
+	query.Set("X-Amz-Expires", strconv.Itoa(int(expires/time.Second)))
+	query.Set("X-Amz-SignedHeaders", "host")
+	u.RawQuery = canonicalQuery(query)
+
+	r := &http.Request{Method: method, URL: u, Host: u.Host, Header: http.Header{}}
+	u.RawQuery += "&X-Amz-Signature=" + signature(secretKey, now, region, canonicalRequest(r, []string{"host"}))
+	return u.String(), nil
+}
+
+// canonicalRequest builds the SigV4 canonical request for a presigned URL
+func canonicalRequest(r *http.Request, signedHeaders []string) string {
+	query := r.URL.Query()
+	query.Del("X-Amz-Signature")
+
+	var headers strings.Builder
+	for _, name := range signedHeaders {
+		value := append([]string(nil), r.Header.Values(name)...)
+		if name == "host" {
+			value = []string{r.Host}
+		}
+		for i, v := range value {
+			value[i] = strings.Join(strings.Fields(v), " ")
+		}
+		headers.WriteString(name + ":" + strings.Join(value, ",") + "\n")
+	}
+
+	return strings.Join([]string{
+		r.Method,
+		uriEncode(r.URL.Path, false),
+		canonicalQuery(query),
+		headers.String(),
+		strings.Join(signedHeaders, ";"),
+		"UNSIGNED-PAYLOAD",
+	}, "\n")
+}
+
+// canonicalQuery encodes query parameters sorted by name, then value
+func canonicalQuery(query url.Values) string {
+	var pairs []string
+	for name, values := range query {
+		for _, value := range values {
+			pairs = append(pairs, uriEncode(name, true)+"="+uriEncode(value, true))
+		}
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, "&")
+}
+
+// signature computes the hex signature of a canonical request
+func signature(secretKey string, date time.Time, region, canonical string) string {
+	digest := sha256.Sum256([]byte(canonical))
+	stringToSign := strings.Join([]string{
+		Algorithm,
+		date.Format(timeFormat),
+		strings.Join([]string{date.Format(dateFormat), region, service, terminator}, "/"),
+		hex.EncodeToString(digest[:]),
+	}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+secretKey), date.Format(dateFormat))
+	key = hmacSHA256(key, region)
+	key = hmacSHA256(key, service)
+	key = hmacSHA256(key, terminator)
+	return hex.EncodeToString(hmacSHA256(key, stringToSign))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// uriEncode percent-encodes everything except unreserved characters, and
+// slashes too when encodeSlash is set
+func uriEncode(s string, encodeSlash bool) string {
+	const hexDigits = "0123456789ABCDEF"
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if 'A' <= c && c <= 'Z' || 'a' <= c && c <= 'z' || '0' <= c && c <= '9' ||
+			c == '-' || c == '_' || c == '.' || c == '~' || (c == '/' && !encodeSlash) {
+			b.WriteByte(c)
+			continue
+		}
+		b.WriteByte('%')
+		b.WriteByte(hexDigits[c>>4])
+		b.WriteByte(hexDigits[c&15])
+	}
+	return b.String()
+}
@@ -0,0 +1,118 @@
+// Package s3store reads and writes objects in an S3-compatible bucket, such
+// as AWS S3, MinIO or Cloudflare R2, using path-style presigned requests.
+package s3store
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/LonleySailor/privatepaste/backend/pkg/s3sig"
+)
+
+// requestExpiry is how long each presigned request is valid for
+const requestExpiry = 5 * time.Minute
+
+// maxObjectSize bounds the objects read, as a guard against misconfiguration
+const maxObjectSize = 64 << 20
+
+// Store is a bucket objects are stored in, under an optional key prefix
+type Store struct {
+	endpoint  string
+	bucket    string
+	prefix    string
+	region    string
+	accessKey string
+	secretKey string
+	client    *http.Client
+}
+
+// New creates a store for bucket at endpoint, such as
+// https://s3.eu-west-1.amazonaws.com. A nil client uses a default with a
+// timeout.
+func New(endpoint, bucket, prefix, region, accessKey, secretKey string, client *http.Client) *Store {
+	if client == nil {
+		client = &http.Client{Timeout: 30 * time.Second}
+	}
+	return &Store{
+		endpoint:  strings.TrimSuffix(endpoint, "/"),
+		bucket:    bucket,
+		prefix:    prefix,
+		region:    region,
+		accessKey: accessKey,
+		secretKey: secretKey,
+		client:    client,
+	}
+}
+
+// Put stores data under key, replacing any object already there
+func (s *Store) Put(key string, data []byte) error {
+	resp, err := s.do(http.MethodPut, key, data)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("s3 put %s: status %d", key, resp.StatusCode)
+	}
+	return nil
+}
+
+// Get returns the object stored under key, or nil if there is none
+func (s *Store) Get(key string) ([]byte, error) {
+	resp, err := s.do(http.MethodGet, key, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return nil, nil
+	case resp.StatusCode/100 != 2:
+		return nil, fmt.Errorf("s3 get %s: status %d", key, resp.StatusCode)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxObjectSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > maxObjectSize {
+		return nil, fmt.Errorf("s3 get %s: object too large", key)
+	}
+	return data, nil
+}
+
+// Delete removes the object stored under key; deleting a missing object
+// is not an error
+func (s *Store) Delete(key string) error {
+	resp, err := s.do(http.MethodDelete, key, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 && resp.StatusCode != http.StatusNotFound {
+		return fmt.Errorf("s3 delete %s: status %d", key, resp.StatusCode)
+	}
+	return nil
+}
+
+// do sends a presigned request for the object under key
+func (s *Store) do(method, key string, body []byte) (*http.Response, error) {
+	objectURL := s.endpoint + "/" + url.PathEscape(s.bucket) + "/" + s.prefix + key
+	signed, err := s3sig.PresignMethod(method, objectURL, s.accessKey, s.secretKey, s.region, time.Now(), requestExpiry)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequest(method, signed, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	if body == nil {
+		req.Body = http.NoBody
+		req.ContentLength = 0
+	}
+	return s.client.Do(req)
+}
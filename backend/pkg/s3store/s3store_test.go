@@ -0,0 +1,75 @@
+package s3store
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/LonleySailor/privatepaste/backend/pkg/s3sig"
+)
+
+// fakeS3 is an in-memory bucket that checks every request's signature
+type fakeS3 struct {
+	mu      sync.Mutex
+	objects map[string][]byte
+	secret  string
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	presigned, err := s3sig.Parse(r)
+	if err != nil || presigned.Verify(r, f.secret, time.Now()) != nil {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	switch r.Method {
+	case http.MethodPut:
+		data, _ := io.ReadAll(r.Body)
+		f.objects[r.URL.Path] = data
+	case http.MethodGet:
+		data, ok := f.objects[r.URL.Path]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write(data)
+	case http.MethodDelete:
+		delete(f.objects, r.URL.Path)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func TestStore(t *testing.T) {
+	bucket := &fakeS3{objects: map[string][]byte{}, secret: "secret"}
+	server := httptest.NewServer(bucket)
+	defer server.Close()
+
+	store := New(server.URL, "pastes", "attachments/", "us-east-1", "access", "secret", nil)
+	if err := store.Put("abc", []byte("hello")); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+	if _, ok := bucket.objects["/pastes/attachments/abc"]; !ok {
+		t.Errorf("Expected the object under the bucket and prefix, got %v", bucket.objects)
+	}
+
+	data, err := store.Get("abc")
+	if err != nil || string(data) != "hello" {
+		t.Errorf("Expected hello, got %q, %v", data, err)
+	}
+	if err := store.Delete("abc"); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	if data, err := store.Get("abc"); err != nil || data != nil {
+		t.Errorf("Expected a deleted object to be missing, got %q, %v", data, err)
+	}
+
+	wrongKey := New(server.URL, "pastes", "", "us-east-1", "access", "wrong", nil)
+	if err := wrongKey.Put("abc", []byte("x")); err == nil {
+		t.Error("Expected a request signed with the wrong key to fail")
+	}
+}
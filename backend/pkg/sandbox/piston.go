@@ -0,0 +1,165 @@
+package sandbox
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Limits applied to sandbox executions
+const (
+	requestTimeout  = 30 * time.Second
+	runTimeoutMs    = 10000
+	maxResponseSize = 2 << 20
+)
+
+// MaxStdinBytes is the most stdin an execution may be given
+const MaxStdinBytes = 64 << 10
+
+// ErrUnsupportedLanguage is returned when the runner has no runtime for the language
+var ErrUnsupportedLanguage = errors.New("language not supported by sandbox")
+
+// ErrStdinTooLarge is returned for stdin over MaxStdinBytes
+var ErrStdinTooLarge = fmt.Errorf("stdin exceeds %d bytes", MaxStdinBytes)
+
+// languageAliases maps paste language names onto Piston runtime names
+var languageAliases = map[string]string{
+	"golang": "go",
+	"py":     "python",
+	"js":     "javascript",
+	"ts":     "typescript",
+	"rb":     "ruby",
+	"rs":     "rust",
+	"sh":     "bash",
+	"shell":  "bash",
+	"c++":    "cpp",
+}
+
+// Stage is the output of one execution stage (compile or run)
+type Stage struct {
+	Stdout string  `json:"stdout"`
+	Stderr string  `json:"stderr"`
+	Output string  `json:"output"`
+	Code   *int    `json:"code"`
+	Signal *string `json:"signal"`
+}
+
+// Result is the outcome of running a snippet
+type Result struct {
+	Language string `json:"language"`
+	Version  string `json:"version"`
+	Compile  *Stage `json:"compile,omitempty"`
+	Run      Stage  `json:"run"`
+}
+
+// ExitCode returns the exit code of the stage that determined the outcome,
+// or -1 if the process was killed by a signal
+func (r *Result) ExitCode() int {
+	stage := &r.Run
+	if r.Compile != nil && r.Compile.Code != nil && *r.Compile.Code != 0 {
+		stage = r.Compile
+	}
+	if stage.Code == nil {
+		return -1
+	}
+	return *stage.Code
+}
+
+// Output returns the combined output, including compiler errors if compilation failed
+func (r *Result) Output() string {
+	if r.Compile != nil && r.Compile.Code != nil && *r.Compile.Code != 0 {
+		return r.Compile.Output
+	}
+	return r.Run.Output
+}
+
+// Client submits code to a Piston-compatible execution API
+type Client struct {
+	baseURL string
+	client  *http.Client
+}
+
+// NewClient creates a client for the Piston API at baseURL (e.g. https://emkc.org/api/v2/piston)
+func NewClient(baseURL string) *Client {
+	return &Client{
+		baseURL: strings.TrimRight(baseURL, "/"),
+		client:  &http.Client{Timeout: requestTimeout},
+	}
+}
+
+// executeRequest is the Piston execute request body
+type executeRequest struct {
+	Language     string        `json:"language"`
+	Version      string        `json:"version"`
+	Files        []executeFile `json:"files"`
+	Stdin        string        `json:"stdin,omitempty"`
+	RunTimeoutMs int           `json:"run_timeout"`
+}
+
+type executeFile struct {
+	Content string `json:"content"`
+}
+
+// Execute runs code written in language with the given stdin
+func (c *Client) Execute(ctx context.Context, language, code, stdin string) (*Result, error) {
+	if len(stdin) > MaxStdinBytes {
+		return nil, ErrStdinTooLarge
+	}
+
+	lang := strings.ToLower(strings.TrimSpace(language))
+	if alias, ok := languageAliases[lang]; ok {
+		lang = alias
+	}
+	if lang == "" {
+		return nil, ErrUnsupportedLanguage
+	}
+
+	body, err := json.Marshal(executeRequest{
+		Language:     lang,
+		Version:      "*",
+		Files:        []executeFile{{Content: code}},
+		Stdin:        stdin,
+		RunTimeoutMs: runTimeoutMs,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseURL+"/execute", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("sandbox unavailable: %w", err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseSize))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read sandbox response: %w", err)
+	}
+
+	// Piston reports unknown runtimes as 400 with a message
+	if resp.StatusCode == http.StatusBadRequest && bytes.Contains(data, []byte("runtime is unknown")) {
+		return nil, ErrUnsupportedLanguage
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("sandbox returned status %d", resp.StatusCode)
+	}
+
+	var result Result
+	if err := json.Unmarshal(data, &result); err != nil {
+		return nil, fmt.Errorf("invalid sandbox response: %w", err)
+	}
+
+	return &result, nil
+}
@@ -0,0 +1,71 @@
+package sandbox
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func newTestServer(t *testing.T) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req executeRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || r.URL.Path != "/execute" {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		switch req.Language {
+		case "python":
+			w.Write([]byte(`{"language":"python","version":"3.10.0","run":{"stdout":"hi\n","stderr":"","output":"hi\n","code":0,"signal":null}}`))
+		case "c":
+			w.Write([]byte(`{"language":"c","version":"10.2.0","compile":{"output":"error: x","code":1},"run":{"output":"","code":null}}`))
+		default:
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"message":"` + req.Language + `-* runtime is unknown"}`))
+		}
+	}))
+}
+
+func TestExecute(t *testing.T) {
+	server := newTestServer(t)
+	defer server.Close()
+
+	client := NewClient(server.URL)
+
+	result, err := client.Execute(context.Background(), "py", "print('hi')", "")
+	if err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+	if result.Output() != "hi\n" || result.ExitCode() != 0 {
+		t.Errorf("Unexpected result: output=%q code=%d", result.Output(), result.ExitCode())
+	}
+}
+
+func TestExecute_CompileError(t *testing.T) {
+	server := newTestServer(t)
+	defer server.Close()
+
+	result, err := NewClient(server.URL).Execute(context.Background(), "c", "int main(", "")
+	if err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+	if result.Output() != "error: x" || result.ExitCode() != 1 {
+		t.Errorf("Expected compile output and exit code, got output=%q code=%d", result.Output(), result.ExitCode())
+	}
+}
+
+func TestExecute_UnsupportedLanguage(t *testing.T) {
+	server := newTestServer(t)
+	defer server.Close()
+
+	client := NewClient(server.URL)
+
+	if _, err := client.Execute(context.Background(), "cobol", "x", ""); !errors.Is(err, ErrUnsupportedLanguage) {
+		t.Errorf("Expected ErrUnsupportedLanguage, got %v", err)
+	}
+	if _, err := client.Execute(context.Background(), "", "x", ""); !errors.Is(err, ErrUnsupportedLanguage) {
+		t.Errorf("Expected ErrUnsupportedLanguage for empty language, got %v", err)
+	}
+}
@@ -0,0 +1,72 @@
+// Package simhash computes 64-bit SimHash fingerprints of text, which differ
+// in few bits for near-identical texts
+package simhash
+
+import (
+	"hash/fnv"
+	"math/bits"
+	"strings"
+	"unicode"
+)
+
+// shingleSize is the number of consecutive words hashed as one feature, so
+// reordered text still differs
+const shingleSize = 3
+
+// Bands is the number of 16-bit bands a fingerprint is split into for
+// lookups. Fingerprints within Bands-1 bits of each other share at least one
+// band.
+const Bands = 4
+
+// MaxBandedDistance is the largest distance lookups by band are guaranteed
+// to find
+const MaxBandedDistance = Bands - 1
+
+// Fingerprint returns the SimHash of text. Words are compared case
+// insensitively and whitespace and punctuation are ignored.
+func Fingerprint(text string) uint64 {
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+
+	var weights [64]int
+	add := func(feature string) {
+		h := fnv.New64a()
+		h.Write([]byte(feature))
+		sum := h.Sum64()
+		for i := range weights {
+			if sum&(1<<i) != 0 {
+				weights[i]++
+			} else {
+				weights[i]--
+			}
+		}
+	}
+
+	if len(words) < shingleSize {
+		for _, word := range words {
+			add(word)
+		}
+	}
+	for i := 0; i+shingleSize <= len(words); i++ {
+		add(strings.Join(words[i:i+shingleSize], " "))
+	}
+
+	var fingerprint uint64
+	for i, weight := range weights {
+		if weight > 0 {
+			fingerprint |= 1 << i
+		}
+	}
+	return fingerprint
+}
+
+// Distance returns the number of bits in which two fingerprints differ
+func Distance(a, b uint64) int {
+	return bits.OnesCount64(a ^ b)
+}
+
+// Band returns the i-th 16-bit band of a fingerprint
+func Band(fingerprint uint64, i int) uint16 {
+	return uint16(fingerprint >> (16 * i))
+}
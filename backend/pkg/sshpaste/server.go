@@ -0,0 +1,190 @@
+// Package sshpaste serves paste submission over SSH. A client authenticates
+// with a public key registered on its account and pipes content to stdin
+// (ssh paste@host < file); the server stores it and prints the paste's URL.
+// An optional command names the language (ssh paste@host go < main.go).
+package sshpaste
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// Server limits
+const (
+	DefaultMaxSize = 1 << 20
+	sessionTimeout = 2 * time.Minute
+)
+
+// userIDExtension carries the authenticated user through the connection's permissions
+const userIDExtension = "pastevault-user-id"
+
+var errUnknownKey = errors.New("unknown public key")
+
+// Backend resolves keys to users and stores submitted pastes
+type Backend interface {
+	// UserForKey returns the user holding the key with a SHA256 fingerprint
+	UserForKey(fingerprint string) (userID int, ok bool, err error)
+	// CreatePaste stores content for a user and returns its URL. Errors are
+	// shown to the client, so they must not expose internal details.
+	CreatePaste(userID int, content, language string) (string, error)
+}
+
+// Server accepts SSH connections and turns each session's stdin into a paste
+type Server struct {
+	config  *ssh.ServerConfig
+	backend Backend
+	MaxSize int64 // Largest accepted paste in bytes
+}
+
+// NewServer creates an SSH paste server presenting hostKey to clients
+func NewServer(hostKey ssh.Signer, backend Backend) *Server {
+	s := &Server{backend: backend, MaxSize: DefaultMaxSize}
+	s.config = &ssh.ServerConfig{
+		PublicKeyCallback: s.authenticate,
+		ServerVersion:     "SSH-2.0-PasteVault",
+	}
+	s.config.AddHostKey(hostKey)
+	return s
+}
+
+// LoadHostKey reads the server's private host key, generating an Ed25519 key
+// at path on first start so clients see the same host key across restarts
+func LoadHostKey(path string) (ssh.Signer, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		_, key, genErr := ed25519.GenerateKey(rand.Reader)
+		if genErr != nil {
+			return nil, genErr
+		}
+		block, genErr := ssh.MarshalPrivateKey(key, "pastevault host key")
+		if genErr != nil {
+			return nil, genErr
+		}
+		data = pem.EncodeToMemory(block)
+		if err := os.WriteFile(path, data, 0o600); err != nil {
+			return nil, fmt.Errorf("failed to write SSH host key: %w", err)
+		}
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to read SSH host key: %w", err)
+	}
+	return ssh.ParsePrivateKey(data)
+}
+
+// authenticate accepts public keys registered to an account
+func (s *Server) authenticate(_ ssh.ConnMetadata, key ssh.PublicKey) (*ssh.Permissions, error) {
+	userID, ok, err := s.backend.UserForKey(ssh.FingerprintSHA256(key))
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, errUnknownKey
+	}
+	return &ssh.Permissions{Extensions: map[string]string{userIDExtension: strconv.Itoa(userID)}}, nil
+}
+
+// Serve accepts connections on ln until it is closed
+func (s *Server) Serve(ln net.Listener) error {
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			return err
+		}
+		go s.handleConn(conn)
+	}
+}
+
+// handleConn runs the SSH handshake and serves the connection's sessions.
+// The whole connection shares one deadline so idle clients cannot hold it open.
+func (s *Server) handleConn(netConn net.Conn) {
+	defer netConn.Close()
+	netConn.SetDeadline(time.Now().Add(sessionTimeout))
+
+	conn, channels, requests, err := ssh.NewServerConn(netConn, s.config)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+	go ssh.DiscardRequests(requests)
+
+	userID, err := strconv.Atoi(conn.Permissions.Extensions[userIDExtension])
+	if err != nil {
+		return
+	}
+
+	for newChannel := range channels {
+		if newChannel.ChannelType() != "session" {
+			newChannel.Reject(ssh.UnknownChannelType, "only sessions are supported")
+			continue
+		}
+		channel, requests, err := newChannel.Accept()
+		if err != nil {
+			continue
+		}
+		go s.handleSession(userID, channel, requests)
+	}
+}
+
+// handleSession waits for the client to start a shell or command, then reads
+// the paste from stdin and reports its URL and an exit status
+func (s *Server) handleSession(userID int, channel ssh.Channel, requests <-chan *ssh.Request) {
+	defer channel.Close()
+
+	for req := range requests {
+		if req.Type != "exec" && req.Type != "shell" {
+			req.Reply(false, nil)
+			continue
+		}
+
+		var language string
+		if req.Type == "exec" {
+			var payload struct{ Command string }
+			if err := ssh.Unmarshal(req.Payload, &payload); err != nil {
+				req.Reply(false, nil)
+				continue
+			}
+			language = strings.TrimSpace(payload.Command)
+		}
+		req.Reply(true, nil)
+		go ssh.DiscardRequests(requests)
+
+		status := s.submit(userID, language, channel)
+		channel.SendRequest("exit-status", false, ssh.Marshal(struct{ Status uint32 }{status}))
+		return
+	}
+}
+
+// submit stores stdin as a paste and returns the session's exit status
+func (s *Server) submit(userID int, language string, channel ssh.Channel) uint32 {
+	content, err := io.ReadAll(io.LimitReader(channel, s.MaxSize+1))
+	if err != nil {
+		fmt.Fprintln(channel.Stderr(), "error: failed to read paste")
+		return 1
+	}
+	if int64(len(content)) > s.MaxSize {
+		fmt.Fprintf(channel.Stderr(), "error: paste is larger than %d bytes\n", s.MaxSize)
+		return 1
+	}
+
+	url, err := s.backend.CreatePaste(userID, string(content), language)
+	if err != nil {
+		fmt.Fprintln(channel.Stderr(), "error: "+err.Error())
+		return 1
+	}
+	fmt.Fprintln(channel, url)
+	return 0
+}
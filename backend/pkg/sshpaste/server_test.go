@@ -0,0 +1,123 @@
+package sshpaste
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"errors"
+	"net"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/ssh"
+)
+
+type stubBackend struct {
+	users  map[string]int
+	pastes []string
+	langs  []string
+}
+
+func (b *stubBackend) UserForKey(fingerprint string) (int, bool, error) {
+	userID, ok := b.users[fingerprint]
+	return userID, ok, nil
+}
+
+func (b *stubBackend) CreatePaste(userID int, content, language string) (string, error) {
+	if language == "nope" {
+		return "", errors.New("language is not supported")
+	}
+	b.pastes = append(b.pastes, content)
+	b.langs = append(b.langs, language)
+	return "https://paste.example/p/abc123", nil
+}
+
+func newSigner(t *testing.T) ssh.Signer {
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	signer, err := ssh.NewSignerFromKey(key)
+	if err != nil {
+		t.Fatalf("Failed to create signer: %v", err)
+	}
+	return signer
+}
+
+func TestServer_SubmitPaste(t *testing.T) {
+	hostKey, err := LoadHostKey(filepath.Join(t.TempDir(), "host_key"))
+	if err != nil {
+		t.Fatalf("Failed to create host key: %v", err)
+	}
+	clientKey := newSigner(t)
+	backend := &stubBackend{users: map[string]int{ssh.FingerprintSHA256(clientKey.PublicKey()): 7}}
+	server := NewServer(hostKey, backend)
+	server.MaxSize = 16
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	defer ln.Close()
+	go server.Serve(ln)
+
+	dial := func(key ssh.Signer) (*ssh.Client, error) {
+		return ssh.Dial("tcp", ln.Addr().String(), &ssh.ClientConfig{
+			User:            "paste",
+			Auth:            []ssh.AuthMethod{ssh.PublicKeys(key)},
+			HostKeyCallback: ssh.FixedHostKey(hostKey.PublicKey()),
+		})
+	}
+	run := func(client *ssh.Client, command, stdin string) (string, string, error) {
+		session, err := client.NewSession()
+		if err != nil {
+			t.Fatalf("Failed to open session: %v", err)
+		}
+		defer session.Close()
+		var stdout, stderr strings.Builder
+		session.Stdin = strings.NewReader(stdin)
+		session.Stdout = &stdout
+		session.Stderr = &stderr
+		if command == "" {
+			err = session.Shell()
+			if err == nil {
+				err = session.Wait()
+			}
+		} else {
+			err = session.Run(command)
+		}
+		return stdout.String(), stderr.String(), err
+	}
+
+	if _, err := dial(newSigner(t)); err == nil {
+		t.Fatal("Expected an unregistered key to be refused")
+	}
+
+	client, err := dial(clientKey)
+	if err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	defer client.Close()
+
+	stdout, _, err := run(client, "", "hello\n")
+	if err != nil || stdout != "https://paste.example/p/abc123\n" {
+		t.Fatalf("Expected the paste URL, got %q (%v)", stdout, err)
+	}
+	if backend.pastes[0] != "hello\n" || backend.langs[0] != "" {
+		t.Errorf("Unexpected paste %q in %q", backend.pastes[0], backend.langs[0])
+	}
+
+	if _, _, err := run(client, "go", "package main\n"); err != nil || backend.langs[1] != "go" {
+		t.Errorf("Expected the command to set the language, got %v %v", backend.langs, err)
+	}
+
+	_, stderr, err := run(client, "nope", "x")
+	var exitErr *ssh.ExitError
+	if !errors.As(err, &exitErr) || exitErr.ExitStatus() != 1 || !strings.Contains(stderr, "not supported") {
+		t.Errorf("Expected the backend's error with exit status 1, got %q (%v)", stderr, err)
+	}
+
+	if _, stderr, err := run(client, "", strings.Repeat("x", 17)); err == nil || !strings.Contains(stderr, "larger than 16 bytes") {
+		t.Errorf("Expected an oversized paste to be refused, got %q (%v)", stderr, err)
+	}
+}
@@ -0,0 +1,143 @@
+package transform
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// MaxOperations limits how many operations a single pipeline may chain
+const MaxOperations = 10
+
+// Operation is a named content transformation
+type Operation struct {
+	Name        string
+	Description string
+	Language    string // Language of the output, empty to keep the input language
+	Apply       func(content string) (string, error)
+}
+
+// operations is the registry of available transforms, keyed by name
+var operations = map[string]Operation{}
+
+// Register adds an operation to the registry, replacing any with the same name
+func Register(op Operation) {
+	operations[op.Name] = op
+}
+
+// Lookup returns the named operation
+func Lookup(name string) (Operation, bool) {
+	op, ok := operations[name]
+	return op, ok
+}
+
+// Operations returns every registered operation sorted by name
+func Operations() []Operation {
+	ops := make([]Operation, 0, len(operations))
+	for _, op := range operations {
+		ops = append(ops, op)
+	}
+	sort.Slice(ops, func(i, j int) bool { return ops[i].Name < ops[j].Name })
+	return ops
+}
+
+// Result is the output of a pipeline run
+type Result struct {
+	Content  string
+	Language string // Output language of the last operation that sets one
+}
+
+// Run applies the named operations to content in order
+func Run(content string, names []string) (*Result, error) {
+	if len(names) == 0 {
+		return nil, fmt.Errorf("at least one operation is required")
+	}
+	if len(names) > MaxOperations {
+		return nil, fmt.Errorf("at most %d operations may be chained", MaxOperations)
+	}
+
+	result := &Result{Content: content}
+	for _, name := range names {
+		op, ok := Lookup(name)
+		if !ok {
+			return nil, fmt.Errorf("unknown operation %q", name)
+		}
+		out, err := op.Apply(result.Content)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		result.Content = out
+		if op.Language != "" {
+			result.Language = op.Language
+		}
+	}
+
+	return result, nil
+}
+
+func init() {
+	Register(Operation{Name: "base64-decode", Description: "Decode standard or URL-safe base64", Apply: base64Decode})
+	Register(Operation{Name: "base64-encode", Description: "Encode as standard base64", Apply: func(s string) (string, error) {
+		return base64.StdEncoding.EncodeToString([]byte(s)), nil
+	}})
+	Register(Operation{Name: "hex-decode", Description: "Decode a hexadecimal string", Apply: func(s string) (string, error) {
+		b, err := hex.DecodeString(strings.Join(strings.Fields(s), ""))
+		return string(b), err
+	}})
+	Register(Operation{Name: "json-pretty", Description: "Pretty-print JSON", Language: "json", Apply: jsonPretty})
+	Register(Operation{Name: "json-minify", Description: "Minify JSON", Language: "json", Apply: func(s string) (string, error) {
+		var buf bytes.Buffer
+		err := json.Compact(&buf, []byte(s))
+		return buf.String(), err
+	}})
+	Register(Operation{Name: "jwt-decode", Description: "Decode a JWT header and claims without verifying it", Language: "json", Apply: jwtDecode})
+	Register(Operation{Name: "url-decode", Description: "Decode percent-encoded text", Apply: url.QueryUnescape})
+	Register(Operation{Name: "url-encode", Description: "Percent-encode text", Apply: func(s string) (string, error) {
+		return url.QueryEscape(s), nil
+	}})
+}
+
+// base64Decode accepts padded or unpadded, standard or URL-safe input
+func base64Decode(s string) (string, error) {
+	s = strings.Join(strings.Fields(s), "")
+	for _, enc := range []*base64.Encoding{base64.StdEncoding, base64.URLEncoding, base64.RawStdEncoding, base64.RawURLEncoding} {
+		if b, err := enc.DecodeString(s); err == nil {
+			return string(b), nil
+		}
+	}
+	return "", fmt.Errorf("input is not valid base64")
+}
+
+// jsonPretty re-indents JSON with two spaces
+func jsonPretty(s string) (string, error) {
+	var buf bytes.Buffer
+	if err := json.Indent(&buf, []byte(strings.TrimSpace(s)), "", "  "); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// jwtDecode decodes the header and payload segments of a JWT
+func jwtDecode(s string) (string, error) {
+	parts := strings.Split(strings.TrimSpace(s), ".")
+	if len(parts) != 3 {
+		return "", fmt.Errorf("input is not a JWT")
+	}
+
+	decoded := make(map[string]json.RawMessage, 2)
+	for i, key := range []string{"header", "payload"} {
+		b, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(parts[i], "="))
+		if err != nil || !json.Valid(b) {
+			return "", fmt.Errorf("JWT %s is not valid base64url JSON", key)
+		}
+		decoded[key] = b
+	}
+
+	out, err := json.MarshalIndent(decoded, "", "  ")
+	return string(out), err
+}
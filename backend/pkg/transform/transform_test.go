@@ -0,0 +1,51 @@
+package transform
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRun_Chain(t *testing.T) {
+	// base64 of {"a":1}
+	result, err := Run("eyJhIjoxfQ==", []string{"base64-decode", "json-pretty"})
+	if err != nil {
+		t.Fatalf("Pipeline failed: %v", err)
+	}
+	if result.Content != "{\n  \"a\": 1\n}" {
+		t.Errorf("Unexpected content: %q", result.Content)
+	}
+	if result.Language != "json" {
+		t.Errorf("Expected json language, got %q", result.Language)
+	}
+}
+
+func TestRun_Errors(t *testing.T) {
+	if _, err := Run("x", nil); err == nil {
+		t.Error("Expected error for empty pipeline")
+	}
+	if _, err := Run("x", []string{"rot13"}); err == nil {
+		t.Error("Expected error for unknown operation")
+	}
+	if _, err := Run("not json", []string{"json-pretty"}); err == nil || !strings.HasPrefix(err.Error(), "json-pretty:") {
+		t.Errorf("Expected operation-prefixed error, got %v", err)
+	}
+}
+
+func TestJWTDecode(t *testing.T) {
+	token := "eyJhbGciOiJIUzI1NiJ9.eyJzdWIiOiIxMjMifQ.c2ln"
+
+	result, err := Run(token, []string{"jwt-decode"})
+	if err != nil {
+		t.Fatalf("Failed to decode JWT: %v", err)
+	}
+	if !strings.Contains(result.Content, `"alg": "HS256"`) || !strings.Contains(result.Content, `"sub": "123"`) {
+		t.Errorf("Unexpected JWT decode output: %s", result.Content)
+	}
+}
+
+func TestURLDecode(t *testing.T) {
+	result, err := Run("a%20b%26c", []string{"url-decode"})
+	if err != nil || result.Content != "a b&c" {
+		t.Errorf("Unexpected url-decode result: %+v, %v", result, err)
+	}
+}
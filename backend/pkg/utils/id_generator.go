@@ -9,6 +9,9 @@ import (
 const (
 	// IDLength is the length of generated paste IDs
 	IDLength = 6
+	// LongIDLength is the length of IDs for unlisted and private pastes
+	// (~119 bits of entropy), making enumeration infeasible
+	LongIDLength = 20
 	// Charset contains all characters used for ID generation
 	Charset = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
 )
@@ -29,7 +32,12 @@ func NewIDGenerator() *IDGenerator {
 
 // Generate creates a new random ID
 func (g *IDGenerator) Generate() (string, error) {
-	result := make([]byte, g.length)
+	return g.generate(g.length)
+}
+
+// generate creates a new random ID of the given length
+func (g *IDGenerator) generate(length int) (string, error) {
+	result := make([]byte, length)
 	charsetLen := big.NewInt(int64(len(g.charset)))
 
 	for i := range result {
@@ -45,10 +53,20 @@ func (g *IDGenerator) Generate() (string, error) {
 
 // GenerateWithCollisionCheck generates a unique ID by checking against a collision checker
 func (g *IDGenerator) GenerateWithCollisionCheck(existsChecker func(string) (bool, error)) (string, error) {
+	return g.generateUnique(g.length, existsChecker)
+}
+
+// GenerateLongWithCollisionCheck generates a unique high-entropy ID of LongIDLength
+func (g *IDGenerator) GenerateLongWithCollisionCheck(existsChecker func(string) (bool, error)) (string, error) {
+	return g.generateUnique(LongIDLength, existsChecker)
+}
+
+// generateUnique generates an ID of the given length that existsChecker reports as unused
+func (g *IDGenerator) generateUnique(length int, existsChecker func(string) (bool, error)) (string, error) {
 	const maxRetries = 10
 
 	for i := 0; i < maxRetries; i++ {
-		id, err := g.Generate()
+		id, err := g.generate(length)
 		if err != nil {
 			return "", err
 		}
@@ -68,7 +86,7 @@ func (g *IDGenerator) GenerateWithCollisionCheck(existsChecker func(string) (boo
 
 // IsValidID checks if an ID matches the expected format
 func (g *IDGenerator) IsValidID(id string) bool {
-	if len(id) != g.length {
+	if len(id) != g.length && len(id) != LongIDLength {
 		return false
 	}
 
@@ -84,3 +84,18 @@ func TestGenerateWithCollisionCheck(t *testing.T) {
 		t.Errorf("Generated ID %s collides with existing ID", id)
 	}
 }
+
+func TestGenerateLongWithCollisionCheck(t *testing.T) {
+	generator := NewIDGenerator()
+
+	id, err := generator.GenerateLongWithCollisionCheck(func(string) (bool, error) { return false, nil })
+	if err != nil {
+		t.Fatalf("Failed to generate long ID: %v", err)
+	}
+	if len(id) != LongIDLength {
+		t.Errorf("Expected ID length %d, got %d", LongIDLength, len(id))
+	}
+	if !generator.IsValidID(id) {
+		t.Errorf("Generated long ID %s should be valid", id)
+	}
+}
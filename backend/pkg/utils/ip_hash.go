@@ -0,0 +1,76 @@
+package utils
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ipHashLength is the number of hex characters kept from each IP hash
+const ipHashLength = 32
+
+// IPHasher turns client IPs into salted hashes so activity can be correlated
+// without storing raw addresses. The salt is derived from a secret and the
+// current rotation period, so hashes stop linking across periods once the
+// salt rotates.
+type IPHasher struct {
+	secret   []byte
+	rotation time.Duration
+	now      func() time.Time
+}
+
+// NewIPHasher creates an IP hasher. A rotation of zero keeps a single salt forever.
+func NewIPHasher(secret string, rotation time.Duration) *IPHasher {
+	return &IPHasher{
+		secret:   []byte(secret),
+		rotation: rotation,
+		now:      time.Now,
+	}
+}
+
+// Hash returns the salted hash of ip for the current rotation period
+func (h *IPHasher) Hash(ip string) string {
+	return h.hashForPeriod(ip, h.period(h.now()))
+}
+
+// Candidates returns the hashes ip may have been stored under in the current
+// and previous rotation periods, for looking up recent activity by IP
+func (h *IPHasher) Candidates(ip string) []string {
+	current := h.period(h.now())
+	if h.rotation <= 0 || current == 0 {
+		return []string{h.hashForPeriod(ip, current)}
+	}
+	return []string{h.hashForPeriod(ip, current), h.hashForPeriod(ip, current-1)}
+}
+
+// period returns the index of the rotation period containing t
+func (h *IPHasher) period(t time.Time) int64 {
+	if h.rotation <= 0 {
+		return 0
+	}
+	return t.Unix() / int64(h.rotation/time.Second)
+}
+
+// hashForPeriod hashes the normalized IP with the salt for the given period
+func (h *IPHasher) hashForPeriod(ip string, period int64) string {
+	saltMAC := hmac.New(sha256.New, h.secret)
+	saltMAC.Write([]byte("ip-salt:" + strconv.FormatInt(period, 10)))
+	salt := saltMAC.Sum(nil)
+
+	mac := hmac.New(sha256.New, salt)
+	mac.Write([]byte(normalizeIP(ip)))
+	return hex.EncodeToString(mac.Sum(nil))[:ipHashLength]
+}
+
+// normalizeIP canonicalizes an address so equivalent forms hash identically
+func normalizeIP(ip string) string {
+	ip = strings.TrimSpace(ip)
+	if parsed := net.ParseIP(ip); parsed != nil {
+		return parsed.String()
+	}
+	return ip
+}
@@ -0,0 +1,46 @@
+package utils
+
+import (
+	"testing"
+	"time"
+)
+
+func TestIPHasher_Hash(t *testing.T) {
+	hasher := NewIPHasher("secret", 0)
+
+	hash := hasher.Hash("203.0.113.7")
+	if len(hash) != ipHashLength {
+		t.Errorf("Expected hash length %d, got %d", ipHashLength, len(hash))
+	}
+	if hash == "203.0.113.7" {
+		t.Error("Hash should not be the raw IP")
+	}
+	if hasher.Hash("203.0.113.7") != hash {
+		t.Error("Hash should be stable within a period")
+	}
+	if hasher.Hash("2001:db8::1") != hasher.Hash("2001:0db8:0:0:0:0:0:1") {
+		t.Error("Equivalent IPv6 forms should hash identically")
+	}
+	if NewIPHasher("other", 0).Hash("203.0.113.7") == hash {
+		t.Error("Different secrets should produce different hashes")
+	}
+}
+
+func TestIPHasher_Rotation(t *testing.T) {
+	now := time.Date(2025, 1, 10, 12, 0, 0, 0, time.UTC)
+	hasher := NewIPHasher("secret", 24*time.Hour)
+	hasher.now = func() time.Time { return now }
+
+	before := hasher.Hash("203.0.113.7")
+
+	now = now.Add(24 * time.Hour)
+	after := hasher.Hash("203.0.113.7")
+	if before == after {
+		t.Error("Hash should change when the salt rotates")
+	}
+
+	candidates := hasher.Candidates("203.0.113.7")
+	if len(candidates) != 2 || candidates[0] != after || candidates[1] != before {
+		t.Errorf("Expected current and previous period hashes, got %v", candidates)
+	}
+}
@@ -0,0 +1,38 @@
+package utils
+
+import "net"
+
+// nonPublicNetworks are ranges that are not routable on the public internet
+// but that net.IP has no predicate for
+var nonPublicNetworks = func() []*net.IPNet {
+	var networks []*net.IPNet
+	for _, cidr := range []string{
+		"0.0.0.0/8",     // "This" network
+		"100.64.0.0/10", // Carrier-grade NAT
+		"192.0.0.0/24",  // IETF protocol assignments
+		"198.18.0.0/15", // Benchmarking
+		"240.0.0.0/4",   // Reserved
+		"64:ff9b::/96",  // NAT64, which can reach IPv4 private ranges
+	} {
+		_, network, _ := net.ParseCIDR(cidr)
+		networks = append(networks, network)
+	}
+	return networks
+}()
+
+// IsPublicIP reports whether an address is reachable on the public internet,
+// as opposed to loopback, private, link-local, multicast or reserved ones.
+// Outgoing requests to user-supplied URLs must only connect to public
+// addresses, so they cannot reach internal services.
+func IsPublicIP(ip net.IP) bool {
+	if ip == nil || ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsInterfaceLocalMulticast() || ip.IsMulticast() {
+		return false
+	}
+	for _, network := range nonPublicNetworks {
+		if network.Contains(ip) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,80 @@
+package utils
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+)
+
+// ResponseSigner signs raw paste content with an Ed25519 key so copies served
+// by mirrors and CDNs can be checked against the origin's published key
+type ResponseSigner struct {
+	key   ed25519.PrivateKey
+	keyID string
+}
+
+// NewResponseSigner creates a response signer using the given key
+func NewResponseSigner(key ed25519.PrivateKey) *ResponseSigner {
+	sum := sha256.Sum256(key.Public().(ed25519.PublicKey))
+	return &ResponseSigner{key: key, keyID: hex.EncodeToString(sum[:8])}
+}
+
+// LoadResponseSigner reads a PKCS #8 PEM Ed25519 key, generating one at path
+// on first start so the published key survives restarts
+func LoadResponseSigner(path string) (*ResponseSigner, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		_, key, genErr := ed25519.GenerateKey(rand.Reader)
+		if genErr != nil {
+			return nil, genErr
+		}
+		der, genErr := x509.MarshalPKCS8PrivateKey(key)
+		if genErr != nil {
+			return nil, genErr
+		}
+		data = pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+		if err := os.WriteFile(path, data, 0o600); err != nil {
+			return nil, fmt.Errorf("failed to write response signing key: %w", err)
+		}
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to read response signing key: %w", err)
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("response signing key is not PEM encoded")
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse response signing key: %w", err)
+	}
+	key, ok := parsed.(ed25519.PrivateKey)
+	if !ok {
+		return nil, errors.New("response signing key is not an Ed25519 key")
+	}
+	return NewResponseSigner(key), nil
+}
+
+// Sign returns the base64 Ed25519 signature of content
+func (s *ResponseSigner) Sign(content []byte) string {
+	return base64.StdEncoding.EncodeToString(ed25519.Sign(s.key, content))
+}
+
+// PublicKey returns the base64 raw Ed25519 public key signatures verify against
+func (s *ResponseSigner) PublicKey() string {
+	return base64.StdEncoding.EncodeToString(s.key.Public().(ed25519.PublicKey))
+}
+
+// KeyID returns a short identifier of the public key, sent with signatures
+// so verifiers can tell when the key has been rotated
+func (s *ResponseSigner) KeyID() string {
+	return s.keyID
+}
@@ -0,0 +1,33 @@
+package utils
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"path/filepath"
+	"testing"
+)
+
+func TestResponseSigner(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "signing.pem")
+	signer, err := LoadResponseSigner(path)
+	if err != nil {
+		t.Fatalf("Failed to create signing key: %v", err)
+	}
+
+	publicKey, _ := base64.StdEncoding.DecodeString(signer.PublicKey())
+	signature, _ := base64.StdEncoding.DecodeString(signer.Sign([]byte("content")))
+	if !ed25519.Verify(publicKey, []byte("content"), signature) {
+		t.Error("Expected the signature to verify against the published key")
+	}
+	if ed25519.Verify(publicKey, []byte("tampered"), signature) {
+		t.Error("Expected the signature not to verify other content")
+	}
+
+	reloaded, err := LoadResponseSigner(path)
+	if err != nil {
+		t.Fatalf("Failed to reload signing key: %v", err)
+	}
+	if reloaded.PublicKey() != signer.PublicKey() || reloaded.KeyID() != signer.KeyID() {
+		t.Error("Expected the stored key to be reused")
+	}
+}
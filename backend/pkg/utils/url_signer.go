@@ -0,0 +1,61 @@
+package utils
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"strconv"
+	"time"
+)
+
+// Errors returned when verifying signed URLs
+var (
+	ErrSignatureMissing = errors.New("signature required")
+	ErrSignatureInvalid = errors.New("invalid signature")
+	ErrSignatureExpired = errors.New("signature expired")
+)
+
+// URLSigner creates and verifies expiring HMAC signatures for paste URLs
+type URLSigner struct {
+	secret []byte
+}
+
+// NewURLSigner creates a URL signer using the given secret
+func NewURLSigner(secret string) *URLSigner {
+	return &URLSigner{secret: []byte(secret)}
+}
+
+// Sign returns the signature authorizing access to the paste until expires
+func (s *URLSigner) Sign(pasteID string, expires time.Time) string {
+	return s.sign(pasteID, strconv.FormatInt(expires.Unix(), 10))
+}
+
+// Verify checks a signature and its unix expiry timestamp for the paste
+func (s *URLSigner) Verify(pasteID, expires, signature string) error {
+	if expires == "" || signature == "" {
+		return ErrSignatureMissing
+	}
+
+	expected := s.sign(pasteID, expires)
+	if !hmac.Equal([]byte(expected), []byte(signature)) {
+		return ErrSignatureInvalid
+	}
+
+	unix, err := strconv.ParseInt(expires, 10, 64)
+	if err != nil {
+		return ErrSignatureInvalid
+	}
+	if time.Now().Unix() > unix {
+		return ErrSignatureExpired
+	}
+
+	return nil
+}
+
+// sign computes the URL-safe HMAC over the paste ID and expiry
+func (s *URLSigner) sign(pasteID, expires string) string {
+	mac := hmac.New(sha256.New, s.secret)
+	mac.Write([]byte(pasteID + "\n" + expires))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,34 @@
+package utils
+
+import (
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestURLSigner(t *testing.T) {
+	signer := NewURLSigner("secret")
+	expires := time.Now().Add(time.Hour)
+	expiresStr := strconv.FormatInt(expires.Unix(), 10)
+
+	signature := signer.Sign("abc123", expires)
+
+	if err := signer.Verify("abc123", expiresStr, signature); err != nil {
+		t.Errorf("Expected valid signature, got %v", err)
+	}
+	if err := signer.Verify("xyz789", expiresStr, signature); err != ErrSignatureInvalid {
+		t.Errorf("Expected ErrSignatureInvalid for another paste, got %v", err)
+	}
+	if err := signer.Verify("abc123", expiresStr+"0", signature); err != ErrSignatureInvalid {
+		t.Errorf("Expected ErrSignatureInvalid for tampered expiry, got %v", err)
+	}
+	if err := signer.Verify("abc123", "", ""); err != ErrSignatureMissing {
+		t.Errorf("Expected ErrSignatureMissing, got %v", err)
+	}
+
+	past := time.Now().Add(-time.Minute)
+	pastStr := strconv.FormatInt(past.Unix(), 10)
+	if err := signer.Verify("abc123", pastStr, signer.Sign("abc123", past)); err != ErrSignatureExpired {
+		t.Errorf("Expected ErrSignatureExpired, got %v", err)
+	}
+}
@@ -0,0 +1,192 @@
+package validation
+
+import (
+	"math"
+	"strings"
+	"unicode"
+)
+
+// PasswordStrength is an estimate of how hard a password is to guess, meant
+// to inform the person choosing it rather than to reject it
+type PasswordStrength struct {
+	Score    int      `json:"score"` // 0 (very weak) to 4 (very strong)
+	Warnings []string `json:"warnings,omitempty"`
+}
+
+// Entropy in bits needed to reach scores 1 through 4
+var strengthThresholds = [...]float64{25, 35, 45, 60}
+
+// commonPasswords are matched anywhere in a password, ignoring case
+var commonPasswords = []string{
+	"password", "passw0rd", "123456", "qwerty", "abc123", "letmein", "welcome",
+	"monkey", "dragon", "football", "baseball", "iloveyou", "admin", "login",
+	"master", "sunshine", "princess", "shadow", "secret", "trustno1", "hello",
+	"freedom", "whatever", "superman", "starwars", "changeme", "default",
+}
+
+// keyboardRows are walked left to right or right to left in keyboard patterns
+var keyboardRows = []string{"1234567890", "qwertyuiop", "asdfghjkl", "zxcvbnm"}
+
+// minPatternLength is the shortest repeat, sequence or keyboard walk counted
+// as a pattern
+const minPatternLength = 3
+
+// EstimatePasswordStrength scores a password in the manner of zxcvbn: each
+// character is worth the entropy of its character set, except characters
+// that continue a common password, a repeat, a sequence, a keyboard walk or a
+// year, which are worth a single bit.
+func EstimatePasswordStrength(password string) PasswordStrength {
+	runes := []rune(password)
+	lower := make([]rune, len(runes))
+	for i, r := range runes {
+		lower[i] = unicode.ToLower(r)
+	}
+
+	var strength PasswordStrength
+	predictable := make([]bool, len(runes))
+	warn := func(message string) {
+		for _, existing := range strength.Warnings {
+			if existing == message {
+				return
+			}
+		}
+		strength.Warnings = append(strength.Warnings, message)
+	}
+
+	for _, word := range commonPasswords {
+		if string(lower) == word {
+			strength.Warnings = []string{"is a commonly used password"}
+			return strength
+		}
+		if markMatches(lower, []rune(word), predictable) {
+			warn("contains a commonly used password")
+		}
+	}
+
+	markRuns(lower, predictable, func(prev, next rune) bool { return prev == next }, func() {
+		warn("repeated characters like \"aaa\" are easy to guess")
+	})
+	markRuns(lower, predictable, func(prev, next rune) bool { return next-prev == 1 || prev-next == 1 }, func() {
+		warn("sequences like \"abc\" or \"654\" are easy to guess")
+	})
+	markRuns(lower, predictable, adjacentOnKeyboard, func() {
+		warn("keyboard patterns like \"qwerty\" are easy to guess")
+	})
+	for i := 0; i+4 <= len(lower); i++ {
+		if isRecentYear(lower[i : i+4]) {
+			for j := i + 1; j < i+4; j++ {
+				predictable[j] = true
+			}
+			warn("years are easy to guess")
+		}
+	}
+
+	if len(runes) < 8 {
+		warn("short passwords are easy to guess")
+	}
+
+	perChar := math.Log2(float64(charsetSize(runes)))
+	var bits float64
+	for i := range runes {
+		if predictable[i] {
+			bits++
+		} else {
+			bits += perChar
+		}
+	}
+	for _, threshold := range strengthThresholds {
+		if bits >= threshold {
+			strength.Score++
+		}
+	}
+	return strength
+}
+
+// markMatches marks every occurrence of word in s after its first character,
+// reporting whether there were any
+func markMatches(s, word []rune, predictable []bool) bool {
+	found := false
+	for i := 0; i+len(word) <= len(s); i++ {
+		if string(s[i:i+len(word)]) == string(word) {
+			for j := i + 1; j < i+len(word); j++ {
+				predictable[j] = true
+			}
+			found = true
+		}
+	}
+	return found
+}
+
+// markRuns marks the characters continuing each run of at least
+// minPatternLength characters in which every adjacent pair satisfies follows,
+// calling found for each run
+func markRuns(s []rune, predictable []bool, follows func(prev, next rune) bool, found func()) {
+	start := 0
+	for i := 1; i <= len(s); i++ {
+		if i < len(s) && follows(s[i-1], s[i]) {
+			continue
+		}
+		if i-start >= minPatternLength {
+			for j := start + 1; j < i; j++ {
+				predictable[j] = true
+			}
+			found()
+		}
+		start = i
+	}
+}
+
+// adjacentOnKeyboard reports whether next is beside prev on a keyboard row
+func adjacentOnKeyboard(prev, next rune) bool {
+	for _, row := range keyboardRows {
+		i := strings.IndexRune(row, prev)
+		if i < 0 {
+			continue
+		}
+		j := strings.IndexRune(row, next)
+		return j >= 0 && (j == i+1 || j == i-1)
+	}
+	return false
+}
+
+// isRecentYear reports whether s is a year from 1900 to 2099
+func isRecentYear(s []rune) bool {
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	prefix := string(s[:2])
+	return prefix == "19" || prefix == "20"
+}
+
+// charsetSize is the size of the character set an attacker would have to
+// search for a password using the same kinds of characters
+func charsetSize(runes []rune) int {
+	var lower, upper, digit, symbol, other bool
+	for _, r := range runes {
+		switch {
+		case r >= 'a' && r <= 'z':
+			lower = true
+		case r >= 'A' && r <= 'Z':
+			upper = true
+		case r >= '0' && r <= '9':
+			digit = true
+		case r < unicode.MaxASCII:
+			symbol = true
+		default:
+			other = true
+		}
+	}
+
+	size := 1
+	for _, set := range []struct {
+		used bool
+		size int
+	}{{lower, 26}, {upper, 26}, {digit, 10}, {symbol, 33}, {other, 100}} {
+		if set.used {
+			size += set.size
+		}
+	}
+	return size
+}
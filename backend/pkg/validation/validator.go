@@ -1,7 +1,10 @@
 package validation
 
 import (
+	"encoding/base64"
 	"fmt"
+	"net"
+	"slices"
 	"strings"
 	"time"
 )
@@ -41,12 +44,42 @@ func (e *ValidationErrors) Add(field, message string) {
 	*e = append(*e, ValidationError{Field: field, Message: message})
 }
 
+// Username length limits
+const (
+	DefaultUsernameMinLength = 3
+	maxUsernameLength        = 50
+)
+
+// DefaultReservedUsernames are names nobody may register or rename to, since
+// they could pass for the instance's staff or its endpoints
+var DefaultReservedUsernames = []string{
+	"abuse", "admin", "administrator", "anonymous", "api", "help", "hostmaster",
+	"mail", "no-reply", "noreply", "null", "official", "pastevault", "postmaster",
+	"privatepaste", "root", "security", "staff", "support", "system", "undefined",
+	"webmaster", "www",
+}
+
 // Validator provides validation utilities
-type Validator struct{}
+type Validator struct {
+	usernameMinLength int
+	reservedUsernames map[string]bool // Lowercase
+}
 
 // NewValidator creates a new validator instance
 func NewValidator() *Validator {
-	return &Validator{}
+	v := &Validator{}
+	v.SetUsernamePolicy(DefaultUsernameMinLength, DefaultReservedUsernames)
+	return v
+}
+
+// SetUsernamePolicy replaces the minimum username length and the reserved
+// usernames, which are matched case-insensitively
+func (v *Validator) SetUsernamePolicy(minLength int, reserved []string) {
+	v.usernameMinLength = minLength
+	v.reservedUsernames = make(map[string]bool, len(reserved))
+	for _, name := range reserved {
+		v.reservedUsernames[strings.ToLower(name)] = true
+	}
 }
 
 // ValidateString validates string fields with various constraints
@@ -72,7 +105,7 @@ func (v *Validator) ValidateString(value, fieldName string, required bool, minLe
 
 // ValidateUsername validates username format and constraints
 func (v *Validator) ValidateUsername(username string) *ValidationError {
-	if err := v.ValidateString(username, "username", true, 3, 50); err != nil {
+	if err := v.ValidateString(username, "username", true, v.usernameMinLength, maxUsernameLength); err != nil {
 		return err
 	}
 
@@ -89,6 +122,18 @@ func (v *Validator) ValidateUsername(username string) *ValidationError {
 	return nil
 }
 
+// ValidateNewUsername validates a username a user chose, at registration or
+// when renaming, which must also not be reserved
+func (v *Validator) ValidateNewUsername(username string) *ValidationError {
+	if err := v.ValidateUsername(username); err != nil {
+		return err
+	}
+	if v.reservedUsernames[strings.ToLower(username)] {
+		return &ValidationError{Field: "username", Message: "is reserved"}
+	}
+	return nil
+}
+
 // ValidatePassword validates password strength
 func (v *Validator) ValidatePassword(password string) *ValidationError {
 	if err := v.ValidateString(password, "password", true, 8, 128); err != nil {
@@ -122,6 +167,200 @@ func (v *Validator) ValidatePassword(password string) *ValidationError {
 	return nil
 }
 
+// maxPasswordHintLength caps the hint shown to visitors of a protected paste
+const maxPasswordHintLength = 100
+
+// ValidatePasswordHint validates the optional hint for a paste password,
+// which needs a password and must not give it away outright
+func (v *Validator) ValidatePasswordHint(hint, password string) *ValidationError {
+	if hint == "" {
+		return nil
+	}
+	if password == "" {
+		return &ValidationError{Field: "password_hint", Message: "requires a password"}
+	}
+	if err := v.ValidateString(hint, "password_hint", false, 0, maxPasswordHintLength); err != nil {
+		return err
+	}
+	if strings.Contains(strings.ToLower(hint), strings.ToLower(password)) {
+		return &ValidationError{Field: "password_hint", Message: "must not contain the password"}
+	}
+	return nil
+}
+
+// maxUnlockAttempts caps the failed unlock attempts a creator may allow
+// before a paste is destroyed
+const maxUnlockAttempts = 100
+
+// ValidateMaxUnlockAttempts validates the number of wrong passwords after
+// which a paste is destroyed, where zero allows any number
+func (v *Validator) ValidateMaxUnlockAttempts(attempts int, password string) *ValidationError {
+	if attempts == 0 {
+		return nil
+	}
+	if password == "" {
+		return &ValidationError{Field: "max_unlock_attempts", Message: "requires a password"}
+	}
+	if attempts < 1 || attempts > maxUnlockAttempts {
+		return &ValidationError{Field: "max_unlock_attempts", Message: fmt.Sprintf("must be between 1 and %d", maxUnlockAttempts)}
+	}
+	return nil
+}
+
+// ValidateAccessWindow validates a daily UTC access window written as
+// "HH:MM-HH:MM", returning its start and end in minutes after midnight. The
+// end may be earlier than the start for a window spanning midnight.
+func (v *Validator) ValidateAccessWindow(window string) (int, int, *ValidationError) {
+	invalid := &ValidationError{Field: "access_window", Message: "must be two different UTC times like 09:00-17:00"}
+	startText, endText, found := strings.Cut(window, "-")
+	if !found {
+		return 0, 0, invalid
+	}
+	start, err := time.Parse("15:04", startText)
+	if err != nil {
+		return 0, 0, invalid
+	}
+	end, err := time.Parse("15:04", endText)
+	if err != nil || end.Equal(start) {
+		return 0, 0, invalid
+	}
+	return start.Hour()*60 + start.Minute(), end.Hour()*60 + end.Minute(), nil
+}
+
+// maxLocationRestrictions caps the networks and the countries a paste may
+// be restricted to
+const maxLocationRestrictions = 20
+
+// ValidateAllowedNetworks validates the CIDR ranges a paste may be viewed
+// from, returning them in canonical form. Single addresses are accepted as
+// ranges of one.
+func (v *Validator) ValidateAllowedNetworks(networks []string) ([]string, *ValidationError) {
+	if len(networks) > maxLocationRestrictions {
+		return nil, &ValidationError{Field: "allowed_networks", Message: fmt.Sprintf("must have at most %d entries", maxLocationRestrictions)}
+	}
+	canonical := make([]string, 0, len(networks))
+	for _, network := range networks {
+		network = strings.TrimSpace(network)
+		if ip := net.ParseIP(network); ip != nil {
+			if ip.To4() != nil {
+				network += "/32"
+			} else {
+				network += "/128"
+			}
+		}
+		_, ipNet, err := net.ParseCIDR(network)
+		if err != nil {
+			return nil, &ValidationError{Field: "allowed_networks", Message: "must be IP addresses or CIDR ranges like 10.0.0.0/8"}
+		}
+		canonical = append(canonical, ipNet.String())
+	}
+	return canonical, nil
+}
+
+// ValidateAllowedCountries validates the ISO 3166 country codes a paste may
+// be viewed from, returning them upper-cased
+func (v *Validator) ValidateAllowedCountries(countries []string) ([]string, *ValidationError) {
+	if len(countries) > maxLocationRestrictions {
+		return nil, &ValidationError{Field: "allowed_countries", Message: fmt.Sprintf("must have at most %d entries", maxLocationRestrictions)}
+	}
+	codes := make([]string, 0, len(countries))
+	for _, country := range countries {
+		code := strings.ToUpper(strings.TrimSpace(country))
+		if len(code) != 2 || code[0] < 'A' || code[0] > 'Z' || code[1] < 'A' || code[1] > 'Z' {
+			return nil, &ValidationError{Field: "allowed_countries", Message: "must be two-letter ISO country codes"}
+		}
+		codes = append(codes, code)
+	}
+	return codes, nil
+}
+
+// ValidateEmbedDomains validates the domains allowed to embed a paste,
+// returning them lower-cased
+func (v *Validator) ValidateEmbedDomains(domains []string) ([]string, *ValidationError) {
+	if len(domains) > maxLocationRestrictions {
+		return nil, &ValidationError{Field: "embed_domains", Message: fmt.Sprintf("must have at most %d entries", maxLocationRestrictions)}
+	}
+	normalized := make([]string, 0, len(domains))
+	for _, domain := range domains {
+		domain = strings.ToLower(strings.TrimSpace(domain))
+		if !isDomainName(domain) {
+			return nil, &ValidationError{Field: "embed_domains", Message: "must be domain names like docs.example.com"}
+		}
+		normalized = append(normalized, domain)
+	}
+	return normalized, nil
+}
+
+// isDomainName reports whether s is a lowercase host name made of
+// dot-separated labels of letters, digits and inner hyphens
+func isDomainName(s string) bool {
+	if s == "" || len(s) > 253 {
+		return false
+	}
+	for _, label := range strings.Split(s, ".") {
+		if label == "" || len(label) > 63 || label[0] == '-' || label[len(label)-1] == '-' {
+			return false
+		}
+		for _, c := range label {
+			if (c < 'a' || c > 'z') && (c < '0' || c > '9') && c != '-' {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// Limits on the tags of a paste
+const (
+	maxTagsPerPaste = 10
+	maxTagLength    = 32
+)
+
+// ValidateTags validates the tags of a paste, returning them lowercased
+// without duplicates. Tags are lowercase letters, digits, dots, hyphens and
+// underscores, starting with a letter or digit.
+func (v *Validator) ValidateTags(tags []string) ([]string, *ValidationError) {
+	if len(tags) > maxTagsPerPaste {
+		return nil, &ValidationError{Field: "tags", Message: fmt.Sprintf("must have at most %d entries", maxTagsPerPaste)}
+	}
+	normalized := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if tag == "" || len(tag) > maxTagLength {
+			return nil, &ValidationError{Field: "tags", Message: fmt.Sprintf("must be 1 to %d characters each", maxTagLength)}
+		}
+		for i, c := range tag {
+			alnum := (c >= 'a' && c <= 'z') || (c >= '0' && c <= '9')
+			if !alnum && (i == 0 || (c != '.' && c != '-' && c != '_')) {
+				return nil, &ValidationError{Field: "tags", Message: "must contain only letters, digits, dots, hyphens and underscores, and start with a letter or digit"}
+			}
+		}
+		if !slices.Contains(normalized, tag) {
+			normalized = append(normalized, tag)
+		}
+	}
+	return normalized, nil
+}
+
+// maxAttachmentNameLength caps the file names of paste attachments
+const maxAttachmentNameLength = 100
+
+// ValidateAttachmentName validates the file name of a paste attachment,
+// which appears in its URL and download headers: letters, digits, dots,
+// hyphens and underscores, starting with a letter or digit
+func (v *Validator) ValidateAttachmentName(name string) *ValidationError {
+	if name == "" || len(name) > maxAttachmentNameLength {
+		return &ValidationError{Field: "name", Message: fmt.Sprintf("must be 1 to %d characters", maxAttachmentNameLength)}
+	}
+	for i, c := range name {
+		alnum := (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9')
+		if !alnum && (i == 0 || (c != '.' && c != '-' && c != '_')) {
+			return &ValidationError{Field: "name", Message: "must contain only letters, digits, dots, hyphens and underscores, and start with a letter or digit"}
+		}
+	}
+	return nil
+}
+
 // ValidatePasteContent validates paste content
 func (v *Validator) ValidatePasteContent(content string) *ValidationError {
 	if err := v.ValidateString(content, "content", true, 1, 1000000); err != nil { // 1MB limit
@@ -131,6 +370,29 @@ func (v *Validator) ValidatePasteContent(content string) *ValidationError {
 	return nil
 }
 
+// Bounds on how far ahead a paste may expire
+const (
+	minExpiry = time.Minute
+	MaxExpiry = 365 * 24 * time.Hour
+)
+
+// ValidateExpiresAt validates an absolute expiry given as an RFC3339
+// timestamp with a UTC offset, such as "2026-10-23T17:00:00+02:00". It must
+// fall within the same bounds as expiry durations, counted from now.
+func (v *Validator) ValidateExpiresAt(expiresAt string, now time.Time) (*time.Time, *ValidationError) {
+	t, err := time.Parse(time.RFC3339, expiresAt)
+	if err != nil {
+		return nil, &ValidationError{Field: "expires_at", Message: "must be an RFC3339 timestamp like '2026-10-23T17:00:00+02:00'"}
+	}
+	if t.Sub(now) < minExpiry {
+		return nil, &ValidationError{Field: "expires_at", Message: "must be at least 1 minute in the future"}
+	}
+	if t.Sub(now) > MaxExpiry {
+		return nil, &ValidationError{Field: "expires_at", Message: "cannot be more than 1 year in the future"}
+	}
+	return &t, nil
+}
+
 // ValidateExpiryDuration validates expiry duration strings
 func (v *Validator) ValidateExpiryDuration(duration string) (*time.Duration, *ValidationError) {
 	if duration == "" {
@@ -155,7 +417,7 @@ func (v *Validator) ValidateExpiryDuration(duration string) (*time.Duration, *Va
 		hours := time.Duration(days) * 24 * time.Hour
 
 		// Check maximum duration (1 year)
-		if hours > 365*24*time.Hour {
+		if hours > MaxExpiry {
 			return nil, &ValidationError{Field: "expiry", Message: "expiry duration cannot exceed 1 year"}
 		}
 
@@ -169,12 +431,12 @@ func (v *Validator) ValidateExpiryDuration(duration string) (*time.Duration, *Va
 	}
 
 	// Check minimum duration (1 minute)
-	if d < time.Minute {
+	if d < minExpiry {
 		return nil, &ValidationError{Field: "expiry", Message: "expiry duration must be at least 1 minute"}
 	}
 
 	// Check maximum duration (1 year)
-	if d > 365*24*time.Hour {
+	if d > MaxExpiry {
 		return nil, &ValidationError{Field: "expiry", Message: "expiry duration cannot exceed 1 year"}
 	}
 
@@ -183,9 +445,13 @@ func (v *Validator) ValidateExpiryDuration(duration string) (*time.Duration, *Va
 
 // ValidateID validates paste ID format
 func (v *Validator) ValidateID(id string) *ValidationError {
-	if err := v.ValidateString(id, "id", true, 6, 6); err != nil {
+	// Public pastes use 6 character IDs; unlisted and private ones use 16-22
+	if err := v.ValidateString(id, "id", true, 6, 22); err != nil {
 		return err
 	}
+	if len(id) > 6 && len(id) < 16 {
+		return &ValidationError{Field: "id", Message: "must be 6 or 16-22 characters long"}
+	}
 
 	// Check for valid characters (alphanumeric only)
 	for _, char := range id {
@@ -221,6 +487,89 @@ func (v *Validator) ValidateLanguage(language string) *ValidationError {
 	return nil
 }
 
+// AllowedThemes lists the syntax themes a creator may choose for a paste
+var AllowedThemes = []string{
+	"github-light",
+	"github-dark",
+	"monokai",
+	"dracula",
+	"solarized-light",
+	"solarized-dark",
+	"nord",
+}
+
+// ValidateTheme validates the syntax theme render preference
+func (v *Validator) ValidateTheme(theme string) *ValidationError {
+	if theme == "" {
+		return nil // Optional field, viewer default applies
+	}
+
+	for _, allowed := range AllowedThemes {
+		if theme == allowed {
+			return nil
+		}
+	}
+
+	return &ValidationError{Field: "theme", Message: fmt.Sprintf("must be one of: %s", strings.Join(AllowedThemes, ", "))}
+}
+
+// ValidateKind validates the paste kind
+func (v *Validator) ValidateKind(kind string) *ValidationError {
+	switch kind {
+	case "", "text", "diff":
+		return nil
+	default:
+		return &ValidationError{Field: "kind", Message: "must be one of: text, diff"}
+	}
+}
+
+// ValidateVisibility validates the paste visibility
+func (v *Validator) ValidateVisibility(visibility string) *ValidationError {
+	switch visibility {
+	case "", "public", "unlisted", "private":
+		return nil
+	default:
+		return &ValidationError{Field: "visibility", Message: "must be one of: public, unlisted, private"}
+	}
+}
+
+// maxSignatureLength bounds detached signatures attached to pastes
+const maxSignatureLength = 16384
+
+// SignatureFormat detects the format of a detached signature: "pgp" for an
+// ASCII-armored OpenPGP signature, "minisign" for a minisign signature file,
+// or "" if it is neither
+func SignatureFormat(signature string) string {
+	signature = strings.TrimSpace(signature)
+	if strings.HasPrefix(signature, "-----BEGIN PGP SIGNATURE-----") &&
+		strings.HasSuffix(signature, "-----END PGP SIGNATURE-----") {
+		return "pgp"
+	}
+
+	// An untrusted comment, then the base64 algorithm, key ID and signature
+	lines := strings.Split(signature, "\n")
+	if len(lines) >= 2 && strings.HasPrefix(lines[0], "untrusted comment:") {
+		if raw, err := base64.StdEncoding.DecodeString(strings.TrimSpace(lines[1])); err == nil && len(raw) == 74 {
+			return "minisign"
+		}
+	}
+	return ""
+}
+
+// ValidateSignature validates an optional detached content signature
+func (v *Validator) ValidateSignature(signature string) *ValidationError {
+	if signature == "" {
+		return nil
+	}
+	if len(signature) > maxSignatureLength {
+		return &ValidationError{Field: "signature", Message: fmt.Sprintf("must be at most %d bytes", maxSignatureLength)}
+	}
+	if SignatureFormat(signature) == "" {
+		return &ValidationError{Field: "signature", Message: "must be an ASCII-armored PGP signature or a minisign signature"}
+	}
+	return nil
+}
+
 // ValidateCreatePasteRequestFull validates a create paste request with all fields
 func (v *Validator) ValidateCreatePasteRequestFull(content, password, expiry, language string) ValidationErrors {
 	var errors ValidationErrors
@@ -261,7 +610,7 @@ func (v *Validator) ValidateUserRegistrationRequest(username, password string) V
 	var errors ValidationErrors
 
 	// Validate username
-	if err := v.ValidateUsername(username); err != nil {
+	if err := v.ValidateNewUsername(username); err != nil {
 		errors.Add(err.Field, err.Message)
 	}
 
@@ -1,6 +1,7 @@
 package validation
 
 import (
+	"strings"
 	"testing"
 	"time"
 )
@@ -89,6 +90,44 @@ func TestValidateExpiryDuration(t *testing.T) {
 	}
 }
 
+func TestValidateExpiresAt(t *testing.T) {
+	validator := NewValidator()
+	now := time.Date(2026, 10, 19, 9, 0, 0, 0, time.UTC)
+
+	testCases := []struct {
+		name          string
+		input         string
+		expectedError bool
+		expected      time.Time
+	}{
+		{"Friday 17:00 CET", "2026-10-23T17:00:00+01:00", false, time.Date(2026, 10, 23, 16, 0, 0, 0, time.UTC)},
+		{"UTC", "2026-10-19T10:00:00Z", false, time.Date(2026, 10, 19, 10, 0, 0, 0, time.UTC)},
+		{"Missing offset", "2026-10-23T17:00:00", true, time.Time{}},
+		{"Date only", "2026-10-23", true, time.Time{}},
+		{"In the past", "2026-10-19T08:00:00Z", true, time.Time{}},
+		{"Too soon", "2026-10-19T09:00:30Z", true, time.Time{}},
+		{"Beyond a year", "2027-11-01T00:00:00Z", true, time.Time{}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			expiresAt, err := validator.ValidateExpiresAt(tc.input, now)
+			if tc.expectedError {
+				if err == nil {
+					t.Errorf("Expected error but got none")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if !expiresAt.Equal(tc.expected) {
+				t.Errorf("Expected %v but got %v", tc.expected, expiresAt)
+			}
+		})
+	}
+}
+
 func TestValidateCreatePasteRequestFull(t *testing.T) {
 	validator := NewValidator()
 
@@ -173,6 +212,16 @@ func TestValidateID(t *testing.T) {
 			id:            "abc1234",
 			expectedError: true,
 		},
+		{
+			name:          "Valid long ID",
+			id:            "aB3dE5gH7jK9mN1pQ3sT",
+			expectedError: false,
+		},
+		{
+			name:          "Too long for a long ID",
+			id:            "aB3dE5gH7jK9mN1pQ3sT5vW",
+			expectedError: true,
+		},
 		{
 			name:          "Invalid characters",
 			id:            "abc@12",
@@ -201,3 +250,134 @@ func TestValidateID(t *testing.T) {
 		})
 	}
 }
+
+func TestValidateTheme(t *testing.T) {
+	validator := NewValidator()
+
+	testCases := []struct {
+		name          string
+		theme         string
+		expectedError bool
+	}{
+		{"Empty uses default", "", false},
+		{"Known theme", "monokai", false},
+		{"Unknown theme", "neon", true},
+		{"Case sensitive", "Monokai", true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := validator.ValidateTheme(tc.theme)
+
+			if tc.expectedError && err == nil {
+				t.Errorf("Expected error but got none")
+			}
+			if !tc.expectedError && err != nil {
+				t.Errorf("Unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+func TestSignatureFormat(t *testing.T) {
+	minisig := "untrusted comment: signature from minisign secret key\n" +
+		"RUQBAgMEBQYHCAABAgMEBQYHCAkKCwwNDg8QERITFBUWFxgZGhscHR4fICEiIyQlJicoKSorLC0uLzAxMjM0NTY3ODk6Ozw9Pj8=\n" +
+		"trusted comment: timestamp:1555779966\tfile:myfile.txt\n" +
+		"2/ldTSh0QdAgl7B7MdMG/n8+Jt6ZnpmMSRQ28yUtRDvqP8f1eJMZ74Ik5GpAmMhehBWNn6GwjC8FRqVzfR6lNBA==\n"
+
+	testCases := []struct {
+		name      string
+		signature string
+		expected  string
+	}{
+		{"PGP armor", "-----BEGIN PGP SIGNATURE-----\n\niQEzBAABCAAdFiEE\n-----END PGP SIGNATURE-----\n", "pgp"},
+		{"Minisign", minisig, "minisign"},
+		{"Truncated minisign", "untrusted comment: x\nRUQf6LRCGA9i\n", ""},
+		{"Arbitrary text", "signed by me", ""},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if format := SignatureFormat(tc.signature); format != tc.expected {
+				t.Errorf("Expected %q, got %q", tc.expected, format)
+			}
+		})
+	}
+
+	if err := NewValidator().ValidateSignature("signed by me"); err == nil {
+		t.Error("Expected an unrecognized signature to be rejected")
+	}
+}
+
+func TestValidateNewUsername(t *testing.T) {
+	v := NewValidator()
+	if err := v.ValidateNewUsername("ADMIN"); err == nil || err.Message != "is reserved" {
+		t.Errorf("Expected a reserved username to be refused regardless of case, got %v", err)
+	}
+	if err := v.ValidateUsername("admin"); err != nil {
+		t.Errorf("Expected existing reserved names to stay valid usernames, got %v", err)
+	}
+	if err := v.ValidateNewUsername("alice"); err != nil {
+		t.Errorf("Expected alice to be accepted, got %v", err)
+	}
+
+	v.SetUsernamePolicy(6, []string{"Ops"})
+	if err := v.ValidateNewUsername("alice"); err == nil {
+		t.Error("Expected a name below the minimum length to be refused")
+	}
+	if err := v.ValidateNewUsername("admin-team"); err != nil {
+		t.Errorf("Expected the built-in list to be replaced, got %v", err)
+	}
+	if err := v.ValidateNewUsername("ops"); err == nil {
+		t.Error("Expected a configured reserved name to be refused")
+	}
+}
+
+func TestEstimatePasswordStrength(t *testing.T) {
+	testCases := []struct {
+		password string
+		score    int
+		warning  string
+	}{
+		{"password", 0, "is a commonly used password"},
+		{"aaaaaaaa", 0, "repeated characters like \"aaa\" are easy to guess"},
+		{"mypassword123", 1, "contains a commonly used password"},
+		{"Summer2024", 2, "years are easy to guess"},
+		{"x7Gq", 0, "short passwords are easy to guess"},
+		{"k8#Lp2!qZr", 4, ""},
+		{"correct horse battery staple", 4, ""},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.password, func(t *testing.T) {
+			strength := EstimatePasswordStrength(tc.password)
+			if strength.Score != tc.score {
+				t.Errorf("Expected score %d, got %d", tc.score, strength.Score)
+			}
+			if tc.warning == "" && len(strength.Warnings) != 0 {
+				t.Errorf("Expected no warnings, got %v", strength.Warnings)
+			}
+			if tc.warning != "" && (len(strength.Warnings) == 0 || strength.Warnings[0] != tc.warning) {
+				t.Errorf("Expected warning %q, got %v", tc.warning, strength.Warnings)
+			}
+		})
+	}
+}
+
+func TestValidateTags(t *testing.T) {
+	v := NewValidator()
+
+	tags, err := v.ValidateTags([]string{" Infra ", "k8s.prod", "infra", "on_call"})
+	if err != nil {
+		t.Fatalf("Expected valid tags, got %v", err)
+	}
+	if strings.Join(tags, ",") != "infra,k8s.prod,on_call" {
+		t.Errorf("Expected tags lowercased without duplicates, got %v", tags)
+	}
+
+	for _, invalid := range [][]string{{""}, {"-infra"}, {"two words"}, {strings.Repeat("a", 33)}, make([]string, 11)} {
+		if _, err := v.ValidateTags(invalid); err == nil || err.Field != "tags" {
+			t.Errorf("Expected %q to be refused, got %v", invalid, err)
+		}
+	}
+}